@@ -1,32 +1,167 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 
 	"julianmorley.ca/con-plar/prog2270/internal/router"
 	"julianmorley.ca/con-plar/prog2270/pkg/ai"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorreport"
 	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/logging"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
 	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/notifications"
+	"julianmorley.ca/con-plar/prog2270/pkg/payments"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+	"julianmorley.ca/con-plar/prog2270/pkg/scheduler"
+	"julianmorley.ca/con-plar/prog2270/pkg/shipping"
+	"julianmorley.ca/con-plar/prog2270/pkg/tracing"
+	"julianmorley.ca/con-plar/prog2270/pkg/workers"
 )
 
 func main() {
 
 	if err := godotenv.Load(); err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+		logging.Fatal("Error loading .env file", "error", err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		logging.Fatal("Failed to load configuration", "error", err)
+	}
+	logging.Init(cfg.Log)
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing, cfg.Env)
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
 	}
 
-	mongo.InitMongoDB()
+	if err := errorreport.Init(cfg.Errors, cfg.Env); err != nil {
+		logging.Fatal("Failed to initialize error reporting", "error", err)
+	}
+
+	models.ConfigurePricing(cfg.Pricing)
+	redis.Configure(cfg.Redis)
+	mongo.InitMongoDB(cfg.Mongo)
 	mongo.EnsureIndexesOnStartup()
-	ai.InitializeAIService()
-	router.InitEngine()
+	mongo.EnsureValidatorsOnStartup()
+	if err := mongo.LoadLoyaltyTierRules(context.Background()); err != nil {
+		slog.Warn("Failed to load loyalty tier rules, using defaults", "error", err)
+	}
+	if err := mongo.LoadTaxRules(context.Background()); err != nil {
+		slog.Warn("Failed to load tax rules, using defaults", "error", err)
+	}
+	ai.InitializeAIService(cfg.AI)
+	payments.Init(cfg.Payments)
+	shipping.Init(cfg.Shipping)
+	notifications.Init(cfg.Notifications)
+	router.InitEngine(cfg)
 	router.InitializeRoutes()
+	if err := workers.RegisterScheduledTasks(cfg.Workers, cfg.Scheduler); err != nil {
+		logging.Fatal("Failed to register scheduled tasks", "error", err)
+	}
+	if err := router.RegisterScheduledTasks(cfg.Scheduler); err != nil {
+		logging.Fatal("Failed to register scheduled tasks", "error", err)
+	}
+
+	// Cancelled on SIGINT or SIGTERM, which stops the workers and starts the shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Each worker returns once ctx is cancelled; the clients stay open until they have
+	var background sync.WaitGroup
+	for _, start := range []func(context.Context, config.WorkersConfig){
+		workers.StartCartPersistenceWorker,
+		workers.StartReportSchedulerWorker,
+		workers.StartDomainEventWorker,
+		workers.StartOutboxRelayWorker,
+		workers.StartCustomerExportWorker,
+		workers.StartProductChangesWorker,
+		workers.StartNotificationWorker,
+		workers.StartImportWorker,
+		workers.StartReportArchiveWorker,
+	} {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			start(ctx, cfg.Workers)
+		}()
+	}
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		scheduler.Start(ctx)
+	}()
+
+	server := &http.Server{
+		Addr:    ":" + cfg.Server.Port,
+		Handler: router.Router,
+	}
+	// Open event streams would otherwise hold Shutdown for the whole grace period
+	server.RegisterOnShutdown(router.CloseStreams)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server is running", "port", cfg.Server.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
+		closeClients()
+		logging.Fatal("Failed to run server", "error", err)
+	case <-ctx.Done():
+		// A second signal kills the process without waiting
+		stop()
+	}
 
-	port := global.GetEnvOrDefault("PORT", "8000")
-	log.Printf("Server is running on port %s", port)
+	slog.Info("Shutting down, waiting for in-flight requests", "grace_period", cfg.Server.ShutdownGrace.String())
 
-	if err := router.Router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to run server: %v", err)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownGrace)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server did not shut down cleanly", "error", err)
+	}
+
+	workersStopped := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(workersStopped)
+	}()
+	select {
+	case <-workersStopped:
+	case <-shutdownCtx.Done():
+		slog.Error("Background workers did not stop within the grace period")
+	}
+
+	closeClients()
+	errorreport.Flush(2 * time.Second)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// closeClients releases the shared MongoDB and Redis connections
+func closeClients() {
+	ctx, cancel := global.GetDefaultTimer()
+	defer cancel()
+
+	if err := mongo.Disconnect(ctx); err != nil {
+		slog.Error("Error disconnecting from MongoDB", "error", err)
+	}
+	if err := redis.Close(); err != nil {
+		slog.Error("Error disconnecting from Redis", "error", err)
 	}
 }
@@ -0,0 +1,97 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+	"julianmorley.ca/con-plar/prog2270/pkg/workers"
+)
+
+// GetAbandonedCarts lists abandoned cart snapshots with pagination
+func GetAbandonedCarts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	result, err := mongo.GetAbandonedCarts(c.Request.Context(), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch abandoned carts", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(result.Carts, result.Pagination, nil))
+}
+
+// StartReviewSentimentJob triggers the background review sentiment analysis job
+func StartReviewSentimentJob(c *gin.Context) {
+	reanalyze := c.Query("reanalyze") == "true"
+
+	status, err := workers.StartReviewSentimentJob(reanalyze)
+	if err != nil {
+		switch err.Error() {
+		case "sentiment job already running":
+			respondWithError(c, "Sentiment job already running", global.ValidationError{Field: "job", Message: err.Error(), Code: errorcodes.AlreadyRunning})
+		case "AI service is not enabled":
+			c.JSON(http.StatusServiceUnavailable, global.ErrorResponse("AI service is not enabled", nil))
+		default:
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to start sentiment job", nil))
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, global.SuccessResponse(status))
+}
+
+// GetReviewSentimentJobStatus reports progress of the review sentiment job
+func GetReviewSentimentJobStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, global.SuccessResponse(workers.GetReviewSentimentJobStatus()))
+}
+
+// ClearAnalyticsCache drops every cached analytics result so the next request recomputes it
+func ClearAnalyticsCache(c *gin.Context) {
+	deleted, err := redis.Analytics.Clear(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to clear analytics cache: "+err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
+		"cleared": deleted,
+	}))
+}
+
+// StartProductEmbeddingJob triggers the background product embedding job
+func StartProductEmbeddingJob(c *gin.Context) {
+	reembed := c.Query("reembed") == "true"
+
+	status, err := workers.StartProductEmbeddingJob(reembed)
+	if err != nil {
+		switch err.Error() {
+		case "embedding job already running":
+			respondWithError(c, "Embedding job already running", global.ValidationError{Field: "job", Message: err.Error(), Code: errorcodes.AlreadyRunning})
+		case "AI service is not enabled":
+			c.JSON(http.StatusServiceUnavailable, global.ErrorResponse("AI service is not enabled", nil))
+		default:
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to start embedding job", nil))
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, global.SuccessResponse(status))
+}
+
+// GetProductEmbeddingJobStatus reports progress of the product embedding job
+func GetProductEmbeddingJobStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, global.SuccessResponse(workers.GetProductEmbeddingJobStatus()))
+}
@@ -0,0 +1,187 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// GetAdmins lists admins, newest first, with when each last used their key
+func GetAdmins(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.AdminInvited, models.AdminActive, models.AdminDeactivated:
+	default:
+		respondWithError(c, "Invalid status parameter", global.ValidationError{Field: "status", Message: "status must be one of: invited, active, deactivated", Code: errorcodes.InvalidValue})
+		return
+	}
+
+	admins, total, err := mongo.GetAdmins(c.Request.Context(), status, page, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching admins", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch admins", nil))
+		return
+	}
+
+	respondWithList(c, "admins", admins, global.NewPagination(page, limit, int(total)), nil)
+}
+
+// InviteAdmin emails someone a link to become an admin with the given roles. Inviting
+// someone who hasn't accepted yet sends a new link that replaces the old one.
+func InviteAdmin(c *gin.Context) {
+	var req models.InviteAdminRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	token, tokenHash := models.NewAdminSecret("")
+	expiresAt := time.Now().Add(adminInviteTTL)
+	invite := &models.Admin{
+		Email:           req.Email,
+		Name:            req.Name,
+		Roles:           req.Roles,
+		InviteTokenHash: tokenHash,
+		InviteExpiresAt: &expiresAt,
+	}
+	if inviter := requestAdmin(c); inviter != nil {
+		invite.InvitedBy = &inviter.ID
+	}
+
+	admin, created, err := mongo.InviteAdmin(c.Request.Context(), invite, adminInvitationURL(token))
+	if err != nil {
+		if errors.Is(err, mongo.ErrDuplicate) {
+			respondWithError(c, "Admin already exists", global.ValidationError{Field: "email", Message: "An active admin already has this email", Code: errorcodes.DuplicateEmail})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error inviting admin", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to invite admin", nil))
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, global.SuccessResponse(admin))
+}
+
+// AcceptAdminInvitation activates an invited admin and returns their personal key. The
+// key is not stored, so this is the only time it can be read.
+func AcceptAdminInvitation(c *gin.Context) {
+	var req models.AcceptAdminInvitationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	key, keyHash := models.NewAdminSecret(models.AdminKeyPrefix)
+	admin, err := mongo.AcceptAdminInvitation(c.Request.Context(), models.HashAdminSecret(req.Token), req.Name, keyHash)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Invitation not found", global.ValidationError{Field: "token", Message: "The invitation has expired, was already accepted or was replaced by a newer one", Code: errorcodes.NotFound})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error accepting admin invitation", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to accept invitation", nil))
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "Admin accepted invitation", "admin_id", admin.ID.Hex())
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, global.SuccessResponse(models.AcceptedAdminInvitation{Admin: admin, Key: key}))
+}
+
+// UpdateAdminRoles replaces an admin's roles
+func UpdateAdminRoles(c *gin.Context) {
+	adminID, ok := adminIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateAdminRolesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	admin, err := mongo.UpdateAdminRoles(c.Request.Context(), adminID, req.Roles)
+	if err != nil {
+		respondWithAdminError(c, adminID, "Failed to update admin roles", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(admin))
+}
+
+// DeactivateAdmin turns off an admin's key and any invitation they haven't accepted.
+// Admins can't deactivate themselves.
+func DeactivateAdmin(c *gin.Context) {
+	adminID, ok := adminIDParam(c)
+	if !ok {
+		return
+	}
+	if current := requestAdmin(c); current != nil && current.ID == adminID {
+		respondWithError(c, "Cannot deactivate yourself", global.ValidationError{Field: "adminId", Message: "Another admin must deactivate this account", Code: errorcodes.InvalidOperation})
+		return
+	}
+
+	admin, err := mongo.DeactivateAdmin(c.Request.Context(), adminID)
+	if err != nil {
+		respondWithAdminError(c, adminID, "Failed to deactivate admin", err)
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "Admin deactivated", "admin_id", adminID.Hex())
+
+	c.JSON(http.StatusOK, global.SuccessResponse(admin))
+}
+
+// adminIDParam parses the :adminId route parameter, responding with an error when it is
+// not an ObjectID
+func adminIDParam(c *gin.Context) (bson.ObjectID, bool) {
+	adminID, err := bson.ObjectIDFromHex(c.Param("adminId"))
+	if err != nil {
+		respondWithError(c, "Invalid admin ID format", global.ValidationError{Field: "adminId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return bson.ObjectID{}, false
+	}
+	return adminID, true
+}
+
+// respondWithAdminError answers a failed change to one admin
+func respondWithAdminError(c *gin.Context, adminID bson.ObjectID, message string, err error) {
+	if errors.Is(err, mongo.ErrNotFound) {
+		respondWithError(c, "Admin not found", global.ValidationError{Field: "adminId", Message: "No admin exists with this ID", Code: errorcodes.NotFound})
+		return
+	}
+	slog.ErrorContext(c.Request.Context(), "Error updating admin", "admin_id", adminID.Hex(), "error", err)
+	c.JSON(http.StatusInternalServerError, global.ErrorResponse(message, nil))
+}
+
+// adminInvitationURL is the accept link sent in an invitation email
+func adminInvitationURL(token string) string {
+	link, err := url.Parse(adminInviteURL)
+	if err != nil {
+		return adminInviteURL + "?token=" + token
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
@@ -0,0 +1,286 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/ai"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+)
+
+// NaturalLanguageQueryRequest is the payload for asking a plain-English analytics question
+type NaturalLanguageQueryRequest struct {
+	Question string `json:"question" binding:"required,min=5,max=500"`
+}
+
+// aiFailureStatus maps an AI error to 503 when the service is disabled or its circuit
+// breaker is open, and to 502 when the provider call itself failed
+func aiFailureStatus(err error) int {
+	if ai.IsUnavailable(err) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
+
+// RunNaturalLanguageQuery turns a plain-English question into a whitelisted aggregation,
+// runs it, and returns the results along with the generated pipeline
+func RunNaturalLanguageQuery(c *gin.Context) {
+	var req NaturalLanguageQueryRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if !ai.IsEnabled() {
+		c.JSON(http.StatusServiceUnavailable, global.ErrorResponse("AI service is not enabled", nil))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	spec, pipeline, err := ai.GenerateQuerySpec(ctx, strings.TrimSpace(req.Question))
+	if err != nil {
+		// A spec with no pipeline means the AI answered but the query failed validation
+		if spec != nil {
+			respondWithError(c, "Generated query was rejected", global.ValidationError{Field: "question", Message: err.Error(), Code: errorcodes.QueryRejected})
+			return
+		}
+		c.JSON(aiFailureStatus(err), global.ErrorResponse("Failed to generate query: "+err.Error(), nil))
+		return
+	}
+
+	results, err := mongo.RunAggregation(ctx, spec.Collection, pipeline)
+	if err != nil {
+		respondWithError(c, "Generated query failed to run", global.ValidationError{Field: "question", Message: err.Error(), Code: errorcodes.QueryFailed})
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(results, global.SinglePage(len(results)), map[string]interface{}{
+		"question":    req.Question,
+		"collection":  spec.Collection,
+		"pipeline":    spec.Pipeline,
+		"explanation": spec.Explanation,
+	}))
+}
+
+// ChatWithAssistant sends a message to the analytics assistant, remembering the conversation per session
+func ChatWithAssistant(c *gin.Context) {
+	var req models.ChatRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if !ai.IsEnabled() {
+		c.JSON(http.StatusServiceUnavailable, global.ErrorResponse("AI service is not enabled", nil))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	history, err := redis.GetChatHistory(ctx, req.SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to load conversation: "+err.Error(), nil))
+		return
+	}
+
+	userMessage := models.ChatMessage{Role: "user", Content: strings.TrimSpace(req.Message), CreatedAt: time.Now()}
+
+	reply, err := ai.Chat(ctx, history, userMessage.Content)
+	if err != nil {
+		c.JSON(aiFailureStatus(err), global.ErrorResponse("Failed to generate reply: "+err.Error(), nil))
+		return
+	}
+
+	assistantMessage := models.ChatMessage{Role: "assistant", Content: reply, CreatedAt: time.Now()}
+	if err := redis.AppendChatMessages(ctx, req.SessionID, userMessage, assistantMessage); err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to save conversation: "+err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
+		"session_id": req.SessionID,
+		"reply":      assistantMessage,
+	}))
+}
+
+// GetChatHistory returns the remembered messages for an assistant conversation
+func GetChatHistory(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	history, err := redis.GetChatHistory(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to load conversation: "+err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(history, global.SinglePage(len(history)), map[string]interface{}{
+		"session_id": sessionID,
+	}))
+}
+
+// ClearChatHistory forgets an assistant conversation
+func ClearChatHistory(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	if err := redis.ClearChatHistory(c.Request.Context(), sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to clear conversation: "+err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
+		"session_id": sessionID,
+		"cleared":    true,
+	}))
+}
+
+// GenerateProductDescription drafts AI marketing copy for a product. The draft is held for
+// 24 hours until an admin accepts it; the product is not changed yet.
+func GenerateProductDescription(c *gin.Context) {
+	sku := c.Param("sku")
+
+	if !ai.IsEnabled() {
+		c.JSON(http.StatusServiceUnavailable, global.ErrorResponse("AI service is not enabled", nil))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	product, err := mongo.GetProductBySKU(ctx, sku)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch product", nil))
+		return
+	}
+
+	draft, err := ai.GenerateProductDescription(ctx, product)
+	if err != nil {
+		c.JSON(aiFailureStatus(err), global.ErrorResponse("Failed to generate description: "+err.Error(), nil))
+		return
+	}
+
+	if err := redis.SaveProductDescriptionDraft(ctx, draft); err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to save description draft: "+err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(draft))
+}
+
+// AcceptProductDescription applies the pending AI draft (with any admin edits) to the
+// product and refreshes its cache entry
+func AcceptProductDescription(c *gin.Context) {
+	sku := c.Param("sku")
+
+	var req models.AcceptProductDescriptionRequest
+	if c.Request.ContentLength > 0 {
+		if !bindJSON(c, &req) {
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+
+	draft, err := redis.GetProductDescriptionDraft(ctx, sku)
+	if err != nil {
+		if err.Error() == "draft not found" {
+			respondWithError(c, "No pending description draft", global.ValidationError{Field: "sku", Message: "Generate a description before accepting it", Code: errorcodes.NotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to load description draft: "+err.Error(), nil))
+		return
+	}
+
+	req.Apply(draft)
+
+	updatedProduct, err := mongo.UpdateProductBySKU(ctx, sku, map[string]interface{}{
+		"description": draft.Description,
+		"seo_title":   draft.SEOTitle,
+		"tags":        draft.Tags,
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to update product", nil))
+		return
+	}
+
+	if err := redis.DeleteProductDescriptionDraft(ctx, sku); err != nil {
+		slog.WarnContext(c.Request.Context(), "Failed to delete description draft", "sku", sku, "error", err)
+	}
+
+	c.Header("X-Cache", "REFRESHED")
+	c.JSON(http.StatusOK, global.SuccessResponse(updatedProduct))
+}
+
+// GetSimilarProducts returns the products whose embeddings are closest to the given product.
+// A product without an embedding yet is embedded on demand when the AI service is available.
+func GetSimilarProducts(c *gin.Context) {
+	sku := c.Param("sku")
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if err != nil || limit < 1 || limit > 20 {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid limit parameter", []global.ValidationError{
+			{Field: "limit", Message: "limit must be a number between 1 and 20"},
+		}))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	embedding, err := mongo.GetProductEmbedding(ctx, sku)
+	if err != nil && !errors.Is(err, mongo.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to load product embedding: "+err.Error(), nil))
+		return
+	}
+
+	if embedding == nil {
+		product, err := mongo.GetProductBySKU(ctx, sku)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNotFound) {
+				respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch product", nil))
+			return
+		}
+
+		if !ai.IsEnabled() {
+			c.JSON(http.StatusServiceUnavailable, global.ErrorResponse("Product has no embedding and the AI service is not enabled", nil))
+			return
+		}
+
+		embeddings, err := ai.EmbedProducts(ctx, []models.Product{*product})
+		if err != nil {
+			c.JSON(aiFailureStatus(err), global.ErrorResponse("Failed to embed product: "+err.Error(), nil))
+			return
+		}
+		embedding = &embeddings[0]
+
+		if err := mongo.UpsertProductEmbeddings(ctx, embeddings); err != nil {
+			slog.WarnContext(c.Request.Context(), "Failed to store embedding", "sku", sku, "error", err)
+		}
+	}
+
+	similar, err := mongo.FindSimilarProducts(ctx, embedding, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to find similar products: "+err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(similar, global.SinglePage(len(similar)), map[string]interface{}{
+		"sku": sku,
+	}))
+}
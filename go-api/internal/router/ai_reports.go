@@ -0,0 +1,146 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/workers"
+)
+
+// CreateReportSchedule schedules an AI report to be generated and stored on a cadence
+func CreateReportSchedule(c *gin.Context) {
+	var req models.CreateReportScheduleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := workers.ValidateReportScheduleParams(req.ReportType, req.Params); err != nil {
+		respondWithError(c, "Invalid report params", global.ValidationError{Field: "params", Message: err.Error(), Code: errorcodes.InvalidValue})
+		return
+	}
+
+	schedule, err := mongo.CreateReportSchedule(c.Request.Context(), req.ToReportSchedule())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error creating report schedule", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to create report schedule", nil))
+		return
+	}
+
+	c.JSON(http.StatusCreated, global.SuccessResponse(schedule))
+}
+
+// GetReportSchedules lists every AI report schedule
+func GetReportSchedules(c *gin.Context) {
+	schedules, err := mongo.GetReportSchedules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to get report schedules", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(schedules, global.SinglePage(len(schedules)), nil))
+}
+
+// DeleteReportSchedule stops a scheduled report; reports it already generated are kept
+func DeleteReportSchedule(c *gin.Context) {
+	scheduleID, err := bson.ObjectIDFromHex(c.Param("scheduleId"))
+	if err != nil {
+		respondWithError(c, "Invalid schedule ID format", global.ValidationError{Field: "scheduleId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	if err := mongo.DeleteReportSchedule(c.Request.Context(), scheduleID); err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			c.JSON(http.StatusNotFound, global.ErrorResponse("Report schedule not found", nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to delete report schedule", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
+		"schedule_id": scheduleID.Hex(),
+		"deleted":     true,
+	}))
+}
+
+// GetStoredAIReports lists stored AI reports, most recent first, for comparing trends over time
+func GetStoredAIReports(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter := mongo.StoredReportFilter{ReportType: c.Query("type")}
+
+	if scheduleIDValue := c.Query("schedule_id"); scheduleIDValue != "" {
+		scheduleID, err := bson.ObjectIDFromHex(scheduleIDValue)
+		if err != nil {
+			respondWithError(c, "Invalid schedule_id parameter", global.ValidationError{Field: "schedule_id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+			return
+		}
+		filter.ScheduleID = &scheduleID
+	}
+
+	if startDate := c.Query("startDate"); startDate != "" {
+		startTime, err := time.Parse("2006-01-02", startDate)
+		if err != nil {
+			respondWithError(c, "Invalid startDate parameter", global.ValidationError{Field: "startDate", Message: "startDate must be in YYYY-MM-DD format", Code: errorcodes.InvalidFormat})
+			return
+		}
+		filter.StartDate = &startTime
+	}
+
+	if endDate := c.Query("endDate"); endDate != "" {
+		endTime, err := time.Parse("2006-01-02", endDate)
+		if err != nil {
+			respondWithError(c, "Invalid endDate parameter", global.ValidationError{Field: "endDate", Message: "endDate must be in YYYY-MM-DD format", Code: errorcodes.InvalidFormat})
+			return
+		}
+		// Include the whole end day
+		endTime = endTime.Add(24 * time.Hour)
+		filter.EndDate = &endTime
+	}
+
+	result, err := mongo.GetStoredReports(c.Request.Context(), filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch reports", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(result.Reports, result.Pagination, nil))
+}
+
+// GetStoredAIReport returns a single stored AI report
+func GetStoredAIReport(c *gin.Context) {
+	reportID, err := bson.ObjectIDFromHex(c.Param("reportId"))
+	if err != nil {
+		respondWithError(c, "Invalid report ID format", global.ValidationError{Field: "reportId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	report, err := mongo.GetStoredReportByID(c.Request.Context(), reportID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			c.JSON(http.StatusNotFound, global.ErrorResponse("Report not found", nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch report", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(report))
+}
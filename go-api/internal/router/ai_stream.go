@@ -0,0 +1,90 @@
+package router
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/ai"
+)
+
+// aiStreamTimeout bounds how long a streamed AI report may run
+const aiStreamTimeout = 2 * time.Minute
+
+// streamAIReport sends a report as Server-Sent Events: a "token" event for each piece of
+// AI output as it arrives, then a "summary" event with the complete report, or an
+// "error" event if the report could not be generated
+func streamAIReport(c *gin.Context, generate func(ctx context.Context, onToken func(string)) (*ai.AIReportResponse, error)) {
+	// Stop generating when the client disconnects or the server shuts down
+	ctx, cancel := streamContext(c, aiStreamTimeout)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	onToken := func(token string) {
+		c.SSEvent("token", gin.H{"content": token})
+		c.Writer.Flush()
+	}
+
+	report, err := generate(ctx, onToken)
+	if err != nil {
+		c.SSEvent("error", gin.H{"message": "Failed to generate report: " + err.Error()})
+		c.Writer.Flush()
+		return
+	}
+
+	c.SSEvent("summary", report)
+	c.Writer.Flush()
+}
+
+// StreamAISalesReport streams the AI sales report over SSE
+func StreamAISalesReport(c *gin.Context) {
+	startDate := c.DefaultQuery("startDate", "")
+	endDate := c.DefaultQuery("endDate", "")
+	refresh := c.Query("refresh") == "true"
+
+	streamAIReport(c, func(ctx context.Context, onToken func(string)) (*ai.AIReportResponse, error) {
+		return ai.StreamSalesReport(ctx, startDate, endDate, refresh, onToken)
+	})
+}
+
+// StreamAICustomerInsights streams the AI customer insights over SSE
+func StreamAICustomerInsights(c *gin.Context) {
+	refresh := c.Query("refresh") == "true"
+
+	streamAIReport(c, func(ctx context.Context, onToken func(string)) (*ai.AIReportResponse, error) {
+		return ai.StreamCustomerInsights(ctx, refresh, onToken)
+	})
+}
+
+// StreamAIInventoryReport streams the AI inventory report over SSE
+func StreamAIInventoryReport(c *gin.Context) {
+	alertsOnlyStr := c.DefaultQuery("alertsOnly", "false")
+	alertsOnly := alertsOnlyStr == "true" || alertsOnlyStr == "1"
+	refresh := c.Query("refresh") == "true"
+
+	streamAIReport(c, func(ctx context.Context, onToken func(string)) (*ai.AIReportResponse, error) {
+		return ai.StreamInventoryReport(ctx, alertsOnly, refresh, onToken)
+	})
+}
+
+// StreamAIProductAnalysis streams the AI top products analysis over SSE
+func StreamAIProductAnalysis(c *gin.Context) {
+	sortBy := c.DefaultQuery("sortBy", "revenue")
+	startDate := c.DefaultQuery("startDate", "")
+	endDate := c.DefaultQuery("endDate", "")
+
+	limit := 10
+	if limitValue, err := strconv.Atoi(c.DefaultQuery("limit", "10")); err == nil && limitValue > 0 && limitValue <= 100 {
+		limit = limitValue
+	}
+	refresh := c.Query("refresh") == "true"
+
+	streamAIReport(c, func(ctx context.Context, onToken func(string)) (*ai.AIReportResponse, error) {
+		return ai.StreamTopProductsAnalysis(ctx, limit, sortBy, startDate, endDate, refresh, onToken)
+	})
+}
@@ -0,0 +1,324 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/logging"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+const (
+	// auditBodyLimit caps how much of a request body is copied into the audit log
+	auditBodyLimit = 64 << 10
+	// redactedValue replaces sensitive fields in audited request bodies
+	redactedValue = "[REDACTED]"
+)
+
+// auditRedactedFields are matched against lowercased JSON keys; any key containing one is redacted
+var auditRedactedFields = []string{"password", "payment", "card_number", "cvv", "cvc", "gift_card_code", "token", "secret"}
+
+// AuditMiddleware records every POST, PUT, PATCH and DELETE on the group in the audit_logs
+// collection with the route, actor, status and a redacted copy of the JSON body.
+// entityType names the audited resource, e.g. "customer" or "order".
+func AuditMiddleware(entityType string) gin.HandlerFunc {
+	return auditWrites(entityType, false)
+}
+
+// AdminAuditMiddleware records the writes on the group made with an admin key, like
+// AuditMiddleware. Use it on groups whose writes are not all audited.
+func AdminAuditMiddleware(entityType string) gin.HandlerFunc {
+	return auditWrites(entityType, true)
+}
+
+// auditWrites records the group's writes, or only admins' writes when adminOnly is set.
+// Writes to one product or order also record the fields they changed.
+func auditWrites(entityType string, adminOnly bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+		isAdmin := isAdminRequest(c)
+		if adminOnly && !isAdmin {
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(c.Request.Body, auditBodyLimit+1))
+			c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+		}
+		snapshot := auditSnapshot(c, entityType)
+		before := snapshot(c.Request.Context())
+
+		c.Next()
+
+		entry := &models.AuditLog{
+			RequestID:   logging.RequestID(c.Request.Context()),
+			EntityType:  entityType,
+			EntityID:    auditEntityID(c),
+			Method:      c.Request.Method,
+			Route:       c.FullPath(),
+			Path:        c.Request.URL.Path,
+			Actor:       "anonymous",
+			ClientIP:    c.ClientIP(),
+			RequestBody: redactAuditBody(body),
+			Status:      c.Writer.Status(),
+			CreatedAt:   time.Now(),
+		}
+		if isAdmin {
+			entry.Actor = "admin"
+			if admin := requestAdmin(c); admin != nil {
+				entry.AdminID = &admin.ID
+				entry.AdminEmail = admin.Email
+			}
+		}
+
+		// The response has been written, so store the entry without holding up the handler
+		ctx := context.WithoutCancel(c.Request.Context())
+		go func() {
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+
+			if before != nil && entry.Status < http.StatusBadRequest {
+				if after := snapshot(ctx); after != nil {
+					entry.Changes = auditChanges(before, after)
+				}
+			}
+			if err := mongo.InsertAuditLog(ctx, entry); err != nil {
+				slog.ErrorContext(ctx, "Error writing audit log", "route", entry.Route, "error", err)
+			}
+		}()
+	}
+}
+
+// auditSnapshot returns a loader for the product or order a request writes to, which
+// returns nil when there is none or it can't be read
+func auditSnapshot(c *gin.Context, entityType string) func(ctx context.Context) interface{} {
+	if sku := c.Param("sku"); sku != "" && (entityType == "product" || entityType == "inventory") {
+		return func(ctx context.Context) interface{} {
+			product, err := mongo.GetProductBySKU(ctx, sku)
+			if err != nil {
+				return nil
+			}
+			return product
+		}
+	}
+	if orderNumber := c.Param("orderNumber"); orderNumber != "" && entityType == "order" {
+		return func(ctx context.Context) interface{} {
+			order, err := mongo.GetOrderByNumber(ctx, orderNumber)
+			if err != nil {
+				return nil
+			}
+			return order
+		}
+	}
+	return func(ctx context.Context) interface{} {
+		return nil
+	}
+}
+
+// requestActor names who made a request: admin with a valid X-Admin-Key, otherwise
+// anonymous
+func requestActor(c *gin.Context) string {
+	if isAdminRequest(c) {
+		return "admin"
+	}
+	return "anonymous"
+}
+
+// auditEntityID returns the ID of the resource named in the route, if any
+func auditEntityID(c *gin.Context) string {
+	if len(c.Params) > 0 {
+		return c.Params[0].Value
+	}
+	return ""
+}
+
+// auditChanges lists the fields that differ between two snapshots of an entity, by
+// dotted JSON path. Arrays are compared whole, and sensitive fields are redacted.
+func auditChanges(before, after interface{}) []models.AuditChange {
+	beforeFields, afterFields := map[string]interface{}{}, map[string]interface{}{}
+	flattenAuditFields("", jsonValue(before), beforeFields)
+	flattenAuditFields("", jsonValue(after), afterFields)
+
+	paths := make([]string, 0, len(afterFields))
+	for path := range afterFields {
+		paths = append(paths, path)
+	}
+	for path := range beforeFields {
+		if _, ok := afterFields[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	changes := []models.AuditChange{}
+	for _, path := range paths {
+		if path == "updated_at" || reflect.DeepEqual(beforeFields[path], afterFields[path]) {
+			continue
+		}
+		change := models.AuditChange{Field: path, Before: beforeFields[path], After: afterFields[path]}
+		if isRedactedField(path) {
+			change.Before, change.After = redactedValue, redactedValue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// jsonValue converts a value to the maps, slices and scalars of its JSON form
+func jsonValue(value interface{}) interface{} {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return nil
+	}
+	return decoded
+}
+
+// flattenAuditFields adds each leaf of value to fields under its dotted path
+func flattenAuditFields(prefix string, value interface{}, fields map[string]interface{}) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		if prefix != "" {
+			fields[prefix] = value
+		}
+		return
+	}
+	for key, field := range object {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		flattenAuditFields(path, field, fields)
+	}
+}
+
+// redactAuditBody decodes a JSON body and replaces sensitive fields. Bodies that are
+// empty, too large or not JSON are summarized rather than stored.
+func redactAuditBody(body []byte) interface{} {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	if len(body) > auditBodyLimit {
+		return "[body exceeds " + strconv.Itoa(auditBodyLimit) + " bytes]"
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return "[non-JSON body]"
+	}
+	return redactValue(decoded)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, field := range typed {
+			if isRedactedField(key) {
+				typed[key] = redactedValue
+			} else {
+				typed[key] = redactValue(field)
+			}
+		}
+		return typed
+	case []interface{}:
+		for i := range typed {
+			typed[i] = redactValue(typed[i])
+		}
+		return typed
+	default:
+		return value
+	}
+}
+
+func isRedactedField(key string) bool {
+	key = strings.ToLower(key)
+	for _, field := range auditRedactedFields {
+		if strings.Contains(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAuditLogs lists audit log entries, most recent first. Filters: entity_type,
+// entity_id, actor, admin_id, method, route, field, startDate and endDate (YYYY-MM-DD).
+func GetAuditLogs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter := mongo.AuditLogFilter{
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+		Actor:      c.Query("actor"),
+		Method:     strings.ToUpper(c.Query("method")),
+		Route:      c.Query("route"),
+		Field:      c.Query("field"),
+	}
+
+	if adminID := c.Query("admin_id"); adminID != "" {
+		id, err := bson.ObjectIDFromHex(adminID)
+		if err != nil {
+			respondWithError(c, "Invalid admin_id parameter", global.ValidationError{Field: "admin_id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+			return
+		}
+		filter.AdminID = &id
+	}
+
+	if startDate := c.Query("startDate"); startDate != "" {
+		startTime, err := time.Parse("2006-01-02", startDate)
+		if err != nil {
+			respondWithError(c, "Invalid startDate parameter", global.ValidationError{Field: "startDate", Message: "startDate must be in YYYY-MM-DD format", Code: errorcodes.InvalidFormat})
+			return
+		}
+		filter.StartDate = &startTime
+	}
+
+	if endDate := c.Query("endDate"); endDate != "" {
+		endTime, err := time.Parse("2006-01-02", endDate)
+		if err != nil {
+			respondWithError(c, "Invalid endDate parameter", global.ValidationError{Field: "endDate", Message: "endDate must be in YYYY-MM-DD format", Code: errorcodes.InvalidFormat})
+			return
+		}
+		// Include the whole end day
+		endTime = endTime.Add(24 * time.Hour)
+		filter.EndDate = &endTime
+	}
+
+	result, err := mongo.GetAuditLogs(c.Request.Context(), filter, page, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching audit logs", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch audit logs", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(result.Logs, result.Pagination, nil))
+}
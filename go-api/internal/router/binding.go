@@ -0,0 +1,209 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+)
+
+// Request models carry binding tags (checked by Gin) and validate tags (which Gin never
+// read, so rules like email and oneof went unenforced). bindJSON runs both itself so
+// errors from either report the same JSON field paths, including slice indexes.
+var (
+	bindingValidator = newRequestValidator("binding")
+	requestValidator = newRequestValidator("validate")
+)
+
+func newRequestValidator(tagName string) *validator.Validate {
+	v := validator.New()
+	v.SetTagName(tagName)
+	v.RegisterTagNameFunc(jsonFieldName)
+	return v
+}
+
+// jsonFieldName reports fields by their JSON name so errors match the request body
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// bindJSON decodes the request body into req and enforces both its binding and validate
+// tags. On failure it writes a 400 listing every invalid field and returns false.
+func bindJSON(c *gin.Context, req interface{}) bool {
+	if c.Request.Body == nil {
+		respondWithError(c, "Invalid request data", global.ValidationError{Field: "body", Message: "request body is required", Code: errorcodes.JSONParseError})
+		return false
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", bindingErrors(err)))
+		return false
+	}
+
+	if errs := validateRequest(req); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", errs))
+		return false
+	}
+	return true
+}
+
+// validateRequest runs the binding and validate tags on a struct or on each element of a
+// slice of structs. Slice elements are reported as [index].field.
+func validateRequest(req interface{}) []global.ValidationError {
+	value := reflect.ValueOf(req)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		errs := bindingErrors(bindingValidator.Struct(value.Interface()))
+		for _, err := range bindingErrors(requestValidator.Struct(value.Interface())) {
+			if !hasFieldError(errs, err.Field) {
+				errs = append(errs, err)
+			}
+		}
+		return errs
+	case reflect.Slice, reflect.Array:
+		var errs []global.ValidationError
+		for i := 0; i < value.Len(); i++ {
+			for _, err := range validateRequest(value.Index(i).Interface()) {
+				err.Field = fmt.Sprintf("[%d].%s", i, err.Field)
+				errs = append(errs, err)
+			}
+		}
+		return errs
+	default:
+		return nil
+	}
+}
+
+func hasFieldError(errs []global.ValidationError, field string) bool {
+	for _, err := range errs {
+		if err.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// bindingErrors converts decode and validation errors into ValidationError entries
+func bindingErrors(err error) []global.ValidationError {
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrors validator.ValidationErrors
+	if errors.As(err, &fieldErrors) {
+		errs := make([]global.ValidationError, 0, len(fieldErrors))
+		for _, fieldError := range fieldErrors {
+			errs = append(errs, global.ValidationError{
+				Field:   fieldPath(fieldError.Namespace()),
+				Message: validationMessage(fieldError),
+				Code:    validationCode(fieldError.Tag()),
+			})
+		}
+		return errs
+	}
+
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		return []global.ValidationError{
+			{Field: typeError.Field, Message: "must be a " + typeError.Type.String(), Code: errorcodes.InvalidType},
+		}
+	}
+
+	return []global.ValidationError{
+		{Field: "body", Message: err.Error(), Code: errorcodes.JSONParseError},
+	}
+}
+
+// fieldPath drops the struct type name the validator puts at the start of a namespace,
+// e.g. "CreateOrderRequest.items[0].quantity" becomes "items[0].quantity"
+func fieldPath(namespace string) string {
+	if _, path, found := strings.Cut(namespace, "."); found {
+		return path
+	}
+	return namespace
+}
+
+// validationCode maps a validator tag to the catalogued code clients switch on
+func validationCode(tag string) errorcodes.Code {
+	switch tag {
+	case "required":
+		return errorcodes.Required
+	case "oneof":
+		return errorcodes.InvalidValue
+	case "min", "max", "gt", "gte", "lt", "lte":
+		return errorcodes.OutOfRange
+	default:
+		return errorcodes.InvalidFormat
+	}
+}
+
+func validationMessage(fieldError validator.FieldError) string {
+	param := fieldError.Param()
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(param, " ", ", ")
+	case "len":
+		return "must be exactly " + param + " " + lengthUnit(fieldError)
+	case "min":
+		if isLengthField(fieldError) {
+			return "must be at least " + param + " " + lengthUnit(fieldError)
+		}
+		return "must be at least " + param
+	case "max":
+		if isLengthField(fieldError) {
+			return "must be at most " + param + " " + lengthUnit(fieldError)
+		}
+		return "must be at most " + param
+	case "gte":
+		return "must be greater than or equal to " + param
+	case "gt":
+		return "must be greater than " + param
+	case "lte":
+		return "must be less than or equal to " + param
+	case "lt":
+		return "must be less than " + param
+	default:
+		return "failed the " + fieldError.Tag() + " check"
+	}
+}
+
+func isLengthField(fieldError validator.FieldError) bool {
+	switch fieldError.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
+
+func lengthUnit(fieldError validator.FieldError) string {
+	if fieldError.Kind() == reflect.String {
+		return "characters"
+	}
+	return "items"
+}
@@ -0,0 +1,78 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+	"julianmorley.ca/con-plar/prog2270/pkg/workers"
+)
+
+// maxCacheWarmLimit caps how many best-selling products one warm request caches
+const maxCacheWarmLimit = 1000
+
+// GetCacheStats reports how many Redis keys each prefix has and how much memory Redis uses
+func GetCacheStats(c *gin.Context) {
+	stats, err := redis.GetCacheStats(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error reading cache stats", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to read cache stats", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(stats))
+}
+
+// FlushCache deletes the cached keys under one prefix: product:, category: or analytics:
+func FlushCache(c *gin.Context) {
+	prefix := c.Query("prefix")
+	if !redis.FlushableCachePrefix(prefix) {
+		respondWithError(c, "Invalid prefix parameter", global.ValidationError{Field: "prefix", Message: "prefix must be one of: product:, category:, analytics:", Code: errorcodes.InvalidValue})
+		return
+	}
+
+	deleted, err := redis.FlushCachePrefix(c.Request.Context(), prefix)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error flushing cache", "prefix", prefix, "deleted", deleted, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to flush cache", nil))
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "Cache flushed", "prefix", prefix, "deleted", deleted)
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
+		"prefix":  prefix,
+		"deleted": deleted,
+	}))
+}
+
+// WarmProductCache loads products from MongoDB into Redis now instead of waiting for the
+// cache-warm task: the best sellers by default, or every active product with all=true
+func WarmProductCache(c *gin.Context) {
+	limit := cacheWarmLimit
+	if c.Query("limit") != "" {
+		parsed, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || parsed < 1 || parsed > maxCacheWarmLimit {
+			respondWithError(c, "Invalid limit parameter", global.ValidationError{Field: "limit", Message: "limit must be between 1 and 1000", Code: errorcodes.OutOfRange})
+			return
+		}
+		limit = parsed
+	}
+	if c.Query("all") == "true" {
+		limit = 0
+	}
+
+	warmed, err := workers.WarmProductCache(c.Request.Context(), limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error warming product cache", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to warm product cache", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
+		"warmed": warmed,
+	}))
+}
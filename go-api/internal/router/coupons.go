@@ -0,0 +1,229 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+)
+
+// couponRejectionReasons maps coupon validation failures to their error codes
+var couponRejectionReasons = map[string]errorcodes.Code{
+	"coupon is not active":              errorcodes.CouponInactive,
+	"coupon has expired":                errorcodes.CouponExpired,
+	"coupon usage limit reached":        errorcodes.CouponUsageLimitReached,
+	"coupon per-customer limit reached": errorcodes.CouponCustomerLimitReached,
+	"minimum subtotal not met":          errorcodes.CouponMinSubtotalNotMet,
+}
+
+// GetAllCoupons lists every coupon
+func GetAllCoupons(c *gin.Context) {
+	coupons, err := mongo.GetAllCoupons(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to get coupons", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(coupons, global.SinglePage(len(coupons)), nil))
+}
+
+// GetCouponByCode retrieves a single coupon by code
+func GetCouponByCode(c *gin.Context) {
+	code := c.Param("code")
+
+	coupon, err := mongo.GetCouponByCode(c.Request.Context(), code)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Coupon not found", global.ValidationError{Field: "code", Message: "No coupon exists with this code", Code: errorcodes.NotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch coupon", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(coupon))
+}
+
+// CreateCoupon creates a new coupon
+func CreateCoupon(c *gin.Context) {
+	var req models.CreateCouponRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if req.Type == "percentage" && req.Value > 100 {
+		respondWithError(c, "Invalid coupon value", global.ValidationError{Field: "value", Message: "Percentage coupons cannot exceed 100", Code: errorcodes.InvalidValue})
+		return
+	}
+
+	coupon, err := mongo.CreateCoupon(c.Request.Context(), req.ToCoupon())
+	if err != nil {
+		if errors.Is(err, mongo.ErrDuplicate) {
+			respondWithError(c, "Coupon code already exists", global.ValidationError{Field: "code", Message: "This coupon code is already in use", Code: errorcodes.DuplicateCode})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error creating coupon", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to create coupon", nil))
+		return
+	}
+
+	c.JSON(http.StatusCreated, global.SuccessResponse(coupon))
+}
+
+// UpdateCoupon applies partial updates to a coupon
+func UpdateCoupon(c *gin.Context) {
+	code := c.Param("code")
+	ctx := c.Request.Context()
+
+	var req models.UpdateCouponRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	existing, err := mongo.GetCouponByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Coupon not found", global.ValidationError{Field: "code", Message: "No coupon exists with this code", Code: errorcodes.NotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch coupon", nil))
+		return
+	}
+
+	if req.Value != nil && existing.Type == "percentage" && *req.Value > 100 {
+		respondWithError(c, "Invalid coupon value", global.ValidationError{Field: "value", Message: "Percentage coupons cannot exceed 100", Code: errorcodes.InvalidValue})
+		return
+	}
+
+	updatedCoupon, err := mongo.UpdateCoupon(ctx, code, &req)
+	if err != nil {
+		switch err.Error() {
+		case "coupon not found":
+			respondWithError(c, "Coupon not found", global.ValidationError{Field: "code", Message: "No coupon exists with this code", Code: errorcodes.NotFound})
+		case "no fields to update":
+			respondWithError(c, "No updates provided", global.ValidationError{Field: "body", Message: "Request body must contain at least one field to update", Code: errorcodes.EmptyUpdates})
+		default:
+			slog.ErrorContext(c.Request.Context(), "Error updating coupon", "code", code, "error", err)
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to update coupon", nil))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(updatedCoupon))
+}
+
+// DeleteCoupon removes a coupon by code
+func DeleteCoupon(c *gin.Context) {
+	code := c.Param("code")
+
+	if err := mongo.DeleteCoupon(c.Request.Context(), code); err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Coupon not found", global.ValidationError{Field: "code", Message: "No coupon exists with this code", Code: errorcodes.NotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to delete coupon", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]string{
+		"code": models.NormalizeCouponCode(code),
+	}))
+}
+
+// ApplyCartCoupon validates a coupon code and applies it to the cart
+func ApplyCartCoupon(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Session ID is required", []global.ValidationError{
+			{Field: "sessionId", Message: "sessionId URL parameter is required"},
+		}))
+		return
+	}
+
+	var request models.ApplyCouponRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	var customerID bson.ObjectID
+	if request.CustomerID != "" {
+		objectID, err := bson.ObjectIDFromHex(request.CustomerID)
+		if err != nil {
+			respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "customer_id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+			return
+		}
+		customerID = objectID
+	}
+
+	ctx, cancel := global.GetRequestTimer(c.Request.Context())
+	defer cancel()
+
+	restorePersistedCart(ctx, sessionID)
+
+	cart, err := redis.GetCart(ctx, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve cart: "+err.Error(), nil))
+		return
+	}
+
+	coupon, err := mongo.ValidateCoupon(ctx, request.Code, cart.Subtotal, customerID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Coupon not found", global.ValidationError{Field: "code", Message: "No coupon exists with this code", Code: errorcodes.NotFound})
+			return
+		}
+		if code, ok := couponRejectionReasons[err.Error()]; ok {
+			c.JSON(http.StatusBadRequest, global.ErrorResponse("Coupon cannot be applied", []global.ValidationError{
+				{Field: "code", Message: err.Error(), Code: code},
+			}))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to validate coupon: "+err.Error(), nil))
+		return
+	}
+
+	cart, err = redis.ApplyCouponToCart(ctx, sessionID, coupon)
+	if err != nil {
+		if err.Error() == "cart is empty" {
+			respondWithError(c, "Cart is empty", global.ValidationError{Field: "sessionId", Message: "Add items to the cart before applying a coupon", Code: errorcodes.EmptyCart})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to apply coupon: "+err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(cart))
+}
+
+// RemoveCartCoupon removes the applied coupon from the cart
+func RemoveCartCoupon(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Session ID is required", []global.ValidationError{
+			{Field: "sessionId", Message: "sessionId URL parameter is required"},
+		}))
+		return
+	}
+
+	ctx, cancel := global.GetRequestTimer(c.Request.Context())
+	defer cancel()
+
+	cart, err := redis.RemoveCouponFromCart(ctx, sessionID)
+	if err != nil {
+		if err.Error() == "no coupon applied to cart" {
+			respondWithError(c, "No coupon applied", global.ValidationError{Field: "sessionId", Message: "This cart has no coupon applied", Code: errorcodes.NotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to remove coupon: "+err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(cart))
+}
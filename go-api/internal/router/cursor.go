@@ -0,0 +1,44 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// cursorPageRequest reports whether the client asked for cursor pagination by sending a
+// cursor parameter (empty for the first page), and returns the cursor and page size
+func cursorPageRequest(c *gin.Context) (string, int, bool) {
+	cursor, ok := c.GetQuery("cursor")
+	if !ok {
+		return "", 0, false
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	return cursor, limit, true
+}
+
+// respondWithCursorPage writes a keyset page in the negotiated list format, or the error
+// that fetching it returned. name is the collection, used in messages and filenames.
+func respondWithCursorPage(c *gin.Context, name string, limit int, page *mongo.CursorPage, err error) {
+	if err != nil {
+		if errors.Is(err, mongo.ErrInvalidCursor) {
+			respondWithError(c, "Invalid cursor", global.ValidationError{Field: "cursor", Message: "cursor must be a next_cursor value returned by this endpoint", Code: errorcodes.InvalidFormat})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error fetching cursor page", "collection", name, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to get "+name, nil))
+		return
+	}
+
+	respondWithList(c, name, page.Items, global.NewCursorPagination(limit, page.NextCursor, page.HasMore), nil)
+}
@@ -0,0 +1,140 @@
+package router
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// exportContentTypes maps each customer export format to the type it downloads as
+var exportContentTypes = map[string]string{
+	"json": "application/json",
+	"zip":  "application/zip",
+}
+
+// RequestCustomerExport queues an export of everything stored about a customer, or
+// returns the export already queued or ready in the same format
+func RequestCustomerExport(c *gin.Context) {
+	customer, ok := loadCustomer(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if _, ok := exportContentTypes[format]; !ok {
+		respondWithError(c, "Invalid export format", global.ValidationError{Field: "format", Message: "must be one of: json, zip", Code: errorcodes.InvalidValue})
+		return
+	}
+
+	export, created, err := mongo.RequestCustomerExport(c.Request.Context(), customer.ID, format)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error requesting customer export", "customer_id", customer.ID.Hex(), "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to request customer export", nil))
+		return
+	}
+	if created {
+		slog.InfoContext(c.Request.Context(), "Queued customer export", "customer_id", customer.ID.Hex(), "export_id", export.ID.Hex(), "format", format)
+	}
+
+	respondWithCustomerExport(c, export)
+}
+
+// GetCustomerExport reports an export's progress, with a signed download link once it
+// is ready
+func GetCustomerExport(c *gin.Context) {
+	customer, ok := loadCustomer(c)
+	if !ok {
+		return
+	}
+
+	exportID, err := bson.ObjectIDFromHex(c.Param("exportId"))
+	if err != nil {
+		respondWithError(c, "Invalid export ID format", global.ValidationError{Field: "exportId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	export, err := mongo.GetCustomerExport(c.Request.Context(), customer.ID, exportID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Export not found", global.ValidationError{Field: "exportId", Message: "The customer has no export with this ID", Code: errorcodes.NotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch customer export", nil))
+		return
+	}
+
+	respondWithCustomerExport(c, export)
+}
+
+// respondWithCustomerExport sends an export's status: 200 once it is ready (with a
+// download link) or failed, otherwise 202 with a Location to poll
+func respondWithCustomerExport(c *gin.Context, export *models.CustomerExport) {
+	switch export.Status {
+	case models.ExportReady:
+		export.DownloadURL = signedExportURL(export.ID, time.Now().Add(exportLinkTTL))
+		c.JSON(http.StatusOK, global.SuccessResponse(export))
+	case models.ExportFailed:
+		c.JSON(http.StatusOK, global.SuccessResponse(export))
+	default:
+		c.Header("Location", fmt.Sprintf("/api/customers/%s/export/%s", export.CustomerID.Hex(), export.ID.Hex()))
+		c.JSON(http.StatusAccepted, global.SuccessResponse(export))
+	}
+}
+
+// DownloadCustomerExport serves a ready export's file to a holder of its signed link
+func DownloadCustomerExport(c *gin.Context) {
+	exportID, err := bson.ObjectIDFromHex(c.Param("exportId"))
+	if err != nil {
+		respondWithError(c, "Invalid export ID format", global.ValidationError{Field: "exportId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !hmac.Equal([]byte(c.Query("signature")), []byte(exportSignature(exportID, expires))) {
+		respondWithError(c, "Invalid download link", global.ValidationError{Field: "signature", Message: "The link is not signed for this export", Code: errorcodes.InvalidSignature})
+		return
+	}
+	if time.Now().Unix() > expires {
+		respondWithError(c, "Download link expired", global.ValidationError{Field: "expires", Message: "Request the export again for a new link", Code: errorcodes.LinkExpired})
+		return
+	}
+
+	export, err := mongo.GetCustomerExportFile(c.Request.Context(), exportID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Export not found", global.ValidationError{Field: "exportId", Message: "The export has expired or is not ready", Code: errorcodes.NotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch customer export", nil))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.FileName()))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, exportContentTypes[export.Format], export.Data)
+}
+
+// signedExportURL is the download path for an export, valid until expires
+func signedExportURL(exportID bson.ObjectID, expires time.Time) string {
+	return fmt.Sprintf("/api/exports/%s/download?expires=%d&signature=%s", exportID.Hex(), expires.Unix(), exportSignature(exportID, expires.Unix()))
+}
+
+// exportSignature signs an export ID and link expiry with the export signing key
+func exportSignature(exportID bson.ObjectID, expires int64) string {
+	mac := hmac.New(sha256.New, exportSigningKey)
+	fmt.Fprintf(mac, "%s:%d", exportID.Hex(), expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
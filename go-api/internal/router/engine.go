@@ -1,28 +1,94 @@
 package router
 
 import (
-	"os"
+	"context"
+	"crypto/rand"
+	"log/slog"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
 )
 
 var Router *gin.Engine
 
-func InitEngine() {
-	Router = gin.Default()
-	if os.Getenv("ENV") == "production" {
+// adminAPIKey is the shared key AdminMiddleware accepts in the X-Admin-Key header, along
+// with each active admin's personal key
+var adminAPIKey string
+
+// searchFuzziness is the typos per term /api/search tolerates when fuzzy isn't set
+var searchFuzziness int
+
+// adminInviteURL and adminInviteTTL set the accept link of admin invitations
+var (
+	adminInviteURL string
+	adminInviteTTL time.Duration
+)
+
+// cacheWarmLimit is how many best sellers POST /api/admin/cache/warm caches by default
+var cacheWarmLimit int
+
+// seedEnabled allows POST /api/admin/seed, only in development and staging
+var seedEnabled bool
+
+// exportSigningKey signs customer export and report archive download links, which work
+// for exportLinkTTL
+var (
+	exportSigningKey []byte
+	exportLinkTTL    time.Duration
+)
+
+// shuttingDown is cancelled by CloseStreams once the server starts shutting down
+var shuttingDown, closeStreams = context.WithCancel(context.Background())
+
+// CloseStreams ends every open Server-Sent Events stream so shutdown doesn't wait on
+// them; clients reconnect to another instance
+func CloseStreams() {
+	closeStreams()
+}
+
+// streamContext bounds a streaming response by timeout, the client disconnecting and
+// the server shutting down
+func streamContext(c *gin.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	stop := context.AfterFunc(shuttingDown, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+func InitEngine(cfg *config.Config) {
+	adminAPIKey = cfg.Server.AdminAPIKey
+	adminInviteURL = cfg.Server.AdminInviteURL
+	adminInviteTTL = cfg.Server.AdminInviteTTL
+	searchFuzziness = cfg.Mongo.SearchFuzziness
+	cacheWarmLimit = cfg.Scheduler.CacheWarmLimit
+	seedEnabled = cfg.Env == "development" || cfg.Env == "staging"
+
+	exportSigningKey = []byte(cfg.Server.ExportSigningKey)
+	exportLinkTTL = cfg.Server.ExportLinkTTL
+	if len(exportSigningKey) == 0 {
+		exportSigningKey = make([]byte, 32)
+		_, _ = rand.Read(exportSigningKey)
+		slog.Warn("EXPORT_SIGNING_KEY is not set; export and report archive links only work on this instance until it restarts")
+	}
+
+	if cfg.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	} else {
 		gin.SetMode(gin.DebugMode)
 	}
+	Router = gin.New()
+	Router.Use(otelgin.Middleware(cfg.Tracing.ServiceName), RequestLoggerMiddleware(), RecoveryMiddleware(), LanguageMiddleware())
 
 	Router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:5173", "https://plar-conestoga-prog2270.julianmorley.ca"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length", "X-Total-Count"},
+		AllowOrigins:     cfg.Server.CORSOrigins,
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "X-Admin-Key", "X-Request-ID", "If-Match", "If-None-Match"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", "Location", "X-Total-Count", "X-Next-Cursor", "X-Request-ID", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -32,9 +98,24 @@ func InitializeRoutes() {
 	api := Router.Group("/api")
 	{
 		api.GET("/health", HealthCheck)
+		api.GET("/health/live", LivenessCheck)
+		api.GET("/health/ready", ReadinessCheck)
 		api.GET("/search", SearchDatabase)
+		api.GET("/search/suggest", SuggestSearch)
+		api.GET("/errors", GetErrorCodes)
+		api.GET("/graphql", ServeGraphQL)
+		api.POST("/graphql", ServeGraphQL)
+		api.GET("/docs", ServeSwaggerUI)
+		api.GET("/docs/openapi.json", ServeOpenAPISpec)
+
+		api.POST("/webhooks/payments/:provider", HandlePaymentWebhook)
+		api.GET("/unsubscribe", Unsubscribe)
+		api.GET("/exports/:exportId/download", DownloadCustomerExport)
+		api.GET("/report-archives/:archiveId/download", DownloadReportArchive)
+		api.POST("/unsubscribe", Unsubscribe)
 
 		products := api.Group("/products")
+		products.Use(AuditMiddleware("product"))
 		{
 			products.GET("/", GetAllProducts)
 			products.POST("/", CreateNewProducts)
@@ -42,7 +123,21 @@ func InitializeRoutes() {
 			products.DELETE("/", BulkDeleteProducts)
 			products.GET("/:sku", GetProductBySKU)
 			products.PUT("/:sku", EditProductBySKU)
+			products.PATCH("/:sku", EditProductBySKU)
 			products.DELETE("/:sku", DeleteProductBySKU)
+			products.GET("/:sku/similar", GetSimilarProducts)
+			products.POST("/:sku/notify-me", NotifyMeWhenInStock)
+			products.POST("/:sku/ai/describe", AdminMiddleware(), GenerateProductDescription)
+			products.POST("/:sku/ai/describe/accept", AdminMiddleware(), AcceptProductDescription)
+
+			productReviews := products.Group("/:sku/reviews")
+			productReviews.Use(ProductReviewsMiddleware())
+			{
+				productReviews.GET("/", GetReviewsForItem)
+				productReviews.POST("/", CreateReviewForItem)
+				productReviews.PUT("/:reviewId", UpdateReviewForItem)
+				productReviews.DELETE("/:reviewId", DeleteReviewForItem)
+			}
 		}
 
 		categories := api.Group("/categories")
@@ -51,38 +146,69 @@ func InitializeRoutes() {
 		}
 
 		orders := api.Group("/orders")
+		orders.Use(AuditMiddleware("order"))
 		{
 			orders.GET("/", GetAllOrders)
 			orders.POST("/", CreateNewOrders)
 			orders.PUT("/", BulkEditOrders)
 			orders.DELETE("/", BulkDeleteOrders)
 			orders.GET("/:orderNumber", GetOrderByNumber)
+			orders.GET("/:orderNumber/events", StreamOrderEvents)
+			orders.POST("/:orderNumber/payment-intent", CreateOrderPaymentIntent)
+			orders.POST("/:orderNumber/payment-capture", CaptureOrderPayment)
+			orders.POST("/:orderNumber/refunds", AdminMiddleware(), RefundOrder)
 			orders.PUT("/:orderNumber", EditOrderByNumber)
+			orders.PATCH("/:orderNumber", EditOrderByNumber)
 			orders.DELETE("/:orderNumber", DeleteOrderByNumber)
 		}
 
 		customers := api.Group("/customers")
+		customers.Use(AuditMiddleware("customer"))
 		{
 			customers.GET("/", GetAllCustomers)
 			customers.POST("/", CreateCustomer)
 			customers.GET("/:id", GetCustomerByID)
 			customers.PUT("/:id", UpdateCustomer)
+			customers.PATCH("/:id/preferences", UpdateCustomerPreferences)
 			customers.DELETE("/:id", DeleteCustomer)
 			customers.GET("/:id/orders", GetCustomerOrders)
 			customers.POST("/:id/addresses", AddCustomerAddress)
 			customers.PUT("/:id/addresses/:addressId", UpdateCustomerAddress)
 			customers.DELETE("/:id/addresses/:addressId", DeleteCustomerAddress)
+			customers.GET("/:id/saved-searches", ListSavedSearches)
+			customers.POST("/:id/saved-searches", CreateSavedSearch)
+			customers.GET("/:id/saved-searches/:searchId", GetSavedSearch)
+			customers.PUT("/:id/saved-searches/:searchId", UpdateSavedSearch)
+			customers.DELETE("/:id/saved-searches/:searchId", DeleteSavedSearch)
+			customers.GET("/:id/price-alerts", ListPriceAlerts)
+			customers.POST("/:id/price-alerts", CreatePriceAlert)
+			customers.DELETE("/:id/price-alerts/:alertId", DeletePriceAlert)
+			customers.POST("/:id/loyalty/redeem", RedeemLoyaltyPoints)
+			customers.GET("/:id/loyalty/transactions", GetLoyaltyTransactions)
+			customers.GET("/:id/notifications", GetCustomerNotifications)
+			customers.POST("/:id/notifications/read", MarkAllCustomerNotificationsRead)
+			customers.POST("/:id/notifications/:notificationId/read", MarkCustomerNotificationRead)
+			customers.GET("/:id/export", RequestCustomerExport)
+			customers.GET("/:id/export/:exportId", GetCustomerExport)
 		}
 
 		reviews := api.Group("/reviews")
-		reviews.Use(ReviewsMiddleware())
+		reviews.Use(ReviewsMiddleware(), AdminAuditMiddleware("review"))
 		{
-			reviews.GET("/", GetReviewsForItem)
+			reviews.GET("/", GetAllReviews)
 			reviews.POST("/", CreateReviewForItem)
 			reviews.PUT("/", UpdateReviewForItem)
 			reviews.DELETE("/", DeleteReviewForItem)
 		}
 
+		review := api.Group("/reviews/:reviewId")
+		review.Use(AdminAuditMiddleware("review"))
+		{
+			review.POST("/helpful", MarkReviewHelpful)
+			review.DELETE("/helpful", UnmarkReviewHelpful)
+			review.POST("/reply", AdminMiddleware(), ReplyToReview)
+		}
+
 		cart := api.Group("/cart")
 		{
 			cart.GET("/:sessionId", GetCart)
@@ -90,22 +216,55 @@ func InitializeRoutes() {
 			cart.PUT("/:sessionId/items/:sku", UpdateCartItem)
 			cart.DELETE("/:sessionId/items/:sku", RemoveFromCart)
 			cart.DELETE("/:sessionId/clear", ClearCart)
+			cart.POST("/:sessionId/checkout", StartCheckout)
+			cart.POST("/:sessionId/coupon", ApplyCartCoupon)
+			cart.DELETE("/:sessionId/coupon", RemoveCartCoupon)
+			cart.PUT("/:sessionId/shipping", SetCartShipping)
+		}
+
+		coupons := api.Group("/coupons")
+		coupons.Use(AdminMiddleware(), AdminAuditMiddleware("coupon"))
+		{
+			coupons.GET("/", GetAllCoupons)
+			coupons.POST("/", CreateCoupon)
+			coupons.GET("/:code", GetCouponByCode)
+			coupons.PUT("/:code", UpdateCoupon)
+			coupons.DELETE("/:code", DeleteCoupon)
+		}
+
+		giftCards := api.Group("/gift-cards")
+		giftCards.Use(AdminAuditMiddleware("gift_card"))
+		{
+			giftCards.GET("/", AdminMiddleware(), GetAllGiftCards)
+			giftCards.POST("/", AdminMiddleware(), IssueGiftCard)
+			giftCards.GET("/:code/balance", GetGiftCardBalance)
 		}
 
 		inventory := api.Group("/inventory")
+		inventory.Use(AuditMiddleware("inventory"))
 		{
-			inventory.GET("/", nil)
-			inventory.POST("/", nil)
-			inventory.GET("/:id", nil)
-			inventory.PUT("/:id", nil)
+			inventory.GET("/", GetInventory)
+			inventory.POST("/", AdjustInventory)
+			inventory.GET("/:sku", GetInventoryBySKU)
+			inventory.PUT("/:sku", SetInventoryLevels)
+			inventory.GET("/:sku/history", GetInventoryHistory)
+			inventory.POST("/:sku/recounts", CreateInventoryRecount)
+			inventory.GET("/recounts", GetInventoryRecounts)
+			inventory.POST("/recounts/:recountId/approve", AdminMiddleware(), ApproveInventoryRecount)
+			inventory.POST("/recounts/:recountId/reject", AdminMiddleware(), RejectInventoryRecount)
 		}
 
 		analytics := api.Group("/analytics")
 		{
 			analytics.GET("/sales", GetSalesAnalytics)
+			analytics.GET("/sales-by-region", GetSalesByRegion)
+			analytics.GET("/funnel", GetSalesFunnel)
+			analytics.GET("/retention", GetRetentionAnalytics)
 			analytics.GET("/customers/segments", GetCustomerSegments)
 			analytics.GET("/top-products", GetTopProducts)
 			analytics.GET("/inventory", GetInventoryAnalytics)
+			analytics.GET("/reviews/sentiment", GetReviewSentimentAnalytics)
+			analytics.GET("/search", GetSearchAnalytics)
 
 			// AI-powered analytics endpoints
 			aiAnalytics := analytics.Group("/ai")
@@ -114,12 +273,70 @@ func InitializeRoutes() {
 				aiAnalytics.GET("/customer-insights", GenerateAICustomerInsights)
 				aiAnalytics.GET("/inventory-report", GenerateAIInventoryReport)
 				aiAnalytics.GET("/product-analysis", GenerateAIProductAnalysis)
+				aiAnalytics.GET("/forecast", GenerateAIDemandForecast)
+				aiAnalytics.GET("/sales-report/stream", StreamAISalesReport)
+				aiAnalytics.GET("/customer-insights/stream", StreamAICustomerInsights)
+				aiAnalytics.GET("/inventory-report/stream", StreamAIInventoryReport)
+				aiAnalytics.GET("/product-analysis/stream", StreamAIProductAnalysis)
+				aiAnalytics.GET("/reports", GetStoredAIReports)
+				aiAnalytics.GET("/reports/:reportId", GetStoredAIReport)
 			}
 		}
 
+		aiGroup := api.Group("/ai")
+		aiGroup.Use(AdminMiddleware())
+		{
+			aiGroup.POST("/query", RunNaturalLanguageQuery)
+			aiGroup.POST("/chat", ChatWithAssistant)
+			aiGroup.GET("/chat/:sessionId", GetChatHistory)
+			aiGroup.DELETE("/chat/:sessionId", ClearChatHistory)
+		}
+
 		admin := api.Group("/admin")
+		admin.Use(AdminAuditMiddleware("admin"))
 		{
 			admin.GET("/", nil)
+			admin.GET("/abandoned-carts", AdminMiddleware(), GetAbandonedCarts)
+			admin.POST("/reviews/sentiment", AdminMiddleware(), StartReviewSentimentJob)
+			admin.GET("/reviews/sentiment", AdminMiddleware(), GetReviewSentimentJobStatus)
+			admin.DELETE("/analytics/cache", AdminMiddleware(), ClearAnalyticsCache)
+			admin.GET("/cache", AdminMiddleware(), GetCacheStats)
+			admin.DELETE("/cache", AdminMiddleware(), FlushCache)
+			admin.POST("/cache/warm", AdminMiddleware(), WarmProductCache)
+			admin.GET("/indexes", AdminMiddleware(), GetIndexes)
+			admin.POST("/indexes/ensure", AdminMiddleware(), EnsureIndexes)
+			admin.POST("/products/embeddings", AdminMiddleware(), StartProductEmbeddingJob)
+			admin.GET("/products/embeddings", AdminMiddleware(), GetProductEmbeddingJobStatus)
+			admin.GET("/reports/schedules", AdminMiddleware(), GetReportSchedules)
+			admin.POST("/reports/schedules", AdminMiddleware(), CreateReportSchedule)
+			admin.DELETE("/reports/schedules/:scheduleId", AdminMiddleware(), DeleteReportSchedule)
+			admin.POST("/reports/archives", AdminMiddleware(), RequestReportArchive)
+			admin.GET("/reports/archives/:archiveId", AdminMiddleware(), GetReportArchive)
+			admin.GET("/audit-logs", AdminMiddleware(), GetAuditLogs)
+			admin.GET("/scheduler/tasks", AdminMiddleware(), GetScheduledTasks)
+			admin.GET("/loyalty-tiers", AdminMiddleware(), GetLoyaltyTierRules)
+			admin.PUT("/loyalty-tiers", AdminMiddleware(), UpdateLoyaltyTierRules)
+			admin.GET("/tax-rules", AdminMiddleware(), GetTaxRules)
+			admin.PUT("/tax-rules", AdminMiddleware(), UpdateTaxRules)
+			admin.GET("/templates", AdminMiddleware(), ListTemplates)
+			admin.POST("/templates", AdminMiddleware(), CreateTemplate)
+			admin.GET("/templates/:key", AdminMiddleware(), GetTemplate)
+			admin.PUT("/templates/:key", AdminMiddleware(), UpdateTemplate)
+			admin.DELETE("/templates/:key", AdminMiddleware(), DeleteTemplate)
+			admin.GET("/templates/:key/versions", AdminMiddleware(), ListTemplateVersions)
+			admin.POST("/templates/:key/versions/:version/restore", AdminMiddleware(), RestoreTemplateVersion)
+			admin.POST("/templates/:key/preview", AdminMiddleware(), PreviewTemplate)
+			admin.GET("/notifications", AdminMiddleware(), GetNotifications)
+			admin.POST("/notifications/:notificationId/retry", AdminMiddleware(), RetryNotification)
+			admin.GET("/admins", AdminMiddleware(), GetAdmins)
+			admin.POST("/admins", AdminMiddleware(), InviteAdmin)
+			admin.PUT("/admins/:adminId/roles", AdminMiddleware(), UpdateAdminRoles)
+			admin.POST("/admins/:adminId/deactivate", AdminMiddleware(), DeactivateAdmin)
+			admin.POST("/invitations/accept", AcceptAdminInvitation)
+			admin.POST("/seed", AdminMiddleware(), SeedDatabase)
+			admin.GET("/jobs/:jobId", AdminMiddleware(), GetImportJob)
 		}
 	}
+
+	warnUndocumentedRoutes()
 }
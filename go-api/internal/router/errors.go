@@ -0,0 +1,21 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+)
+
+// respondWithError writes a single-field error using the HTTP status catalogued for its
+// code, so the same code always comes back with the same status
+func respondWithError(c *gin.Context, message string, err global.ValidationError) {
+	c.JSON(errorcodes.Status(err.Code), global.ErrorResponse(message, []global.ValidationError{err}))
+}
+
+// GetErrorCodes lists every error code the API returns with its HTTP status
+func GetErrorCodes(c *gin.Context) {
+	codes := errorcodes.Catalog()
+	c.JSON(http.StatusOK, global.ListResponse(codes, global.SinglePage(len(codes)), nil))
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// productETag is a strong ETag over the product's JSON representation, so it changes
+// whenever any returned field does, even on writes that don't bump updated_at
+func productETag(product *models.Product) string {
+	body, err := json.Marshal(product)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header value lists etag.
+// "*" matches any current representation. If-None-Match uses weak comparison, so
+// pass weak=true to ignore a W/ prefix.
+func etagMatches(header string, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// respondWithProduct writes the product with its ETag, or 304 Not Modified when the
+// client's If-None-Match already has this version
+func respondWithProduct(c *gin.Context, product *models.Product) {
+	etag := productETag(product)
+	if etag != "" {
+		c.Header("ETag", etag)
+		// Let clients cache the body but revalidate with If-None-Match every time
+		c.Header("Cache-Control", "no-cache")
+	}
+
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag, true) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(product))
+}
+
+// preconditionFailed rejects a write whose If-Match no longer matches the stored product.
+// current, when known, is sent back as the ETag to retry against.
+func preconditionFailed(c *gin.Context, current *models.Product) {
+	if current != nil {
+		c.Header("ETag", productETag(current))
+	}
+	respondWithError(c, "Product has been modified", global.ValidationError{Field: "If-Match", Message: "The product changed since it was fetched; reload it and retry", Code: errorcodes.PreconditionFailed})
+}
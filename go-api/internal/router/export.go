@@ -0,0 +1,204 @@
+package router
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+)
+
+// List endpoints negotiate their output format from ?format= or the Accept header.
+// JSON keeps the usual envelope; CSV and NDJSON stream the items alone and carry the
+// pagination in headers.
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	mimeCSV    = "text/csv"
+	mimeNDJSON = "application/x-ndjson"
+)
+
+// exportFlushEvery controls how many rows are buffered before being flushed to the client
+const exportFlushEvery = 100
+
+// responseFormat picks json, csv or ndjson. ?format= wins over Accept; anything
+// unrecognised falls back to JSON.
+func responseFormat(c *gin.Context) string {
+	if format := strings.ToLower(c.Query("format")); format != "" {
+		switch format {
+		case formatCSV, formatNDJSON:
+			return format
+		case "jsonl":
+			return formatNDJSON
+		}
+		return formatJSON
+	}
+
+	switch c.NegotiateFormat(gin.MIMEJSON, mimeCSV, mimeNDJSON, "application/ndjson", "application/jsonl") {
+	case mimeCSV:
+		return formatCSV
+	case mimeNDJSON, "application/ndjson", "application/jsonl":
+		return formatNDJSON
+	default:
+		return formatJSON
+	}
+}
+
+// respondWithList writes a list in the format the client negotiated. name is used as
+// the CSV download's filename.
+func respondWithList(c *gin.Context, name string, items interface{}, pagination global.PaginationInfo, meta map[string]interface{}) {
+	switch responseFormat(c) {
+	case formatCSV:
+		setPaginationHeaders(c, pagination)
+		writeCSV(c, name, items)
+	case formatNDJSON:
+		setPaginationHeaders(c, pagination)
+		writeNDJSON(c, items)
+	default:
+		c.JSON(http.StatusOK, global.ListResponse(items, pagination, meta))
+	}
+}
+
+// setPaginationHeaders carries the pagination block for formats without an envelope
+func setPaginationHeaders(c *gin.Context, pagination global.PaginationInfo) {
+	if pagination.NextCursor != "" {
+		c.Header("X-Next-Cursor", pagination.NextCursor)
+	}
+	if pagination.TotalItems > 0 || pagination.TotalPages > 0 {
+		c.Header("X-Total-Count", strconv.Itoa(pagination.TotalItems))
+	}
+}
+
+// writeNDJSON streams one JSON document per line
+func writeNDJSON(c *gin.Context, items interface{}) {
+	c.Header("Content-Type", mimeNDJSON+"; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	rows := listValue(items)
+	for i := 0; i < rows.Len(); i++ {
+		_ = encoder.Encode(rows.Index(i).Interface())
+		if (i+1)%exportFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+	}
+	c.Writer.Flush()
+}
+
+// writeCSV streams a CSV attachment to the client, flushing periodically so large
+// exports start downloading before every row is written. Columns are the items' JSON
+// fields with nested objects flattened to dotted names (stock.total); arrays are
+// written as JSON.
+func writeCSV(c *gin.Context, filename string, items interface{}) {
+	rows := listValue(items)
+	flattened := make([]map[string]string, rows.Len())
+	var header []string
+	seen := map[string]bool{}
+	for i := range flattened {
+		flattened[i] = map[string]string{}
+		body, err := json.Marshal(rows.Index(i).Interface())
+		if err != nil {
+			continue
+		}
+		for _, column := range flattenJSONObject("", body, flattened[i]) {
+			if !seen[column] {
+				seen[column] = true
+				header = append(header, column)
+			}
+		}
+	}
+
+	c.Header("Content-Type", mimeCSV+"; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.csv"`, filename, time.Now().Format("20060102")))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	_ = writer.Write(header)
+	record := make([]string, len(header))
+	for i, row := range flattened {
+		for j, column := range header {
+			record[j] = row[column]
+		}
+		_ = writer.Write(record)
+		if (i+1)%exportFlushEvery == 0 {
+			writer.Flush()
+			c.Writer.Flush()
+		}
+	}
+	writer.Flush()
+	c.Writer.Flush()
+}
+
+// listValue returns items as a slice value; a single non-slice item becomes one row
+func listValue(items interface{}) reflect.Value {
+	value := reflect.ValueOf(items)
+	if !value.IsValid() {
+		return reflect.ValueOf([]interface{}{})
+	}
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return reflect.ValueOf([]interface{}{items})
+	}
+	return value
+}
+
+// flattenJSONObject writes each leaf of a JSON object into row under its dotted path and
+// returns the paths in document order, so columns follow the struct's field order.
+// A value that is not an object is stored under prefix itself.
+func flattenJSONObject(prefix string, body []byte, row map[string]string) []string {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		row[prefix] = csvCell(body)
+		return []string{prefix}
+	}
+
+	var columns []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			break
+		}
+
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if trimmed := bytes.TrimSpace(value); len(trimmed) > 0 && trimmed[0] == '{' {
+			columns = append(columns, flattenJSONObject(path, trimmed, row)...)
+			continue
+		}
+		row[path] = csvCell(value)
+		columns = append(columns, path)
+	}
+	return columns
+}
+
+// csvCell renders a JSON leaf: strings unquoted, null empty, numbers, booleans and
+// arrays as their JSON text
+func csvCell(value json.RawMessage) string {
+	trimmed := bytes.TrimSpace(value)
+	if bytes.Equal(trimmed, []byte("null")) {
+		return ""
+	}
+	if len(trimmed) > 0 && trimmed[0] == '"' {
+		var text string
+		if err := json.Unmarshal(trimmed, &text); err == nil {
+			return text
+		}
+	}
+	return string(trimmed)
+}
@@ -0,0 +1,58 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// IssueGiftCard issues a new gift card with a generated code
+func IssueGiftCard(c *gin.Context) {
+	var req models.IssueGiftCardRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	giftCard, err := mongo.IssueGiftCard(c.Request.Context(), req.ToGiftCard())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error issuing gift card", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to issue gift card", nil))
+		return
+	}
+
+	c.JSON(http.StatusCreated, global.SuccessResponse(giftCard))
+}
+
+// GetAllGiftCards lists every issued gift card
+func GetAllGiftCards(c *gin.Context) {
+	giftCards, err := mongo.GetAllGiftCards(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to get gift cards", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(giftCards, global.SinglePage(len(giftCards)), nil))
+}
+
+// GetGiftCardBalance returns the remaining balance for a gift card code
+func GetGiftCardBalance(c *gin.Context) {
+	code := c.Param("code")
+
+	giftCard, err := mongo.GetGiftCardByCode(c.Request.Context(), code)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Gift card not found", global.ValidationError{Field: "code", Message: "No gift card exists with this code", Code: errorcodes.NotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch gift card", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(giftCard.ToBalance()))
+}
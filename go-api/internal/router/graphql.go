@@ -0,0 +1,385 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/graphql"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// The GraphQL endpoint is a read-only view over the same mongo helpers as the REST
+// handlers. Object fields come from the models' JSON names, plus the links between
+// them (order -> items -> product, order -> customer, product -> reviews,
+// customer -> orders), so a page can fetch everything it renders in one request.
+// Like /api/search, only products and reviews are public: orders and customers are
+// in the schema only for requests with a valid X-Admin-Key.
+var (
+	graphqlPublicSchema *graphql.Schema
+	graphqlAdminSchema  *graphql.Schema
+	graphqlSchemaOnce   sync.Once
+)
+
+// graphqlPage is a cursor page of products, orders or customers
+type graphqlPage struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor"`
+	HasMore    bool        `json:"has_more"`
+}
+
+// ServeGraphQL runs a GraphQL query from a POST body or GET query parameters
+func ServeGraphQL(c *gin.Context) {
+	var request graphql.Request
+	if c.Request.Method == http.MethodGet {
+		request.Query = c.Query("query")
+		request.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				respondWithError(c, "Invalid variables", global.ValidationError{Field: "variables", Message: "variables must be a JSON object", Code: errorcodes.JSONParseError})
+				return
+			}
+		}
+		if request.Query == "" {
+			respondWithError(c, "Missing query", global.ValidationError{Field: "query", Message: "is required", Code: errorcodes.Required})
+			return
+		}
+	} else if !bindJSON(c, &request) {
+		return
+	}
+
+	graphqlSchemaOnce.Do(func() {
+		graphqlPublicSchema = buildGraphQLSchema(false)
+		graphqlAdminSchema = buildGraphQLSchema(true)
+	})
+	schema := graphqlPublicSchema
+	if isAdminRequest(c) {
+		schema = graphqlAdminSchema
+	}
+
+	ctx := context.WithValue(c.Request.Context(), graphqlLoaderKey{}, newGraphQLLoader())
+	response := graphql.Execute(ctx, schema, request)
+	if response.Data == nil {
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// buildGraphQLSchema builds the schema admins see, or without admin the public one,
+// which has no path to an order or customer
+func buildGraphQLSchema(admin bool) *graphql.Schema {
+	types := graphql.NewTypes()
+	product := types.Object("Product", models.Product{})
+	order := types.Object("Order", models.Order{})
+	orderItem := types.Object("OrderItem", models.OrderItem{})
+	customer := types.Object("Customer", models.Customer{})
+	review := types.Object("Review", models.Review{})
+
+	order.Fields["items"] = &graphql.FieldDef{Type: orderItem, Resolve: resolveOrderItems}
+	order.Fields["customer"] = &graphql.FieldDef{Type: customer, Resolve: resolveOrderCustomer}
+	orderItem.Fields["product"] = &graphql.FieldDef{Type: product, Resolve: resolveOrderItemProduct}
+	product.Fields["reviews"] = &graphql.FieldDef{Type: review, Args: []string{"limit"}, Resolve: resolveProductReviews}
+	customer.Fields["orders"] = &graphql.FieldDef{Type: order, Args: []string{"limit"}, Resolve: resolveCustomerOrders}
+	review.Fields["customer"] = &graphql.FieldDef{Type: customer, Resolve: resolveReviewCustomer}
+
+	pageArgs := []string{"limit", "cursor"}
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.FieldDef{
+		"product":   {Type: product, Args: []string{"sku"}, Resolve: resolveProduct},
+		"products":  {Type: pageObject("ProductPage", product), Args: pageArgs, Resolve: resolvePage(mongo.GetProductsPage, &[]models.Product{})},
+		"order":     {Type: order, Args: []string{"order_number"}, Resolve: resolveOrder},
+		"orders":    {Type: pageObject("OrderPage", order), Args: pageArgs, Resolve: resolvePage(mongo.GetOrdersPage, &[]models.Order{})},
+		"customer":  {Type: customer, Args: []string{"id"}, Resolve: resolveCustomer},
+		"customers": {Type: pageObject("CustomerPage", customer), Args: pageArgs, Resolve: resolvePage(allCustomersPage, &[]models.Customer{})},
+		"reviews":   {Type: review, Args: []string{"sku", "limit"}, Resolve: resolveReviews},
+	}}
+
+	if !admin {
+		for _, name := range []string{"order", "orders", "customer", "customers"} {
+			delete(query.Fields, name)
+		}
+		delete(review.Fields, "customer")
+	}
+	return &graphql.Schema{Query: query}
+}
+
+func pageObject(name string, item *graphql.Object) *graphql.Object {
+	return &graphql.Object{Name: name, Fields: map[string]*graphql.FieldDef{
+		"items":       {Type: item},
+		"next_cursor": {},
+		"has_more":    {},
+	}}
+}
+
+// graphqlLimit reads a limit argument, defaulting to 20 and capped at 100 like the REST lists
+func graphqlLimit(args map[string]interface{}) (int, error) {
+	limit, err := graphql.IntArg(args, "limit", 20)
+	if err != nil {
+		return 0, err
+	}
+	if limit < 1 || limit > 100 {
+		return 0, errors.New("limit must be between 1 and 100")
+	}
+	return limit, nil
+}
+
+// resolvePage lists a collection with keyset pagination, decoding items into a fresh
+// slice of the same type as sample
+func resolvePage(fetch func(ctx context.Context, cursor string, limit int) (*mongo.CursorPage, error), sample interface{}) graphql.ResolveFunc {
+	return func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+		limit, err := graphqlLimit(args)
+		if err != nil {
+			return nil, err
+		}
+		cursor, _ := graphql.StringArg(args, "cursor")
+
+		page, err := fetch(ctx, cursor, limit)
+		if err != nil {
+			return nil, err
+		}
+		items, err := decodeDocuments(page.Items, sample)
+		if err != nil {
+			return nil, err
+		}
+		return graphqlPage{Items: items, NextCursor: page.NextCursor, HasMore: page.HasMore}, nil
+	}
+}
+
+// allCustomersPage is a cursor page of every customer, for the customers query
+func allCustomersPage(ctx context.Context, cursor string, limit int) (*mongo.CursorPage, error) {
+	return mongo.GetCustomersPage(ctx, mongo.CustomerFilter{}, cursor, limit)
+}
+
+// decodeDocuments decodes raw documents into a new slice shaped like *sample
+func decodeDocuments(documents []bson.M, sample interface{}) (interface{}, error) {
+	raw, err := bson.Marshal(bson.D{{Key: "items", Value: documents}})
+	if err != nil {
+		return nil, err
+	}
+	target := reflect.New(reflect.TypeOf(sample).Elem()).Interface()
+	if err := bson.Raw(raw).Lookup("items").Unmarshal(target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+func resolveProduct(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+	sku, _ := graphql.StringArg(args, "sku")
+	return loaderFrom(ctx).product(ctx, sku)
+}
+
+func resolveOrder(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+	orderNumber, _ := graphql.StringArg(args, "order_number")
+	order, err := mongo.GetOrderByNumber(ctx, orderNumber)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return order, nil
+}
+
+func resolveCustomer(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+	id, _ := graphql.StringArg(args, "id")
+	customerID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("id must be a 24-character hex ObjectID")
+	}
+	return loaderFrom(ctx).customer(ctx, customerID)
+}
+
+func resolveReviews(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+	limit, err := graphqlLimit(args)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := mongo.ReviewFilter{}
+	if sku, ok := graphql.StringArg(args, "sku"); ok {
+		product, err := loaderFrom(ctx).product(ctx, sku)
+		if err != nil || product == nil {
+			return nil, err
+		}
+		filter.ProductID = product.ID
+	}
+
+	result, err := mongo.GetAllReviews(ctx, filter, 1, limit)
+	if err != nil {
+		return nil, err
+	}
+	return result.Reviews, nil
+}
+
+func resolveOrderItems(ctx context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+	order := asOrder(source)
+	skus := make([]string, 0, len(order.Items))
+	for _, item := range order.Items {
+		skus = append(skus, item.SKU)
+	}
+	// Load every item's product in one query in case the selection asks for them
+	if err := loaderFrom(ctx).primeProducts(ctx, skus); err != nil {
+		return nil, err
+	}
+	return order.Items, nil
+}
+
+func resolveOrderItemProduct(ctx context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+	item, ok := source.(models.OrderItem)
+	if !ok {
+		return nil, nil
+	}
+	return loaderFrom(ctx).product(ctx, item.SKU)
+}
+
+func resolveOrderCustomer(ctx context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+	return loaderFrom(ctx).customer(ctx, asOrder(source).CustomerID)
+}
+
+func resolveReviewCustomer(ctx context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+	review, ok := source.(models.Review)
+	if !ok {
+		return nil, nil
+	}
+	return loaderFrom(ctx).customer(ctx, review.CustomerID)
+}
+
+func resolveProductReviews(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+	limit, err := graphqlLimit(args)
+	if err != nil {
+		return nil, err
+	}
+	result, err := mongo.GetAllReviews(ctx, mongo.ReviewFilter{ProductID: asProduct(source).ID}, 1, limit)
+	if err != nil {
+		return nil, err
+	}
+	return result.Reviews, nil
+}
+
+func resolveCustomerOrders(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+	limit, err := graphqlLimit(args)
+	if err != nil {
+		return nil, err
+	}
+	result, err := mongo.GetCustomerOrdersWithStats(ctx, asCustomer(source).ID, 1, limit)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDocuments(result.Orders, &[]models.Order{})
+}
+
+// Sources arrive as pointers from single lookups and as values from lists
+func asOrder(source interface{}) *models.Order {
+	switch order := source.(type) {
+	case *models.Order:
+		return order
+	case models.Order:
+		return &order
+	}
+	return &models.Order{}
+}
+
+func asProduct(source interface{}) *models.Product {
+	switch product := source.(type) {
+	case *models.Product:
+		return product
+	case models.Product:
+		return &product
+	}
+	return &models.Product{}
+}
+
+func asCustomer(source interface{}) *models.Customer {
+	switch customer := source.(type) {
+	case *models.Customer:
+		return customer
+	case models.Customer:
+		return &customer
+	}
+	return &models.Customer{}
+}
+
+// graphqlLoader caches products and customers for one request, so an order whose
+// items share a product, or a list of orders from one customer, looks each up once
+type graphqlLoader struct {
+	mu        sync.Mutex
+	products  map[string]*models.Product
+	customers map[bson.ObjectID]*models.Customer
+}
+
+type graphqlLoaderKey struct{}
+
+func newGraphQLLoader() *graphqlLoader {
+	return &graphqlLoader{
+		products:  map[string]*models.Product{},
+		customers: map[bson.ObjectID]*models.Customer{},
+	}
+}
+
+func loaderFrom(ctx context.Context) *graphqlLoader {
+	if loader, ok := ctx.Value(graphqlLoaderKey{}).(*graphqlLoader); ok {
+		return loader
+	}
+	return newGraphQLLoader()
+}
+
+// primeProducts fetches the products not cached yet with a single query. SKUs with no
+// product are cached as nil so they aren't looked up again.
+func (l *graphqlLoader) primeProducts(ctx context.Context, skus []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	missing := []string{}
+	for _, sku := range skus {
+		if _, ok := l.products[sku]; !ok {
+			missing = append(missing, sku)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	found, err := mongo.GetProductsBySKUs(ctx, missing)
+	if err != nil {
+		return err
+	}
+	for _, sku := range missing {
+		l.products[sku] = found[sku]
+	}
+	return nil
+}
+
+func (l *graphqlLoader) product(ctx context.Context, sku string) (*models.Product, error) {
+	if err := l.primeProducts(ctx, []string{sku}); err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.products[sku], nil
+}
+
+func (l *graphqlLoader) customer(ctx context.Context, id bson.ObjectID) (*models.Customer, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if customer, ok := l.customers[id]; ok {
+		return customer, nil
+	}
+	customer, err := mongo.GetCustomerByID(ctx, id)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNotFound) {
+			return nil, err
+		}
+		customer = nil
+	}
+	l.customers[id] = customer
+	return customer, nil
+}
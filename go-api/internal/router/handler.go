@@ -1,15 +1,22 @@
 package router
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"golang.org/x/crypto/bcrypt"
 	"julianmorley.ca/con-plar/prog2270/pkg/ai"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
 	"julianmorley.ca/con-plar/prog2270/pkg/global"
 	"julianmorley.ca/con-plar/prog2270/pkg/models"
 	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
@@ -26,13 +33,19 @@ func HealthCheck(c *gin.Context) {
 }
 
 func GetAllProducts(c *gin.Context) {
-	products, err := mongo.GetAllProducts()
+	if cursor, limit, ok := cursorPageRequest(c); ok {
+		page, err := mongo.GetProductsPage(c.Request.Context(), cursor, limit)
+		respondWithCursorPage(c, "products", limit, page, err)
+		return
+	}
+
+	products, err := mongo.GetAllProducts(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to get products", nil))
 		return
 	}
 
-	c.JSON(http.StatusOK, global.SuccessResponse(products))
+	respondWithList(c, "products", products, global.SinglePage(len(products)), nil)
 }
 
 // GetProductBySKU retrieves a product by SKU with Redis caching
@@ -41,122 +54,115 @@ func GetProductBySKU(c *gin.Context) {
 
 	// Validate SKU format (basic validation)
 	if len(sku) < 3 || len(sku) > 50 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid SKU format", []global.ValidationError{
-			{Field: "sku", Message: "SKU must be between 3 and 50 characters", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid SKU format", global.ValidationError{Field: "sku", Message: "SKU must be between 3 and 50 characters", Code: errorcodes.InvalidFormat})
 		return
 	}
 
 	ctx := c.Request.Context()
 
-	// Try Redis cache first using SKU
-	product, err := redis.GetProductBySKUFromCache(ctx, sku)
-	if err == nil {
-		// Found in cache, return immediately
-		c.Header("X-Cache", "HIT")
-		c.JSON(http.StatusOK, global.SuccessResponse(product))
-		return
-	}
-
-	// Cache miss, check MongoDB by SKU
-	product, err = mongo.GetProductBySKU(ctx, sku)
+	// Read through the product cache, loading from MongoDB on a miss
+	product, cacheStatus, err := redis.FetchProduct(ctx, sku, func() (*models.Product, error) {
+		return mongo.GetProductBySKU(ctx, sku)
+	})
 	if err != nil {
 		// Check if it's a "not found" error
-		if err.Error() == "mongo: no documents in result" {
-			c.JSON(http.StatusNotFound, global.ErrorResponse("Product not found", []global.ValidationError{
-				{Field: "sku", Message: "No product exists with this SKU", Code: "not_found"},
-			}))
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
 			return
 		}
 		// Other database error
-		log.Printf("Error fetching product from MongoDB: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error fetching product from MongoDB", "error", err)
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch product", nil))
 		return
 	}
 
-	// Found in MongoDB, cache it for future requests
-	if cacheErr := redis.CacheSingleProduct(ctx, product); cacheErr != nil {
-		// Log cache error but don't fail the request
-		log.Printf("Warning: Failed to cache product in Redis: %v", cacheErr)
-	}
-
-	// Return product with cache miss indicator
-	c.Header("X-Cache", "MISS")
-	c.JSON(http.StatusOK, global.SuccessResponse(product))
+	c.Header("X-Cache", cacheStatus)
+	respondWithProduct(c, product)
 }
 
-// EditProductBySKU updates specific fields of a product by SKU
+// EditProductBySKU applies a JSON merge patch to a product by SKU
 func EditProductBySKU(c *gin.Context) {
 	sku := c.Param("sku")
 
 	// Validate SKU format
 	if len(sku) < 3 || len(sku) > 50 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid SKU format", []global.ValidationError{
-			{Field: "sku", Message: "SKU must be between 3 and 50 characters", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid SKU format", global.ValidationError{Field: "sku", Message: "SKU must be between 3 and 50 characters", Code: errorcodes.InvalidFormat})
 		return
 	}
 
 	ctx := c.Request.Context()
 
-	// Parse JSON body into a map for partial updates
-	var updates map[string]interface{}
-	if err := c.ShouldBindJSON(&updates); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid JSON format", []global.ValidationError{
-			{Field: "body", Message: err.Error(), Code: "json_parse_error"},
-		}))
+	// Parse the body as a JSON merge patch
+	patch, ok := bindMergePatch(c)
+	if !ok {
+		return
+	}
+	updates, patchErrors := flattenMergePatch(c, patch, productPatchRules)
+	if len(patchErrors) > 0 {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", patchErrors))
 		return
 	}
 
-	// Validate that we have updates to apply
+	// Check if we still have updates after removing read-only fields
 	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("No updates provided", []global.ValidationError{
-			{Field: "body", Message: "Request body must contain at least one field to update", Code: "empty_updates"},
-		}))
+		respondWithError(c, "No valid updates provided", global.ValidationError{Field: "body", Message: "All provided fields are immutable and cannot be updated", Code: errorcodes.NoValidUpdates})
 		return
 	}
 
-	// Prevent updating immutable fields - remove them from updates instead of erroring
-	immutableFields := []string{"_id", "id", "sku", "created_at"}
-	for _, field := range immutableFields {
-		if _, exists := updates[field]; exists {
-			delete(updates, field)
-			log.Printf("Warning: Removed immutable field '%s' from update request", field)
+	current, err := mongo.GetProductBySKU(ctx, sku)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
+			return
 		}
+		slog.ErrorContext(c.Request.Context(), "Error fetching product from MongoDB", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to update product", nil))
+		return
 	}
 
-	// Check if we still have updates after removing immutable fields
-	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("No valid updates provided", []global.ValidationError{
-			{Field: "body", Message: "All provided fields are immutable and cannot be updated", Code: "no_valid_updates"},
-		}))
+	// With If-Match, only apply the edit to the version the client last fetched
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch != "" && !etagMatches(ifMatch, productETag(current), false) {
+		preconditionFailed(c, current)
+		return
+	}
+
+	if errs := validateMergePatch(current, updates, &models.Product{}); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", errs))
 		return
 	}
 
-	// Update the product in MongoDB
-	updatedProduct, err := mongo.UpdateProductBySKU(ctx, sku, updates)
+	var updatedProduct *models.Product
+	if ifMatch != "" {
+		updatedProduct, err = mongo.UpdateProductBySKUIfUnmodified(ctx, sku, current.UpdatedAt, updates)
+	} else {
+		updatedProduct, err = mongo.UpdateProductBySKU(ctx, sku, updates)
+	}
 	if err != nil {
+		if errors.Is(err, mongo.ErrProductModified) {
+			preconditionFailed(c, nil)
+			return
+		}
+		if validationErr, ok := updateError(err, ""); ok {
+			respondWithError(c, "Invalid request data", validationErr)
+			return
+		}
 		// Check if it's a "not found" error
-		if err.Error() == "mongo: no documents in result" {
-			c.JSON(http.StatusNotFound, global.ErrorResponse("Product not found", []global.ValidationError{
-				{Field: "sku", Message: "No product exists with this SKU", Code: "not_found"},
-			}))
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
 			return
 		}
 		// Other database error
-		log.Printf("Error updating product in MongoDB: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error updating product in MongoDB", "error", err)
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to update product", nil))
 		return
 	}
 
-	// Update the entire document in Redis cache
-	if cacheErr := redis.CacheSingleProduct(ctx, updatedProduct); cacheErr != nil {
-		// Log cache error but don't fail the request since DB update succeeded
-		log.Printf("Warning: Failed to update product cache in Redis: %v", cacheErr)
-	}
-
 	// Return the updated product
 	c.Header("X-Cache", "REFRESHED")
+	if etag := productETag(updatedProduct); etag != "" {
+		c.Header("ETag", etag)
+	}
 	c.JSON(http.StatusOK, global.SuccessResponse(updatedProduct))
 }
 
@@ -166,9 +172,7 @@ func DeleteProductBySKU(c *gin.Context) {
 
 	// Validate SKU format
 	if len(sku) < 3 || len(sku) > 50 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid SKU format", []global.ValidationError{
-			{Field: "sku", Message: "SKU must be between 3 and 50 characters", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid SKU format", global.ValidationError{Field: "sku", Message: "SKU must be between 3 and 50 characters", Code: errorcodes.InvalidFormat})
 		return
 	}
 
@@ -178,24 +182,16 @@ func DeleteProductBySKU(c *gin.Context) {
 	deletedProduct, err := mongo.DeleteProductBySKU(ctx, sku)
 	if err != nil {
 		// Check if it's a "not found" error
-		if err.Error() == "mongo: no documents in result" {
-			c.JSON(http.StatusNotFound, global.ErrorResponse("Product not found", []global.ValidationError{
-				{Field: "sku", Message: "No product exists with this SKU", Code: "not_found"},
-			}))
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
 			return
 		}
 		// Other database error
-		log.Printf("Error deleting product from MongoDB: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error deleting product from MongoDB", "error", err)
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to delete product", nil))
 		return
 	}
 
-	// Remove from Redis cache
-	if cacheErr := redis.RemoveProductFromCache(ctx, deletedProduct); cacheErr != nil {
-		// Log cache error but don't fail the request since DB deletion succeeded
-		log.Printf("Warning: Failed to remove product from Redis cache: %v", cacheErr)
-	}
-
 	// Return success with the deleted product info
 	c.Header("X-Cache", "DELETED")
 	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
@@ -207,17 +203,17 @@ func DeleteProductBySKU(c *gin.Context) {
 func CreateNewProducts(c *gin.Context) {
 	var req []models.CreateProductRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", []global.ValidationError{
-			{Field: "request", Message: err.Error(), Code: "validation_error"},
-		}))
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	if len(req) == 0 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("No products provided", []global.ValidationError{
-			{Field: "products", Message: "At least one product is required", Code: "empty_array"},
-		}))
+		respondWithError(c, "No products provided", global.ValidationError{Field: "products", Message: "At least one product is required", Code: errorcodes.EmptyArray})
+		return
+	}
+
+	if wantsAsyncImport(c) {
+		queueImport(c, models.ImportProducts, req)
 		return
 	}
 
@@ -232,12 +228,6 @@ func CreateNewProducts(c *gin.Context) {
 		return
 	}
 
-	if err := redis.AddProductsToCache(c.Request.Context(), createdProducts); err != nil {
-		// Log the error but don't fail the request since MongoDB succeeded
-		// In production, you might want to use a proper logger here
-		log.Printf("Warning: Failed to cache products in Redis: %v", err)
-	}
-
 	c.JSON(http.StatusCreated, global.SuccessResponse(map[string]interface{}{
 		"products": createdProducts,
 		"count":    len(createdProducts),
@@ -249,43 +239,39 @@ func BulkEditProducts(c *gin.Context) {
 	var bulkUpdates []map[string]interface{}
 
 	if err := c.ShouldBindJSON(&bulkUpdates); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid JSON format", []global.ValidationError{
-			{Field: "body", Message: err.Error(), Code: "json_parse_error"},
-		}))
+		respondWithError(c, "Invalid JSON format", global.ValidationError{Field: "body", Message: err.Error(), Code: errorcodes.JSONParseError})
 		return
 	}
 
 	// Validate that we have updates to apply
 	if len(bulkUpdates) == 0 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("No updates provided", []global.ValidationError{
-			{Field: "body", Message: "Request body must contain at least one product update", Code: "empty_updates"},
-		}))
+		respondWithError(c, "No updates provided", global.ValidationError{Field: "body", Message: "Request body must contain at least one product update", Code: errorcodes.EmptyUpdates})
 		return
 	}
 
 	ctx := c.Request.Context()
 	var updatedProducts []*models.Product
-	var errors []global.ValidationError
+	var validationErrors []global.ValidationError
 
 	// Process each product update
 	for i, updateData := range bulkUpdates {
 		// Extract SKU from the update data
 		skuInterface, exists := updateData["sku"]
 		if !exists {
-			errors = append(errors, global.ValidationError{
+			validationErrors = append(validationErrors, global.ValidationError{
 				Field:   fmt.Sprintf("[%d].sku", i),
 				Message: "SKU is required for each product update",
-				Code:    "missing_sku",
+				Code:    errorcodes.MissingSKU,
 			})
 			continue
 		}
 
 		sku, ok := skuInterface.(string)
 		if !ok || len(sku) < 3 || len(sku) > 50 {
-			errors = append(errors, global.ValidationError{
+			validationErrors = append(validationErrors, global.ValidationError{
 				Field:   fmt.Sprintf("[%d].sku", i),
 				Message: "SKU must be a string between 3 and 50 characters",
-				Code:    "invalid_sku_format",
+				Code:    errorcodes.InvalidSKUFormat,
 			})
 			continue
 		}
@@ -298,21 +284,26 @@ func BulkEditProducts(c *gin.Context) {
 			}
 		}
 
-		// Remove immutable fields (same logic as EditProductBySKU)
-		immutableFields := []string{"_id", "id", "sku", "created_at"}
-		for _, field := range immutableFields {
+		// Reject update operators and positional paths before anything else
+		if keyErrors := operatorKeyErrors(updates, fmt.Sprintf("[%d]", i)); len(keyErrors) > 0 {
+			validationErrors = append(validationErrors, keyErrors...)
+			continue
+		}
+
+		// Remove read-only fields (same logic as EditProductBySKU)
+		for _, field := range productPatchRules.ReadOnly {
 			if _, exists := updates[field]; exists {
 				delete(updates, field)
-				log.Printf("Warning: Removed immutable field '%s' from bulk update for SKU %s", field, sku)
+				slog.WarnContext(c.Request.Context(), "Removed immutable field from bulk update", "field", field, "sku", sku)
 			}
 		}
 
 		// Skip if no valid updates remain
 		if len(updates) == 0 {
-			errors = append(errors, global.ValidationError{
+			validationErrors = append(validationErrors, global.ValidationError{
 				Field:   fmt.Sprintf("[%d]", i),
 				Message: fmt.Sprintf("No valid fields to update for SKU %s", sku),
-				Code:    "no_valid_updates",
+				Code:    errorcodes.NoValidUpdates,
 			})
 			continue
 		}
@@ -320,30 +311,29 @@ func BulkEditProducts(c *gin.Context) {
 		// Update the product in MongoDB
 		updatedProduct, err := mongo.UpdateProductBySKU(ctx, sku, updates)
 		if err != nil {
+			if validationErr, ok := updateError(err, fmt.Sprintf("[%d].", i)); ok {
+				validationErrors = append(validationErrors, validationErr)
+				continue
+			}
 			// Handle product not found
-			if err.Error() == "mongo: no documents in result" {
-				errors = append(errors, global.ValidationError{
+			if errors.Is(err, mongo.ErrNotFound) {
+				validationErrors = append(validationErrors, global.ValidationError{
 					Field:   fmt.Sprintf("[%d].sku", i),
 					Message: fmt.Sprintf("No product exists with SKU %s", sku),
-					Code:    "not_found",
+					Code:    errorcodes.NotFound,
 				})
 				continue
 			}
 			// Handle other database errors
-			log.Printf("Error updating product %s in MongoDB: %v", sku, err)
-			errors = append(errors, global.ValidationError{
+			slog.ErrorContext(c.Request.Context(), "Error updating product in MongoDB", "sku", sku, "error", err)
+			validationErrors = append(validationErrors, global.ValidationError{
 				Field:   fmt.Sprintf("[%d].sku", i),
 				Message: fmt.Sprintf("Failed to update product with SKU %s", sku),
-				Code:    "update_failed",
+				Code:    errorcodes.UpdateFailed,
 			})
 			continue
 		}
 
-		// Update Redis cache
-		if cacheErr := redis.CacheSingleProduct(ctx, updatedProduct); cacheErr != nil {
-			log.Printf("Warning: Failed to update product cache in Redis for SKU %s: %v", sku, cacheErr)
-		}
-
 		updatedProducts = append(updatedProducts, updatedProduct)
 	}
 
@@ -352,7 +342,7 @@ func BulkEditProducts(c *gin.Context) {
 	if len(updatedProducts) == 0 {
 		// All updates failed
 		statusCode = http.StatusBadRequest
-	} else if len(errors) > 0 {
+	} else if len(validationErrors) > 0 {
 		// Partial success
 		statusCode = http.StatusMultiStatus
 	}
@@ -364,9 +354,9 @@ func BulkEditProducts(c *gin.Context) {
 		"total_requested":  len(bulkUpdates),
 	}
 
-	if len(errors) > 0 {
-		responseData["errors"] = errors
-		responseData["error_count"] = len(errors)
+	if len(validationErrors) > 0 {
+		responseData["errors"] = validationErrors
+		responseData["error_count"] = len(validationErrors)
 	}
 
 	c.Header("X-Cache", "BULK-REFRESHED")
@@ -383,24 +373,19 @@ func BulkDeleteProducts(c *gin.Context) {
 	var deleteRequests []BulkDeleteRequest
 
 	// Parse JSON body - expecting array of objects with sku property
-	if err := c.ShouldBindJSON(&deleteRequests); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid JSON format", []global.ValidationError{
-			{Field: "body", Message: "Expected array of objects with 'sku' property", Code: "json_parse_error"},
-		}))
+	if !bindJSON(c, &deleteRequests) {
 		return
 	}
 
 	// Validate that we have SKUs to delete
 	if len(deleteRequests) == 0 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("No SKUs provided", []global.ValidationError{
-			{Field: "body", Message: "Request body must contain at least one object with SKU to delete", Code: "empty_array"},
-		}))
+		respondWithError(c, "No SKUs provided", global.ValidationError{Field: "body", Message: "Request body must contain at least one object with SKU to delete", Code: errorcodes.EmptyArray})
 		return
 	}
 
 	ctx := c.Request.Context()
 	var deletedProducts []*models.Product
-	var errors []global.ValidationError
+	var validationErrors []global.ValidationError
 	successCount := 0
 
 	// Process each SKU for deletion
@@ -409,10 +394,10 @@ func BulkDeleteProducts(c *gin.Context) {
 
 		// Validate SKU format
 		if len(sku) < 3 || len(sku) > 50 {
-			errors = append(errors, global.ValidationError{
+			validationErrors = append(validationErrors, global.ValidationError{
 				Field:   fmt.Sprintf("[%d].sku", i),
 				Message: "SKU must be between 3 and 50 characters",
-				Code:    "invalid_format",
+				Code:    errorcodes.InvalidFormat,
 			})
 			continue
 		}
@@ -421,30 +406,24 @@ func BulkDeleteProducts(c *gin.Context) {
 		deletedProduct, err := mongo.DeleteProductBySKU(ctx, sku)
 		if err != nil {
 			// Handle not found error
-			if err.Error() == "mongo: no documents in result" {
-				errors = append(errors, global.ValidationError{
+			if errors.Is(err, mongo.ErrNotFound) {
+				validationErrors = append(validationErrors, global.ValidationError{
 					Field:   fmt.Sprintf("[%d].sku", i),
 					Message: fmt.Sprintf("No product exists with SKU %s", sku),
-					Code:    "not_found",
+					Code:    errorcodes.NotFound,
 				})
 			} else {
 				// Other database error
-				log.Printf("Error deleting product %s from MongoDB: %v", sku, err)
-				errors = append(errors, global.ValidationError{
+				slog.ErrorContext(c.Request.Context(), "Error deleting product from MongoDB", "sku", sku, "error", err)
+				validationErrors = append(validationErrors, global.ValidationError{
 					Field:   fmt.Sprintf("[%d].sku", i),
 					Message: "Database error occurred",
-					Code:    "database_error",
+					Code:    errorcodes.DatabaseError,
 				})
 			}
 			continue
 		}
 
-		// Remove from Redis cache
-		if cacheErr := redis.RemoveProductFromCache(ctx, deletedProduct); cacheErr != nil {
-			// Log cache error but don't fail the request since DB deletion succeeded
-			log.Printf("Warning: Failed to remove product %s from Redis cache: %v", sku, cacheErr)
-		}
-
 		deletedProducts = append(deletedProducts, deletedProduct)
 		successCount++
 	}
@@ -462,9 +441,9 @@ func BulkDeleteProducts(c *gin.Context) {
 	}
 
 	// Add error information if any
-	if len(errors) > 0 {
-		responseData["error_count"] = len(errors)
-		responseData["errors"] = errors
+	if len(validationErrors) > 0 {
+		responseData["error_count"] = len(validationErrors)
+		responseData["errors"] = validationErrors
 	}
 
 	// Determine status code based on results
@@ -472,7 +451,7 @@ func BulkDeleteProducts(c *gin.Context) {
 	if successCount == 0 {
 		// All deletions failed
 		statusCode = http.StatusBadRequest
-	} else if len(errors) > 0 {
+	} else if len(validationErrors) > 0 {
 		// Partial success
 		statusCode = http.StatusMultiStatus
 	}
@@ -483,31 +462,37 @@ func BulkDeleteProducts(c *gin.Context) {
 }
 
 func GetAllOrders(c *gin.Context) {
-	orders, err := mongo.GetAllOrders()
+	if cursor, limit, ok := cursorPageRequest(c); ok {
+		page, err := mongo.GetOrdersPage(c.Request.Context(), cursor, limit)
+		respondWithCursorPage(c, "orders", limit, page, err)
+		return
+	}
+
+	orders, err := mongo.GetAllOrders(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to get orders", nil))
 		return
 	}
 
-	c.JSON(http.StatusOK, global.SuccessResponse(orders))
+	respondWithList(c, "orders", orders, global.SinglePage(len(orders)), nil)
 }
 
 // CreateNewOrders creates multiple orders from an array of order requests
 func CreateNewOrders(c *gin.Context) {
 	var orderRequests []models.CreateOrderRequest
 
-	if err := c.ShouldBindJSON(&orderRequests); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid JSON format", []global.ValidationError{
-			{Field: "body", Message: err.Error(), Code: "json_parse_error"},
-		}))
+	if !bindJSON(c, &orderRequests) {
 		return
 	}
 
 	// Validate that we have orders to create
 	if len(orderRequests) == 0 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("No orders provided", []global.ValidationError{
-			{Field: "orders", Message: "At least one order is required", Code: "empty_array"},
-		}))
+		respondWithError(c, "No orders provided", global.ValidationError{Field: "orders", Message: "At least one order is required", Code: errorcodes.EmptyArray})
+		return
+	}
+
+	if wantsAsyncImport(c) {
+		queueImport(c, models.ImportOrders, orderRequests)
 		return
 	}
 
@@ -526,9 +511,7 @@ func CreateNewOrders(c *gin.Context) {
 	}
 
 	if allFailed {
-		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to create any orders", []global.ValidationError{
-			{Field: "orders", Message: "All order creation attempts failed", Code: "creation_failed"},
-		}))
+		respondWithError(c, "Failed to create any orders", global.ValidationError{Field: "orders", Message: "All order creation attempts failed", Code: errorcodes.CreationFailed})
 		return
 	}
 
@@ -574,43 +557,39 @@ func BulkEditOrders(c *gin.Context) {
 	var bulkUpdates []map[string]interface{}
 
 	if err := c.ShouldBindJSON(&bulkUpdates); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid JSON format", []global.ValidationError{
-			{Field: "body", Message: err.Error(), Code: "json_parse_error"},
-		}))
+		respondWithError(c, "Invalid JSON format", global.ValidationError{Field: "body", Message: err.Error(), Code: errorcodes.JSONParseError})
 		return
 	}
 
 	// Validate that we have updates to apply
 	if len(bulkUpdates) == 0 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("No updates provided", []global.ValidationError{
-			{Field: "body", Message: "Request body must contain at least one order update", Code: "empty_updates"},
-		}))
+		respondWithError(c, "No updates provided", global.ValidationError{Field: "body", Message: "Request body must contain at least one order update", Code: errorcodes.EmptyUpdates})
 		return
 	}
 
 	ctx := c.Request.Context()
 	var updatedOrders []*models.Order
-	var errors []global.ValidationError
+	var validationErrors []global.ValidationError
 
 	// Process each order update
 	for i, updateData := range bulkUpdates {
 		// Extract order_number from the update data
 		orderNumberInterface, exists := updateData["order_number"]
 		if !exists {
-			errors = append(errors, global.ValidationError{
+			validationErrors = append(validationErrors, global.ValidationError{
 				Field:   fmt.Sprintf("[%d].order_number", i),
 				Message: "Order number is required for each order update",
-				Code:    "missing_order_number",
+				Code:    errorcodes.MissingOrderNumber,
 			})
 			continue
 		}
 
 		orderNumber, ok := orderNumberInterface.(string)
 		if !ok || len(orderNumber) < 3 || len(orderNumber) > 100 {
-			errors = append(errors, global.ValidationError{
+			validationErrors = append(validationErrors, global.ValidationError{
 				Field:   fmt.Sprintf("[%d].order_number", i),
 				Message: "Order number must be a string between 3 and 100 characters",
-				Code:    "invalid_order_number_format",
+				Code:    errorcodes.InvalidOrderNumberFormat,
 			})
 			continue
 		}
@@ -620,21 +599,34 @@ func BulkEditOrders(c *gin.Context) {
 			updates[key] = value
 		}
 
-		// Remove immutable fields
-		immutableFields := []string{"_id", "id", "order_number", "created_at", "customer_id", "customer_email"}
-		for _, field := range immutableFields {
+		// Reject update operators and positional paths before anything else
+		if keyErrors := operatorKeyErrors(updates, fmt.Sprintf("[%d]", i)); len(keyErrors) > 0 {
+			validationErrors = append(validationErrors, keyErrors...)
+			continue
+		}
+
+		// Remove read-only fields (same logic as EditOrderByNumber)
+		for _, field := range orderPatchRules.ReadOnly {
 			if _, exists := updates[field]; exists {
 				delete(updates, field)
-				log.Printf("Warning: Removed immutable field '%s' from bulk update for order %s", field, orderNumber)
+				slog.WarnContext(c.Request.Context(), "Removed immutable field from bulk update", "field", field, "order_number", orderNumber)
+			}
+		}
+
+		// Only the payment provider's webhooks change an order's payment
+		for field := range updates {
+			if field == "payment" || strings.HasPrefix(field, "payment.") {
+				delete(updates, field)
+				slog.WarnContext(c.Request.Context(), "Removed immutable field from bulk update", "field", field, "order_number", orderNumber)
 			}
 		}
 
 		// Skip if no valid updates remain
 		if len(updates) == 0 {
-			errors = append(errors, global.ValidationError{
+			validationErrors = append(validationErrors, global.ValidationError{
 				Field:   fmt.Sprintf("[%d]", i),
 				Message: fmt.Sprintf("No valid fields to update for order %s", orderNumber),
-				Code:    "no_valid_updates",
+				Code:    errorcodes.NoValidUpdates,
 			})
 			continue
 		}
@@ -642,21 +634,33 @@ func BulkEditOrders(c *gin.Context) {
 		// Update the order in MongoDB
 		updatedOrder, err := mongo.UpdateOrderByNumber(ctx, orderNumber, updates)
 		if err != nil {
+			if validationErr, ok := updateError(err, fmt.Sprintf("[%d].", i)); ok {
+				validationErrors = append(validationErrors, validationErr)
+				continue
+			}
 			// Handle order not found
-			if err.Error() == "mongo: no documents in result" {
-				errors = append(errors, global.ValidationError{
+			if errors.Is(err, mongo.ErrNotFound) {
+				validationErrors = append(validationErrors, global.ValidationError{
 					Field:   fmt.Sprintf("[%d].order_number", i),
 					Message: fmt.Sprintf("No order exists with order number %s", orderNumber),
-					Code:    "not_found",
+					Code:    errorcodes.NotFound,
+				})
+				continue
+			}
+			if errors.Is(err, mongo.ErrInsufficientStock) {
+				validationErrors = append(validationErrors, global.ValidationError{
+					Field:   fmt.Sprintf("[%d].status", i),
+					Message: fmt.Sprintf("Not enough warehouse stock to allocate order %s for processing", orderNumber),
+					Code:    errorcodes.InsufficientStock,
 				})
 				continue
 			}
 			// Handle other database errors
-			log.Printf("Error updating order %s in MongoDB: %v", orderNumber, err)
-			errors = append(errors, global.ValidationError{
+			slog.ErrorContext(c.Request.Context(), "Error updating order in MongoDB", "order_number", orderNumber, "error", err)
+			validationErrors = append(validationErrors, global.ValidationError{
 				Field:   fmt.Sprintf("[%d].order_number", i),
 				Message: fmt.Sprintf("Failed to update order with order number %s", orderNumber),
-				Code:    "update_failed",
+				Code:    errorcodes.UpdateFailed,
 			})
 			continue
 		}
@@ -669,7 +673,7 @@ func BulkEditOrders(c *gin.Context) {
 	if len(updatedOrders) == 0 {
 		// All updates failed
 		statusCode = http.StatusBadRequest
-	} else if len(errors) > 0 {
+	} else if len(validationErrors) > 0 {
 		// Partial success
 		statusCode = http.StatusMultiStatus
 	}
@@ -681,9 +685,9 @@ func BulkEditOrders(c *gin.Context) {
 		"total_requested": len(bulkUpdates),
 	}
 
-	if len(errors) > 0 {
-		responseData["errors"] = errors
-		responseData["error_count"] = len(errors)
+	if len(validationErrors) > 0 {
+		responseData["errors"] = validationErrors
+		responseData["error_count"] = len(validationErrors)
 	}
 
 	c.Header("X-Cache", "BULK-UPDATED")
@@ -700,24 +704,19 @@ func BulkDeleteOrders(c *gin.Context) {
 	var deleteRequests []BulkDeleteOrderRequest
 
 	// Parse JSON body - expecting array of objects with order_number property
-	if err := c.ShouldBindJSON(&deleteRequests); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid JSON format", []global.ValidationError{
-			{Field: "body", Message: "Expected array of objects with 'order_number' property", Code: "json_parse_error"},
-		}))
+	if !bindJSON(c, &deleteRequests) {
 		return
 	}
 
 	// Validate that we have order numbers to delete
 	if len(deleteRequests) == 0 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("No order numbers provided", []global.ValidationError{
-			{Field: "body", Message: "Request body must contain at least one order to delete", Code: "empty_array"},
-		}))
+		respondWithError(c, "No order numbers provided", global.ValidationError{Field: "body", Message: "Request body must contain at least one order to delete", Code: errorcodes.EmptyArray})
 		return
 	}
 
 	ctx := c.Request.Context()
 	var deletedOrders []*models.Order
-	var errors []global.ValidationError
+	var validationErrors []global.ValidationError
 	successCount := 0
 
 	// Process each order number for deletion
@@ -726,10 +725,10 @@ func BulkDeleteOrders(c *gin.Context) {
 
 		// Validate order number format
 		if len(orderNumber) < 3 || len(orderNumber) > 100 {
-			errors = append(errors, global.ValidationError{
+			validationErrors = append(validationErrors, global.ValidationError{
 				Field:   fmt.Sprintf("[%d].order_number", i),
 				Message: "Order number must be between 3 and 100 characters",
-				Code:    "invalid_format",
+				Code:    errorcodes.InvalidFormat,
 			})
 			continue
 		}
@@ -738,19 +737,19 @@ func BulkDeleteOrders(c *gin.Context) {
 		deletedOrder, err := mongo.DeleteOrderByNumber(ctx, orderNumber)
 		if err != nil {
 			// Handle not found error
-			if err.Error() == "mongo: no documents in result" || err.Error() == "order not found" {
-				errors = append(errors, global.ValidationError{
+			if errors.Is(err, mongo.ErrNotFound) {
+				validationErrors = append(validationErrors, global.ValidationError{
 					Field:   fmt.Sprintf("[%d].order_number", i),
 					Message: fmt.Sprintf("No order exists with order number %s", orderNumber),
-					Code:    "not_found",
+					Code:    errorcodes.NotFound,
 				})
 			} else {
 				// Other database error
-				log.Printf("Error deleting order %s from MongoDB: %v", orderNumber, err)
-				errors = append(errors, global.ValidationError{
+				slog.ErrorContext(c.Request.Context(), "Error deleting order from MongoDB", "order_number", orderNumber, "error", err)
+				validationErrors = append(validationErrors, global.ValidationError{
 					Field:   fmt.Sprintf("[%d].order_number", i),
 					Message: "Database error occurred",
-					Code:    "database_error",
+					Code:    errorcodes.DatabaseError,
 				})
 			}
 			continue
@@ -773,9 +772,9 @@ func BulkDeleteOrders(c *gin.Context) {
 	}
 
 	// Add error information if any
-	if len(errors) > 0 {
-		responseData["error_count"] = len(errors)
-		responseData["errors"] = errors
+	if len(validationErrors) > 0 {
+		responseData["error_count"] = len(validationErrors)
+		responseData["errors"] = validationErrors
 	}
 
 	// Determine status code based on results
@@ -783,7 +782,7 @@ func BulkDeleteOrders(c *gin.Context) {
 	if successCount == 0 {
 		// All deletions failed
 		statusCode = http.StatusBadRequest
-	} else if len(errors) > 0 {
+	} else if len(validationErrors) > 0 {
 		// Partial success
 		statusCode = http.StatusMultiStatus
 	}
@@ -799,9 +798,7 @@ func GetOrderByNumber(c *gin.Context) {
 
 	// Validate order number format (basic validation)
 	if len(orderNumber) < 3 || len(orderNumber) > 100 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid order number format", []global.ValidationError{
-			{Field: "order_number", Message: "Order number must be between 3 and 100 characters", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid order number format", global.ValidationError{Field: "order_number", Message: "Order number must be between 3 and 100 characters", Code: errorcodes.InvalidFormat})
 		return
 	}
 
@@ -811,14 +808,12 @@ func GetOrderByNumber(c *gin.Context) {
 	order, err := mongo.GetOrderByNumber(ctx, orderNumber)
 	if err != nil {
 		// Check if it's a "not found" error
-		if err.Error() == "mongo: no documents in result" {
-			c.JSON(http.StatusNotFound, global.ErrorResponse("Order not found", []global.ValidationError{
-				{Field: "order_number", Message: "No order exists with this order number", Code: "not_found"},
-			}))
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Order not found", global.ValidationError{Field: "order_number", Message: "No order exists with this order number", Code: errorcodes.NotFound})
 			return
 		}
 		// Other database error
-		log.Printf("Error fetching order from MongoDB: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error fetching order from MongoDB", "error", err)
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch order", nil))
 		return
 	}
@@ -827,66 +822,68 @@ func GetOrderByNumber(c *gin.Context) {
 	c.JSON(http.StatusOK, global.SuccessResponse(order))
 }
 
-// EditOrderByNumber updates specific fields of an order by order number
+// EditOrderByNumber applies a JSON merge patch to an order by order number
 func EditOrderByNumber(c *gin.Context) {
 	orderNumber := c.Param("orderNumber")
 
 	// Validate order number format
 	if len(orderNumber) < 3 || len(orderNumber) > 100 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid order number format", []global.ValidationError{
-			{Field: "order_number", Message: "Order number must be between 3 and 100 characters", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid order number format", global.ValidationError{Field: "order_number", Message: "Order number must be between 3 and 100 characters", Code: errorcodes.InvalidFormat})
 		return
 	}
 
 	ctx := c.Request.Context()
 
-	// Parse JSON body into a map for partial updates
-	var updates map[string]interface{}
-	if err := c.ShouldBindJSON(&updates); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid JSON format", []global.ValidationError{
-			{Field: "body", Message: err.Error(), Code: "json_parse_error"},
-		}))
+	// Parse the body as a JSON merge patch
+	patch, ok := bindMergePatch(c)
+	if !ok {
+		return
+	}
+	updates, patchErrors := flattenMergePatch(c, patch, orderPatchRules)
+	if len(patchErrors) > 0 {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", patchErrors))
 		return
 	}
 
-	// Validate that we have updates to apply
+	// Check if we still have updates after removing read-only fields
 	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("No updates provided", []global.ValidationError{
-			{Field: "body", Message: "Request body must contain at least one field to update", Code: "empty_updates"},
-		}))
+		respondWithError(c, "No valid updates provided", global.ValidationError{Field: "body", Message: "All provided fields are immutable and cannot be updated", Code: errorcodes.NoValidUpdates})
 		return
 	}
 
-	// Prevent updating immutable fields - remove them from updates instead of erroring
-	immutableFields := []string{"_id", "id", "order_number", "created_at", "customer_id", "customer_email"}
-	for _, field := range immutableFields {
-		if _, exists := updates[field]; exists {
-			delete(updates, field)
-			log.Printf("Warning: Removed immutable field '%s' from update request", field)
+	current, err := mongo.GetOrderByNumber(ctx, orderNumber)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Order not found", global.ValidationError{Field: "order_number", Message: "No order exists with this order number", Code: errorcodes.NotFound})
+			return
 		}
+		slog.ErrorContext(c.Request.Context(), "Error fetching order from MongoDB", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to update order", nil))
+		return
 	}
-
-	// Check if we still have updates after removing immutable fields
-	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("No valid updates provided", []global.ValidationError{
-			{Field: "body", Message: "All provided fields are immutable and cannot be updated", Code: "no_valid_updates"},
-		}))
+	if errs := validateMergePatch(current, updates, &models.Order{}); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", errs))
 		return
 	}
 
 	// Update the order in MongoDB
 	updatedOrder, err := mongo.UpdateOrderByNumber(ctx, orderNumber, updates)
 	if err != nil {
+		if validationErr, ok := updateError(err, ""); ok {
+			respondWithError(c, "Invalid request data", validationErr)
+			return
+		}
 		// Check if it's a "not found" error
-		if err.Error() == "mongo: no documents in result" {
-			c.JSON(http.StatusNotFound, global.ErrorResponse("Order not found", []global.ValidationError{
-				{Field: "order_number", Message: "No order exists with this order number", Code: "not_found"},
-			}))
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Order not found", global.ValidationError{Field: "order_number", Message: "No order exists with this order number", Code: errorcodes.NotFound})
+			return
+		}
+		if errors.Is(err, mongo.ErrInsufficientStock) {
+			respondWithError(c, "Insufficient stock", global.ValidationError{Field: "status", Message: "Not enough warehouse stock to allocate this order for processing", Code: errorcodes.InsufficientStock})
 			return
 		}
 		// Other database error
-		log.Printf("Error updating order in MongoDB: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error updating order in MongoDB", "error", err)
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to update order", nil))
 		return
 	}
@@ -901,9 +898,7 @@ func DeleteOrderByNumber(c *gin.Context) {
 
 	// Validate order number format
 	if len(orderNumber) < 3 || len(orderNumber) > 100 {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid order number format", []global.ValidationError{
-			{Field: "order_number", Message: "Order number must be between 3 and 100 characters", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid order number format", global.ValidationError{Field: "order_number", Message: "Order number must be between 3 and 100 characters", Code: errorcodes.InvalidFormat})
 		return
 	}
 
@@ -913,14 +908,12 @@ func DeleteOrderByNumber(c *gin.Context) {
 	deletedOrder, err := mongo.DeleteOrderByNumber(ctx, orderNumber)
 	if err != nil {
 		// Check if it's a "not found" error
-		if err.Error() == "mongo: no documents in result" || err.Error() == "order not found" {
-			c.JSON(http.StatusNotFound, global.ErrorResponse("Order not found", []global.ValidationError{
-				{Field: "order_number", Message: "No order exists with this order number", Code: "not_found"},
-			}))
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Order not found", global.ValidationError{Field: "order_number", Message: "No order exists with this order number", Code: errorcodes.NotFound})
 			return
 		}
 		// Other database error
-		log.Printf("Error deleting order from MongoDB: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error deleting order from MongoDB", "error", err)
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to delete order", nil))
 		return
 	}
@@ -932,50 +925,212 @@ func DeleteOrderByNumber(c *gin.Context) {
 	}))
 }
 
-// GetAllCategories retrieves all distinct categories from products
+// GetAllCategories retrieves all distinct categories from products, cached per language
 func GetAllCategories(c *gin.Context) {
-	categories, err := mongo.GetAllCategories()
+	ctx := c.Request.Context()
+
+	var categories []string
+	cacheStatus, err := redis.Categories.Fetch(ctx, mongo.Language(ctx), false, &categories, func() (err error) {
+		categories, err = mongo.GetAllCategories(ctx)
+		return err
+	})
 	if err != nil {
-		log.Printf("Error fetching categories: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error fetching categories", "error", err)
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch categories", nil))
 		return
 	}
 
-	// Return categories with count information
-	response := map[string]interface{}{
-		"categories":  categories,
-		"total_count": len(categories),
-	}
-
-	c.JSON(http.StatusOK, global.SuccessResponse(response))
+	c.Header("X-Cache", cacheStatus)
+	c.JSON(http.StatusOK, global.ListResponse(categories, global.SinglePage(len(categories)), nil))
 }
 
+// GetAllCustomers lists customers newest first, without their password hashes. Filters:
+// account_status, tier, min_total_spent, created_from and created_to (YYYY-MM-DD).
 func GetAllCustomers(c *gin.Context) {
-	customers, err := mongo.GetAllCustomers()
+	filter, ok := customerFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	if cursor, limit, ok := cursorPageRequest(c); ok {
+		page, err := mongo.GetCustomersPage(c.Request.Context(), filter, cursor, limit)
+		respondWithCursorPage(c, "customers", limit, page, err)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	result, err := mongo.GetAllCustomers(c.Request.Context(), filter, page, limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve customers: "+err.Error(), nil))
+		slog.ErrorContext(c.Request.Context(), "Error fetching customers", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve customers", nil))
 		return
 	}
 
-	c.JSON(http.StatusOK, global.SuccessResponse(customers))
+	respondWithList(c, "customers", result.Customers, result.Pagination, nil)
+}
+
+// customerFilterFromQuery reads the customer listing filters, responding with an error
+// when one is invalid
+func customerFilterFromQuery(c *gin.Context) (mongo.CustomerFilter, bool) {
+	filter := mongo.CustomerFilter{AccountStatus: c.Query("account_status")}
+
+	switch filter.AccountStatus {
+	case "", "active", "inactive", "suspended", "deleted":
+	default:
+		respondWithError(c, "Invalid account_status filter", global.ValidationError{Field: "account_status", Message: "account_status must be one of: active, inactive, suspended, deleted", Code: errorcodes.InvalidValue})
+		return filter, false
+	}
+
+	if tier := c.Query("tier"); tier != "" {
+		if _, _, ok := models.LoyaltyTierPointRange(tier); !ok {
+			respondWithError(c, "Invalid tier filter", global.ValidationError{Field: "tier", Message: "tier must be one of: Bronze, Silver, Gold, Platinum", Code: errorcodes.InvalidValue})
+			return filter, false
+		}
+		filter.LoyaltyTier = tier
+	}
+
+	if raw := c.Query("min_total_spent"); raw != "" {
+		minSpent, err := strconv.ParseFloat(raw, 64)
+		if err != nil || minSpent < 0 {
+			respondWithError(c, "Invalid min_total_spent filter", global.ValidationError{Field: "min_total_spent", Message: "min_total_spent must be a non-negative number", Code: errorcodes.InvalidValue})
+			return filter, false
+		}
+		filter.MinTotalSpent = &minSpent
+	}
+
+	if createdFrom := c.Query("created_from"); createdFrom != "" {
+		fromTime, err := time.Parse("2006-01-02", createdFrom)
+		if err != nil {
+			respondWithError(c, "Invalid created_from parameter", global.ValidationError{Field: "created_from", Message: "created_from must be in YYYY-MM-DD format", Code: errorcodes.InvalidFormat})
+			return filter, false
+		}
+		filter.CreatedFrom = &fromTime
+	}
+
+	if createdTo := c.Query("created_to"); createdTo != "" {
+		toTime, err := time.Parse("2006-01-02", createdTo)
+		if err != nil {
+			respondWithError(c, "Invalid created_to parameter", global.ValidationError{Field: "created_to", Message: "created_to must be in YYYY-MM-DD format", Code: errorcodes.InvalidFormat})
+			return filter, false
+		}
+		// Include the whole end day
+		toTime = toTime.Add(24 * time.Hour)
+		filter.CreatedTo = &toTime
+	}
+
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && !filter.CreatedFrom.Before(*filter.CreatedTo) {
+		respondWithError(c, "Invalid created date range", global.ValidationError{Field: "created_to", Message: "created_to must not be before created_from", Code: errorcodes.InvalidRange})
+		return filter, false
+	}
+
+	return filter, true
 }
 
-func GetAllReviews(c *gin.Context) {}
+// GetAllReviews lists reviews with pagination, rating/verified filters and sorting.
+// Requests that still pass item and id are served by GetReviewsForItem for compatibility.
+func GetAllReviews(c *gin.Context) {
+	if _, exists := c.Get("entity"); exists {
+		GetReviewsForItem(c)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var filter mongo.ReviewFilter
+
+	if ratingParam := c.Query("rating"); ratingParam != "" {
+		rating, err := strconv.Atoi(ratingParam)
+		if err != nil || rating < 1 || rating > 5 {
+			respondWithError(c, "Invalid rating filter", global.ValidationError{Field: "rating", Message: "rating must be an integer between 1 and 5", Code: errorcodes.InvalidValue})
+			return
+		}
+		filter.Rating = rating
+	}
+
+	filter.VerifiedOnly = c.Query("verified") == "true"
+
+	filter.SortBy = c.DefaultQuery("sort", "date")
+	if filter.SortBy != "date" && filter.SortBy != "helpful" {
+		respondWithError(c, "Invalid sort option", global.ValidationError{Field: "sort", Message: "sort must be one of: date, helpful", Code: errorcodes.InvalidValue})
+		return
+	}
+
+	// Moderators can list held-back reviews; everyone else only sees approved ones
+	filter.Status = c.Query("status")
+	switch filter.Status {
+	case "", "approved":
+	case "pending", "flagged":
+		if !isAdminRequest(c) {
+			respondWithError(c, "Admin access required", global.ValidationError{Field: "status", Message: "only admins can list pending or flagged reviews", Code: errorcodes.Forbidden})
+			return
+		}
+	default:
+		respondWithError(c, "Invalid status filter", global.ValidationError{Field: "status", Message: "status must be one of: approved, pending, flagged", Code: errorcodes.InvalidValue})
+		return
+	}
+
+	if productID := c.Query("product_id"); productID != "" {
+		objectID, err := bson.ObjectIDFromHex(productID)
+		if err != nil {
+			respondWithError(c, "Invalid product ID format", global.ValidationError{Field: "product_id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+			return
+		}
+		filter.ProductID = objectID
+	}
+
+	if customerID := c.Query("customer_id"); customerID != "" {
+		objectID, err := bson.ObjectIDFromHex(customerID)
+		if err != nil {
+			respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "customer_id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+			return
+		}
+		filter.CustomerID = objectID
+	}
+
+	result, err := mongo.GetAllReviews(c.Request.Context(), filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch reviews", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(result.Reviews, result.Pagination, nil))
+}
 
 func GetAllCartItems(c *gin.Context) {}
 
 func GetBaseAnalytics(c *gin.Context) {}
 
-func GetInventoryPagenated(c *gin.Context) {}
-
 func GetCustomerSegments(c *gin.Context) {
-	segments, err := mongo.GetCustomerSpendingSegments(c.Request.Context())
+	var segments *mongo.CustomerSegmentsResult
+	err := cachedAnalytics(c, &segments, func() (err error) {
+		segments, err = mongo.GetCustomerSpendingSegments(c.Request.Context())
+		return err
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch customer segments", nil))
 		return
 	}
 
-	c.JSON(http.StatusOK, global.SuccessResponse(segments))
+	respondWithList(c, "customer-segments", segments.Segments, global.SinglePage(len(segments.Segments)), map[string]interface{}{
+		"total_customers": segments.TotalCustomers,
+	})
 }
 
 func GetCustomerOrders(c *gin.Context) {
@@ -983,9 +1138,7 @@ func GetCustomerOrders(c *gin.Context) {
 
 	objectID, err := bson.ObjectIDFromHex(customerID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid customer ID format", []global.ValidationError{
-			{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
 		return
 	}
 
@@ -999,22 +1152,21 @@ func GetCustomerOrders(c *gin.Context) {
 		limit = 10
 	}
 
-	result, err := mongo.GetCustomerOrdersWithStats(objectID, page, limit)
+	result, err := mongo.GetCustomerOrdersWithStats(c.Request.Context(), objectID, page, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch customer orders", nil))
 		return
 	}
 
-	c.JSON(http.StatusOK, global.SuccessResponse(result))
+	respondWithList(c, "customer-orders", result.Orders, result.Pagination, map[string]interface{}{
+		"summary": result.Summary,
+	})
 }
 
 func CreateCustomer(c *gin.Context) {
 	var req models.CreateCustomerRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", []global.ValidationError{
-			{Field: "request", Message: err.Error(), Code: "validation_error"},
-		}))
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -1052,10 +1204,8 @@ func CreateCustomer(c *gin.Context) {
 
 	createdCustomer, err := mongo.CreateCustomer(c.Request.Context(), customer)
 	if err != nil {
-		if err.Error() == "email already exists" {
-			c.JSON(http.StatusConflict, global.ErrorResponse("Email already registered", []global.ValidationError{
-				{Field: "email", Message: "This email is already in use", Code: "duplicate_email"},
-			}))
+		if errors.Is(err, mongo.ErrDuplicate) {
+			respondWithError(c, "Email already registered", global.ValidationError{Field: "email", Message: "This email is already in use", Code: errorcodes.DuplicateEmail})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to create customer", nil))
@@ -1073,9 +1223,7 @@ func GetCustomerByID(c *gin.Context) {
 	// Validate ObjectID format
 	objectID, err := bson.ObjectIDFromHex(customerID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid customer ID format", []global.ValidationError{
-			{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
 		return
 	}
 
@@ -1084,10 +1232,8 @@ func GetCustomerByID(c *gin.Context) {
 	// Fetch customer from database
 	customer, err := mongo.GetCustomerByID(c.Request.Context(), objectID)
 	if err != nil {
-		if err.Error() == "customer not found" {
-			c.JSON(http.StatusNotFound, global.ErrorResponse("Customer not found", []global.ValidationError{
-				{Field: "id", Message: "No customer exists with this ID", Code: "not_found"},
-			}))
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Customer not found", global.ValidationError{Field: "id", Message: "No customer exists with this ID", Code: errorcodes.NotFound})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch customer", nil))
@@ -1102,9 +1248,7 @@ func UpdateCustomer(c *gin.Context) {
 
 	objectID, err := bson.ObjectIDFromHex(customerID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid customer ID format", []global.ValidationError{
-			{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
 		return
 	}
 
@@ -1112,19 +1256,14 @@ func UpdateCustomer(c *gin.Context) {
 
 	// Bind request payload
 	var req models.UpdateCustomerRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", []global.ValidationError{
-			{Field: "request", Message: err.Error(), Code: "validation_error"},
-		}))
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	updatedCustomer, err := mongo.UpdateCustomer(c.Request.Context(), objectID, &req)
 	if err != nil {
-		if err.Error() == "customer not found" {
-			c.JSON(http.StatusNotFound, global.ErrorResponse("Customer not found", []global.ValidationError{
-				{Field: "id", Message: "No customer exists with this ID", Code: "not_found"},
-			}))
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Customer not found", global.ValidationError{Field: "id", Message: "No customer exists with this ID", Code: errorcodes.NotFound})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to update customer", nil))
@@ -1134,32 +1273,59 @@ func UpdateCustomer(c *gin.Context) {
 	c.JSON(http.StatusOK, global.SuccessResponse(updatedCustomer))
 }
 
+// UpdateCustomerPreferences changes some of a customer's preferences without sending
+// the whole customer
+func UpdateCustomerPreferences(c *gin.Context) {
+	customerID := c.Param("id")
+
+	objectID, err := bson.ObjectIDFromHex(customerID)
+	if err != nil {
+		respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	// In Production, this would be protected to allow only the customer themselves or admins to access the data
+	var req models.UpdatePreferencesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	updatedCustomer, err := mongo.UpdateCustomerPreferences(c.Request.Context(), objectID, &req)
+	if err != nil {
+		switch err.Error() {
+		case "customer not found":
+			respondWithError(c, "Customer not found", global.ValidationError{Field: "id", Message: "No customer exists with this ID", Code: errorcodes.NotFound})
+		case "no fields to update":
+			respondWithError(c, "No updates provided", global.ValidationError{Field: "body", Message: "Request body must contain at least one preference to update", Code: errorcodes.EmptyUpdates})
+		default:
+			slog.ErrorContext(c.Request.Context(), "Error updating customer preferences", "customer_id", customerID, "error", err)
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to update preferences", nil))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(updatedCustomer))
+}
+
 func AddCustomerAddress(c *gin.Context) {
 	customerID := c.Param("id")
 
 	objectID, err := bson.ObjectIDFromHex(customerID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid customer ID format", []global.ValidationError{
-			{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
 		return
 	}
 
 	// In Production, this would be protected to allow only the customer themselves or admins to access the data
 	var address models.Address
-	if err := c.ShouldBindJSON(&address); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid address data", []global.ValidationError{
-			{Field: "address", Message: err.Error(), Code: "validation_error"},
-		}))
+	if !bindJSON(c, &address) {
 		return
 	}
 
 	updatedCustomer, err := mongo.AddCustomerAddress(c.Request.Context(), objectID, address)
 	if err != nil {
-		if err.Error() == "customer not found" {
-			c.JSON(http.StatusNotFound, global.ErrorResponse("Customer not found", []global.ValidationError{
-				{Field: "id", Message: "No customer exists with this ID", Code: "not_found"},
-			}))
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Customer not found", global.ValidationError{Field: "id", Message: "No customer exists with this ID", Code: errorcodes.NotFound})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to add address", nil))
@@ -1173,41 +1339,30 @@ func UpdateCustomerAddress(c *gin.Context) {
 	customerID := c.Param("id")
 	addressIndex, err := strconv.Atoi(c.Param("addressId"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid address ID", []global.ValidationError{
-			{Field: "addressId", Message: "Must be a valid integer index", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid address ID", global.ValidationError{Field: "addressId", Message: "Must be a valid integer index", Code: errorcodes.InvalidFormat})
 		return
 	}
 
 	objectID, err := bson.ObjectIDFromHex(customerID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid customer ID format", []global.ValidationError{
-			{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
 		return
 	}
 
 	// In Production, this would be protected to allow only the customer themselves or admins to access the data
 	var address models.Address
-	if err := c.ShouldBindJSON(&address); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid address data", []global.ValidationError{
-			{Field: "address", Message: err.Error(), Code: "validation_error"},
-		}))
+	if !bindJSON(c, &address) {
 		return
 	}
 
 	updatedCustomer, err := mongo.UpdateCustomerAddress(c.Request.Context(), objectID, addressIndex, address)
 	if err != nil {
-		if err.Error() == "customer not found" {
-			c.JSON(http.StatusNotFound, global.ErrorResponse("Customer not found", []global.ValidationError{
-				{Field: "id", Message: "No customer exists with this ID", Code: "not_found"},
-			}))
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Customer not found", global.ValidationError{Field: "id", Message: "No customer exists with this ID", Code: errorcodes.NotFound})
 			return
 		}
-		if err.Error() == "address not found" {
-			c.JSON(http.StatusNotFound, global.ErrorResponse("Address not found", []global.ValidationError{
-				{Field: "addressId", Message: "No address exists at this index", Code: "not_found"},
-			}))
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Address not found", global.ValidationError{Field: "addressId", Message: "No address exists at this index", Code: errorcodes.NotFound})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to update address", nil))
@@ -1221,17 +1376,13 @@ func DeleteCustomerAddress(c *gin.Context) {
 	customerID := c.Param("id")
 	addressIndex, err := strconv.Atoi(c.Param("addressId"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid address ID", []global.ValidationError{
-			{Field: "addressId", Message: "Must be a valid integer index", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid address ID", global.ValidationError{Field: "addressId", Message: "Must be a valid integer index", Code: errorcodes.InvalidFormat})
 		return
 	}
 
 	objectID, err := bson.ObjectIDFromHex(customerID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid customer ID format", []global.ValidationError{
-			{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: "invalid_format"},
-		}))
+		respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
 		return
 	}
 
@@ -1239,22 +1390,16 @@ func DeleteCustomerAddress(c *gin.Context) {
 
 	updatedCustomer, err := mongo.DeleteCustomerAddress(c.Request.Context(), objectID, addressIndex)
 	if err != nil {
-		if err.Error() == "customer not found" {
-			c.JSON(http.StatusNotFound, global.ErrorResponse("Customer not found", []global.ValidationError{
-				{Field: "id", Message: "No customer exists with this ID", Code: "not_found"},
-			}))
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Customer not found", global.ValidationError{Field: "id", Message: "No customer exists with this ID", Code: errorcodes.NotFound})
 			return
 		}
-		if err.Error() == "address not found" {
-			c.JSON(http.StatusNotFound, global.ErrorResponse("Address not found", []global.ValidationError{
-				{Field: "addressId", Message: "No address exists at this index", Code: "not_found"},
-			}))
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Address not found", global.ValidationError{Field: "addressId", Message: "No address exists at this index", Code: errorcodes.NotFound})
 			return
 		}
-		if err.Error() == "cannot delete last address" {
-			c.JSON(http.StatusBadRequest, global.ErrorResponse("Cannot delete last address", []global.ValidationError{
-				{Field: "addressId", Message: "Customer must have at least one address", Code: "invalid_operation"},
-			}))
+		if errors.Is(err, mongo.ErrLastAddress) {
+			respondWithError(c, "Cannot delete last address", global.ValidationError{Field: "addressId", Message: "Customer must have at least one address", Code: errorcodes.InvalidOperation})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to delete address", nil))
@@ -1277,13 +1422,13 @@ func DeleteCustomer(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := global.GetDefaultTimer()
+	ctx, cancel := global.GetRequestTimer(c.Request.Context())
 	defer cancel()
 
 	// Delete customer from database
 	err = mongo.DeleteCustomer(ctx, customerID)
 	if err != nil {
-		if err.Error() == "customer not found" {
+		if errors.Is(err, mongo.ErrNotFound) {
 			c.JSON(http.StatusNotFound, global.ErrorResponse("Customer not found", []global.ValidationError{
 				{Field: "id", Message: "customer with this ID does not exist"},
 			}))
@@ -1327,13 +1472,16 @@ func GetReviewsForItem(c *gin.Context) {
 	}
 
 	// Get reviews from database
-	reviews, err := mongo.GetAllReviewsForItem(entityTypeStr, entityIDStr)
+	reviews, err := mongo.GetAllReviewsForItem(c.Request.Context(), entityTypeStr, entityIDStr)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve reviews: "+err.Error(), nil))
 		return
 	}
 
-	c.JSON(http.StatusOK, global.SuccessResponse(reviews))
+	c.JSON(http.StatusOK, global.ListResponse(reviews, global.SinglePage(len(reviews)), map[string]interface{}{
+		"entity": entityTypeStr,
+		"id":     entityIDStr,
+	}))
 }
 
 func CreateReviewForItem(c *gin.Context) {
@@ -1373,10 +1521,7 @@ func CreateReviewForItem(c *gin.Context) {
 
 	// Parse request body
 	var reviewRequest models.CreateReviewRequest
-	if err := c.ShouldBindJSON(&reviewRequest); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", []global.ValidationError{
-			{Field: "request", Message: err.Error(), Code: "validation_error"},
-		}))
+	if !bindJSON(c, &reviewRequest) {
 		return
 	}
 
@@ -1391,12 +1536,15 @@ func CreateReviewForItem(c *gin.Context) {
 	reviewRequest.ProductID = productObjID
 
 	// Create review in database
-	review, err := mongo.CreateReviewForItem(&reviewRequest)
+	review, err := mongo.CreateReviewForItem(c.Request.Context(), &reviewRequest)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to create review: "+err.Error(), nil))
 		return
 	}
 
+	// Screen for spam/abuse in the background so the response isn't held up by the AI call
+	go ai.ModerateReview(review)
+
 	c.JSON(http.StatusCreated, global.SuccessResponse(review))
 }
 func UpdateReviewForItem(c *gin.Context) {
@@ -1413,8 +1561,11 @@ func UpdateReviewForItem(c *gin.Context) {
 		return
 	}
 
-	// Get review ID from query parameter
-	reviewID := c.Query("reviewId")
+	// Get review ID from the nested route, falling back to the query parameter
+	reviewID := c.Param("reviewId")
+	if reviewID == "" {
+		reviewID = c.Query("reviewId")
+	}
 	if reviewID == "" {
 		c.JSON(http.StatusBadRequest, global.ErrorResponse("Review ID is required", []global.ValidationError{
 			{Field: "reviewId", Message: "reviewId query parameter is required"},
@@ -1445,17 +1596,14 @@ func UpdateReviewForItem(c *gin.Context) {
 
 	// Parse request body
 	var updateRequest models.UpdateReviewRequest
-	if err := c.ShouldBindJSON(&updateRequest); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", []global.ValidationError{
-			{Field: "request", Message: err.Error(), Code: "validation_error"},
-		}))
+	if !bindJSON(c, &updateRequest) {
 		return
 	}
 
 	// Update review in database
-	updatedReview, err := mongo.UpdateReviewForItem(reviewID, entityIDStr, &updateRequest)
+	updatedReview, err := mongo.UpdateReviewForItem(c.Request.Context(), reviewID, entityIDStr, &updateRequest)
 	if err != nil {
-		if err.Error() == "review not found" || err.Error() == "review not found for this product" {
+		if errors.Is(err, mongo.ErrNotFound) {
 			c.JSON(http.StatusNotFound, global.ErrorResponse("Review not found", []global.ValidationError{
 				{Field: "reviewId", Message: "review not found or does not belong to this product"},
 			}))
@@ -1481,8 +1629,11 @@ func DeleteReviewForItem(c *gin.Context) {
 		return
 	}
 
-	// Get review ID from query parameter
-	reviewID := c.Query("reviewId")
+	// Get review ID from the nested route, falling back to the query parameter
+	reviewID := c.Param("reviewId")
+	if reviewID == "" {
+		reviewID = c.Query("reviewId")
+	}
 	if reviewID == "" {
 		c.JSON(http.StatusBadRequest, global.ErrorResponse("Review ID is required", []global.ValidationError{
 			{Field: "reviewId", Message: "reviewId query parameter is required"},
@@ -1512,9 +1663,9 @@ func DeleteReviewForItem(c *gin.Context) {
 	}
 
 	// Delete review from database
-	deletedReviewID, err := mongo.DeleteReviewForItem(reviewID, entityIDStr)
+	deletedReviewID, err := mongo.DeleteReviewForItem(c.Request.Context(), reviewID, entityIDStr)
 	if err != nil {
-		if err.Error() == "review not found" || err.Error() == "review not found for this product" {
+		if errors.Is(err, mongo.ErrNotFound) {
 			c.JSON(http.StatusNotFound, global.ErrorResponse("Review not found", []global.ValidationError{
 				{Field: "reviewId", Message: "review not found or does not belong to this product"},
 			}))
@@ -1530,7 +1681,8 @@ func DeleteReviewForItem(c *gin.Context) {
 	})
 }
 
-// SearchDatabase searches across all collections and groups results by type
+// SearchDatabase searches across all collections and groups results by type. Requests
+// without the admin key search products only.
 func SearchDatabase(c *gin.Context) {
 	// Get search query parameter
 	query := c.Query("q")
@@ -1541,27 +1693,235 @@ func SearchDatabase(c *gin.Context) {
 		return
 	}
 
-	// Get optional limit parameter (default: 10 per collection)
-	limitStr := c.DefaultQuery("limit", "10")
-	limit, err := strconv.Atoi(limitStr)
+	// Get optional paging parameters (default: the first 10 of each type)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
 	if err != nil || limit < 1 || limit > 100 {
 		limit = 10
 	}
 
-	// Perform search across all collections
-	results, err := mongo.SearchDatabase(query, limit)
+	var types []string
+	for _, searchType := range splitFilterValues(c.QueryArray("types")) {
+		if !slices.Contains(mongo.SearchTypes, searchType) {
+			respondWithError(c, "Invalid search type", global.ValidationError{Field: "types", Message: "must be a comma-separated list of " + strings.Join(mongo.SearchTypes, ", "), Code: errorcodes.InvalidValue})
+			return
+		}
+		if !slices.Contains(types, searchType) {
+			types = append(types, searchType)
+		}
+	}
+
+	// Customer, order and review results are for admins; everyone else searches products
+	if !isAdminRequest(c) {
+		for _, searchType := range types {
+			if searchType != mongo.SearchTypeProducts {
+				respondWithError(c, "Admin access required", global.ValidationError{Field: "types", Message: "only admins can search " + searchType, Code: errorcodes.Forbidden})
+				return
+			}
+		}
+		types = []string{mongo.SearchTypeProducts}
+	}
+
+	filters, ok := parseProductFilters(c)
+	if !ok {
+		return
+	}
+
+	fuzziness := searchFuzziness
+	if raw := c.Query("fuzzy"); raw != "" {
+		fuzziness, err = strconv.Atoi(raw)
+		if err != nil || fuzziness < 0 || fuzziness > 2 {
+			respondWithError(c, "Invalid fuzzy parameter", global.ValidationError{Field: "fuzzy", Message: "fuzzy must be 0, 1 or 2", Code: errorcodes.OutOfRange})
+			return
+		}
+	}
+
+	// Look up likely spellings of unknown terms, which text searches include as
+	// optional terms and clients can offer as "did you mean"
+	corrections, err := redis.SpellingCorrections(c.Request.Context(), query, fuzziness)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "Error looking up spelling corrections", "error", err)
+		corrections = map[string][]string{}
+	}
+	var alternatives []string
+	for _, words := range corrections {
+		alternatives = append(alternatives, words...)
+	}
+	slices.Sort(alternatives)
+
+	// Perform search across the requested collections
+	request := mongo.SearchRequest{Query: query, Page: page, Limit: limit, Types: types, Filters: filters, Fuzziness: fuzziness, Alternatives: alternatives}
+	results, err := mongo.SearchDatabase(c.Request.Context(), request)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Search failed: "+err.Error(), nil))
 		return
 	}
+	recordSearch(c, request, results)
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":   "success",
+	// Flatten the groups into one list; each result carries its type
+	items := make([]mongo.SearchResult, 0, results.Total)
+	items = append(items, results.Products...)
+	items = append(items, results.Customers...)
+	items = append(items, results.Orders...)
+	items = append(items, results.Reviews...)
+
+	// Each type pages separately; the list has more while any type does
+	searched := request.SearchedTypes()
+	pages := map[string]global.PaginationInfo{}
+	pagination := global.NewPagination(page, limit, 0)
+	for _, searchType := range searched {
+		typePage := global.NewPagination(page, limit, results.Matches[searchType])
+		pages[searchType] = typePage
+		pagination.TotalItems += typePage.TotalItems
+		pagination.TotalPages = max(pagination.TotalPages, typePage.TotalPages)
+		pagination.HasMore = pagination.HasMore || typePage.HasMore
+	}
+
+	meta := map[string]interface{}{
 		"query":    query,
 		"limit":    limit,
-		"results":  results,
-		"searched": []string{"products", "customers", "orders", "reviews"},
-	})
+		"searched": searched,
+		"counts": map[string]int{
+			"products":  len(results.Products),
+			"customers": len(results.Customers),
+			"orders":    len(results.Orders),
+			"reviews":   len(results.Reviews),
+		},
+		"pages":       pages,
+		"fuzzy":       fuzziness,
+		"corrections": corrections,
+	}
+	if results.Facets != nil {
+		meta["facets"] = results.Facets
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(items, pagination, meta))
+}
+
+// attributeFilterPattern limits attribute filter names to plain field names
+var attributeFilterPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+
+// parseProductFilters reads the search filters: category and brand (comma-separated
+// lists), price_min, price_max, in_stock, and attr.<name> for product attributes. It
+// responds with an error and returns false when one is invalid.
+func parseProductFilters(c *gin.Context) (models.ProductFilters, bool) {
+	var filters models.ProductFilters
+	params := c.Request.URL.Query()
+
+	filters.Categories = splitFilterValues(params["category"])
+	filters.Brands = splitFilterValues(params["brand"])
+
+	for _, bound := range []struct {
+		name  string
+		value **float64
+	}{{"price_min", &filters.PriceMin}, {"price_max", &filters.PriceMax}} {
+		raw := params.Get(bound.name)
+		if raw == "" {
+			continue
+		}
+		price, err := strconv.ParseFloat(raw, 64)
+		if err != nil || price < 0 {
+			respondWithError(c, "Invalid price filter", global.ValidationError{Field: bound.name, Message: "must be a non-negative number", Code: errorcodes.InvalidValue})
+			return filters, false
+		}
+		*bound.value = &price
+	}
+	if filters.PriceMin != nil && filters.PriceMax != nil && *filters.PriceMin > *filters.PriceMax {
+		respondWithError(c, "Invalid price filter", global.ValidationError{Field: "price_min", Message: "must not be greater than price_max", Code: errorcodes.InvalidRange})
+		return filters, false
+	}
+
+	if raw := params.Get("in_stock"); raw != "" {
+		inStock, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondWithError(c, "Invalid stock filter", global.ValidationError{Field: "in_stock", Message: "must be true or false", Code: errorcodes.InvalidValue})
+			return filters, false
+		}
+		filters.InStock = &inStock
+	}
+
+	for key, values := range params {
+		name, isAttribute := strings.CutPrefix(key, "attr.")
+		if !isAttribute {
+			continue
+		}
+		if !attributeFilterPattern.MatchString(name) {
+			respondWithError(c, "Invalid attribute filter", global.ValidationError{Field: key, Message: "attribute names may only contain letters, digits, underscores and hyphens", Code: errorcodes.InvalidFormat})
+			return filters, false
+		}
+		if values := splitFilterValues(values); len(values) > 0 {
+			if filters.Attributes == nil {
+				filters.Attributes = map[string][]string{}
+			}
+			filters.Attributes[name] = values
+		}
+	}
+
+	return filters, true
+}
+
+// splitFilterValues flattens repeated and comma-separated query values
+func splitFilterValues(params []string) []string {
+	var values []string
+	for _, param := range params {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// maxSuggestions caps how many completions SuggestSearch returns
+const maxSuggestions = 10
+
+// SuggestSearch returns product name, brand and category completions for a prefix
+func SuggestSearch(c *gin.Context) {
+	query := c.Query("q")
+	if query == "" {
+		respondWithError(c, "Search query is required", global.ValidationError{Field: "q", Message: "q query parameter is required", Code: errorcodes.Required})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(maxSuggestions)))
+	if err != nil || limit < 1 || limit > maxSuggestions {
+		limit = maxSuggestions
+	}
+
+	suggestions, err := redis.SuggestProducts(c.Request.Context(), query, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error reading search suggestions", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to load suggestions", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(suggestions, global.SinglePage(len(suggestions)), map[string]interface{}{
+		"query": query,
+		"limit": limit,
+	}))
+}
+
+// cachedAnalytics fills dest from the Redis analytics cache when available, otherwise runs
+// load (which must populate dest) and caches the result. Results are keyed by route and
+// query string; ?refresh=true skips the cached copy and stores a fresh one.
+func cachedAnalytics(c *gin.Context, dest interface{}, load func() error) error {
+	query := c.Request.URL.Query()
+	refresh := query.Get("refresh") == "true"
+	query.Del("refresh")
+	query.Del("format")
+	key := c.FullPath() + "?" + query.Encode()
+
+	cacheStatus, err := redis.Analytics.Fetch(c.Request.Context(), key, refresh, dest, load)
+	if err != nil {
+		return err
+	}
+
+	c.Header("X-Cache", cacheStatus)
+	return nil
 }
 
 // GetSalesAnalytics returns daily sales summary with optional date range filtering
@@ -1580,19 +1940,148 @@ func GetSalesAnalytics(c *gin.Context) {
 	}
 
 	// Get sales analytics from database
-	salesData, err := mongo.GetSalesAnalytics(startDateStr, endDateStr, groupByStr)
+	var salesData []mongo.SalesData
+	err := cachedAnalytics(c, &salesData, func() (err error) {
+		salesData, err = mongo.GetSalesAnalytics(c.Request.Context(), startDateStr, endDateStr, groupByStr)
+		return err
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve sales analytics: "+err.Error(), nil))
 		return
 	}
 
+	respondWithList(c, "sales", salesData, global.SinglePage(len(salesData)), map[string]interface{}{
+		"group_by":   groupByStr,
+		"start_date": startDateStr,
+		"end_date":   endDateStr,
+	})
+}
+
+// GetSalesByRegion returns order revenue grouped by shipping province and city
+func GetSalesByRegion(c *gin.Context) {
+	startDateStr := c.Query("start_date") // Format: 2025-11-01
+	endDateStr := c.Query("end_date")     // Format: 2025-11-30
+	province := strings.ToUpper(c.Query("province"))
+
+	if province != "" && len(province) != 2 {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid province parameter", []global.ValidationError{
+			{Field: "province", Message: "province must be a two-letter code such as ON or BC"},
+		}))
+		return
+	}
+
+	var regions []mongo.RegionSales
+	err := cachedAnalytics(c, &regions, func() (err error) {
+		regions, err = mongo.GetSalesByRegion(c.Request.Context(), startDateStr, endDateStr, province)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve sales by region: "+err.Error(), nil))
+		return
+	}
+
+	respondWithList(c, "sales-by-region", regions, global.SinglePage(len(regions)), map[string]interface{}{
+		"start_date": startDateStr,
+		"end_date":   endDateStr,
+		"province":   province,
+	})
+}
+
+// GetRetentionAnalytics returns the repeat purchase rate, average days between orders, and AOV trend
+func GetRetentionAnalytics(c *gin.Context) {
+	startDateStr := c.Query("start_date")           // Format: 2025-11-01
+	endDateStr := c.Query("end_date")               // Format: 2025-11-30
+	groupByStr := c.DefaultQuery("group_by", "day") // day, week, month
+
+	// Validate group_by parameter
+	if groupByStr != "day" && groupByStr != "week" && groupByStr != "month" {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid group_by parameter", []global.ValidationError{
+			{Field: "group_by", Message: "group_by must be one of: day, week, month"},
+		}))
+		return
+	}
+
+	retention, err := mongo.GetRetentionAnalytics(c.Request.Context(), startDateStr, endDateStr, groupByStr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve retention analytics: "+err.Error(), nil))
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":     "success",
 		"group_by":   groupByStr,
 		"start_date": startDateStr,
 		"end_date":   endDateStr,
-		"data":       salesData,
+		"data":       retention,
+	})
+}
+
+// GetSalesFunnel reports counts and conversion rates from cart creation through payment
+func GetSalesFunnel(c *gin.Context) {
+	startDateStr := c.Query("start_date") // Format: 2025-11-01
+	endDateStr := c.Query("end_date")     // Format: 2025-11-30
+
+	// Default to the last 30 days
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	startDate := today.AddDate(0, 0, -29)
+	endDate := today
+
+	if startDateStr != "" {
+		parsed, err := time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid start_date parameter", []global.ValidationError{
+				{Field: "start_date", Message: "start_date must be in YYYY-MM-DD format"},
+			}))
+			return
+		}
+		startDate = parsed
+	}
+	if endDateStr != "" {
+		parsed, err := time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid end_date parameter", []global.ValidationError{
+				{Field: "end_date", Message: "end_date must be in YYYY-MM-DD format"},
+			}))
+			return
+		}
+		endDate = parsed
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid date range", []global.ValidationError{
+			{Field: "end_date", Message: "end_date must not be before start_date"},
+		}))
+		return
+	}
+
+	ctx, cancel := mongo.Analytics.Context(c.Request.Context())
+	defer cancel()
+
+	cartsCreated, err := redis.CountFunnelEvents(ctx, "cart_created", startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve cart events: "+err.Error(), nil))
+		return
+	}
+	checkoutsStarted, err := redis.CountFunnelEvents(ctx, "checkout_started", startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve checkout events: "+err.Error(), nil))
+		return
+	}
+
+	// endDate is inclusive, so count orders up to the start of the following day
+	ordersPlaced, ordersPaid, err := mongo.CountFunnelOrders(ctx, startDate, endDate.Add(24*time.Hour))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve orders: "+err.Error(), nil))
+		return
+	}
+
+	funnel := models.NewSalesFunnel(startDate, endDate, []models.FunnelStage{
+		{Stage: "cart_created", Count: cartsCreated},
+		{Stage: "checkout_started", Count: checkoutsStarted},
+		{Stage: "order_placed", Count: ordersPlaced},
+		{Stage: "order_paid", Count: ordersPaid},
 	})
+
+	c.JSON(http.StatusOK, global.SuccessResponse(funnel))
 }
 
 // GetTopProducts returns top N products by revenue or quantity
@@ -1625,13 +2114,21 @@ func GetTopProducts(c *gin.Context) {
 	}
 
 	// Get top products data
-	topProducts, err := mongo.GetTopProductsByRevenue(limit, sortBy, startDate, endDate)
+	var topProducts []mongo.TopProduct
+	err = cachedAnalytics(c, &topProducts, func() (err error) {
+		topProducts, err = mongo.GetTopProductsByRevenue(c.Request.Context(), limit, sortBy, startDate, endDate)
+		return err
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve top products: "+err.Error(), nil))
 		return
 	}
 
-	c.JSON(http.StatusOK, global.SuccessResponse(topProducts))
+	respondWithList(c, "top-products", topProducts, global.SinglePage(len(topProducts)), map[string]interface{}{
+		"sort_by":    sortBy,
+		"start_date": startDate,
+		"end_date":   endDate,
+	})
 }
 
 // GetInventoryAnalytics returns real-time inventory status with optional alerts filter
@@ -1646,27 +2143,44 @@ func GetInventoryAnalytics(c *gin.Context) {
 	}
 
 	// Get inventory status data
-	inventoryStatus, err := mongo.GetInventoryStatus(alertsOnly)
+	inventoryStatus, err := mongo.GetInventoryStatus(c.Request.Context(), alertsOnly)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve inventory status: "+err.Error(), nil))
 		return
 	}
 
-	// Add summary metadata
-	response := map[string]interface{}{
-		"inventory": inventoryStatus,
-		"summary": map[string]interface{}{
-			"total_products": len(inventoryStatus),
-			"alerts_only":    alertsOnly,
-		},
-	}
-
-	c.JSON(http.StatusOK, global.SuccessResponse(response))
+	respondWithList(c, "inventory", inventoryStatus, global.SinglePage(len(inventoryStatus)), map[string]interface{}{
+		"alerts_only": alertsOnly,
+	})
 }
 
 // Cart handlers
 
-// GetCart retrieves cart by session ID
+// restorePersistedCart reloads a cart from its MongoDB snapshot when the Redis copy has expired.
+// Failures are logged and treated as a fresh cart so the request can still proceed.
+func restorePersistedCart(ctx context.Context, sessionID string) {
+	exists, err := redis.CartExists(ctx, sessionID)
+	if err != nil || exists {
+		return
+	}
+
+	snapshot, err := mongo.GetCartSnapshot(ctx, sessionID)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNotFound) {
+			slog.ErrorContext(ctx, "Error loading persisted cart", "session_id", sessionID, "error", err)
+		}
+		return
+	}
+	if len(snapshot.Items) == 0 {
+		return
+	}
+
+	if _, err := redis.RestoreCart(ctx, snapshot); err != nil {
+		slog.ErrorContext(ctx, "Error restoring persisted cart", "session_id", sessionID, "error", err)
+	}
+}
+
+// GetCart retrieves cart by session ID, revalidating prices and stock by default
 func GetCart(c *gin.Context) {
 	sessionID := c.Param("sessionId")
 	if sessionID == "" {
@@ -1676,18 +2190,43 @@ func GetCart(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := global.GetDefaultTimer()
+	ctx, cancel := global.GetRequestTimer(c.Request.Context())
 	defer cancel()
 
+	restorePersistedCart(ctx, sessionID)
+
 	cart, err := redis.GetCart(ctx, sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve cart: "+err.Error(), nil))
 		return
 	}
 
+	// Re-check prices and stock against the catalog unless explicitly disabled
+	if c.DefaultQuery("revalidate", "true") != "false" && len(cart.Items) > 0 {
+		cart, err = revalidateCart(ctx, cart)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to revalidate cart: "+err.Error(), nil))
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, global.SuccessResponse(cart))
 }
 
+// revalidateCart checks a cart's prices and stock against the current catalog
+func revalidateCart(ctx context.Context, cart *models.Cart) (*models.Cart, error) {
+	skus := make([]string, 0, len(cart.Items))
+	for sku := range cart.Items {
+		skus = append(skus, sku)
+	}
+
+	products, err := mongo.GetProductsBySKUs(ctx, skus)
+	if err != nil {
+		return nil, err
+	}
+	return redis.RevalidateCart(ctx, cart, products)
+}
+
 // AddToCart adds an item to the cart
 func AddToCart(c *gin.Context) {
 	sessionID := c.Param("sessionId")
@@ -1699,20 +2238,17 @@ func AddToCart(c *gin.Context) {
 	}
 
 	var request models.AddToCartRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", []global.ValidationError{
-			{Field: "request", Message: err.Error(), Code: "validation_error"},
-		}))
+	if !bindJSON(c, &request) {
 		return
 	}
 
-	ctx, cancel := global.GetDefaultTimer()
+	ctx, cancel := global.GetRequestTimer(c.Request.Context())
 	defer cancel()
 
 	// Get product details by SKU
 	product, err := mongo.GetProductBySKU(ctx, request.SKU)
 	if err != nil {
-		if err.Error() == "product not found" {
+		if errors.Is(err, mongo.ErrNotFound) {
 			c.JSON(http.StatusNotFound, global.ErrorResponse("Product not found", []global.ValidationError{
 				{Field: "sku", Message: "product with this SKU does not exist"},
 			}))
@@ -1730,8 +2266,19 @@ func AddToCart(c *gin.Context) {
 		return
 	}
 
+	restorePersistedCart(ctx, sessionID)
+
+	// Apply the loyalty tier benefits of a known customer; guests get none
+	var loyaltyTier string
+	if request.CustomerEmail != "" {
+		loyaltyTier, err = mongo.GetLoyaltyTierByEmail(ctx, request.CustomerEmail)
+		if err != nil && !errors.Is(err, mongo.ErrNotFound) {
+			slog.WarnContext(c.Request.Context(), "Failed to look up loyalty tier", "session_id", sessionID, "error", err)
+		}
+	}
+
 	// Add to cart
-	cart, err := redis.AddToCart(ctx, sessionID, request.SKU, request.Quantity, product)
+	cart, err := redis.AddToCart(ctx, sessionID, request.SKU, request.Quantity, product, request.CustomerEmail, loyaltyTier)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to add item to cart: "+err.Error(), nil))
 		return
@@ -1759,16 +2306,15 @@ func UpdateCartItem(c *gin.Context) {
 	}
 
 	var request models.UpdateCartItemRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", []global.ValidationError{
-			{Field: "request", Message: err.Error(), Code: "validation_error"},
-		}))
+	if !bindJSON(c, &request) {
 		return
 	}
 
-	ctx, cancel := global.GetDefaultTimer()
+	ctx, cancel := global.GetRequestTimer(c.Request.Context())
 	defer cancel()
 
+	restorePersistedCart(ctx, sessionID)
+
 	// Update cart item
 	cart, err := redis.UpdateCartItem(ctx, sessionID, sku, request.Quantity)
 	if err != nil {
@@ -1803,9 +2349,11 @@ func RemoveFromCart(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := global.GetDefaultTimer()
+	ctx, cancel := global.GetRequestTimer(c.Request.Context())
 	defer cancel()
 
+	restorePersistedCart(ctx, sessionID)
+
 	// Remove from cart
 	cart, err := redis.RemoveFromCart(ctx, sessionID, sku)
 	if err != nil {
@@ -1831,7 +2379,7 @@ func ClearCart(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := global.GetDefaultTimer()
+	ctx, cancel := global.GetRequestTimer(c.Request.Context())
 	defer cancel()
 
 	err := redis.ClearCart(ctx, sessionID)
@@ -1840,12 +2388,61 @@ func ClearCart(c *gin.Context) {
 		return
 	}
 
+	// Drop the persisted snapshot too so the cleared cart is not restored later
+	if err := mongo.DeleteCartSnapshot(ctx, sessionID); err != nil {
+		slog.WarnContext(c.Request.Context(), "Failed to delete persisted cart", "session_id", sessionID, "error", err)
+	}
+
 	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
 		"session_id": sessionID,
 		"cleared":    true,
 	}))
 }
 
+// StartCheckout records that a cart has entered checkout and returns it for review
+func StartCheckout(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Session ID is required", []global.ValidationError{
+			{Field: "sessionId", Message: "sessionId URL parameter is required"},
+		}))
+		return
+	}
+
+	ctx, cancel := global.GetRequestTimer(c.Request.Context())
+	defer cancel()
+
+	restorePersistedCart(ctx, sessionID)
+
+	cart, err := redis.GetCart(ctx, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve cart: "+err.Error(), nil))
+		return
+	}
+
+	if len(cart.Items) == 0 {
+		respondWithError(c, "Cart is empty", global.ValidationError{Field: "sessionId", Message: "cannot start checkout with an empty cart", Code: errorcodes.EmptyCart})
+		return
+	}
+
+	// Checkout always uses current prices, and can't start while any item is unavailable
+	cart, err = revalidateCart(ctx, cart)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to revalidate cart: "+err.Error(), nil))
+		return
+	}
+	if cart.Revalidation.OutOfStock > 0 {
+		respondWithError(c, "Items are unavailable", global.ValidationError{Field: "items", Message: fmt.Sprintf("%d item(s) are out of stock or no longer sold; update the cart before checking out", cart.Revalidation.OutOfStock), Code: errorcodes.InsufficientStock})
+		return
+	}
+
+	if err := redis.TrackFunnelEvent(ctx, "checkout_started", sessionID); err != nil {
+		slog.WarnContext(c.Request.Context(), "Failed to track checkout_started", "session_id", sessionID, "error", err)
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(cart))
+}
+
 // AI Analytics Handlers
 
 // GenerateAISalesReport generates AI-powered sales analytics report
@@ -1854,11 +2451,11 @@ func GenerateAISalesReport(c *gin.Context) {
 	startDate := c.DefaultQuery("startDate", "")
 	endDate := c.DefaultQuery("endDate", "")
 
-	ctx, cancel := global.GetDefaultTimer()
+	ctx, cancel := mongo.Analytics.Context(c.Request.Context())
 	defer cancel()
 
 	// Generate AI sales report
-	report, err := ai.GenerateSalesReport(ctx, startDate, endDate)
+	report, err := ai.GenerateSalesReport(ctx, startDate, endDate, c.Query("refresh") == "true")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to generate sales report: "+err.Error(), nil))
 		return
@@ -1869,11 +2466,11 @@ func GenerateAISalesReport(c *gin.Context) {
 
 // GenerateAICustomerInsights generates AI-powered customer analytics
 func GenerateAICustomerInsights(c *gin.Context) {
-	ctx, cancel := global.GetDefaultTimer()
+	ctx, cancel := mongo.Analytics.Context(c.Request.Context())
 	defer cancel()
 
 	// Generate AI customer insights
-	report, err := ai.GenerateCustomerInsights(ctx)
+	report, err := ai.GenerateCustomerInsights(ctx, c.Query("refresh") == "true")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to generate customer insights: "+err.Error(), nil))
 		return
@@ -1888,11 +2485,11 @@ func GenerateAIInventoryReport(c *gin.Context) {
 	alertsOnlyStr := c.DefaultQuery("alertsOnly", "false")
 	alertsOnly := alertsOnlyStr == "true" || alertsOnlyStr == "1"
 
-	ctx, cancel := global.GetDefaultTimer()
+	ctx, cancel := mongo.Analytics.Context(c.Request.Context())
 	defer cancel()
 
 	// Generate AI inventory report
-	report, err := ai.GenerateInventoryReport(ctx, alertsOnly)
+	report, err := ai.GenerateInventoryReport(ctx, alertsOnly, c.Query("refresh") == "true")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to generate inventory report: "+err.Error(), nil))
 		return
@@ -1901,6 +2498,41 @@ func GenerateAIInventoryReport(c *gin.Context) {
 	c.JSON(http.StatusOK, report)
 }
 
+// GenerateAIDemandForecast forecasts units and revenue per SKU or category with AI commentary
+func GenerateAIDemandForecast(c *gin.Context) {
+	groupBy := c.DefaultQuery("group_by", "sku")
+	if groupBy != "sku" && groupBy != "category" {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid group_by parameter", []global.ValidationError{
+			{Field: "group_by", Message: "group_by must be either 'sku' or 'category'"},
+		}))
+		return
+	}
+
+	horizon, err := strconv.Atoi(c.DefaultQuery("horizon", "30"))
+	if err != nil || (horizon != 30 && horizon != 90) {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid horizon parameter", []global.ValidationError{
+			{Field: "horizon", Message: "horizon must be 30 or 90 days"},
+		}))
+		return
+	}
+
+	limit := 20
+	if limitValue, err := strconv.Atoi(c.DefaultQuery("limit", "20")); err == nil && limitValue > 0 && limitValue <= 100 {
+		limit = limitValue
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), aiStreamTimeout)
+	defer cancel()
+
+	report, err := ai.GenerateDemandForecast(ctx, groupBy, horizon, limit, c.Query("refresh") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to generate demand forecast: "+err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
 // GenerateAIProductAnalysis generates AI-powered top products analysis
 func GenerateAIProductAnalysis(c *gin.Context) {
 	// Get query parameters
@@ -1915,11 +2547,11 @@ func GenerateAIProductAnalysis(c *gin.Context) {
 		limit = limitValue
 	}
 
-	ctx, cancel := global.GetDefaultTimer()
+	ctx, cancel := mongo.Analytics.Context(c.Request.Context())
 	defer cancel()
 
 	// Generate AI product analysis
-	report, err := ai.GenerateTopProductsAnalysis(ctx, limit, sortBy, startDate, endDate)
+	report, err := ai.GenerateTopProductsAnalysis(ctx, limit, sortBy, startDate, endDate, c.Query("refresh") == "true")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to generate product analysis: "+err.Error(), nil))
 		return
@@ -0,0 +1,109 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/ai"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+)
+
+// healthCheckTimeout bounds each dependency check so a hung dependency fails the probe
+const healthCheckTimeout = 2 * time.Second
+
+// dependencyStatus is the outcome of checking one dependency
+type dependencyStatus struct {
+	Status    string   `json:"status"` // "ok", "degraded", "disabled" or "down"
+	Required  bool     `json:"required"`
+	LatencyMs int64    `json:"latency_ms"`
+	Error     string   `json:"error,omitempty"`
+	Missing   []string `json:"missing,omitempty"`
+	Provider  string   `json:"provider,omitempty"`
+}
+
+// LivenessCheck reports that the process is up and serving requests. It checks no
+// dependencies, so a failing database never gets the pod restarted.
+func LivenessCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]string{"status": "OK"}))
+}
+
+// ReadinessCheck pings MongoDB and Redis and verifies the required indexes exist,
+// responding 503 when any of them fails. AI is reported but never blocks readiness.
+func ReadinessCheck(c *gin.Context) {
+	checks := map[string]dependencyStatus{
+		"mongodb": checkDependency(c.Request.Context(), func(ctx context.Context) error {
+			return mongo.GetMongoClient().Ping(ctx, nil)
+		}),
+		"redis": checkDependency(c.Request.Context(), func(ctx context.Context) error {
+			return redis.RedisClient().Ping(ctx).Err()
+		}),
+		"indexes": checkIndexes(c.Request.Context()),
+		"ai":      checkAI(),
+	}
+
+	ready := true
+	for _, check := range checks {
+		if check.Required && check.Status != "ok" {
+			ready = false
+		}
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, global.APIResponse{
+			Success: false,
+			Message: "Service not ready",
+			Data:    gin.H{"status": "NOT_READY", "checks": checks},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(gin.H{"status": "READY", "checks": checks}))
+}
+
+// checkDependency runs a required check under healthCheckTimeout and times it
+func checkDependency(parent context.Context, check func(ctx context.Context) error) dependencyStatus {
+	ctx, cancel := context.WithTimeout(parent, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	status := dependencyStatus{Status: "ok", Required: true, LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		status.Status = "down"
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// checkIndexes reports the required indexes that have not been created
+func checkIndexes(parent context.Context) dependencyStatus {
+	var missing []string
+	status := checkDependency(parent, func(ctx context.Context) error {
+		var err error
+		missing, err = mongo.MissingIndexes(ctx)
+		return err
+	})
+	if status.Status == "ok" && len(missing) > 0 {
+		status.Status = "down"
+		status.Missing = missing
+	}
+	return status
+}
+
+// checkAI reports whether AI is configured and its circuit breaker is closed. The
+// provider is not called, since probes run often and AI calls are billed.
+func checkAI() dependencyStatus {
+	status := dependencyStatus{Status: "ok", Provider: ai.ProviderName()}
+	switch {
+	case !ai.IsEnabled():
+		status.Status = "disabled"
+	case ai.IsCircuitOpen():
+		status.Status = "degraded"
+		status.Error = ai.ErrAIUnavailable.Error()
+	}
+	return status
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// wantsAsyncImport reports whether a bulk create asked to run in the background
+func wantsAsyncImport(c *gin.Context) bool {
+	return c.Query("async") == "true"
+}
+
+// queueImport queues a validated bulk create for the import worker and answers 202
+// with a Location to poll. Only admins can queue imports, since only they can read
+// the job back.
+func queueImport[T any](c *gin.Context, kind string, rows []T) {
+	if !isAdminRequest(c) {
+		respondWithError(c, "Admin access required", global.ValidationError{Field: "async", Message: "Only admins can run imports in the background", Code: errorcodes.Forbidden})
+		return
+	}
+
+	docs := make([]interface{}, len(rows))
+	for i, row := range rows {
+		docs[i] = row
+	}
+	var createdBy *bson.ObjectID
+	if admin := requestAdmin(c); admin != nil {
+		createdBy = &admin.ID
+	}
+
+	job, err := mongo.CreateImportJob(c.Request.Context(), kind, docs, createdBy)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error queueing import", "kind", kind, "rows", len(rows), "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to queue import", nil))
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "Import queued", "job_id", job.ID.Hex(), "kind", kind, "rows", job.Total)
+	c.Header("Location", fmt.Sprintf("/api/admin/jobs/%s", job.ID.Hex()))
+	c.JSON(http.StatusAccepted, global.SuccessResponse(job))
+}
+
+// GetImportJob reports a background import's progress, rejected rows and final counts
+func GetImportJob(c *gin.Context) {
+	jobID, err := bson.ObjectIDFromHex(c.Param("jobId"))
+	if err != nil {
+		respondWithError(c, "Invalid job ID format", global.ValidationError{Field: "jobId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	job, err := mongo.GetImportJob(c.Request.Context(), jobID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Import job not found", global.ValidationError{Field: "jobId", Message: "No import job exists with this ID, or it finished more than 7 days ago", Code: errorcodes.NotFound})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error getting import job", "job_id", jobID.Hex(), "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to get import job", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(job))
+}
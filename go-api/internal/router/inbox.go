@@ -0,0 +1,91 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// GetCustomerNotifications lists a customer's in-app notifications, newest first, with
+// the unread count
+func GetCustomerNotifications(c *gin.Context) {
+	customer, ok := loadCustomer(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	notifications, total, unread, err := mongo.GetInboxNotifications(c.Request.Context(), customer.ID, c.Query("unread") == "true", page, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching inbox", "customer_id", customer.ID.Hex(), "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch notifications", nil))
+		return
+	}
+
+	respondWithList(c, "notifications", notifications, global.NewPagination(page, limit, int(total)), map[string]interface{}{
+		"unread": unread,
+	})
+}
+
+// MarkCustomerNotificationRead marks one of a customer's in-app notifications read
+func MarkCustomerNotificationRead(c *gin.Context) {
+	customerID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+	notificationID, err := bson.ObjectIDFromHex(c.Param("notificationId"))
+	if err != nil {
+		respondWithError(c, "Invalid notification ID format", global.ValidationError{Field: "notificationId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	notification, err := mongo.MarkInboxNotificationRead(c.Request.Context(), customerID, notificationID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Notification not found", global.ValidationError{Field: "notificationId", Message: "This customer has no notification with this ID", Code: errorcodes.NotFound})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error marking notification read", "customer_id", customerID.Hex(), "notification_id", notificationID.Hex(), "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to mark notification read", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(notification))
+}
+
+// MarkAllCustomerNotificationsRead marks every unread in-app notification of a customer read
+func MarkAllCustomerNotificationsRead(c *gin.Context) {
+	customer, ok := loadCustomer(c)
+	if !ok {
+		return
+	}
+
+	marked, err := mongo.MarkAllInboxNotificationsRead(c.Request.Context(), customer.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error marking notifications read", "customer_id", customer.ID.Hex(), "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to mark notifications read", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
+		"marked": marked,
+		"unread": 0,
+	}))
+}
@@ -0,0 +1,82 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// GetIndexes lists the indexes that exist on each collection with how often they are
+// used, and names the required indexes that are missing
+func GetIndexes(c *gin.Context) {
+	collection := c.Query("collection")
+
+	indexes, err := mongo.GetIndexes(c.Request.Context(), collection)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Collection not found", global.ValidationError{Field: "collection", Message: "No collection exists with this name", Code: errorcodes.NotFound})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error listing indexes", "collection", collection, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to list indexes", nil))
+		return
+	}
+
+	missing, err := mongo.MissingIndexes(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error checking required indexes", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to list indexes", nil))
+		return
+	}
+	if collection != "" {
+		inCollection := []string{}
+		for _, name := range missing {
+			if strings.HasPrefix(name, collection+".") {
+				inCollection = append(inCollection, name)
+			}
+		}
+		missing = inCollection
+	}
+	if missing == nil {
+		missing = []string{}
+	}
+
+	respondWithList(c, "indexes", indexes, global.SinglePage(len(indexes)), map[string]interface{}{
+		"missing": missing,
+	})
+}
+
+// EnsureIndexes creates any required index that is missing, as the API does at startup,
+// and reports what happened with each. It fails when an index couldn't be created, listing
+// those indexes.
+func EnsureIndexes(c *gin.Context) {
+	results, err := mongo.EnsureIndexes(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error ensuring indexes", "error", err)
+		var failed []global.ValidationError
+		for _, result := range results {
+			if result.Status == models.IndexFailed {
+				failed = append(failed, global.ValidationError{Field: result.Collection + "." + result.Name, Message: result.Error})
+			}
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to create indexes", failed))
+		return
+	}
+
+	summary := map[string]int{}
+	for _, result := range results {
+		summary[result.Status]++
+	}
+	slog.InfoContext(c.Request.Context(), "Indexes ensured", "created", summary[models.IndexCreated], "skipped", summary[models.IndexSkipped])
+
+	c.JSON(http.StatusOK, global.ListResponse(results, global.SinglePage(len(results)), map[string]interface{}{
+		"summary": summary,
+	}))
+}
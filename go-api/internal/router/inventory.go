@@ -0,0 +1,338 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// GetInventory lists product stock levels with pagination and category, status, warehouse and low-stock filters
+func GetInventory(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter := mongo.InventoryFilter{Category: c.Query("category")}
+
+	filter.Status = c.Query("status")
+	if filter.Status != "" && filter.Status != "active" && filter.Status != "inactive" {
+		respondWithError(c, "Invalid status parameter", global.ValidationError{Field: "status", Message: "status must be one of: active, inactive", Code: errorcodes.InvalidValue})
+		return
+	}
+
+	filter.Warehouse = c.Query("warehouse")
+	if filter.Warehouse != "" && !slices.Contains(models.Warehouses, filter.Warehouse) {
+		respondWithError(c, "Invalid warehouse parameter", global.ValidationError{Field: "warehouse", Message: "warehouse must be one of: warehouse_main, warehouse_east, warehouse_west", Code: errorcodes.InvalidValue})
+		return
+	}
+
+	if c.Query("low_stock") == "true" {
+		threshold, _ := strconv.Atoi(c.DefaultQuery("threshold", "10"))
+		if threshold < 1 {
+			respondWithError(c, "Invalid threshold parameter", global.ValidationError{Field: "threshold", Message: "threshold must be a positive number", Code: errorcodes.InvalidValue})
+			return
+		}
+		filter.LowStockThreshold = threshold
+	}
+
+	result, err := mongo.GetInventory(c.Request.Context(), filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch inventory", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(result.Items, result.Pagination, nil))
+}
+
+// GetInventoryBySKU returns stock levels and recent inventory changes for a product
+func GetInventoryBySKU(c *gin.Context) {
+	sku := c.Param("sku")
+
+	detail, err := mongo.GetInventoryBySKU(c.Request.Context(), sku)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch inventory", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(detail))
+}
+
+// inventoryChangeTypes are the change_type values accepted by the history filter
+var inventoryChangeTypes = map[string]bool{
+	"adjustment": true, "purchase": true, "sale": true, "return": true,
+	"damage": true, "lost": true, "recount": true, "transfer": true,
+}
+
+// GetInventoryHistory lists a product's inventory changes with pagination and filters
+func GetInventoryHistory(c *gin.Context) {
+	sku := c.Param("sku")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var filter mongo.InventoryHistoryFilter
+
+	if startDate := c.Query("startDate"); startDate != "" {
+		startTime, err := time.Parse("2006-01-02", startDate)
+		if err != nil {
+			respondWithError(c, "Invalid startDate parameter", global.ValidationError{Field: "startDate", Message: "startDate must be in YYYY-MM-DD format", Code: errorcodes.InvalidFormat})
+			return
+		}
+		filter.StartDate = &startTime
+	}
+
+	if endDate := c.Query("endDate"); endDate != "" {
+		endTime, err := time.Parse("2006-01-02", endDate)
+		if err != nil {
+			respondWithError(c, "Invalid endDate parameter", global.ValidationError{Field: "endDate", Message: "endDate must be in YYYY-MM-DD format", Code: errorcodes.InvalidFormat})
+			return
+		}
+		// Include the whole end day
+		endTime = endTime.Add(24 * time.Hour)
+		filter.EndDate = &endTime
+	}
+
+	if filter.StartDate != nil && filter.EndDate != nil && !filter.StartDate.Before(*filter.EndDate) {
+		respondWithError(c, "Invalid date range", global.ValidationError{Field: "endDate", Message: "endDate must not be before startDate", Code: errorcodes.InvalidRange})
+		return
+	}
+
+	filter.Warehouse = c.Query("warehouse")
+	if filter.Warehouse != "" && !slices.Contains(models.Warehouses, filter.Warehouse) {
+		respondWithError(c, "Invalid warehouse parameter", global.ValidationError{Field: "warehouse", Message: "warehouse must be one of: warehouse_main, warehouse_east, warehouse_west", Code: errorcodes.InvalidValue})
+		return
+	}
+
+	filter.ChangeType = c.Query("change_type")
+	if filter.ChangeType != "" && !inventoryChangeTypes[filter.ChangeType] {
+		respondWithError(c, "Invalid change_type parameter", global.ValidationError{Field: "change_type", Message: "change_type must be one of: adjustment, purchase, sale, return, damage, lost, recount, transfer", Code: errorcodes.InvalidValue})
+		return
+	}
+
+	result, err := mongo.GetInventoryHistory(c.Request.Context(), sku, filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch inventory history", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(result.Logs, result.Pagination, map[string]interface{}{
+		"sku": sku,
+	}))
+}
+
+// AdjustInventory applies a relative stock change to a single warehouse
+func AdjustInventory(c *gin.Context) {
+	var request models.AdjustInventoryRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	product, inventoryLog, err := mongo.AdjustInventory(ctx, &request)
+	if err != nil {
+		switch err.Error() {
+		case "product not found":
+			respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
+		case "insufficient stock in warehouse":
+			respondWithError(c, "Insufficient stock", global.ValidationError{Field: "quantity", Message: "warehouse does not hold enough stock for this removal", Code: errorcodes.InsufficientStock})
+		default:
+			slog.ErrorContext(c.Request.Context(), "Error adjusting inventory", "sku", request.SKU, "error", err)
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to adjust inventory", nil))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
+		"inventory": models.NewInventoryItem(product),
+		"log":       inventoryLog,
+	}))
+}
+
+// SetInventoryLevels sets absolute warehouse stock levels for a product
+func SetInventoryLevels(c *gin.Context) {
+	sku := c.Param("sku")
+
+	var request models.SetInventoryLevelsRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	product, logs, err := mongo.SetInventoryLevels(ctx, sku, &request)
+	if err != nil {
+		switch err.Error() {
+		case "product not found":
+			respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
+		case "no fields to update":
+			respondWithError(c, "No stock levels provided", global.ValidationError{Field: "body", Message: "Provide at least one of warehouse_main, warehouse_east, warehouse_west", Code: errorcodes.EmptyUpdates})
+		default:
+			slog.ErrorContext(c.Request.Context(), "Error setting inventory levels", "sku", sku, "error", err)
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to set inventory levels", nil))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
+		"inventory": models.NewInventoryItem(product),
+		"logs":      logs,
+	}))
+}
+
+// CreateInventoryRecount submits a physical count for one warehouse of a product
+func CreateInventoryRecount(c *gin.Context) {
+	sku := c.Param("sku")
+
+	var request models.CreateRecountRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	recount, err := mongo.CreateInventoryRecount(c.Request.Context(), sku, &request)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error recording recount", "sku", sku, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to record recount", nil))
+		return
+	}
+
+	c.JSON(http.StatusCreated, global.SuccessResponse(recount))
+}
+
+// GetInventoryRecounts lists recounts, optionally filtered by status
+func GetInventoryRecounts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	status := c.Query("status")
+	validStatuses := map[string]bool{"": true, "matched": true, "recorded": true, "pending": true, "applied": true, "rejected": true}
+	if !validStatuses[status] {
+		respondWithError(c, "Invalid status parameter", global.ValidationError{Field: "status", Message: "status must be one of: matched, recorded, pending, applied, rejected", Code: errorcodes.InvalidValue})
+		return
+	}
+
+	result, err := mongo.GetInventoryRecounts(c.Request.Context(), status, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch recounts", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(result.Recounts, result.Pagination, nil))
+}
+
+// ApproveInventoryRecount applies a pending recount correction to the product stock
+func ApproveInventoryRecount(c *gin.Context) {
+	recountID, request, ok := parseRecountReview(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	recount, product, err := mongo.ApproveInventoryRecount(ctx, recountID, request)
+	if err != nil {
+		if !respondRecountReviewError(c, err) {
+			switch err.Error() {
+			case "insufficient stock in warehouse":
+				respondWithError(c, "Correction would make stock negative", global.ValidationError{Field: "recountId", Message: "warehouse stock has changed since the count; recount and try again", Code: errorcodes.InsufficientStock})
+			case "product not found":
+				respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "The counted product no longer exists", Code: errorcodes.NotFound})
+			default:
+				slog.ErrorContext(c.Request.Context(), "Error approving recount", "recount_id", recountID.Hex(), "error", err)
+				c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to approve recount", nil))
+			}
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
+		"recount":   recount,
+		"inventory": models.NewInventoryItem(product),
+	}))
+}
+
+// RejectInventoryRecount closes a pending recount without changing stock
+func RejectInventoryRecount(c *gin.Context) {
+	recountID, request, ok := parseRecountReview(c)
+	if !ok {
+		return
+	}
+
+	recount, err := mongo.RejectInventoryRecount(c.Request.Context(), recountID, request)
+	if err != nil {
+		if !respondRecountReviewError(c, err) {
+			slog.ErrorContext(c.Request.Context(), "Error rejecting recount", "recount_id", recountID.Hex(), "error", err)
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to reject recount", nil))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(recount))
+}
+
+// parseRecountReview extracts the recount ID and reviewer details for approve/reject requests
+func parseRecountReview(c *gin.Context) (bson.ObjectID, *models.ReviewRecountRequest, bool) {
+	recountID, err := bson.ObjectIDFromHex(c.Param("recountId"))
+	if err != nil {
+		respondWithError(c, "Invalid recount ID format", global.ValidationError{Field: "recountId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return bson.ObjectID{}, nil, false
+	}
+
+	var request models.ReviewRecountRequest
+	if !bindJSON(c, &request) {
+		return bson.ObjectID{}, nil, false
+	}
+
+	return recountID, &request, true
+}
+
+// respondRecountReviewError writes the response for errors shared by approve and reject
+func respondRecountReviewError(c *gin.Context, err error) bool {
+	switch err.Error() {
+	case "recount not found":
+		respondWithError(c, "Recount not found", global.ValidationError{Field: "recountId", Message: "No recount exists with this ID", Code: errorcodes.NotFound})
+	case "recount is not pending approval":
+		respondWithError(c, "Recount is not pending approval", global.ValidationError{Field: "recountId", Message: "Only pending recounts can be approved or rejected", Code: errorcodes.InvalidStatus})
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package router
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// RedeemLoyaltyPoints applies some of a customer's points as a discount on one of their
+// pending orders
+func RedeemLoyaltyPoints(c *gin.Context) {
+	customer, ok := loadCustomer(c)
+	if !ok {
+		return
+	}
+
+	var req models.RedeemLoyaltyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	order, transaction, err := mongo.RedeemLoyaltyPoints(c.Request.Context(), customer.ID, req.OrderNumber, req.Points)
+	if err != nil {
+		switch err.Error() {
+		case "order not found":
+			respondWithError(c, "Order not found", global.ValidationError{Field: "order_number", Message: "The customer has no order with this number", Code: errorcodes.NotFound})
+		case "order is not pending":
+			respondWithError(c, "Order is not pending", global.ValidationError{Field: "order_number", Message: "Points can only be redeemed on pending orders", Code: errorcodes.InvalidStatus})
+		case "loyalty points already redeemed on this order":
+			respondWithError(c, "Loyalty points already redeemed", global.ValidationError{Field: "order_number", Message: "Points have already been redeemed on this order", Code: errorcodes.InvalidOperation})
+		case "points exceed the amount due":
+			respondWithError(c, "Too many points", global.ValidationError{Field: "points", Message: "The points are worth more than the order's amount due", Code: errorcodes.OutOfRange})
+		case "insufficient loyalty points":
+			respondWithError(c, "Insufficient loyalty points", global.ValidationError{Field: "points", Message: "The customer's balance does not cover these points", Code: errorcodes.InvalidValue})
+		default:
+			slog.ErrorContext(c.Request.Context(), "Error redeeming loyalty points", "customer_id", customer.ID.Hex(), "order_number", req.OrderNumber, "error", err)
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to redeem loyalty points", nil))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(models.LoyaltyRedemption{Order: order, Transaction: transaction}))
+}
+
+// GetLoyaltyTransactions lists a customer's loyalty points ledger, newest first, with
+// their current balance
+func GetLoyaltyTransactions(c *gin.Context) {
+	customer, ok := loadCustomer(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	transactions, total, err := mongo.GetLoyaltyTransactions(c.Request.Context(), customer.ID, page, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching loyalty transactions", "customer_id", customer.ID.Hex(), "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch loyalty transactions", nil))
+		return
+	}
+
+	tier := customer.CalculateLoyaltyTier()
+	respondWithList(c, "loyalty-transactions", transactions, global.NewPagination(page, limit, int(total)), map[string]interface{}{
+		"points":       customer.LoyaltyPoints,
+		"points_value": models.LoyaltyPointsValue(customer.LoyaltyPoints),
+		"tier":         tier,
+		"benefits":     models.LoyaltyTierBenefitsFor(tier),
+	})
+}
+
+// GetLoyaltyTierRules returns the benefits of each loyalty tier
+func GetLoyaltyTierRules(c *gin.Context) {
+	rules, err := mongo.GetLoyaltyTierRules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch loyalty tier rules", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(rules))
+}
+
+// UpdateLoyaltyTierRules replaces the benefits of every loyalty tier
+func UpdateLoyaltyTierRules(c *gin.Context) {
+	var req models.UpdateLoyaltyTierRulesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	seen := map[string]bool{}
+	for i, tier := range req.Tiers {
+		if seen[tier.Tier] {
+			respondWithError(c, "Duplicate loyalty tier", global.ValidationError{Field: fmt.Sprintf("tiers[%d].tier", i), Message: tier.Tier + " is listed more than once", Code: errorcodes.InvalidValue})
+			return
+		}
+		seen[tier.Tier] = true
+	}
+
+	rules, err := mongo.UpdateLoyaltyTierRules(c.Request.Context(), req.Tiers)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error updating loyalty tier rules", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to update loyalty tier rules", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(rules))
+}
@@ -0,0 +1,317 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// Single-resource edits use JSON Merge Patch (RFC 7386): nested objects merge into the
+// stored document instead of replacing it, and null removes a field. Each resource lists
+// the paths a patch may reach, so a typo or a server-owned field is reported instead of
+// being written.
+
+// patchRule describes one patchable path. A path ending in ".*" matches any key of a
+// map field, such as a product attribute.
+type patchRule struct {
+	Removable bool // null may remove the field
+}
+
+type patchRules struct {
+	Paths map[string]patchRule
+	// ReadOnly paths are dropped with a warning so a fetched document can be sent back
+	ReadOnly []string
+}
+
+var productPatchRules = patchRules{
+	Paths: map[string]patchRule{
+		"name":                 {},
+		"description":          {Removable: true},
+		"seo_title":            {Removable: true},
+		"category":             {},
+		"subcategory":          {Removable: true},
+		"brand":                {},
+		"price":                {},
+		"currency":             {},
+		"weight_kg":            {Removable: true},
+		"stock.warehouse_main": {},
+		"stock.warehouse_east": {},
+		"stock.warehouse_west": {},
+		"attributes":           {Removable: true},
+		"attributes.*":         {Removable: true},
+		"images":               {Removable: true},
+		"tags":                 {Removable: true},
+		"status":               {},
+	},
+	ReadOnly: []string{"_id", "id", "sku", "created_at", "updated_at", "ratings", "stock.total"},
+}
+
+var orderPatchRules = patchRules{
+	Paths: map[string]patchRule{
+		"status":                       {},
+		"notes":                        {Removable: true},
+		"shipping_address.street":      {},
+		"shipping_address.city":        {},
+		"shipping_address.province":    {},
+		"shipping_address.postal_code": {},
+		"shipping_address.country":     {},
+		"billing_address":              {Removable: true},
+		"billing_address.street":       {},
+		"billing_address.city":         {},
+		"billing_address.province":     {},
+		"billing_address.postal_code":  {},
+		"billing_address.country":      {},
+		"tracking":                     {Removable: true},
+		"tracking.carrier":             {},
+		"tracking.number":              {},
+		"tracking.url":                 {Removable: true},
+	},
+	ReadOnly: []string{"_id", "id", "order_number", "created_at", "updated_at", "customer_id", "customer_email", "payment"},
+}
+
+func (rules patchRules) rule(path string) (patchRule, bool) {
+	if rule, ok := rules.Paths[path]; ok {
+		return rule, true
+	}
+	if parent, _, found := cutLast(path); found {
+		rule, ok := rules.Paths[parent+".*"]
+		return rule, ok
+	}
+	return patchRule{}, false
+}
+
+// hasChildren reports whether any patchable path sits below path
+func (rules patchRules) hasChildren(path string) bool {
+	for candidate := range rules.Paths {
+		if strings.HasPrefix(candidate, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (rules patchRules) isReadOnly(path string) bool {
+	for _, readOnly := range rules.ReadOnly {
+		if readOnly == path {
+			return true
+		}
+	}
+	return false
+}
+
+// bindMergePatch decodes a merge patch body, keeping integers as integers so stock
+// levels aren't stored as doubles, and rejects operator keys at any depth. On failure it
+// writes a 400 and returns false.
+func bindMergePatch(c *gin.Context) (map[string]interface{}, bool) {
+	var patch map[string]interface{}
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&patch); err != nil {
+		respondWithError(c, "Invalid JSON format", global.ValidationError{Field: "body", Message: err.Error(), Code: errorcodes.JSONParseError})
+		return nil, false
+	}
+	if len(patch) == 0 {
+		respondWithError(c, "No updates provided", global.ValidationError{Field: "body", Message: "Request body must contain at least one field to update", Code: errorcodes.EmptyUpdates})
+		return nil, false
+	}
+	if errs := operatorKeyErrors(patch, ""); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Invalid request data", errs))
+		return nil, false
+	}
+	return normalizeJSONNumbers(patch).(map[string]interface{}), true
+}
+
+func normalizeJSONNumbers(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case json.Number:
+		if i, err := typed.Int64(); err == nil {
+			return i
+		}
+		f, _ := typed.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range typed {
+			typed[key] = normalizeJSONNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = normalizeJSONNumbers(item)
+		}
+	}
+	return value
+}
+
+// flattenMergePatch turns a merge patch into dotted field paths for the update. Nested
+// objects recurse into their patchable children and null becomes a nil value, which
+// the mongo layer unsets.
+func flattenMergePatch(c *gin.Context, patch map[string]interface{}, rules patchRules) (map[string]interface{}, []global.ValidationError) {
+	updates := map[string]interface{}{}
+	var errs []global.ValidationError
+	flattenInto(c, "", patch, rules, updates, &errs)
+	return updates, errs
+}
+
+func flattenInto(c *gin.Context, prefix string, patch map[string]interface{}, rules patchRules, updates map[string]interface{}, errs *[]global.ValidationError) {
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := patch[key]
+		path := prefix + key
+
+		if strings.Contains(key, ".") || mongo.IsOperatorKey(key) {
+			*errs = append(*errs, global.ValidationError{Field: path, Message: "field names must be non-empty and may not contain '.' or start with '$'", Code: errorcodes.InvalidFormat})
+			continue
+		}
+		if rules.isReadOnly(path) {
+			slog.WarnContext(c.Request.Context(), "Removed read-only field from merge patch", "field", path)
+			continue
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok && rules.hasChildren(path) {
+			flattenInto(c, path+".", nested, rules, updates, errs)
+			continue
+		}
+
+		rule, ok := rules.rule(path)
+		if !ok {
+			*errs = append(*errs, global.ValidationError{Field: path, Message: "field does not exist or cannot be patched", Code: errorcodes.NotPatchable})
+			continue
+		}
+		if value == nil && !rule.Removable {
+			*errs = append(*errs, global.ValidationError{Field: path, Message: "is required and cannot be removed", Code: errorcodes.Required})
+			continue
+		}
+		updates[path] = value
+	}
+}
+
+// validateMergePatch applies updates to the current document and checks the result
+// against target's validate tags. Only errors under a top-level field the patch touched
+// are reported, so a stored document that already breaks a rule elsewhere can still be
+// edited.
+func validateMergePatch(current interface{}, updates map[string]interface{}, target interface{}) []global.ValidationError {
+	body, err := json.Marshal(current)
+	if err != nil {
+		return nil
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil
+	}
+
+	touched := map[string]bool{}
+	for path, value := range updates {
+		touched[strings.SplitN(path, ".", 2)[0]] = true
+		applyPath(document, path, value)
+	}
+
+	body, err = json.Marshal(document)
+	if err != nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		return bindingErrors(err)
+	}
+
+	var errs []global.ValidationError
+	for _, validationErr := range validateRequest(target) {
+		top := strings.SplitN(validationErr.Field, ".", 2)[0]
+		top, _, _ = strings.Cut(top, "[")
+		if touched[top] {
+			errs = append(errs, validationErr)
+		}
+	}
+	return errs
+}
+
+// applyPath sets or, for nil, removes a dotted path in a decoded JSON document
+func applyPath(document map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := document[part].(map[string]interface{})
+		if !ok {
+			if value == nil {
+				return
+			}
+			child = map[string]interface{}{}
+			document[part] = child
+		}
+		document = child
+	}
+	last := parts[len(parts)-1]
+	if value == nil {
+		delete(document, last)
+		return
+	}
+	document[last] = value
+}
+
+// operatorKeyErrors reports every key in a decoded update body, at any depth, that
+// MongoDB would read as an operator, such as $set or stock.$[]. Bulk edits check their
+// bodies with it before anything reaches the mongo layer.
+func operatorKeyErrors(value interface{}, path string) []global.ValidationError {
+	var errs []global.ValidationError
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field := key
+			if path != "" {
+				field = path + "." + key
+			}
+			if mongo.IsOperatorKey(key) {
+				errs = append(errs, global.ValidationError{Field: field, Message: "field names may not start with '$', use positional operators or be empty", Code: errorcodes.InvalidFormat})
+				continue
+			}
+			errs = append(errs, operatorKeyErrors(typed[key], field)...)
+		}
+	case []interface{}:
+		for i, item := range typed {
+			errs = append(errs, operatorKeyErrors(item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return errs
+}
+
+// updateError turns the mongo layer's rejection of an update path or value into a field
+// error, with prefix (such as "[2].") in front of the field for bulk edits
+func updateError(err error, prefix string) (global.ValidationError, bool) {
+	var invalid *mongo.InvalidUpdateError
+	if !errors.As(err, &invalid) {
+		return global.ValidationError{}, false
+	}
+	code := errorcodes.InvalidType
+	switch {
+	case invalid.Operator:
+		code = errorcodes.InvalidFormat
+	case invalid.Unknown:
+		code = errorcodes.NotPatchable
+	}
+	return global.ValidationError{Field: prefix + invalid.Field, Message: invalid.Message, Code: code}, true
+}
+
+// cutLast splits path at its final dot
+func cutLast(path string) (string, string, bool) {
+	i := strings.LastIndex(path, ".")
+	if i < 0 {
+		return path, "", false
+	}
+	return path[:i], path[i+1:], true
+}
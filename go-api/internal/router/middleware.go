@@ -1,28 +1,46 @@
 package router
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorreport"
 	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/logging"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
 )
 
 func ReviewsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		entityType := c.Request.URL.Query().Get("item")
+
+		// Listing all reviews does not target a single entity
+		if entityType == "" && c.Request.Method == http.MethodGet {
+			c.Next()
+			return
+		}
+
 		if entityType == "" {
-			c.JSON(http.StatusBadRequest, global.ErrorResponse("item query parameter required", []global.ValidationError{
-				{Field: "item", Message: "item query parameter is required", Code: "required"},
-			}))
+			respondWithError(c, "item query parameter required", global.ValidationError{Field: "item", Message: "item query parameter is required", Code: errorcodes.Required})
 			c.Abort()
 			return
 		}
 
 		entityId := c.Request.URL.Query().Get("id")
 		if entityId == "" {
-			c.JSON(http.StatusBadRequest, global.ErrorResponse("id query parameter required", []global.ValidationError{
-				{Field: "id", Message: "id query parameter is required", Code: "required"},
-			}))
+			respondWithError(c, "id query parameter required", global.ValidationError{Field: "id", Message: "id query parameter is required", Code: errorcodes.Required})
 			c.Abort()
 			return
 		}
@@ -32,3 +50,209 @@ func ReviewsMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// ProductReviewsMiddleware resolves the :sku route parameter to a product ID so the
+// nested /products/:sku/reviews routes can share the ReviewsMiddleware handlers
+func ProductReviewsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sku := c.Param("sku")
+
+		ctx, cancel := global.GetRequestTimer(c.Request.Context())
+		defer cancel()
+
+		product, err := mongo.GetProductBySKU(ctx, sku)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNotFound) {
+				respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
+			} else {
+				c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve product: "+err.Error(), nil))
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set("entity", "product")
+		c.Set("id", product.ID.Hex())
+		c.Next()
+	}
+}
+
+// isAdminRequest checks the X-Admin-Key header against the configured admin key and the
+// personal keys of active admins
+func isAdminRequest(c *gin.Context) bool {
+	providedKey := c.GetHeader("X-Admin-Key")
+
+	if adminAPIKey != "" && subtle.ConstantTimeCompare([]byte(providedKey), []byte(adminAPIKey)) == 1 {
+		return true
+	}
+	return requestAdmin(c) != nil
+}
+
+// requestAdmin returns the active admin whose personal key the request carries, or nil.
+// The lookup runs once per request however many times it is asked.
+func requestAdmin(c *gin.Context) *models.Admin {
+	if cached, ok := c.Get("admin"); ok {
+		return cached.(*models.Admin)
+	}
+
+	var admin *models.Admin
+	if key := c.GetHeader("X-Admin-Key"); strings.HasPrefix(key, models.AdminKeyPrefix) {
+		found, err := mongo.AuthenticateAdmin(c.Request.Context(), key)
+		if err == nil {
+			admin = found
+		} else if !errors.Is(err, mongo.ErrNotFound) {
+			slog.ErrorContext(c.Request.Context(), "Error checking admin key", "error", err)
+		}
+	}
+	c.Set("admin", admin)
+	return admin
+}
+
+// AdminMiddleware restricts a route to callers presenting the ADMIN_API_KEY or an active
+// admin's personal key in the X-Admin-Key header
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isAdminRequest(c) {
+			respondWithError(c, "Admin access required", global.ValidationError{Field: "X-Admin-Key", Message: "a valid admin key is required", Code: errorcodes.Forbidden})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequestLoggerMiddleware assigns each request an ID, reusing a valid X-Request-ID header
+// from the caller, attaches it to the request context and the X-Request-ID response
+// header, and logs the method, path, status and latency once the request completes
+func RequestLoggerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader("X-Request-ID")
+		if !isValidRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Set("request_id", requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		c.Header("X-Request-ID", requestID)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+
+		slog.Log(c.Request.Context(), level, "request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"latency_ms", time.Since(start).Milliseconds(),
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// LanguageMiddleware picks the language listings and searches sort by: the lang query
+// parameter, else the first supported language in Accept-Language, else English.
+// Clients pass a customer's preferences.language to sort by their rules.
+func LanguageMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Language")
+
+		language := strings.ToLower(c.Query("lang"))
+		if !mongo.IsSupportedLanguage(language) {
+			language = acceptedLanguage(c.GetHeader("Accept-Language"))
+		}
+		c.Request = c.Request.WithContext(mongo.WithLanguage(c.Request.Context(), language))
+		c.Next()
+	}
+}
+
+// acceptedLanguage returns the first supported language an Accept-Language header lists,
+// such as "fr" for "fr-CA,fr;q=0.9,en;q=0.8"
+func acceptedLanguage(header string) string {
+	for _, tag := range strings.Split(header, ",") {
+		tag, _, _ = strings.Cut(strings.TrimSpace(tag), ";")
+		primary, _, _ := strings.Cut(tag, "-")
+		if language := strings.ToLower(primary); mongo.IsSupportedLanguage(language) {
+			return language
+		}
+	}
+	return mongo.DefaultLanguage
+}
+
+// RecoveryMiddleware turns a panic in a handler into a 500 with the standard error
+// envelope, logging the panic and stack with the request ID and reporting it to Sentry
+// when configured. Panics caused by the client hanging up are logged without a response.
+func RecoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			ctx := c.Request.Context()
+			if isBrokenConnection(recovered) {
+				slog.WarnContext(ctx, "Client connection closed", "route", c.FullPath(), "error", recovered)
+				c.Abort()
+				return
+			}
+
+			slog.ErrorContext(ctx, "Panic recovered",
+				"method", c.Request.Method,
+				"route", c.FullPath(),
+				"panic", fmt.Sprint(recovered),
+				"stack", string(debug.Stack()),
+			)
+			errorreport.ReportPanic(ctx, recovered, c.Request, c.FullPath())
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, global.ErrorResponse("Internal server error", nil))
+		}()
+
+		c.Next()
+	}
+}
+
+// isBrokenConnection reports whether a panic came from writing to a client that disconnected
+func isBrokenConnection(recovered any) bool {
+	err, ok := recovered.(error)
+	if !ok {
+		return false
+	}
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// isValidRequestID accepts caller-supplied IDs of up to 128 printable ASCII characters
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 128 {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random 32-character hex request ID
+func newRequestID() string {
+	randomBytes := make([]byte, 16)
+	rand.Read(randomBytes)
+	return hex.EncodeToString(randomBytes)
+}
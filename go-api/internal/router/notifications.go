@@ -0,0 +1,82 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// GetNotifications lists queued, sent, skipped and dead-lettered notifications, newest first
+func GetNotifications(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter := mongo.NotificationFilter{
+		Status:    c.Query("status"),
+		Channel:   c.Query("channel"),
+		Recipient: c.Query("recipient"),
+	}
+
+	switch filter.Status {
+	case "", models.NotificationPending, models.NotificationSent, models.NotificationDead, models.NotificationSkipped:
+	default:
+		respondWithError(c, "Invalid status parameter", global.ValidationError{Field: "status", Message: "status must be one of: pending, sent, dead, skipped", Code: errorcodes.InvalidValue})
+		return
+	}
+	switch filter.Channel {
+	case "", models.NotificationEmail, models.NotificationSMS, models.NotificationWebhook:
+	default:
+		respondWithError(c, "Invalid channel parameter", global.ValidationError{Field: "channel", Message: "channel must be one of: email, sms, webhook", Code: errorcodes.InvalidValue})
+		return
+	}
+
+	notifications, total, counts, err := mongo.GetNotifications(c.Request.Context(), filter, page, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching notifications", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch notifications", nil))
+		return
+	}
+
+	respondWithList(c, "notifications", notifications, global.NewPagination(page, limit, int(total)), map[string]interface{}{
+		"counts": counts,
+	})
+}
+
+// RetryNotification moves a dead-lettered notification back into the delivery queue
+func RetryNotification(c *gin.Context) {
+	notificationID, err := bson.ObjectIDFromHex(c.Param("notificationId"))
+	if err != nil {
+		respondWithError(c, "Invalid notification ID format", global.ValidationError{Field: "notificationId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	notification, err := mongo.RequeueNotification(c.Request.Context(), notificationID)
+	if err != nil {
+		switch err.Error() {
+		case "notification not found":
+			respondWithError(c, "Notification not found", global.ValidationError{Field: "notificationId", Message: "No notification exists with this ID", Code: errorcodes.NotFound})
+		case "notification is not dead-lettered":
+			respondWithError(c, "Notification is not dead-lettered", global.ValidationError{Field: "notificationId", Message: "Only dead-lettered notifications can be retried", Code: errorcodes.InvalidStatus})
+		default:
+			slog.ErrorContext(c.Request.Context(), "Error requeueing notification", "notification_id", notificationID.Hex(), "error", err)
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retry notification", nil))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(notification))
+}
@@ -0,0 +1,600 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/ai"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/graphql"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+	"julianmorley.ca/con-plar/prog2270/pkg/scheduler"
+)
+
+// The OpenAPI document is generated from the registered Gin routes, the routeDocs
+// table and the Go request/response structs, so it is rebuilt with every build and
+// cannot drift from the handlers. Routes missing from routeDocs are still listed.
+var (
+	openAPIOnce sync.Once
+	openAPISpec map[string]interface{}
+)
+
+// ServeOpenAPISpec returns the generated OpenAPI 3 document as JSON
+func ServeOpenAPISpec(c *gin.Context) {
+	openAPIOnce.Do(func() {
+		openAPISpec = buildOpenAPISpec(Router.Routes())
+	})
+	c.JSON(http.StatusOK, openAPISpec)
+}
+
+// ServeSwaggerUI renders Swagger UI for the generated spec
+func ServeSwaggerUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>PLAR API Docs</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/api/docs/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>`
+
+// warnUndocumentedRoutes logs every registered API route that has no routeDocs entry
+func warnUndocumentedRoutes() {
+	for _, route := range Router.Routes() {
+		if _, ok := routeDocs[route.Method+" "+route.Path]; !ok && strings.HasPrefix(route.Path, "/api/") && !strings.HasPrefix(route.Path, "/api/docs") {
+			slog.Warn("Route has no OpenAPI documentation", "method", route.Method, "path", route.Path)
+		}
+	}
+}
+
+// routeDoc describes one route in the generated spec
+type routeDoc struct {
+	Tag      string
+	Summary  string
+	Admin    bool              // requires the X-Admin-Key header
+	Query    map[string]string // query parameter name -> description
+	Request  interface{}       // value of the JSON body type, nil when there is no body
+	Response interface{}       // value of the type in the success envelope's data, nil for a free-form object
+	Status   int               // success status, default 200
+	Stream   bool              // responds with text/event-stream
+	List     bool              // responds with the list envelope; Response is the item slice
+	Export   bool              // list can also be negotiated as CSV or NDJSON
+	Bare     bool              // Response is the whole body rather than the envelope's data
+	Download []string          // responds with a file attachment of these content types
+}
+
+// routeDocs documents each route, keyed by "METHOD /gin/path"
+var routeDocs = map[string]routeDoc{
+	"GET /api/health":                              {Tag: "Health", Summary: "Check the MongoDB connection"},
+	"GET /api/health/live":                         {Tag: "Health", Summary: "Liveness probe"},
+	"GET /api/health/ready":                        {Tag: "Health", Summary: "Readiness probe with per-dependency status and latency"},
+	"GET /api/errors":                              {Tag: "Errors", Summary: "List every error code with its HTTP status", Response: []errorcodes.Entry{}, List: true},
+	"GET /api/exports/:exportId/download":          {Tag: "Customers", Summary: "Download a customer export with its signed link", Query: map[string]string{"expires": "Link expiry (Unix seconds)", "signature": "Link signature"}, Download: []string{"application/json", "application/zip"}},
+	"GET /api/report-archives/:archiveId/download": {Tag: "Admin", Summary: "Download a report archive with its signed link", Query: map[string]string{"expires": "Link expiry (Unix seconds)", "signature": "Link signature"}, Download: []string{"application/zip"}},
+	"GET /api/graphql":                             {Tag: "GraphQL", Summary: "Run a GraphQL query given as query, operationName and variables parameters", Query: map[string]string{"query": "GraphQL query document", "operationName": "Operation to run when the document has several", "variables": "JSON object of variable values"}, Response: graphql.Response{}, Bare: true},
+	"POST /api/graphql":                            {Tag: "GraphQL", Summary: "Run a GraphQL query over products, orders, customers and reviews", Request: graphql.Request{}, Response: graphql.Response{}, Bare: true},
+	"GET /api/search":                              {Tag: "Search", Summary: "Search products, customers, orders and reviews", Query: map[string]string{"q": "Search text", "page": "Page of each type's results", "limit": "Results per type per page", "types": "Types to search, comma-separated: products, customers, orders, reviews (all but products need X-Admin-Key)", "category": "Product categories, comma-separated", "brand": "Product brands, comma-separated", "price_min": "Lowest product price", "price_max": "Highest product price", "in_stock": "true for products in stock, false for sold out", "attr.{name}": "Product attribute values, comma-separated", "fuzzy": "Typos tolerated per term, 0-2 (default SEARCH_FUZZINESS)"}, Response: []mongo.SearchResult{}, List: true},
+	"GET /api/search/suggest":                      {Tag: "Search", Summary: "Autocomplete product names, brands and categories", Query: map[string]string{"q": "Prefix to complete", "limit": "Maximum completions (up to 10)"}, Response: []redis.Suggestion{}, List: true},
+
+	"GET /api/products/":                         {Tag: "Products", Summary: "List products", Response: []models.Product{}, List: true, Export: true, Query: cursorQuery},
+	"POST /api/products/":                        {Tag: "Products", Summary: "Create products", Query: map[string]string{"async": "true to queue an import job and answer 202 (admin only)"}, Request: []models.CreateProductRequest{}, Status: http.StatusCreated},
+	"PUT /api/products/":                         {Tag: "Products", Summary: "Bulk edit products", Request: []map[string]interface{}{}},
+	"DELETE /api/products/":                      {Tag: "Products", Summary: "Bulk delete products", Request: []BulkDeleteRequest{}},
+	"GET /api/products/:sku":                     {Tag: "Products", Summary: "Get a product by SKU; 304 when If-None-Match matches the ETag", Response: models.Product{}},
+	"PUT /api/products/:sku":                     {Tag: "Products", Summary: "Edit a product with a JSON merge patch; 412 when If-Match no longer matches", Request: map[string]interface{}{}, Response: models.Product{}},
+	"PATCH /api/products/:sku":                   {Tag: "Products", Summary: "Edit a product with a JSON merge patch; 412 when If-Match no longer matches", Request: map[string]interface{}{}, Response: models.Product{}},
+	"DELETE /api/products/:sku":                  {Tag: "Products", Summary: "Delete a product"},
+	"GET /api/products/:sku/similar":             {Tag: "Products", Summary: "Find similar products by embedding", Query: map[string]string{"limit": "Number of products (default 5)"}, Response: []models.SimilarProduct{}, List: true},
+	"POST /api/products/:sku/notify-me":          {Tag: "Products", Summary: "Get one email when an out-of-stock product is back in stock", Request: models.NotifyMeRequest{}, Response: models.StockSubscription{}, Status: http.StatusCreated},
+	"POST /api/products/:sku/ai/describe":        {Tag: "Products", Summary: "Draft an AI description, SEO title and tags", Admin: true, Response: models.ProductDescriptionDraft{}},
+	"POST /api/products/:sku/ai/describe/accept": {Tag: "Products", Summary: "Apply the AI draft to the product", Admin: true, Request: models.AcceptProductDescriptionRequest{}, Response: models.Product{}},
+
+	"GET /api/products/:sku/reviews/":             {Tag: "Reviews", Summary: "List reviews for a product", Response: []models.Review{}, List: true},
+	"POST /api/products/:sku/reviews/":            {Tag: "Reviews", Summary: "Review a product", Request: models.CreateReviewRequest{}, Response: models.Review{}, Status: http.StatusCreated},
+	"PUT /api/products/:sku/reviews/:reviewId":    {Tag: "Reviews", Summary: "Update a product review", Request: models.UpdateReviewRequest{}, Response: models.Review{}},
+	"DELETE /api/products/:sku/reviews/:reviewId": {Tag: "Reviews", Summary: "Delete a product review"},
+	"GET /api/reviews/":                           {Tag: "Reviews", Summary: "List reviews", Query: map[string]string{"page": "Page number", "limit": "Page size (max 100)", "rating": "1-5", "verified": "true to only show verified purchases", "sort": "date or helpful", "product_id": "Product ID", "customer_id": "Customer ID", "status": "Moderation status", "item": "Legacy: product, customer or order", "id": "Legacy: ID of the item"}, Response: []models.Review{}, List: true},
+	"POST /api/reviews/":                          {Tag: "Reviews", Summary: "Review an item", Query: map[string]string{"item": "Entity type being reviewed"}, Request: models.CreateReviewRequest{}, Response: models.Review{}, Status: http.StatusCreated},
+	"PUT /api/reviews/":                           {Tag: "Reviews", Summary: "Update a review", Query: map[string]string{"item": "Entity type being reviewed"}, Request: models.UpdateReviewRequest{}, Response: models.Review{}},
+	"DELETE /api/reviews/":                        {Tag: "Reviews", Summary: "Delete a review", Query: map[string]string{"item": "Entity type being reviewed"}},
+	"POST /api/reviews/:reviewId/helpful":         {Tag: "Reviews", Summary: "Mark a review helpful", Request: models.ReviewHelpfulVoteRequest{}, Response: models.Review{}},
+	"DELETE /api/reviews/:reviewId/helpful":       {Tag: "Reviews", Summary: "Remove a helpful vote", Request: models.ReviewHelpfulVoteRequest{}, Response: models.Review{}},
+	"POST /api/reviews/:reviewId/reply":           {Tag: "Reviews", Summary: "Reply to a review", Admin: true, Request: models.ReviewReplyRequest{}, Response: models.Review{}},
+	"GET /api/categories/":                        {Tag: "Products", Summary: "List categories", Response: []string{}, List: true},
+
+	"GET /api/orders/":                              {Tag: "Orders", Summary: "List orders", Response: []models.Order{}, List: true, Export: true, Query: cursorQuery},
+	"POST /api/orders/":                             {Tag: "Orders", Summary: "Create orders", Query: map[string]string{"async": "true to queue an import job and answer 202 (admin only)"}, Request: []models.CreateOrderRequest{}, Status: http.StatusCreated},
+	"PUT /api/orders/":                              {Tag: "Orders", Summary: "Bulk edit orders", Request: []map[string]interface{}{}},
+	"DELETE /api/orders/":                           {Tag: "Orders", Summary: "Bulk delete orders", Request: []BulkDeleteOrderRequest{}},
+	"GET /api/orders/:orderNumber":                  {Tag: "Orders", Summary: "Get an order", Response: models.Order{}},
+	"PUT /api/orders/:orderNumber":                  {Tag: "Orders", Summary: "Edit an order with a JSON merge patch", Request: map[string]interface{}{}, Response: models.Order{}},
+	"PATCH /api/orders/:orderNumber":                {Tag: "Orders", Summary: "Edit an order with a JSON merge patch", Request: map[string]interface{}{}, Response: models.Order{}},
+	"DELETE /api/orders/:orderNumber":               {Tag: "Orders", Summary: "Delete an order"},
+	"GET /api/orders/:orderNumber/events":           {Tag: "Orders", Summary: "Follow an order's status and timeline changes as Server-Sent Events", Stream: true},
+	"POST /api/orders/:orderNumber/payment-intent":  {Tag: "Orders", Summary: "Start or resume the Stripe or PayPal payment for an order", Response: models.OrderPaymentIntent{}},
+	"POST /api/orders/:orderNumber/payment-capture": {Tag: "Orders", Summary: "Capture an approved PayPal payment and mark the order paid", Response: models.Order{}},
+	"POST /api/orders/:orderNumber/refunds":         {Tag: "Orders", Summary: "Refund an order in full or by line item through its payment provider", Admin: true, Request: models.RefundOrderRequest{}, Response: models.Order{}, Status: http.StatusCreated},
+	"GET /api/unsubscribe":                          {Tag: "Customers", Summary: "Unsubscribe a customer from a list with the signed link from a notification", Query: map[string]string{"customer": "Customer ID", "list": "email, sms or newsletter", "signature": "Link signature"}},
+	"POST /api/unsubscribe":                         {Tag: "Customers", Summary: "One-click unsubscribe from a mail client's List-Unsubscribe-Post", Query: map[string]string{"customer": "Customer ID", "list": "email, sms or newsletter", "signature": "Link signature"}},
+	"POST /api/webhooks/payments/:provider":         {Tag: "Payments", Summary: "Receive a Stripe or PayPal payment or refund event, applied once per event ID"},
+
+	"GET /api/customers/":                                        {Tag: "Customers", Summary: "List customers", Response: []models.Customer{}, List: true, Export: true, Query: customerListQuery},
+	"POST /api/customers/":                                       {Tag: "Customers", Summary: "Create a customer", Request: models.CreateCustomerRequest{}, Response: models.Customer{}, Status: http.StatusCreated},
+	"GET /api/customers/:id":                                     {Tag: "Customers", Summary: "Get a customer", Response: models.Customer{}},
+	"PUT /api/customers/:id":                                     {Tag: "Customers", Summary: "Update a customer", Request: models.UpdateCustomerRequest{}, Response: models.Customer{}},
+	"PATCH /api/customers/:id/preferences":                       {Tag: "Customers", Summary: "Update some of a customer's preferences", Request: models.UpdatePreferencesRequest{}, Response: models.Customer{}},
+	"DELETE /api/customers/:id":                                  {Tag: "Customers", Summary: "Delete a customer"},
+	"GET /api/customers/:id/orders":                              {Tag: "Customers", Summary: "Customer order history with stats", Query: map[string]string{"page": "Page number", "limit": "Page size (max 100)"}, Response: []models.Order{}, List: true, Export: true},
+	"POST /api/customers/:id/addresses":                          {Tag: "Customers", Summary: "Add an address", Request: models.Address{}, Response: models.Customer{}, Status: http.StatusCreated},
+	"PUT /api/customers/:id/addresses/:addressId":                {Tag: "Customers", Summary: "Update an address", Request: models.Address{}, Response: models.Customer{}},
+	"DELETE /api/customers/:id/addresses/:addressId":             {Tag: "Customers", Summary: "Delete an address", Response: models.Customer{}},
+	"GET /api/customers/:id/saved-searches":                      {Tag: "Customers", Summary: "List a customer's saved searches", Response: []models.SavedSearch{}, List: true, Export: true},
+	"POST /api/customers/:id/saved-searches":                     {Tag: "Customers", Summary: "Save a search, optionally notifying on new matching products", Request: models.CreateSavedSearchRequest{}, Response: models.SavedSearch{}, Status: http.StatusCreated},
+	"GET /api/customers/:id/saved-searches/:searchId":            {Tag: "Customers", Summary: "Get a saved search", Response: models.SavedSearch{}},
+	"PUT /api/customers/:id/saved-searches/:searchId":            {Tag: "Customers", Summary: "Update a saved search", Request: models.UpdateSavedSearchRequest{}, Response: models.SavedSearch{}},
+	"DELETE /api/customers/:id/saved-searches/:searchId":         {Tag: "Customers", Summary: "Delete a saved search"},
+	"GET /api/customers/:id/price-alerts":                        {Tag: "Customers", Summary: "List a customer's price alerts", Response: []models.PriceAlert{}, List: true, Export: true},
+	"POST /api/customers/:id/price-alerts":                       {Tag: "Customers", Summary: "Email the customer when a product's price goes down, optionally to a target price", Request: models.CreatePriceAlertRequest{}, Response: models.PriceAlert{}, Status: http.StatusCreated},
+	"DELETE /api/customers/:id/price-alerts/:alertId":            {Tag: "Customers", Summary: "Remove a price alert"},
+	"POST /api/customers/:id/loyalty/redeem":                     {Tag: "Customers", Summary: "Redeem loyalty points as a discount on a pending order", Request: models.RedeemLoyaltyRequest{}, Response: models.LoyaltyRedemption{}},
+	"GET /api/customers/:id/loyalty/transactions":                {Tag: "Customers", Summary: "List a customer's loyalty points ledger and balance", Response: []models.LoyaltyTransaction{}, List: true, Export: true},
+	"GET /api/customers/:id/notifications":                       {Tag: "Customers", Summary: "List a customer's in-app notifications with the unread count", Query: map[string]string{"unread": "true to list only unread notifications", "page": "Page number", "limit": "Page size (max 100)"}, Response: []models.InboxNotification{}, List: true, Export: true},
+	"POST /api/customers/:id/notifications/read":                 {Tag: "Customers", Summary: "Mark all of a customer's notifications read"},
+	"POST /api/customers/:id/notifications/:notificationId/read": {Tag: "Customers", Summary: "Mark a notification read", Response: models.InboxNotification{}},
+	"GET /api/customers/:id/export":                              {Tag: "Customers", Summary: "Queue an export of everything stored about a customer, or get the one already queued", Query: map[string]string{"format": "json (default) or zip"}, Response: models.CustomerExport{}, Status: http.StatusAccepted},
+	"GET /api/customers/:id/export/:exportId":                    {Tag: "Customers", Summary: "Get a customer export's status and, once ready, its signed download link", Response: models.CustomerExport{}},
+
+	"GET /api/cart/:sessionId":               {Tag: "Cart", Summary: "Get a cart", Response: models.Cart{}},
+	"POST /api/cart/:sessionId/items":        {Tag: "Cart", Summary: "Add an item to the cart", Request: models.AddToCartRequest{}, Response: models.Cart{}, Status: http.StatusCreated},
+	"PUT /api/cart/:sessionId/items/:sku":    {Tag: "Cart", Summary: "Change an item's quantity", Request: models.UpdateCartItemRequest{}, Response: models.Cart{}},
+	"DELETE /api/cart/:sessionId/items/:sku": {Tag: "Cart", Summary: "Remove an item from the cart"},
+	"DELETE /api/cart/:sessionId/clear":      {Tag: "Cart", Summary: "Empty the cart"},
+	"POST /api/cart/:sessionId/checkout":     {Tag: "Cart", Summary: "Revalidate the cart before checkout", Response: models.Cart{}},
+	"POST /api/cart/:sessionId/coupon":       {Tag: "Cart", Summary: "Apply a coupon to the cart", Request: models.ApplyCouponRequest{}, Response: models.Cart{}},
+	"DELETE /api/cart/:sessionId/coupon":     {Tag: "Cart", Summary: "Remove the cart's coupon", Response: models.Cart{}},
+	"PUT /api/cart/:sessionId/shipping":      {Tag: "Cart", Summary: "Set where and how the cart ships, which sets its tax and shipping rates", Request: models.SetCartShippingRequest{}, Response: models.Cart{}},
+
+	"GET /api/coupons/":                 {Tag: "Coupons", Summary: "List coupons", Admin: true, Response: []models.Coupon{}, List: true},
+	"POST /api/coupons/":                {Tag: "Coupons", Summary: "Create a coupon", Admin: true, Request: models.CreateCouponRequest{}, Response: models.Coupon{}, Status: http.StatusCreated},
+	"GET /api/coupons/:code":            {Tag: "Coupons", Summary: "Get a coupon", Admin: true, Response: models.Coupon{}},
+	"PUT /api/coupons/:code":            {Tag: "Coupons", Summary: "Update a coupon", Admin: true, Request: models.UpdateCouponRequest{}, Response: models.Coupon{}},
+	"DELETE /api/coupons/:code":         {Tag: "Coupons", Summary: "Delete a coupon", Admin: true},
+	"GET /api/gift-cards/":              {Tag: "Gift Cards", Summary: "List gift cards", Admin: true, Response: []models.GiftCard{}, List: true},
+	"POST /api/gift-cards/":             {Tag: "Gift Cards", Summary: "Issue a gift card", Admin: true, Request: models.IssueGiftCardRequest{}, Response: models.GiftCard{}, Status: http.StatusCreated},
+	"GET /api/gift-cards/:code/balance": {Tag: "Gift Cards", Summary: "Check a gift card balance", Response: models.GiftCardBalance{}},
+
+	"GET /api/inventory/":                             {Tag: "Inventory", Summary: "List inventory levels", Query: map[string]string{"page": "Page number", "limit": "Page size (max 100)"}, Response: []models.InventoryItem{}, List: true},
+	"POST /api/inventory/":                            {Tag: "Inventory", Summary: "Adjust stock levels", Request: models.AdjustInventoryRequest{}},
+	"GET /api/inventory/:sku":                         {Tag: "Inventory", Summary: "Get warehouse levels for a product", Response: models.InventoryDetail{}},
+	"PUT /api/inventory/:sku":                         {Tag: "Inventory", Summary: "Set warehouse levels for a product", Request: models.SetInventoryLevelsRequest{}},
+	"GET /api/inventory/:sku/history":                 {Tag: "Inventory", Summary: "Stock movement history", Query: map[string]string{"page": "Page number", "limit": "Page size (max 100)"}, Response: []models.InventoryLog{}, List: true},
+	"POST /api/inventory/:sku/recounts":               {Tag: "Inventory", Summary: "Submit a stock recount", Request: models.CreateRecountRequest{}, Response: models.InventoryRecount{}, Status: http.StatusCreated},
+	"GET /api/inventory/recounts":                     {Tag: "Inventory", Summary: "List stock recounts", Query: map[string]string{"status": "Recount status", "page": "Page number", "limit": "Page size (max 100)"}, Response: []models.InventoryRecount{}, List: true},
+	"POST /api/inventory/recounts/:recountId/approve": {Tag: "Inventory", Summary: "Approve a recount and apply it", Admin: true},
+	"POST /api/inventory/recounts/:recountId/reject":  {Tag: "Inventory", Summary: "Reject a recount", Admin: true, Request: models.ReviewRecountRequest{}, Response: models.InventoryRecount{}},
+
+	"GET /api/analytics/sales":              {Tag: "Analytics", Summary: "Sales over time", Query: analyticsRangeQuery, Response: []mongo.SalesData{}, List: true, Export: true},
+	"GET /api/analytics/sales-by-region":    {Tag: "Analytics", Summary: "Sales by province and city", Query: analyticsRangeQuery, Response: []mongo.RegionSales{}, List: true, Export: true},
+	"GET /api/analytics/funnel":             {Tag: "Analytics", Summary: "Cart-to-order sales funnel", Query: analyticsRangeQuery, Response: models.SalesFunnel{}},
+	"GET /api/analytics/retention":          {Tag: "Analytics", Summary: "Customer retention cohorts", Query: analyticsRangeQuery},
+	"GET /api/analytics/customers/segments": {Tag: "Analytics", Summary: "RFM customer segments", Response: []mongo.CustomerSegment{}, List: true, Export: true},
+	"GET /api/analytics/top-products":       {Tag: "Analytics", Summary: "Best-selling products", Query: analyticsDateQuery, Response: []mongo.TopProduct{}, List: true, Export: true},
+	"GET /api/analytics/inventory":          {Tag: "Analytics", Summary: "Inventory status and alerts", List: true, Export: true},
+	"GET /api/analytics/reviews/sentiment":  {Tag: "Analytics", Summary: "Review sentiment per product", Response: []mongo.ProductSentiment{}, List: true},
+	"GET /api/analytics/search":             {Tag: "Analytics", Summary: "Top search terms and searches with no results", Query: map[string]string{"start_date": "YYYY-MM-DD (default 30 days ago)", "end_date": "YYYY-MM-DD (default today)", "limit": "Terms per list (default 10, max 100)"}, Response: mongo.SearchAnalytics{}},
+
+	"GET /api/analytics/ai/sales-report":             {Tag: "AI Analytics", Summary: "AI sales report", Query: analyticsDateQuery, Response: ai.AIReportResponse{}},
+	"GET /api/analytics/ai/customer-insights":        {Tag: "AI Analytics", Summary: "AI customer insights", Response: ai.AIReportResponse{}},
+	"GET /api/analytics/ai/inventory-report":         {Tag: "AI Analytics", Summary: "AI inventory report", Response: ai.AIReportResponse{}},
+	"GET /api/analytics/ai/product-analysis":         {Tag: "AI Analytics", Summary: "AI top product analysis", Query: analyticsDateQuery, Response: ai.AIReportResponse{}},
+	"GET /api/analytics/ai/forecast":                 {Tag: "AI Analytics", Summary: "AI demand forecast", Response: ai.AIReportResponse{}},
+	"GET /api/analytics/ai/sales-report/stream":      {Tag: "AI Analytics", Summary: "Stream the AI sales report", Query: analyticsDateQuery, Stream: true},
+	"GET /api/analytics/ai/customer-insights/stream": {Tag: "AI Analytics", Summary: "Stream AI customer insights", Stream: true},
+	"GET /api/analytics/ai/inventory-report/stream":  {Tag: "AI Analytics", Summary: "Stream the AI inventory report", Stream: true},
+	"GET /api/analytics/ai/product-analysis/stream":  {Tag: "AI Analytics", Summary: "Stream the AI product analysis", Query: analyticsDateQuery, Stream: true},
+	"GET /api/analytics/ai/reports":                  {Tag: "AI Analytics", Summary: "List stored AI reports", Query: map[string]string{"type": "Report type", "schedule_id": "Schedule that produced the report", "startDate": "YYYY-MM-DD", "endDate": "YYYY-MM-DD", "page": "Page number", "limit": "Page size (max 100)"}, Response: []models.StoredReport{}, List: true},
+	"GET /api/analytics/ai/reports/:reportId":        {Tag: "AI Analytics", Summary: "Get a stored AI report", Response: models.StoredReport{}},
+
+	"POST /api/ai/query":             {Tag: "AI", Summary: "Answer a natural-language question from the data", Admin: true, Request: NaturalLanguageQueryRequest{}, List: true},
+	"POST /api/ai/chat":              {Tag: "AI", Summary: "Chat with the shopping assistant", Admin: true, Request: models.ChatRequest{}},
+	"GET /api/ai/chat/:sessionId":    {Tag: "AI", Summary: "Get chat history", Admin: true, Response: []models.ChatMessage{}, List: true},
+	"DELETE /api/ai/chat/:sessionId": {Tag: "AI", Summary: "Clear chat history", Admin: true},
+
+	"GET /api/admin/":                                          {Tag: "Admin", Summary: "Admin root"},
+	"GET /api/admin/abandoned-carts":                           {Tag: "Admin", Summary: "List abandoned cart snapshots", Admin: true, Query: map[string]string{"page": "Page number", "limit": "Page size (max 100)"}, Response: []models.AbandonedCart{}, List: true},
+	"POST /api/admin/reviews/sentiment":                        {Tag: "Admin", Summary: "Start the review sentiment job", Admin: true, Status: http.StatusAccepted},
+	"GET /api/admin/reviews/sentiment":                         {Tag: "Admin", Summary: "Review sentiment job status", Admin: true},
+	"DELETE /api/admin/analytics/cache":                        {Tag: "Admin", Summary: "Clear cached analytics", Admin: true},
+	"GET /api/admin/cache":                                     {Tag: "Admin", Summary: "Count Redis keys by prefix and report Redis memory use", Admin: true, Response: models.CacheStats{}},
+	"DELETE /api/admin/cache":                                  {Tag: "Admin", Summary: "Flush one cache prefix", Admin: true, Query: map[string]string{"prefix": "product:, category: or analytics:"}},
+	"POST /api/admin/cache/warm":                               {Tag: "Admin", Summary: "Load products from MongoDB into the Redis cache now", Admin: true, Query: map[string]string{"limit": "Best sellers to cache (default CACHE_WARM_LIMIT, max 1000)", "all": "true caches every active product"}},
+	"GET /api/admin/indexes":                                   {Tag: "Admin", Summary: "List the indexes on each collection with usage counts from $indexStats; meta.missing names required indexes that don't exist", Admin: true, Query: map[string]string{"collection": "Only this collection's indexes"}, Response: []models.CollectionIndex{}, List: true},
+	"POST /api/admin/indexes/ensure":                           {Tag: "Admin", Summary: "Create any missing required index, as at startup", Admin: true, Response: []models.EnsuredIndex{}, List: true},
+	"POST /api/admin/products/embeddings":                      {Tag: "Admin", Summary: "Start the product embedding job", Admin: true, Query: map[string]string{"reembed": "Re-embed unchanged products"}, Status: http.StatusAccepted},
+	"GET /api/admin/products/embeddings":                       {Tag: "Admin", Summary: "Product embedding job status", Admin: true},
+	"GET /api/admin/reports/schedules":                         {Tag: "Admin", Summary: "List AI report schedules", Admin: true, Response: []models.ReportSchedule{}, List: true},
+	"POST /api/admin/reports/schedules":                        {Tag: "Admin", Summary: "Schedule an AI report", Admin: true, Request: models.CreateReportScheduleRequest{}, Response: models.ReportSchedule{}, Status: http.StatusCreated},
+	"DELETE /api/admin/reports/schedules/:scheduleId":          {Tag: "Admin", Summary: "Delete an AI report schedule", Admin: true},
+	"POST /api/admin/reports/archives":                         {Tag: "Admin", Summary: "Queue a ZIP of the sales, top product and inventory reports as CSV with AI summaries, or get the one already queued for the range", Admin: true, Request: models.CreateReportArchiveRequest{}, Response: models.ReportArchive{}, Status: http.StatusAccepted},
+	"GET /api/admin/reports/archives/:archiveId":               {Tag: "Admin", Summary: "Get a report archive's status and, once ready, its signed download link", Admin: true, Response: models.ReportArchive{}},
+	"GET /api/admin/audit-logs":                                {Tag: "Admin", Summary: "Query the audit log of writes, with field changes to products and orders", Admin: true, Query: map[string]string{"entity_type": "customer, order, product, inventory, coupon, gift_card, review or admin", "entity_id": "First route parameter, such as a customer ID, order number or SKU", "actor": "admin or anonymous", "admin_id": "Admin whose personal key made the write", "method": "POST, PUT, PATCH or DELETE", "route": "Route pattern, such as /api/products/:sku", "field": "Only writes that changed this field, such as price", "startDate": "YYYY-MM-DD", "endDate": "YYYY-MM-DD", "page": "Page number", "limit": "Page size (max 100)"}, Response: []models.AuditLog{}, List: true},
+	"GET /api/admin/scheduler/tasks":                           {Tag: "Admin", Summary: "List recurring tasks with their schedule, next run and last run status", Admin: true, Response: []scheduler.TaskStatus{}, List: true},
+	"GET /api/admin/loyalty-tiers":                             {Tag: "Admin", Summary: "Get the benefits of each loyalty tier", Admin: true, Response: models.LoyaltyTierRules{}},
+	"PUT /api/admin/loyalty-tiers":                             {Tag: "Admin", Summary: "Replace the benefits of every loyalty tier", Admin: true, Request: models.UpdateLoyaltyTierRulesRequest{}, Response: models.LoyaltyTierRules{}},
+	"GET /api/admin/tax-rules":                                 {Tag: "Admin", Summary: "Get the sales tax rates of each province", Admin: true, Response: models.TaxRules{}},
+	"PUT /api/admin/tax-rules":                                 {Tag: "Admin", Summary: "Replace the sales tax rates of every province", Admin: true, Request: models.UpdateTaxRulesRequest{}, Response: models.TaxRules{}},
+	"GET /api/admin/templates":                                 {Tag: "Admin", Summary: "List the current version of every notification template", Admin: true, Response: []models.NotificationTemplate{}, List: true},
+	"POST /api/admin/templates":                                {Tag: "Admin", Summary: "Create a notification template", Admin: true, Request: models.CreateTemplateRequest{}, Response: models.NotificationTemplate{}, Status: http.StatusCreated},
+	"GET /api/admin/templates/:key":                            {Tag: "Admin", Summary: "Get a template's current version", Admin: true, Query: map[string]string{"version": "An earlier version to fetch instead"}, Response: models.NotificationTemplate{}},
+	"PUT /api/admin/templates/:key":                            {Tag: "Admin", Summary: "Save a new version of a template", Admin: true, Request: models.UpdateTemplateRequest{}, Response: models.NotificationTemplate{}},
+	"DELETE /api/admin/templates/:key":                         {Tag: "Admin", Summary: "Delete a template and all its versions", Admin: true},
+	"GET /api/admin/templates/:key/versions":                   {Tag: "Admin", Summary: "List every version of a template", Admin: true, Response: []models.NotificationTemplate{}, List: true},
+	"POST /api/admin/templates/:key/versions/:version/restore": {Tag: "Admin", Summary: "Save an earlier version as the template's next version", Admin: true, Response: models.NotificationTemplate{}},
+	"POST /api/admin/templates/:key/preview":                   {Tag: "Admin", Summary: "Render a template with sample variables", Admin: true, Request: models.PreviewTemplateRequest{}, Response: models.RenderedTemplate{}},
+	"GET /api/admin/notifications":                             {Tag: "Admin", Summary: "List queued, sent and dead-lettered notifications", Admin: true, Query: map[string]string{"status": "pending, sent or dead", "channel": "email, sms or webhook", "recipient": "Email address, phone number or webhook URL", "page": "Page number", "limit": "Page size (max 100)"}, Response: []models.Notification{}, List: true},
+	"POST /api/admin/notifications/:notificationId/retry":      {Tag: "Admin", Summary: "Requeue a dead-lettered notification", Admin: true, Response: models.Notification{}},
+	"GET /api/admin/admins":                                    {Tag: "Admin", Summary: "List admins with their roles, status and last login", Admin: true, Query: map[string]string{"status": "invited, active or deactivated", "page": "Page number", "limit": "Page size (max 100)"}, Response: []models.Admin{}, List: true},
+	"POST /api/admin/admins":                                   {Tag: "Admin", Summary: "Invite someone to become an admin by email, or send an invited admin a new link", Admin: true, Request: models.InviteAdminRequest{}, Response: models.Admin{}, Status: http.StatusCreated},
+	"PUT /api/admin/admins/:adminId/roles":                     {Tag: "Admin", Summary: "Replace an admin's roles", Admin: true, Request: models.UpdateAdminRolesRequest{}, Response: models.Admin{}},
+	"POST /api/admin/admins/:adminId/deactivate":               {Tag: "Admin", Summary: "Deactivate an admin's key and pending invitation", Admin: true, Response: models.Admin{}},
+	"POST /api/admin/invitations/accept":                       {Tag: "Admin", Summary: "Accept an admin invitation and get the personal admin key, shown only once", Request: models.AcceptAdminInvitationRequest{}, Response: models.AcceptedAdminInvitation{}, Status: http.StatusCreated},
+	"POST /api/admin/seed":                                     {Tag: "Admin", Summary: "Generate sample products, customers, orders and reviews (development and staging only)", Admin: true, Request: models.SeedRequest{}, Response: models.SeedCounts{}, Status: http.StatusCreated},
+	"GET /api/admin/jobs/:jobId":                               {Tag: "Admin", Summary: "Get a background import's progress, rejected rows and final counts", Admin: true, Response: models.ImportJob{}},
+}
+
+var (
+	analyticsDateQuery  = map[string]string{"startDate": "YYYY-MM-DD", "endDate": "YYYY-MM-DD"}
+	analyticsRangeQuery = map[string]string{"start_date": "YYYY-MM-DD", "end_date": "YYYY-MM-DD"}
+	cursorQuery         = map[string]string{"cursor": "Switches to cursor pagination; empty for the first page, then the previous next_cursor", "limit": "Page size in cursor mode (default 20, max 100)"}
+	customerListQuery   = map[string]string{"page": "Page number", "limit": "Page size (default 20, max 100)", "cursor": cursorQuery["cursor"], "account_status": "active, inactive, suspended or deleted", "tier": "Loyalty tier: Bronze, Silver, Gold or Platinum", "min_total_spent": "Lowest lifetime spend", "created_from": "YYYY-MM-DD", "created_to": "YYYY-MM-DD"}
+)
+
+// ginParamPattern matches Gin path parameters such as :sku
+var ginParamPattern = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// buildOpenAPISpec assembles the OpenAPI document for the given routes
+func buildOpenAPISpec(routes gin.RoutesInfo) map[string]interface{} {
+	schemas := &schemaRegistry{definitions: map[string]interface{}{}}
+	schemas.schemaFor(reflect.TypeOf(global.ValidationError{}))
+	schemas.schemaFor(reflect.TypeOf(global.PaginationInfo{}))
+	schemas.definitions["ErrorResponse"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"success": map[string]interface{}{"type": "boolean", "example": false},
+			"message": map[string]interface{}{"type": "string"},
+			"errors":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/components/schemas/ValidationError"}},
+		},
+	}
+
+	errorResponse := func(description string) map[string]interface{} {
+		return map[string]interface{}{
+			"description": description,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/ErrorResponse"}},
+			},
+		}
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Path, "/api/") || strings.HasPrefix(route.Path, "/api/docs") {
+			continue
+		}
+
+		doc, documented := routeDocs[route.Method+" "+route.Path]
+		if !documented {
+			doc = routeDoc{Tag: "Undocumented", Summary: handlerName(route.Handler)}
+		}
+		if doc.Status == 0 {
+			doc.Status = http.StatusOK
+		}
+
+		var parameters []interface{}
+		for _, match := range ginParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		queryNames := make([]string, 0, len(doc.Query))
+		for name := range doc.Query {
+			queryNames = append(queryNames, name)
+		}
+		sort.Strings(queryNames)
+		for _, name := range queryNames {
+			parameters = append(parameters, map[string]interface{}{
+				"name": name, "in": "query", "description": doc.Query[name], "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		if doc.Export {
+			parameters = append(parameters, map[string]interface{}{
+				"name": "format", "in": "query", "description": "csv or ndjson instead of JSON; overrides Accept",
+				"schema": map[string]interface{}{"type": "string", "enum": []string{formatJSON, formatCSV, formatNDJSON}},
+			})
+		}
+		if doc.Admin {
+			parameters = append(parameters, map[string]interface{}{
+				"name": "X-Admin-Key", "in": "header", "required": true, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		dataSchema := map[string]interface{}{"type": "object"}
+		if doc.List {
+			dataSchema = map[string]interface{}{"type": "array", "items": map[string]interface{}{}}
+		}
+		if doc.Response != nil {
+			dataSchema = schemas.schemaFor(reflect.TypeOf(doc.Response))
+		}
+		envelope := map[string]interface{}{
+			"success": map[string]interface{}{"type": "boolean", "example": true},
+			"data":    dataSchema,
+		}
+		if doc.List {
+			envelope["pagination"] = map[string]interface{}{"$ref": "#/components/schemas/PaginationInfo"}
+			envelope["meta"] = map[string]interface{}{"type": "object", "additionalProperties": true}
+		}
+		successContent := map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"type": "object", "properties": envelope},
+			},
+		}
+		if doc.Bare {
+			successContent["application/json"] = map[string]interface{}{"schema": dataSchema}
+		}
+		if doc.Export {
+			successContent[mimeCSV] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+			successContent[mimeNDJSON] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+		}
+		if doc.Stream {
+			successContent = map[string]interface{}{
+				"text/event-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			}
+		}
+		if len(doc.Download) > 0 {
+			successContent = map[string]interface{}{}
+			for _, contentType := range doc.Download {
+				successContent[contentType] = map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}}
+			}
+		}
+
+		responses := map[string]interface{}{
+			strconv.Itoa(doc.Status): map[string]interface{}{"description": http.StatusText(doc.Status), "content": successContent},
+			"400":                    errorResponse("Invalid request"),
+			"500":                    errorResponse("Server error"),
+		}
+		if len(parameters) > 0 && strings.Contains(route.Path, ":") {
+			responses["404"] = errorResponse("Not found")
+		}
+		if doc.Admin {
+			responses["403"] = errorResponse("Admin access required")
+		}
+
+		operation := map[string]interface{}{
+			"tags":        []string{doc.Tag},
+			"summary":     doc.Summary,
+			"operationId": route.Method + strings.ReplaceAll(ginParamPattern.ReplaceAllString(route.Path, "$1"), "/", "_"),
+			"responses":   responses,
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if doc.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemas.schemaFor(reflect.TypeOf(doc.Request))},
+				},
+			}
+		}
+
+		path := ginParamPattern.ReplaceAllString(route.Path, "{$1}")
+		pathItem, ok := paths[path].(map[string]interface{})
+		if !ok {
+			pathItem = map[string]interface{}{}
+			paths[path] = pathItem
+		}
+		pathItem[strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "PLAR E-commerce API",
+			"version":     "1.0.0",
+			"description": "Every JSON response uses the {success, data, message, errors} envelope. List endpoints return an array in data plus pagination and meta.",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas.definitions},
+	}
+}
+
+// handlerName shortens a Gin handler name such as ".../internal/router.GetCart" to "GetCart"
+func handlerName(handler string) string {
+	if i := strings.LastIndex(handler, "."); i >= 0 {
+		return handler[i+1:]
+	}
+	return handler
+}
+
+// schemaRegistry converts Go types to JSON schemas, registering each named struct once
+type schemaRegistry struct {
+	definitions map[string]interface{}
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	objectIDType  = reflect.TypeOf(bson.ObjectID{})
+	errorCodeType = reflect.TypeOf(errorcodes.Code(""))
+)
+
+func (r *schemaRegistry) schemaFor(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case objectIDType:
+		return map[string]interface{}{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+	case errorCodeType:
+		codes := []string{}
+		for _, entry := range errorcodes.Catalog() {
+			codes = append(codes, string(entry.Code))
+		}
+		return map[string]interface{}{"type": "string", "enum": codes}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": r.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": r.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		if _, ok := r.definitions[t.Name()]; !ok {
+			// Reserve the name first so self-referencing types terminate
+			r.definitions[t.Name()] = map[string]interface{}{}
+			r.definitions[t.Name()] = r.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema lists a struct's JSON fields, applying required, oneof and length rules
+// from its validate and binding tags
+func (r *schemaRegistry) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := r.structSchema(field.Type)
+			for key, value := range embedded["properties"].(map[string]interface{}) {
+				properties[key] = value
+			}
+			if embeddedRequired, ok := embedded["required"].([]string); ok {
+				required = append(required, embeddedRequired...)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := r.schemaFor(field.Type)
+		rules := field.Tag.Get("binding")
+		if rules == "" {
+			rules = field.Tag.Get("validate")
+		}
+		if applyValidationRules(schema, rules, field.Type) {
+			required = append(required, name)
+		}
+		properties[name] = schema
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// applyValidationRules copies validator rules that OpenAPI can express onto schema and
+// reports whether the field is required. Rules after "dive" apply to elements and are skipped.
+func applyValidationRules(schema map[string]interface{}, rules string, fieldType reflect.Type) bool {
+	if _, isRef := schema["$ref"]; isRef {
+		return strings.Contains(rules, "required")
+	}
+
+	for fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+	isString := fieldType.Kind() == reflect.String
+
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "oneof":
+			schema["enum"] = strings.Fields(value)
+		case "min", "max", "len", "gte", "lte", "gt":
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			switch {
+			case isString && (key == "min" || key == "len"):
+				schema["minLength"] = int(number)
+			case isString && key == "max":
+				schema["maxLength"] = int(number)
+			case isString:
+			case key == "min" || key == "gte":
+				schema["minimum"] = number
+			case key == "max" || key == "lte":
+				schema["maximum"] = number
+			case key == "gt":
+				schema["minimum"] = number
+				schema["exclusiveMinimum"] = true
+			}
+			if isString && key == "len" {
+				schema["maxLength"] = int(number)
+			}
+		}
+	}
+	return required
+}
@@ -0,0 +1,96 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+const (
+	// orderEventsHeartbeat keeps proxies from closing an idle stream
+	orderEventsHeartbeat = 15 * time.Second
+	// orderEventsMaxDuration bounds one connection; EventSource reconnects on its own
+	orderEventsMaxDuration = 30 * time.Minute
+)
+
+// StreamOrderEvents follows one order over Server-Sent Events: a "snapshot" event with
+// the current status and timeline, then a "status" event each time the status changes.
+// The stream ends once the order is delivered or cancelled.
+func StreamOrderEvents(c *gin.Context) {
+	orderNumber := c.Param("orderNumber")
+
+	// Validate order number format
+	if len(orderNumber) < 3 || len(orderNumber) > 100 {
+		respondWithError(c, "Invalid order number format", global.ValidationError{Field: "order_number", Message: "Order number must be between 3 and 100 characters", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	ctx, cancel := streamContext(c, orderEventsMaxDuration)
+	defer cancel()
+
+	// Subscribe before reading the order so a change between the two isn't missed
+	updates, unsubscribe := events.Subscribe(events.OrderTopic(orderNumber), 8)
+	defer unsubscribe()
+
+	order, err := mongo.GetOrderByNumber(ctx, orderNumber)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Order not found", global.ValidationError{Field: "order_number", Message: "No order exists with this order number", Code: errorcodes.NotFound})
+			return
+		}
+		slog.ErrorContext(ctx, "Error fetching order for event stream", "order_number", orderNumber, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to get order", nil))
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent(models.OrderEventSnapshot, models.OrderStatusEvent{
+		OrderNumber: order.OrderNumber,
+		Status:      order.Status,
+		Timeline:    order.Timeline,
+	})
+	c.Writer.Flush()
+	if isFinalOrderStatus(order.Status) {
+		return
+	}
+
+	heartbeat := time.NewTicker(orderEventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			// SSE comment line; ignored by EventSource
+			_, _ = c.Writer.WriteString(": keep-alive\n\n")
+			c.Writer.Flush()
+		case event, ok := <-updates:
+			if !ok {
+				return
+			}
+			c.SSEvent(event.Type, event.Data)
+			c.Writer.Flush()
+			if status, ok := event.Data.(*models.OrderStatusEvent); ok && isFinalOrderStatus(status.Status) {
+				return
+			}
+		}
+	}
+}
+
+// isFinalOrderStatus reports whether an order can no longer change status
+func isFinalOrderStatus(status string) bool {
+	return status == "delivered" || status == "cancelled"
+}
@@ -0,0 +1,423 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/payments"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+)
+
+// maxWebhookBytes bounds a webhook body; payment events are a few KB
+const maxWebhookBytes = 1 << 20
+
+// CreateOrderPaymentIntent starts the payment for an unpaid order with the provider its
+// payment method uses, or returns the payment already started while the customer can
+// still complete it
+func CreateOrderPaymentIntent(c *gin.Context) {
+	orderNumber := c.Param("orderNumber")
+
+	// Validate order number format
+	if len(orderNumber) < 3 || len(orderNumber) > 100 {
+		respondWithError(c, "Invalid order number format", global.ValidationError{Field: "order_number", Message: "Order number must be between 3 and 100 characters", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	order, provider, ok := orderForPayment(c, orderNumber)
+	if !ok {
+		return
+	}
+	if order.Totals.AmountDue <= 0 {
+		respondWithError(c, "Nothing to pay", global.ValidationError{Field: "totals.amount_due", Message: "The order has no amount due", Code: errorcodes.InvalidOperation})
+		return
+	}
+
+	// Reuse the current payment unless it can no longer be completed or the amount has
+	// changed, such as after loyalty points were redeemed
+	var checkout *payments.Checkout
+	if order.Payment.IntentID != "" {
+		existing, err := provider.GetPayment(ctx, order.Payment.IntentID)
+		if err != nil {
+			slog.WarnContext(ctx, "Error fetching payment, starting a new one", "order_number", orderNumber, "provider", provider.Name(), "intent_id", order.Payment.IntentID, "error", err)
+		} else if existing.Reusable && payments.ToCents(existing.Amount) == payments.ToCents(order.Totals.AmountDue) {
+			checkout = existing
+		}
+	}
+
+	if checkout == nil {
+		var err error
+		checkout, err = provider.CreatePayment(ctx, orderNumber, order.Totals.AmountDue)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error creating payment", "order_number", orderNumber, "provider", provider.Name(), "error", err)
+			respondWithError(c, "Failed to start payment", global.ValidationError{Field: "payment", Message: "The payment provider could not start the payment", Code: errorcodes.PaymentFailed})
+			return
+		}
+		if err := mongo.SetOrderPaymentIntent(ctx, orderNumber, checkout.ID); err != nil {
+			if errors.Is(err, mongo.ErrOrderPaid) {
+				respondWithError(c, "Order is already paid", global.ValidationError{Field: "payment.status", Message: "The order's payment has already completed", Code: errorcodes.InvalidStatus})
+				return
+			}
+			slog.ErrorContext(ctx, "Error recording payment intent", "order_number", orderNumber, "intent_id", checkout.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to start payment", nil))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(models.OrderPaymentIntent{
+		OrderNumber:  orderNumber,
+		Provider:     provider.Name(),
+		IntentID:     checkout.ID,
+		ClientSecret: checkout.ClientSecret,
+		ApprovalURL:  checkout.ApprovalURL,
+		Amount:       checkout.Amount,
+		Currency:     checkout.Currency,
+		Status:       checkout.Status,
+	}))
+}
+
+// CaptureOrderPayment collects a PayPal payment once the customer has approved it and
+// marks the order paid. Capturing an order that is already paid returns it unchanged.
+func CaptureOrderPayment(c *gin.Context) {
+	orderNumber := c.Param("orderNumber")
+
+	// Validate order number format
+	if len(orderNumber) < 3 || len(orderNumber) > 100 {
+		respondWithError(c, "Invalid order number format", global.ValidationError{Field: "order_number", Message: "Order number must be between 3 and 100 characters", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	order, provider, ok := orderForPayment(c, orderNumber)
+	if !ok {
+		return
+	}
+	capturer, ok := provider.(payments.Capturer)
+	if !ok {
+		respondWithError(c, "Payment is captured automatically", global.ValidationError{Field: "payment.method", Message: "Only PayPal payments are captured through the API", Code: errorcodes.InvalidOperation})
+		return
+	}
+	if order.Payment.IntentID == "" {
+		respondWithError(c, "Payment not started", global.ValidationError{Field: "payment.intent_id", Message: "Start the payment with POST /api/orders/:orderNumber/payment-intent first", Code: errorcodes.InvalidStatus})
+		return
+	}
+
+	capture, err := capturer.Capture(ctx, order.Payment.IntentID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error capturing payment", "order_number", orderNumber, "provider", provider.Name(), "intent_id", order.Payment.IntentID, "error", err)
+		respondWithError(c, "Failed to capture payment", global.ValidationError{Field: "payment", Message: "The payment provider could not capture the payment; the customer may not have approved it yet", Code: errorcodes.PaymentFailed})
+		return
+	}
+
+	var updated *models.Order
+	if capture.Status == "COMPLETED" {
+		updated, err = mongo.MarkOrderPaid(ctx, orderNumber, order.Payment.IntentID, capture.TransactionID, capture.Amount)
+	} else {
+		updated, err = mongo.MarkOrderPaymentFailed(ctx, orderNumber, order.Payment.IntentID, "PayPal did not complete the payment ("+capture.Status+")")
+	}
+	if errors.Is(err, mongo.ErrPaymentMismatch) {
+		slog.ErrorContext(ctx, "Captured payment does not match order", "order_number", orderNumber, "transaction_id", capture.TransactionID, "error", err)
+		respondWithError(c, "Payment does not match order", global.ValidationError{Field: "payment", Message: err.Error(), Code: errorcodes.PaymentFailed})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error recording captured payment", "order_number", orderNumber, "transaction_id", capture.TransactionID, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to record payment", nil))
+		return
+	}
+	if updated == nil {
+		// A webhook or an earlier capture got there first
+		if updated, err = mongo.GetOrderByNumber(ctx, orderNumber); err != nil {
+			slog.ErrorContext(ctx, "Error fetching order from MongoDB", "error", err)
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to record payment", nil))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(updated))
+}
+
+// RefundOrder refunds a paid order through its payment provider, either in full or for
+// the listed line items, and records the refund on the order with its reason and actor
+func RefundOrder(c *gin.Context) {
+	orderNumber := c.Param("orderNumber")
+
+	// Validate order number format
+	if len(orderNumber) < 3 || len(orderNumber) > 100 {
+		respondWithError(c, "Invalid order number format", global.ValidationError{Field: "order_number", Message: "Order number must be between 3 and 100 characters", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	var req models.RefundOrderRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	order, err := mongo.GetOrderByNumber(ctx, orderNumber)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Order not found", global.ValidationError{Field: "order_number", Message: "No order exists with this order number", Code: errorcodes.NotFound})
+			return
+		}
+		slog.ErrorContext(ctx, "Error fetching order from MongoDB", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to refund order", nil))
+		return
+	}
+
+	provider, err := payments.ForMethod(order.Payment.Method)
+	switch {
+	case errors.Is(err, payments.ErrNoProvider):
+		respondWithError(c, "Order is not paid online", global.ValidationError{Field: "payment.method", Message: "Cash orders are refunded outside the API", Code: errorcodes.InvalidOperation})
+		return
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, global.ErrorResponse("Payments by "+order.Payment.Method+" are not enabled", nil))
+		return
+	case order.Payment.Status != "completed" || order.Payment.TransactionID == "":
+		respondWithError(c, "Order can't be refunded", global.ValidationError{Field: "payment.status", Message: "Only completed payments can be refunded", Code: errorcodes.InvalidStatus})
+		return
+	}
+
+	remaining := math.Round((order.Payment.Amount-order.Payment.Refunded)*100) / 100
+	refund := models.OrderRefund{Reason: req.Reason, Actor: requestActor(c), CreatedAt: time.Now()}
+
+	if len(req.Items) == 0 {
+		// A full refund returns whatever is left, shipping included, and covers every
+		// unit not refunded yet
+		refund.Amount = remaining
+		for i := range order.Items {
+			item := &order.Items[i]
+			if quantity := item.Quantity - order.RefundedQuantity(item.SKU); quantity > 0 {
+				refund.Items = append(refund.Items, models.RefundItem{SKU: item.SKU, Quantity: quantity, Amount: order.ItemRefundAmount(item, quantity)})
+			}
+		}
+	} else {
+		for i, line := range req.Items {
+			field := fmt.Sprintf("items[%d]", i)
+
+			var item *models.OrderItem
+			for j := range order.Items {
+				if order.Items[j].SKU == line.SKU {
+					item = &order.Items[j]
+					break
+				}
+			}
+			if item == nil {
+				respondWithError(c, "Item not in order", global.ValidationError{Field: field + ".sku", Message: "The order has no item with SKU " + line.SKU, Code: errorcodes.InvalidValue})
+				return
+			}
+
+			refundable := item.Quantity - order.RefundedQuantity(item.SKU)
+			for _, earlier := range refund.Items {
+				if earlier.SKU == item.SKU {
+					refundable -= earlier.Quantity
+				}
+			}
+			if line.Quantity > refundable {
+				respondWithError(c, "Quantity exceeds what can be refunded", global.ValidationError{Field: field + ".quantity", Message: fmt.Sprintf("Only %d of %s can still be refunded", max(refundable, 0), item.SKU), Code: errorcodes.OutOfRange})
+				return
+			}
+
+			amount := order.ItemRefundAmount(item, line.Quantity)
+			refund.Items = append(refund.Items, models.RefundItem{SKU: item.SKU, Quantity: line.Quantity, Amount: amount})
+			refund.Amount += amount
+		}
+		// Rounding each line can leave the sum a cent over what is left
+		refund.Amount = math.Min(math.Round(refund.Amount*100)/100, remaining)
+	}
+
+	if refund.Amount <= 0 {
+		respondWithError(c, "Nothing to refund", global.ValidationError{Field: "items", Message: "The order has nothing left to refund", Code: errorcodes.InvalidOperation})
+		return
+	}
+
+	issued, err := provider.Refund(ctx, order.Payment.TransactionID, refund.Amount, order.Payment.Refunded, req.Reason)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error issuing refund", "order_number", orderNumber, "provider", provider.Name(), "amount", refund.Amount, "error", err)
+		respondWithError(c, "Failed to refund order", global.ValidationError{Field: "payment", Message: "The payment provider could not issue the refund", Code: errorcodes.PaymentFailed})
+		return
+	}
+	refund.ID = issued.ID
+	refund.Status = issued.Status
+
+	updated, err := mongo.AddOrderRefund(ctx, orderNumber, refund, order.Payment.Refunded)
+	if err == nil && updated == nil {
+		// A retry of the same request already recorded this refund
+		updated, err = mongo.GetOrderByNumber(ctx, orderNumber)
+	}
+	if err != nil {
+		// The money has moved, so log enough to record the refund by hand
+		slog.ErrorContext(ctx, "Error recording refund", "order_number", orderNumber, "refund_id", refund.ID, "amount", refund.Amount, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Refund issued but not recorded", nil))
+		return
+	}
+
+	slog.InfoContext(ctx, "Refunded order", "order_number", orderNumber, "provider", provider.Name(), "refund_id", refund.ID, "amount", refund.Amount, "payment_status", updated.Payment.Status)
+	c.JSON(http.StatusCreated, global.SuccessResponse(updated))
+}
+
+// orderForPayment loads an order that can still be paid and the provider its payment
+// method uses, writing the error response and returning false otherwise
+func orderForPayment(c *gin.Context, orderNumber string) (*models.Order, payments.Provider, bool) {
+	ctx := c.Request.Context()
+
+	order, err := mongo.GetOrderByNumber(ctx, orderNumber)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Order not found", global.ValidationError{Field: "order_number", Message: "No order exists with this order number", Code: errorcodes.NotFound})
+			return nil, nil, false
+		}
+		slog.ErrorContext(ctx, "Error fetching order from MongoDB", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to start payment", nil))
+		return nil, nil, false
+	}
+
+	provider, err := payments.ForMethod(order.Payment.Method)
+	switch {
+	case errors.Is(err, payments.ErrNoProvider):
+		respondWithError(c, "Order is not paid online", global.ValidationError{Field: "payment.method", Message: "Only credit_card, debit_card and paypal orders are paid online", Code: errorcodes.InvalidOperation})
+		return nil, nil, false
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, global.ErrorResponse("Payments by "+order.Payment.Method+" are not enabled", nil))
+		return nil, nil, false
+	case order.Payment.Status == "completed" || order.Payment.Status == "refunded":
+		respondWithError(c, "Order is already paid", global.ValidationError{Field: "payment.status", Message: "The order's payment has already completed", Code: errorcodes.InvalidStatus})
+		return nil, nil, false
+	case order.Status == "cancelled":
+		respondWithError(c, "Order is cancelled", global.ValidationError{Field: "status", Message: "Cancelled orders can't be paid", Code: errorcodes.InvalidStatus})
+		return nil, nil, false
+	}
+	return order, provider, true
+}
+
+// HandlePaymentWebhook verifies a provider's webhook and applies its event to the order
+// exactly once. Each event ID is claimed in Redis while it is applied and remembered
+// afterwards, so redeliveries are acknowledged without touching the order. Providers
+// retry anything but a 2xx, so only failures worth retrying return an error status.
+func HandlePaymentWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	providerName := c.Param("provider")
+
+	if providerName != payments.ProviderStripe && providerName != payments.ProviderPayPal {
+		respondWithError(c, "Unknown payment provider", global.ValidationError{Field: "provider", Message: "must be stripe or paypal", Code: errorcodes.NotFound})
+		return
+	}
+	provider, ok := payments.Get(providerName)
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, global.ErrorResponse("Payments through "+providerName+" are not enabled", nil))
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBytes))
+	if err != nil {
+		respondWithError(c, "Invalid webhook", global.ValidationError{Field: "body", Message: "could not read the request body", Code: errorcodes.JSONParseError})
+		return
+	}
+
+	event, err := provider.ParseWebhook(ctx, payload, c.Request.Header)
+	if err != nil {
+		if errors.Is(err, payments.ErrInvalidSignature) {
+			respondWithError(c, "Invalid webhook signature", global.ValidationError{Field: "signature", Message: "The webhook is not signed for this endpoint or is too old", Code: errorcodes.InvalidSignature})
+			return
+		}
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+			respondWithError(c, "Invalid webhook", global.ValidationError{Field: "body", Message: "must be a " + providerName + " event", Code: errorcodes.JSONParseError})
+			return
+		}
+		// PayPal verifies signatures over the network, which can fail transiently
+		slog.ErrorContext(ctx, "Error verifying payment webhook", "provider", providerName, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to process webhook", nil))
+		return
+	}
+
+	if event.Type == "" {
+		// Events the endpoint is subscribed to but the API doesn't act on
+		c.JSON(http.StatusOK, global.SuccessResponse(gin.H{"received": true}))
+		return
+	}
+
+	// The order updates below only match orders in the state they move from, so an event
+	// applied twice changes nothing. The claim keeps concurrent deliveries from racing and
+	// lets redeliveries skip the database. Without Redis, fall back on the updates alone.
+	claimed := false
+	state, err := redis.ClaimWebhookEvent(ctx, providerName, event.ID)
+	switch {
+	case err != nil:
+		slog.WarnContext(ctx, "Error claiming payment webhook, applying it unclaimed", "provider", providerName, "event_id", event.ID, "error", err)
+	case state == redis.WebhookEventProcessed:
+		slog.InfoContext(ctx, "Skipping duplicate payment webhook", "provider", providerName, "event_id", event.ID, "type", event.Type)
+		c.JSON(http.StatusOK, global.SuccessResponse(gin.H{"received": true, "duplicate": true}))
+		return
+	case state == redis.WebhookEventProcessing:
+		respondWithError(c, "Webhook already processing", global.ValidationError{Field: "id", Message: "Another delivery of this event is being processed", Code: errorcodes.AlreadyProcessing})
+		return
+	default:
+		claimed = true
+	}
+
+	order, err := applyPaymentEvent(ctx, event)
+	if err != nil && !errors.Is(err, mongo.ErrNotFound) && !errors.Is(err, mongo.ErrPaymentMismatch) {
+		slog.ErrorContext(ctx, "Error applying payment webhook", "provider", providerName, "event_id", event.ID, "type", event.Type, "error", err)
+		if claimed {
+			if err := redis.ReleaseWebhookEvent(ctx, providerName, event.ID); err != nil {
+				slog.WarnContext(ctx, "Error releasing payment webhook", "provider", providerName, "event_id", event.ID, "error", err)
+			}
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to process webhook", nil))
+		return
+	}
+
+	if claimed {
+		if err := redis.CompleteWebhookEvent(ctx, providerName, event.ID); err != nil {
+			slog.WarnContext(ctx, "Error recording processed payment webhook", "provider", providerName, "event_id", event.ID, "error", err)
+		}
+	}
+
+	switch {
+	case errors.Is(err, mongo.ErrPaymentMismatch):
+		// Retrying won't change the payment either; leave the order unpaid for review
+		slog.ErrorContext(ctx, "Payment webhook does not match order", "provider", providerName, "event_id", event.ID, "type", event.Type, "order_number", event.OrderNumber, "error", err)
+	case err != nil:
+		// Retrying won't make the order appear, so acknowledge the event
+		slog.WarnContext(ctx, "Payment webhook for an unknown order", "provider", providerName, "event_id", event.ID, "type", event.Type)
+	case order != nil:
+		slog.InfoContext(ctx, "Applied payment webhook", "provider", providerName, "event_id", event.ID, "type", event.Type, "order_number", order.OrderNumber, "payment_status", order.Payment.Status)
+	}
+	c.JSON(http.StatusOK, global.SuccessResponse(gin.H{"received": true}))
+}
+
+// applyPaymentEvent moves the event's order to its new payment state. It returns nil
+// when the order had already moved past it.
+func applyPaymentEvent(ctx context.Context, event *payments.WebhookEvent) (*models.Order, error) {
+	switch event.Type {
+	case payments.EventPaymentSucceeded:
+		return mongo.MarkOrderPaid(ctx, event.OrderNumber, event.PaymentID, event.TransactionID, event.Amount)
+	case payments.EventPaymentFailed:
+		return mongo.MarkOrderPaymentFailed(ctx, event.OrderNumber, event.PaymentID, event.FailureMessage)
+	case payments.EventRefunded:
+		paid, err := mongo.GetOrderByTransaction(ctx, event.TransactionID)
+		if err != nil {
+			return nil, err
+		}
+		return mongo.RecordOrderRefund(ctx, paid.OrderNumber, event.Refunded)
+	default:
+		return nil, nil
+	}
+}
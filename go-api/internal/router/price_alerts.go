@@ -0,0 +1,103 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// ListPriceAlerts returns a customer's price alerts, newest first
+func ListPriceAlerts(c *gin.Context) {
+	customer, ok := loadCustomer(c)
+	if !ok {
+		return
+	}
+
+	alerts, err := mongo.ListPriceAlerts(c.Request.Context(), customer.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error listing price alerts", "customer_id", customer.ID.Hex(), "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch price alerts", nil))
+		return
+	}
+
+	respondWithList(c, "price-alerts", alerts, global.SinglePage(len(alerts)), nil)
+}
+
+// CreatePriceAlert watches a product's price for a customer, or changes the target price
+// of the alert they already have on it
+func CreatePriceAlert(c *gin.Context) {
+	customer, ok := loadCustomer(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreatePriceAlertRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	product, err := mongo.GetProductBySKU(ctx, req.SKU)
+	if err != nil && !errors.Is(err, mongo.ErrNotFound) {
+		slog.ErrorContext(ctx, "Error fetching product", "sku", req.SKU, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch product", nil))
+		return
+	}
+	if err != nil || product.Status == "deleted" {
+		respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
+		return
+	}
+
+	alert, created, err := mongo.SavePriceAlert(ctx, customer.ID, product, req.TargetPrice)
+	if err != nil {
+		if errors.Is(err, mongo.ErrLimitReached) {
+			respondWithError(c, "Price alert limit reached", global.ValidationError{Field: "id", Message: fmt.Sprintf("Customers can watch at most %d products", models.MaxPriceAlerts), Code: errorcodes.LimitReached})
+			return
+		}
+		slog.ErrorContext(ctx, "Error saving price alert", "customer_id", customer.ID.Hex(), "sku", req.SKU, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to save price alert", nil))
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, global.SuccessResponse(alert))
+}
+
+// DeletePriceAlert stops a customer's price alert
+func DeletePriceAlert(c *gin.Context) {
+	customerID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+	alertID, err := bson.ObjectIDFromHex(c.Param("alertId"))
+	if err != nil {
+		respondWithError(c, "Invalid price alert ID format", global.ValidationError{Field: "alertId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	if err := mongo.DeletePriceAlert(c.Request.Context(), customerID, alertID); err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Price alert not found", global.ValidationError{Field: "alertId", Message: "This customer has no price alert with this ID", Code: errorcodes.NotFound})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error deleting price alert", "customer_id", customerID.Hex(), "alert_id", alertID.Hex(), "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to delete price alert", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]string{
+		"id": alertID.Hex(),
+	}))
+}
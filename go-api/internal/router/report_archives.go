@@ -0,0 +1,148 @@
+package router
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// RequestReportArchive queues a ZIP of the sales, top product and inventory reports for
+// a date range, or returns the archive already queued or ready for the same range
+func RequestReportArchive(c *gin.Context) {
+	var req models.CreateReportArchiveRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		respondWithError(c, "Invalid start_date", global.ValidationError{Field: "start_date", Message: "start_date must be in YYYY-MM-DD format", Code: errorcodes.InvalidFormat})
+		return
+	}
+	endDate, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil {
+		respondWithError(c, "Invalid end_date", global.ValidationError{Field: "end_date", Message: "end_date must be in YYYY-MM-DD format", Code: errorcodes.InvalidFormat})
+		return
+	}
+	if endDate.Before(startDate) {
+		respondWithError(c, "Invalid date range", global.ValidationError{Field: "end_date", Message: "end_date must not be before start_date", Code: errorcodes.InvalidValue})
+		return
+	}
+	if days := int(endDate.Sub(startDate)/(24*time.Hour)) + 1; days > models.MaxReportArchiveDays {
+		respondWithError(c, "Date range too long", global.ValidationError{Field: "end_date", Message: fmt.Sprintf("An archive covers at most %d days", models.MaxReportArchiveDays), Code: errorcodes.OutOfRange})
+		return
+	}
+
+	var requestedBy *bson.ObjectID
+	if admin := requestAdmin(c); admin != nil {
+		requestedBy = &admin.ID
+	}
+
+	archive, created, err := mongo.RequestReportArchive(c.Request.Context(), req.StartDate, req.EndDate, requestedBy)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error requesting report archive", "start_date", req.StartDate, "end_date", req.EndDate, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to request report archive", nil))
+		return
+	}
+	if created {
+		slog.InfoContext(c.Request.Context(), "Queued report archive", "archive_id", archive.ID.Hex(), "start_date", req.StartDate, "end_date", req.EndDate)
+	}
+
+	respondWithReportArchive(c, archive)
+}
+
+// GetReportArchive reports an archive's progress, with a signed download link once it
+// is ready
+func GetReportArchive(c *gin.Context) {
+	archiveID, err := bson.ObjectIDFromHex(c.Param("archiveId"))
+	if err != nil {
+		respondWithError(c, "Invalid archive ID format", global.ValidationError{Field: "archiveId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	archive, err := mongo.GetReportArchive(c.Request.Context(), archiveID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Report archive not found", global.ValidationError{Field: "archiveId", Message: "No report archive exists with this ID", Code: errorcodes.NotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch report archive", nil))
+		return
+	}
+
+	respondWithReportArchive(c, archive)
+}
+
+// respondWithReportArchive sends an archive's status: 200 once it is ready (with a
+// download link) or failed, otherwise 202 with a Location to poll
+func respondWithReportArchive(c *gin.Context, archive *models.ReportArchive) {
+	switch archive.Status {
+	case models.ExportReady:
+		archive.DownloadURL = signedReportArchiveURL(archive.ID, time.Now().Add(exportLinkTTL))
+		c.JSON(http.StatusOK, global.SuccessResponse(archive))
+	case models.ExportFailed:
+		c.JSON(http.StatusOK, global.SuccessResponse(archive))
+	default:
+		c.Header("Location", fmt.Sprintf("/api/admin/reports/archives/%s", archive.ID.Hex()))
+		c.JSON(http.StatusAccepted, global.SuccessResponse(archive))
+	}
+}
+
+// DownloadReportArchive serves a ready archive's ZIP to a holder of its signed link
+func DownloadReportArchive(c *gin.Context) {
+	archiveID, err := bson.ObjectIDFromHex(c.Param("archiveId"))
+	if err != nil {
+		respondWithError(c, "Invalid archive ID format", global.ValidationError{Field: "archiveId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !hmac.Equal([]byte(c.Query("signature")), []byte(reportArchiveSignature(archiveID, expires))) {
+		respondWithError(c, "Invalid download link", global.ValidationError{Field: "signature", Message: "The link is not signed for this archive", Code: errorcodes.InvalidSignature})
+		return
+	}
+	if time.Now().Unix() > expires {
+		respondWithError(c, "Download link expired", global.ValidationError{Field: "expires", Message: "Fetch the archive's status again for a new link", Code: errorcodes.LinkExpired})
+		return
+	}
+
+	archive, err := mongo.GetReportArchiveFile(c.Request.Context(), archiveID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Report archive not found", global.ValidationError{Field: "archiveId", Message: "The archive has expired or is not ready", Code: errorcodes.NotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch report archive", nil))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", archive.FileName()))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", archive.Data)
+}
+
+// signedReportArchiveURL is the download path for an archive, valid until expires
+func signedReportArchiveURL(archiveID bson.ObjectID, expires time.Time) string {
+	return fmt.Sprintf("/api/report-archives/%s/download?expires=%d&signature=%s", archiveID.Hex(), expires.Unix(), reportArchiveSignature(archiveID, expires.Unix()))
+}
+
+// reportArchiveSignature signs an archive ID and link expiry with the export signing key.
+// The prefix keeps a customer export's signature from working as an archive's.
+func reportArchiveSignature(archiveID bson.ObjectID, expires int64) string {
+	mac := hmac.New(sha256.New, exportSigningKey)
+	fmt.Fprintf(mac, "report-archive:%s:%d", archiveID.Hex(), expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,130 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// parseHelpfulVote extracts the review and customer IDs for a helpful vote request
+func parseHelpfulVote(c *gin.Context) (bson.ObjectID, bson.ObjectID, bool) {
+	reviewID, err := bson.ObjectIDFromHex(c.Param("reviewId"))
+	if err != nil {
+		respondWithError(c, "Invalid review ID format", global.ValidationError{Field: "reviewId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return bson.ObjectID{}, bson.ObjectID{}, false
+	}
+
+	var request models.ReviewHelpfulVoteRequest
+	if !bindJSON(c, &request) {
+		return bson.ObjectID{}, bson.ObjectID{}, false
+	}
+
+	customerID, err := bson.ObjectIDFromHex(request.CustomerID)
+	if err != nil {
+		respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "customer_id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return bson.ObjectID{}, bson.ObjectID{}, false
+	}
+
+	return reviewID, customerID, true
+}
+
+// MarkReviewHelpful records a customer's helpful vote on a review
+func MarkReviewHelpful(c *gin.Context) {
+	reviewID, customerID, ok := parseHelpfulVote(c)
+	if !ok {
+		return
+	}
+
+	review, err := mongo.AddReviewHelpfulVote(c.Request.Context(), reviewID, customerID)
+	if err != nil {
+		switch err.Error() {
+		case "review not found":
+			respondWithError(c, "Review not found", global.ValidationError{Field: "reviewId", Message: "No review exists with this ID", Code: errorcodes.NotFound})
+		case "customer has already voted on this review":
+			respondWithError(c, "Already voted", global.ValidationError{Field: "customer_id", Message: err.Error(), Code: errorcodes.DuplicateVote})
+		default:
+			slog.ErrorContext(c.Request.Context(), "Error recording helpful vote", "review_id", reviewID.Hex(), "error", err)
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to record vote", nil))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(review))
+}
+
+// UnmarkReviewHelpful removes a customer's helpful vote from a review
+func UnmarkReviewHelpful(c *gin.Context) {
+	reviewID, customerID, ok := parseHelpfulVote(c)
+	if !ok {
+		return
+	}
+
+	review, err := mongo.RemoveReviewHelpfulVote(c.Request.Context(), reviewID, customerID)
+	if err != nil {
+		switch err.Error() {
+		case "vote not found", "review not found":
+			respondWithError(c, "Vote not found", global.ValidationError{Field: "customer_id", Message: "This customer has not voted on this review", Code: errorcodes.NotFound})
+		default:
+			slog.ErrorContext(c.Request.Context(), "Error removing helpful vote", "review_id", reviewID.Hex(), "error", err)
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to remove vote", nil))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(review))
+}
+
+// ReplyToReview adds or replaces the merchant reply on a review
+func ReplyToReview(c *gin.Context) {
+	reviewID, err := bson.ObjectIDFromHex(c.Param("reviewId"))
+	if err != nil {
+		respondWithError(c, "Invalid review ID format", global.ValidationError{Field: "reviewId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	var request models.ReviewReplyRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	review, err := mongo.SetReviewReply(c.Request.Context(), reviewID, request.Message, request.Author)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Review not found", global.ValidationError{Field: "reviewId", Message: "No review exists with this ID", Code: errorcodes.NotFound})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error replying to review", "review_id", reviewID.Hex(), "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to save reply", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(review))
+}
+
+// GetReviewSentimentAnalytics returns aggregate review sentiment per product
+func GetReviewSentimentAnalytics(c *gin.Context) {
+	var productID bson.ObjectID
+	if productIDParam := c.Query("product_id"); productIDParam != "" {
+		objectID, err := bson.ObjectIDFromHex(productIDParam)
+		if err != nil {
+			respondWithError(c, "Invalid product ID format", global.ValidationError{Field: "product_id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+			return
+		}
+		productID = objectID
+	}
+
+	sentiments, err := mongo.GetProductSentiment(c.Request.Context(), productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve review sentiment: "+err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.ListResponse(sentiments, global.SinglePage(len(sentiments)), nil))
+}
@@ -0,0 +1,186 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// loadCustomer reads the customer ID from the path and fetches the customer
+func loadCustomer(c *gin.Context) (*models.Customer, bool) {
+	customerID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return nil, false
+	}
+
+	// In Production, this would be protected to allow only the customer themselves or admins to access the data
+	customer, err := mongo.GetCustomerByID(c.Request.Context(), customerID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Customer not found", global.ValidationError{Field: "id", Message: "No customer exists with this ID", Code: errorcodes.NotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch customer", nil))
+		return nil, false
+	}
+
+	return customer, true
+}
+
+// parseSavedSearchID reads the customer and saved search IDs from the path
+func parseSavedSearchID(c *gin.Context) (bson.ObjectID, bson.ObjectID, bool) {
+	customerID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "id", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return bson.ObjectID{}, bson.ObjectID{}, false
+	}
+
+	searchID, err := bson.ObjectIDFromHex(c.Param("searchId"))
+	if err != nil {
+		respondWithError(c, "Invalid saved search ID format", global.ValidationError{Field: "searchId", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return bson.ObjectID{}, bson.ObjectID{}, false
+	}
+
+	return customerID, searchID, true
+}
+
+// validateSavedSearchFilters applies the checks parseProductFilters makes on query
+// parameters to filters sent in a request body
+func validateSavedSearchFilters(c *gin.Context, filters models.ProductFilters) bool {
+	if filters.PriceMin != nil && filters.PriceMax != nil && *filters.PriceMin > *filters.PriceMax {
+		respondWithError(c, "Invalid price filter", global.ValidationError{Field: "filters.price_min", Message: "must not be greater than price_max", Code: errorcodes.InvalidRange})
+		return false
+	}
+	for name := range filters.Attributes {
+		if !attributeFilterPattern.MatchString(name) {
+			respondWithError(c, "Invalid attribute filter", global.ValidationError{Field: "filters.attributes." + name, Message: "attribute names may only contain letters, digits, underscores and hyphens", Code: errorcodes.InvalidFormat})
+			return false
+		}
+	}
+	return true
+}
+
+// respondWithSavedSearchError writes the response for a failed saved search lookup or write
+func respondWithSavedSearchError(c *gin.Context, err error, action string) {
+	switch err.Error() {
+	case "saved search not found":
+		respondWithError(c, "Saved search not found", global.ValidationError{Field: "searchId", Message: "This customer has no saved search with this ID", Code: errorcodes.NotFound})
+	case "no fields to update":
+		respondWithError(c, "No updates provided", global.ValidationError{Field: "body", Message: "Request body must contain at least one field to update", Code: errorcodes.EmptyUpdates})
+	default:
+		slog.ErrorContext(c.Request.Context(), "Error with saved search", "action", action, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to "+action+" saved search", nil))
+	}
+}
+
+// ListSavedSearches returns a customer's saved searches, newest first
+func ListSavedSearches(c *gin.Context) {
+	customer, ok := loadCustomer(c)
+	if !ok {
+		return
+	}
+
+	searches, err := mongo.ListSavedSearches(c.Request.Context(), customer.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error listing saved searches", "customer_id", customer.ID.Hex(), "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch saved searches", nil))
+		return
+	}
+
+	respondWithList(c, "saved-searches", searches, global.SinglePage(len(searches)), nil)
+}
+
+// CreateSavedSearch saves a search query and filters for a customer
+func CreateSavedSearch(c *gin.Context) {
+	customer, ok := loadCustomer(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateSavedSearchRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !validateSavedSearchFilters(c, req.Filters) {
+		return
+	}
+
+	search, err := mongo.CreateSavedSearch(c.Request.Context(), req.ToSavedSearch(customer.ID))
+	if err != nil {
+		if errors.Is(err, mongo.ErrLimitReached) {
+			respondWithError(c, "Saved search limit reached", global.ValidationError{Field: "id", Message: fmt.Sprintf("Customers can save at most %d searches", models.MaxSavedSearches), Code: errorcodes.LimitReached})
+			return
+		}
+		respondWithSavedSearchError(c, err, "create")
+		return
+	}
+
+	c.JSON(http.StatusCreated, global.SuccessResponse(search))
+}
+
+// GetSavedSearch returns one of a customer's saved searches
+func GetSavedSearch(c *gin.Context) {
+	customerID, searchID, ok := parseSavedSearchID(c)
+	if !ok {
+		return
+	}
+
+	search, err := mongo.GetSavedSearch(c.Request.Context(), customerID, searchID)
+	if err != nil {
+		respondWithSavedSearchError(c, err, "fetch")
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(search))
+}
+
+// UpdateSavedSearch applies partial updates to a saved search. Filters replace the
+// saved filters as a whole.
+func UpdateSavedSearch(c *gin.Context) {
+	customerID, searchID, ok := parseSavedSearchID(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateSavedSearchRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Filters != nil && !validateSavedSearchFilters(c, *req.Filters) {
+		return
+	}
+
+	search, err := mongo.UpdateSavedSearch(c.Request.Context(), customerID, searchID, &req)
+	if err != nil {
+		respondWithSavedSearchError(c, err, "update")
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(search))
+}
+
+// DeleteSavedSearch removes a saved search
+func DeleteSavedSearch(c *gin.Context) {
+	customerID, searchID, ok := parseSavedSearchID(c)
+	if !ok {
+		return
+	}
+
+	if err := mongo.DeleteSavedSearch(c.Request.Context(), customerID, searchID); err != nil {
+		respondWithSavedSearchError(c, err, "delete")
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]string{
+		"id": searchID.Hex(),
+	}))
+}
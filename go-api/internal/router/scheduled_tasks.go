@@ -0,0 +1,65 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+	"julianmorley.ca/con-plar/prog2270/pkg/scheduler"
+)
+
+// precomputedAnalytics are the cached analytics views refreshed ahead of time, requested
+// with their default parameters as the dashboard loads them
+var precomputedAnalytics = []string{
+	"/api/analytics/sales",
+	"/api/analytics/sales-by-region",
+	"/api/analytics/customers/segments",
+	"/api/analytics/top-products",
+}
+
+// RegisterScheduledTasks adds the router's recurring tasks to the default scheduler.
+// Call it after InitializeRoutes.
+func RegisterScheduledTasks(cfg config.SchedulerConfig) error {
+	return scheduler.Register(scheduler.Task{
+		Name:    "analytics-precompute",
+		Spec:    cfg.AnalyticsPrecompute.Schedule,
+		Enabled: cfg.AnalyticsPrecompute.Enabled,
+		Timeout: 5 * time.Minute,
+		Run:     precomputeAnalytics,
+	})
+}
+
+// precomputeAnalytics refreshes the cached analytics views by requesting each through
+// the router with ?refresh=true, so they are cached under the same keys clients hit. It
+// does nothing when analytics aren't cached.
+func precomputeAnalytics(ctx context.Context) error {
+	if !redis.Analytics.Enabled() {
+		return nil
+	}
+
+	for _, path := range precomputedAnalytics {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, path+"?refresh=true", nil)
+		if err != nil {
+			return err
+		}
+
+		recorder := httptest.NewRecorder()
+		Router.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusOK {
+			return fmt.Errorf("%s returned status %d", path, recorder.Code)
+		}
+	}
+	return nil
+}
+
+// GetScheduledTasks lists the recurring tasks with their schedule and last run
+func GetScheduledTasks(c *gin.Context) {
+	tasks := scheduler.Status()
+	c.JSON(http.StatusOK, global.ListResponse(tasks, global.SinglePage(len(tasks)), nil))
+}
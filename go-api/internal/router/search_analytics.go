@@ -0,0 +1,103 @@
+package router
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/logging"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// recordSearch logs a search in the background so it doesn't slow the response.
+// Later pages of a search aren't new searches, so only first pages are logged.
+func recordSearch(c *gin.Context, request mongo.SearchRequest, results *mongo.SearchResults) {
+	if request.Page > 1 {
+		return
+	}
+
+	matches := 0
+	for _, count := range results.Matches {
+		matches += count
+	}
+	entry := &models.SearchLog{
+		RequestID: logging.RequestID(c.Request.Context()),
+		Query:     request.Query,
+		Term:      models.SearchTerm(request.Query),
+		Types:     request.SearchedTypes(),
+		Filtered:  !request.Filters.IsEmpty(),
+		Results:   matches,
+		CreatedAt: time.Now(),
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		if err := mongo.InsertSearchLog(ctx, entry); err != nil {
+			slog.ErrorContext(ctx, "Error writing search log", "error", err)
+		}
+	}()
+}
+
+// GetSearchAnalytics reports search volume, the most searched terms, and the most
+// searched terms that found nothing
+func GetSearchAnalytics(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		respondWithError(c, "Invalid limit parameter", global.ValidationError{Field: "limit", Message: "limit must be a number between 1 and 100", Code: errorcodes.OutOfRange})
+		return
+	}
+
+	// Default to the last 30 days
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	startDate := today.AddDate(0, 0, -29)
+	endDate := today
+
+	for _, param := range []struct {
+		name string
+		date *time.Time
+	}{{"start_date", &startDate}, {"end_date", &endDate}} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			respondWithError(c, "Invalid "+param.name+" parameter", global.ValidationError{Field: param.name, Message: param.name + " must be in YYYY-MM-DD format", Code: errorcodes.InvalidFormat})
+			return
+		}
+		*param.date = parsed
+	}
+	if endDate.Before(startDate) {
+		respondWithError(c, "Invalid date range", global.ValidationError{Field: "end_date", Message: "end_date must not be before start_date", Code: errorcodes.InvalidRange})
+		return
+	}
+
+	var analytics *mongo.SearchAnalytics
+	err = cachedAnalytics(c, &analytics, func() (err error) {
+		ctx, cancel := mongo.Analytics.Context(c.Request.Context())
+		defer cancel()
+
+		// endDate is inclusive, so count searches up to the start of the following day
+		analytics, err = mongo.GetSearchAnalytics(ctx, startDate, endDate.Add(24*time.Hour), limit)
+		if analytics != nil {
+			analytics.StartDate = startDate.Format("2006-01-02")
+			analytics.EndDate = endDate.Format("2006-01-02")
+		}
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to retrieve search analytics: "+err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(analytics))
+}
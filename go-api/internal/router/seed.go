@@ -0,0 +1,56 @@
+package router
+
+import (
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/seed"
+)
+
+// SeedDatabase fills the database with generated products, customers, orders and
+// reviews. It only runs when ENV is development or staging.
+func SeedDatabase(c *gin.Context) {
+	if !seedEnabled {
+		respondWithError(c, "Seeding is disabled", global.ValidationError{Field: "env", Message: "Sample data can only be seeded when ENV is development or staging", Code: errorcodes.Forbidden})
+		return
+	}
+
+	var req models.SeedRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	counts := req.Counts()
+
+	// Seeded customers share a random password, so nobody can sign in as them
+	password := make([]byte, 32)
+	_, _ = rand.Read(password)
+	passwordHash, err := bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to process password", nil))
+		return
+	}
+
+	data, err := seed.Generate(counts, string(passwordHash))
+	if err != nil {
+		respondWithError(c, "Invalid seed volumes", global.ValidationError{Field: "body", Message: err.Error(), Code: errorcodes.InvalidValue})
+		return
+	}
+
+	if err := mongo.SeedDatabase(c.Request.Context(), data); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error seeding database", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to seed database", nil))
+		return
+	}
+
+	seeded := models.SeedCounts{Products: len(data.Products), Customers: len(data.Customers), Orders: len(data.Orders), Reviews: len(data.Reviews)}
+	slog.InfoContext(c.Request.Context(), "Database seeded", "products", seeded.Products, "customers", seeded.Customers, "orders", seeded.Orders, "reviews", seeded.Reviews)
+
+	c.JSON(http.StatusCreated, global.SuccessResponse(seeded))
+}
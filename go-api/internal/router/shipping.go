@@ -0,0 +1,40 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+)
+
+// SetCartShipping sets where and how a cart ships, returning the cart taxed for its
+// province with shipping quoted for every method
+func SetCartShipping(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, global.ErrorResponse("Session ID is required", []global.ValidationError{
+			{Field: "sessionId", Message: "sessionId URL parameter is required"},
+		}))
+		return
+	}
+
+	var req models.SetCartShippingRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx, cancel := global.GetRequestTimer(c.Request.Context())
+	defer cancel()
+
+	restorePersistedCart(ctx, sessionID)
+
+	cart, err := redis.SetCartShipping(ctx, sessionID, req.Province, req.PostalCode, req.Method)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to update cart: "+err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(cart))
+}
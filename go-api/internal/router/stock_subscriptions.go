@@ -0,0 +1,76 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// NotifyMeWhenInStock subscribes a customer or email address to one email when an
+// out-of-stock product is restocked
+func NotifyMeWhenInStock(c *gin.Context) {
+	sku := c.Param("sku")
+	if len(sku) < 3 || len(sku) > 50 {
+		respondWithError(c, "Invalid SKU format", global.ValidationError{Field: "sku", Message: "SKU must be between 3 and 50 characters", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	var req models.NotifyMeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	product, err := mongo.GetProductBySKU(ctx, sku)
+	if err != nil && !errors.Is(err, mongo.ErrNotFound) {
+		slog.ErrorContext(ctx, "Error fetching product", "sku", sku, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch product", nil))
+		return
+	}
+	if err != nil || product.Status == "deleted" {
+		respondWithError(c, "Product not found", global.ValidationError{Field: "sku", Message: "No product exists with this SKU", Code: errorcodes.NotFound})
+		return
+	}
+	if product.Stock.Total > 0 {
+		respondWithError(c, "Product is in stock", global.ValidationError{Field: "sku", Message: "Only out-of-stock products can be subscribed to", Code: errorcodes.InvalidStatus})
+		return
+	}
+
+	subscription := &models.StockSubscription{SKU: product.SKU, Email: strings.ToLower(req.Email), CreatedAt: time.Now()}
+	if req.CustomerID != "" {
+		customerID, _ := bson.ObjectIDFromHex(req.CustomerID)
+		customer, err := mongo.GetCustomerByID(ctx, customerID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNotFound) {
+				respondWithError(c, "Customer not found", global.ValidationError{Field: "customer_id", Message: "No customer exists with this ID", Code: errorcodes.NotFound})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch customer", nil))
+			return
+		}
+		subscription.Email = customer.Email
+		subscription.CustomerID = &customer.ID
+	}
+
+	subscription, created, err := mongo.SubscribeToRestock(ctx, subscription)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving stock subscription", "sku", sku, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to subscribe", nil))
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, global.SuccessResponse(subscription))
+}
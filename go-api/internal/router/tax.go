@@ -0,0 +1,50 @@
+package router
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// GetTaxRules returns the tax rates of each province
+func GetTaxRules(c *gin.Context) {
+	rules, err := mongo.GetTaxRules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to fetch tax rules", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(rules))
+}
+
+// UpdateTaxRules replaces the tax rates of every province
+func UpdateTaxRules(c *gin.Context) {
+	var req models.UpdateTaxRulesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	seen := map[string]bool{}
+	for i, rule := range req.Provinces {
+		if seen[rule.Province] {
+			respondWithError(c, "Duplicate province", global.ValidationError{Field: fmt.Sprintf("provinces[%d].province", i), Message: rule.Province + " is listed more than once", Code: errorcodes.InvalidValue})
+			return
+		}
+		seen[rule.Province] = true
+	}
+
+	rules, err := mongo.UpdateTaxRules(c.Request.Context(), req.Provinces)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error updating tax rules", "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to update tax rules", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(rules))
+}
@@ -0,0 +1,200 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// templateKeyPattern limits template keys to names that are safe in URLs, such as
+// order_shipped or cart.abandoned
+var templateKeyPattern = regexp.MustCompile(`^[a-z0-9_.-]{2,100}$`)
+
+// parseTemplateVersion reads an optional version number from the path or query; 0
+// means the current version
+func parseTemplateVersion(c *gin.Context, value string) (int, bool) {
+	if value == "" {
+		return 0, true
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		respondWithError(c, "Invalid template version", global.ValidationError{Field: "version", Message: "must be a positive integer", Code: errorcodes.InvalidFormat})
+		return 0, false
+	}
+	return version, true
+}
+
+// respondWithTemplateError writes the response for a failed template lookup or write
+func respondWithTemplateError(c *gin.Context, err error, action string) {
+	switch err.Error() {
+	case "template not found":
+		respondWithError(c, "Template not found", global.ValidationError{Field: "key", Message: "No template or template version exists with this key", Code: errorcodes.NotFound})
+	case "template already exists":
+		respondWithError(c, "Template already exists", global.ValidationError{Field: "key", Message: "A template with this key already exists; update it to add a version", Code: errorcodes.DuplicateCode})
+	case "template is being changed concurrently":
+		respondWithError(c, "Template is being changed", global.ValidationError{Field: "key", Message: "Another version was saved at the same time; retry", Code: errorcodes.PreconditionFailed})
+	default:
+		slog.ErrorContext(c.Request.Context(), "Error with template", "action", action, "key", c.Param("key"), "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to "+action+" template", nil))
+	}
+}
+
+// ListTemplates lists the current version of every notification template
+func ListTemplates(c *gin.Context) {
+	templates, err := mongo.ListTemplates(c.Request.Context())
+	if err != nil {
+		respondWithTemplateError(c, err, "list")
+		return
+	}
+
+	respondWithList(c, "templates", templates, global.SinglePage(len(templates)), nil)
+}
+
+// GetTemplate returns a template's current version, or the version given by ?version
+func GetTemplate(c *gin.Context) {
+	version, ok := parseTemplateVersion(c, c.Query("version"))
+	if !ok {
+		return
+	}
+
+	template, err := mongo.GetTemplate(c.Request.Context(), c.Param("key"), version)
+	if err != nil {
+		respondWithTemplateError(c, err, "fetch")
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(template))
+}
+
+// ListTemplateVersions lists every version of a template, newest first
+func ListTemplateVersions(c *gin.Context) {
+	versions, err := mongo.ListTemplateVersions(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		respondWithTemplateError(c, err, "list versions of")
+		return
+	}
+
+	respondWithList(c, "template-versions", versions, global.SinglePage(len(versions)), nil)
+}
+
+// CreateTemplate stores version 1 of a new template
+func CreateTemplate(c *gin.Context) {
+	var req models.CreateTemplateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	req.Key = strings.ToLower(req.Key)
+	if !templateKeyPattern.MatchString(req.Key) {
+		respondWithError(c, "Invalid template key", global.ValidationError{Field: "key", Message: "may only contain lowercase letters, digits, underscores, dots and hyphens", Code: errorcodes.InvalidFormat})
+		return
+	}
+
+	template, err := mongo.CreateTemplate(c.Request.Context(), req.ToTemplate())
+	if err != nil {
+		respondWithTemplateError(c, err, "create")
+		return
+	}
+
+	c.JSON(http.StatusCreated, global.SuccessResponse(template))
+}
+
+// UpdateTemplate saves the request's changes as the template's next version
+func UpdateTemplate(c *gin.Context) {
+	var req models.UpdateTemplateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if req.Description == nil && req.Subject == nil && req.Body == nil {
+		respondWithError(c, "No updates provided", global.ValidationError{Field: "body", Message: "Request body must contain at least one field to update", Code: errorcodes.EmptyUpdates})
+		return
+	}
+
+	template, err := mongo.SaveTemplateVersion(c.Request.Context(), c.Param("key"), req.NextVersion)
+	if err != nil {
+		respondWithTemplateError(c, err, "update")
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(template))
+}
+
+// RestoreTemplateVersion saves an earlier version's copy as the template's next version
+func RestoreTemplateVersion(c *gin.Context) {
+	version, ok := parseTemplateVersion(c, c.Param("version"))
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	restored, err := mongo.GetTemplate(ctx, c.Param("key"), version)
+	if err != nil {
+		respondWithTemplateError(c, err, "fetch")
+		return
+	}
+
+	req := models.UpdateTemplateRequest{Description: &restored.Description, Subject: &restored.Subject, Body: &restored.Body}
+	template, err := mongo.SaveTemplateVersion(ctx, restored.Key, req.NextVersion)
+	if err != nil {
+		respondWithTemplateError(c, err, "restore")
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(template))
+}
+
+// DeleteTemplate removes a template with all its versions
+func DeleteTemplate(c *gin.Context) {
+	key := c.Param("key")
+
+	if err := mongo.DeleteTemplate(c.Request.Context(), key); err != nil {
+		respondWithTemplateError(c, err, "delete")
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
+		"key":     key,
+		"deleted": true,
+	}))
+}
+
+// PreviewTemplate renders a template version with sample variables without sending it
+func PreviewTemplate(c *gin.Context) {
+	var req models.PreviewTemplateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	template, err := mongo.GetTemplate(c.Request.Context(), c.Param("key"), req.Version)
+	if err != nil {
+		respondWithTemplateError(c, err, "fetch")
+		return
+	}
+
+	rendered, err := template.Render(req.Variables)
+	if err != nil {
+		var missing *models.MissingVariablesError
+		if errors.As(err, &missing) {
+			errs := make([]global.ValidationError, 0, len(missing.Names))
+			for _, name := range missing.Names {
+				errs = append(errs, global.ValidationError{Field: "variables." + name, Message: "is used by the template but has no value", Code: errorcodes.Required})
+			}
+			c.JSON(http.StatusBadRequest, global.ErrorResponse("Missing template variables", errs))
+			return
+		}
+		respondWithTemplateError(c, err, "render")
+		return
+	}
+
+	c.JSON(http.StatusOK, global.SuccessResponse(rendered))
+}
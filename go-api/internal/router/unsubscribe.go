@@ -0,0 +1,55 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/notifications"
+)
+
+// Unsubscribe takes a customer off a list with the signed link from one of their
+// notifications. It answers GET for links opened in a browser and POST for one-click
+// unsubscribes from mail clients; unsubscribing twice succeeds both times.
+func Unsubscribe(c *gin.Context) {
+	customerID, err := bson.ObjectIDFromHex(c.Query("customer"))
+	if err != nil {
+		respondWithError(c, "Invalid customer ID format", global.ValidationError{Field: "customer", Message: "Must be a valid MongoDB ObjectID", Code: errorcodes.InvalidFormat})
+		return
+	}
+	list := c.Query("list")
+	switch list {
+	case models.ListEmail, models.ListSMS, models.ListNewsletter:
+	default:
+		respondWithError(c, "Invalid list parameter", global.ValidationError{Field: "list", Message: "list must be one of: email, sms, newsletter", Code: errorcodes.InvalidValue})
+		return
+	}
+	if !notifications.ValidUnsubscribeSignature(customerID, list, c.Query("signature")) {
+		respondWithError(c, "Invalid unsubscribe link", global.ValidationError{Field: "signature", Message: "The link is not signed for this customer and list", Code: errorcodes.InvalidSignature})
+		return
+	}
+
+	customer, err := mongo.UpdateCustomerPreferences(c.Request.Context(), customerID, models.UnsubscribeFrom(list))
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			respondWithError(c, "Customer not found", global.ValidationError{Field: "customer", Message: "No customer exists with this ID", Code: errorcodes.NotFound})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error unsubscribing customer", "customer_id", customerID.Hex(), "list", list, "error", err)
+		c.JSON(http.StatusInternalServerError, global.ErrorResponse("Failed to unsubscribe", nil))
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "Customer unsubscribed", "customer_id", customerID.Hex(), "list", list)
+
+	c.JSON(http.StatusOK, global.SuccessResponse(map[string]interface{}{
+		"customer_id": customerID.Hex(),
+		"list":        list,
+		"preferences": customer.Preferences,
+	}))
+}
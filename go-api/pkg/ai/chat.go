@@ -0,0 +1,54 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// Chat answers the next message in an analytics conversation. The system prompt is seeded
+// with a fresh analytics snapshot each turn so answers reflect current data.
+func Chat(ctx context.Context, history []models.ChatMessage, message string) (string, error) {
+	systemPrompt := fmt.Sprintf(AnalyticsAssistantSystemPrompt, time.Now().Format(time.RFC1123), buildAnalyticsSnapshot(ctx))
+
+	messages := append(history, models.ChatMessage{Role: "user", Content: message})
+	return generateChatCompletion(ctx, systemPrompt, messages)
+}
+
+// buildAnalyticsSnapshot summarizes the last 30 days of sales, top products, customer
+// segments and inventory alerts. Sections that fail to load are noted rather than omitted.
+func buildAnalyticsSnapshot(ctx context.Context) string {
+	endDate := time.Now().Format("2006-01-02")
+	startDate := time.Now().AddDate(0, 0, -30).Format("2006-01-02")
+
+	var snapshot strings.Builder
+	addSection := func(title string, data interface{}, err error) {
+		snapshot.WriteString("## " + title + "\n")
+		if err != nil {
+			snapshot.WriteString("unavailable: " + err.Error() + "\n\n")
+			return
+		}
+		dataJSON, _ := json.Marshal(data)
+		snapshot.Write(dataJSON)
+		snapshot.WriteString("\n\n")
+	}
+
+	sales, err := mongo.GetSalesAnalytics(ctx, startDate, endDate, "week")
+	addSection("Weekly sales, last 30 days", sales, err)
+
+	topProducts, err := mongo.GetTopProductsByRevenue(ctx, 5, "revenue", startDate, endDate)
+	addSection("Top 5 products by revenue, last 30 days", topProducts, err)
+
+	segments, err := mongo.GetCustomerSpendingSegments(ctx)
+	addSection("Customer spending segments", segments, err)
+
+	alerts, err := mongo.GetInventoryStatus(ctx, true)
+	addSection("Inventory alerts", alerts, err)
+
+	return snapshot.String()
+}
@@ -2,94 +2,118 @@ package ai
 
 import (
 	"context"
-	"log"
-	"os"
+	"errors"
+	"log/slog"
+	"strings"
 
-	"github.com/openai/openai-go/v2"
-	"github.com/openai/openai-go/v2/option"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
 )
 
-var client *openai.Client
-var isInitialized bool
-
-// InitializeAIService initializes the Azure OpenAI client with environment variables
-func InitializeAIService() {
-	endpoint := os.Getenv("AZURE_OPENAI_ENDPOINT")
-	apiKey := os.Getenv("AZURE_OPENAI_API_KEY")
+var provider Provider
+
+// InitializeAIService selects the configured AI provider (azure, openai or ollama) and
+// applies the retry and circuit breaker settings. AI features stay disabled when the
+// selected provider is missing its credentials.
+func InitializeAIService(cfg config.AIConfig) {
+	resilience = resilienceConfig{
+		CallTimeout:      cfg.CallTimeout,
+		MaxRetries:       cfg.MaxRetries,
+		BaseBackoff:      cfg.RetryBackoff,
+		FailureThreshold: cfg.BreakerFailures,
+		Cooldown:         cfg.BreakerCooldown,
+	}
 
-	if endpoint == "" || apiKey == "" {
-		log.Println("AI service disabled - Azure OpenAI credentials not provided")
-		log.Println("Required: AZURE_OPENAI_ENDPOINT and AZURE_OPENAI_API_KEY environment variables")
-		isInitialized = false
+	selected, err := newProvider(cfg)
+	if err != nil {
+		slog.Warn("AI service disabled", "reason", err)
+		provider = nil
 		return
 	}
 
-	clientValue := openai.NewClient(
-		option.WithBaseURL(endpoint),
-		option.WithAPIKey(apiKey),
-	)
-	client = &clientValue
-
-	isInitialized = true
-	log.Println("AI service initialized with Azure OpenAI")
+	provider = selected
+	slog.Info("AI service initialized", "provider", provider.Name())
 }
 
 // IsEnabled returns whether the AI service is properly initialized
 func IsEnabled() bool {
-	return isInitialized && client != nil
+	return provider != nil
 }
 
-// GetClient returns the OpenAI client instance
-func GetClient() *openai.Client {
-	if !IsEnabled() {
-		return nil
+// ProviderName returns the configured provider's name, or an empty string when AI is disabled
+func ProviderName() string {
+	if provider == nil {
+		return ""
 	}
-	return client
+	return provider.Name()
 }
 
 // generateCompletion is a helper function to generate AI completions
 func generateCompletion(ctx context.Context, systemMessage, userMessage string) (string, error) {
+	return generateChatCompletion(ctx, systemMessage, []models.ChatMessage{{Role: "user", Content: userMessage}})
+}
+
+// generateChatCompletion generates an AI completion for a multi-turn conversation
+func generateChatCompletion(ctx context.Context, systemMessage string, messages []models.ChatMessage) (string, error) {
 	if !IsEnabled() {
 		return "", &AIError{Message: "AI service is not enabled"}
 	}
 
-	deploymentName := os.Getenv("AZURE_OPENAI_DEPLOYMENT_NAME")
-	if deploymentName == "" {
-		deploymentName = "gpt-35-turbo" // Default deployment name
+	content, err := withResilience(ctx, "ai.complete", func(ctx context.Context) (string, error) {
+		return provider.Complete(ctx, systemMessage, messages)
+	}, func() bool { return true })
+	if errors.Is(err, ErrAIUnavailable) {
+		return "", &AIError{Message: ErrAIUnavailable.Error(), Cause: err}
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "AI API error", "error", err)
+		return "", &AIError{Message: "Failed to generate AI response", Cause: err}
 	}
 
-	resp, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Model: openai.ChatModel(deploymentName),
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			{
-				OfSystem: &openai.ChatCompletionSystemMessageParam{
-					Content: openai.ChatCompletionSystemMessageParamContentUnion{
-						OfString: openai.String(systemMessage),
-					},
-				},
-			},
-			{
-				OfUser: &openai.ChatCompletionUserMessageParam{
-					Content: openai.ChatCompletionUserMessageParamContentUnion{
-						OfString: openai.String(userMessage),
-					},
-				},
-			},
-		},
-		MaxTokens:   openai.Int(1500),  // Limit response length
-		Temperature: openai.Float(0.7), // Balanced creativity
-	})
+	if content == "" {
+		return "", &AIError{Message: "AI returned empty response"}
+	}
+
+	return content, nil
+}
 
+// streamCompletion generates an AI completion using the streaming API, passing each
+// content token to onToken as it arrives. Returns the full response text.
+func streamCompletion(ctx context.Context, systemMessage, userMessage string, onToken func(string)) (string, error) {
+	if !IsEnabled() {
+		return "", &AIError{Message: "AI service is not enabled"}
+	}
+
+	// Retrying after tokens were sent would repeat them, so only retry before the first one
+	streamed := false
+	content, err := withResilience(ctx, "ai.stream", func(ctx context.Context) (string, error) {
+		return provider.Stream(ctx, systemMessage, []models.ChatMessage{{Role: "user", Content: userMessage}}, func(token string) {
+			streamed = true
+			onToken(token)
+		})
+	}, func() bool { return !streamed })
+	if errors.Is(err, ErrAIUnavailable) {
+		return "", &AIError{Message: ErrAIUnavailable.Error(), Cause: err}
+	}
 	if err != nil {
-		log.Printf("AI API Error: %v", err)
-		return "", &AIError{Message: "Failed to generate AI response", Cause: err}
+		slog.ErrorContext(ctx, "AI API error", "error", err)
+		return content, &AIError{Message: "Failed to stream AI response", Cause: err}
 	}
 
-	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
+	if content == "" {
 		return "", &AIError{Message: "AI returned empty response"}
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	return content, nil
+}
+
+// extractJSON strips the markdown code fence models occasionally wrap JSON responses in
+func extractJSON(response string) string {
+	response = strings.TrimSpace(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimSuffix(response, "```")
+	return strings.TrimSpace(response)
 }
 
 // AIError represents an AI service error
@@ -98,6 +122,20 @@ type AIError struct {
 	Cause   error
 }
 
+func (e *AIError) Unwrap() error {
+	return e.Cause
+}
+
+// IsUnavailable reports whether err means the AI service is switched off or its
+// circuit breaker is open, as opposed to a failed request
+func IsUnavailable(err error) bool {
+	var aiErr *AIError
+	if errors.As(err, &aiErr) && aiErr.Message == "AI service is not enabled" {
+		return true
+	}
+	return errors.Is(err, ErrAIUnavailable)
+}
+
 func (e *AIError) Error() string {
 	if e.Cause != nil {
 		return e.Message + ": " + e.Cause.Error()
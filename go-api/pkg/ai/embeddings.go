@@ -0,0 +1,75 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// EmbeddingModel returns the model the current provider uses for embeddings
+func EmbeddingModel() string {
+	if !IsEnabled() {
+		return ""
+	}
+	return provider.EmbeddingModel()
+}
+
+// EmbedTexts returns one vector per input text, in the same order
+func EmbedTexts(ctx context.Context, texts []string) ([][]float64, error) {
+	if !IsEnabled() {
+		return nil, &AIError{Message: "AI service is not enabled"}
+	}
+
+	vectors, err := withResilience(ctx, "ai.embed", func(ctx context.Context) ([][]float64, error) {
+		return provider.Embed(ctx, texts)
+	}, func() bool { return true })
+	if errors.Is(err, ErrAIUnavailable) {
+		return nil, &AIError{Message: ErrAIUnavailable.Error(), Cause: err}
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "AI API error", "error", err)
+		return nil, &AIError{Message: "Failed to generate embeddings", Cause: err}
+	}
+
+	if len(vectors) != len(texts) {
+		return nil, &AIError{Message: "AI returned an incomplete set of embeddings"}
+	}
+	for _, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, &AIError{Message: "AI returned an incomplete set of embeddings"}
+		}
+	}
+
+	return vectors, nil
+}
+
+// EmbedProducts generates embeddings for a batch of products
+func EmbedProducts(ctx context.Context, products []models.Product) ([]models.ProductEmbedding, error) {
+	texts := make([]string, len(products))
+	for i := range products {
+		texts[i] = products[i].EmbeddingText()
+	}
+
+	vectors, err := EmbedTexts(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	embeddings := make([]models.ProductEmbedding, len(products))
+	for i := range products {
+		embeddings[i] = models.ProductEmbedding{
+			ProductID: products[i].ID,
+			SKU:       products[i].SKU,
+			Vector:    vectors[i],
+			TextHash:  products[i].EmbeddingTextHash(),
+			Model:     EmbeddingModel(),
+			UpdatedAt: now,
+		}
+	}
+
+	return embeddings, nil
+}
@@ -0,0 +1,145 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+const (
+	// forecastHistoryDays is how much sales history feeds the forecast
+	forecastHistoryDays = 91
+	// forecastWindowDays is the moving-average window used to project demand
+	forecastWindowDays = 28
+)
+
+// DemandForecast projects units and revenue for one SKU or category
+type DemandForecast struct {
+	Key             string  `json:"key"`
+	Label           string  `json:"label"`
+	HistoryUnits    int     `json:"history_units"`
+	HistoryRevenue  float64 `json:"history_revenue"`
+	WeeklyUnits     []int   `json:"weekly_units"` // oldest week first
+	AvgDailyUnits   float64 `json:"avg_daily_units"`
+	TrendPercent    float64 `json:"trend_percent"` // last window vs the window before it
+	ForecastUnits   int     `json:"forecast_units"`
+	ForecastRevenue float64 `json:"forecast_revenue"`
+}
+
+// DemandForecastResult is the moving-average forecast the AI comments on
+type DemandForecastResult struct {
+	GroupBy     string           `json:"group_by"`
+	HorizonDays int              `json:"horizon_days"`
+	HistoryDays int              `json:"history_days"`
+	Method      string           `json:"method"`
+	Items       []DemandForecast `json:"items"`
+}
+
+// GenerateDemandForecast projects demand for the next horizonDays with a moving average
+// over recent daily sales, and asks the AI for commentary on the projection
+func GenerateDemandForecast(ctx context.Context, groupBy string, horizonDays, limit int, refresh bool) (*AIReportResponse, error) {
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -(forecastHistoryDays - 1))
+
+	sales, err := mongo.GetDailyItemSales(ctx, since, groupBy)
+	if err != nil {
+		return reportDataError("demand forecast", err), err
+	}
+
+	forecast := &DemandForecastResult{
+		GroupBy:     groupBy,
+		HorizonDays: horizonDays,
+		HistoryDays: forecastHistoryDays,
+		Method:      fmt.Sprintf("%d-day moving average of daily units", forecastWindowDays),
+		Items:       forecastDemand(sales, today, horizonDays, limit),
+	}
+
+	return buildReport(ctx, reportRequest{
+		Type:         "forecast",
+		Label:        "demand forecast",
+		Params:       []string{groupBy, fmt.Sprint(horizonDays), fmt.Sprint(limit), today.Format("2006-01-02")},
+		RawData:      forecast,
+		SystemPrompt: DemandForecastSystemPrompt,
+		UserPrompt:   formatForecastDataPrompt(forecast),
+		Refresh:      refresh,
+	}, nil), nil
+}
+
+// forecastDemand builds a forecast per key from daily sales, keeping the limit best sellers
+func forecastDemand(sales []mongo.DailyItemSales, today time.Time, horizonDays, limit int) []DemandForecast {
+	weeks := (forecastHistoryDays + 6) / 7
+	forecasts := map[string]*DemandForecast{}
+	recentUnits := map[string]int{}
+	priorUnits := map[string]int{}
+
+	for _, day := range sales {
+		forecast, ok := forecasts[day.Key]
+		if !ok {
+			forecast = &DemandForecast{Key: day.Key, Label: day.Label, WeeklyUnits: make([]int, weeks)}
+			forecasts[day.Key] = forecast
+		}
+
+		daysAgo := int(today.Sub(day.Date.UTC().Truncate(24*time.Hour)) / (24 * time.Hour))
+		if daysAgo < 0 || daysAgo >= forecastHistoryDays {
+			continue
+		}
+
+		forecast.HistoryUnits += day.Units
+		forecast.HistoryRevenue += day.Revenue
+		forecast.WeeklyUnits[(forecastHistoryDays-1-daysAgo)/7] += day.Units
+
+		switch {
+		case daysAgo < forecastWindowDays:
+			recentUnits[day.Key] += day.Units
+		case daysAgo < 2*forecastWindowDays:
+			priorUnits[day.Key] += day.Units
+		}
+	}
+
+	items := make([]DemandForecast, 0, len(forecasts))
+	for key, forecast := range forecasts {
+		forecast.HistoryRevenue = math.Round(forecast.HistoryRevenue*100) / 100
+		forecast.AvgDailyUnits = math.Round(float64(recentUnits[key])/forecastWindowDays*100) / 100
+		if priorUnits[key] > 0 {
+			change := float64(recentUnits[key]-priorUnits[key]) / float64(priorUnits[key]) * 100
+			forecast.TrendPercent = math.Round(change*10) / 10
+		}
+
+		forecast.ForecastUnits = int(math.Round(float64(recentUnits[key]) / forecastWindowDays * float64(horizonDays)))
+		if forecast.HistoryUnits > 0 {
+			avgUnitRevenue := forecast.HistoryRevenue / float64(forecast.HistoryUnits)
+			forecast.ForecastRevenue = math.Round(float64(forecast.ForecastUnits)*avgUnitRevenue*100) / 100
+		}
+
+		items = append(items, *forecast)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].HistoryUnits > items[j].HistoryUnits
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	return items
+}
+
+func formatForecastDataPrompt(forecast *DemandForecastResult) string {
+	jsonData, _ := json.MarshalIndent(forecast, "", "  ")
+	return fmt.Sprintf(`Review the following %d-day demand forecast by %s. It was produced with a %s;
+weekly_units shows the last %d days of history, oldest week first.
+
+%s
+
+Please provide:
+1. Which items are likely to run hot or cold and why, based on the trend and weekly pattern
+2. Where the simple moving average is likely to over- or under-forecast
+3. Inventory and purchasing recommendations for the forecast period`,
+		forecast.HorizonDays, forecast.GroupBy, forecast.Method, forecast.HistoryDays, string(jsonData))
+}
@@ -0,0 +1,68 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// reviewModerationStatuses maps the AI verdict to the review moderation status
+var reviewModerationStatuses = map[string]string{
+	"approve": "approved",
+	"review":  "pending",
+	"reject":  "flagged",
+}
+
+// ScreenReview asks the AI service whether a review looks like spam or abuse
+func ScreenReview(ctx context.Context, review *models.Review) (*models.ReviewModeration, error) {
+	userPrompt := fmt.Sprintf("Rating: %d/5\nTitle: %s\nComment: %s", review.Rating, review.Title, review.Comment)
+
+	response, err := generateCompletion(ctx, ReviewModerationSystemPrompt, userPrompt)
+	if err != nil {
+		return nil, err
+	}
+
+	var moderation models.ReviewModeration
+	if err := json.Unmarshal([]byte(extractJSON(response)), &moderation); err != nil {
+		return nil, &AIError{Message: "AI returned an unreadable moderation result", Cause: err}
+	}
+	if _, ok := reviewModerationStatuses[moderation.Verdict]; !ok {
+		return nil, &AIError{Message: "AI returned an unknown moderation verdict: " + moderation.Verdict}
+	}
+
+	moderation.CheckedAt = time.Now()
+
+	return &moderation, nil
+}
+
+// ModerateReview screens a newly created review and moves suspicious ones into the
+// pending or flagged state with the AI rationale. Reviews stay approved when AI is disabled.
+func ModerateReview(review *models.Review) {
+	if !IsEnabled() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	moderation, err := ScreenReview(ctx, review)
+	if err != nil {
+		slog.Warn("Failed to screen review", "review_id", review.ID.Hex(), "error", err)
+		return
+	}
+
+	status := reviewModerationStatuses[moderation.Verdict]
+	if err := mongo.SetReviewModeration(ctx, review.ID, status, moderation); err != nil {
+		slog.Warn("Failed to save moderation result", "review_id", review.ID.Hex(), "error", err)
+		return
+	}
+
+	if status != "approved" {
+		slog.Info("Review moderated", "review_id", review.ID.Hex(), "status", status, "category", moderation.Category, "rationale", moderation.Rationale)
+	}
+}
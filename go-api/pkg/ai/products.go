@@ -0,0 +1,50 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// GenerateProductDescription drafts a marketing description, SEO title and tags from a product's attributes
+func GenerateProductDescription(ctx context.Context, product *models.Product) (*models.ProductDescriptionDraft, error) {
+	var attributes strings.Builder
+	for key, value := range product.Attributes {
+		fmt.Fprintf(&attributes, "- %s: %s\n", key, value)
+	}
+
+	userPrompt := fmt.Sprintf(`Name: %s
+Brand: %s
+Category: %s / %s
+Price: %.2f %s
+Current description: %s
+Current tags: %s
+Attributes:
+%s`, product.Name, product.Brand, product.Category, product.Subcategory, product.Price, product.Currency,
+		product.Description, strings.Join(product.Tags, ", "), attributes.String())
+
+	response, err := generateCompletion(ctx, ProductDescriptionSystemPrompt, userPrompt)
+	if err != nil {
+		return nil, err
+	}
+
+	var draft models.ProductDescriptionDraft
+	if err := json.Unmarshal([]byte(extractJSON(response)), &draft); err != nil {
+		return nil, &AIError{Message: "AI returned an unreadable product description", Cause: err}
+	}
+	if draft.Description == "" || draft.SEOTitle == "" {
+		return nil, &AIError{Message: "AI returned an incomplete product description"}
+	}
+
+	for i, tag := range draft.Tags {
+		draft.Tags[i] = strings.ToLower(strings.TrimSpace(tag))
+	}
+	draft.SKU = product.SKU
+	draft.GeneratedAt = time.Now()
+
+	return &draft, nil
+}
@@ -0,0 +1,52 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+const (
+	// completionMaxTokens limits response length for every provider
+	completionMaxTokens = 1500
+	// completionTemperature balances creativity for every provider
+	completionTemperature = 0.7
+)
+
+// Provider is a backend that can generate chat completions and embeddings
+type Provider interface {
+	// Name describes the provider and model for logs
+	Name() string
+	// Complete returns the full response to a conversation
+	Complete(ctx context.Context, systemMessage string, messages []models.ChatMessage) (string, error)
+	// Stream passes response tokens to onToken as they arrive and returns the full response
+	Stream(ctx context.Context, systemMessage string, messages []models.ChatMessage, onToken func(string)) (string, error)
+	// Embed returns one vector per input text, in the same order
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+	// EmbeddingModel identifies the model behind Embed, so vectors from different models are not compared
+	EmbeddingModel() string
+}
+
+// newProvider builds the provider selected by cfg.Provider
+func newProvider(cfg config.AIConfig) (Provider, error) {
+	switch cfg.Provider {
+	case "azure":
+		if cfg.Azure.Endpoint == "" || cfg.Azure.APIKey == "" {
+			return nil, fmt.Errorf("Azure OpenAI credentials not provided (required: AZURE_OPENAI_ENDPOINT and AZURE_OPENAI_API_KEY)")
+		}
+		return newOpenAIProvider("Azure OpenAI", cfg.Azure.Endpoint, cfg.Azure.APIKey,
+			cfg.Azure.Deployment, cfg.Azure.EmbeddingDeployment), nil
+	case "openai":
+		if cfg.OpenAI.APIKey == "" {
+			return nil, fmt.Errorf("OpenAI credentials not provided (required: OPENAI_API_KEY)")
+		}
+		return newOpenAIProvider("OpenAI", cfg.OpenAI.BaseURL, cfg.OpenAI.APIKey,
+			cfg.OpenAI.Model, cfg.OpenAI.EmbeddingModel), nil
+	case "ollama":
+		return newOllamaProvider(cfg.Ollama.Host, cfg.Ollama.Model, cfg.Ollama.EmbeddingModel), nil
+	default:
+		return nil, fmt.Errorf("unknown AI_PROVIDER %q (expected azure, openai or ollama)", cfg.Provider)
+	}
+}
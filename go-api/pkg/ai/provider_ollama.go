@@ -0,0 +1,195 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// ollamaProvider talks to a local Ollama server through its native chat and embed APIs
+type ollamaProvider struct {
+	host           string
+	chatModel      string
+	embeddingModel string
+	httpClient     *http.Client
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaChatRequest struct {
+	Model    string                 `json:"model"`
+	Messages []ollamaMessage        `json:"messages"`
+	Stream   bool                   `json:"stream"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error,omitempty"`
+}
+
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings [][]float64 `json:"embeddings"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// ollamaStatusError is returned when the Ollama server answers with a non-200 status
+type ollamaStatusError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *ollamaStatusError) Error() string {
+	return "ollama returned " + e.Status + ": " + e.Message
+}
+
+// newOllamaProvider creates a provider for the Ollama server at host
+func newOllamaProvider(host, chatModel, embeddingModel string) *ollamaProvider {
+	return &ollamaProvider{
+		host:           strings.TrimSuffix(host, "/"),
+		chatModel:      chatModel,
+		embeddingModel: embeddingModel,
+		httpClient:     &http.Client{},
+	}
+}
+
+func (p *ollamaProvider) Name() string {
+	return "Ollama (" + p.chatModel + ")"
+}
+
+func (p *ollamaProvider) EmbeddingModel() string {
+	return p.embeddingModel
+}
+
+func (p *ollamaProvider) Complete(ctx context.Context, systemMessage string, messages []models.ChatMessage) (string, error) {
+	resp, err := p.post(ctx, "/api/chat", p.chatRequest(systemMessage, messages, false))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var chatResponse ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResponse); err != nil {
+		return "", fmt.Errorf("failed to decode Ollama response: %w", err)
+	}
+	if chatResponse.Error != "" {
+		return "", fmt.Errorf("ollama: %s", chatResponse.Error)
+	}
+
+	return chatResponse.Message.Content, nil
+}
+
+func (p *ollamaProvider) Stream(ctx context.Context, systemMessage string, messages []models.ChatMessage, onToken func(string)) (string, error) {
+	resp, err := p.post(ctx, "/api/chat", p.chatRequest(systemMessage, messages, true))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Ollama streams one JSON object per line
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			return content.String(), fmt.Errorf("failed to decode Ollama stream: %w", err)
+		}
+		if chunk.Error != "" {
+			return content.String(), fmt.Errorf("ollama: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			onToken(chunk.Message.Content)
+		}
+		if chunk.Done {
+			break
+		}
+	}
+
+	return content.String(), scanner.Err()
+}
+
+func (p *ollamaProvider) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	resp, err := p.post(ctx, "/api/embed", ollamaEmbedRequest{Model: p.embeddingModel, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var embedResponse ollamaEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode Ollama embeddings: %w", err)
+	}
+	if embedResponse.Error != "" {
+		return nil, fmt.Errorf("ollama: %s", embedResponse.Error)
+	}
+
+	return embedResponse.Embeddings, nil
+}
+
+// chatRequest converts a conversation into an Ollama chat request
+func (p *ollamaProvider) chatRequest(systemMessage string, messages []models.ChatMessage, stream bool) ollamaChatRequest {
+	ollamaMessages := make([]ollamaMessage, 0, len(messages)+1)
+	ollamaMessages = append(ollamaMessages, ollamaMessage{Role: "system", Content: systemMessage})
+	for _, message := range messages {
+		role := "user"
+		if message.Role == "assistant" {
+			role = "assistant"
+		}
+		ollamaMessages = append(ollamaMessages, ollamaMessage{Role: role, Content: message.Content})
+	}
+
+	return ollamaChatRequest{
+		Model:    p.chatModel,
+		Messages: ollamaMessages,
+		Stream:   stream,
+		Options: map[string]interface{}{
+			"num_predict": completionMaxTokens,
+			"temperature": completionTemperature,
+		},
+	}
+}
+
+// post sends a JSON request to the Ollama server and returns the response on HTTP 200
+func (p *ollamaProvider) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.host+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &ollamaStatusError{StatusCode: resp.StatusCode, Status: resp.Status, Message: strings.TrimSpace(string(message))}
+	}
+
+	return resp, nil
+}
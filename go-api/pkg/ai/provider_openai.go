@@ -0,0 +1,131 @@
+package ai
+
+import (
+	"context"
+	"strings"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// openAIProvider talks to the OpenAI API or an Azure OpenAI deployment, which share a wire format
+type openAIProvider struct {
+	label          string
+	client         openai.Client
+	chatModel      string
+	embeddingModel string
+}
+
+// newOpenAIProvider creates a provider for the OpenAI-compatible API at baseURL.
+// An empty baseURL uses the public OpenAI endpoint.
+func newOpenAIProvider(label, baseURL, apiKey, chatModel, embeddingModel string) *openAIProvider {
+	// Retries are handled by withResilience so they share its backoff and circuit breaker
+	opts := []option.RequestOption{option.WithAPIKey(apiKey), option.WithMaxRetries(0)}
+	if baseURL != "" {
+		opts = append(opts, option.WithBaseURL(baseURL))
+	}
+
+	return &openAIProvider{
+		label:          label,
+		client:         openai.NewClient(opts...),
+		chatModel:      chatModel,
+		embeddingModel: embeddingModel,
+	}
+}
+
+func (p *openAIProvider) Name() string {
+	return p.label + " (" + p.chatModel + ")"
+}
+
+func (p *openAIProvider) EmbeddingModel() string {
+	return p.embeddingModel
+}
+
+func (p *openAIProvider) Complete(ctx context.Context, systemMessage string, messages []models.ChatMessage) (string, error) {
+	resp, err := p.client.Chat.Completions.New(ctx, p.completionParams(systemMessage, messages))
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", nil
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+func (p *openAIProvider) Stream(ctx context.Context, systemMessage string, messages []models.ChatMessage, onToken func(string)) (string, error) {
+	stream := p.client.Chat.Completions.NewStreaming(ctx, p.completionParams(systemMessage, messages))
+	defer stream.Close()
+
+	var content strings.Builder
+	for stream.Next() {
+		chunk := stream.Current()
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		token := chunk.Choices[0].Delta.Content
+		content.WriteString(token)
+		onToken(token)
+	}
+
+	return content.String(), stream.Err()
+}
+
+func (p *openAIProvider) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	resp, err := p.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Model: openai.EmbeddingModel(p.embeddingModel),
+		Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float64, len(texts))
+	for _, embedding := range resp.Data {
+		if int(embedding.Index) < len(vectors) {
+			vectors[embedding.Index] = embedding.Embedding
+		}
+	}
+
+	return vectors, nil
+}
+
+// completionParams builds the chat completion request shared by Complete and Stream
+func (p *openAIProvider) completionParams(systemMessage string, messages []models.ChatMessage) openai.ChatCompletionNewParams {
+	params := []openai.ChatCompletionMessageParamUnion{
+		{
+			OfSystem: &openai.ChatCompletionSystemMessageParam{
+				Content: openai.ChatCompletionSystemMessageParamContentUnion{
+					OfString: openai.String(systemMessage),
+				},
+			},
+		},
+	}
+	for _, message := range messages {
+		if message.Role == "assistant" {
+			params = append(params, openai.ChatCompletionMessageParamUnion{
+				OfAssistant: &openai.ChatCompletionAssistantMessageParam{
+					Content: openai.ChatCompletionAssistantMessageParamContentUnion{
+						OfString: openai.String(message.Content),
+					},
+				},
+			})
+			continue
+		}
+		params = append(params, openai.ChatCompletionMessageParamUnion{
+			OfUser: &openai.ChatCompletionUserMessageParam{
+				Content: openai.ChatCompletionUserMessageParamContentUnion{
+					OfString: openai.String(message.Content),
+				},
+			},
+		})
+	}
+
+	return openai.ChatCompletionNewParams{
+		Model:       openai.ChatModel(p.chatModel),
+		Messages:    params,
+		MaxTokens:   openai.Int(completionMaxTokens),
+		Temperature: openai.Float(completionTemperature),
+	}
+}
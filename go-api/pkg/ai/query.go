@@ -0,0 +1,173 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// maxQueryResults caps how many documents a natural-language query can return
+const maxQueryResults = 100
+
+// queryCollections lists the collections natural-language queries may run against
+var queryCollections = map[string]bool{
+	"orders":         true,
+	"products":       true,
+	"customers":      true,
+	"reviews":        true,
+	"inventory_logs": true,
+}
+
+// queryLookupCollections lists the collections a $lookup stage may join
+var queryLookupCollections = map[string]bool{
+	"orders":   true,
+	"products": true,
+	"reviews":  true,
+}
+
+// queryStages lists the aggregation stages a generated pipeline may use
+var queryStages = map[string]bool{
+	"$match": true, "$group": true, "$project": true, "$addFields": true, "$unwind": true,
+	"$sort": true, "$limit": true, "$skip": true, "$count": true, "$lookup": true,
+}
+
+// queryOperators lists the expression, query and accumulator operators a generated pipeline may use
+var queryOperators = map[string]bool{
+	// Query
+	"$eq": true, "$ne": true, "$gt": true, "$gte": true, "$lt": true, "$lte": true,
+	"$in": true, "$nin": true, "$and": true, "$or": true, "$nor": true, "$not": true,
+	"$exists": true, "$regex": true, "$options": true, "$elemMatch": true, "$size": true, "$expr": true,
+	// Accumulators
+	"$sum": true, "$avg": true, "$min": true, "$max": true, "$first": true, "$last": true,
+	"$push": true, "$addToSet": true, "$count": true,
+	// Arithmetic and conditional
+	"$add": true, "$subtract": true, "$multiply": true, "$divide": true, "$round": true, "$abs": true,
+	"$cond": true, "$ifNull": true, "$switch": true,
+	// Dates and strings
+	"$year": true, "$month": true, "$week": true, "$dayOfMonth": true, "$dayOfWeek": true,
+	"$dateToString": true, "$toUpper": true, "$toLower": true, "$concat": true,
+	// Arrays
+	"$arrayElemAt": true, "$filter": true, "$map": true, "$setUnion": true,
+}
+
+// QuerySpec is the aggregation the AI generated for a natural-language question
+type QuerySpec struct {
+	Collection  string          `json:"collection"`
+	Pipeline    json.RawMessage `json:"pipeline"`
+	Explanation string          `json:"explanation"`
+}
+
+// GenerateQuerySpec asks the AI to translate a question into an aggregation and validates
+// it against the collection, stage and operator whitelists. Returns the spec as generated
+// along with the pipeline ready to run.
+func GenerateQuerySpec(ctx context.Context, question string) (*QuerySpec, bson.A, error) {
+	userPrompt := fmt.Sprintf("Today's date: %s\nQuestion: %s", time.Now().Format("2006-01-02"), question)
+
+	response, err := generateCompletion(ctx, NaturalLanguageQuerySystemPrompt, userPrompt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var spec QuerySpec
+	if err := json.Unmarshal([]byte(extractJSON(response)), &spec); err != nil {
+		return nil, nil, &AIError{Message: "AI returned an unreadable query", Cause: err}
+	}
+
+	pipeline, err := validateQuerySpec(&spec)
+	if err != nil {
+		return &spec, nil, err
+	}
+
+	return &spec, pipeline, nil
+}
+
+// validateQuerySpec parses the generated pipeline as extended JSON (so {"$date": ...} becomes a
+// real date) and rejects anything outside the whitelists. Results are always capped at
+// maxQueryResults, and customer passwords are stripped before any other stage runs.
+func validateQuerySpec(spec *QuerySpec) (bson.A, error) {
+	if !queryCollections[spec.Collection] {
+		return nil, &AIError{Message: "query uses a collection that is not allowed: " + spec.Collection}
+	}
+
+	var wrapper struct {
+		Pipeline bson.A `bson:"pipeline"`
+	}
+	document := append(append([]byte(`{"pipeline":`), spec.Pipeline...), '}')
+	if err := bson.UnmarshalExtJSON(document, false, &wrapper); err != nil {
+		return nil, &AIError{Message: "query pipeline is not valid", Cause: err}
+	}
+	if len(wrapper.Pipeline) == 0 {
+		return nil, &AIError{Message: "query pipeline is empty"}
+	}
+
+	for _, raw := range wrapper.Pipeline {
+		stage, ok := raw.(bson.D)
+		if !ok || len(stage) != 1 {
+			return nil, &AIError{Message: "each pipeline stage must be an object with a single stage operator"}
+		}
+		if !queryStages[stage[0].Key] {
+			return nil, &AIError{Message: "query uses a stage that is not allowed: " + stage[0].Key}
+		}
+		if stage[0].Key == "$lookup" {
+			if err := validateLookup(stage[0].Value); err != nil {
+				return nil, err
+			}
+		}
+		if err := validateQueryOperators(stage[0].Value); err != nil {
+			return nil, err
+		}
+	}
+
+	pipeline := bson.A{}
+	if spec.Collection == "customers" {
+		pipeline = append(pipeline, bson.D{{Key: "$unset", Value: "password"}})
+	}
+	pipeline = append(pipeline, wrapper.Pipeline...)
+	pipeline = append(pipeline, bson.D{{Key: "$limit", Value: maxQueryResults}})
+
+	return pipeline, nil
+}
+
+// validateLookup only allows joins against whitelisted collections
+func validateLookup(value interface{}) error {
+	lookup, ok := value.(bson.D)
+	if !ok {
+		return &AIError{Message: "$lookup must be an object"}
+	}
+	for _, elem := range lookup {
+		if elem.Key == "from" {
+			from, _ := elem.Value.(string)
+			if !queryLookupCollections[from] {
+				return &AIError{Message: "$lookup joins a collection that is not allowed: " + from}
+			}
+			return nil
+		}
+	}
+	return &AIError{Message: "$lookup must specify a collection"}
+}
+
+// validateQueryOperators walks a stage body and rejects any operator outside the whitelist
+func validateQueryOperators(value interface{}) error {
+	switch v := value.(type) {
+	case bson.D:
+		for _, elem := range v {
+			if strings.HasPrefix(elem.Key, "$") && !queryOperators[elem.Key] {
+				return &AIError{Message: "query uses an operator that is not allowed: " + elem.Key}
+			}
+			if err := validateQueryOperators(elem.Value); err != nil {
+				return err
+			}
+		}
+	case bson.A:
+		for _, item := range v {
+			if err := validateQueryOperators(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -2,11 +2,17 @@ package ai
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
 	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
 )
 
 // AIReportResponse represents the structure of AI-generated reports
@@ -15,6 +21,7 @@ type AIReportResponse struct {
 	Data        ReportData `json:"data"`
 	GeneratedAt time.Time  `json:"generated_at"`
 	AIEnabled   bool       `json:"ai_enabled"`
+	AICache     string     `json:"ai_cache,omitempty"` // HIT, MISS or REFRESHED
 }
 
 type ReportData struct {
@@ -24,163 +31,201 @@ type ReportData struct {
 	Error      string      `json:"error,omitempty"`
 }
 
-// GenerateSalesReport generates AI-powered insights from sales analytics data
-func GenerateSalesReport(ctx context.Context, startDate, endDate string) (*AIReportResponse, error) {
-	// Fetch sales data using existing mongo functions
-	// Default to daily grouping if no specific grouping is needed
-	groupBy := "day"
-	salesData, err := mongo.GetSalesAnalytics(startDate, endDate, groupBy)
-	if err != nil {
-		return &AIReportResponse{
-			Status:      "error",
-			Data:        ReportData{Error: "Failed to fetch sales data: " + err.Error()},
-			GeneratedAt: time.Now(),
-			AIEnabled:   IsEnabled(),
-		}, err
-	}
+// reportRequest describes one AI report run: the data it analyzes and how to prompt for it
+type reportRequest struct {
+	Type         string   // cache namespace, e.g. "sales"
+	Label        string   // used in summaries, e.g. "top products"
+	Params       []string // request parameters that shaped RawData
+	RawData      interface{}
+	SystemPrompt string
+	UserPrompt   string
+	Refresh      bool // skip the cached insights and regenerate
+}
 
+// cacheKey identifies a report by type, parameters and a fingerprint of the data analyzed,
+// so cached insights are reused only while the underlying data is unchanged
+func (r *reportRequest) cacheKey() string {
+	dataJSON, _ := json.Marshal(r.RawData)
+	fingerprint := sha256.Sum256(dataJSON)
+	return fmt.Sprintf("%s:%s:%s", r.Type, strings.Join(r.Params, ":"), hex.EncodeToString(fingerprint[:8]))
+}
+
+// buildReport produces the AI report for req, serving cached insights when the same data
+// was analyzed recently. When onToken is set the AI output is streamed through it; cached
+// insights are passed to onToken in one piece.
+func buildReport(ctx context.Context, req reportRequest, onToken func(string)) *AIReportResponse {
 	response := &AIReportResponse{
 		Status:      "success",
 		GeneratedAt: time.Now(),
 		AIEnabled:   IsEnabled(),
 		Data: ReportData{
-			RawData: salesData,
-			Summary: "Sales data retrieved successfully",
+			RawData: req.RawData,
+			Summary: "Raw " + req.Label + " data (AI insights unavailable)",
 		},
 	}
 
-	// Generate AI insights if service is enabled
-	if IsEnabled() {
-		userPrompt := formatSalesDataPrompt(salesData)
-		aiInsights, err := generateCompletion(ctx, SalesReportSystemPrompt, userPrompt)
-		if err != nil {
-			response.Data.Error = "AI analysis failed: " + err.Error()
-		} else {
-			response.Data.AIInsights = aiInsights
-			response.Data.Summary = "AI-generated sales insights and recommendations"
+	if !IsEnabled() {
+		return response
+	}
+
+	key := req.cacheKey()
+	if !req.Refresh {
+		if cached, found, err := redis.GetCachedAIReport(ctx, key); err != nil {
+			slog.WarnContext(ctx, "Failed to read AI report cache", "key", key, "error", err)
+		} else if found {
+			var cachedResponse AIReportResponse
+			if err := json.Unmarshal(cached, &cachedResponse); err == nil {
+				cachedResponse.AICache = "HIT"
+				if onToken != nil {
+					onToken(cachedResponse.Data.AIInsights)
+				}
+				return &cachedResponse
+			}
 		}
+	}
+
+	var aiInsights string
+	var err error
+	if onToken != nil {
+		aiInsights, err = streamCompletion(ctx, req.SystemPrompt, req.UserPrompt, onToken)
 	} else {
-		response.Data.Summary = "Raw sales data (AI insights unavailable)"
+		aiInsights, err = generateCompletion(ctx, req.SystemPrompt, req.UserPrompt)
+	}
+	if err != nil {
+		response.Data.Error = "AI analysis failed: " + err.Error()
+		return response
 	}
 
-	return response, nil
-}
+	response.Data.AIInsights = aiInsights
+	response.Data.Summary = "AI-generated " + req.Label + " insights and recommendations"
+	response.GeneratedAt = time.Now()
 
-// GenerateCustomerInsights generates AI-powered customer segmentation analysis
-func GenerateCustomerInsights(ctx context.Context) (*AIReportResponse, error) {
-	// Fetch customer segments using existing mongo functions
-	customerData, err := mongo.GetCustomerSpendingSegments(ctx)
-	if err != nil {
-		return &AIReportResponse{
-			Status:      "error",
-			Data:        ReportData{Error: "Failed to fetch customer data: " + err.Error()},
-			GeneratedAt: time.Now(),
-			AIEnabled:   IsEnabled(),
-		}, err
+	// Only successful analyses are cached. The AI call may outlast ctx's deadline, so the
+	// write gets its own timeout.
+	cacheCtx, cancel := global.GetDefaultTimer()
+	defer cancel()
+	if err := redis.CacheAIReport(cacheCtx, key, response); err != nil {
+		slog.WarnContext(ctx, "Failed to cache AI report", "key", key, "error", err)
 	}
 
-	response := &AIReportResponse{
-		Status:      "success",
+	response.AICache = "MISS"
+	if req.Refresh {
+		response.AICache = "REFRESHED"
+	}
+	return response
+}
+
+// reportDataError builds the error response returned when report data cannot be loaded
+func reportDataError(label string, err error) *AIReportResponse {
+	return &AIReportResponse{
+		Status:      "error",
+		Data:        ReportData{Error: "Failed to fetch " + label + " data: " + err.Error()},
 		GeneratedAt: time.Now(),
 		AIEnabled:   IsEnabled(),
-		Data: ReportData{
-			RawData: customerData,
-			Summary: "Customer segmentation data retrieved successfully",
-		},
 	}
+}
 
-	if IsEnabled() {
-		userPrompt := formatCustomerDataPrompt(customerData)
-		aiInsights, err := generateCompletion(ctx, CustomerInsightsSystemPrompt, userPrompt)
-		if err != nil {
-			response.Data.Error = "AI analysis failed: " + err.Error()
-		} else {
-			response.Data.AIInsights = aiInsights
-			response.Data.Summary = "AI-generated customer insights and recommendations"
-		}
-	} else {
-		response.Data.Summary = "Raw customer data (AI insights unavailable)"
+// GenerateSalesReport generates AI-powered insights from sales analytics data
+func GenerateSalesReport(ctx context.Context, startDate, endDate string, refresh bool) (*AIReportResponse, error) {
+	req, err := salesReportRequest(ctx, startDate, endDate, refresh)
+	if err != nil {
+		return reportDataError("sales", err), err
 	}
+	return buildReport(ctx, *req, nil), nil
+}
 
-	return response, nil
+// GenerateCustomerInsights generates AI-powered customer segmentation analysis
+func GenerateCustomerInsights(ctx context.Context, refresh bool) (*AIReportResponse, error) {
+	req, err := customerInsightsRequest(ctx, refresh)
+	if err != nil {
+		return reportDataError("customer", err), err
+	}
+	return buildReport(ctx, *req, nil), nil
 }
 
 // GenerateInventoryReport generates AI-powered inventory analysis
-func GenerateInventoryReport(ctx context.Context, alertsOnly bool) (*AIReportResponse, error) {
-	// Fetch inventory data using existing mongo functions
-	inventoryData, err := mongo.GetInventoryStatus(alertsOnly)
+func GenerateInventoryReport(ctx context.Context, alertsOnly, refresh bool) (*AIReportResponse, error) {
+	req, err := inventoryReportRequest(ctx, alertsOnly, refresh)
 	if err != nil {
-		return &AIReportResponse{
-			Status:      "error",
-			Data:        ReportData{Error: "Failed to fetch inventory data: " + err.Error()},
-			GeneratedAt: time.Now(),
-			AIEnabled:   IsEnabled(),
-		}, err
+		return reportDataError("inventory", err), err
 	}
+	return buildReport(ctx, *req, nil), nil
+}
 
-	response := &AIReportResponse{
-		Status:      "success",
-		GeneratedAt: time.Now(),
-		AIEnabled:   IsEnabled(),
-		Data: ReportData{
-			RawData: inventoryData,
-			Summary: "Inventory status data retrieved successfully",
-		},
+// GenerateTopProductsAnalysis generates AI-powered top products analysis
+func GenerateTopProductsAnalysis(ctx context.Context, limit int, sortBy, startDate, endDate string, refresh bool) (*AIReportResponse, error) {
+	req, err := topProductsRequest(ctx, limit, sortBy, startDate, endDate, refresh)
+	if err != nil {
+		return reportDataError("top products", err), err
 	}
+	return buildReport(ctx, *req, nil), nil
+}
 
-	if IsEnabled() {
-		userPrompt := formatInventoryDataPrompt(inventoryData, alertsOnly)
-		aiInsights, err := generateCompletion(ctx, InventoryReportSystemPrompt, userPrompt)
-		if err != nil {
-			response.Data.Error = "AI analysis failed: " + err.Error()
-		} else {
-			response.Data.AIInsights = aiInsights
-			response.Data.Summary = "AI-generated inventory insights and recommendations"
-		}
-	} else {
-		response.Data.Summary = "Raw inventory data (AI insights unavailable)"
+// salesReportRequest loads daily sales for the sales report
+func salesReportRequest(ctx context.Context, startDate, endDate string, refresh bool) (*reportRequest, error) {
+	salesData, err := mongo.GetSalesAnalytics(ctx, startDate, endDate, "day")
+	if err != nil {
+		return nil, err
 	}
-
-	return response, nil
+	return &reportRequest{
+		Type:         "sales",
+		Label:        "sales",
+		Params:       []string{startDate, endDate},
+		RawData:      salesData,
+		SystemPrompt: SalesReportSystemPrompt,
+		UserPrompt:   formatSalesDataPrompt(salesData),
+		Refresh:      refresh,
+	}, nil
 }
 
-// GenerateTopProductsAnalysis generates AI-powered top products analysis
-func GenerateTopProductsAnalysis(ctx context.Context, limit int, sortBy, startDate, endDate string) (*AIReportResponse, error) {
-	// Fetch top products data using existing mongo functions
-	topProducts, err := mongo.GetTopProductsByRevenue(limit, sortBy, startDate, endDate)
+// customerInsightsRequest loads customer segments for the customer insights report
+func customerInsightsRequest(ctx context.Context, refresh bool) (*reportRequest, error) {
+	customerData, err := mongo.GetCustomerSpendingSegments(ctx)
 	if err != nil {
-		return &AIReportResponse{
-			Status:      "error",
-			Data:        ReportData{Error: "Failed to fetch top products data: " + err.Error()},
-			GeneratedAt: time.Now(),
-			AIEnabled:   IsEnabled(),
-		}, err
+		return nil, err
 	}
+	return &reportRequest{
+		Type:         "customers",
+		Label:        "customer",
+		RawData:      customerData,
+		SystemPrompt: CustomerInsightsSystemPrompt,
+		UserPrompt:   formatCustomerDataPrompt(customerData),
+		Refresh:      refresh,
+	}, nil
+}
 
-	response := &AIReportResponse{
-		Status:      "success",
-		GeneratedAt: time.Now(),
-		AIEnabled:   IsEnabled(),
-		Data: ReportData{
-			RawData: topProducts,
-			Summary: "Top products data retrieved successfully",
-		},
+// inventoryReportRequest loads inventory status for the inventory report
+func inventoryReportRequest(ctx context.Context, alertsOnly, refresh bool) (*reportRequest, error) {
+	inventoryData, err := mongo.GetInventoryStatus(ctx, alertsOnly)
+	if err != nil {
+		return nil, err
 	}
+	return &reportRequest{
+		Type:         "inventory",
+		Label:        "inventory",
+		Params:       []string{fmt.Sprint(alertsOnly)},
+		RawData:      inventoryData,
+		SystemPrompt: InventoryReportSystemPrompt,
+		UserPrompt:   formatInventoryDataPrompt(inventoryData, alertsOnly),
+		Refresh:      refresh,
+	}, nil
+}
 
-	if IsEnabled() {
-		userPrompt := formatTopProductsDataPrompt(topProducts, sortBy, limit)
-		aiInsights, err := generateCompletion(ctx, TopProductsSystemPrompt, userPrompt)
-		if err != nil {
-			response.Data.Error = "AI analysis failed: " + err.Error()
-		} else {
-			response.Data.AIInsights = aiInsights
-			response.Data.Summary = "AI-generated top products insights and recommendations"
-		}
-	} else {
-		response.Data.Summary = "Raw top products data (AI insights unavailable)"
+// topProductsRequest loads top products for the product analysis report
+func topProductsRequest(ctx context.Context, limit int, sortBy, startDate, endDate string, refresh bool) (*reportRequest, error) {
+	topProducts, err := mongo.GetTopProductsByRevenue(ctx, limit, sortBy, startDate, endDate)
+	if err != nil {
+		return nil, err
 	}
-
-	return response, nil
+	return &reportRequest{
+		Type:         "top-products",
+		Label:        "top products",
+		Params:       []string{fmt.Sprint(limit), sortBy, startDate, endDate},
+		RawData:      topProducts,
+		SystemPrompt: TopProductsSystemPrompt,
+		UserPrompt:   formatTopProductsDataPrompt(topProducts, sortBy, limit),
+		Refresh:      refresh,
+	}, nil
 }
 
 // Helper functions to format data for AI prompts
@@ -0,0 +1,203 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("julianmorley.ca/con-plar/prog2270/pkg/ai")
+
+// ErrAIUnavailable is returned without calling the provider while the circuit breaker is open
+var ErrAIUnavailable = errors.New("AI service is temporarily unavailable")
+
+// resilienceConfig controls retries, timeouts and the circuit breaker around provider calls
+type resilienceConfig struct {
+	CallTimeout      time.Duration // hard limit on a single provider attempt
+	MaxRetries       int           // extra attempts after a transient failure
+	BaseBackoff      time.Duration // delay before the first retry, doubled for each one after
+	FailureThreshold int           // consecutive failed calls that open the breaker
+	Cooldown         time.Duration // how long the breaker stays open before a trial call
+}
+
+// resilience is set by InitializeAIService
+var resilience = resilienceConfig{CallTimeout: 30 * time.Second, MaxRetries: 2, BaseBackoff: 500 * time.Millisecond, FailureThreshold: 5, Cooldown: time.Minute}
+
+// circuitBreaker stops provider calls after repeated failures, then lets a single
+// trial call through once the cooldown has passed
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trialing  bool
+}
+
+var breaker = &circuitBreaker{}
+
+// allow reports whether a call may go to the provider
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < resilience.FailureThreshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.trialing {
+		return false
+	}
+	b.trialing = true
+	return true
+}
+
+// IsCircuitOpen reports whether the breaker is currently rejecting provider calls
+func IsCircuitOpen() bool {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	return breaker.failures >= resilience.FailureThreshold && time.Now().Before(breaker.openUntil)
+}
+
+// release ends an allowed call without judging the provider's health
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialing = false
+}
+
+// record updates the breaker with the outcome of an allowed call
+func (b *circuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialing = false
+	if !failed {
+		if b.failures >= resilience.FailureThreshold {
+			slog.Info("AI circuit breaker closed")
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= resilience.FailureThreshold {
+		b.openUntil = time.Now().Add(resilience.Cooldown)
+		slog.Warn("AI circuit breaker open", "cooldown", resilience.Cooldown.String(), "consecutive_failures", b.failures)
+	}
+}
+
+// withResilience runs call against the provider with a per-attempt timeout, retrying
+// transient failures with exponential backoff. Calls fail fast with ErrAIUnavailable
+// while the circuit breaker is open. retryable is consulted before each retry so
+// callers can refuse once partial output has been delivered. Each call is recorded as
+// an operation span carrying the provider name and attempt count.
+func withResilience[T any](ctx context.Context, operation string, call func(ctx context.Context) (T, error), retryable func() bool) (result T, err error) {
+	ctx, span := tracer.Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("gen_ai.system", provider.Name())))
+	attempts := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("ai.attempts", attempts))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	if !breaker.allow() {
+		span.SetAttributes(attribute.Bool("ai.breaker_open", true))
+		return result, ErrAIUnavailable
+	}
+
+	ctx, cancel := detachDeadline(ctx)
+	defer cancel()
+
+	for attempt := 0; ; attempt++ {
+		attempts++
+		result, err = callWithTimeout(ctx, call)
+		if err == nil || ctx.Err() != nil || !isTransient(err) || attempt >= resilience.MaxRetries || !retryable() {
+			break
+		}
+
+		backoff := resilience.BaseBackoff << attempt
+		slog.WarnContext(ctx, "AI call failed, retrying", "attempt", attempt+1, "max_attempts", resilience.MaxRetries+1, "backoff", backoff.String(), "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			breaker.release()
+			return result, ctx.Err()
+		}
+	}
+
+	// A caller giving up says nothing about the provider's health, and a rejected
+	// request still shows the provider is reachable
+	if ctx.Err() != nil {
+		breaker.release()
+	} else {
+		breaker.record(err != nil && isTransient(err))
+	}
+	return result, err
+}
+
+// detachDeadline returns a context that ignores ctx's deadline (such as GetDefaultTimer's),
+// so AI calls are bounded by CallTimeout alone, but is still cancelled along with ctx
+func detachDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			cancel()
+		}
+	})
+
+	return detached, func() {
+		stop()
+		cancel()
+	}
+}
+
+// callWithTimeout runs one attempt under its own CallTimeout
+func callWithTimeout[T any](ctx context.Context, call func(ctx context.Context) (T, error)) (T, error) {
+	callCtx, cancel := context.WithTimeout(ctx, resilience.CallTimeout)
+	defer cancel()
+
+	return call(callCtx)
+}
+
+// isTransient reports whether err is worth retrying: timeouts, network failures,
+// rate limiting and server errors
+func isTransient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return isTransientStatus(apiErr.StatusCode)
+	}
+	var ollamaErr *ollamaStatusError
+	if errors.As(err, &ollamaErr) {
+		return isTransientStatus(ollamaErr.StatusCode)
+	}
+
+	return false
+}
+
+func isTransientStatus(statusCode int) bool {
+	return statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
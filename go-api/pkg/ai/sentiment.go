@@ -0,0 +1,59 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// sentimentResult is a single entry of the AI sentiment response
+type sentimentResult struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+	Label string  `json:"label"`
+}
+
+// AnalyzeReviewSentiment scores a batch of reviews in a single AI request.
+// Reviews missing from the response or with an invalid label are left out of the result.
+func AnalyzeReviewSentiment(ctx context.Context, reviews []models.Review) (map[bson.ObjectID]*models.ReviewSentiment, error) {
+	var prompt strings.Builder
+	for _, review := range reviews {
+		fmt.Fprintf(&prompt, "ID: %s\nRating: %d/5\nTitle: %s\nComment: %s\n\n",
+			review.ID.Hex(), review.Rating, review.Title, review.Comment)
+	}
+
+	response, err := generateCompletion(ctx, ReviewSentimentSystemPrompt, prompt.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var results []sentimentResult
+	if err := json.Unmarshal([]byte(extractJSON(response)), &results); err != nil {
+		return nil, &AIError{Message: "AI returned an unreadable sentiment result", Cause: err}
+	}
+
+	now := time.Now()
+	sentiments := make(map[bson.ObjectID]*models.ReviewSentiment, len(results))
+	for _, result := range results {
+		reviewID, err := bson.ObjectIDFromHex(result.ID)
+		if err != nil {
+			continue
+		}
+		if result.Label != "positive" && result.Label != "neutral" && result.Label != "negative" {
+			continue
+		}
+		sentiments[reviewID] = &models.ReviewSentiment{
+			Score:      math.Round(math.Max(-1, math.Min(1, result.Score))*100) / 100,
+			Label:      result.Label,
+			AnalyzedAt: now,
+		}
+	}
+
+	return sentiments, nil
+}
@@ -0,0 +1,39 @@
+package ai
+
+import "context"
+
+// StreamSalesReport generates the sales report, streaming AI tokens to onToken as they arrive
+func StreamSalesReport(ctx context.Context, startDate, endDate string, refresh bool, onToken func(string)) (*AIReportResponse, error) {
+	req, err := salesReportRequest(ctx, startDate, endDate, refresh)
+	if err != nil {
+		return nil, err
+	}
+	return buildReport(ctx, *req, onToken), nil
+}
+
+// StreamCustomerInsights generates customer insights, streaming AI tokens to onToken as they arrive
+func StreamCustomerInsights(ctx context.Context, refresh bool, onToken func(string)) (*AIReportResponse, error) {
+	req, err := customerInsightsRequest(ctx, refresh)
+	if err != nil {
+		return nil, err
+	}
+	return buildReport(ctx, *req, onToken), nil
+}
+
+// StreamInventoryReport generates the inventory report, streaming AI tokens to onToken as they arrive
+func StreamInventoryReport(ctx context.Context, alertsOnly, refresh bool, onToken func(string)) (*AIReportResponse, error) {
+	req, err := inventoryReportRequest(ctx, alertsOnly, refresh)
+	if err != nil {
+		return nil, err
+	}
+	return buildReport(ctx, *req, onToken), nil
+}
+
+// StreamTopProductsAnalysis generates the top products analysis, streaming AI tokens to onToken as they arrive
+func StreamTopProductsAnalysis(ctx context.Context, limit int, sortBy, startDate, endDate string, refresh bool, onToken func(string)) (*AIReportResponse, error) {
+	req, err := topProductsRequest(ctx, limit, sortBy, startDate, endDate, refresh)
+	if err != nil {
+		return nil, err
+	}
+	return buildReport(ctx, *req, onToken), nil
+}
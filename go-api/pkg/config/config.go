@@ -0,0 +1,501 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/scheduler"
+)
+
+// Config holds every setting the API reads from the environment. It is loaded and
+// validated once at startup and handed to each package's initializer.
+type Config struct {
+	Env           string // "production" enables release mode and JSON logs by default
+	Server        ServerConfig
+	Log           LogConfig
+	Tracing       TracingConfig
+	Errors        ErrorReportingConfig
+	Mongo         MongoConfig
+	Redis         RedisConfig
+	AI            AIConfig
+	Workers       WorkersConfig
+	Scheduler     SchedulerConfig
+	Pricing       PricingConfig
+	Payments      PaymentsConfig
+	Shipping      ShippingConfig
+	Notifications NotificationsConfig
+}
+
+// ServerConfig controls the HTTP server
+type ServerConfig struct {
+	Port          string
+	AdminAPIKey   string        // shared admin key; empty leaves only personal admin keys
+	CORSOrigins   []string      // origins allowed to call the API from a browser
+	ShutdownGrace time.Duration // how long in-flight requests may run after a shutdown signal
+	// ExportSigningKey signs customer data export and report archive download links;
+	// empty uses a random key, so links only work on the instance that signed them until
+	// it restarts
+	ExportSigningKey string
+	ExportLinkTTL    time.Duration // how long a signed download link works
+
+	AdminInviteURL string        // page that accepts admin invitations; the token is added as ?token=
+	AdminInviteTTL time.Duration // how long an admin invitation can be accepted
+}
+
+// LogConfig controls the slog output
+type LogConfig struct {
+	Level  string // debug, info, warn or error
+	Format string // json or text
+}
+
+// TracingConfig controls OpenTelemetry export
+type TracingConfig struct {
+	ServiceName  string
+	OTLPEndpoint string // empty disables export; the exporter reads its other options from the OTEL_ variables
+}
+
+// ErrorReportingConfig controls forwarding of recovered panics
+type ErrorReportingConfig struct {
+	SentryDSN string // empty disables Sentry; panics are still logged
+}
+
+// readPreferences are the MongoDB read preference modes, lowercased
+var readPreferences = []string{"primary", "primarypreferred", "secondary", "secondarypreferred", "nearest"}
+
+// MongoConfig locates the MongoDB database
+type MongoConfig struct {
+	URI      string
+	Database string
+	// SearchMode is "text" for text indexes or "atlas" for Atlas Search with fuzzy
+	// matching, facets and highlights
+	SearchMode       string
+	AtlasSearchIndex string
+	// SearchFuzziness is the default number of typos (0-2) search tolerates per term
+	SearchFuzziness int
+	// SchemaValidation is what MongoDB does with a product, order, customer or review
+	// write that breaks its collection's $jsonSchema: "error" rejects it, "warn" only
+	// logs it and "off" removes the validators
+	SchemaValidation string
+
+	// Each kind of database work has its own deadline, and reports and bulk reads may
+	// use a secondary (primary, primarypreferred, secondary, secondarypreferred or nearest)
+	TransactionTimeout      time.Duration // order, payment and stock transactions
+	AnalyticsTimeout        time.Duration // analytics aggregations and AI report data
+	AnalyticsReadPreference string
+	BulkTimeout             time.Duration // customer data exports and seeding
+	BulkReadPreference      string
+}
+
+// RedisConfig locates the Redis server and sets how each kind of data is cached
+type RedisConfig struct {
+	Address          string
+	Password         string
+	Products         CacheConfig
+	Categories       CacheConfig
+	Analytics        CacheConfig
+	AIReportCacheTTL time.Duration
+}
+
+// Cache strategies
+const (
+	CacheAside   = "cache-aside"   // fill the cache on a read miss and drop entries when the data changes
+	WriteThrough = "write-through" // fill on a read miss and store the new value when the data changes
+	NoCache      = "none"          // always read MongoDB
+)
+
+// CacheConfig sets how one kind of data is cached and for how long
+type CacheConfig struct {
+	Strategy string
+	TTL      time.Duration
+}
+
+// AIConfig selects the AI provider and controls retries and the circuit breaker
+type AIConfig struct {
+	Provider        string // azure, openai or ollama
+	Azure           AzureConfig
+	OpenAI          OpenAIConfig
+	Ollama          OllamaConfig
+	CallTimeout     time.Duration
+	MaxRetries      int
+	RetryBackoff    time.Duration
+	BreakerFailures int
+	BreakerCooldown time.Duration
+}
+
+type AzureConfig struct {
+	Endpoint            string
+	APIKey              string
+	Deployment          string
+	EmbeddingDeployment string
+}
+
+type OpenAIConfig struct {
+	APIKey         string
+	BaseURL        string
+	Model          string
+	EmbeddingModel string
+}
+
+type OllamaConfig struct {
+	Host           string
+	Model          string
+	EmbeddingModel string
+}
+
+// WorkersConfig sets how often the background workers run
+type WorkersConfig struct {
+	CartAbandonmentIdle     time.Duration
+	CartAbandonmentScan     time.Duration
+	CartAbandonmentWebhook  string
+	CartPersistSweep        time.Duration
+	ReportSchedulerScan     time.Duration
+	EventsWebhook           string
+	OutboxRelayInterval     time.Duration
+	OutboxMaxAttempts       int
+	CustomerExportInterval  time.Duration
+	NotificationInterval    time.Duration
+	NotificationMaxAttempts int
+	ImportInterval          time.Duration
+	ReportArchiveInterval   time.Duration
+}
+
+// ScheduledTaskConfig turns a recurring task on or off and sets when it runs
+type ScheduledTaskConfig struct {
+	Enabled  bool
+	Schedule string // cron expression or descriptor, see scheduler.Parse
+}
+
+// SchedulerConfig sets which recurring tasks run and when
+type SchedulerConfig struct {
+	CacheWarm           ScheduledTaskConfig
+	CacheWarmLimit      int
+	CartAbandonment     ScheduledTaskConfig
+	LowStockScan        ScheduledTaskConfig
+	LowStockThreshold   int
+	AnalyticsPrecompute ScheduledTaskConfig
+	SuggestIndex        ScheduledTaskConfig // also runs at startup to fill the autocomplete index
+	AdminDigest         ScheduledTaskConfig
+	AdminDigestEmails   []string // recipients of the weekly digest; none turns it off
+}
+
+// PricingConfig sets the province taxed when totalling carts and orders and the loyalty
+// program's earn and redeem rates
+type PricingConfig struct {
+	// DefaultTaxProvince is taxed for carts without a shipping province and for shipping
+	// addresses whose province has no tax rule
+	DefaultTaxProvince string
+	// FreeShippingThreshold is the subtotal from which standard shipping is free
+	FreeShippingThreshold float64
+	// LoyaltyPointsPerDollar are earned per dollar of a delivered order's discounted subtotal
+	LoyaltyPointsPerDollar int
+	// LoyaltyPointValue is the discount in dollars one redeemed point is worth
+	LoyaltyPointValue float64
+}
+
+// PaymentsConfig connects checkout to Stripe for cards and PayPal
+type PaymentsConfig struct {
+	StripeSecretKey     string // empty disables card payments and refunds
+	StripeWebhookSecret string // signing secret of the webhook endpoint, whsec_...
+	StripeAPIBase       string
+	PayPalClientID      string // empty disables PayPal payments and refunds
+	PayPalClientSecret  string
+	PayPalWebhookID     string // ID of the webhook registered with PayPal, used to verify events
+	PayPalAPIBase       string // the sandbox by default; https://api-m.paypal.com in production
+	Currency            string // ISO currency code charged for orders, lowercase
+}
+
+// NotificationsConfig connects the notification channels. A channel without its settings
+// dead-letters its notifications.
+type NotificationsConfig struct {
+	SMTPHost         string
+	SMTPPort         int
+	SMTPUsername     string
+	SMTPPassword     string
+	SMTPFrom         string // sender address of every email
+	TwilioAccountSID string
+	TwilioAuthToken  string
+	TwilioFromNumber string
+	TwilioAPIBase    string
+
+	// UnsubscribeSigningKey signs the unsubscribe links in customer notifications; empty
+	// uses a random key, so links only work on this instance until it restarts
+	UnsubscribeSigningKey string
+	PublicBaseURL         string // where customers reach the API, the start of every unsubscribe link
+}
+
+// ShippingConfig sets where orders ship from and connects shipping quotes to Canada Post
+type ShippingConfig struct {
+	OriginProvince     string
+	OriginPostalCode   string
+	DefaultWeightGrams int    // used for products without a weight
+	CanadaPostUsername string // empty prices shipping with the built-in rate table
+	CanadaPostPassword string
+	CanadaPostCustomer string // customer number on the Canada Post account
+	CanadaPostAPIBase  string // the development gateway by default; https://soa-gw.canadapost.ca in production
+}
+
+// Load reads the configuration from the environment, applying defaults for unset
+// variables. It returns every invalid or missing value in a single error.
+func Load() (*Config, error) {
+	l := &loader{}
+	cfg := &Config{Env: l.string("ENV", "development")}
+
+	defaultLogFormat := "text"
+	if cfg.Env == "production" {
+		defaultLogFormat = "json"
+	}
+
+	cfg.Server = ServerConfig{
+		Port:          l.string("PORT", "8000"),
+		AdminAPIKey:   l.string("ADMIN_API_KEY", ""),
+		CORSOrigins:   l.list("CORS_ALLOWED_ORIGINS", "http://localhost:3000,http://localhost:5173,https://plar-conestoga-prog2270.julianmorley.ca"),
+		ShutdownGrace: l.seconds("SHUTDOWN_GRACE_SECONDS", 15),
+
+		ExportSigningKey: l.string("EXPORT_SIGNING_KEY", ""),
+		ExportLinkTTL:    l.seconds("EXPORT_LINK_TTL_SECONDS", 900),
+
+		AdminInviteURL: l.string("ADMIN_INVITE_URL", "http://localhost:5173/admin/accept-invite"),
+		AdminInviteTTL: l.seconds("ADMIN_INVITE_TTL_SECONDS", 7*24*60*60),
+	}
+	cfg.Log = LogConfig{
+		Level:  l.oneOf("LOG_LEVEL", "info", "debug", "info", "warn", "warning", "error"),
+		Format: l.oneOf("LOG_FORMAT", defaultLogFormat, "json", "text"),
+	}
+	cfg.Tracing = TracingConfig{
+		ServiceName:  l.string("OTEL_SERVICE_NAME", "plar-go-api"),
+		OTLPEndpoint: l.string("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", l.string("OTEL_EXPORTER_OTLP_ENDPOINT", "")),
+	}
+	cfg.Errors = ErrorReportingConfig{
+		SentryDSN: l.string("SENTRY_DSN", ""),
+	}
+	searchFuzziness, _ := strconv.Atoi(l.oneOf("SEARCH_FUZZINESS", "1", "0", "1", "2"))
+	cfg.Mongo = MongoConfig{
+		URI:      l.required("MONGODB_URI"),
+		Database: l.string("MONGODB_DATABASE", "plar_prog2270"),
+
+		SearchMode:       l.oneOf("SEARCH_MODE", "text", "text", "atlas"),
+		AtlasSearchIndex: l.string("ATLAS_SEARCH_INDEX", "default"),
+		SearchFuzziness:  searchFuzziness,
+		SchemaValidation: l.oneOf("MONGODB_SCHEMA_VALIDATION", "error", "error", "warn", "off"),
+
+		TransactionTimeout:      l.seconds("MONGODB_TRANSACTION_TIMEOUT_SECONDS", 5),
+		AnalyticsTimeout:        l.seconds("MONGODB_ANALYTICS_TIMEOUT_SECONDS", 30),
+		AnalyticsReadPreference: l.oneOf("MONGODB_ANALYTICS_READ_PREFERENCE", "secondarypreferred", readPreferences...),
+		BulkTimeout:             l.seconds("MONGODB_BULK_TIMEOUT_SECONDS", 120),
+		BulkReadPreference:      l.oneOf("MONGODB_BULK_READ_PREFERENCE", "secondarypreferred", readPreferences...),
+	}
+	// An analytics result can't be rebuilt from the write that changed it, so analytics
+	// can't be written through
+	cfg.Redis = RedisConfig{
+		Address:          l.string("REDIS_ADDRESS", "localhost:6379"),
+		Password:         l.string("REDIS_PASSWORD", ""),
+		Products:         l.cache("PRODUCT", WriteThrough, 24*60*60, CacheAside, WriteThrough, NoCache),
+		Categories:       l.cache("CATEGORY", CacheAside, 60*60, CacheAside, WriteThrough, NoCache),
+		Analytics:        l.cache("ANALYTICS", CacheAside, 300, CacheAside, NoCache),
+		AIReportCacheTTL: l.seconds("AI_REPORT_CACHE_TTL_SECONDS", 3600),
+	}
+	cfg.AI = AIConfig{
+		Provider: l.oneOf("AI_PROVIDER", "azure", "azure", "openai", "ollama"),
+		Azure: AzureConfig{
+			Endpoint:            l.string("AZURE_OPENAI_ENDPOINT", ""),
+			APIKey:              l.string("AZURE_OPENAI_API_KEY", ""),
+			Deployment:          l.string("AZURE_OPENAI_DEPLOYMENT_NAME", "gpt-35-turbo"),
+			EmbeddingDeployment: l.string("AZURE_OPENAI_EMBEDDING_DEPLOYMENT", "text-embedding-3-small"),
+		},
+		OpenAI: OpenAIConfig{
+			APIKey:         l.string("OPENAI_API_KEY", ""),
+			BaseURL:        l.string("OPENAI_BASE_URL", ""),
+			Model:          l.string("OPENAI_MODEL", "gpt-4o-mini"),
+			EmbeddingModel: l.string("OPENAI_EMBEDDING_MODEL", "text-embedding-3-small"),
+		},
+		Ollama: OllamaConfig{
+			Host:           l.string("OLLAMA_HOST", "http://localhost:11434"),
+			Model:          l.string("OLLAMA_MODEL", "llama3.1"),
+			EmbeddingModel: l.string("OLLAMA_EMBEDDING_MODEL", "nomic-embed-text"),
+		},
+		CallTimeout:     l.seconds("AI_CALL_TIMEOUT_SECONDS", 30),
+		MaxRetries:      l.int("AI_MAX_RETRIES", 2, 0),
+		RetryBackoff:    time.Duration(l.int("AI_RETRY_BACKOFF_MS", 500, 1)) * time.Millisecond,
+		BreakerFailures: l.int("AI_BREAKER_FAILURES", 5, 1),
+		BreakerCooldown: l.seconds("AI_BREAKER_COOLDOWN_SECONDS", 60),
+	}
+	cfg.Workers = WorkersConfig{
+		CartAbandonmentIdle:     time.Duration(l.int("CART_ABANDONMENT_MINUTES", 30, 1)) * time.Minute,
+		CartAbandonmentScan:     l.seconds("CART_ABANDONMENT_SCAN_SECONDS", 60),
+		CartAbandonmentWebhook:  l.string("CART_ABANDONMENT_WEBHOOK_URL", ""),
+		CartPersistSweep:        l.seconds("CART_PERSIST_SWEEP_SECONDS", 60),
+		ReportSchedulerScan:     l.seconds("REPORT_SCHEDULER_SCAN_SECONDS", 60),
+		EventsWebhook:           l.string("EVENTS_WEBHOOK_URL", ""),
+		OutboxRelayInterval:     l.seconds("OUTBOX_RELAY_INTERVAL_SECONDS", 2),
+		OutboxMaxAttempts:       l.int("OUTBOX_MAX_ATTEMPTS", 10, 1),
+		CustomerExportInterval:  l.seconds("CUSTOMER_EXPORT_INTERVAL_SECONDS", 5),
+		NotificationInterval:    l.seconds("NOTIFICATION_INTERVAL_SECONDS", 5),
+		NotificationMaxAttempts: l.int("NOTIFICATION_MAX_ATTEMPTS", 8, 1),
+		ImportInterval:          l.seconds("IMPORT_INTERVAL_SECONDS", 2),
+		ReportArchiveInterval:   l.seconds("REPORT_ARCHIVE_INTERVAL_SECONDS", 5),
+	}
+	cfg.Scheduler = SchedulerConfig{
+		CacheWarm:           l.task("TASK_CACHE_WARM", "*/30 * * * *"),
+		CacheWarmLimit:      l.int("CACHE_WARM_LIMIT", 100, 1),
+		CartAbandonment:     l.task("TASK_CART_ABANDONMENT", fmt.Sprintf("@every %s", cfg.Workers.CartAbandonmentScan)),
+		LowStockScan:        l.task("TASK_LOW_STOCK_SCAN", "0 * * * *"),
+		LowStockThreshold:   l.int("LOW_STOCK_THRESHOLD", 10, 1),
+		AnalyticsPrecompute: l.task("TASK_ANALYTICS_PRECOMPUTE", "*/5 * * * *"),
+		SuggestIndex:        l.task("TASK_SUGGEST_INDEX", "@daily"),
+		AdminDigest:         l.task("TASK_ADMIN_DIGEST", "0 13 * * 1"),
+		AdminDigestEmails:   l.list("ADMIN_DIGEST_EMAILS", ""),
+	}
+	cfg.Pricing = PricingConfig{
+		DefaultTaxProvince:    l.oneOf("TAX_DEFAULT_PROVINCE", "ON", "AB", "BC", "MB", "NB", "NL", "NS", "NT", "NU", "ON", "PE", "QC", "SK", "YT"),
+		FreeShippingThreshold: float64(l.int("FREE_SHIPPING_THRESHOLD", 100, 0)),
+
+		LoyaltyPointsPerDollar: l.int("LOYALTY_POINTS_PER_DOLLAR", 1, 0),
+		LoyaltyPointValue:      l.rate("LOYALTY_POINT_VALUE", 0.01),
+	}
+
+	cfg.Payments = PaymentsConfig{
+		StripeSecretKey:     l.string("STRIPE_SECRET_KEY", ""),
+		StripeWebhookSecret: l.string("STRIPE_WEBHOOK_SECRET", ""),
+		StripeAPIBase:       l.string("STRIPE_API_BASE", "https://api.stripe.com"),
+		PayPalClientID:      l.string("PAYPAL_CLIENT_ID", ""),
+		PayPalClientSecret:  l.string("PAYPAL_CLIENT_SECRET", ""),
+		PayPalWebhookID:     l.string("PAYPAL_WEBHOOK_ID", ""),
+		PayPalAPIBase:       l.string("PAYPAL_API_BASE", "https://api-m.sandbox.paypal.com"),
+		Currency:            l.oneOf("PAYMENT_CURRENCY", "cad", "cad", "usd", "eur"),
+	}
+
+	cfg.Notifications = NotificationsConfig{
+		SMTPHost:         l.string("SMTP_HOST", ""),
+		SMTPPort:         l.int("SMTP_PORT", 587, 1),
+		SMTPUsername:     l.string("SMTP_USERNAME", ""),
+		SMTPPassword:     l.string("SMTP_PASSWORD", ""),
+		SMTPFrom:         l.string("SMTP_FROM", "no-reply@localhost"),
+		TwilioAccountSID: l.string("TWILIO_ACCOUNT_SID", ""),
+		TwilioAuthToken:  l.string("TWILIO_AUTH_TOKEN", ""),
+		TwilioFromNumber: l.string("TWILIO_FROM_NUMBER", ""),
+		TwilioAPIBase:    l.string("TWILIO_API_BASE", "https://api.twilio.com"),
+
+		UnsubscribeSigningKey: l.string("UNSUBSCRIBE_SIGNING_KEY", ""),
+		PublicBaseURL:         strings.TrimRight(l.string("PUBLIC_BASE_URL", "http://localhost:"+cfg.Server.Port), "/"),
+	}
+
+	cfg.Shipping = ShippingConfig{
+		OriginProvince:     l.oneOf("SHIPPING_ORIGIN_PROVINCE", "ON", "AB", "BC", "MB", "NB", "NL", "NS", "NT", "NU", "ON", "PE", "QC", "SK", "YT"),
+		OriginPostalCode:   l.string("SHIPPING_ORIGIN_POSTAL_CODE", ""),
+		DefaultWeightGrams: l.int("SHIPPING_DEFAULT_WEIGHT_GRAMS", 500, 1),
+		CanadaPostUsername: l.string("CANADA_POST_USERNAME", ""),
+		CanadaPostPassword: l.string("CANADA_POST_PASSWORD", ""),
+		CanadaPostCustomer: l.string("CANADA_POST_CUSTOMER_NUMBER", ""),
+		CanadaPostAPIBase:  l.string("CANADA_POST_API_BASE", "https://ct.soa-gw.canadapost.ca"),
+	}
+
+	if len(l.errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(l.errs...))
+	}
+	return cfg, nil
+}
+
+// loader reads environment variables, collecting an error for each invalid value
+type loader struct {
+	errs []error
+}
+
+func (l *loader) string(key, defaultValue string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func (l *loader) required(key string) string {
+	value := l.string(key, "")
+	if value == "" {
+		l.errs = append(l.errs, fmt.Errorf("%s is required", key))
+	}
+	return value
+}
+
+func (l *loader) oneOf(key, defaultValue string, allowed ...string) string {
+	value := strings.ToLower(l.string(key, defaultValue))
+	for _, option := range allowed {
+		if value == option {
+			return value
+		}
+	}
+	l.errs = append(l.errs, fmt.Errorf("%s must be one of %s, got %q", key, strings.Join(allowed, ", "), value))
+	return defaultValue
+}
+
+func (l *loader) list(key, defaultValue string) []string {
+	var values []string
+	for _, value := range strings.Split(l.string(key, defaultValue), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (l *loader) int(key string, defaultValue, minimum int) int {
+	raw := l.string(key, "")
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < minimum {
+		l.errs = append(l.errs, fmt.Errorf("%s must be a whole number of at least %d, got %q", key, minimum, raw))
+		return defaultValue
+	}
+	return value
+}
+
+func (l *loader) seconds(key string, defaultValue int) time.Duration {
+	return time.Duration(l.int(key, defaultValue, 1)) * time.Second
+}
+
+func (l *loader) bool(key string, defaultValue bool) bool {
+	raw := l.string(key, "")
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s must be true or false, got %q", key, raw))
+		return defaultValue
+	}
+	return value
+}
+
+// task reads <prefix>_ENABLED (default true) and <prefix>_SCHEDULE
+func (l *loader) task(prefix, defaultSchedule string) ScheduledTaskConfig {
+	schedule := l.string(prefix+"_SCHEDULE", defaultSchedule)
+	if _, err := scheduler.Parse(schedule); err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s_SCHEDULE: %w", prefix, err))
+	}
+	return ScheduledTaskConfig{Enabled: l.bool(prefix+"_ENABLED", true), Schedule: schedule}
+}
+
+// cache reads <prefix>_CACHE_STRATEGY, one of strategies, and <prefix>_CACHE_TTL_SECONDS
+func (l *loader) cache(prefix, defaultStrategy string, defaultTTL int, strategies ...string) CacheConfig {
+	return CacheConfig{
+		Strategy: l.oneOf(prefix+"_CACHE_STRATEGY", defaultStrategy, strategies...),
+		TTL:      l.seconds(prefix+"_CACHE_TTL_SECONDS", defaultTTL),
+	}
+}
+
+func (l *loader) rate(key string, defaultValue float64) float64 {
+	raw := l.string(key, "")
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || value < 0 || value >= 1 {
+		l.errs = append(l.errs, fmt.Errorf("%s must be a fraction between 0 and 1, got %q", key, raw))
+		return defaultValue
+	}
+	return value
+}
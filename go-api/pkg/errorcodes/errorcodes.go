@@ -0,0 +1,136 @@
+package errorcodes
+
+import "net/http"
+
+// Code is the machine-readable reason in a ValidationError. Clients switch on these, so
+// existing values must never be renamed; add a new code instead.
+type Code string
+
+const (
+	// Request format
+	JSONParseError Code = "json_parse_error"
+	InvalidType    Code = "invalid_type"
+	InvalidFormat  Code = "invalid_format"
+	InvalidValue   Code = "invalid_value"
+	InvalidRange   Code = "invalid_range"
+	OutOfRange     Code = "out_of_range"
+	Required       Code = "required"
+	EmptyArray     Code = "empty_array"
+	EmptyUpdates   Code = "empty_updates"
+	NoValidUpdates Code = "no_valid_updates"
+
+	// Specific request fields
+	MissingSKU               Code = "missing_sku"
+	InvalidSKUFormat         Code = "invalid_sku_format"
+	MissingOrderNumber       Code = "missing_order_number"
+	InvalidOrderNumberFormat Code = "invalid_order_number_format"
+	InvalidOperation         Code = "invalid_operation"
+	NotPatchable             Code = "not_patchable"
+
+	// Resource state
+	NotFound           Code = "not_found"
+	Forbidden          Code = "forbidden"
+	PreconditionFailed Code = "precondition_failed"
+	InvalidStatus      Code = "invalid_status"
+	InsufficientStock  Code = "insufficient_stock"
+	EmptyCart          Code = "empty_cart"
+	AlreadyRunning     Code = "already_running"
+	AlreadyProcessing  Code = "already_processing"
+	DuplicateCode      Code = "duplicate_code"
+	DuplicateEmail     Code = "duplicate_email"
+	DuplicateVote      Code = "duplicate_vote"
+	LimitReached       Code = "limit_reached"
+	InvalidSignature   Code = "invalid_signature"
+	LinkExpired        Code = "link_expired"
+
+	// Coupons
+	CouponInactive             Code = "inactive"
+	CouponExpired              Code = "expired"
+	CouponUsageLimitReached    Code = "usage_limit_reached"
+	CouponCustomerLimitReached Code = "customer_limit_reached"
+	CouponMinSubtotalNotMet    Code = "min_subtotal_not_met"
+
+	// AI queries
+	QueryRejected Code = "query_rejected"
+	QueryFailed   Code = "query_failed"
+
+	// Server side
+	DatabaseError  Code = "database_error"
+	CreationFailed Code = "creation_failed"
+	UpdateFailed   Code = "update_failed"
+	PaymentFailed  Code = "payment_failed"
+)
+
+// Entry documents one code for the /api/errors reference
+type Entry struct {
+	Code        Code   `json:"code"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+// catalog lists every code with the HTTP status it is returned with when it is the
+// reason a request failed. Per-item codes inside bulk responses keep the bulk status.
+var catalog = []Entry{
+	{JSONParseError, http.StatusBadRequest, "The request body is not valid JSON"},
+	{InvalidType, http.StatusBadRequest, "A field has the wrong JSON type"},
+	{InvalidFormat, http.StatusBadRequest, "A value is malformed, such as an ObjectID, date or email"},
+	{InvalidValue, http.StatusBadRequest, "A value is not one of the allowed options"},
+	{InvalidRange, http.StatusBadRequest, "A date range ends before it starts"},
+	{OutOfRange, http.StatusBadRequest, "A number or length is outside its allowed minimum or maximum"},
+	{Required, http.StatusBadRequest, "A required field is missing"},
+	{EmptyArray, http.StatusBadRequest, "A bulk request contains no items"},
+	{EmptyUpdates, http.StatusBadRequest, "An update request contains no fields"},
+	{NoValidUpdates, http.StatusBadRequest, "Every field in an update is immutable"},
+	{MissingSKU, http.StatusBadRequest, "A bulk product item has no sku"},
+	{InvalidSKUFormat, http.StatusBadRequest, "A SKU is not 3-50 characters"},
+	{MissingOrderNumber, http.StatusBadRequest, "A bulk order item has no order_number"},
+	{InvalidOrderNumberFormat, http.StatusBadRequest, "An order number is not 3-100 characters"},
+	{InvalidOperation, http.StatusBadRequest, "The change would leave the resource invalid, such as removing a customer's last address"},
+	{NotPatchable, http.StatusBadRequest, "A merge patch names a field that does not exist or cannot be changed"},
+	{NotFound, http.StatusNotFound, "The resource does not exist"},
+	{Forbidden, http.StatusForbidden, "The request needs a valid X-Admin-Key, or the route is turned off in this environment"},
+	{PreconditionFailed, http.StatusPreconditionFailed, "If-Match no longer matches the stored resource"},
+	{InvalidStatus, http.StatusConflict, "The resource's status does not allow this change"},
+	{InsufficientStock, http.StatusConflict, "There is not enough stock to fulfil the request"},
+	{EmptyCart, http.StatusBadRequest, "The cart has no items"},
+	{AlreadyRunning, http.StatusConflict, "The background job is already running"},
+	{AlreadyProcessing, http.StatusConflict, "Another delivery of the webhook event is being processed; retry later"},
+	{DuplicateCode, http.StatusConflict, "A coupon or gift card with this code already exists"},
+	{DuplicateEmail, http.StatusConflict, "A customer or active admin with this email already exists"},
+	{DuplicateVote, http.StatusConflict, "The customer has already voted on this review"},
+	{LimitReached, http.StatusConflict, "The customer already has the maximum number of saved searches"},
+	{InvalidSignature, http.StatusForbidden, "The download link's, unsubscribe link's or webhook's signature does not match it"},
+	{LinkExpired, http.StatusGone, "The download link has expired"},
+	{CouponInactive, http.StatusBadRequest, "The coupon is not active"},
+	{CouponExpired, http.StatusBadRequest, "The coupon has expired"},
+	{CouponUsageLimitReached, http.StatusBadRequest, "The coupon has been used the maximum number of times"},
+	{CouponCustomerLimitReached, http.StatusBadRequest, "The customer has used the coupon the maximum number of times"},
+	{CouponMinSubtotalNotMet, http.StatusBadRequest, "The cart subtotal is below the coupon minimum"},
+	{QueryRejected, http.StatusUnprocessableEntity, "The generated query was not a safe read-only aggregation"},
+	{QueryFailed, http.StatusUnprocessableEntity, "The generated query failed to run"},
+	{DatabaseError, http.StatusInternalServerError, "The database operation failed"},
+	{CreationFailed, http.StatusInternalServerError, "The resource could not be created"},
+	{UpdateFailed, http.StatusInternalServerError, "The resource could not be updated"},
+	{PaymentFailed, http.StatusBadGateway, "The payment provider rejected or could not complete the request"},
+}
+
+var statusByCode = func() map[Code]int {
+	statuses := make(map[Code]int, len(catalog))
+	for _, entry := range catalog {
+		statuses[entry.Code] = entry.Status
+	}
+	return statuses
+}()
+
+// Status returns the HTTP status for code, or 500 for a code missing from the catalog
+func Status(code Code) int {
+	if status, ok := statusByCode[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Catalog returns every code with its status and description
+func Catalog() []Entry {
+	return append([]Entry(nil), catalog...)
+}
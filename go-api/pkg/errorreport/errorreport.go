@@ -0,0 +1,58 @@
+package errorreport
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/logging"
+)
+
+// enabled is set once Init has configured a Sentry client
+var enabled bool
+
+// Init configures Sentry when a DSN is set; otherwise panics are only logged
+func Init(cfg config.ErrorReportingConfig, env string) error {
+	if cfg.SentryDSN == "" {
+		slog.Info("Error reporting disabled - SENTRY_DSN not set")
+		return nil
+	}
+
+	if err := sentry.Init(sentry.ClientOptions{
+		Dsn:         cfg.SentryDSN,
+		Environment: env,
+	}); err != nil {
+		return err
+	}
+
+	enabled = true
+	slog.Info("Error reporting enabled")
+	return nil
+}
+
+// ReportPanic sends a recovered panic to Sentry, tagged with the request ID and route
+func ReportPanic(ctx context.Context, recovered any, request *http.Request, route string) {
+	if !enabled {
+		return
+	}
+
+	hub := sentry.CurrentHub().Clone()
+	hub.ConfigureScope(func(scope *sentry.Scope) {
+		scope.SetRequest(request)
+		scope.SetTag("route", route)
+		if requestID := logging.RequestID(ctx); requestID != "" {
+			scope.SetTag("request_id", requestID)
+		}
+	})
+	hub.RecoverWithContext(ctx, recovered)
+}
+
+// Flush waits up to timeout for queued reports to be sent. Call it on shutdown.
+func Flush(timeout time.Duration) {
+	if enabled {
+		sentry.Flush(timeout)
+	}
+}
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Event is one message published on a topic
+type Event struct {
+	Topic string      `json:"-"`
+	Type  string      `json:"type"`
+	Data  interface{} `json:"data"`
+	At    time.Time   `json:"at"`
+}
+
+// Bus fans events out to in-process subscribers by topic. Publishing never blocks: a
+// subscriber whose buffer is full misses the event, so a stalled client can't hold up
+// the write that produced it. Subscribers only see events published by this instance.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan Event]struct{}
+}
+
+// NewBus returns an empty bus
+func NewBus() *Bus {
+	return &Bus{subscribers: map[string]map[chan Event]struct{}{}}
+}
+
+// defaultBus carries the application's domain events
+var defaultBus = NewBus()
+
+// Subscribe registers for events on topic. Call the returned function to unsubscribe;
+// it closes the channel.
+func (b *Bus) Subscribe(topic string, buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+
+	b.mu.Lock()
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = map[chan Event]struct{}{}
+	}
+	b.subscribers[topic][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[topic], ch)
+			if len(b.subscribers[topic]) == 0 {
+				delete(b.subscribers, topic)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish sends an event to every current subscriber of topic
+func (b *Bus) Publish(topic string, eventType string, data interface{}) {
+	event := Event{Topic: topic, Type: eventType, Data: data, At: time.Now()}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers[topic] {
+		select {
+		case ch <- event:
+		default:
+			slog.Warn("Dropped event for slow subscriber", "topic", topic, "type", eventType)
+		}
+	}
+}
+
+// Subscribe registers for events on the default bus
+func Subscribe(topic string, buffer int) (<-chan Event, func()) {
+	return defaultBus.Subscribe(topic, buffer)
+}
+
+// Publish sends an event on the default bus
+func Publish(topic string, eventType string, data interface{}) {
+	defaultBus.Publish(topic, eventType, data)
+}
+
+// OrderTopic is the topic carrying one order's status changes
+func OrderTopic(orderNumber string) string {
+	return "order:" + orderNumber
+}
@@ -0,0 +1,265 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/logging"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+)
+
+// Domain event types. Writes record these in the same transaction as the change; side
+// effects such as cache refreshes subscribe to them instead of running in the handler.
+const (
+	ProductCreated      = "product.created"
+	ProductUpdated      = "product.updated"
+	ProductDeleted      = "product.deleted"
+	OrderCreated        = "order.created"
+	OrderStatusChanged  = "order.status_changed"
+	OrderPaymentChanged = "order.payment_changed"
+	OrderUpdated        = "order.updated"
+	OrderDeleted        = "order.deleted"
+	CustomerCreated     = "customer.created"
+	CustomerUpdated     = "customer.updated"
+	CustomerDeleted     = "customer.deleted"
+	ReviewCreated       = "review.created"
+	ReviewUpdated       = "review.updated"
+	ReviewDeleted       = "review.deleted"
+	StockChanged        = "stock.changed"
+	StockLow            = "stock.low"
+	SavedSearchMatched  = "saved_search.matched"
+	LoyaltyTiersUpdated = "loyalty_tiers.updated"
+	TaxRulesUpdated     = "tax_rules.updated"
+	DatabaseSeeded      = "database.seeded"
+)
+
+// DomainEvent is the envelope published over Redis and posted to the events webhook.
+// Data holds the typed payload for Type and is read back with Decode.
+type DomainEvent struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	RequestID string          `json:"request_id,omitempty"`
+	At        time.Time       `json:"at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Decode unmarshals the event's payload into target
+func (e DomainEvent) Decode(target interface{}) error {
+	if err := json.Unmarshal(e.Data, target); err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", e.Type, err)
+	}
+	return nil
+}
+
+// ProductCreatedEvent carries a newly created product
+type ProductCreatedEvent struct {
+	Product *models.Product `json:"product"`
+}
+
+// ProductUpdatedEvent carries a product after it changed, with its price from before
+type ProductUpdatedEvent struct {
+	Product       *models.Product `json:"product"`
+	PreviousPrice float64         `json:"previous_price,omitempty"`
+}
+
+// ProductDeletedEvent carries a product as it was before deletion
+type ProductDeletedEvent struct {
+	Product *models.Product `json:"product"`
+}
+
+// OrderCreatedEvent carries a newly placed order
+type OrderCreatedEvent struct {
+	Order *models.Order `json:"order"`
+}
+
+// OrderUpdatedEvent carries an order after any change to it, including status changes,
+// which also publish OrderStatusChanged
+type OrderUpdatedEvent struct {
+	Order *models.Order `json:"order"`
+}
+
+// OrderDeletedEvent carries an order as it was before deletion
+type OrderDeletedEvent struct {
+	Order *models.Order `json:"order"`
+}
+
+// OrderPaymentChangedEvent carries an order's payment after its payment provider reported it paid,
+// declined or refunded
+type OrderPaymentChangedEvent struct {
+	OrderNumber string         `json:"order_number"`
+	Payment     models.Payment `json:"payment"`
+}
+
+// CustomerCreatedEvent carries a newly registered customer
+type CustomerCreatedEvent struct {
+	Customer *models.Customer `json:"customer"`
+}
+
+// CustomerUpdatedEvent carries a customer after their profile, preferences or addresses
+// changed
+type CustomerUpdatedEvent struct {
+	Customer *models.Customer `json:"customer"`
+}
+
+// CustomerDeletedEvent names a deleted customer
+type CustomerDeletedEvent struct {
+	CustomerID string `json:"customer_id"`
+}
+
+// ReviewCreatedEvent carries a newly posted review
+type ReviewCreatedEvent struct {
+	Review *models.Review `json:"review"`
+}
+
+// ReviewUpdatedEvent carries a review after its author edited it
+type ReviewUpdatedEvent struct {
+	Review *models.Review `json:"review"`
+}
+
+// ReviewDeletedEvent names a deleted review and its product
+type ReviewDeletedEvent struct {
+	ReviewID  string `json:"review_id"`
+	ProductID string `json:"product_id"`
+}
+
+// StockChangedEvent carries a product after its warehouse stock changed, with its total
+// stock from before
+type StockChangedEvent struct {
+	SKU           string          `json:"sku"`
+	Reason        string          `json:"reason"`
+	PreviousTotal int             `json:"previous_total"`
+	Product       *models.Product `json:"product"`
+}
+
+// StockLowEvent lists the products found at or below the low-stock threshold by the
+// scheduled scan, lowest stock first
+type StockLowEvent struct {
+	Threshold int                    `json:"threshold"`
+	Products  []models.InventoryItem `json:"products"`
+}
+
+// SavedSearchMatchedEvent tells a customer who opted into notifications that a new
+// product matches one of their saved searches
+type SavedSearchMatchedEvent struct {
+	CustomerID    string          `json:"customer_id"`
+	SavedSearchID string          `json:"saved_search_id"`
+	Name          string          `json:"name"`
+	Query         string          `json:"query"`
+	Product       *models.Product `json:"product"`
+}
+
+// LoyaltyTiersUpdatedEvent carries the loyalty tier rules an admin saved
+type LoyaltyTiersUpdatedEvent struct {
+	Rules *models.LoyaltyTierRules `json:"rules"`
+}
+
+// TaxRulesUpdatedEvent carries the tax rules an admin saved
+type TaxRulesUpdatedEvent struct {
+	Rules *models.TaxRules `json:"rules"`
+}
+
+// DatabaseSeededEvent counts the records a seed inserted. Seeding publishes no other
+// events, so subscribers that cache derived data refresh on this one.
+type DatabaseSeededEvent struct {
+	Counts models.SeedCounts `json:"counts"`
+}
+
+// Encode wraps data in a new event envelope of eventType, returning the event ID and
+// the JSON published to subscribers. Writes store it in the MongoDB outbox and the relay
+// worker publishes it, so an event is never lost once its write commits.
+func Encode(ctx context.Context, eventType string, data interface{}) (string, []byte, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	event := DomainEvent{
+		ID:        newEventID(),
+		Type:      eventType,
+		RequestID: logging.RequestID(ctx),
+		At:        time.Now().UTC(),
+		Data:      payload,
+	}
+	envelope, err := json.Marshal(event)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	return event.ID, envelope, nil
+}
+
+// newEventID generates a random 32-character hex event ID
+func newEventID() string {
+	randomBytes := make([]byte, 16)
+	rand.Read(randomBytes)
+	return hex.EncodeToString(randomBytes)
+}
+
+// HandlerFunc reacts to one domain event. Every instance runs its handlers for every
+// event, and the outbox may publish an event more than once, so handlers must be idempotent.
+type HandlerFunc func(ctx context.Context, event DomainEvent) error
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string][]HandlerFunc{}
+)
+
+// On registers handler for eventType. Register handlers before calling Listen.
+func On(eventType string, handler HandlerFunc) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[eventType] = append(handlers[eventType], handler)
+}
+
+// Listen subscribes to domain events and runs the registered handlers for each one in
+// the order they arrive. It blocks until ctx is cancelled, so run it in its own goroutine.
+func Listen(ctx context.Context) {
+	messages, closeSubscription := redis.SubscribeDomainEvents(ctx)
+	defer func() {
+		if err := closeSubscription(); err != nil {
+			slog.Warn("Error closing domain event subscription", "error", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			var event DomainEvent
+			if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+				slog.Warn("Ignoring malformed domain event", "error", err)
+				continue
+			}
+			dispatch(event)
+		}
+	}
+}
+
+// dispatch runs every handler registered for the event, each with its own timeout
+func dispatch(event DomainEvent) {
+	handlersMu.RLock()
+	registered := handlers[event.Type]
+	handlersMu.RUnlock()
+
+	for _, handler := range registered {
+		handlerCtx, cancel := global.GetDefaultTimer()
+		handlerCtx = logging.WithRequestID(handlerCtx, event.RequestID)
+		if err := handler(handlerCtx, event); err != nil {
+			slog.ErrorContext(handlerCtx, "Domain event handler failed", "type", event.Type, "event_id", event.ID, "error", err)
+		}
+		cancel()
+	}
+}
@@ -1,7 +1,11 @@
 package global
 
 import (
+	"encoding/json"
 	"net/http"
+	"reflect"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/errorcodes"
 )
 
 type Response struct {
@@ -11,9 +15,9 @@ type Response struct {
 }
 
 type ValidationError struct {
-	Field   string `json:"field"`
-	Message string `json:"message"`
-	Code    string `json:"code,omitempty"`
+	Field   string          `json:"field"`
+	Message string          `json:"message"`
+	Code    errorcodes.Code `json:"code,omitempty"`
 }
 
 type APIResponse struct {
@@ -21,6 +25,9 @@ type APIResponse struct {
 	Data    interface{}       `json:"data,omitempty"`
 	Message string            `json:"message,omitempty"`
 	Errors  []ValidationError `json:"errors,omitempty"`
+	// Pagination and Meta are only set on list responses
+	Pagination *PaginationInfo        `json:"pagination,omitempty"`
+	Meta       map[string]interface{} `json:"meta,omitempty"`
 }
 
 func SuccessResponse(data interface{}) APIResponse {
@@ -37,3 +44,80 @@ func ErrorResponse(message string, errors []ValidationError) APIResponse {
 		Errors:  errors,
 	}
 }
+
+// PaginationInfo describes which part of a list is being returned. Offset pages carry
+// page counts; cursor pages carry next_cursor instead.
+type PaginationInfo struct {
+	Page       int    `json:"page"`
+	Limit      int    `json:"limit"`
+	TotalPages int    `json:"total_pages"`
+	TotalItems int    `json:"total_items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+
+	cursor bool
+}
+
+// NewPagination builds the pagination block for page of a list with totalItems entries
+func NewPagination(page int, limit int, totalItems int) PaginationInfo {
+	totalPages := 0
+	if limit > 0 {
+		totalPages = (totalItems + limit - 1) / limit
+	}
+	return PaginationInfo{
+		Page:       page,
+		Limit:      limit,
+		TotalPages: totalPages,
+		TotalItems: totalItems,
+		HasMore:    page < totalPages,
+	}
+}
+
+// NewCursorPagination builds the pagination block for a keyset page. Totals are not
+// counted, since counting would cost what cursor pagination saves.
+func NewCursorPagination(limit int, nextCursor string, hasMore bool) PaginationInfo {
+	return PaginationInfo{
+		Limit:      limit,
+		NextCursor: nextCursor,
+		HasMore:    hasMore,
+		cursor:     true,
+	}
+}
+
+// SinglePage is the pagination block for a list returned in full
+func SinglePage(totalItems int) PaginationInfo {
+	return PaginationInfo{
+		Page:       1,
+		Limit:      totalItems,
+		TotalPages: 1,
+		TotalItems: totalItems,
+	}
+}
+
+// MarshalJSON leaves the page counts out of cursor pagination
+func (p PaginationInfo) MarshalJSON() ([]byte, error) {
+	if p.cursor {
+		return json.Marshal(struct {
+			Limit      int    `json:"limit"`
+			NextCursor string `json:"next_cursor,omitempty"`
+			HasMore    bool   `json:"has_more"`
+		}{p.Limit, p.NextCursor, p.HasMore})
+	}
+
+	type offsetPagination PaginationInfo
+	return json.Marshal(offsetPagination(p))
+}
+
+// ListResponse is the envelope for every list endpoint: the items are always a JSON
+// array in data, with the page in pagination and any list-level details in meta
+func ListResponse(items interface{}, pagination PaginationInfo, meta map[string]interface{}) APIResponse {
+	if value := reflect.ValueOf(items); !value.IsValid() || (value.Kind() == reflect.Slice && value.IsNil()) {
+		items = []interface{}{}
+	}
+	return APIResponse{
+		Success:    true,
+		Data:       items,
+		Pagination: &pagination,
+		Meta:       meta,
+	}
+}
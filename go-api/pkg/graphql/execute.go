@@ -0,0 +1,344 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+const defaultMaxDepth = 10
+
+// Request is the body of a GraphQL HTTP request
+type Request struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is a GraphQL result. Data is nil when the request could not be executed at
+// all; field errors leave the field null and are listed in Errors.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+// Error is one entry in a GraphQL response's errors list
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Execute parses, validates and runs a query against schema
+func Execute(ctx context.Context, schema *Schema, request Request) *Response {
+	document, err := Parse(request.Query)
+	if err != nil {
+		return requestError(err.Error())
+	}
+
+	operation, message := selectOperation(document, request.OperationName)
+	if operation == nil {
+		return requestError(message)
+	}
+	if operation.Type != "query" {
+		return requestError(fmt.Sprintf("%s operations are not supported; use the REST API to make changes", operation.Type))
+	}
+
+	variables := map[string]interface{}{}
+	declared := map[string]bool{}
+	for _, definition := range operation.Variables {
+		declared[definition.Name] = true
+		value, ok := request.Variables[definition.Name]
+		if !ok {
+			value = definition.Default
+		}
+		if value == nil && definition.Required {
+			return requestError(fmt.Sprintf("Variable \"$%s\" is required", definition.Name))
+		}
+		variables[definition.Name] = value
+	}
+
+	maxDepth := schema.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = defaultMaxDepth
+	}
+	v := validator{declared: declared, maxDepth: maxDepth}
+	v.validateSelection(schema.Query, operation.SelectionSet, 1)
+	if len(v.errors) > 0 {
+		return &Response{Errors: v.errors}
+	}
+
+	e := &executor{ctx: ctx, variables: variables}
+	data := e.executeSelection(schema.Query, operation.SelectionSet, schema.Query, nil)
+	return &Response{Data: data, Errors: e.errors}
+}
+
+func requestError(message string) *Response {
+	return &Response{Errors: []Error{{Message: message}}}
+}
+
+func selectOperation(document *Document, name string) (*Operation, string) {
+	if name == "" {
+		if len(document.Operations) > 1 {
+			return nil, "operationName is required when the document has several operations"
+		}
+		return document.Operations[0], ""
+	}
+	for _, operation := range document.Operations {
+		if operation.Name == name {
+			return operation, ""
+		}
+	}
+	return nil, fmt.Sprintf("Unknown operation named \"%s\"", name)
+}
+
+// validator rejects selections the schema can't answer before anything is resolved
+type validator struct {
+	declared map[string]bool
+	maxDepth int
+	errors   []Error
+}
+
+func (v *validator) errorf(format string, args ...interface{}) {
+	v.errors = append(v.errors, Error{Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validateSelection(object *Object, fields []*Field, depth int) {
+	if depth > v.maxDepth {
+		v.errorf("Query is nested deeper than the maximum of %d levels", v.maxDepth)
+		return
+	}
+
+	for _, field := range fields {
+		for _, directive := range field.Directives {
+			if directive.Name != "skip" && directive.Name != "include" {
+				v.errorf("Unknown directive \"@%s\"", directive.Name)
+			}
+			v.validateVariables(directive.Arguments)
+		}
+		v.validateVariables(field.Arguments)
+
+		if field.Name == "__typename" {
+			if field.SelectionSet != nil {
+				v.errorf("Field \"__typename\" must not have a selection since it is a scalar")
+			}
+			continue
+		}
+
+		definition, ok := object.Fields[field.Name]
+		if !ok {
+			v.errorf("Cannot query field \"%s\" on type \"%s\"", field.Name, object.Name)
+			continue
+		}
+		for name := range field.Arguments {
+			if !contains(definition.Args, name) {
+				v.errorf("Unknown argument \"%s\" on field \"%s.%s\"", name, object.Name, field.Name)
+			}
+		}
+
+		switch {
+		case definition.Type != nil && field.SelectionSet == nil:
+			v.errorf("Field \"%s\" of type \"%s\" must have a selection of subfields", field.Name, definition.Type.Name)
+		case definition.Type == nil && field.SelectionSet != nil:
+			v.errorf("Field \"%s\" must not have a selection since it is a scalar", field.Name)
+		case definition.Type != nil:
+			v.validateSelection(definition.Type, field.SelectionSet, depth+1)
+		}
+	}
+}
+
+func (v *validator) validateVariables(value interface{}) {
+	switch typed := value.(type) {
+	case Variable:
+		if !v.declared[string(typed)] {
+			v.errorf("Variable \"$%s\" is not defined", typed)
+		}
+	case []interface{}:
+		for _, item := range typed {
+			v.validateVariables(item)
+		}
+	case map[string]interface{}:
+		for _, item := range typed {
+			v.validateVariables(item)
+		}
+	}
+}
+
+type executor struct {
+	ctx       context.Context
+	variables map[string]interface{}
+	errors    []Error
+}
+
+func (e *executor) executeSelection(object *Object, fields []*Field, source interface{}, path []interface{}) *orderedObject {
+	result := &orderedObject{values: map[string]interface{}{}}
+	for _, field := range fields {
+		if !e.included(field) {
+			continue
+		}
+		key := field.ResponseKey()
+		fieldPath := append(append([]interface{}{}, path...), key)
+
+		if field.Name == "__typename" {
+			result.set(key, object.Name)
+			continue
+		}
+
+		definition := object.Fields[field.Name]
+		args := e.substitute(field.Arguments).(map[string]interface{})
+
+		var value interface{}
+		if definition.Resolve != nil {
+			var err error
+			if value, err = definition.Resolve(e.ctx, source, args); err != nil {
+				e.errors = append(e.errors, Error{Message: err.Error(), Path: fieldPath})
+				result.set(key, nil)
+				continue
+			}
+		} else {
+			value = defaultResolve(source, field.Name)
+		}
+
+		result.set(key, e.complete(definition.Type, field, value, fieldPath))
+	}
+	return result
+}
+
+// complete shapes a resolved value for the response: lists element by element and
+// objects through their selection set
+func (e *executor) complete(object *Object, field *Field, value interface{}, path []interface{}) interface{} {
+	if isNil(value) {
+		return nil
+	}
+	if object == nil {
+		return value
+	}
+
+	list := reflect.ValueOf(value)
+	for list.Kind() == reflect.Pointer {
+		list = list.Elem()
+	}
+	if list.Kind() == reflect.Slice || list.Kind() == reflect.Array {
+		items := make([]interface{}, list.Len())
+		for i := range items {
+			items[i] = e.complete(object, field, list.Index(i).Interface(), append(append([]interface{}{}, path...), i))
+		}
+		return items
+	}
+
+	return e.executeSelection(object, field.SelectionSet, value, path)
+}
+
+// included applies @skip(if:) and @include(if:)
+func (e *executor) included(field *Field) bool {
+	for _, directive := range field.Directives {
+		condition, _ := e.substitute(directive.Arguments["if"]).(bool)
+		if (directive.Name == "skip" && condition) || (directive.Name == "include" && !condition) {
+			return false
+		}
+	}
+	return true
+}
+
+// substitute replaces variable references in an argument value
+func (e *executor) substitute(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case Variable:
+		return e.variables[string(typed)]
+	case []interface{}:
+		items := make([]interface{}, len(typed))
+		for i, item := range typed {
+			items[i] = e.substitute(item)
+		}
+		return items
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			object[key] = e.substitute(item)
+		}
+		return object
+	case nil:
+		return map[string]interface{}{}
+	default:
+		return value
+	}
+}
+
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return reflected.IsNil()
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// orderedObject keeps fields in the order they were selected, as the spec requires
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *orderedObject) set(key string, value interface{}) {
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// IntArg reads an integer argument, accepting JSON numbers from variables
+func IntArg(args map[string]interface{}, name string, fallback int) (int, error) {
+	switch value := args[name].(type) {
+	case nil:
+		return fallback, nil
+	case int64:
+		return int(value), nil
+	case float64:
+		if value != math.Trunc(value) {
+			return 0, fmt.Errorf("argument \"%s\" must be an integer", name)
+		}
+		return int(value), nil
+	default:
+		return 0, fmt.Errorf("argument \"%s\" must be an integer", name)
+	}
+}
+
+// StringArg reads a string argument, reporting whether it was given
+func StringArg(args map[string]interface{}, name string) (string, bool) {
+	value, ok := args[name].(string)
+	return value, ok
+}
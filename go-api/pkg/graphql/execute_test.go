@@ -0,0 +1,187 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testReview struct {
+	Rating int    `json:"rating"`
+	Title  string `json:"title"`
+}
+
+type testProduct struct {
+	SKU     string       `json:"sku"`
+	Name    string       `json:"name"`
+	Price   float64      `json:"price"`
+	Reviews []testReview `json:"reviews"`
+	Secret  string       `json:"-"`
+}
+
+// testSchema serves products from a map, with a reviews field that fails for one SKU
+func testSchema() *Schema {
+	products := map[string]*testProduct{
+		"A": {SKU: "A", Name: "Anvil", Price: 10, Reviews: []testReview{{Rating: 5, Title: "Heavy"}, {Rating: 4, Title: "Solid"}}},
+		"B": {SKU: "B", Name: "Bucket", Price: 2.5},
+	}
+
+	types := NewTypes()
+	product := types.Object("Product", testProduct{})
+	review := product.Fields["reviews"].Type
+	product.Fields["reviews"] = &FieldDef{Type: review, Args: []string{"limit"}, Resolve: func(_ context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+		p := source.(*testProduct)
+		if p.SKU == "B" {
+			return nil, errors.New("reviews unavailable")
+		}
+		limit, err := IntArg(args, "limit", len(p.Reviews))
+		if err != nil {
+			return nil, err
+		}
+		return p.Reviews[:limit], nil
+	}}
+
+	query := &Object{Name: "Query", Fields: map[string]*FieldDef{
+		"product": {Type: product, Args: []string{"sku"}, Resolve: func(_ context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+			sku, _ := StringArg(args, "sku")
+			if p, ok := products[sku]; ok {
+				return p, nil
+			}
+			return nil, nil
+		}},
+		"products": {Type: product, Resolve: func(context.Context, interface{}, map[string]interface{}) (interface{}, error) {
+			return []*testProduct{products["A"], products["B"]}, nil
+		}},
+		"version": {Resolve: func(context.Context, interface{}, map[string]interface{}) (interface{}, error) {
+			return "1.0", nil
+		}},
+	}}
+	return &Schema{Query: query}
+}
+
+// run executes a request and returns the response encoded as JSON
+func run(t *testing.T, request Request) (string, *Response) {
+	t.Helper()
+	response := Execute(context.Background(), testSchema(), request)
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("encoding the response: %v", err)
+	}
+	return string(encoded), response
+}
+
+func TestExecuteSelection(t *testing.T) {
+	got, _ := run(t, Request{Query: `{ version first: product(sku: "A") { name sku reviews(limit: 1) { title } } }`})
+	want := `{"data":{"version":"1.0","first":{"name":"Anvil","sku":"A","reviews":[{"title":"Heavy"}]}}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestExecuteVariablesAndDirectives(t *testing.T) {
+	got, _ := run(t, Request{
+		Query:     `query Page($sku: String!, $withPrice: Boolean = false, $limit: Int) { product(sku: $sku) { name price @include(if: $withPrice) __typename reviews(limit: $limit) @skip(if: false) { rating } } }`,
+		Variables: map[string]interface{}{"sku": "A", "limit": float64(2)},
+	})
+	want := `{"data":{"product":{"name":"Anvil","__typename":"Product","reviews":[{"rating":5},{"rating":4}]}}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestExecuteListsAndFieldErrors(t *testing.T) {
+	got, response := run(t, Request{Query: `{ products { sku reviews { rating } } missing: product(sku: "Z") { sku } }`})
+	want := `{"data":{"products":[{"sku":"A","reviews":[{"rating":5},{"rating":4}]},{"sku":"B","reviews":null}],"missing":null},"errors":[{"message":"reviews unavailable","path":["products",1,"reviews"]}]}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if len(response.Errors) != 1 {
+		t.Errorf("got %d errors, want 1", len(response.Errors))
+	}
+}
+
+func TestExecuteOperationName(t *testing.T) {
+	document := `query One { version } query Two { product(sku: "B") { name } }`
+
+	got, _ := run(t, Request{Query: document, OperationName: "Two"})
+	if want := `{"data":{"product":{"name":"Bucket"}}}`; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	_, response := run(t, Request{Query: document})
+	if response.Data != nil || len(response.Errors) != 1 || !strings.Contains(response.Errors[0].Message, "operationName is required") {
+		t.Errorf("got %+v, want an operationName error", response)
+	}
+
+	_, response = run(t, Request{Query: document, OperationName: "Three"})
+	if response.Data != nil || len(response.Errors) != 1 || !strings.Contains(response.Errors[0].Message, "Unknown operation") {
+		t.Errorf("got %+v, want an unknown operation error", response)
+	}
+}
+
+func TestExecuteRejectsInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		request Request
+		message string
+	}{
+		{"syntax error", Request{Query: `{ version`}, "syntax error"},
+		{"mutation", Request{Query: `mutation { version }`}, "mutation operations are not supported"},
+		{"unknown field", Request{Query: `{ price }`}, `Cannot query field "price" on type "Query"`},
+		{"hidden field", Request{Query: `{ product(sku: "A") { Secret } }`}, `Cannot query field "Secret" on type "Product"`},
+		{"unknown argument", Request{Query: `{ product(id: "A") { sku } }`}, `Unknown argument "id" on field "Query.product"`},
+		{"object without selection", Request{Query: `{ product(sku: "A") }`}, "must have a selection of subfields"},
+		{"scalar with selection", Request{Query: `{ version { major } }`}, "must not have a selection since it is a scalar"},
+		{"unknown directive", Request{Query: `{ version @defer }`}, `Unknown directive "@defer"`},
+		{"undeclared variable", Request{Query: `{ product(sku: $sku) { sku } }`}, `Variable "$sku" is not defined`},
+		{"missing required variable", Request{Query: `query ($sku: String!) { product(sku: $sku) { sku } }`}, `Variable "$sku" is required`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, response := run(t, test.request)
+			if response.Data != nil {
+				t.Fatalf("got data %v, want the request rejected", response.Data)
+			}
+			if len(response.Errors) == 0 || !strings.Contains(response.Errors[0].Message, test.message) {
+				t.Errorf("got errors %+v, want one mentioning %q", response.Errors, test.message)
+			}
+		})
+	}
+}
+
+func TestExecuteMaxDepth(t *testing.T) {
+	schema := testSchema()
+	schema.MaxDepth = 2
+
+	response := Execute(context.Background(), schema, Request{Query: `{ product(sku: "A") { reviews { rating } } }`})
+	if response.Data != nil || len(response.Errors) != 1 || !strings.Contains(response.Errors[0].Message, "nested deeper than the maximum of 2 levels") {
+		t.Errorf("got %+v, want a depth error", response)
+	}
+
+	response = Execute(context.Background(), schema, Request{Query: `{ product(sku: "A") { name } }`})
+	if response.Data == nil || len(response.Errors) != 0 {
+		t.Errorf("got %+v, want two levels to run", response)
+	}
+}
+
+func TestIntArg(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    int
+		wantErr bool
+	}{
+		{nil, 20, false},
+		{int64(5), 5, false},
+		{float64(7), 7, false},
+		{1.5, 0, true},
+		{"5", 0, true},
+	}
+	for _, test := range tests {
+		got, err := IntArg(map[string]interface{}{"limit": test.value}, "limit", 20)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("IntArg(%#v) = %d, %v; want %d, error %v", test.value, got, err, test.want, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,536 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Document is a parsed GraphQL request document
+type Document struct {
+	Operations []*Operation
+}
+
+// Operation is one query in a document
+type Operation struct {
+	Type         string // "query", "mutation" or "subscription"
+	Name         string
+	Variables    []VariableDefinition
+	SelectionSet []*Field
+}
+
+// VariableDefinition declares a $variable the operation accepts
+type VariableDefinition struct {
+	Name     string
+	Required bool // declared with a non-null (!) type
+	Default  interface{}
+}
+
+// Field is one selection. Arguments hold literal values, with Variable for $refs.
+type Field struct {
+	Alias        string
+	Name         string
+	Arguments    map[string]interface{}
+	Directives   []Directive
+	SelectionSet []*Field
+	Line         int
+}
+
+// ResponseKey is the name the field is returned under
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// Directive is an @name(args) annotation on a field
+type Directive struct {
+	Name      string
+	Arguments map[string]interface{}
+}
+
+// Variable is a $name reference inside an argument value
+type Variable string
+
+// Parse reads a query document. Fragments are not supported.
+func Parse(source string) (*Document, error) {
+	p := &parser{lexer: lexer{source: source, line: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	document := &Document{}
+	for p.token.kind != tokenEOF {
+		operation, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		document.Operations = append(document.Operations, operation)
+	}
+	if len(document.Operations) == 0 {
+		return nil, p.errorf("document contains no operations")
+	}
+	return document, nil
+}
+
+type parser struct {
+	lexer lexer
+	token token
+}
+
+func (p *parser) advance() error {
+	token, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("syntax error on line %d: %s", p.token.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) peek(kind tokenKind, value string) bool {
+	return p.token.kind == kind && (value == "" || p.token.value == value)
+}
+
+func (p *parser) expect(kind tokenKind, value string) (token, error) {
+	if !p.peek(kind, value) {
+		want := value
+		if want == "" {
+			want = kind.String()
+		}
+		return token{}, p.errorf("expected %s, found %q", want, p.token.value)
+	}
+	current := p.token
+	return current, p.advance()
+}
+
+func (p *parser) parseOperation() (*Operation, error) {
+	operation := &Operation{Type: "query"}
+	if p.peek(tokenPunct, "{") {
+		selections, err := p.parseSelectionSet()
+		operation.SelectionSet = selections
+		return operation, err
+	}
+
+	if !p.peek(tokenName, "") {
+		return nil, p.errorf("expected an operation, found %q", p.token.value)
+	}
+	switch p.token.value {
+	case "query", "mutation", "subscription":
+		operation.Type = p.token.value
+	case "fragment":
+		return nil, p.errorf("fragments are not supported")
+	default:
+		return nil, p.errorf("unknown operation type %q", p.token.value)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.peek(tokenName, "") {
+		operation.Name = p.token.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek(tokenPunct, "(") {
+		variables, err := p.parseVariableDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		operation.Variables = variables
+	}
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+
+	selections, err := p.parseSelectionSet()
+	operation.SelectionSet = selections
+	return operation, err
+}
+
+func (p *parser) parseVariableDefinitions() ([]VariableDefinition, error) {
+	if _, err := p.expect(tokenPunct, "("); err != nil {
+		return nil, err
+	}
+
+	var definitions []VariableDefinition
+	for !p.peek(tokenPunct, ")") {
+		if _, err := p.expect(tokenPunct, "$"); err != nil {
+			return nil, err
+		}
+		name, err := p.expect(tokenName, "")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenPunct, ":"); err != nil {
+			return nil, err
+		}
+		required, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+
+		definition := VariableDefinition{Name: name.value, Required: required}
+		if p.peek(tokenPunct, "=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if definition.Default, err = p.parseValue(true); err != nil {
+				return nil, err
+			}
+		}
+		definitions = append(definitions, definition)
+	}
+	return definitions, p.advance()
+}
+
+// parseType skips a type reference and reports whether it is non-null
+func (p *parser) parseType() (bool, error) {
+	if p.peek(tokenPunct, "[") {
+		if err := p.advance(); err != nil {
+			return false, err
+		}
+		if _, err := p.parseType(); err != nil {
+			return false, err
+		}
+		if _, err := p.expect(tokenPunct, "]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.expect(tokenName, ""); err != nil {
+		return false, err
+	}
+
+	if p.peek(tokenPunct, "!") {
+		return true, p.advance()
+	}
+	return false, nil
+}
+
+func (p *parser) parseSelectionSet() ([]*Field, error) {
+	if _, err := p.expect(tokenPunct, "{"); err != nil {
+		return nil, err
+	}
+
+	var fields []*Field
+	for !p.peek(tokenPunct, "}") {
+		if p.token.kind == tokenEOF {
+			return nil, p.errorf("unterminated selection set")
+		}
+		if p.peek(tokenPunct, "...") {
+			return nil, p.errorf("fragments are not supported")
+		}
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, p.errorf("selection set is empty")
+	}
+	return fields, p.advance()
+}
+
+func (p *parser) parseField() (*Field, error) {
+	name, err := p.expect(tokenName, "")
+	if err != nil {
+		return nil, err
+	}
+	field := &Field{Name: name.value, Line: name.line}
+
+	if p.peek(tokenPunct, ":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		actual, err := p.expect(tokenName, "")
+		if err != nil {
+			return nil, err
+		}
+		field.Alias, field.Name = field.Name, actual.value
+	}
+	if p.peek(tokenPunct, "(") {
+		if field.Arguments, err = p.parseArguments(); err != nil {
+			return nil, err
+		}
+	}
+	if field.Directives, err = p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	if p.peek(tokenPunct, "{") {
+		if field.SelectionSet, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+func (p *parser) parseArguments() (map[string]interface{}, error) {
+	if _, err := p.expect(tokenPunct, "("); err != nil {
+		return nil, err
+	}
+
+	arguments := map[string]interface{}{}
+	for !p.peek(tokenPunct, ")") {
+		name, err := p.expect(tokenName, "")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenPunct, ":"); err != nil {
+			return nil, err
+		}
+		if arguments[name.value], err = p.parseValue(false); err != nil {
+			return nil, err
+		}
+	}
+	return arguments, p.advance()
+}
+
+func (p *parser) parseDirectives() ([]Directive, error) {
+	var directives []Directive
+	for p.peek(tokenPunct, "@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.expect(tokenName, "")
+		if err != nil {
+			return nil, err
+		}
+		directive := Directive{Name: name.value}
+		if p.peek(tokenPunct, "(") {
+			if directive.Arguments, err = p.parseArguments(); err != nil {
+				return nil, err
+			}
+		}
+		directives = append(directives, directive)
+	}
+	return directives, nil
+}
+
+// parseValue reads an argument value. constant is set for variable defaults, which may not
+// reference other variables.
+func (p *parser) parseValue(constant bool) (interface{}, error) {
+	current := p.token
+	switch {
+	case p.peek(tokenPunct, "$"):
+		if constant {
+			return nil, p.errorf("variables are not allowed here")
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.expect(tokenName, "")
+		return Variable(name.value), err
+	case p.peek(tokenPunct, "["):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.peek(tokenPunct, "]") {
+			item, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, p.advance()
+	case p.peek(tokenPunct, "{"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		object := map[string]interface{}{}
+		for !p.peek(tokenPunct, "}") {
+			name, err := p.expect(tokenName, "")
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokenPunct, ":"); err != nil {
+				return nil, err
+			}
+			if object[name.value], err = p.parseValue(constant); err != nil {
+				return nil, err
+			}
+		}
+		return object, p.advance()
+	case current.kind == tokenInt:
+		value, err := strconv.ParseInt(current.value, 10, 64)
+		if err != nil {
+			return nil, p.errorf("invalid integer %s", current.value)
+		}
+		return value, p.advance()
+	case current.kind == tokenFloat:
+		value, err := strconv.ParseFloat(current.value, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", current.value)
+		}
+		return value, p.advance()
+	case current.kind == tokenString:
+		return current.value, p.advance()
+	case current.kind == tokenName:
+		// true, false and null; any other name is an enum value, passed as its string
+		var value interface{} = current.value
+		switch current.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		}
+		return value, p.advance()
+	default:
+		return nil, p.errorf("expected a value, found %q", current.value)
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenPunct:
+		return "punctuation"
+	case tokenName:
+		return "a name"
+	case tokenInt, tokenFloat:
+		return "a number"
+	case tokenString:
+		return "a string"
+	default:
+		return "end of document"
+	}
+}
+
+type token struct {
+	kind  tokenKind
+	value string
+	line  int
+}
+
+type lexer struct {
+	source string
+	pos    int
+	line   int
+}
+
+func (l *lexer) next() (token, error) {
+	// Whitespace, commas and comments are insignificant
+	for l.pos < len(l.source) {
+		ch := l.source[l.pos]
+		if ch == '\n' {
+			l.line++
+		}
+		if ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',' {
+			l.pos++
+			continue
+		}
+		if ch == '#' {
+			for l.pos < len(l.source) && l.source[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		break
+	}
+	if l.pos >= len(l.source) {
+		return token{kind: tokenEOF, line: l.line}, nil
+	}
+
+	start := l.pos
+	ch := l.source[l.pos]
+	switch {
+	case strings.HasPrefix(l.source[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokenPunct, value: "...", line: l.line}, nil
+	case strings.ContainsRune("!$():=@[]{}|&", rune(ch)):
+		l.pos++
+		return token{kind: tokenPunct, value: string(ch), line: l.line}, nil
+	case ch == '_' || isLetter(ch):
+		for l.pos < len(l.source) && (l.source[l.pos] == '_' || isLetter(l.source[l.pos]) || isDigit(l.source[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokenName, value: l.source[start:l.pos], line: l.line}, nil
+	case ch == '-' || isDigit(ch):
+		return l.number()
+	case ch == '"':
+		return l.string()
+	default:
+		r, _ := utf8.DecodeRuneInString(l.source[l.pos:])
+		return token{}, fmt.Errorf("syntax error on line %d: unexpected character %q", l.line, r)
+	}
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	kind := tokenInt
+	if l.source[l.pos] == '-' {
+		l.pos++
+	}
+	for l.pos < len(l.source) {
+		ch := l.source[l.pos]
+		switch {
+		case isDigit(ch):
+		case ch == '.' || ch == 'e' || ch == 'E':
+			kind = tokenFloat
+		case (ch == '+' || ch == '-') && (l.source[l.pos-1] == 'e' || l.source[l.pos-1] == 'E'):
+		default:
+			return token{kind: kind, value: l.source[start:l.pos], line: l.line}, nil
+		}
+		l.pos++
+	}
+	return token{kind: kind, value: l.source[start:l.pos], line: l.line}, nil
+}
+
+func (l *lexer) string() (token, error) {
+	line := l.line
+	if strings.HasPrefix(l.source[l.pos:], `"""`) {
+		end := strings.Index(l.source[l.pos+3:], `"""`)
+		if end < 0 {
+			return token{}, fmt.Errorf("syntax error on line %d: unterminated string", line)
+		}
+		value := l.source[l.pos+3 : l.pos+3+end]
+		l.line += strings.Count(value, "\n")
+		l.pos += end + 6
+		return token{kind: tokenString, value: strings.TrimSpace(value), line: line}, nil
+	}
+
+	// Single-line strings use JSON's escapes
+	for end := l.pos + 1; end < len(l.source); end++ {
+		switch l.source[end] {
+		case '\\':
+			end++
+		case '\n':
+			return token{}, fmt.Errorf("syntax error on line %d: unterminated string", line)
+		case '"':
+			var value string
+			if err := json.Unmarshal([]byte(l.source[l.pos:end+1]), &value); err != nil {
+				return token{}, fmt.Errorf("syntax error on line %d: invalid string", line)
+			}
+			l.pos = end + 1
+			return token{kind: tokenString, value: value, line: line}, nil
+		}
+	}
+	return token{}, fmt.Errorf("syntax error on line %d: unterminated string", line)
+}
+
+func isLetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
@@ -0,0 +1,131 @@
+package graphql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOperation(t *testing.T) {
+	document, err := Parse(`
+		# the order page
+		query OrderPage($number: String!, $limit: Int = 3) {
+			order(order_number: $number) {
+				number: order_number
+				items { quantity product { name reviews(limit: $limit) @skip(if: false) { rating } } }
+			}
+		}`)
+	if err != nil {
+		t.Fatalf("Parse returned %v", err)
+	}
+	if len(document.Operations) != 1 {
+		t.Fatalf("got %d operations, want 1", len(document.Operations))
+	}
+
+	operation := document.Operations[0]
+	if operation.Type != "query" || operation.Name != "OrderPage" {
+		t.Errorf("got %s %q, want query \"OrderPage\"", operation.Type, operation.Name)
+	}
+	if len(operation.Variables) != 2 {
+		t.Fatalf("got %d variables, want 2", len(operation.Variables))
+	}
+	if v := operation.Variables[0]; v.Name != "number" || !v.Required || v.Default != nil {
+		t.Errorf("got variable %+v, want required $number without a default", v)
+	}
+	if v := operation.Variables[1]; v.Name != "limit" || v.Required || v.Default != int64(3) {
+		t.Errorf("got variable %+v, want optional $limit defaulting to 3", v)
+	}
+
+	order := operation.SelectionSet[0]
+	if order.Name != "order" || order.Arguments["order_number"] != Variable("number") {
+		t.Errorf("got field %q with arguments %v", order.Name, order.Arguments)
+	}
+	number := order.SelectionSet[0]
+	if number.Name != "order_number" || number.Alias != "number" || number.ResponseKey() != "number" {
+		t.Errorf("got alias %q for %q, want number for order_number", number.Alias, number.Name)
+	}
+	reviews := order.SelectionSet[1].SelectionSet[1].SelectionSet[1]
+	if reviews.Name != "reviews" || len(reviews.Directives) != 1 || reviews.Directives[0].Name != "skip" {
+		t.Errorf("got %q with directives %+v, want reviews with @skip", reviews.Name, reviews.Directives)
+	}
+	if reviews.Directives[0].Arguments["if"] != false {
+		t.Errorf("got @skip(if: %v), want false", reviews.Directives[0].Arguments["if"])
+	}
+}
+
+func TestParseShorthandQuery(t *testing.T) {
+	document, err := Parse(`{ products(limit: 2) { items { sku } } }`)
+	if err != nil {
+		t.Fatalf("Parse returned %v", err)
+	}
+	operation := document.Operations[0]
+	if operation.Type != "query" || operation.Name != "" {
+		t.Errorf("got %s %q, want an anonymous query", operation.Type, operation.Name)
+	}
+	if limit := operation.SelectionSet[0].Arguments["limit"]; limit != int64(2) {
+		t.Errorf("got limit %#v, want int64 2", limit)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	document, err := Parse(`{ f(i: -4, f: 1.5e2, s: "a\"bé", t: true, n: null, e: ACTIVE, l: [1, "x"], o: {k: false}) }`)
+	if err != nil {
+		t.Fatalf("Parse returned %v", err)
+	}
+	args := document.Operations[0].SelectionSet[0].Arguments
+
+	checks := map[string]interface{}{
+		"i": int64(-4),
+		"f": 150.0,
+		"s": "a\"bé",
+		"t": true,
+		"n": nil,
+		"e": "ACTIVE",
+	}
+	for name, want := range checks {
+		if got := args[name]; got != want {
+			t.Errorf("argument %s: got %#v, want %#v", name, got, want)
+		}
+	}
+	if list, ok := args["l"].([]interface{}); !ok || len(list) != 2 || list[0] != int64(1) || list[1] != "x" {
+		t.Errorf("argument l: got %#v, want [1 \"x\"]", args["l"])
+	}
+	if object, ok := args["o"].(map[string]interface{}); !ok || object["k"] != false {
+		t.Errorf("argument o: got %#v, want {k: false}", args["o"])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		reason string
+	}{
+		{"empty document", ``, "no operations"},
+		{"fragment definition", `fragment F on Product { sku }`, "fragments are not supported"},
+		{"fragment spread", `{ product(sku: "A") { ...F } }`, "fragments are not supported"},
+		{"unterminated selection", `{ products { items { sku } }`, "unterminated selection set"},
+		{"empty selection", `{ product(sku: "A") { } }`, "selection set is empty"},
+		{"variable in default", `query ($a: Int = $b) { products { has_more } }`, "variables are not allowed here"},
+		{"unknown operation type", `select { sku }`, "unknown operation type"},
+		{"unterminated string", `{ product(sku: "A) { sku } }`, "unterminated string"},
+		{"missing colon", `{ product(sku "A") { sku } }`, "expected :"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.query)
+			if err == nil {
+				t.Fatalf("Parse(%q) succeeded, want an error", test.query)
+			}
+			if !strings.Contains(err.Error(), test.reason) {
+				t.Errorf("got %q, want it to mention %q", err, test.reason)
+			}
+		})
+	}
+}
+
+func TestParseErrorLine(t *testing.T) {
+	_, err := Parse("{\n  products {\n    items { sku\n")
+	if err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("got %v, want a syntax error on line 4", err)
+	}
+}
@@ -0,0 +1,170 @@
+package graphql
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Schema is the root of a read-only GraphQL API
+type Schema struct {
+	Query *Object
+	// MaxDepth limits how deeply selections may nest; 0 means 10
+	MaxDepth int
+}
+
+// Object is a GraphQL object type
+type Object struct {
+	Name   string
+	Fields map[string]*FieldDef
+}
+
+// ResolveFunc returns a field's value for its parent object (source). Args holds the
+// field's arguments with variables already substituted.
+type ResolveFunc func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error)
+
+// FieldDef describes one field of an Object
+type FieldDef struct {
+	// Type is the object type the field returns, or nil for a scalar or JSON value.
+	// Slices of either are returned as lists.
+	Type *Object
+	// Args lists the argument names the field accepts
+	Args []string
+	// Resolve computes the value; nil reads the source's field with the same JSON name
+	Resolve ResolveFunc
+}
+
+// Types builds Objects from Go structs, reusing one Object per Go type so nested
+// structs shared by several models (such as an address) are one GraphQL type
+type Types struct {
+	byType map[reflect.Type]*Object
+}
+
+// NewTypes returns an empty type registry
+func NewTypes() *Types {
+	return &Types{byType: map[reflect.Type]*Object{}}
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Object returns the Object for sample's struct type, building it on first use. Fields
+// follow the struct's JSON names; nested structs become their own Objects named after
+// the Go type, and anything JSON encodes itself (times, ObjectIDs) or maps are scalars.
+func (t *Types) Object(name string, sample interface{}) *Object {
+	structType := reflect.TypeOf(sample)
+	for structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	object := t.objectFor(structType)
+	if name != "" {
+		object.Name = name
+	}
+	return object
+}
+
+func (t *Types) objectFor(structType reflect.Type) *Object {
+	if object, ok := t.byType[structType]; ok {
+		return object
+	}
+	object := &Object{Name: structType.Name(), Fields: map[string]*FieldDef{}}
+	t.byType[structType] = object
+	t.addFields(object, structType)
+	return object
+}
+
+func (t *Types) addFields(object *Object, structType reflect.Type) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			t.addFields(object, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		definition := &FieldDef{}
+		if elem := elementType(field.Type); isObjectType(elem) {
+			definition.Type = t.objectFor(elem)
+		}
+		object.Fields[name] = definition
+	}
+}
+
+// elementType unwraps pointers and slices to the type a list or field holds
+func elementType(fieldType reflect.Type) reflect.Type {
+	for fieldType.Kind() == reflect.Pointer || fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array {
+		fieldType = fieldType.Elem()
+	}
+	return fieldType
+}
+
+func isObjectType(fieldType reflect.Type) bool {
+	if fieldType.Kind() != reflect.Struct {
+		return false
+	}
+	pointer := reflect.PointerTo(fieldType)
+	return !fieldType.Implements(jsonMarshalerType) && !pointer.Implements(jsonMarshalerType) &&
+		!fieldType.Implements(textMarshalerType) && !pointer.Implements(textMarshalerType)
+}
+
+// defaultResolve reads the field named by its JSON name from a struct, or by key from
+// a map. Maps decoded from MongoDB store the id under _id.
+func defaultResolve(source interface{}, name string) interface{} {
+	value := reflect.ValueOf(source)
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		item := value.MapIndex(reflect.ValueOf(name).Convert(value.Type().Key()))
+		if !item.IsValid() && name == "id" {
+			item = value.MapIndex(reflect.ValueOf("_id").Convert(value.Type().Key()))
+		}
+		if !item.IsValid() {
+			return nil
+		}
+		return item.Interface()
+	case reflect.Struct:
+		return structField(value, name)
+	default:
+		return nil
+	}
+}
+
+func structField(value reflect.Value, name string) interface{} {
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && jsonName == "" && field.Type.Kind() == reflect.Struct {
+			if found := structField(value.Field(i), name); found != nil {
+				return found
+			}
+			continue
+		}
+		if jsonName == name || (jsonName == "" && field.Name == name) {
+			return value.Field(i).Interface()
+		}
+	}
+	return nil
+}
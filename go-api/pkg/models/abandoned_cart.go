@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// AbandonedCart is a snapshot of a cart that sat idle past the abandonment threshold
+type AbandonedCart struct {
+	ID            bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	SessionID     string        `json:"session_id" bson:"session_id"`
+	CustomerEmail string        `json:"customer_email,omitempty" bson:"customer_email,omitempty"`
+	Items         []CartItem    `json:"items" bson:"items"`
+	ItemCount     int           `json:"item_count" bson:"item_count"`
+	Subtotal      float64       `json:"subtotal" bson:"subtotal"`
+	Total         float64       `json:"total" bson:"total"`
+	LastActivity  time.Time     `json:"last_activity" bson:"last_activity"`
+	DetectedAt    time.Time     `json:"detected_at" bson:"detected_at"`
+	WebhookStatus string        `json:"webhook_status" bson:"webhook_status" validate:"oneof=skipped queued sent failed"` // sent is from before webhooks were queued
+	Recovered     bool          `json:"recovered" bson:"recovered"`
+}
+
+// NewAbandonedCart builds a snapshot from the current cart contents
+func NewAbandonedCart(cart *Cart, lastActivity time.Time) *AbandonedCart {
+	items := make([]CartItem, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		items = append(items, *item)
+	}
+
+	return &AbandonedCart{
+		SessionID:     cart.SessionID,
+		CustomerEmail: cart.CustomerEmail,
+		Items:         items,
+		ItemCount:     cart.ItemCount,
+		Subtotal:      cart.Subtotal,
+		Total:         cart.Total,
+		LastActivity:  lastActivity,
+		DetectedAt:    time.Now(),
+		WebhookStatus: "skipped",
+	}
+}
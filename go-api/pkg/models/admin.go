@@ -0,0 +1,89 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Admin account statuses
+const (
+	AdminInvited     = "invited" // waiting for the invitation to be accepted
+	AdminActive      = "active"
+	AdminDeactivated = "deactivated" // key no longer works; a new invitation reactivates the account
+)
+
+// Admin roles. Every admin key can still call every admin route; roles record what each
+// admin should be allowed to do until role checks are enforced.
+const (
+	AdminRoleOwner     = "owner" // manages other admins
+	AdminRoleCatalog   = "catalog"
+	AdminRoleOrders    = "orders"
+	AdminRoleCustomers = "customers"
+	AdminRoleAnalytics = "analytics"
+)
+
+// AdminKeyPrefix starts every personal admin key, telling it apart from ADMIN_API_KEY
+const AdminKeyPrefix = "adm_"
+
+// Admin is a person with admin access. They sign requests with a personal key in
+// X-Admin-Key, which is only stored hashed.
+type Admin struct {
+	ID              bson.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Email           string         `json:"email" bson:"email" validate:"required,email"`
+	Name            string         `json:"name,omitempty" bson:"name,omitempty"`
+	Roles           []string       `json:"roles" bson:"roles" validate:"required,min=1,dive,oneof=owner catalog orders customers analytics"`
+	Status          string         `json:"status" bson:"status" validate:"oneof=invited active deactivated"`
+	KeyHash         string         `json:"-" bson:"key_hash,omitempty"`
+	InviteTokenHash string         `json:"-" bson:"invite_token_hash,omitempty"`
+	InviteExpiresAt *time.Time     `json:"invite_expires_at,omitempty" bson:"invite_expires_at,omitempty"`
+	InvitedBy       *bson.ObjectID `json:"invited_by,omitempty" bson:"invited_by,omitempty"`       // empty when invited with ADMIN_API_KEY
+	LastLoginAt     *time.Time     `json:"last_login_at,omitempty" bson:"last_login_at,omitempty"` // last request made with the admin's key, to the minute
+	AcceptedAt      *time.Time     `json:"accepted_at,omitempty" bson:"accepted_at,omitempty"`
+	DeactivatedAt   *time.Time     `json:"deactivated_at,omitempty" bson:"deactivated_at,omitempty"`
+	CreatedAt       time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at" bson:"updated_at"`
+}
+
+// InviteAdminRequest invites someone to become an admin
+type InviteAdminRequest struct {
+	Email string   `json:"email" validate:"required,email"`
+	Name  string   `json:"name,omitempty" validate:"omitempty,max=100"`
+	Roles []string `json:"roles" validate:"required,min=1,dive,oneof=owner catalog orders customers analytics"`
+}
+
+// AcceptAdminInvitationRequest accepts an invitation with the token from its email
+type AcceptAdminInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+	Name  string `json:"name,omitempty" validate:"omitempty,max=100"`
+}
+
+// UpdateAdminRolesRequest replaces an admin's roles
+type UpdateAdminRolesRequest struct {
+	Roles []string `json:"roles" validate:"required,min=1,dive,oneof=owner catalog orders customers analytics"`
+}
+
+// AcceptedAdminInvitation is the newly active admin with their key, which is only ever
+// shown this once
+type AcceptedAdminInvitation struct {
+	Admin *Admin `json:"admin"`
+	Key   string `json:"key"`
+}
+
+// NewAdminSecret returns a random invitation token or key, with prefix, and the hash
+// that is stored in its place
+func NewAdminSecret(prefix string) (string, string) {
+	randomBytes := make([]byte, 32)
+	rand.Read(randomBytes)
+	secret := prefix + hex.EncodeToString(randomBytes)
+	return secret, HashAdminSecret(secret)
+}
+
+// HashAdminSecret hashes an invitation token or key for storage and lookup
+func HashAdminSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
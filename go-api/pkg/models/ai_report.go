@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ReportScheduleCadences maps each supported cadence to the time between runs
+var ReportScheduleCadences = map[string]time.Duration{
+	"hourly": time.Hour,
+	"daily":  24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+// ReportSchedule tells the report scheduler to generate an AI report on a fixed cadence
+type ReportSchedule struct {
+	ID         bson.ObjectID     `json:"id" bson:"_id,omitempty"`
+	ReportType string            `json:"report_type" bson:"report_type" validate:"required,oneof=sales-report customer-insights inventory-report product-analysis forecast"`
+	Cadence    string            `json:"cadence" bson:"cadence" validate:"required,oneof=hourly daily weekly"`
+	Params     map[string]string `json:"params,omitempty" bson:"params,omitempty"`
+	Enabled    bool              `json:"enabled" bson:"enabled"`
+	NextRunAt  time.Time         `json:"next_run_at" bson:"next_run_at"`
+	LastRunAt  *time.Time        `json:"last_run_at,omitempty" bson:"last_run_at,omitempty"`
+	LastError  string            `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	CreatedAt  time.Time         `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at" bson:"updated_at"`
+}
+
+// CreateReportScheduleRequest represents the request payload for scheduling an AI report
+type CreateReportScheduleRequest struct {
+	ReportType string            `json:"report_type" binding:"required,oneof=sales-report customer-insights inventory-report product-analysis forecast"`
+	Cadence    string            `json:"cadence" binding:"required,oneof=hourly daily weekly"`
+	Params     map[string]string `json:"params"`
+	Enabled    *bool             `json:"enabled"`
+	StartAt    *time.Time        `json:"start_at"` // first run; defaults to now
+}
+
+// StoredReport is a generated AI report kept for historical comparison
+type StoredReport struct {
+	ID          bson.ObjectID     `json:"id" bson:"_id,omitempty"`
+	ScheduleID  *bson.ObjectID    `json:"schedule_id,omitempty" bson:"schedule_id,omitempty"`
+	ReportType  string            `json:"report_type" bson:"report_type"`
+	Params      map[string]string `json:"params,omitempty" bson:"params,omitempty"`
+	Status      string            `json:"status" bson:"status"`
+	AIEnabled   bool              `json:"ai_enabled" bson:"ai_enabled"`
+	Data        StoredReportData  `json:"data" bson:"data"`
+	GeneratedAt time.Time         `json:"generated_at" bson:"generated_at"`
+}
+
+// StoredReportData mirrors the data section of an AI report response
+type StoredReportData struct {
+	RawData    interface{} `json:"raw_data" bson:"raw_data"`
+	AIInsights string      `json:"ai_insights,omitempty" bson:"ai_insights,omitempty"`
+	Summary    string      `json:"summary" bson:"summary"`
+	Error      string      `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// ToReportSchedule converts the request into a new schedule due at StartAt, or now
+func (req *CreateReportScheduleRequest) ToReportSchedule() *ReportSchedule {
+	now := time.Now()
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	nextRunAt := now
+	if req.StartAt != nil {
+		nextRunAt = *req.StartAt
+	}
+
+	return &ReportSchedule{
+		ReportType: req.ReportType,
+		Cadence:    req.Cadence,
+		Params:     req.Params,
+		Enabled:    enabled,
+		NextRunAt:  nextRunAt,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// Interval returns the time between runs of the schedule
+func (s *ReportSchedule) Interval() time.Duration {
+	if interval, ok := ReportScheduleCadences[s.Cadence]; ok {
+		return interval
+	}
+	return 24 * time.Hour
+}
+
+// NextRunAfter returns the first run time after ranAt on the schedule's cadence,
+// skipping runs that were missed while the server was down
+func (s *ReportSchedule) NextRunAfter(ranAt time.Time) time.Time {
+	interval := s.Interval()
+	next := s.NextRunAt.Add(interval)
+	for !next.After(ranAt) {
+		next = next.Add(interval)
+	}
+	return next
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// AuditLog records one write request against an audited resource. Writes to a single
+// product or order also record which fields they changed.
+type AuditLog struct {
+	ID          bson.ObjectID  `json:"id" bson:"_id,omitempty"`
+	RequestID   string         `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	EntityType  string         `json:"entity_type" bson:"entity_type"` // customer, order, product, inventory, coupon, gift_card, review or admin
+	EntityID    string         `json:"entity_id,omitempty" bson:"entity_id,omitempty"`
+	Method      string         `json:"method" bson:"method"`
+	Route       string         `json:"route" bson:"route"`
+	Path        string         `json:"path" bson:"path"`
+	Actor       string         `json:"actor" bson:"actor"`                           // admin or anonymous
+	AdminID     *bson.ObjectID `json:"admin_id,omitempty" bson:"admin_id,omitempty"` // admin whose personal key was used; empty for ADMIN_API_KEY
+	AdminEmail  string         `json:"admin_email,omitempty" bson:"admin_email,omitempty"`
+	ClientIP    string         `json:"client_ip" bson:"client_ip"`
+	RequestBody interface{}    `json:"request_body,omitempty" bson:"request_body,omitempty"` // redacted copy of the JSON body
+	Status      int            `json:"status" bson:"status"`
+	Changes     []AuditChange  `json:"changes,omitempty" bson:"changes,omitempty"`
+	CreatedAt   time.Time      `json:"created_at" bson:"created_at"`
+}
+
+// AuditChange is one field a write changed, by its dotted JSON path
+type AuditChange struct {
+	Field  string      `json:"field" bson:"field"`
+	Before interface{} `json:"before" bson:"before"`
+	After  interface{} `json:"after" bson:"after"`
+}
@@ -0,0 +1,23 @@
+package models
+
+// CacheStats describes what is in Redis
+type CacheStats struct {
+	TotalKeys int64              `json:"total_keys"`
+	Prefixes  []CachePrefixStats `json:"prefixes"` // most keys first
+	Memory    CacheMemory        `json:"memory"`
+}
+
+// CachePrefixStats counts the keys sharing a prefix, the key up to its first colon
+type CachePrefixStats struct {
+	Prefix string `json:"prefix"` // empty for keys without a colon
+	Keys   int64  `json:"keys"`
+}
+
+// CacheMemory is Redis's memory use as reported by INFO memory
+type CacheMemory struct {
+	UsedBytes      int64  `json:"used_bytes"`
+	UsedHuman      string `json:"used_human"`
+	PeakBytes      int64  `json:"peak_bytes"`
+	MaxBytes       int64  `json:"max_bytes"` // 0 when Redis has no memory limit
+	EvictionPolicy string `json:"eviction_policy"`
+}
@@ -1,32 +1,124 @@
 package models
 
+import "time"
+
 // Cart models for Redis session-based storage
 
 type CartItem struct {
-	ProductID   string  `json:"product_id" redis:"product_id"`
-	SKU         string  `json:"sku" redis:"sku"`
-	ProductName string  `json:"product_name" redis:"product_name"`
-	Price       float64 `json:"price" redis:"price"`
-	Quantity    int     `json:"quantity" redis:"quantity"`
-	Subtotal    float64 `json:"subtotal" redis:"subtotal"`
-	AddedAt     string  `json:"added_at" redis:"added_at"`
+	ProductID   string  `json:"product_id" bson:"product_id" redis:"product_id"`
+	SKU         string  `json:"sku" bson:"sku" redis:"sku"`
+	ProductName string  `json:"product_name" bson:"product_name" redis:"product_name"`
+	Price       float64 `json:"price" bson:"price" redis:"price"`
+	Quantity    int     `json:"quantity" bson:"quantity" redis:"quantity"`
+	Subtotal    float64 `json:"subtotal" bson:"subtotal" redis:"subtotal"`
+	WeightKg    float64 `json:"weight_kg,omitempty" bson:"weight_kg,omitempty" redis:"weight_kg"` // per unit
+	AddedAt     string  `json:"added_at" bson:"added_at" redis:"added_at"`
+
+	// Revalidation flags, populated when the cart is read and never persisted
+	PriceChanged   bool    `json:"price_changed,omitempty" bson:"-" redis:"-"`
+	PreviousPrice  float64 `json:"previous_price,omitempty" bson:"-" redis:"-"`
+	OutOfStock     bool    `json:"out_of_stock,omitempty" bson:"-" redis:"-"`
+	AvailableStock *int    `json:"available_stock,omitempty" bson:"-" redis:"-"`
+}
+
+// PayableQuantity is how many of the item can be bought: all of it, or only the stock
+// left when revalidation found too little
+func (item *CartItem) PayableQuantity() int {
+	if item.AvailableStock != nil && *item.AvailableStock < item.Quantity {
+		return max(*item.AvailableStock, 0)
+	}
+	return item.Quantity
+}
+
+// CartRevalidation summarises what changed when cart items were checked against the catalog
+type CartRevalidation struct {
+	CheckedAt    string `json:"checked_at"`
+	PriceChanges int    `json:"price_changes"`
+	OutOfStock   int    `json:"out_of_stock"`
 }
 
 type Cart struct {
-	SessionID   string               `json:"session_id"`
-	Items       map[string]*CartItem `json:"items"` // keyed by SKU
-	Subtotal    float64              `json:"subtotal"`
-	Tax         float64              `json:"tax"`
-	Shipping    float64              `json:"shipping"`
-	Total       float64              `json:"total"`
-	ItemCount   int                  `json:"item_count"`
-	LastUpdated string               `json:"last_updated"`
-	ExpiresAt   string               `json:"expires_at"`
+	SessionID      string               `json:"session_id"`
+	CustomerEmail  string               `json:"customer_email,omitempty"`    // Optional, used for abandonment recovery
+	LoyaltyTier    string               `json:"loyalty_tier,omitempty"`      // Tier of the customer with CustomerEmail
+	Province       string               `json:"shipping_province,omitempty"` // Taxed province; TAX_DEFAULT_PROVINCE until set
+	PostalCode     string               `json:"shipping_postal_code,omitempty"`
+	Items          map[string]*CartItem `json:"items"` // keyed by SKU
+	Subtotal       float64              `json:"subtotal"`
+	Coupon         *CartCoupon          `json:"coupon,omitempty"`
+	Discount       float64              `json:"discount"`
+	TierDiscount   float64              `json:"tier_discount"`
+	Tax            float64              `json:"tax"`
+	TaxRate        float64              `json:"tax_rate"`
+	ShippingMethod string               `json:"shipping_method"`
+	ShippingRates  []ShippingRate       `json:"shipping_rates,omitempty"` // quoted for every method
+	Shipping       float64              `json:"shipping"`                 // cost of ShippingMethod
+	Total          float64              `json:"total"`
+	ItemCount      int                  `json:"item_count"`
+	LastUpdated    string               `json:"last_updated"`
+	ExpiresAt      string               `json:"expires_at"`
+	Revalidation   *CartRevalidation    `json:"revalidation,omitempty"`
+}
+
+// PersistedCart is the MongoDB snapshot of a Redis cart, used to restore carts after the Redis TTL expires
+type PersistedCart struct {
+	SessionID      string               `json:"session_id" bson:"session_id"`
+	CustomerEmail  string               `json:"customer_email,omitempty" bson:"customer_email,omitempty"`
+	LoyaltyTier    string               `json:"loyalty_tier,omitempty" bson:"loyalty_tier,omitempty"`
+	Province       string               `json:"shipping_province,omitempty" bson:"shipping_province,omitempty"`
+	PostalCode     string               `json:"shipping_postal_code,omitempty" bson:"shipping_postal_code,omitempty"`
+	ShippingMethod string               `json:"shipping_method,omitempty" bson:"shipping_method,omitempty"`
+	Items          map[string]*CartItem `json:"items" bson:"items"`
+	Coupon         *CartCoupon          `json:"coupon,omitempty" bson:"coupon,omitempty"`
+	UpdatedAt      time.Time            `json:"updated_at" bson:"updated_at"`
+}
+
+// NewPersistedCart builds a snapshot from the current cart contents
+func NewPersistedCart(cart *Cart) *PersistedCart {
+	return &PersistedCart{
+		SessionID:      cart.SessionID,
+		CustomerEmail:  cart.CustomerEmail,
+		LoyaltyTier:    cart.LoyaltyTier,
+		Province:       cart.Province,
+		PostalCode:     cart.PostalCode,
+		ShippingMethod: cart.ShippingMethod,
+		Items:          cart.Items,
+		Coupon:         cart.Coupon,
+		UpdatedAt:      time.Now(),
+	}
+}
+
+// CartCoupon is a snapshot of the coupon applied to a cart, kept so the
+// discount can be recalculated whenever the cart contents change
+type CartCoupon struct {
+	Code        string  `json:"code" bson:"code" redis:"coupon_code"`
+	Type        string  `json:"type" bson:"type" redis:"coupon_type"`
+	Value       float64 `json:"value" bson:"value" redis:"coupon_value"`
+	MinSubtotal float64 `json:"min_subtotal" bson:"min_subtotal" redis:"coupon_min_subtotal"`
+}
+
+// ToCoupon converts the snapshot back into a coupon for discount calculation
+func (cc *CartCoupon) ToCoupon() *Coupon {
+	return &Coupon{
+		Code:        cc.Code,
+		Type:        cc.Type,
+		Value:       cc.Value,
+		MinSubtotal: cc.MinSubtotal,
+	}
 }
 
 type AddToCartRequest struct {
-	SKU      string `json:"sku" binding:"required"`
-	Quantity int    `json:"quantity" binding:"required,min=1"`
+	SKU           string `json:"sku" binding:"required"`
+	Quantity      int    `json:"quantity" binding:"required,min=1"`
+	CustomerEmail string `json:"customer_email" binding:"omitempty,email"`
+}
+
+// SetCartShippingRequest sets where and how a cart ships. The province sets its tax rate;
+// all three set its shipping rates. Omitted fields keep their current values.
+type SetCartShippingRequest struct {
+	Province   string `json:"province" validate:"required_without_all=PostalCode Method,omitempty,len=2,alpha"`
+	PostalCode string `json:"postal_code" validate:"omitempty,min=3,max=10"`
+	Method     string `json:"method" validate:"omitempty,oneof=standard express"`
 }
 
 type UpdateCartItemRequest struct {
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ChatMessage is a single turn in an analytics assistant conversation
+type ChatMessage struct {
+	Role      string    `json:"role"` // user or assistant
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ChatRequest represents the request payload for sending a message to the analytics assistant
+type ChatRequest struct {
+	SessionID string `json:"session_id" binding:"required,min=8,max=100"`
+	Message   string `json:"message" binding:"required,min=1,max=2000"`
+}
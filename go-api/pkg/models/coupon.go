@@ -0,0 +1,129 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Coupon represents a promo code that can be applied to carts and orders
+type Coupon struct {
+	ID               bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	Code             string        `json:"code" bson:"code" validate:"required,min=3,max=50"`
+	Description      string        `json:"description" bson:"description,omitempty" validate:"max=500"`
+	Type             string        `json:"type" bson:"type" validate:"required,oneof=percentage fixed"`
+	Value            float64       `json:"value" bson:"value" validate:"required,gt=0"`
+	MinSubtotal      float64       `json:"min_subtotal" bson:"min_subtotal" validate:"gte=0"`
+	ExpiresAt        *time.Time    `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	UsageLimit       int           `json:"usage_limit" bson:"usage_limit" validate:"gte=0"` // 0 means unlimited
+	UsageCount       int           `json:"usage_count" bson:"usage_count" validate:"gte=0"`
+	PerCustomerLimit int           `json:"per_customer_limit" bson:"per_customer_limit" validate:"gte=0"` // 0 means unlimited
+	Active           bool          `json:"active" bson:"active"`
+	CreatedAt        time.Time     `json:"created_at" bson:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at" bson:"updated_at"`
+}
+
+// CouponRedemption records a single use of a coupon against an order
+type CouponRedemption struct {
+	ID          bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	CouponCode  string        `json:"coupon_code" bson:"coupon_code"`
+	CustomerID  bson.ObjectID `json:"customer_id" bson:"customer_id"`
+	OrderNumber string        `json:"order_number" bson:"order_number"`
+	Discount    float64       `json:"discount" bson:"discount"`
+	RedeemedAt  time.Time     `json:"redeemed_at" bson:"redeemed_at"`
+}
+
+// CreateCouponRequest represents the request payload for creating a coupon
+type CreateCouponRequest struct {
+	Code             string     `json:"code" binding:"required,min=3,max=50"`
+	Description      string     `json:"description" binding:"max=500"`
+	Type             string     `json:"type" binding:"required,oneof=percentage fixed"`
+	Value            float64    `json:"value" binding:"required,gt=0"`
+	MinSubtotal      float64    `json:"min_subtotal" binding:"gte=0"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	UsageLimit       int        `json:"usage_limit" binding:"gte=0"`
+	PerCustomerLimit int        `json:"per_customer_limit" binding:"gte=0"`
+}
+
+// UpdateCouponRequest represents the request payload for updating a coupon
+type UpdateCouponRequest struct {
+	Description      *string    `json:"description,omitempty" binding:"omitempty,max=500"`
+	Value            *float64   `json:"value,omitempty" binding:"omitempty,gt=0"`
+	MinSubtotal      *float64   `json:"min_subtotal,omitempty" binding:"omitempty,gte=0"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	UsageLimit       *int       `json:"usage_limit,omitempty" binding:"omitempty,gte=0"`
+	PerCustomerLimit *int       `json:"per_customer_limit,omitempty" binding:"omitempty,gte=0"`
+	Active           *bool      `json:"active,omitempty"`
+}
+
+// ApplyCouponRequest represents the request payload for applying a coupon to a cart
+type ApplyCouponRequest struct {
+	Code       string `json:"code" binding:"required"`
+	CustomerID string `json:"customer_id"` // Optional, enables the per-customer limit check before checkout
+}
+
+// NormalizeCouponCode returns the canonical form of a coupon code
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ToCoupon converts the create request into a new active coupon
+func (req *CreateCouponRequest) ToCoupon() *Coupon {
+	now := time.Now()
+	return &Coupon{
+		Code:             NormalizeCouponCode(req.Code),
+		Description:      req.Description,
+		Type:             req.Type,
+		Value:            req.Value,
+		MinSubtotal:      req.MinSubtotal,
+		ExpiresAt:        req.ExpiresAt,
+		UsageLimit:       req.UsageLimit,
+		UsageCount:       0,
+		PerCustomerLimit: req.PerCustomerLimit,
+		Active:           true,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+}
+
+// SetTimestamps sets created_at and updated_at timestamps
+func (c *Coupon) SetTimestamps() {
+	now := time.Now()
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = now
+	}
+	c.UpdatedAt = now
+}
+
+// IsExpired checks if the coupon expiry date has passed
+func (c *Coupon) IsExpired() bool {
+	return c.ExpiresAt != nil && time.Now().After(*c.ExpiresAt)
+}
+
+// IsUsageExhausted checks if the coupon has reached its global usage limit
+func (c *Coupon) IsUsageExhausted() bool {
+	return c.UsageLimit > 0 && c.UsageCount >= c.UsageLimit
+}
+
+// CalculateDiscount returns the discount for the given subtotal, never exceeding the subtotal
+func (c *Coupon) CalculateDiscount(subtotal float64) float64 {
+	if subtotal <= 0 || subtotal < c.MinSubtotal {
+		return 0
+	}
+
+	var discount float64
+	switch c.Type {
+	case "percentage":
+		discount = subtotal * (c.Value / 100)
+	case "fixed":
+		discount = c.Value
+	}
+
+	if discount > subtotal {
+		discount = subtotal
+	}
+
+	return math.Round(discount*100) / 100
+}
@@ -44,6 +44,24 @@ type UpdateCustomerRequest struct {
 	AccountStatus *string      `json:"account_status,omitempty" validate:"omitempty,oneof=active inactive suspended deleted"`
 }
 
+// UpdatePreferencesRequest changes only the preferences it includes. An empty
+// favorite_categories list clears them.
+type UpdatePreferencesRequest struct {
+	Newsletter         *bool    `json:"newsletter,omitempty"`
+	SMSNotifications   *bool    `json:"sms_notifications,omitempty"`
+	EmailNotifications *bool    `json:"email_notifications,omitempty"`
+	Language           *string  `json:"language,omitempty" validate:"omitempty,oneof=en fr es"`
+	Currency           *string  `json:"currency,omitempty" validate:"omitempty,oneof=CAD USD EUR"`
+	FavoriteCategories []string `json:"favorite_categories,omitempty" validate:"omitempty,max=20,dive,min=1,max=100"`
+}
+
+// Lists a customer can unsubscribe from. Each is one of their preference flags.
+const (
+	ListEmail      = "email"      // email_notifications
+	ListSMS        = "sms"        // sms_notifications
+	ListNewsletter = "newsletter" // newsletter
+)
+
 type Preferences struct {
 	Newsletter         bool     `bson:"newsletter" json:"newsletter"`
 	SMSNotifications   bool     `bson:"sms_notifications" json:"sms_notifications"`
@@ -53,6 +71,34 @@ type Preferences struct {
 	FavoriteCategories []string `bson:"favorite_categories,omitempty" json:"favorite_categories,omitempty"`
 }
 
+// Allows reports whether the preferences let a customer be sent messages on a list
+func (p Preferences) Allows(list string) bool {
+	switch list {
+	case ListEmail:
+		return p.EmailNotifications
+	case ListSMS:
+		return p.SMSNotifications
+	case ListNewsletter:
+		return p.Newsletter
+	}
+	return false
+}
+
+// UnsubscribeFrom is the preferences change that takes a customer off a list
+func UnsubscribeFrom(list string) *UpdatePreferencesRequest {
+	off := false
+	req := &UpdatePreferencesRequest{}
+	switch list {
+	case ListEmail:
+		req.EmailNotifications = &off
+	case ListSMS:
+		req.SMSNotifications = &off
+	case ListNewsletter:
+		req.Newsletter = &off
+	}
+	return req
+}
+
 func (c *Customer) SetTimestamps() {
 	now := time.Now()
 	if c.CreatedAt.IsZero() {
@@ -145,6 +191,23 @@ func (c *Customer) CalculateLoyaltyTier() string {
 	}
 }
 
+// LoyaltyTierPointRange is the loyalty points a customer in tier holds: at least min
+// and, unless max is 0, fewer than max. It matches CalculateLoyaltyTier.
+func LoyaltyTierPointRange(tier string) (min, max int, ok bool) {
+	switch tier {
+	case "Platinum":
+		return 10000, 0, true
+	case "Gold":
+		return 5000, 10000, true
+	case "Silver":
+		return 1000, 5000, true
+	case "Bronze":
+		return 0, 1000, true
+	default:
+		return 0, 0, false
+	}
+}
+
 func (c *Customer) GetAverageOrderValue() float64 {
 	if c.TotalOrders == 0 {
 		return 0.0
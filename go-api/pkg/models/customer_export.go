@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Export statuses, shared by customer exports and report archives
+const (
+	ExportPending = "pending" // queued for the export worker
+	ExportRunning = "running" // leased by a worker
+	ExportReady   = "ready"   // file stored and downloadable
+	ExportFailed  = "failed"  // gave up after repeated errors
+)
+
+// CustomerExportRetention is how long a finished export is kept for download
+const CustomerExportRetention = 7 * 24 * time.Hour
+
+// CustomerExport is a queued or finished export of everything stored about a customer
+type CustomerExport struct {
+	ID          bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	CustomerID  bson.ObjectID `json:"customer_id" bson:"customer_id"`
+	Format      string        `json:"format" bson:"format" validate:"oneof=json zip"`
+	Status      string        `json:"status" bson:"status" validate:"oneof=pending running ready failed"`
+	Attempts    int           `json:"attempts" bson:"attempts"`
+	Error       string        `json:"error,omitempty" bson:"error,omitempty"`
+	Size        int           `json:"size_bytes,omitempty" bson:"size_bytes,omitempty"`
+	Data        []byte        `json:"-" bson:"data,omitempty"`
+	DownloadURL string        `json:"download_url,omitempty" bson:"-"` // signed, set when ready
+	AvailableAt time.Time     `json:"-" bson:"available_at"`           // lease expiry while running
+	CreatedAt   time.Time     `json:"created_at" bson:"created_at"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	ExpiresAt   time.Time     `json:"expires_at" bson:"expires_at"`
+}
+
+// FileName is the name the export downloads as
+func (e *CustomerExport) FileName() string {
+	return "customer-" + e.CustomerID.Hex() + "-export." + e.Format
+}
+
+// CustomerDataExport is the contents of a customer export
+type CustomerDataExport struct {
+	ExportedAt          time.Time            `json:"exported_at"`
+	Customer            *Customer            `json:"customer"`
+	Orders              []Order              `json:"orders"`
+	Reviews             []Review             `json:"reviews"`
+	CartSnapshots       []PersistedCart      `json:"cart_snapshots"`
+	AbandonedCarts      []AbandonedCart      `json:"abandoned_carts"`
+	SavedSearches       []SavedSearch        `json:"saved_searches"`
+	LoyaltyTransactions []LoyaltyTransaction `json:"loyalty_transactions"`
+	Notifications       []InboxNotification  `json:"notifications"`
+	AuditLogs           []AuditLog           `json:"audit_logs"`
+}
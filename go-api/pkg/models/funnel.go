@@ -0,0 +1,46 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// FunnelStage is the number of sessions or orders that reached a step of the sales funnel
+type FunnelStage struct {
+	Stage                  string  `json:"stage"`
+	Count                  int64   `json:"count"`
+	ConversionFromPrevious float64 `json:"conversion_from_previous"` // percentage of the previous stage
+	ConversionFromStart    float64 `json:"conversion_from_start"`    // percentage of the first stage
+}
+
+// SalesFunnel reports conversion across cart created → checkout started → order placed → order paid
+type SalesFunnel struct {
+	StartDate time.Time     `json:"start_date"`
+	EndDate   time.Time     `json:"end_date"`
+	Stages    []FunnelStage `json:"stages"`
+}
+
+// NewSalesFunnel builds the funnel from ordered stage counts and computes conversion rates
+func NewSalesFunnel(startDate, endDate time.Time, stages []FunnelStage) *SalesFunnel {
+	for i := range stages {
+		if i == 0 {
+			if stages[i].Count > 0 {
+				stages[i].ConversionFromPrevious = 100
+				stages[i].ConversionFromStart = 100
+			}
+			continue
+		}
+		stages[i].ConversionFromPrevious = conversionRate(stages[i].Count, stages[i-1].Count)
+		stages[i].ConversionFromStart = conversionRate(stages[i].Count, stages[0].Count)
+	}
+
+	return &SalesFunnel{StartDate: startDate, EndDate: endDate, Stages: stages}
+}
+
+// conversionRate returns count as a percentage of base, rounded to 2 decimals
+func conversionRate(count, base int64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return math.Round(float64(count)/float64(base)*10000) / 100
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// GiftCard represents a stored-value card that can be redeemed at checkout
+type GiftCard struct {
+	ID             bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	Code           string        `json:"code" bson:"code" validate:"required"`
+	InitialBalance float64       `json:"initial_balance" bson:"initial_balance" validate:"gt=0"`
+	Balance        float64       `json:"balance" bson:"balance" validate:"gte=0"`
+	Currency       string        `json:"currency" bson:"currency" validate:"required,len=3"`
+	Status         string        `json:"status" bson:"status" validate:"required,oneof=active disabled depleted"`
+	RecipientEmail string        `json:"recipient_email,omitempty" bson:"recipient_email,omitempty" validate:"omitempty,email"`
+	ExpiresAt      *time.Time    `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	CreatedAt      time.Time     `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at" bson:"updated_at"`
+}
+
+// GiftCardTransaction records every balance movement on a gift card
+type GiftCardTransaction struct {
+	ID           bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	Code         string        `json:"code" bson:"code"`
+	Type         string        `json:"type" bson:"type" validate:"oneof=issue redeem refund"`
+	Amount       float64       `json:"amount" bson:"amount"`
+	BalanceAfter float64       `json:"balance_after" bson:"balance_after"`
+	OrderNumber  string        `json:"order_number,omitempty" bson:"order_number,omitempty"`
+	CreatedAt    time.Time     `json:"created_at" bson:"created_at"`
+}
+
+// IssueGiftCardRequest represents the request payload for issuing a gift card
+type IssueGiftCardRequest struct {
+	Amount         float64    `json:"amount" binding:"required,gt=0"`
+	Currency       string     `json:"currency" binding:"omitempty,len=3"`
+	RecipientEmail string     `json:"recipient_email" binding:"omitempty,email"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+// GiftCardBalance is the public view returned by the balance check endpoint
+type GiftCardBalance struct {
+	Code      string     `json:"code"`
+	Balance   float64    `json:"balance"`
+	Currency  string     `json:"currency"`
+	Status    string     `json:"status"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// GenerateGiftCardCode creates a random code in the format GC-XXXX-XXXX-XXXX
+func GenerateGiftCardCode() string {
+	randomBytes := make([]byte, 6)
+	rand.Read(randomBytes)
+	hex := strings.ToUpper(fmt.Sprintf("%x", randomBytes))
+	return fmt.Sprintf("GC-%s-%s-%s", hex[0:4], hex[4:8], hex[8:12])
+}
+
+// ToGiftCard converts the issue request into a new active gift card
+func (req *IssueGiftCardRequest) ToGiftCard() *GiftCard {
+	now := time.Now()
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = "CAD"
+	}
+	amount := math.Round(req.Amount*100) / 100
+	return &GiftCard{
+		Code:           GenerateGiftCardCode(),
+		InitialBalance: amount,
+		Balance:        amount,
+		Currency:       currency,
+		Status:         "active",
+		RecipientEmail: req.RecipientEmail,
+		ExpiresAt:      req.ExpiresAt,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// IsExpired checks if the gift card expiry date has passed
+func (g *GiftCard) IsExpired() bool {
+	return g.ExpiresAt != nil && time.Now().After(*g.ExpiresAt)
+}
+
+// IsRedeemable checks if the gift card can currently be used
+func (g *GiftCard) IsRedeemable() bool {
+	return g.Status == "active" && g.Balance > 0 && !g.IsExpired()
+}
+
+// ToBalance returns the public balance view of the gift card
+func (g *GiftCard) ToBalance() *GiftCardBalance {
+	return &GiftCardBalance{
+		Code:      g.Code,
+		Balance:   g.Balance,
+		Currency:  g.Currency,
+		Status:    g.Status,
+		ExpiresAt: g.ExpiresAt,
+	}
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Import job statuses
+const (
+	ImportPending   = "pending"   // queued, or waiting to retry after an error
+	ImportRunning   = "running"   // leased by a worker
+	ImportCompleted = "completed" // every row was tried; failed counts the rows that were rejected
+	ImportFailed    = "failed"    // gave up after repeated errors; rows from processed on were not imported
+)
+
+// What an import job creates
+const (
+	ImportProducts = "products"
+	ImportOrders   = "orders"
+)
+
+// ImportJobRetention is how long a finished import job can still be looked up
+const ImportJobRetention = 7 * 24 * time.Hour
+
+// MaxImportRowErrors caps the row errors an import job keeps
+const MaxImportRowErrors = 100
+
+// ImportJob is a bulk product or order import run in the background. Its rows are
+// stored in import_job_rows until the job finishes.
+type ImportJob struct {
+	ID          bson.ObjectID    `json:"id" bson:"_id,omitempty"`
+	Kind        string           `json:"kind" bson:"kind" validate:"oneof=products orders"`
+	Status      string           `json:"status" bson:"status" validate:"oneof=pending running completed failed"`
+	Total       int              `json:"total" bson:"total"`
+	Processed   int              `json:"processed" bson:"processed"` // rows tried so far, in order
+	Succeeded   int              `json:"succeeded" bson:"succeeded"`
+	Failed      int              `json:"failed" bson:"failed"`
+	RowErrors   []ImportRowError `json:"row_errors" bson:"row_errors"` // the first MaxImportRowErrors rejected rows
+	Attempts    int              `json:"attempts" bson:"attempts"`
+	Error       string           `json:"error,omitempty" bson:"error,omitempty"` // last error that stopped the job
+	CreatedBy   *bson.ObjectID   `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	AvailableAt time.Time        `json:"-" bson:"available_at"` // lease expiry while running
+	CreatedAt   time.Time        `json:"created_at" bson:"created_at"`
+	StartedAt   *time.Time       `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	ExpiresAt   time.Time        `json:"expires_at" bson:"expires_at"`
+}
+
+// ImportRowError is why one row of an import was rejected
+type ImportRowError struct {
+	Row   int    `json:"row" bson:"row"` // index in the submitted array
+	Error string `json:"error" bson:"error"`
+}
+
+// ImportJobRow is one submitted product or order waiting to be imported
+type ImportJobRow struct {
+	JobID     bson.ObjectID `bson:"job_id"`
+	Row       int           `bson:"row"`
+	Data      bson.Raw      `bson:"data"`
+	ExpiresAt time.Time     `bson:"expires_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Kinds of in-app notification
+const (
+	InboxOrderUpdate = "order_update"
+	InboxPriceDrop   = "price_drop"
+	InboxLoyalty     = "loyalty"
+)
+
+// InboxNotification is a message shown in a customer's in-app notification inbox
+type InboxNotification struct {
+	ID          bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	CustomerID  bson.ObjectID `json:"customer_id" bson:"customer_id"`
+	Kind        string        `json:"kind" bson:"kind"`
+	Title       string        `json:"title" bson:"title"`
+	Message     string        `json:"message" bson:"message"`
+	OrderNumber string        `json:"order_number,omitempty" bson:"order_number,omitempty"`
+	SKU         string        `json:"sku,omitempty" bson:"sku,omitempty"`
+	DedupeKey   string        `json:"-" bson:"dedupe_key"` // the event it came from, so instances add it once
+	Read        bool          `json:"read" bson:"read"`
+	ReadAt      *time.Time    `json:"read_at,omitempty" bson:"read_at,omitempty"`
+	CreatedAt   time.Time     `json:"created_at" bson:"created_at"`
+}
+
+// NewInboxNotification builds an unread notification for customerID from the domain
+// event with eventID
+func NewInboxNotification(customerID bson.ObjectID, kind, eventID, title, message string) *InboxNotification {
+	return &InboxNotification{
+		CustomerID: customerID,
+		Kind:       kind,
+		Title:      title,
+		Message:    message,
+		DedupeKey:  eventID + ":" + customerID.Hex(),
+		CreatedAt:  time.Now(),
+	}
+}
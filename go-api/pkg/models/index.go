@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// CollectionIndex is an index that exists on a collection, with how often queries use it
+type CollectionIndex struct {
+	Collection         string     `json:"collection" bson:"-"`
+	Name               string     `json:"name" bson:"name"`
+	Keys               bson.D     `json:"keys" bson:"key"`
+	Unique             bool       `json:"unique,omitempty" bson:"unique,omitempty"`
+	Sparse             bool       `json:"sparse,omitempty" bson:"sparse,omitempty"`
+	ExpireAfterSeconds *int64     `json:"expire_after_seconds,omitempty" bson:"expireAfterSeconds,omitempty"`
+	PartialFilter      bson.D     `json:"partial_filter,omitempty" bson:"partialFilterExpression,omitempty"`
+	Required           bool       `json:"required" bson:"-"`              // created by the API at startup
+	Accesses           int64      `json:"accesses" bson:"-"`              // operations that used the index since usage_since
+	UsageSince         *time.Time `json:"usage_since,omitempty" bson:"-"` // when MongoDB started counting, usually its last restart
+}
+
+// Outcomes of ensuring a required index
+const (
+	IndexExists  = "exists"
+	IndexCreated = "created"
+	IndexSkipped = "skipped" // existing documents break the index's unique constraint
+	IndexFailed  = "failed"
+)
+
+// EnsuredIndex is what ensuring one required index did
+type EnsuredIndex struct {
+	Collection string `json:"collection"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Warehouses lists the warehouse names, which match the Stock bson field names
+var Warehouses = []string{"warehouse_main", "warehouse_east", "warehouse_west"}
+
+// InventoryItem is the stock view of a product
+type InventoryItem struct {
+	ProductID bson.ObjectID `json:"product_id" bson:"_id"`
+	SKU       string        `json:"sku" bson:"sku"`
+	Name      string        `json:"name" bson:"name"`
+	Category  string        `json:"category" bson:"category"`
+	Status    string        `json:"status" bson:"status"`
+	Stock     Stock         `json:"stock" bson:"stock"`
+	UpdatedAt time.Time     `json:"updated_at" bson:"updated_at"`
+}
+
+// InventoryDetail is a single product's stock with its most recent inventory changes
+type InventoryDetail struct {
+	InventoryItem
+	RecentLogs []InventoryLog `json:"recent_logs"`
+}
+
+// AdjustInventoryRequest represents a relative stock change in a single warehouse
+type AdjustInventoryRequest struct {
+	SKU         string `json:"sku" binding:"required,min=3,max=50"`
+	Warehouse   string `json:"warehouse" binding:"required,oneof=warehouse_main warehouse_east warehouse_west"`
+	Quantity    int    `json:"quantity" binding:"required,ne=0"` // Positive adds stock, negative removes it
+	ChangeType  string `json:"change_type" binding:"required,oneof=adjustment purchase sale return damage lost transfer"`
+	Reason      string `json:"reason" binding:"required,min=5,max=500"`
+	PerformedBy string `json:"performed_by" binding:"required,min=2,max=100"`
+	Notes       string `json:"notes" binding:"max=1000"`
+}
+
+// SetInventoryLevelsRequest represents absolute stock levels per warehouse. Omitted warehouses are unchanged.
+type SetInventoryLevelsRequest struct {
+	WarehouseMain *int   `json:"warehouse_main" binding:"omitempty,gte=0"`
+	WarehouseEast *int   `json:"warehouse_east" binding:"omitempty,gte=0"`
+	WarehouseWest *int   `json:"warehouse_west" binding:"omitempty,gte=0"`
+	Reason        string `json:"reason" binding:"required,min=5,max=500"`
+	PerformedBy   string `json:"performed_by" binding:"required,min=2,max=100"`
+	Notes         string `json:"notes" binding:"max=1000"`
+}
+
+// Levels returns the requested levels keyed by warehouse name
+func (req *SetInventoryLevelsRequest) Levels() map[string]int {
+	levels := map[string]int{}
+	if req.WarehouseMain != nil {
+		levels["warehouse_main"] = *req.WarehouseMain
+	}
+	if req.WarehouseEast != nil {
+		levels["warehouse_east"] = *req.WarehouseEast
+	}
+	if req.WarehouseWest != nil {
+		levels["warehouse_west"] = *req.WarehouseWest
+	}
+	return levels
+}
+
+// Warehouse returns the stock level for a warehouse name
+func (s *Stock) Warehouse(warehouse string) int {
+	switch warehouse {
+	case "warehouse_main":
+		return s.WarehouseMain
+	case "warehouse_east":
+		return s.WarehouseEast
+	case "warehouse_west":
+		return s.WarehouseWest
+	}
+	return 0
+}
+
+// NewInventoryItem builds the stock view of a product
+func NewInventoryItem(product *Product) *InventoryItem {
+	return &InventoryItem{
+		ProductID: product.ID,
+		SKU:       product.SKU,
+		Name:      product.Name,
+		Category:  product.Category,
+		Status:    product.Status,
+		Stock:     product.Stock,
+		UpdatedAt: product.UpdatedAt,
+	}
+}
+
+// InventoryRecount records a physical cycle count of one warehouse and its variance against recorded stock.
+// Counts that request a correction wait in "pending" until approved.
+type InventoryRecount struct {
+	ID               bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	ProductID        bson.ObjectID `json:"product_id" bson:"product_id"`
+	SKU              string        `json:"sku" bson:"sku"`
+	Warehouse        string        `json:"warehouse" bson:"warehouse"`
+	RecordedQuantity int           `json:"recorded_quantity" bson:"recorded_quantity"`
+	CountedQuantity  int           `json:"counted_quantity" bson:"counted_quantity"`
+	Variance         int           `json:"variance" bson:"variance"` // counted - recorded
+	Status           string        `json:"status" bson:"status" validate:"oneof=matched recorded pending applied rejected"`
+	CountedBy        string        `json:"counted_by" bson:"counted_by"`
+	Notes            string        `json:"notes,omitempty" bson:"notes,omitempty"`
+	ReviewedBy       string        `json:"reviewed_by,omitempty" bson:"reviewed_by,omitempty"`
+	ReviewNotes      string        `json:"review_notes,omitempty" bson:"review_notes,omitempty"`
+	ReviewedAt       *time.Time    `json:"reviewed_at,omitempty" bson:"reviewed_at,omitempty"`
+	CreatedAt        time.Time     `json:"created_at" bson:"created_at"`
+}
+
+// CreateRecountRequest represents a physical count submitted for one warehouse
+type CreateRecountRequest struct {
+	Warehouse       string `json:"warehouse" binding:"required,oneof=warehouse_main warehouse_east warehouse_west"`
+	CountedQuantity *int   `json:"counted_quantity" binding:"required,gte=0"`
+	CountedBy       string `json:"counted_by" binding:"required,min=2,max=100"`
+	Notes           string `json:"notes" binding:"max=1000"`
+	AutoCorrect     bool   `json:"auto_correct"` // Queue a stock correction for approval when there is a variance
+}
+
+// ReviewRecountRequest represents the approval or rejection of a pending recount correction
+type ReviewRecountRequest struct {
+	ReviewedBy string `json:"reviewed_by" binding:"required,min=2,max=100"`
+	Notes      string `json:"notes" binding:"max=1000"`
+}
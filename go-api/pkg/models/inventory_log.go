@@ -19,7 +19,7 @@ type InventoryLog struct {
 	Reason          string        `bson:"reason" json:"reason" validate:"required,min=5,max=500"`
 	PerformedBy     string        `bson:"performed_by" json:"performed_by" validate:"required,min=2,max=100"` // User ID or system name
 	Notes           string        `bson:"notes,omitempty" json:"notes,omitempty" validate:"max=1000"`
-	CreatedAt       time.Time     `bson:"created_at" json:"created_at"`
+	CreatedAt       time.Time     `bson:"timestamp" json:"created_at"`
 }
 
 // SetTimestamp sets the creation timestamp
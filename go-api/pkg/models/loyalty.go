@@ -0,0 +1,54 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Loyalty transaction types
+const (
+	LoyaltyEarn   = "earn"   // points for a delivered order
+	LoyaltyRedeem = "redeem" // points spent as an order discount
+	LoyaltyRefund = "refund" // redeemed points returned when the order is cancelled
+)
+
+// LoyaltyTransaction records every change to a customer's loyalty points balance
+type LoyaltyTransaction struct {
+	ID           bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	CustomerID   bson.ObjectID `json:"customer_id" bson:"customer_id"`
+	Type         string        `json:"type" bson:"type" validate:"oneof=earn redeem refund"`
+	Points       int           `json:"points" bson:"points"` // negative for redemptions
+	BalanceAfter int           `json:"balance_after" bson:"balance_after"`
+	OrderNumber  string        `json:"order_number,omitempty" bson:"order_number,omitempty"`
+	Amount       float64       `json:"amount,omitempty" bson:"amount,omitempty"` // order discount for redemptions
+	CreatedAt    time.Time     `json:"created_at" bson:"created_at"`
+}
+
+// RedeemLoyaltyRequest represents the request payload for redeeming points on an order
+type RedeemLoyaltyRequest struct {
+	OrderNumber string `json:"order_number" binding:"required"`
+	Points      int    `json:"points" binding:"required,gt=0"`
+}
+
+// LoyaltyRedemption is the discounted order and the ledger entry for a redemption
+type LoyaltyRedemption struct {
+	Order       *Order              `json:"order"`
+	Transaction *LoyaltyTransaction `json:"transaction"`
+}
+
+// LoyaltyPointsEarned returns the points a delivered order earns: the configured points
+// per whole dollar of its subtotal after coupon, tier and loyalty discounts
+func LoyaltyPointsEarned(o *Order) int {
+	spent := o.Totals.Subtotal - o.Totals.Discount - o.Totals.Tier - o.Totals.Loyalty
+	if spent <= 0 {
+		return 0
+	}
+	return int(math.Floor(spent)) * pricing.LoyaltyPointsPerDollar
+}
+
+// LoyaltyPointsValue returns the order discount in dollars that points are worth
+func LoyaltyPointsValue(points int) float64 {
+	return math.Round(float64(points)*pricing.LoyaltyPointValue*100) / 100
+}
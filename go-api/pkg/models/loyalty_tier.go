@@ -0,0 +1,84 @@
+package models
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// LoyaltyTierRulesID is the _id of the single loyalty tier rules document
+const LoyaltyTierRulesID = "loyalty_tiers"
+
+// LoyaltyTierBenefits are the perks a loyalty tier (see Customer.CalculateLoyaltyTier)
+// gives on carts and orders
+type LoyaltyTierBenefits struct {
+	Tier                  string   `json:"tier" bson:"tier" binding:"required,oneof=Bronze Silver Gold Platinum"`
+	DiscountPercent       float64  `json:"discount_percent" bson:"discount_percent" binding:"gte=0,lte=100"`
+	FreeShippingThreshold *float64 `json:"free_shipping_threshold,omitempty" bson:"free_shipping_threshold,omitempty" binding:"omitempty,gte=0"` // nil keeps the standard threshold
+	EarlyAccess           bool     `json:"early_access" bson:"early_access"`
+}
+
+// LoyaltyTierRules is the admin-managed document holding every tier's benefits
+type LoyaltyTierRules struct {
+	ID        string                `json:"-" bson:"_id"`
+	Tiers     []LoyaltyTierBenefits `json:"tiers" bson:"tiers"`
+	UpdatedAt time.Time             `json:"updated_at" bson:"updated_at"`
+}
+
+// UpdateLoyaltyTierRulesRequest replaces the benefits of every tier. Tiers left out get
+// no benefits.
+type UpdateLoyaltyTierRulesRequest struct {
+	Tiers []LoyaltyTierBenefits `json:"tiers" binding:"required,dive"`
+}
+
+// DefaultLoyaltyTierRules are used until an admin saves rules of their own
+func DefaultLoyaltyTierRules() *LoyaltyTierRules {
+	freeShippingAt := func(subtotal float64) *float64 { return &subtotal }
+	return &LoyaltyTierRules{
+		ID: LoyaltyTierRulesID,
+		Tiers: []LoyaltyTierBenefits{
+			{Tier: "Bronze"},
+			{Tier: "Silver", FreeShippingThreshold: freeShippingAt(75)},
+			{Tier: "Gold", DiscountPercent: 5, FreeShippingThreshold: freeShippingAt(50)},
+			{Tier: "Platinum", DiscountPercent: 10, FreeShippingThreshold: freeShippingAt(0), EarlyAccess: true},
+		},
+	}
+}
+
+// tierRules holds the rules applied to totals, or nil for the defaults. The domain
+// event worker swaps them when an admin saves new rules, so they are read and replaced
+// atomically.
+var tierRules atomic.Pointer[LoyaltyTierRules]
+
+// SetLoyaltyTierRules replaces the rules applied to cart and order totals
+func SetLoyaltyTierRules(rules *LoyaltyTierRules) {
+	tierRules.Store(rules)
+}
+
+// LoyaltyTierBenefitsFor returns the current benefits of tier, which are empty for an
+// unknown tier or one without rules
+func LoyaltyTierBenefitsFor(tier string) LoyaltyTierBenefits {
+	rules := tierRules.Load()
+	if rules == nil {
+		rules = DefaultLoyaltyTierRules()
+	}
+	for _, benefits := range rules.Tiers {
+		if benefits.Tier == tier {
+			return benefits
+		}
+	}
+	return LoyaltyTierBenefits{Tier: tier}
+}
+
+// Discount returns the tier discount on a subtotal after any coupon discount
+func (b LoyaltyTierBenefits) Discount(discountedSubtotal float64) float64 {
+	if b.DiscountPercent <= 0 || discountedSubtotal <= 0 {
+		return 0
+	}
+	return math.Round(discountedSubtotal*b.DiscountPercent) / 100
+}
+
+// FreeShipping reports whether the tier ships a subtotal for free
+func (b LoyaltyTierBenefits) FreeShipping(subtotal float64) bool {
+	return b.FreeShippingThreshold != nil && subtotal >= *b.FreeShippingThreshold
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Notification delivery statuses
+const (
+	NotificationPending = "pending" // waiting for its first or next attempt
+	NotificationSent    = "sent"
+	NotificationDead    = "dead"    // dead-lettered after its last attempt or a permanent failure
+	NotificationSkipped = "skipped" // not sent because the customer unsubscribed or was deleted
+)
+
+// Notification channels. Email and SMS channels match the template channels.
+const (
+	NotificationEmail   = TemplateChannelEmail
+	NotificationSMS     = TemplateChannelSMS
+	NotificationWebhook = "webhook"
+)
+
+// Notification is an outbound message in the delivery queue. Webhook notifications carry
+// their JSON payload in Body.
+type Notification struct {
+	ID          bson.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Channel     string         `json:"channel" bson:"channel" validate:"oneof=email sms webhook"`
+	Recipient   string         `json:"recipient" bson:"recipient"`                         // email address, phone number or URL
+	CustomerID  *bson.ObjectID `json:"customer_id,omitempty" bson:"customer_id,omitempty"` // set for notifications to a customer, whose preferences are checked before sending
+	List        string         `json:"list,omitempty" bson:"list,omitempty" validate:"omitempty,oneof=email sms newsletter"`
+	Template    string         `json:"template,omitempty" bson:"template,omitempty"`
+	Version     int            `json:"template_version,omitempty" bson:"template_version,omitempty"`
+	Event       string         `json:"event,omitempty" bson:"event,omitempty"`           // what the notification is about, such as cart.abandoned
+	DedupeKey   string         `json:"dedupe_key,omitempty" bson:"dedupe_key,omitempty"` // queued at most once per key
+	Subject     string         `json:"subject,omitempty" bson:"subject,omitempty"`
+	Body        string         `json:"body" bson:"body"`
+	Status      string         `json:"status" bson:"status" validate:"oneof=pending sent dead skipped"`
+	Attempts    int            `json:"attempts" bson:"attempts"`
+	LastError   string         `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	AvailableAt time.Time      `json:"available_at" bson:"available_at"` // not claimable before this time
+	CreatedAt   time.Time      `json:"created_at" bson:"created_at"`
+	SentAt      *time.Time     `json:"sent_at,omitempty" bson:"sent_at,omitempty"`
+	DeadAt      *time.Time     `json:"dead_at,omitempty" bson:"dead_at,omitempty"`
+}
+
+// NewNotification builds a pending notification from a rendered template
+func NewNotification(rendered *RenderedTemplate, recipient, event string) *Notification {
+	now := time.Now()
+	return &Notification{
+		Channel:     rendered.Channel,
+		Recipient:   recipient,
+		Template:    rendered.Key,
+		Version:     rendered.Version,
+		Event:       event,
+		Subject:     rendered.Subject,
+		Body:        rendered.Body,
+		Status:      NotificationPending,
+		AvailableAt: now,
+		CreatedAt:   now,
+	}
+}
@@ -2,19 +2,81 @@ package models
 
 import (
 	"fmt"
+	"math"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
 )
 
+// pricing holds the settings set by ConfigurePricing
+var pricing config.PricingConfig
+
+// ConfigurePricing sets the default tax province and loyalty rates used to total orders
+// and carts
+func ConfigurePricing(cfg config.PricingConfig) {
+	pricing = cfg
+}
+
 type CreateOrderRequest struct {
 	CustomerID      bson.ObjectID `json:"customer_id" bson:"customer_id" validate:"required"`
 	CustomerEmail   string        `json:"customer_email" bson:"customer_email" validate:"required,email"`
 	Items           []OrderItem   `json:"items" bson:"items" validate:"required,min=1,dive"`
-	ShippingAddress Address       `json:"shipping_address" bson:"shipping_address" validate:"required"`
-	BillingAddress  *Address      `json:"billing_address" bson:"billing_address,omitempty"`
-	Payment         Payment       `json:"payment" bson:"payment" validate:"required"`
-	Notes           string        `json:"notes" bson:"notes,omitempty"`
+	ShippingAddress *Address      `json:"shipping_address" bson:"shipping_address,omitempty" validate:"required_without=ShippingAddressIndex,excluded_with=ShippingAddressIndex"`
+	BillingAddress  *Address      `json:"billing_address" bson:"billing_address,omitempty" validate:"excluded_with=BillingAddressIndex"`
+	// Saved addresses can be used instead, by their index in the customer's addresses.
+	// Billing defaults to the shipping address when neither billing field is set.
+	ShippingAddressIndex *int          `json:"shipping_address_index,omitempty" bson:"shipping_address_index,omitempty" validate:"omitempty,gte=0"`
+	BillingAddressIndex  *int          `json:"billing_address_index,omitempty" bson:"billing_address_index,omitempty" validate:"omitempty,gte=0"`
+	Payment              PaymentMethod `json:"payment" bson:"payment" validate:"required"`
+	ShippingMethod       string        `json:"shipping_method" bson:"shipping_method,omitempty" validate:"omitempty,oneof=standard express"` // defaults to standard
+	CouponCode           string        `json:"coupon_code" bson:"coupon_code,omitempty"`
+	GiftCardCode         string        `json:"gift_card_code" bson:"gift_card_code,omitempty"`
+	GiftCardAmount       float64       `json:"gift_card_amount" bson:"gift_card_amount,omitempty"` // Optional, defaults to the full available balance
+	Notes                string        `json:"notes" bson:"notes,omitempty"`
+}
+
+// ResolveAddresses returns the order's shipping and billing addresses, looking up saved
+// addresses in customer. Billing is a copy of shipping unless one was given.
+func (req *CreateOrderRequest) ResolveAddresses(customer *Customer) (Address, *Address, error) {
+	savedAddress := func(field string, index int) (*Address, error) {
+		if customer == nil {
+			return nil, fmt.Errorf("%s needs an existing customer", field)
+		}
+		if index >= len(customer.Addresses) {
+			return nil, fmt.Errorf("%s %d is out of range: the customer has %d saved address(es)", field, index, len(customer.Addresses))
+		}
+		address := customer.Addresses[index]
+		address.IsDefault = false
+		return &address, nil
+	}
+
+	shipping := req.ShippingAddress
+	if req.ShippingAddressIndex != nil {
+		address, err := savedAddress("shipping_address_index", *req.ShippingAddressIndex)
+		if err != nil {
+			return Address{}, nil, err
+		}
+		shipping = address
+	}
+	if shipping == nil {
+		return Address{}, nil, fmt.Errorf("shipping_address or shipping_address_index is required")
+	}
+
+	billing := req.BillingAddress
+	if req.BillingAddressIndex != nil {
+		address, err := savedAddress("billing_address_index", *req.BillingAddressIndex)
+		if err != nil {
+			return Address{}, nil, err
+		}
+		billing = address
+	}
+	if billing == nil {
+		copied := *shipping
+		billing = &copied
+	}
+
+	return *shipping, billing, nil
 }
 
 // OrderItem represents a single item in an order
@@ -24,7 +86,16 @@ type OrderItem struct {
 	Name      string        `json:"name" bson:"name" validate:"required"`
 	Quantity  int           `json:"quantity" bson:"quantity" validate:"required,gte=1"`
 	UnitPrice float64       `json:"unit_price" bson:"unit_price" validate:"required,gt=0"`
-	Subtotal  float64       `json:"subtotal" bson:"subtotal" validate:"required,gte=0"`
+	WeightKg  float64       `json:"weight_kg,omitempty" bson:"weight_kg,omitempty"` // per unit, taken from the product
+	Subtotal  float64       `json:"subtotal" bson:"subtotal" validate:"gte=0"`      // recalculated from unit_price and quantity
+	// Allocations records which warehouses the item ships from, set when the order moves to processing
+	Allocations []WarehouseAllocation `json:"allocations,omitempty" bson:"allocations,omitempty"`
+}
+
+// WarehouseAllocation is the quantity of an order item reserved from a single warehouse
+type WarehouseAllocation struct {
+	Warehouse string `json:"warehouse" bson:"warehouse"`
+	Quantity  int    `json:"quantity" bson:"quantity" validate:"gte=1"`
 }
 
 // Address represents shipping or billing address
@@ -43,14 +114,30 @@ type OrderTotals struct {
 	Tax        float64 `json:"tax" bson:"tax" validate:"gte=0"`
 	Shipping   float64 `json:"shipping" bson:"shipping" validate:"gte=0"`
 	Discount   float64 `json:"discount" bson:"discount" validate:"gte=0"`
-	GrandTotal float64 `json:"grand_total" bson:"grand_total" validate:"gt=0"`
+	Tier       float64 `json:"tier_discount" bson:"tier_discount" validate:"gte=0"` // Loyalty tier discount
+	Loyalty    float64 `json:"loyalty" bson:"loyalty" validate:"gte=0"`             // Discount paid with loyalty points
+	GrandTotal float64 `json:"grand_total" bson:"grand_total" validate:"gte=0"`
+	GiftCard   float64 `json:"gift_card" bson:"gift_card" validate:"gte=0"`   // Amount paid by gift card
+	AmountDue  float64 `json:"amount_due" bson:"amount_due" validate:"gte=0"` // Remaining balance after gift card
+	TaxRate    float64 `json:"tax_rate" bson:"tax_rate" validate:"gte=0"`     // Combined rate of TaxRule
+	Refunded   float64 `json:"refunded" bson:"refunded" validate:"gte=0"`     // Returned through the payment provider
 }
 
 // Payment represents payment information for an order
+// Status and the amounts are only set by the payment provider, never by the client.
 type Payment struct {
-	Method        string `json:"method" bson:"method" validate:"required,oneof=credit_card debit_card paypal cash"`
-	Status        string `json:"status" bson:"status" validate:"required,oneof=pending completed failed refunded"`
-	TransactionID string `json:"transaction_id" bson:"transaction_id"`
+	Method         string  `json:"method" bson:"method" validate:"required,oneof=credit_card debit_card paypal cash"`
+	Status         string  `json:"status" bson:"status" validate:"required,oneof=pending completed failed refunded"`
+	TransactionID  string  `json:"transaction_id" bson:"transaction_id"`                       // Stripe PaymentIntent or PayPal capture that paid the order
+	IntentID       string  `json:"intent_id,omitempty" bson:"intent_id,omitempty"`             // latest Stripe PaymentIntent or PayPal order started for the order
+	Amount         float64 `json:"amount,omitempty" bson:"amount,omitempty"`                   // amount charged
+	Refunded       float64 `json:"refunded,omitempty" bson:"refunded,omitempty"`               // total refunded so far
+	FailureMessage string  `json:"failure_message,omitempty" bson:"failure_message,omitempty"` // why the last attempt was declined
+}
+
+// PaymentMethod is how a new order will be paid; its payment always starts pending
+type PaymentMethod struct {
+	Method string `json:"method" validate:"required,oneof=credit_card debit_card paypal cash"`
 }
 
 // Timeline tracks the lifecycle of an order
@@ -63,22 +150,46 @@ type Timeline struct {
 	EstimatedDelivery *time.Time `json:"estimated_delivery" bson:"estimated_delivery,omitempty"`
 }
 
+// Order event types sent to GET /api/orders/:orderNumber/events
+const (
+	OrderEventSnapshot = "snapshot" // current state, sent when a client connects
+	OrderEventStatus   = "status"   // the status changed and the timeline was stamped
+)
+
+// OrderStatusEvent describes an order's status and timeline after a change
+type OrderStatusEvent struct {
+	OrderNumber    string    `json:"order_number"`
+	Status         string    `json:"status"`
+	PreviousStatus string    `json:"previous_status,omitempty"`
+	Timeline       Timeline  `json:"timeline"`
+	Tracking       *Tracking `json:"tracking,omitempty"`
+}
+
 // Order represents a customer order in the e-commerce system
 type Order struct {
-	ID              bson.ObjectID `json:"id" bson:"_id,omitempty"`
-	OrderNumber     string        `json:"order_number" bson:"order_number" validate:"required"`
-	CustomerID      bson.ObjectID `json:"customer_id" bson:"customer_id" validate:"required"`
-	CustomerEmail   string        `json:"customer_email" bson:"customer_email" validate:"required,email"`
-	Status          string        `json:"status" bson:"status" validate:"required,oneof=pending processing shipped delivered cancelled"`
-	Items           []OrderItem   `json:"items" bson:"items" validate:"required,min=1,dive"`
-	Totals          OrderTotals   `json:"totals" bson:"totals"`
-	ShippingAddress Address       `json:"shipping_address" bson:"shipping_address"`
-	BillingAddress  *Address      `json:"billing_address" bson:"billing_address,omitempty"`
-	Payment         Payment       `json:"payment" bson:"payment"`
-	Timeline        Timeline      `json:"timeline" bson:"timeline"`
-	Notes           string        `json:"notes" bson:"notes,omitempty"`
-	CreatedAt       time.Time     `json:"created_at" bson:"created_at"`
-	UpdatedAt       time.Time     `json:"updated_at" bson:"updated_at"`
+	ID               bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	OrderNumber      string        `json:"order_number" bson:"order_number" validate:"required"`
+	CustomerID       bson.ObjectID `json:"customer_id" bson:"customer_id" validate:"required"`
+	CustomerEmail    string        `json:"customer_email" bson:"customer_email" validate:"required,email"`
+	Status           string        `json:"status" bson:"status" validate:"required,oneof=pending processing shipped delivered cancelled"`
+	Items            []OrderItem   `json:"items" bson:"items" validate:"required,min=1,dive"`
+	Totals           OrderTotals   `json:"totals" bson:"totals"`
+	ShippingAddress  Address       `json:"shipping_address" bson:"shipping_address"`
+	BillingAddress   *Address      `json:"billing_address" bson:"billing_address,omitempty"`
+	Payment          Payment       `json:"payment" bson:"payment"`
+	TaxRule          *ProvinceTax  `json:"tax_rule,omitempty" bson:"tax_rule,omitempty"`           // rates applied to the order, kept when the rules change
+	ShippingRate     *ShippingRate `json:"shipping_rate,omitempty" bson:"shipping_rate,omitempty"` // rate quoted when the order was placed
+	Tracking         *Tracking     `json:"tracking,omitempty" bson:"tracking,omitempty"`
+	Refunds          []OrderRefund `json:"refunds,omitempty" bson:"refunds,omitempty"`
+	CouponCode       string        `json:"coupon_code,omitempty" bson:"coupon_code,omitempty"`
+	GiftCardCode     string        `json:"gift_card_code,omitempty" bson:"gift_card_code,omitempty"`
+	GiftCardRefunded bool          `json:"gift_card_refunded,omitempty" bson:"gift_card_refunded,omitempty"` // totals.gift_card went back to the card on cancellation or full refund
+	LoyaltyPoints    int           `json:"loyalty_points_redeemed,omitempty" bson:"loyalty_points_redeemed,omitempty"`
+	LoyaltyTier      string        `json:"loyalty_tier,omitempty" bson:"loyalty_tier,omitempty"` // customer's tier when ordering
+	Timeline         Timeline      `json:"timeline" bson:"timeline"`
+	Notes            string        `json:"notes" bson:"notes,omitempty"`
+	CreatedAt        time.Time     `json:"created_at" bson:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at" bson:"updated_at"`
 }
 
 // CalculateItemSubtotal calculates subtotal for a single order item
@@ -87,7 +198,10 @@ func (oi *OrderItem) CalculateItemSubtotal() {
 }
 
 // CalculateTotals calculates all order totals (subtotal, tax, shipping, grand total)
-// Tax rate is 13% (Ontario HST), shipping is flat $15
+// Tax uses the rule for the shipping province, recorded on the order the first time so
+// later recalculations keep the same rates. Shipping costs the rate quoted when the order
+// was placed unless the subtotal or the customer's loyalty tier qualifies for free
+// shipping; orders placed before shipping was quoted keep the old flat $15.
 func (o *Order) CalculateTotals() {
 	// Calculate subtotal from items
 	var subtotal float64
@@ -96,18 +210,33 @@ func (o *Order) CalculateTotals() {
 	}
 	o.Totals.Subtotal = subtotal
 
-	// Calculate tax (13% HST for Ontario)
-	o.Totals.Tax = subtotal * 0.13
+	// Apply the loyalty tier discount after any coupon
+	benefits := LoyaltyTierBenefitsFor(o.LoyaltyTier)
+	o.Totals.Tier = benefits.Discount(subtotal - o.Totals.Discount)
 
-	// Set shipping (flat rate or free over $100)
-	if subtotal >= 100 {
+	// Calculate tax on the discounted subtotal
+	if o.TaxRule == nil {
+		rule := TaxRuleFor(o.ShippingAddress.Province)
+		o.TaxRule = &rule
+	}
+	o.Totals.TaxRate = o.TaxRule.Rate()
+	o.Totals.Tax = (subtotal - o.Totals.Discount - o.Totals.Tier - o.Totals.Loyalty) * o.Totals.TaxRate
+
+	// Set shipping (the quoted rate, or for older orders the flat rate, free over the
+	// threshold or the tier's)
+	if o.ShippingRate != nil {
+		o.Totals.Shipping = ShippingCost(*o.ShippingRate, subtotal, benefits)
+	} else if subtotal >= 100 || benefits.FreeShipping(subtotal) {
 		o.Totals.Shipping = 0.00
 	} else {
 		o.Totals.Shipping = 15.00
 	}
 
 	// Calculate grand total
-	o.Totals.GrandTotal = o.Totals.Subtotal + o.Totals.Tax + o.Totals.Shipping - o.Totals.Discount
+	o.Totals.GrandTotal = o.Totals.Subtotal + o.Totals.Tax + o.Totals.Shipping - o.Totals.Discount - o.Totals.Tier - o.Totals.Loyalty
+
+	// Calculate what is still owed after any gift card redemption
+	o.Totals.AmountDue = o.Totals.GrandTotal - o.Totals.GiftCard
 }
 
 // CalculateAllTotals recalculates item subtotals and order totals
@@ -169,11 +298,46 @@ func (o *Order) GetItemCount() int {
 	return count
 }
 
+// IsAllocated checks if warehouse stock has already been allocated to the order items
+func (o *Order) IsAllocated() bool {
+	for _, item := range o.Items {
+		if len(item.Allocations) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // HasBeenPaid checks if payment has been completed
 func (o *Order) HasBeenPaid() bool {
 	return o.Payment.Status == "completed"
 }
 
+// RefundedQuantity returns how many units of an item earlier refunds have covered
+func (o *Order) RefundedQuantity(sku string) int {
+	var refunded int
+	for _, refund := range o.Refunds {
+		for _, item := range refund.Items {
+			if item.SKU == sku {
+				refunded += item.Quantity
+			}
+		}
+	}
+	return refunded
+}
+
+// ItemRefundAmount is what refunding quantity units of an item pays back through the
+// payment provider: the units' share of the grand total after discounts and tax, less the
+// part paid by gift card. Shipping is only returned by a full refund.
+func (o *Order) ItemRefundAmount(item *OrderItem, quantity int) float64 {
+	if o.Totals.Subtotal <= 0 || o.Totals.GrandTotal <= 0 {
+		return 0
+	}
+	share := item.UnitPrice * float64(quantity) / o.Totals.Subtotal * (o.Totals.GrandTotal - o.Totals.Shipping)
+	paid := share * o.Payment.Amount / o.Totals.GrandTotal
+	return math.Round(paid*100) / 100
+}
+
 // CanBeCancelled checks if the order can still be cancelled
 func (o *Order) CanBeCancelled() bool {
 	return o.Status == "pending" || o.Status == "processing"
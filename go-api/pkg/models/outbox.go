@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Outbox event statuses
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxFailed    = "failed"
+)
+
+// OutboxEvent is a domain event stored alongside the write that produced it, waiting to
+// be relayed. Payload is the encoded event envelope, published as-is.
+type OutboxEvent struct {
+	ID          bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	EventID     string        `json:"event_id" bson:"event_id"`
+	Type        string        `json:"type" bson:"type"`
+	Payload     string        `json:"payload" bson:"payload"`
+	Status      string        `json:"status" bson:"status" validate:"oneof=pending delivered failed"`
+	Attempts    int           `json:"attempts" bson:"attempts"`
+	LastError   string        `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	AvailableAt time.Time     `json:"available_at" bson:"available_at"` // not claimable before this time
+	CreatedAt   time.Time     `json:"created_at" bson:"created_at"`
+	DeliveredAt *time.Time    `json:"delivered_at,omitempty" bson:"delivered_at,omitempty"`
+}
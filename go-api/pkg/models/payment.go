@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// OrderPaymentIntent is what a client needs to complete an order's payment: a client
+// secret to confirm with Stripe.js, or a PayPal page for the customer to approve
+type OrderPaymentIntent struct {
+	OrderNumber  string  `json:"order_number"`
+	Provider     string  `json:"provider"`
+	IntentID     string  `json:"intent_id"`
+	ClientSecret string  `json:"client_secret,omitempty"`
+	ApprovalURL  string  `json:"approval_url,omitempty"`
+	Amount       float64 `json:"amount"`
+	Currency     string  `json:"currency"`
+	Status       string  `json:"status"`
+}
+
+// RefundOrderRequest refunds a paid order: everything not yet refunded when Items is
+// empty, otherwise just those line items
+type RefundOrderRequest struct {
+	Items  []RefundItemRequest `json:"items" validate:"omitempty,dive"`
+	Reason string              `json:"reason" validate:"required,max=500"`
+}
+
+// RefundItemRequest names a quantity of one order line to refund
+type RefundItemRequest struct {
+	SKU      string `json:"sku" validate:"required"`
+	Quantity int    `json:"quantity" validate:"required,gte=1"`
+}
+
+// OrderRefund records a refund issued through the order's payment provider
+type OrderRefund struct {
+	ID        string       `json:"id" bson:"id"` // the provider's refund ID
+	Amount    float64      `json:"amount" bson:"amount"`
+	Items     []RefundItem `json:"items,omitempty" bson:"items,omitempty"`
+	Reason    string       `json:"reason" bson:"reason"`
+	Actor     string       `json:"actor" bson:"actor"`   // who issued it, as in the audit log
+	Status    string       `json:"status" bson:"status"` // the provider's status, e.g. succeeded or pending
+	CreatedAt time.Time    `json:"created_at" bson:"created_at"`
+}
+
+// RefundItem is the part of a refund paid back for one order line
+type RefundItem struct {
+	SKU      string  `json:"sku" bson:"sku"`
+	Quantity int     `json:"quantity" bson:"quantity"`
+	Amount   float64 `json:"amount" bson:"amount"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// MaxPriceAlerts caps how many products one customer can watch
+const MaxPriceAlerts = 50
+
+// PriceAlert emails a customer whenever a product's price goes down, or only when it
+// goes down to TargetPrice or below if one is set
+type PriceAlert struct {
+	ID          bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	CustomerID  bson.ObjectID `json:"customer_id" bson:"customer_id"`
+	SKU         string        `json:"sku" bson:"sku"`
+	ProductName string        `json:"product_name" bson:"product_name"`
+	Price       float64       `json:"price" bson:"price"` // when the alert was set up
+	TargetPrice *float64      `json:"target_price,omitempty" bson:"target_price,omitempty"`
+	AlertCount  int           `json:"alert_count" bson:"alert_count"`
+	LastAlertAt *time.Time    `json:"last_alert_at,omitempty" bson:"last_alert_at,omitempty"`
+	CreatedAt   time.Time     `json:"created_at" bson:"created_at"`
+}
+
+// CreatePriceAlertRequest watches a product's price. Watching a product again replaces
+// its target price.
+type CreatePriceAlertRequest struct {
+	SKU         string   `json:"sku" binding:"required,min=3,max=50"`
+	TargetPrice *float64 `json:"target_price" binding:"omitempty,gt=0"`
+}
+
+// Matches reports whether a price change from previous to price should alert
+func (a *PriceAlert) Matches(previous, price float64) bool {
+	if price >= previous {
+		return false
+	}
+	return a.TargetPrice == nil || price <= *a.TargetPrice
+}
@@ -30,11 +30,13 @@ type Product struct {
 	SKU         string            `json:"sku" bson:"sku" validate:"required,min=3,max=50"`
 	Name        string            `json:"name" bson:"name" validate:"required,min=2,max=200"`
 	Description string            `json:"description" bson:"description" validate:"max=2000"`
+	SEOTitle    string            `json:"seo_title,omitempty" bson:"seo_title,omitempty" validate:"max=70"`
 	Category    string            `json:"category" bson:"category" validate:"required,min=2,max=100"`
 	Subcategory string            `json:"subcategory" bson:"subcategory" validate:"max=100"`
 	Brand       string            `json:"brand" bson:"brand" validate:"required,min=2,max=100"`
 	Price       float64           `json:"price" bson:"price" validate:"required,gt=0"`
-	Currency    string            `json:"currency" bson:"currency" validate:"required,len=3"` // CAD, USD, etc.
+	Currency    string            `json:"currency" bson:"currency" validate:"required,len=3"`              // CAD, USD, etc.
+	WeightKg    float64           `json:"weight_kg,omitempty" bson:"weight_kg,omitempty" validate:"gte=0"` // shipping weight; 0 uses SHIPPING_DEFAULT_WEIGHT_GRAMS
 	Stock       Stock             `json:"stock" bson:"stock"`
 	Attributes  map[string]string `json:"attributes" bson:"attributes"` // Flexible key-value pairs
 	Images      []string          `json:"images" bson:"images" validate:"dive,url"`
@@ -45,6 +47,36 @@ type Product struct {
 	UpdatedAt   time.Time         `json:"updated_at" bson:"updated_at"`
 }
 
+// ProductDescriptionDraft is an AI-generated copy suggestion awaiting admin approval
+type ProductDescriptionDraft struct {
+	SKU         string    `json:"sku"`
+	Description string    `json:"description"`
+	SEOTitle    string    `json:"seo_title"`
+	Tags        []string  `json:"tags"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// AcceptProductDescriptionRequest lets an admin edit the draft before applying it.
+// Omitted fields keep the drafted value.
+type AcceptProductDescriptionRequest struct {
+	Description *string  `json:"description" binding:"omitempty,max=2000"`
+	SEOTitle    *string  `json:"seo_title" binding:"omitempty,max=70"`
+	Tags        []string `json:"tags" binding:"omitempty,dive,min=2,max=50"`
+}
+
+// Apply merges the admin's edits into the draft
+func (req *AcceptProductDescriptionRequest) Apply(draft *ProductDescriptionDraft) {
+	if req.Description != nil {
+		draft.Description = *req.Description
+	}
+	if req.SEOTitle != nil {
+		draft.SEOTitle = *req.SEOTitle
+	}
+	if req.Tags != nil {
+		draft.Tags = req.Tags
+	}
+}
+
 func (p *Product) CalculateTotalStock() {
 	p.Stock.Total = p.Stock.WarehouseMain + p.Stock.WarehouseEast + p.Stock.WarehouseWest
 }
@@ -57,6 +89,7 @@ type CreateProductRequest struct {
 	Brand       string            `json:"brand" validate:"required,min=2,max=100"`
 	Price       float64           `json:"price" validate:"required,gt=0"`
 	Currency    string            `json:"currency" validate:"required,len=3"`
+	WeightKg    float64           `json:"weight_kg" validate:"gte=0"`
 	Images      []string          `json:"images" validate:"dive,url"`
 	Attributes  map[string]string `json:"attributes"`
 	Tags        []string          `json:"tags" validate:"dive,min=2,max=50"`
@@ -94,6 +127,7 @@ func (req *CreateProductRequest) ToProduct() *Product {
 		Brand:       req.Brand,
 		Price:       req.Price,
 		Currency:    req.Currency,
+		WeightKg:    req.WeightKg,
 		Stock:       Stock{WarehouseMain: 0, WarehouseEast: 0, WarehouseWest: 0, Total: 0},
 		Attributes:  req.Attributes,
 		Images:      req.Images,
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ProductEmbedding stores the vector for a product's name, description and tags
+type ProductEmbedding struct {
+	ID        bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	ProductID bson.ObjectID `json:"product_id" bson:"product_id"`
+	SKU       string        `json:"sku" bson:"sku"`
+	Vector    []float64     `json:"-" bson:"vector"`
+	TextHash  string        `json:"text_hash" bson:"text_hash"` // detects when the product text changes
+	Model     string        `json:"model" bson:"model"`
+	UpdatedAt time.Time     `json:"updated_at" bson:"updated_at"`
+}
+
+// SimilarProduct is a nearest-neighbour match with its cosine similarity
+type SimilarProduct struct {
+	Product *Product `json:"product"`
+	Score   float64  `json:"score"`
+}
+
+// EmbeddingText returns the text embedded for a product
+func (p *Product) EmbeddingText() string {
+	return strings.Join([]string{
+		p.Name,
+		p.Brand,
+		p.Category + " / " + p.Subcategory,
+		p.Description,
+		strings.Join(p.Tags, ", "),
+	}, "\n")
+}
+
+// EmbeddingTextHash fingerprints the embedding text so unchanged products can be skipped
+func (p *Product) EmbeddingTextHash() string {
+	hash := sha256.Sum256([]byte(p.EmbeddingText()))
+	return hex.EncodeToString(hash[:])
+}
+
+// CosineSimilarity compares two vectors, returning 0 when their lengths differ or either is empty
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
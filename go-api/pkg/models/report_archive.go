@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ReportArchiveRetention is how long a finished report archive is kept for download
+const ReportArchiveRetention = 7 * 24 * time.Hour
+
+// MaxReportArchiveDays caps the date range one archive covers
+const MaxReportArchiveDays = 366
+
+// ReportArchive is a queued or finished ZIP of the sales, top product and inventory
+// reports for a date range, each as CSV, with their AI summaries
+type ReportArchive struct {
+	ID          bson.ObjectID  `json:"id" bson:"_id,omitempty"`
+	StartDate   string         `json:"start_date" bson:"start_date"` // YYYY-MM-DD
+	EndDate     string         `json:"end_date" bson:"end_date"`     // YYYY-MM-DD, inclusive
+	Status      string         `json:"status" bson:"status" validate:"oneof=pending running ready failed"`
+	Attempts    int            `json:"attempts" bson:"attempts"`
+	Error       string         `json:"error,omitempty" bson:"error,omitempty"`
+	Size        int            `json:"size_bytes,omitempty" bson:"size_bytes,omitempty"`
+	Data        []byte         `json:"-" bson:"data,omitempty"`
+	DownloadURL string         `json:"download_url,omitempty" bson:"-"` // signed, set when ready
+	RequestedBy *bson.ObjectID `json:"requested_by,omitempty" bson:"requested_by,omitempty"`
+	AvailableAt time.Time      `json:"-" bson:"available_at"` // lease expiry while running
+	CreatedAt   time.Time      `json:"created_at" bson:"created_at"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	ExpiresAt   time.Time      `json:"expires_at" bson:"expires_at"`
+}
+
+// FileName is the name the archive downloads as
+func (a *ReportArchive) FileName() string {
+	return "reports-" + a.StartDate + "-to-" + a.EndDate + ".zip"
+}
+
+// CreateReportArchiveRequest represents the request payload for generating a report archive
+type CreateReportArchiveRequest struct {
+	StartDate string `json:"start_date" binding:"required"` // YYYY-MM-DD
+	EndDate   string `json:"end_date" binding:"required"`   // YYYY-MM-DD, inclusive
+}
@@ -8,17 +8,44 @@ import (
 
 // Review represents a customer review for a product
 type Review struct {
-	ID               bson.ObjectID `json:"id" bson:"_id,omitempty"`
-	ProductID        bson.ObjectID `json:"product_id" bson:"product_id" validate:"required"`
-	CustomerID       bson.ObjectID `json:"customer_id" bson:"customer_id" validate:"required"`
-	OrderID          bson.ObjectID `json:"order_id" bson:"order_id,omitempty"`
-	Rating           int           `json:"rating" bson:"rating" validate:"required,gte=1,lte=5"`
-	Title            string        `json:"title" bson:"title" validate:"required,min=2,max=200"`
-	Comment          string        `json:"comment" bson:"comment" validate:"max=2000"`
-	VerifiedPurchase bool          `json:"verified_purchase" bson:"verified_purchase"`
-	HelpfulCount     int           `json:"helpful_count" bson:"helpful_count" validate:"gte=0"`
-	CreatedAt        time.Time     `json:"created_at" bson:"created_at"`
-	UpdatedAt        time.Time     `json:"updated_at" bson:"updated_at"`
+	ID               bson.ObjectID     `json:"id" bson:"_id,omitempty"`
+	ProductID        bson.ObjectID     `json:"product_id" bson:"product_id" validate:"required"`
+	CustomerID       bson.ObjectID     `json:"customer_id" bson:"customer_id" validate:"required"`
+	OrderID          bson.ObjectID     `json:"order_id" bson:"order_id,omitempty"`
+	Rating           int               `json:"rating" bson:"rating" validate:"required,gte=1,lte=5"`
+	Title            string            `json:"title" bson:"title" validate:"required,min=2,max=200"`
+	Comment          string            `json:"comment" bson:"comment" validate:"max=2000"`
+	VerifiedPurchase bool              `json:"verified_purchase" bson:"verified_purchase"`
+	HelpfulCount     int               `json:"helpful_count" bson:"helpful_count" validate:"gte=0"`
+	Reply            *ReviewReply      `json:"reply,omitempty" bson:"reply,omitempty"`
+	ModerationStatus string            `json:"moderation_status" bson:"moderation_status,omitempty" validate:"omitempty,oneof=approved pending flagged"`
+	Moderation       *ReviewModeration `json:"moderation,omitempty" bson:"moderation,omitempty"`
+	Sentiment        *ReviewSentiment  `json:"sentiment,omitempty" bson:"sentiment,omitempty"`
+	CreatedAt        time.Time         `json:"created_at" bson:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at" bson:"updated_at"`
+}
+
+// ReviewModeration records the automated screening result for the moderator
+type ReviewModeration struct {
+	Verdict   string    `json:"verdict" bson:"verdict" validate:"oneof=approve review reject"`
+	Category  string    `json:"category" bson:"category"`
+	Rationale string    `json:"rationale" bson:"rationale"`
+	CheckedAt time.Time `json:"checked_at" bson:"checked_at"`
+}
+
+// ReviewSentiment is the AI-assigned sentiment of a review's text
+type ReviewSentiment struct {
+	Score      float64   `json:"score" bson:"score" validate:"gte=-1,lte=1"` // -1 (negative) to 1 (positive)
+	Label      string    `json:"label" bson:"label" validate:"oneof=positive neutral negative"`
+	AnalyzedAt time.Time `json:"analyzed_at" bson:"analyzed_at"`
+}
+
+// ReviewReply is the merchant's public response to a review
+type ReviewReply struct {
+	Message   string    `json:"message" bson:"message" validate:"required,max=2000"`
+	Author    string    `json:"author" bson:"author" validate:"max=100"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
 }
 
 // SetTimestamps sets created_at and updated_at timestamps
@@ -59,15 +86,43 @@ func (r *Review) IsVerified() bool {
 	return r.VerifiedPurchase
 }
 
+// ReviewVote records a customer's helpful vote so each customer can vote once per review
+type ReviewVote struct {
+	ID         bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	ReviewID   bson.ObjectID `json:"review_id" bson:"review_id"`
+	CustomerID bson.ObjectID `json:"customer_id" bson:"customer_id"`
+	CreatedAt  time.Time     `json:"created_at" bson:"created_at"`
+}
+
+// ReviewHelpfulVoteRequest represents the request payload for casting or undoing a helpful vote
+type ReviewHelpfulVoteRequest struct {
+	CustomerID string `json:"customer_id" binding:"required"`
+}
+
+// ReviewReplyRequest represents the request payload for a merchant reply to a review
+type ReviewReplyRequest struct {
+	Message string `json:"message" binding:"required,min=2,max=2000"`
+	Author  string `json:"author" binding:"max=100"`
+}
+
+// IsVisible checks if the review passed moderation. Reviews created before moderation have no status.
+func (r *Review) IsVisible() bool {
+	return r.ModerationStatus == "" || r.ModerationStatus == "approved"
+}
+
+// HasReply checks if the merchant has responded to this review
+func (r *Review) HasReply() bool {
+	return r.Reply != nil
+}
+
 // CreateReviewRequest represents the request payload for creating a new review
 type CreateReviewRequest struct {
-	ProductID        bson.ObjectID `json:"product_id" bson:"product_id" validate:"required"`
-	CustomerID       bson.ObjectID `json:"customer_id" bson:"customer_id" validate:"required"`
-	OrderID          bson.ObjectID `json:"order_id" bson:"order_id,omitempty"`
-	Rating           int           `json:"rating" bson:"rating" validate:"required,gte=1,lte=5"`
-	Title            string        `json:"title" bson:"title" validate:"required,min=2,max=200"`
-	Comment          string        `json:"comment" bson:"comment" validate:"max=2000"`
-	VerifiedPurchase bool          `json:"verified_purchase" bson:"verified_purchase"`
+	ProductID  bson.ObjectID `json:"product_id" bson:"product_id"` // taken from the URL
+	CustomerID bson.ObjectID `json:"customer_id" bson:"customer_id" validate:"required"`
+	OrderID    bson.ObjectID `json:"order_id" bson:"order_id,omitempty"`
+	Rating     int           `json:"rating" bson:"rating" validate:"required,gte=1,lte=5"`
+	Title      string        `json:"title" bson:"title" validate:"required,min=2,max=200"`
+	Comment    string        `json:"comment" bson:"comment" validate:"max=2000"`
 }
 
 // UpdateReviewRequest represents the request payload for updating an existing review
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ProductFilters narrows product search results. Zero values don't filter.
+type ProductFilters struct {
+	Categories []string `json:"category,omitempty" bson:"categories,omitempty"`
+	Brands     []string `json:"brand,omitempty" bson:"brands,omitempty"`
+	PriceMin   *float64 `json:"price_min,omitempty" bson:"price_min,omitempty" validate:"omitempty,gte=0"`
+	PriceMax   *float64 `json:"price_max,omitempty" bson:"price_max,omitempty" validate:"omitempty,gte=0"`
+	InStock    *bool    `json:"in_stock,omitempty" bson:"in_stock,omitempty"`
+	// Attributes maps an attribute name to the values it may have
+	Attributes map[string][]string `json:"attributes,omitempty" bson:"attributes,omitempty"`
+}
+
+// IsEmpty reports whether no filter is set
+func (f ProductFilters) IsEmpty() bool {
+	return len(f.Categories) == 0 && len(f.Brands) == 0 && f.PriceMin == nil && f.PriceMax == nil &&
+		f.InStock == nil && len(f.Attributes) == 0
+}
+
+// MaxSavedSearches caps how many searches one customer can save
+const MaxSavedSearches = 25
+
+// SavedSearch is a search a customer kept to run again. With Notify set, the customer
+// is told when a newly created product matches it.
+type SavedSearch struct {
+	ID            bson.ObjectID  `json:"id" bson:"_id,omitempty"`
+	CustomerID    bson.ObjectID  `json:"customer_id" bson:"customer_id"`
+	Name          string         `json:"name" bson:"name"`
+	Query         string         `json:"query" bson:"query"`
+	Filters       ProductFilters `json:"filters" bson:"filters"`
+	Notify        bool           `json:"notify" bson:"notify"`
+	MatchCount    int            `json:"match_count" bson:"match_count"` // new products matched since the search was saved
+	LastMatchedAt *time.Time     `json:"last_matched_at,omitempty" bson:"last_matched_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at" bson:"updated_at"`
+}
+
+// SavedSearchMatch records that a new product matched a saved search, so each product
+// is reported once per search however many instances handle its creation
+type SavedSearchMatch struct {
+	ID            bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	SavedSearchID bson.ObjectID `json:"saved_search_id" bson:"saved_search_id"`
+	CustomerID    bson.ObjectID `json:"customer_id" bson:"customer_id"`
+	ProductID     bson.ObjectID `json:"product_id" bson:"product_id"`
+	SKU           string        `json:"sku" bson:"sku"`
+	CreatedAt     time.Time     `json:"created_at" bson:"created_at"`
+}
+
+// CreateSavedSearchRequest represents the request payload for saving a search
+type CreateSavedSearchRequest struct {
+	Name    string         `json:"name" binding:"required,min=1,max=100"`
+	Query   string         `json:"query" binding:"required,min=1,max=200"`
+	Filters ProductFilters `json:"filters"`
+	Notify  bool           `json:"notify"`
+}
+
+// UpdateSavedSearchRequest represents the request payload for updating a saved search
+type UpdateSavedSearchRequest struct {
+	Name    *string         `json:"name,omitempty" binding:"omitempty,min=1,max=100"`
+	Query   *string         `json:"query,omitempty" binding:"omitempty,min=1,max=200"`
+	Filters *ProductFilters `json:"filters,omitempty"`
+	Notify  *bool           `json:"notify,omitempty"`
+}
+
+// ToSavedSearch converts the request into a SavedSearch owned by customerID
+func (req *CreateSavedSearchRequest) ToSavedSearch(customerID bson.ObjectID) *SavedSearch {
+	now := time.Now()
+	return &SavedSearch{
+		CustomerID: customerID,
+		Name:       req.Name,
+		Query:      req.Query,
+		Filters:    req.Filters,
+		Notify:     req.Notify,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
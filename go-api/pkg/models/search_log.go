@@ -0,0 +1,25 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// SearchLog records one search so merchandising can see what shoppers look for
+type SearchLog struct {
+	ID        bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	RequestID string        `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	Query     string        `json:"query" bson:"query"`
+	Term      string        `json:"term" bson:"term"` // normalized query that searches are grouped by
+	Types     []string      `json:"types" bson:"types"`
+	Filtered  bool          `json:"filtered" bson:"filtered"`
+	Results   int           `json:"results" bson:"results"` // matches across every searched type
+	CreatedAt time.Time     `json:"created_at" bson:"created_at"`
+}
+
+// SearchTerm normalizes a query so "Red  Shoes" and "red shoes" count as one term
+func SearchTerm(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
@@ -0,0 +1,42 @@
+package models
+
+// Records POST /api/admin/seed generates of each kind when the request leaves a count out
+const (
+	DefaultSeedProducts  = 50
+	DefaultSeedCustomers = 25
+	DefaultSeedOrders    = 100
+	DefaultSeedReviews   = 150
+)
+
+// SeedRequest sets how many records of each kind to generate. Orders and reviews are
+// placed by the generated customers on the generated products.
+type SeedRequest struct {
+	Products  *int `json:"products,omitempty" validate:"omitempty,gte=0,lte=5000"`
+	Customers *int `json:"customers,omitempty" validate:"omitempty,gte=0,lte=1000"`
+	Orders    *int `json:"orders,omitempty" validate:"omitempty,gte=0,lte=5000"`
+	Reviews   *int `json:"reviews,omitempty" validate:"omitempty,gte=0,lte=5000"`
+}
+
+// SeedCounts is how many records of each kind were, or will be, generated
+type SeedCounts struct {
+	Products  int `json:"products"`
+	Customers int `json:"customers"`
+	Orders    int `json:"orders"`
+	Reviews   int `json:"reviews"`
+}
+
+// Counts applies the defaults to the counts the request leaves out
+func (req *SeedRequest) Counts() SeedCounts {
+	count := func(value *int, fallback int) int {
+		if value == nil {
+			return fallback
+		}
+		return *value
+	}
+	return SeedCounts{
+		Products:  count(req.Products, DefaultSeedProducts),
+		Customers: count(req.Customers, DefaultSeedCustomers),
+		Orders:    count(req.Orders, DefaultSeedOrders),
+		Reviews:   count(req.Reviews, DefaultSeedReviews),
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+// Shipping methods a cart or order can ship by
+const (
+	ShippingStandard = "standard"
+	ShippingExpress  = "express"
+)
+
+// ShippingRate is the price of shipping a cart or order by one method
+type ShippingRate struct {
+	Method        string  `json:"method" bson:"method"`
+	Carrier       string  `json:"carrier" bson:"carrier"`                     // canada_post, or table for the built-in rates
+	Service       string  `json:"service,omitempty" bson:"service,omitempty"` // the carrier's name for the service
+	Amount        float64 `json:"amount" bson:"amount"`
+	EstimatedDays int     `json:"estimated_days,omitempty" bson:"estimated_days,omitempty"`
+}
+
+// Tracking identifies a shipped order's parcel with its carrier
+type Tracking struct {
+	Carrier string `json:"carrier" bson:"carrier" validate:"required,max=50"`
+	Number  string `json:"number" bson:"number" validate:"required,max=100"`
+	URL     string `json:"url,omitempty" bson:"url,omitempty" validate:"omitempty,url"` // the carrier's tracking page
+}
+
+// FindShippingRate returns the rate for method
+func FindShippingRate(rates []ShippingRate, method string) (ShippingRate, bool) {
+	for _, rate := range rates {
+		if rate.Method == method {
+			return rate, true
+		}
+	}
+	return ShippingRate{}, false
+}
+
+// ShippingCost is what shipping at rate costs the customer. Standard shipping is free
+// once the subtotal reaches FREE_SHIPPING_THRESHOLD or the loyalty tier's threshold.
+func ShippingCost(rate ShippingRate, subtotal float64, benefits LoyaltyTierBenefits) float64 {
+	if rate.Method == ShippingStandard && (subtotal >= pricing.FreeShippingThreshold || benefits.FreeShipping(subtotal)) {
+		return 0
+	}
+	return rate.Amount
+}
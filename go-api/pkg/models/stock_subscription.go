@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// StockSubscription asks for one email when an out-of-stock product is back in stock.
+// It is removed once that email is queued.
+type StockSubscription struct {
+	ID         bson.ObjectID  `json:"id" bson:"_id,omitempty"`
+	SKU        string         `json:"sku" bson:"sku"`
+	Email      string         `json:"email" bson:"email"`
+	CustomerID *bson.ObjectID `json:"customer_id,omitempty" bson:"customer_id,omitempty"`
+	CreatedAt  time.Time      `json:"created_at" bson:"created_at"`
+}
+
+// NotifyMeRequest subscribes a customer, or an email address without an account, to a
+// product's restock. A customer is emailed at their account address.
+type NotifyMeRequest struct {
+	CustomerID string `json:"customer_id" validate:"required_without=Email,omitempty,len=24,hexadecimal"`
+	Email      string `json:"email" validate:"required_without=CustomerID,excluded_with=CustomerID,omitempty,email"`
+}
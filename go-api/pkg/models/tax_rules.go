@@ -0,0 +1,94 @@
+package models
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// TaxRulesID is the _id of the single tax rules document
+const TaxRulesID = "tax_rules"
+
+// ProvinceTax is the sales tax charged on orders shipped to a province, as fractions
+// (0.05 for 5%). Provinces charge either HST or GST plus a provincial tax.
+type ProvinceTax struct {
+	Province string  `json:"province" bson:"province" validate:"required,len=2,uppercase"`
+	GST      float64 `json:"gst" bson:"gst" validate:"gte=0,lte=1"`
+	PST      float64 `json:"pst" bson:"pst" validate:"gte=0,lte=1"` // PST, RST or QST
+	HST      float64 `json:"hst" bson:"hst" validate:"gte=0,lte=1"`
+}
+
+// Rate is the combined tax rate
+func (t ProvinceTax) Rate() float64 {
+	return t.GST + t.PST + t.HST
+}
+
+// TaxRules is the admin-managed document holding every province's tax rates
+type TaxRules struct {
+	ID        string        `json:"-" bson:"_id"`
+	Provinces []ProvinceTax `json:"provinces" bson:"provinces"`
+	UpdatedAt time.Time     `json:"updated_at" bson:"updated_at"`
+}
+
+// UpdateTaxRulesRequest replaces the tax rates of every province. Provinces left out
+// are taxed like TAX_DEFAULT_PROVINCE.
+type UpdateTaxRulesRequest struct {
+	Provinces []ProvinceTax `json:"provinces" validate:"required,min=1,dive"`
+}
+
+// DefaultTaxRules are the Canadian rates, used until an admin saves rules of their own
+func DefaultTaxRules() *TaxRules {
+	return &TaxRules{
+		ID: TaxRulesID,
+		Provinces: []ProvinceTax{
+			{Province: "AB", GST: 0.05},
+			{Province: "BC", GST: 0.05, PST: 0.07},
+			{Province: "MB", GST: 0.05, PST: 0.07},
+			{Province: "NB", HST: 0.15},
+			{Province: "NL", HST: 0.15},
+			{Province: "NS", HST: 0.14},
+			{Province: "NT", GST: 0.05},
+			{Province: "NU", GST: 0.05},
+			{Province: "ON", HST: 0.13},
+			{Province: "PE", HST: 0.15},
+			{Province: "QC", GST: 0.05, PST: 0.09975},
+			{Province: "SK", GST: 0.05, PST: 0.06},
+			{Province: "YT", GST: 0.05},
+		},
+	}
+}
+
+// taxRules holds the rules applied to totals, or nil for the defaults. The domain event
+// worker swaps them when an admin saves new rules, so they are read and replaced
+// atomically.
+var taxRules atomic.Pointer[TaxRules]
+
+// SetTaxRules replaces the rules applied to cart and order totals
+func SetTaxRules(rules *TaxRules) {
+	taxRules.Store(rules)
+}
+
+// TaxRuleFor returns the current tax rule for a province. A province without a rule,
+// or an empty one, is taxed like the configured default province.
+func TaxRuleFor(province string) ProvinceTax {
+	rules := taxRules.Load()
+	if rules == nil {
+		rules = DefaultTaxRules()
+	}
+
+	find := func(province string) (ProvinceTax, bool) {
+		for _, rule := range rules.Provinces {
+			if rule.Province == province {
+				return rule, true
+			}
+		}
+		return ProvinceTax{}, false
+	}
+
+	if rule, ok := find(strings.ToUpper(province)); ok {
+		return rule
+	}
+	rule, _ := find(pricing.DefaultTaxProvince)
+	rule.Province = pricing.DefaultTaxProvince
+	return rule
+}
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Channels a notification template is sent through
+const (
+	TemplateChannelEmail = "email"
+	TemplateChannelSMS   = "sms"
+)
+
+// templatePlaceholder matches {{name}} placeholders, spaces inside the braces allowed
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.]+)\s*\}\}`)
+
+// NotificationTemplate is one version of an email or SMS template. Every save stores a
+// new version under the same key, and the highest version is the one sent.
+type NotificationTemplate struct {
+	ID          bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	Key         string        `json:"key" bson:"key"`
+	Version     int           `json:"version" bson:"version"`
+	Channel     string        `json:"channel" bson:"channel"`
+	Description string        `json:"description,omitempty" bson:"description,omitempty"`
+	Subject     string        `json:"subject,omitempty" bson:"subject,omitempty"` // email only
+	Body        string        `json:"body" bson:"body"`
+	Variables   []string      `json:"variables" bson:"variables"` // placeholders used in the subject and body
+	CreatedAt   time.Time     `json:"created_at" bson:"created_at"`
+}
+
+// CreateTemplateRequest creates version 1 of a template
+type CreateTemplateRequest struct {
+	Key         string `json:"key" validate:"required,min=2,max=100"`
+	Channel     string `json:"channel" validate:"required,oneof=email sms"`
+	Description string `json:"description" validate:"max=500"`
+	Subject     string `json:"subject" validate:"required_if=Channel email,max=200"`
+	Body        string `json:"body" validate:"required,max=20000"`
+}
+
+// ToTemplate converts the request into the template's first version
+func (req *CreateTemplateRequest) ToTemplate() *NotificationTemplate {
+	template := &NotificationTemplate{
+		Key:         req.Key,
+		Version:     1,
+		Channel:     req.Channel,
+		Description: req.Description,
+		Subject:     req.Subject,
+		Body:        req.Body,
+		CreatedAt:   time.Now(),
+	}
+	if template.Channel == TemplateChannelSMS {
+		template.Subject = ""
+	}
+	template.Variables = template.findVariables()
+	return template
+}
+
+// UpdateTemplateRequest saves a new version of a template. Omitted fields keep the
+// current version's values.
+type UpdateTemplateRequest struct {
+	Description *string `json:"description" validate:"omitempty,max=500"`
+	Subject     *string `json:"subject" validate:"omitempty,min=1,max=200"`
+	Body        *string `json:"body" validate:"omitempty,min=1,max=20000"`
+}
+
+// NextVersion returns the version that follows current with the request's changes
+func (req *UpdateTemplateRequest) NextVersion(current *NotificationTemplate) *NotificationTemplate {
+	next := *current
+	next.ID = bson.ObjectID{}
+	next.Version = current.Version + 1
+	next.CreatedAt = time.Now()
+	if req.Description != nil {
+		next.Description = *req.Description
+	}
+	if req.Subject != nil && next.Channel == TemplateChannelEmail {
+		next.Subject = *req.Subject
+	}
+	if req.Body != nil {
+		next.Body = *req.Body
+	}
+	next.Variables = next.findVariables()
+	return &next
+}
+
+// PreviewTemplateRequest renders a template with sample values. Version 0 renders the
+// current version.
+type PreviewTemplateRequest struct {
+	Version   int               `json:"version" validate:"gte=0"`
+	Variables map[string]string `json:"variables"`
+}
+
+// RenderedTemplate is a template with its placeholders filled in
+type RenderedTemplate struct {
+	Key     string `json:"key"`
+	Version int    `json:"version"`
+	Channel string `json:"channel"`
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body"`
+}
+
+// MissingVariablesError is returned when rendering a template without a value for each
+// of its placeholders
+type MissingVariablesError struct {
+	Names []string
+}
+
+func (e *MissingVariablesError) Error() string {
+	return "missing template variables: " + strings.Join(e.Names, ", ")
+}
+
+// findVariables lists the distinct placeholders in the subject and body, sorted
+func (t *NotificationTemplate) findVariables() []string {
+	seen := map[string]bool{}
+	variables := []string{}
+	for _, text := range []string{t.Subject, t.Body} {
+		for _, match := range templatePlaceholder.FindAllStringSubmatch(text, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				variables = append(variables, match[1])
+			}
+		}
+	}
+	sort.Strings(variables)
+	return variables
+}
+
+// Render fills in every placeholder from vars. A placeholder without a value is an
+// error, so a message is never sent with {{name}} left in it.
+func (t *NotificationTemplate) Render(vars map[string]string) (*RenderedTemplate, error) {
+	var missing []string
+	for _, name := range t.findVariables() {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &MissingVariablesError{Names: missing}
+	}
+
+	fill := func(text string) string {
+		return templatePlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+			return vars[templatePlaceholder.FindStringSubmatch(placeholder)[1]]
+		})
+	}
+	return &RenderedTemplate{
+		Key:     t.Key,
+		Version: t.Version,
+		Channel: t.Channel,
+		Subject: fill(t.Subject),
+		Body:    fill(t.Body),
+	}, nil
+}
+
+// Keys of the templates the API sends itself
+const (
+	TemplateSavedSearchMatched = "saved_search.matched"
+	TemplateAdminDigest        = "admin.digest"
+	TemplateBackInStock        = "product.back_in_stock"
+	TemplatePriceDrop          = "product.price_drop"
+	TemplateOrderConfirmed     = "order.confirmed"
+	TemplateOrderShipped       = "order.shipped"
+	TemplateOrderDelivered     = "order.delivered"
+	TemplateOrderCancelled     = "order.cancelled"
+	TemplateAdminInvitation    = "admin.invitation"
+)
+
+// DefaultTemplates are sent for keys that have no template saved yet. They count as
+// version 0, and saving a template with the same key replaces them.
+var DefaultTemplates = map[string]NotificationTemplate{
+	TemplateSavedSearchMatched: {
+		Key:         TemplateSavedSearchMatched,
+		Channel:     TemplateChannelEmail,
+		Description: "A new product matches a saved search with notifications on",
+		Subject:     `New match for "{{search_name}}"`,
+		Body:        "Hi {{first_name}},\n\n{{product_name}} ({{product_price}}) matches your saved search \"{{search_name}}\".\n\nYou are receiving this because notifications are on for this search. To stop all notification emails, visit {{unsubscribe_url}}",
+	},
+	TemplateBackInStock: {
+		Key:         TemplateBackInStock,
+		Channel:     TemplateChannelEmail,
+		Description: "A product someone asked about with notify-me is back in stock",
+		Subject:     "{{product_name}} is back in stock",
+		Body:        "Good news: {{product_name}} is back in stock at {{product_price}}.\n\nYou asked us to tell you when it was available again. This is the only email you will get about it.",
+	},
+	TemplatePriceDrop: {
+		Key:         TemplatePriceDrop,
+		Channel:     TemplateChannelEmail,
+		Description: "A product with a customer's price alert went down in price",
+		Subject:     "Price drop: {{product_name}} is now {{product_price}}",
+		Body:        "Hi {{first_name}},\n\n{{product_name}} went down from {{previous_price}} to {{product_price}}.\n\nYou are receiving this because you set a price alert on it. Remove the alert to stop these emails, or visit {{unsubscribe_url}} to stop all notification emails.",
+	},
+	TemplateOrderConfirmed: {
+		Key:         TemplateOrderConfirmed,
+		Channel:     TemplateChannelEmail,
+		Description: "An order was paid and moved to processing",
+		Subject:     "Order {{order_number}} is confirmed",
+		Body:        "Hi {{first_name}},\n\nThanks for your order. We have your payment of {{order_total}} for order {{order_number}} and are getting it ready to ship.\n\nTo stop notification emails, visit {{unsubscribe_url}}",
+	},
+	TemplateOrderShipped: {
+		Key:         TemplateOrderShipped,
+		Channel:     TemplateChannelEmail,
+		Description: "An order shipped, with its tracking number when one was set",
+		Subject:     "Order {{order_number}} has shipped",
+		Body:        "Hi {{first_name}},\n\nOrder {{order_number}} is on its way and should arrive by {{estimated_delivery}}.\n\n{{tracking}}\n\nTo stop notification emails, visit {{unsubscribe_url}}",
+	},
+	TemplateOrderDelivered: {
+		Key:         TemplateOrderDelivered,
+		Channel:     TemplateChannelEmail,
+		Description: "An order was delivered",
+		Subject:     "Order {{order_number}} was delivered",
+		Body:        "Hi {{first_name}},\n\nOrder {{order_number}} was delivered. We hope you enjoy it.\n\nTo stop notification emails, visit {{unsubscribe_url}}",
+	},
+	TemplateOrderCancelled: {
+		Key:         TemplateOrderCancelled,
+		Channel:     TemplateChannelEmail,
+		Description: "An order was cancelled",
+		Subject:     "Order {{order_number}} was cancelled",
+		Body:        "Hi {{first_name}},\n\nOrder {{order_number}} ({{order_total}}) was cancelled. Any payment you made will be refunded to the original payment method.\n\nTo stop notification emails, visit {{unsubscribe_url}}",
+	},
+	TemplateAdminInvitation: {
+		Key:         TemplateAdminInvitation,
+		Channel:     TemplateChannelEmail,
+		Description: "Someone was invited to become an admin",
+		Subject:     "You're invited to be an admin",
+		Body:        "You have been invited to be an admin with the roles: {{roles}}.\n\nAccept the invitation before {{expires_at}} at {{invite_url}}\n\nIf you weren't expecting this, ignore this email.",
+	},
+	TemplateAdminDigest: {
+		Key:         TemplateAdminDigest,
+		Channel:     TemplateChannelEmail,
+		Description: "The weekly digest sent to ADMIN_DIGEST_EMAILS",
+		Subject:     "Weekly digest: {{week_start}} to {{week_end}}",
+		Body:        "Sales\n{{sales}}\n\nTop products\n{{top_products}}\n\nLow stock\n{{low_stock}}\n\nSummary\n{{ai_summary}}",
+	},
+}
+
+// DefaultTemplate returns a copy of the built-in template for key
+func DefaultTemplate(key string) (*NotificationTemplate, bool) {
+	template, ok := DefaultTemplates[key]
+	if !ok {
+		return nil, false
+	}
+	template.Variables = template.findVariables()
+	return &template, true
+}
@@ -0,0 +1,88 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// AbandonedCartsResult is a page of abandoned cart snapshots
+type AbandonedCartsResult struct {
+	Carts      []models.AbandonedCart `json:"carts"`
+	Pagination global.PaginationInfo  `json:"pagination"`
+}
+
+// SaveAbandonedCart stores an abandoned cart snapshot
+func SaveAbandonedCart(ctx context.Context, snapshot *models.AbandonedCart) (*models.AbandonedCart, error) {
+	collection := GetCollection("abandoned_carts")
+
+	result, err := collection.InsertOne(ctx, snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.ID = result.InsertedID.(bson.ObjectID)
+
+	return snapshot, nil
+}
+
+// GetAbandonedCarts returns abandoned cart snapshots, most recent first
+func GetAbandonedCarts(ctx context.Context, page int, limit int) (*AbandonedCartsResult, error) {
+	collection := GetCollection("abandoned_carts")
+
+	filter := bson.D{}
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := (page - 1) * limit
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "detected_at", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	carts := []models.AbandonedCart{}
+	if err := cursor.All(ctx, &carts); err != nil {
+		return nil, err
+	}
+
+	return &AbandonedCartsResult{
+		Carts:      carts,
+		Pagination: global.NewPagination(page, limit, int(totalCount)),
+	}, nil
+}
+
+// MarkAbandonedCartsRecovered flags the abandoned carts detected for email before
+// orderedAt, once that customer has gone on to place an order. It returns how many carts
+// were marked; marking them again is a no-op.
+func MarkAbandonedCartsRecovered(ctx context.Context, email string, orderedAt time.Time) (int64, error) {
+	if email == "" {
+		return 0, nil
+	}
+
+	result, err := GetCollection("abandoned_carts").UpdateMany(ctx,
+		bson.D{
+			{Key: "customer_email", Value: email},
+			{Key: "recovered", Value: false},
+			{Key: "detected_at", Value: bson.D{{Key: "$lte", Value: orderedAt}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "recovered", Value: true}}}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
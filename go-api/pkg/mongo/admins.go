@@ -0,0 +1,191 @@
+package mongo
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// InviteAdmin records an invitation and queues its email with the accept link in the same
+// transaction. Inviting an invited or deactivated admin again replaces their roles and
+// token, so only the newest link works; it returns false for them. Active admins can't
+// be invited.
+func InviteAdmin(ctx context.Context, invite *models.Admin, inviteURL string) (*models.Admin, bool, error) {
+	collection := GetCollection("admins")
+
+	email := strings.ToLower(invite.Email)
+	// MongoDB keeps milliseconds, so a new admin's created_at reads back equal to now
+	now := time.Now().Truncate(time.Millisecond)
+	set := bson.D{
+		{Key: "roles", Value: invite.Roles},
+		{Key: "status", Value: models.AdminInvited},
+		{Key: "invite_token_hash", Value: invite.InviteTokenHash},
+		{Key: "invite_expires_at", Value: invite.InviteExpiresAt},
+		{Key: "updated_at", Value: now},
+	}
+	if invite.Name != "" {
+		set = append(set, bson.E{Key: "name", Value: invite.Name})
+	}
+	if invite.InvitedBy != nil {
+		set = append(set, bson.E{Key: "invited_by", Value: invite.InvitedBy})
+	}
+	update := bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$unset", Value: bson.D{{Key: "key_hash", Value: ""}, {Key: "deactivated_at", Value: ""}}},
+		{Key: "$setOnInsert", Value: bson.D{{Key: "email", Value: email}, {Key: "created_at", Value: now}}},
+	}
+	// An active admin's email doesn't match, so the upsert inserts and trips the unique
+	// email index
+	filter := bson.D{{Key: "email", Value: email}, {Key: "status", Value: bson.D{{Key: "$ne", Value: models.AdminActive}}}}
+	findOptions := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var admin models.Admin
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		if err := collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&admin); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return duplicate("admin")
+			}
+			return err
+		}
+
+		_, err := QueueTemplatedNotification(ctx, models.TemplateAdminInvitation, email, models.TemplateAdminInvitation, models.TemplateAdminInvitation+":"+invite.InviteTokenHash, map[string]string{
+			"invite_url": inviteURL,
+			"roles":      strings.Join(invite.Roles, ", "),
+			"expires_at": invite.InviteExpiresAt.Format("January 2, 2006 15:04 MST"),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &admin, admin.CreatedAt.Equal(now), nil
+}
+
+// AcceptAdminInvitation activates the admin invited with the token whose hash is given,
+// storing the hash of their new key. An unknown, used or expired token is "invitation
+// not found".
+func AcceptAdminInvitation(ctx context.Context, tokenHash, name, keyHash string) (*models.Admin, error) {
+	collection := GetCollection("admins")
+
+	now := time.Now()
+	filter := bson.D{
+		{Key: "invite_token_hash", Value: tokenHash},
+		{Key: "status", Value: models.AdminInvited},
+		{Key: "invite_expires_at", Value: bson.D{{Key: "$gt", Value: now}}},
+	}
+	set := bson.D{
+		{Key: "status", Value: models.AdminActive},
+		{Key: "key_hash", Value: keyHash},
+		{Key: "accepted_at", Value: now},
+		{Key: "updated_at", Value: now},
+	}
+	if name != "" {
+		set = append(set, bson.E{Key: "name", Value: name})
+	}
+	update := bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$unset", Value: bson.D{{Key: "invite_token_hash", Value: ""}, {Key: "invite_expires_at", Value: ""}}},
+	}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var admin models.Admin
+	err := collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&admin)
+	if err != nil {
+		return nil, notFoundError(err, "invitation")
+	}
+	return &admin, nil
+}
+
+// GetAdmins lists admins, newest first, optionally only those with a status
+func GetAdmins(ctx context.Context, status string, page, limit int) ([]models.Admin, int64, error) {
+	collection := GetCollection("admins")
+
+	filter := bson.D{}
+	if status != "" {
+		filter = append(filter, bson.E{Key: "status", Value: status})
+	}
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	admins := []models.Admin{}
+	if err := cursor.All(ctx, &admins); err != nil {
+		return nil, 0, err
+	}
+	return admins, total, nil
+}
+
+// UpdateAdminRoles replaces an admin's roles
+func UpdateAdminRoles(ctx context.Context, id bson.ObjectID, roles []string) (*models.Admin, error) {
+	return updateAdmin(ctx, id, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "roles", Value: roles},
+		{Key: "updated_at", Value: time.Now()},
+	}}})
+}
+
+// DeactivateAdmin turns off an admin's key and any pending invitation. Deactivating an
+// admin again changes nothing.
+func DeactivateAdmin(ctx context.Context, id bson.ObjectID) (*models.Admin, error) {
+	now := time.Now()
+	return updateAdmin(ctx, id, bson.A{
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: models.AdminDeactivated},
+			{Key: "deactivated_at", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$deactivated_at", now}}}},
+			{Key: "updated_at", Value: now},
+		}}},
+		bson.D{{Key: "$unset", Value: bson.A{"key_hash", "invite_token_hash", "invite_expires_at"}}},
+	})
+}
+
+// updateAdmin applies update to an admin and returns the result
+func updateAdmin(ctx context.Context, id bson.ObjectID, update interface{}) (*models.Admin, error) {
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var admin models.Admin
+	err := GetCollection("admins").FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: id}}, update, findOptions).Decode(&admin)
+	if err != nil {
+		return nil, notFoundError(err, "admin")
+	}
+	return &admin, nil
+}
+
+// AuthenticateAdmin returns the active admin with a personal key and stamps their last
+// login, at most once a minute so busy admins don't write on every request
+func AuthenticateAdmin(ctx context.Context, key string) (*models.Admin, error) {
+	now := time.Now()
+	filter := bson.D{{Key: "key_hash", Value: models.HashAdminSecret(key)}, {Key: "status", Value: models.AdminActive}}
+	update := bson.A{
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "last_login_at", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$lt", Value: bson.A{"$last_login_at", now.Add(-time.Minute)}}},
+				now,
+				"$last_login_at",
+			}}}},
+		}}},
+	}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var admin models.Admin
+	err := GetCollection("admins").FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&admin)
+	if err != nil {
+		return nil, notFoundError(err, "admin")
+	}
+	return &admin, nil
+}
@@ -0,0 +1,215 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// StoredReportsResult is a page of stored AI reports
+type StoredReportsResult struct {
+	Reports    []models.StoredReport `json:"reports"`
+	Pagination global.PaginationInfo `json:"pagination"`
+}
+
+// StoredReportFilter narrows the stored report listing. Empty fields are ignored.
+type StoredReportFilter struct {
+	ReportType string
+	ScheduleID *bson.ObjectID
+	StartDate  *time.Time
+	EndDate    *time.Time // exclusive
+}
+
+// reportsCollection decodes raw report data into maps so it serializes back to the same JSON
+func reportsCollection() *mongo.Collection {
+	return GetDatabase().Collection("reports", options.Collection().SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true}))
+}
+
+// CreateReportSchedule stores a new AI report schedule
+func CreateReportSchedule(ctx context.Context, schedule *models.ReportSchedule) (*models.ReportSchedule, error) {
+	collection := GetCollection("report_schedules")
+
+	result, err := collection.InsertOne(ctx, schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.ID = result.InsertedID.(bson.ObjectID)
+
+	return schedule, nil
+}
+
+// GetReportSchedules retrieves every AI report schedule, soonest run first
+func GetReportSchedules(ctx context.Context) ([]models.ReportSchedule, error) {
+	collection := GetCollection("report_schedules")
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "next_run_at", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.D{}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	schedules := []models.ReportSchedule{}
+	if err := cursor.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+// GetDueReportSchedules retrieves enabled schedules whose next run is at or before now
+func GetDueReportSchedules(ctx context.Context, now time.Time) ([]models.ReportSchedule, error) {
+	collection := GetCollection("report_schedules")
+
+	filter := bson.D{
+		{Key: "enabled", Value: true},
+		{Key: "next_run_at", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "next_run_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	schedules := []models.ReportSchedule{}
+	if err := cursor.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+// ClaimReportScheduleRun moves a due schedule to its next run time. It returns false when
+// another worker already claimed this run, so each run is generated once.
+func ClaimReportScheduleRun(ctx context.Context, schedule *models.ReportSchedule, ranAt time.Time) (bool, error) {
+	collection := GetCollection("report_schedules")
+
+	filter := bson.D{
+		{Key: "_id", Value: schedule.ID},
+		{Key: "next_run_at", Value: schedule.NextRunAt},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "next_run_at", Value: schedule.NextRunAfter(ranAt)},
+		{Key: "last_run_at", Value: ranAt},
+		{Key: "updated_at", Value: ranAt},
+	}}}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// SetReportScheduleError records the outcome of the latest run; an empty message clears it
+func SetReportScheduleError(ctx context.Context, id bson.ObjectID, message string) error {
+	collection := GetCollection("report_schedules")
+
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "last_error", Value: message}}}}
+	if message == "" {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "last_error", Value: ""}}}}
+	}
+
+	_, err := collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update)
+	return err
+}
+
+// DeleteReportSchedule removes a schedule; reports it already generated are kept
+func DeleteReportSchedule(ctx context.Context, id bson.ObjectID) error {
+	collection := GetCollection("report_schedules")
+
+	result, err := collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return notFound("report schedule")
+	}
+
+	return nil
+}
+
+// SaveStoredReport inserts a generated AI report into the reports collection
+func SaveStoredReport(ctx context.Context, report *models.StoredReport) (*models.StoredReport, error) {
+	collection := reportsCollection()
+
+	result, err := collection.InsertOne(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+
+	report.ID = result.InsertedID.(bson.ObjectID)
+
+	return report, nil
+}
+
+// GetStoredReports returns stored AI reports matching filter, most recent first
+func GetStoredReports(ctx context.Context, filter StoredReportFilter, page int, limit int) (*StoredReportsResult, error) {
+	collection := reportsCollection()
+
+	query := bson.D{}
+	if filter.ReportType != "" {
+		query = append(query, bson.E{Key: "report_type", Value: filter.ReportType})
+	}
+	if filter.ScheduleID != nil {
+		query = append(query, bson.E{Key: "schedule_id", Value: *filter.ScheduleID})
+	}
+	dateFilter := bson.D{}
+	if filter.StartDate != nil {
+		dateFilter = append(dateFilter, bson.E{Key: "$gte", Value: *filter.StartDate})
+	}
+	if filter.EndDate != nil {
+		dateFilter = append(dateFilter, bson.E{Key: "$lt", Value: *filter.EndDate})
+	}
+	if len(dateFilter) > 0 {
+		query = append(query, bson.E{Key: "generated_at", Value: dateFilter})
+	}
+
+	totalCount, err := collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := (page - 1) * limit
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "generated_at", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	reports := []models.StoredReport{}
+	if err := cursor.All(ctx, &reports); err != nil {
+		return nil, err
+	}
+
+	return &StoredReportsResult{
+		Reports:    reports,
+		Pagination: global.NewPagination(page, limit, int(totalCount)),
+	}, nil
+}
+
+// GetStoredReportByID retrieves a single stored AI report
+func GetStoredReportByID(ctx context.Context, id bson.ObjectID) (*models.StoredReport, error) {
+	collection := reportsCollection()
+
+	var report models.StoredReport
+	err := collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&report)
+	if err != nil {
+		return nil, notFoundError(err, "report")
+	}
+
+	return &report, nil
+}
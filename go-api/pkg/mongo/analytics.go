@@ -2,10 +2,10 @@ package mongo
 
 import (
 	"context"
+	"math"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
-	"julianmorley.ca/con-plar/prog2270/pkg/global"
 )
 
 // SalesData represents daily sales summary
@@ -19,9 +19,9 @@ type SalesData struct {
 
 type CustomerSegment struct {
 	Segment             string  `json:"segment" bson:"_id"`
+	CustomerCount       int     `json:"customer_count" bson:"count"`
 	MinSpent            float64 `json:"min_spent" bson:"min_spent"`
 	MaxSpent            float64 `json:"max_spent" bson:"max_spent"`
-	CustomerCount       int     `json:"customer_count" bson:"count"`
 	AvgOrders           float64 `json:"avg_orders" bson:"avg_orders"`
 	TotalSpent          float64 `json:"total_spent" bson:"total_spent"`
 	AvgSpentPerCustomer float64 `json:"avg_spent_per_customer" bson:"avg_spent_per_customer"`
@@ -33,7 +33,10 @@ type CustomerSegmentsResult struct {
 }
 
 func GetCustomerSpendingSegments(ctx context.Context) (*CustomerSegmentsResult, error) {
-	collection := GetCollection("customers")
+	ctx, cancel := Analytics.Context(ctx)
+	defer cancel()
+
+	collection := Analytics.Collection("customers")
 
 	pipeline := bson.A{
 		bson.D{
@@ -142,38 +145,117 @@ type InventoryStatus struct {
 	LastUpdated  time.Time `json:"last_updated" bson:"last_updated"`
 }
 
-// GetTopProductsByRevenue returns top N products by revenue or quantity
-func GetTopProductsByRevenue(limit int, sortBy string, startDate, endDate string) ([]TopProduct, error) {
-	ctx, cancel := global.GetDefaultTimer()
+// ProductSentiment represents aggregate review sentiment for a product
+type ProductSentiment struct {
+	ProductID     string  `json:"product_id" bson:"_id"`
+	ProductName   string  `json:"product_name" bson:"product_name"`
+	SKU           string  `json:"sku" bson:"sku"`
+	AvgScore      float64 `json:"avg_score" bson:"avg_score"`
+	AvgRating     float64 `json:"avg_rating" bson:"avg_rating"`
+	ReviewCount   int     `json:"review_count" bson:"review_count"`
+	PositiveCount int     `json:"positive_count" bson:"positive_count"`
+	NeutralCount  int     `json:"neutral_count" bson:"neutral_count"`
+	NegativeCount int     `json:"negative_count" bson:"negative_count"`
+}
+
+// GetProductSentiment aggregates analyzed review sentiment per product, most negative first.
+// A zero productID returns every product with analyzed reviews.
+func GetProductSentiment(ctx context.Context, productID bson.ObjectID) ([]ProductSentiment, error) {
+	ctx, cancel := Analytics.Context(ctx)
 	defer cancel()
 
-	collection := GetCollection("orders")
+	collection := Analytics.Collection("reviews")
 
-	// Build match stage for completed orders
-	matchStage := bson.M{
-		"status": bson.M{"$in": []string{"shipped", "delivered", "completed"}},
+	matchStage := bson.M{"sentiment": bson.M{"$exists": true}}
+	if !productID.IsZero() {
+		matchStage["product_id"] = productID
 	}
 
-	// Add date range filtering if provided
-	if startDate != "" || endDate != "" {
-		dateFilter := bson.M{}
-		if startDate != "" {
-			startTime, err := time.Parse("2006-01-02", startDate)
-			if err == nil {
-				dateFilter["$gte"] = startTime
-			}
-		}
-		if endDate != "" {
-			endTime, err := time.Parse("2006-01-02", endDate)
-			if err == nil {
-				dateFilter["$lt"] = endTime.Add(24 * time.Hour)
-			}
-		}
-		if len(dateFilter) > 0 {
-			matchStage["created_at"] = dateFilter
-		}
+	labelCount := func(label string) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$eq": []interface{}{"$sentiment.label", label}}, 1, 0}}}
 	}
 
+	pipeline := []bson.M{
+		{"$match": matchStage},
+		{"$group": bson.M{
+			"_id":            "$product_id",
+			"avg_score":      bson.M{"$avg": "$sentiment.score"},
+			"avg_rating":     bson.M{"$avg": "$rating"},
+			"review_count":   bson.M{"$sum": 1},
+			"positive_count": labelCount("positive"),
+			"neutral_count":  labelCount("neutral"),
+			"negative_count": labelCount("negative"),
+		}},
+		{"$lookup": bson.M{
+			"from":         "products",
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "product",
+		}},
+		{"$unwind": bson.M{"path": "$product", "preserveNullAndEmptyArrays": true}},
+		{"$project": bson.M{
+			"_id":            1,
+			"product_name":   "$product.name",
+			"sku":            "$product.sku",
+			"avg_score":      bson.M{"$round": []interface{}{"$avg_score", 2}},
+			"avg_rating":     bson.M{"$round": []interface{}{"$avg_rating", 2}},
+			"review_count":   1,
+			"positive_count": 1,
+			"neutral_count":  1,
+			"negative_count": 1,
+		}},
+		{"$sort": bson.M{"avg_score": 1}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sentiments := []ProductSentiment{}
+	if err := cursor.All(ctx, &sentiments); err != nil {
+		return nil, err
+	}
+
+	return sentiments, nil
+}
+
+// CountFunnelOrders returns the orders placed in [start, end) and how many of those have been paid
+func CountFunnelOrders(ctx context.Context, start, end time.Time) (int64, int64, error) {
+	ctx, cancel := Analytics.Context(ctx)
+	defer cancel()
+
+	collection := Analytics.Collection("orders")
+
+	filter := bson.M{"created_at": bson.M{"$gte": start, "$lt": end}}
+	placed, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	filter["$or"] = []bson.M{
+		{"payment.status": "completed"},
+		{"timeline.paid_at": bson.M{"$exists": true}},
+	}
+	paid, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return placed, paid, nil
+}
+
+// GetTopProductsByRevenue returns top N products by revenue or quantity
+func GetTopProductsByRevenue(ctx context.Context, limit int, sortBy string, startDate, endDate string) ([]TopProduct, error) {
+	ctx, cancel := Analytics.Context(ctx)
+	defer cancel()
+
+	collection := Analytics.Collection("orders")
+
+	// Build match stage for completed orders
+	matchStage := completedOrdersMatch(startDate, endDate)
+
 	// Determine sort field
 	sortField := "total_revenue"
 	if sortBy == "quantity" {
@@ -221,11 +303,11 @@ func GetTopProductsByRevenue(limit int, sortBy string, startDate, endDate string
 }
 
 // GetInventoryStatus returns real-time inventory status with alerts
-func GetInventoryStatus(alertsOnly bool) ([]InventoryStatus, error) {
-	ctx, cancel := global.GetDefaultTimer()
+func GetInventoryStatus(ctx context.Context, alertsOnly bool) ([]InventoryStatus, error) {
+	ctx, cancel := Analytics.Context(ctx)
 	defer cancel()
 
-	collection := GetCollection("products")
+	collection := Analytics.Collection("products")
 
 	// Build match stage
 	matchStage := bson.M{
@@ -291,37 +373,14 @@ func GetInventoryStatus(alertsOnly bool) ([]InventoryStatus, error) {
 }
 
 // GetSalesAnalytics retrieves sales data with grouping by day, week, or month
-func GetSalesAnalytics(startDate, endDate, groupBy string) ([]SalesData, error) {
-	ctx, cancel := global.GetDefaultTimer()
+func GetSalesAnalytics(ctx context.Context, startDate, endDate, groupBy string) ([]SalesData, error) {
+	ctx, cancel := Analytics.Context(ctx)
 	defer cancel()
 
-	collection := GetCollection("orders")
+	collection := Analytics.Collection("orders")
 
 	// Build match stage for date filtering
-	matchStage := bson.M{
-		"status": bson.M{"$in": []string{"shipped", "delivered", "completed"}},
-	}
-
-	// Add date range filtering if provided
-	if startDate != "" || endDate != "" {
-		dateFilter := bson.M{}
-		if startDate != "" {
-			startTime, err := time.Parse("2006-01-02", startDate)
-			if err == nil {
-				dateFilter["$gte"] = startTime
-			}
-		}
-		if endDate != "" {
-			endTime, err := time.Parse("2006-01-02", endDate)
-			if err == nil {
-				// Add 24 hours to include the entire end date
-				dateFilter["$lt"] = endTime.Add(24 * time.Hour)
-			}
-		}
-		if len(dateFilter) > 0 {
-			matchStage["created_at"] = dateFilter
-		}
-	}
+	matchStage := completedOrdersMatch(startDate, endDate)
 
 	// Build group stage based on groupBy parameter
 	var groupStage bson.M
@@ -403,6 +462,345 @@ func GetSalesAnalytics(startDate, endDate, groupBy string) ([]SalesData, error)
 	return salesData, nil
 }
 
+// AOVTrendPoint represents average order value for a single period
+type AOVTrendPoint struct {
+	Date          string  `json:"date" bson:"_id"`
+	TotalOrders   int     `json:"total_orders" bson:"total_orders"`
+	AvgOrderValue float64 `json:"avg_order_value" bson:"avg_order_value"`
+}
+
+// RetentionAnalytics summarizes repeat purchasing and the AOV trend over a date range
+type RetentionAnalytics struct {
+	TotalCustomers       int             `json:"total_customers" bson:"total_customers"`
+	RepeatCustomers      int             `json:"repeat_customers" bson:"repeat_customers"`
+	RepeatPurchaseRate   float64         `json:"repeat_purchase_rate" bson:"repeat_purchase_rate"` // percentage of customers with 2+ orders
+	AvgDaysBetweenOrders float64         `json:"avg_days_between_orders" bson:"avg_days_between_orders"`
+	AOVTrend             []AOVTrendPoint `json:"aov_trend" bson:"aov_trend"`
+}
+
+// GetRetentionAnalytics returns the repeat purchase rate, average days between orders,
+// and average order value grouped by day, week, or month
+func GetRetentionAnalytics(ctx context.Context, startDate, endDate, groupBy string) (*RetentionAnalytics, error) {
+	ctx, cancel := Analytics.Context(ctx)
+	defer cancel()
+
+	collection := Analytics.Collection("orders")
+
+	const msPerDay = 24 * 60 * 60 * 1000
+
+	// Per-customer order counts and first/last order dates; the average gap between
+	// consecutive orders is the total span divided by the number of gaps
+	customersFacet := []bson.M{
+		{"$group": bson.M{
+			"_id":         "$customer_id",
+			"order_count": bson.M{"$sum": 1},
+			"first_order": bson.M{"$min": "$created_at"},
+			"last_order":  bson.M{"$max": "$created_at"},
+		}},
+		{"$group": bson.M{
+			"_id":              nil,
+			"total_customers":  bson.M{"$sum": 1},
+			"repeat_customers": bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$gte": []interface{}{"$order_count", 2}}, 1, 0}}},
+			"total_span_ms":    bson.M{"$sum": bson.M{"$subtract": []interface{}{"$last_order", "$first_order"}}},
+			"total_gaps":       bson.M{"$sum": bson.M{"$subtract": []interface{}{"$order_count", 1}}},
+		}},
+	}
+
+	trendFacet := []bson.M{
+		{"$group": bson.M{
+			"_id":           dateGroupKey(groupBy),
+			"total_orders":  bson.M{"$sum": 1},
+			"total_revenue": bson.M{"$sum": "$totals.grand_total"},
+		}},
+		{"$project": bson.M{
+			"_id":             formatDateProjection(groupBy),
+			"total_orders":    1,
+			"avg_order_value": bson.M{"$round": []interface{}{bson.M{"$divide": []interface{}{"$total_revenue", "$total_orders"}}, 2}},
+		}},
+		{"$sort": bson.M{"_id": 1}},
+	}
+
+	pipeline := []bson.M{
+		{"$match": completedOrdersMatch(startDate, endDate)},
+		{"$facet": bson.M{
+			"customers": customersFacet,
+			"trend":     trendFacet,
+		}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var facets []struct {
+		Customers []struct {
+			TotalCustomers  int   `bson:"total_customers"`
+			RepeatCustomers int   `bson:"repeat_customers"`
+			TotalSpanMs     int64 `bson:"total_span_ms"`
+			TotalGaps       int   `bson:"total_gaps"`
+		} `bson:"customers"`
+		Trend []AOVTrendPoint `bson:"trend"`
+	}
+	if err := cursor.All(ctx, &facets); err != nil {
+		return nil, err
+	}
+
+	result := &RetentionAnalytics{AOVTrend: []AOVTrendPoint{}}
+	if len(facets) == 0 {
+		return result, nil
+	}
+	if facets[0].Trend != nil {
+		result.AOVTrend = facets[0].Trend
+	}
+
+	if len(facets[0].Customers) > 0 {
+		stats := facets[0].Customers[0]
+		result.TotalCustomers = stats.TotalCustomers
+		result.RepeatCustomers = stats.RepeatCustomers
+		if stats.TotalCustomers > 0 {
+			result.RepeatPurchaseRate = math.Round(float64(stats.RepeatCustomers)/float64(stats.TotalCustomers)*10000) / 100
+		}
+		if stats.TotalGaps > 0 {
+			result.AvgDaysBetweenOrders = math.Round(float64(stats.TotalSpanMs)/float64(stats.TotalGaps)/msPerDay*100) / 100
+		}
+	}
+
+	return result, nil
+}
+
+// CitySales represents order revenue for a single city
+type CitySales struct {
+	City            string  `json:"city" bson:"city"`
+	TotalOrders     int     `json:"total_orders" bson:"total_orders"`
+	TotalRevenue    float64 `json:"total_revenue" bson:"total_revenue"`
+	UniqueCustomers int     `json:"unique_customers" bson:"unique_customers"`
+}
+
+// RegionSales represents order revenue for a province with its city breakdown
+type RegionSales struct {
+	Province        string      `json:"province" bson:"_id"`
+	TotalOrders     int         `json:"total_orders" bson:"total_orders"`
+	TotalRevenue    float64     `json:"total_revenue" bson:"total_revenue"`
+	AvgOrderValue   float64     `json:"avg_order_value" bson:"avg_order_value"`
+	UniqueCustomers int         `json:"unique_customers" bson:"unique_customers"`
+	Cities          []CitySales `json:"cities" bson:"cities"`
+}
+
+// GetSalesByRegion aggregates fulfilled order revenue by shipping province and city,
+// highest revenue first. An empty province returns every province.
+func GetSalesByRegion(ctx context.Context, startDate, endDate, province string) ([]RegionSales, error) {
+	ctx, cancel := Analytics.Context(ctx)
+	defer cancel()
+
+	collection := Analytics.Collection("orders")
+
+	matchStage := completedOrdersMatch(startDate, endDate)
+	if province != "" {
+		matchStage["shipping_address.province"] = province
+	}
+
+	pipeline := []bson.M{
+		{"$match": matchStage},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"province": bson.M{"$toUpper": "$shipping_address.province"},
+				"city":     "$shipping_address.city",
+			},
+			"total_orders":  bson.M{"$sum": 1},
+			"total_revenue": bson.M{"$sum": "$totals.grand_total"},
+			"customers":     bson.M{"$addToSet": "$customer_id"},
+		}},
+		{"$sort": bson.M{"total_revenue": -1}},
+		{"$group": bson.M{
+			"_id":           "$_id.province",
+			"total_orders":  bson.M{"$sum": "$total_orders"},
+			"total_revenue": bson.M{"$sum": "$total_revenue"},
+			"customer_sets": bson.M{"$push": "$customers"},
+			"cities": bson.M{"$push": bson.M{
+				"city":             "$_id.city",
+				"total_orders":     "$total_orders",
+				"total_revenue":    bson.M{"$round": []interface{}{"$total_revenue", 2}},
+				"unique_customers": bson.M{"$size": "$customers"},
+			}},
+		}},
+		{"$project": bson.M{
+			"_id":             1,
+			"total_orders":    1,
+			"total_revenue":   bson.M{"$round": []interface{}{"$total_revenue", 2}},
+			"avg_order_value": bson.M{"$round": []interface{}{bson.M{"$divide": []interface{}{"$total_revenue", "$total_orders"}}, 2}},
+			"unique_customers": bson.M{"$size": bson.M{"$reduce": bson.M{
+				"input":        "$customer_sets",
+				"initialValue": []interface{}{},
+				"in":           bson.M{"$setUnion": []interface{}{"$$value", "$$this"}},
+			}}},
+			"cities": 1,
+		}},
+		{"$sort": bson.M{"total_revenue": -1}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	regions := []RegionSales{}
+	if err := cursor.All(ctx, &regions); err != nil {
+		return nil, err
+	}
+
+	return regions, nil
+}
+
+// DailyItemSales represents units and revenue for one SKU or category on one day
+type DailyItemSales struct {
+	Key     string    `json:"key" bson:"key"`
+	Label   string    `json:"label" bson:"label"`
+	Date    time.Time `json:"date" bson:"date"`
+	Units   int       `json:"units" bson:"units"`
+	Revenue float64   `json:"revenue" bson:"revenue"`
+}
+
+// GetDailyItemSales returns units and revenue per day since the given time, grouped by
+// SKU or by product category. Every order that was not cancelled counts as demand.
+func GetDailyItemSales(ctx context.Context, since time.Time, groupBy string) ([]DailyItemSales, error) {
+	ctx, cancel := Analytics.Context(ctx)
+	defer cancel()
+
+	collection := Analytics.Collection("orders")
+
+	pipeline := []bson.M{
+		{"$match": bson.M{
+			"status":     bson.M{"$ne": "cancelled"},
+			"created_at": bson.M{"$gte": since},
+		}},
+		{"$unwind": "$items"},
+	}
+
+	var key, label interface{} = "$items.sku", "$items.name"
+	if groupBy == "category" {
+		pipeline = append(pipeline,
+			bson.M{"$lookup": bson.M{
+				"from":         "products",
+				"localField":   "items.product_id",
+				"foreignField": "_id",
+				"as":           "product",
+			}},
+			bson.M{"$unwind": bson.M{"path": "$product", "preserveNullAndEmptyArrays": true}},
+		)
+		category := bson.M{"$ifNull": []interface{}{"$product.category", "Uncategorized"}}
+		key, label = category, category
+	}
+
+	pipeline = append(pipeline, bson.M{"$group": bson.M{
+		"_id": bson.M{
+			"key":  key,
+			"date": bson.M{"$dateTrunc": bson.M{"date": "$created_at", "unit": "day"}},
+		},
+		"label":   bson.M{"$first": label},
+		"units":   bson.M{"$sum": "$items.quantity"},
+		"revenue": bson.M{"$sum": "$items.subtotal"},
+	}})
+	pipeline = append(pipeline,
+		bson.M{"$project": bson.M{
+			"_id":     0,
+			"key":     "$_id.key",
+			"date":    "$_id.date",
+			"label":   1,
+			"units":   1,
+			"revenue": bson.M{"$round": []interface{}{"$revenue", 2}},
+		}},
+		bson.M{"$sort": bson.M{"key": 1, "date": 1}},
+	)
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sales := []DailyItemSales{}
+	if err := cursor.All(ctx, &sales); err != nil {
+		return nil, err
+	}
+
+	return sales, nil
+}
+
+// RunAggregation runs an already validated pipeline against a collection and returns the raw documents
+func RunAggregation(ctx context.Context, collectionName string, pipeline bson.A) ([]bson.M, error) {
+	ctx, cancel := Analytics.Context(ctx)
+	defer cancel()
+
+	collection := Analytics.Collection(collectionName)
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	results := []bson.M{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// completedOrdersMatch builds the match stage for fulfilled orders within an optional
+// YYYY-MM-DD date range; the end date is inclusive
+func completedOrdersMatch(startDate, endDate string) bson.M {
+	matchStage := bson.M{
+		"status": bson.M{"$in": []string{"shipped", "delivered", "completed"}},
+	}
+
+	dateFilter := bson.M{}
+	if startDate != "" {
+		startTime, err := time.Parse("2006-01-02", startDate)
+		if err == nil {
+			dateFilter["$gte"] = startTime
+		}
+	}
+	if endDate != "" {
+		endTime, err := time.Parse("2006-01-02", endDate)
+		if err == nil {
+			// Add 24 hours to include the entire end date
+			dateFilter["$lt"] = endTime.Add(24 * time.Hour)
+		}
+	}
+	if len(dateFilter) > 0 {
+		matchStage["created_at"] = dateFilter
+	}
+
+	return matchStage
+}
+
+// dateGroupKey returns the $group _id for bucketing orders by day, week, or month.
+// The shape matches what formatDateProjection expects.
+func dateGroupKey(groupBy string) bson.M {
+	switch groupBy {
+	case "week":
+		return bson.M{
+			"year": bson.M{"$isoWeekYear": "$created_at"},
+			"week": bson.M{"$isoWeek": "$created_at"},
+		}
+	case "month":
+		return bson.M{
+			"year":  bson.M{"$year": "$created_at"},
+			"month": bson.M{"$month": "$created_at"},
+		}
+	default: // day
+		return bson.M{
+			"year":  bson.M{"$year": "$created_at"},
+			"month": bson.M{"$month": "$created_at"},
+			"day":   bson.M{"$dayOfMonth": "$created_at"},
+		}
+	}
+}
+
 // formatDateProjection returns the appropriate date formatting based on groupBy
 func formatDateProjection(groupBy string) bson.M {
 	switch groupBy {
@@ -0,0 +1,131 @@
+package mongo
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// SearchModeAtlas routes /api/search through Atlas Search instead of text indexes
+const SearchModeAtlas = "atlas"
+
+// atlasSearchOperator matches query against paths, allowing up to fuzziness typos per term
+func atlasSearchOperator(query string, paths []string, fuzziness int) bson.D {
+	text := bson.D{
+		{Key: "query", Value: query},
+		{Key: "path", Value: paths},
+	}
+	if fuzziness > 0 {
+		text = append(text, bson.E{Key: "fuzzy", Value: bson.D{{Key: "maxEdits", Value: fuzziness}}})
+	}
+	return bson.D{{Key: "text", Value: text}}
+}
+
+// atlasSearchStage is a $search stage matching req's query against paths
+func atlasSearchStage(req SearchRequest, paths []string, highlight bool) bson.D {
+	search := append(bson.D{{Key: "index", Value: settings.AtlasSearchIndex}}, atlasSearchOperator(req.Query, paths, req.Fuzziness)...)
+	if highlight {
+		search = append(search, bson.E{Key: "highlight", Value: bson.D{{Key: "path", Value: paths}}})
+	}
+	return bson.D{{Key: "$search", Value: search}}
+}
+
+// findAtlasMatches runs an Atlas Search query over paths, best matches first, with the
+// matching fragments of each document highlighted. Matches must also satisfy filter.
+// When resultFields is set, documents keep only those fields and highlights from other
+// paths are dropped.
+func findAtlasMatches(ctx context.Context, collection *mongo.Collection, req SearchRequest, filter bson.D, paths, resultFields []string) ([]textMatch, int, error) {
+	search := mongo.Pipeline{atlasSearchStage(req, paths, true)}
+	if len(filter) > 0 {
+		search = append(search, bson.D{{Key: "$match", Value: filter}})
+	}
+	pipeline := append(append(mongo.Pipeline{}, search...),
+		bson.D{{Key: "$skip", Value: req.skip()}},
+		bson.D{{Key: "$limit", Value: req.Limit}},
+		bson.D{{Key: "$addFields", Value: bson.D{
+			{Key: "score", Value: bson.D{{Key: "$meta", Value: "searchScore"}}},
+			{Key: "highlights", Value: bson.D{{Key: "$meta", Value: "searchHighlights"}}},
+		}}},
+	)
+	if len(resultFields) > 0 {
+		projection := append(bson.D{{Key: "score", Value: 1}, {Key: "highlights", Value: 1}}, includeFields(resultFields)...)
+		pipeline = append(pipeline, bson.D{{Key: "$project", Value: projection}})
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var matches []textMatch
+	for cursor.Next(ctx) {
+		var hit struct {
+			Score      float64           `bson:"score"`
+			Highlights []searchHighlight `bson:"highlights"`
+		}
+		if err := cursor.Decode(&hit); err != nil {
+			return nil, 0, err
+		}
+
+		match := textMatch{Document: append(bson.Raw(nil), cursor.Current...), Score: hit.Score}
+		for _, highlight := range hit.Highlights {
+			if len(resultFields) > 0 && !slices.Contains(resultFields, highlight.Path) {
+				continue
+			}
+			match.Highlights = append(match.Highlights, highlight.String())
+		}
+		matches = append(matches, match)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(matches) > 0 && len(matches) < req.Limit {
+		return matches, req.skip() + len(matches), nil
+	}
+
+	total, err := countAtlasMatches(ctx, collection, search)
+	return matches, total, err
+}
+
+// countAtlasMatches counts the documents the search stages select
+func countAtlasMatches(ctx context.Context, collection *mongo.Collection, search mongo.Pipeline) (int, error) {
+	cursor, err := collection.Aggregate(ctx, append(search, bson.D{{Key: "$count", Value: "total"}}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var counts []struct {
+		Total int `bson:"total"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil || len(counts) == 0 {
+		return 0, err
+	}
+	return counts[0].Total, nil
+}
+
+// searchHighlight is one highlighted fragment returned by Atlas Search
+type searchHighlight struct {
+	Path  string `bson:"path"`
+	Texts []struct {
+		Value string `bson:"value"`
+		Type  string `bson:"type"` // hit or text
+	} `bson:"texts"`
+}
+
+// String renders the fragment with matched terms wrapped in <em> tags
+func (h searchHighlight) String() string {
+	var fragment strings.Builder
+	for _, text := range h.Texts {
+		if text.Type == "hit" {
+			fragment.WriteString("<em>" + text.Value + "</em>")
+		} else {
+			fragment.WriteString(text.Value)
+		}
+	}
+	return fragment.String()
+}
@@ -0,0 +1,111 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// AuditLogsResult is a page of audit log entries
+type AuditLogsResult struct {
+	Logs       []models.AuditLog     `json:"logs"`
+	Pagination global.PaginationInfo `json:"pagination"`
+}
+
+// AuditLogFilter narrows the audit log listing. Empty fields are ignored.
+type AuditLogFilter struct {
+	EntityType string
+	EntityID   string
+	Actor      string
+	AdminID    *bson.ObjectID
+	Method     string
+	Route      string // route pattern, such as /api/products/:sku
+	Field      string // only entries that changed this field, such as price
+	StartDate  *time.Time
+	EndDate    *time.Time // exclusive
+}
+
+// auditLogOptions decode request bodies into maps so they serialize back to the same JSON
+var auditLogOptions = options.Collection().SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true})
+
+// auditLogsCollection returns the audit log collection with auditLogOptions
+func auditLogsCollection() *mongo.Collection {
+	return GetDatabase().Collection("audit_logs", auditLogOptions)
+}
+
+// InsertAuditLog stores one audit log entry
+func InsertAuditLog(ctx context.Context, entry *models.AuditLog) error {
+	_, err := auditLogsCollection().InsertOne(ctx, entry)
+	return err
+}
+
+// GetAuditLogs returns audit log entries matching filter, most recent first
+func GetAuditLogs(ctx context.Context, filter AuditLogFilter, page int, limit int) (*AuditLogsResult, error) {
+	collection := auditLogsCollection()
+
+	query := bson.D{}
+	if filter.EntityType != "" {
+		query = append(query, bson.E{Key: "entity_type", Value: filter.EntityType})
+	}
+	if filter.EntityID != "" {
+		query = append(query, bson.E{Key: "entity_id", Value: filter.EntityID})
+	}
+	if filter.Actor != "" {
+		query = append(query, bson.E{Key: "actor", Value: filter.Actor})
+	}
+	if filter.AdminID != nil {
+		query = append(query, bson.E{Key: "admin_id", Value: *filter.AdminID})
+	}
+	if filter.Method != "" {
+		query = append(query, bson.E{Key: "method", Value: filter.Method})
+	}
+	if filter.Route != "" {
+		query = append(query, bson.E{Key: "route", Value: filter.Route})
+	}
+	if filter.Field != "" {
+		query = append(query, bson.E{Key: "changes.field", Value: filter.Field})
+	}
+	dateFilter := bson.D{}
+	if filter.StartDate != nil {
+		dateFilter = append(dateFilter, bson.E{Key: "$gte", Value: *filter.StartDate})
+	}
+	if filter.EndDate != nil {
+		dateFilter = append(dateFilter, bson.E{Key: "$lt", Value: *filter.EndDate})
+	}
+	if len(dateFilter) > 0 {
+		query = append(query, bson.E{Key: "created_at", Value: dateFilter})
+	}
+
+	totalCount, err := collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := (page - 1) * limit
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	logs := []models.AuditLog{}
+	if err := cursor.All(ctx, &logs); err != nil {
+		return nil, err
+	}
+
+	return &AuditLogsResult{
+		Logs:       logs,
+		Pagination: global.NewPagination(page, limit, int(totalCount)),
+	}, nil
+}
@@ -0,0 +1,85 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// CartSnapshotRetention is how long persisted carts are kept after their last update
+const CartSnapshotRetention = 30 * 24 * time.Hour
+
+// SaveCartSnapshot upserts the persisted copy of a cart by session ID
+func SaveCartSnapshot(ctx context.Context, snapshot *models.PersistedCart) error {
+	collection := GetCollection("carts")
+
+	_, err := collection.ReplaceOne(
+		ctx,
+		bson.D{{Key: "session_id", Value: snapshot.SessionID}},
+		snapshot,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// GetCartSnapshot retrieves the persisted copy of a cart by session ID
+func GetCartSnapshot(ctx context.Context, sessionID string) (*models.PersistedCart, error) {
+	collection := GetCollection("carts")
+
+	var snapshot models.PersistedCart
+	err := collection.FindOne(ctx, bson.D{{Key: "session_id", Value: sessionID}}).Decode(&snapshot)
+	if err != nil {
+		return nil, notFoundError(err, "cart snapshot")
+	}
+
+	return &snapshot, nil
+}
+
+// DeleteCartSnapshot removes the persisted copy of a cart
+func DeleteCartSnapshot(ctx context.Context, sessionID string) error {
+	collection := GetCollection("carts")
+
+	_, err := collection.DeleteOne(ctx, bson.D{{Key: "session_id", Value: sessionID}})
+	return err
+}
+
+// GetCustomerIDsWithCartItem returns the customers whose persisted carts hold sku. Only
+// carts with a customer email can be traced to a customer.
+func GetCustomerIDsWithCartItem(ctx context.Context, sku string) ([]bson.ObjectID, error) {
+	var emails []string
+	err := GetCollection("carts").Distinct(ctx, "customer_email", bson.D{
+		{Key: "items." + sku, Value: bson.D{{Key: "$exists", Value: true}}},
+		{Key: "customer_email", Value: bson.D{{Key: "$exists", Value: true}}},
+	}).Decode(&emails)
+	if err != nil {
+		return nil, err
+	}
+	if len(emails) == 0 {
+		return nil, nil
+	}
+
+	cursor, err := GetCollection("customers").Find(ctx,
+		bson.D{{Key: "email", Value: bson.D{{Key: "$in", Value: emails}}}},
+		options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var customers []struct {
+		ID bson.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &customers); err != nil {
+		return nil, err
+	}
+
+	ids := make([]bson.ObjectID, 0, len(customers))
+	for _, customer := range customers {
+		ids = append(ids, customer.ID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,100 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// Server error codes that mean a change stream can't be opened or resumed
+const (
+	changeStreamUnsupportedCode = 40573 // standalone server
+	changeStreamHistoryLostCode = 286   // resume token is older than the oplog
+)
+
+// ErrChangeStreamsUnsupported is returned when the server is not a replica set
+var ErrChangeStreamsUnsupported = errors.New("change streams require a replica set")
+
+// ErrChangeStreamHistoryLost is returned when the resume token has fallen off the oplog
+var ErrChangeStreamHistoryLost = errors.New("change stream resume point is no longer available")
+
+// ProductChange is one insert, update, replace or delete on the products collection
+type ProductChange struct {
+	Operation string
+	ID        bson.ObjectID
+	// Product is the document after the change; nil for deletes or when it was deleted
+	// again before the change was read
+	Product     *models.Product
+	ResumeToken []byte
+}
+
+// productChangeEvent is the subset of a change stream event the watcher reads
+type productChangeEvent struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID bson.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument *models.Product `bson:"fullDocument"`
+}
+
+// WatchProducts calls handle for each change to the products collection, starting after
+// resumeToken or from now when it is nil. It returns when ctx is cancelled, the stream
+// fails, or handle returns an error.
+func WatchProducts(ctx context.Context, resumeToken []byte, handle func(ProductChange) error) error {
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "operationType", Value: bson.D{
+			{Key: "$in", Value: bson.A{"insert", "update", "replace", "delete"}},
+		}}}}},
+	}
+	streamOptions := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if resumeToken != nil {
+		streamOptions.SetResumeAfter(bson.Raw(resumeToken))
+	}
+
+	stream, err := GetCollection("products").Watch(ctx, pipeline, streamOptions)
+	if err != nil {
+		return changeStreamError(err)
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var event productChangeEvent
+		if err := stream.Decode(&event); err != nil {
+			return fmt.Errorf("failed to decode product change: %w", err)
+		}
+
+		change := ProductChange{
+			Operation:   event.OperationType,
+			ID:          event.DocumentKey.ID,
+			Product:     event.FullDocument,
+			ResumeToken: append([]byte(nil), stream.ResumeToken()...),
+		}
+		if err := handle(change); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return changeStreamError(stream.Err())
+}
+
+// changeStreamError maps server errors the watcher handles specially
+func changeStreamError(err error) error {
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		switch {
+		case serverErr.HasErrorCode(changeStreamUnsupportedCode):
+			return ErrChangeStreamsUnsupported
+		case serverErr.HasErrorCode(changeStreamHistoryLostCode):
+			return ErrChangeStreamHistoryLost
+		}
+	}
+	return err
+}
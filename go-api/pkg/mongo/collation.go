@@ -0,0 +1,60 @@
+package mongo
+
+import (
+	"context"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// collationLocales are the ICU locales for each customer language (Preferences.Language).
+// French follows Canadian rules.
+var collationLocales = map[string]string{
+	"en": "en",
+	"fr": "fr_CA",
+	"es": "es",
+}
+
+// DefaultLanguage is used when a request names no supported language
+const DefaultLanguage = "en"
+
+type languageKey struct{}
+
+// WithLanguage returns a copy of ctx whose listings and searches sort by language's
+// rules. language is a Preferences.Language value; anything else sorts as English.
+func WithLanguage(ctx context.Context, language string) context.Context {
+	return context.WithValue(ctx, languageKey{}, language)
+}
+
+// IsSupportedLanguage reports whether language has its own collation
+func IsSupportedLanguage(language string) bool {
+	_, ok := collationLocales[language]
+	return ok
+}
+
+// Languages lists every language with its own collation
+func Languages() []string {
+	languages := make([]string, 0, len(collationLocales))
+	for language := range collationLocales {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Language returns the language ctx's listings sort by: the one set with WithLanguage
+// when it is supported, otherwise DefaultLanguage
+func Language(ctx context.Context) string {
+	language, _ := ctx.Value(languageKey{}).(string)
+	if !IsSupportedLanguage(language) {
+		return DefaultLanguage
+	}
+	return language
+}
+
+// collation compares strings the way ctx's language orders them. At strength 2 case is
+// ignored and accents count after the base letter, so "Éclair" sorts with "eclair"
+// rather than after "Zebra", and "Books" and "books" are the same value.
+func collation(ctx context.Context) *options.Collation {
+	return &options.Collation{Locale: collationLocales[Language(ctx)], Strength: 2}
+}
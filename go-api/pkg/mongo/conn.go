@@ -1,34 +1,55 @@
 package mongo
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"sync"
 
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
 	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/logging"
 )
 
+var (
+	client     *mongo.Client
+	clientOnce sync.Once
+	settings   config.MongoConfig
+)
+
+// GetMongoClient returns the shared MongoDB client, creating it on first use.
+// The client is safe for concurrent use and pools its own connections.
 func GetMongoClient() *mongo.Client {
-	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
+	clientOnce.Do(func() {
+		serverAPI := options.ServerAPI(options.ServerAPIVersion1)
 
-	clientOptions := options.Client().ApplyURI(global.GetMongoURI()).SetServerAPIOptions(serverAPI)
-	client, err := mongo.Connect(clientOptions)
-	if err != nil {
-		log.Fatalf("Failed to create MongoDB client: %v", err)
-	}
+		clientOptions := options.Client().
+			ApplyURI(settings.URI).
+			SetServerAPIOptions(serverAPI).
+			SetMonitor(newTracingMonitor())
+		newClient, err := mongo.Connect(clientOptions)
+		if err != nil {
+			logging.Fatal("Failed to create MongoDB client", "error", err)
+		}
+		client = newClient
+	})
 	return client
 }
 
 func GetDatabase() *mongo.Database {
-	return GetMongoClient().Database(global.GetDatabaseName())
+	return GetMongoClient().Database(settings.Database)
 }
 
 func GetCollection(collectionName string) *mongo.Collection {
 	return GetDatabase().Collection(collectionName)
 }
 
-func InitMongoDB() {
+// InitMongoDB creates the shared client from cfg and verifies the connection
+func InitMongoDB(cfg config.MongoConfig) {
+	settings = cfg
+	configureOperations(cfg)
 
 	client := GetMongoClient()
 	ctx, cancel := global.GetDefaultTimer()
@@ -36,8 +57,22 @@ func InitMongoDB() {
 
 	// Ping the database to verify connection
 	if err := client.Ping(ctx, nil); err != nil {
-		log.Fatalf("Failed to ping MongoDB: %v", err)
+		logging.Fatal("Failed to ping MongoDB", "error", err)
+	}
+
+	slog.Info("Connected to MongoDB successfully")
+}
+
+// Disconnect closes the shared client and its pooled connections. Call it once on shutdown.
+func Disconnect(ctx context.Context) error {
+	if client == nil {
+		return nil
+	}
+
+	if err := client.Disconnect(ctx); err != nil {
+		return err
 	}
 
-	log.Println("Connected to MongoDB successfully")
+	slog.Info("Disconnected from MongoDB")
+	return nil
 }
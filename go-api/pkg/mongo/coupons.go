@@ -0,0 +1,228 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// GetAllCoupons retrieves all coupons sorted by creation date
+func GetAllCoupons(ctx context.Context) ([]models.Coupon, error) {
+	collection := GetCollection("coupons")
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := collection.Find(ctx, bson.D{}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	coupons := []models.Coupon{}
+	if err := cursor.All(ctx, &coupons); err != nil {
+		return nil, err
+	}
+
+	return coupons, nil
+}
+
+// GetCouponByCode retrieves a coupon by its code
+func GetCouponByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	collection := GetCollection("coupons")
+
+	var coupon models.Coupon
+	err := collection.FindOne(ctx, bson.D{{Key: "code", Value: models.NormalizeCouponCode(code)}}).Decode(&coupon)
+	if err != nil {
+		return nil, notFoundError(err, "coupon")
+	}
+
+	return &coupon, nil
+}
+
+// CreateCoupon inserts a new coupon, rejecting duplicate codes
+func CreateCoupon(ctx context.Context, coupon *models.Coupon) (*models.Coupon, error) {
+	collection := GetCollection("coupons")
+
+	// Check if code already exists
+	if _, err := GetCouponByCode(ctx, coupon.Code); err == nil {
+		return nil, duplicate("coupon code")
+	}
+
+	result, err := collection.InsertOne(ctx, coupon)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, duplicate("coupon code")
+		}
+		return nil, err
+	}
+
+	coupon.ID = result.InsertedID.(bson.ObjectID)
+
+	return coupon, nil
+}
+
+// UpdateCoupon updates a coupon with partial updates and returns the updated coupon
+func UpdateCoupon(ctx context.Context, code string, req *models.UpdateCouponRequest) (*models.Coupon, error) {
+	collection := GetCollection("coupons")
+
+	updateDoc := bson.D{}
+
+	if req.Description != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "description", Value: *req.Description})
+	}
+	if req.Value != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "value", Value: *req.Value})
+	}
+	if req.MinSubtotal != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "min_subtotal", Value: *req.MinSubtotal})
+	}
+	if req.ExpiresAt != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "expires_at", Value: *req.ExpiresAt})
+	}
+	if req.UsageLimit != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "usage_limit", Value: *req.UsageLimit})
+	}
+	if req.PerCustomerLimit != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "per_customer_limit", Value: *req.PerCustomerLimit})
+	}
+	if req.Active != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "active", Value: *req.Active})
+	}
+
+	if len(updateDoc) == 0 {
+		return nil, errors.New("no fields to update")
+	}
+
+	updateDoc = append(updateDoc, bson.E{Key: "updated_at", Value: time.Now()})
+
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updatedCoupon models.Coupon
+	err := collection.FindOneAndUpdate(
+		ctx,
+		bson.D{{Key: "code", Value: models.NormalizeCouponCode(code)}},
+		bson.D{{Key: "$set", Value: updateDoc}},
+		findOptions,
+	).Decode(&updatedCoupon)
+
+	if err != nil {
+		return nil, notFoundError(err, "coupon")
+	}
+
+	return &updatedCoupon, nil
+}
+
+// DeleteCoupon removes a coupon by code
+func DeleteCoupon(ctx context.Context, code string) error {
+	collection := GetCollection("coupons")
+
+	result, err := collection.DeleteOne(ctx, bson.D{{Key: "code", Value: models.NormalizeCouponCode(code)}})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return notFound("coupon")
+	}
+
+	return nil
+}
+
+// ValidateCoupon checks that a coupon can be applied to the given subtotal.
+// The per-customer limit is only checked when a customer ID is provided.
+func ValidateCoupon(ctx context.Context, code string, subtotal float64, customerID bson.ObjectID) (*models.Coupon, error) {
+	coupon, err := GetCouponByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if !coupon.Active {
+		return nil, errors.New("coupon is not active")
+	}
+	if coupon.IsExpired() {
+		return nil, errors.New("coupon has expired")
+	}
+	if coupon.IsUsageExhausted() {
+		return nil, limitReached("coupon usage")
+	}
+	if subtotal < coupon.MinSubtotal {
+		return nil, errors.New("minimum subtotal not met")
+	}
+
+	if coupon.PerCustomerLimit > 0 && !customerID.IsZero() {
+		used, err := GetCollection("coupon_redemptions").CountDocuments(ctx, bson.D{
+			{Key: "coupon_code", Value: coupon.Code},
+			{Key: "customer_id", Value: customerID},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if int(used) >= coupon.PerCustomerLimit {
+			return nil, limitReached("coupon per-customer")
+		}
+	}
+
+	return coupon, nil
+}
+
+// RedeemCoupon atomically increments the coupon usage count and records the redemption.
+// The increment only succeeds while the coupon is below its usage limit. Call it in the
+// order's transaction: the increment makes concurrent redemptions of the same coupon
+// conflict, so the per-customer count read after it can't go stale before commit.
+func RedeemCoupon(ctx context.Context, code string, customerID bson.ObjectID, orderNumber string, discount float64) error {
+	collection := GetCollection("coupons")
+	code = models.NormalizeCouponCode(code)
+
+	filter := bson.D{
+		{Key: "code", Value: code},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "usage_limit", Value: 0}},
+			bson.D{{Key: "$expr", Value: bson.D{{Key: "$lt", Value: bson.A{"$usage_count", "$usage_limit"}}}}},
+		}},
+	}
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "usage_count", Value: 1}}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+	}
+
+	var coupon models.Coupon
+	err := collection.FindOneAndUpdate(ctx, filter, update).Decode(&coupon)
+	if err != nil {
+		if isNoDocuments(err) {
+			return limitReached("coupon usage")
+		}
+		return err
+	}
+
+	if coupon.PerCustomerLimit > 0 && !customerID.IsZero() {
+		used, err := GetCollection("coupon_redemptions").CountDocuments(ctx, bson.D{
+			{Key: "coupon_code", Value: code},
+			{Key: "customer_id", Value: customerID},
+		})
+		if err != nil {
+			return err
+		}
+		if int(used) >= coupon.PerCustomerLimit {
+			return limitReached("coupon per-customer")
+		}
+	}
+
+	redemption := models.CouponRedemption{
+		CouponCode:  code,
+		CustomerID:  customerID,
+		OrderNumber: orderNumber,
+		Discount:    discount,
+		RedeemedAt:  time.Now(),
+	}
+	_, err = GetCollection("coupon_redemptions").InsertOne(ctx, redemption)
+	return err
+}
+
+// isCouponLimitError reports whether RedeemCoupon rejected a redemption for a limit
+func isCouponLimitError(err error) bool {
+	return errors.Is(err, ErrLimitReached)
+}
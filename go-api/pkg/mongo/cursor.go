@@ -0,0 +1,135 @@
+package mongo
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// CursorPage is one page of a keyset-paginated listing. NextCursor resumes after the
+// last item and is empty once HasMore is false.
+type CursorPage struct {
+	Items      []bson.M
+	NextCursor string
+	HasMore    bool
+}
+
+// pageCursor is the decoded form of the opaque cursor: the last item's sort key and _id
+type pageCursor struct {
+	SortValue interface{}   `bson:"v"`
+	ID        bson.ObjectID `bson:"id"`
+}
+
+func encodeCursor(sortValue interface{}, id bson.ObjectID) (string, error) {
+	raw, err := bson.Marshal(pageCursor{SortValue: sortValue, ID: id})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(cursor string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var decoded pageCursor
+	if err := bson.Unmarshal(raw, &decoded); err != nil || decoded.ID.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &decoded, nil
+}
+
+// findPageAfter lists the documents of a collection matching match newest first by
+// sortField then _id, starting after cursor (empty for the first page). It seeks with a
+// range filter instead of skip(), so every page costs the same however deep the client
+// goes. Documents without sortField sort last and are paged by _id alone. A non-empty
+// projection limits the fields returned.
+func findPageAfter(ctx context.Context, collectionName string, sortField string, match bson.D, projection bson.D, cursor string, limit int) (*CursorPage, error) {
+	collection := GetCollection(collectionName)
+
+	filter := bson.D{}
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		if after.SortValue == nil {
+			filter = bson.D{
+				{Key: sortField, Value: nil},
+				{Key: "_id", Value: bson.D{{Key: "$lt", Value: after.ID}}},
+			}
+		} else {
+			filter = bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: sortField, Value: bson.D{{Key: "$lt", Value: after.SortValue}}}},
+				bson.D{{Key: sortField, Value: after.SortValue}, {Key: "_id", Value: bson.D{{Key: "$lt", Value: after.ID}}}},
+				bson.D{{Key: sortField, Value: nil}},
+			}}}
+		}
+	}
+
+	switch {
+	case len(match) > 0 && len(filter) > 0:
+		filter = bson.D{{Key: "$and", Value: bson.A{match, filter}}}
+	case len(match) > 0:
+		filter = match
+	}
+
+	// Fetch one extra document to learn whether another page follows
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: -1}, {Key: "_id", Value: -1}}).
+		SetCollation(collation(ctx)).
+		SetLimit(int64(limit + 1))
+	if len(projection) > 0 {
+		findOptions.SetProjection(projection)
+	}
+
+	dbCursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer dbCursor.Close(ctx)
+
+	items := []bson.M{}
+	if err := dbCursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	page := &CursorPage{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.HasMore = true
+
+		last := page.Items[limit-1]
+		id, ok := last["_id"].(bson.ObjectID)
+		if !ok {
+			return nil, errors.New("document has a non-ObjectID _id")
+		}
+		if page.NextCursor, err = encodeCursor(last[sortField], id); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// GetProductsPage returns a cursor page of products, newest first
+func GetProductsPage(ctx context.Context, cursor string, limit int) (*CursorPage, error) {
+	return findPageAfter(ctx, "products", "created_at", nil, nil, cursor, limit)
+}
+
+// GetOrdersPage returns a cursor page of orders, newest first
+func GetOrdersPage(ctx context.Context, cursor string, limit int) (*CursorPage, error) {
+	return findPageAfter(ctx, "orders", "created_at", nil, nil, cursor, limit)
+}
+
+// GetCustomersPage returns a cursor page of the customers matching filter, newest
+// first, without their password hashes
+func GetCustomersPage(ctx context.Context, filter CustomerFilter, cursor string, limit int) (*CursorPage, error) {
+	return findPageAfter(ctx, "customers", "created_at", filter.query(), customerProjection, cursor, limit)
+}
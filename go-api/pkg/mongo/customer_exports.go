@@ -0,0 +1,229 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// customerExportMaxAttempts is how many times a failing export is retried before it is
+// marked failed
+const customerExportMaxAttempts = 3
+
+// RequestCustomerExport queues an export of a customer's data in format. An export in
+// the same format that is still queued, running or downloadable is returned instead of
+// queueing another; created reports whether a new one was queued.
+func RequestCustomerExport(ctx context.Context, customerID bson.ObjectID, format string) (export *models.CustomerExport, created bool, err error) {
+	collection := GetCollection("customer_exports")
+
+	var existing models.CustomerExport
+	err = collection.FindOne(ctx,
+		bson.D{
+			{Key: "customer_id", Value: customerID},
+			{Key: "format", Value: format},
+			{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{models.ExportPending, models.ExportRunning, models.ExportReady}}}},
+			{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+		},
+		options.FindOne().
+			SetSort(bson.D{{Key: "created_at", Value: -1}}).
+			SetProjection(bson.D{{Key: "data", Value: 0}}),
+	).Decode(&existing)
+	if err == nil {
+		return &existing, false, nil
+	}
+	if !isNoDocuments(err) {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	export = &models.CustomerExport{
+		CustomerID:  customerID,
+		Format:      format,
+		Status:      models.ExportPending,
+		AvailableAt: now,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(models.CustomerExportRetention),
+	}
+	result, err := collection.InsertOne(ctx, export)
+	if err != nil {
+		return nil, false, err
+	}
+	export.ID = result.InsertedID.(bson.ObjectID)
+	return export, true, nil
+}
+
+// GetCustomerExport returns one of a customer's exports without its file
+func GetCustomerExport(ctx context.Context, customerID, exportID bson.ObjectID) (*models.CustomerExport, error) {
+	var export models.CustomerExport
+	err := GetCollection("customer_exports").FindOne(ctx,
+		bson.D{{Key: "_id", Value: exportID}, {Key: "customer_id", Value: customerID}},
+		options.FindOne().SetProjection(bson.D{{Key: "data", Value: 0}}),
+	).Decode(&export)
+	if err != nil {
+		return nil, notFoundError(err, "export")
+	}
+	return &export, nil
+}
+
+// GetCustomerExportFile returns a ready export with its file
+func GetCustomerExportFile(ctx context.Context, exportID bson.ObjectID) (*models.CustomerExport, error) {
+	var export models.CustomerExport
+	err := GetCollection("customer_exports").FindOne(ctx, bson.D{
+		{Key: "_id", Value: exportID},
+		{Key: "status", Value: models.ExportReady},
+	}).Decode(&export)
+	if err != nil {
+		return nil, notFoundError(err, "export")
+	}
+	return &export, nil
+}
+
+// ClaimCustomerExport leases the oldest queued export, or one whose worker stopped
+// before finishing, hiding it from other workers for lease. It returns nil when there
+// is nothing to do.
+func ClaimCustomerExport(ctx context.Context, lease time.Duration) (*models.CustomerExport, error) {
+	now := time.Now()
+	filter := bson.D{
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{models.ExportPending, models.ExportRunning}}}},
+		{Key: "available_at", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "status", Value: models.ExportRunning}, {Key: "available_at", Value: now.Add(lease)}}},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+	}
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var export models.CustomerExport
+	err := GetCollection("customer_exports").FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&export)
+	if err != nil {
+		if isNoDocuments(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &export, nil
+}
+
+// CompleteCustomerExport stores a claimed export's file and makes it downloadable
+func CompleteCustomerExport(ctx context.Context, exportID bson.ObjectID, data []byte) error {
+	now := time.Now()
+	_, err := GetCollection("customer_exports").UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: exportID}},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: models.ExportReady},
+				{Key: "data", Value: data},
+				{Key: "size_bytes", Value: len(data)},
+				{Key: "completed_at", Value: now},
+				{Key: "expires_at", Value: now.Add(models.CustomerExportRetention)},
+			}},
+			{Key: "$unset", Value: bson.D{{Key: "error", Value: ""}}},
+		},
+	)
+	return err
+}
+
+// RetryCustomerExport records a failed attempt. The export is queued again at retryAt,
+// or marked failed when it has used all its attempts.
+func RetryCustomerExport(ctx context.Context, export *models.CustomerExport, exportErr error, retryAt time.Time) error {
+	status := models.ExportPending
+	if export.Attempts >= customerExportMaxAttempts {
+		status = models.ExportFailed
+	}
+
+	_, err := GetCollection("customer_exports").UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: export.ID}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: status},
+			{Key: "error", Value: exportErr.Error()},
+			{Key: "available_at", Value: retryAt},
+		}}},
+	)
+	return err
+}
+
+// GatherCustomerData collects everything stored about a customer: their profile,
+// orders, reviews, cart snapshots, saved searches, loyalty ledger and the audit log
+// entries for their account and orders. The history is read with the Bulk read
+// preference and deadline.
+func GatherCustomerData(ctx context.Context, customerID bson.ObjectID) (*models.CustomerDataExport, error) {
+	ctx, cancel := Bulk.Context(ctx)
+	defer cancel()
+
+	customer, err := GetCustomerByID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &models.CustomerDataExport{
+		ExportedAt:          time.Now().UTC(),
+		Customer:            customer,
+		Orders:              []models.Order{},
+		Reviews:             []models.Review{},
+		CartSnapshots:       []models.PersistedCart{},
+		AbandonedCarts:      []models.AbandonedCart{},
+		SavedSearches:       []models.SavedSearch{},
+		LoyaltyTransactions: []models.LoyaltyTransaction{},
+		Notifications:       []models.InboxNotification{},
+		AuditLogs:           []models.AuditLog{},
+	}
+	byCustomer := bson.D{{Key: "customer_id", Value: customerID}}
+	byEmail := bson.D{{Key: "customer_email", Value: customer.Email}}
+	oldestFirst := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	if err := findAll(ctx, "orders", byCustomer, oldestFirst, &data.Orders); err != nil {
+		return nil, err
+	}
+	if err := findAll(ctx, "reviews", byCustomer, oldestFirst, &data.Reviews); err != nil {
+		return nil, err
+	}
+	if err := findAll(ctx, "carts", byEmail, options.Find(), &data.CartSnapshots); err != nil {
+		return nil, err
+	}
+	if err := findAll(ctx, "abandoned_carts", byEmail, options.Find().SetSort(bson.D{{Key: "detected_at", Value: 1}}), &data.AbandonedCarts); err != nil {
+		return nil, err
+	}
+	if err := findAll(ctx, "saved_searches", byCustomer, oldestFirst, &data.SavedSearches); err != nil {
+		return nil, err
+	}
+	if err := findAll(ctx, "loyalty_transactions", byCustomer, oldestFirst, &data.LoyaltyTransactions); err != nil {
+		return nil, err
+	}
+	if err := findAll(ctx, "inbox", byCustomer, oldestFirst, &data.Notifications); err != nil {
+		return nil, err
+	}
+
+	orderNumbers := bson.A{}
+	for _, order := range data.Orders {
+		orderNumbers = append(orderNumbers, order.OrderNumber)
+	}
+	cursor, err := Bulk.Collection("audit_logs", auditLogOptions).Find(ctx,
+		bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "entity_type", Value: "customer"}, {Key: "entity_id", Value: customerID.Hex()}},
+			bson.D{{Key: "entity_type", Value: "order"}, {Key: "entity_id", Value: bson.D{{Key: "$in", Value: orderNumbers}}}},
+		}}},
+		oldestFirst,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &data.AuditLogs); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// findAll decodes every document of collection matching filter into results
+func findAll(ctx context.Context, collection string, filter bson.D, findOptions *options.FindOptionsBuilder, results interface{}) error {
+	cursor, err := Bulk.Collection(collection).Find(ctx, filter, findOptions)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
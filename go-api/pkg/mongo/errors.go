@@ -0,0 +1,72 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// ErrNotFound is returned when a lookup matches no document. It is wrapped with what was
+// missing, such as "customer not found", so check for it with errors.Is.
+var ErrNotFound = errors.New("not found")
+
+// ErrDuplicate is returned when a write would break a unique index, wrapped with what
+// already exists, such as "email already exists"
+var ErrDuplicate = errors.New("already exists")
+
+// ErrLimitReached is returned when a create or redemption would go past a limit, wrapped
+// with which one, such as "coupon usage limit reached"
+var ErrLimitReached = errors.New("limit reached")
+
+// ErrInsufficientStock is returned when a warehouse can't cover a decrement or an order
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrProductModified is returned by UpdateProductBySKUIfUnmodified when the product
+// changed since the caller read it
+var ErrProductModified = errors.New("product was modified")
+
+// ErrProductUnavailable is returned when an order includes a product that isn't active
+var ErrProductUnavailable = errors.New("is not for sale")
+
+// ErrOrderPaid is returned when a payment is recorded on an order that is already paid
+var ErrOrderPaid = errors.New("order already paid")
+
+// ErrPaymentMismatch is returned when a payment is for another intent than the order's
+// current one, or doesn't cover the order's amount due
+var ErrPaymentMismatch = errors.New("payment does not match order")
+
+// ErrLastAddress is returned when deleting a customer's only address
+var ErrLastAddress = errors.New("cannot delete last address")
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// notFound describes a missing document as "<what> not found"
+func notFound(what string) error {
+	return fmt.Errorf("%s %w", what, ErrNotFound)
+}
+
+// duplicate describes a unique index clash as "<what> already exists"
+func duplicate(what string) error {
+	return fmt.Errorf("%s %w", what, ErrDuplicate)
+}
+
+// limitReached describes a limit as "<what> limit reached"
+func limitReached(what string) error {
+	return fmt.Errorf("%s %w", what, ErrLimitReached)
+}
+
+// isNoDocuments reports whether err is the driver's error for a lookup that matched nothing
+func isNoDocuments(err error) bool {
+	return errors.Is(err, mongo.ErrNoDocuments)
+}
+
+// notFoundError maps the driver's no-documents error to notFound(what) and returns any
+// other error as it is
+func notFoundError(err error, what string) error {
+	if isNoDocuments(err) {
+		return notFound(what)
+	}
+	return err
+}
@@ -0,0 +1,189 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// AllocateOrderInventory reserves stock for every order item from specific warehouses,
+// decrementing the warehouse and total stock and logging each allocation as a sale.
+// Items are filled from the warehouse with the most stock first and split across warehouses
+// when no single one can cover the quantity. On failure all allocations made so far are released.
+func AllocateOrderInventory(ctx context.Context, order *models.Order) ([]models.OrderItem, error) {
+	items := make([]models.OrderItem, len(order.Items))
+	copy(items, order.Items)
+
+	var allocated []allocatedStock
+	var logs []models.InventoryLog
+	changed := map[string]*models.Product{}
+	sold := map[string]int{}
+
+	for i, item := range items {
+		product, err := GetProductBySKU(ctx, item.SKU)
+		if err != nil {
+			releaseAllocatedStock(ctx, allocated)
+			if errors.Is(err, ErrNotFound) {
+				return nil, fmt.Errorf("product %s %w", item.SKU, ErrNotFound)
+			}
+			return nil, err
+		}
+
+		plan, ok := planAllocation(product.Stock, item.Quantity)
+		if !ok {
+			releaseAllocatedStock(ctx, allocated)
+			return nil, fmt.Errorf("%w to allocate order", ErrInsufficientStock)
+		}
+
+		items[i].Allocations = nil
+		for _, allocation := range plan {
+			updated, err := applyStockChange(ctx, item.SKU, allocation.Warehouse, -allocation.Quantity)
+			if err != nil {
+				// Stock moved since it was read; undo this order's allocations
+				releaseAllocatedStock(ctx, allocated)
+				if errors.Is(err, ErrInsufficientStock) {
+					return nil, fmt.Errorf("%w to allocate order", ErrInsufficientStock)
+				}
+				return nil, err
+			}
+			allocated = append(allocated, allocatedStock{SKU: item.SKU, WarehouseAllocation: allocation})
+			changed[item.SKU] = updated
+			sold[item.SKU] += allocation.Quantity
+			items[i].Allocations = append(items[i].Allocations, allocation)
+
+			after := updated.Stock.Warehouse(allocation.Warehouse)
+			logs = append(logs, models.InventoryLog{
+				ProductID:       updated.ID,
+				SKU:             item.SKU,
+				Warehouse:       allocation.Warehouse,
+				ChangeType:      "sale",
+				QuantityBefore:  after + allocation.Quantity,
+				QuantityAfter:   after,
+				QuantityChanged: -allocation.Quantity,
+				Reason:          fmt.Sprintf("Order #%s allocated for fulfillment", order.OrderNumber),
+				PerformedBy:     "system",
+				CreatedAt:       time.Now(),
+			})
+		}
+	}
+
+	for sku, product := range changed {
+		if err := enqueueEvent(ctx, events.StockChanged, events.StockChangedEvent{SKU: sku, Reason: "sale", PreviousTotal: product.Stock.Total + sold[sku], Product: product}); err != nil {
+			releaseAllocatedStock(ctx, allocated)
+			return nil, err
+		}
+	}
+	if err := insertInventoryLogs(ctx, logs); err != nil {
+		releaseAllocatedStock(ctx, allocated)
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// ReleaseOrderInventory returns the stock allocated to a cancelled order to the
+// warehouses it came from, logging each return, and gives back the order's items
+// without allocations so reinstating the order allocates it again. Run it inside
+// inTransaction with the order's status change.
+func ReleaseOrderInventory(ctx context.Context, order *models.Order) ([]models.OrderItem, error) {
+	items := make([]models.OrderItem, len(order.Items))
+	copy(items, order.Items)
+
+	var logs []models.InventoryLog
+	changed := map[string]*models.Product{}
+	returned := map[string]int{}
+
+	for i, item := range items {
+		for _, allocation := range item.Allocations {
+			updated, err := applyStockChange(ctx, item.SKU, allocation.Warehouse, allocation.Quantity)
+			if err != nil {
+				return nil, fmt.Errorf("failed to return stock for %s: %w", item.SKU, err)
+			}
+			changed[item.SKU] = updated
+			returned[item.SKU] += allocation.Quantity
+
+			after := updated.Stock.Warehouse(allocation.Warehouse)
+			logs = append(logs, models.InventoryLog{
+				ProductID:       updated.ID,
+				SKU:             item.SKU,
+				Warehouse:       allocation.Warehouse,
+				ChangeType:      "return",
+				QuantityBefore:  after - allocation.Quantity,
+				QuantityAfter:   after,
+				QuantityChanged: allocation.Quantity,
+				Reason:          fmt.Sprintf("Order #%s cancelled", order.OrderNumber),
+				PerformedBy:     "system",
+				CreatedAt:       time.Now(),
+			})
+		}
+		items[i].Allocations = nil
+	}
+
+	for sku, product := range changed {
+		if err := enqueueEvent(ctx, events.StockChanged, events.StockChangedEvent{SKU: sku, Reason: "return", PreviousTotal: product.Stock.Total - returned[sku], Product: product}); err != nil {
+			return nil, err
+		}
+	}
+	if err := insertInventoryLogs(ctx, logs); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// allocatedStock tracks a completed decrement so it can be released on failure
+type allocatedStock struct {
+	SKU string
+	models.WarehouseAllocation
+}
+
+// planAllocation splits a quantity across warehouses, largest available stock first
+func planAllocation(stock models.Stock, quantity int) ([]models.WarehouseAllocation, bool) {
+	warehouses := make([]string, len(models.Warehouses))
+	copy(warehouses, models.Warehouses)
+	sort.SliceStable(warehouses, func(i, j int) bool {
+		return stock.Warehouse(warehouses[i]) > stock.Warehouse(warehouses[j])
+	})
+
+	// Prefer a single warehouse that can ship the whole quantity
+	if stock.Warehouse(warehouses[0]) >= quantity {
+		return []models.WarehouseAllocation{{Warehouse: warehouses[0], Quantity: quantity}}, true
+	}
+
+	var plan []models.WarehouseAllocation
+	remaining := quantity
+	for _, warehouse := range warehouses {
+		available := stock.Warehouse(warehouse)
+		if available <= 0 {
+			continue
+		}
+		take := min(available, remaining)
+		plan = append(plan, models.WarehouseAllocation{Warehouse: warehouse, Quantity: take})
+		remaining -= take
+		if remaining == 0 {
+			return plan, true
+		}
+	}
+
+	return nil, false
+}
+
+// releaseAllocatedStock returns previously allocated stock to its warehouses. Inside a
+// transaction there is nothing to do, since the failed transaction rolls the
+// allocations back.
+func releaseAllocatedStock(ctx context.Context, allocated []allocatedStock) {
+	if inSession(ctx) {
+		return
+	}
+	for _, allocation := range allocated {
+		if _, err := applyStockChange(ctx, allocation.SKU, allocation.Warehouse, allocation.Quantity); err != nil {
+			slog.WarnContext(ctx, "Failed to release allocation", "quantity", allocation.Quantity, "sku", allocation.SKU, "warehouse", allocation.Warehouse, "error", err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// IssueGiftCard inserts a new gift card, regenerating the code on the rare collision
+func IssueGiftCard(ctx context.Context, giftCard *models.GiftCard) (*models.GiftCard, error) {
+	collection := GetCollection("gift_cards")
+
+	var result *mongo.InsertOneResult
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		result, err = collection.InsertOne(ctx, giftCard)
+		if err == nil || !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+		giftCard.Code = models.GenerateGiftCardCode()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	giftCard.ID = result.InsertedID.(bson.ObjectID)
+
+	recordGiftCardTransaction(ctx, giftCard.Code, "issue", giftCard.Balance, giftCard.Balance, "")
+
+	return giftCard, nil
+}
+
+// GetAllGiftCards retrieves all gift cards sorted by creation date
+func GetAllGiftCards(ctx context.Context) ([]models.GiftCard, error) {
+	collection := GetCollection("gift_cards")
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := collection.Find(ctx, bson.D{}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	giftCards := []models.GiftCard{}
+	if err := cursor.All(ctx, &giftCards); err != nil {
+		return nil, err
+	}
+
+	return giftCards, nil
+}
+
+// GetGiftCardByCode retrieves a gift card by its code
+func GetGiftCardByCode(ctx context.Context, code string) (*models.GiftCard, error) {
+	collection := GetCollection("gift_cards")
+
+	var giftCard models.GiftCard
+	err := collection.FindOne(ctx, bson.D{{Key: "code", Value: strings.ToUpper(strings.TrimSpace(code))}}).Decode(&giftCard)
+	if err != nil {
+		return nil, notFoundError(err, "gift card")
+	}
+
+	return &giftCard, nil
+}
+
+// RedeemGiftCard atomically deducts up to the requested amount from a gift card.
+// A requested amount of 0 redeems as much of maxAmount as the balance allows.
+// Returns the amount actually redeemed.
+func RedeemGiftCard(ctx context.Context, code string, requested, maxAmount float64, orderNumber string) (float64, error) {
+	collection := GetCollection("gift_cards")
+
+	giftCard, err := GetGiftCardByCode(ctx, code)
+	if err != nil {
+		return 0, err
+	}
+	if giftCard.IsExpired() {
+		return 0, errors.New("gift card has expired")
+	}
+	if !giftCard.IsRedeemable() {
+		return 0, errors.New("gift card is not redeemable")
+	}
+
+	amount := requested
+	if amount <= 0 || amount > maxAmount {
+		amount = maxAmount
+	}
+	if amount > giftCard.Balance {
+		if requested > 0 {
+			return 0, errors.New("insufficient gift card balance")
+		}
+		amount = giftCard.Balance
+	}
+	amount = math.Round(amount*100) / 100
+	if amount <= 0 {
+		return 0, nil
+	}
+
+	// Only deduct if the balance still covers the amount at write time
+	filter := bson.D{
+		{Key: "code", Value: giftCard.Code},
+		{Key: "status", Value: "active"},
+		{Key: "balance", Value: bson.D{{Key: "$gte", Value: amount}}},
+	}
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "balance", Value: -amount}}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+	}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated models.GiftCard
+	err = collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&updated)
+	if err != nil {
+		if isNoDocuments(err) {
+			return 0, errors.New("insufficient gift card balance")
+		}
+		return 0, err
+	}
+
+	// Mark the card depleted once the balance reaches zero
+	if updated.Balance < 0.01 {
+		_, _ = collection.UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: updated.ID}, {Key: "balance", Value: bson.D{{Key: "$lt", Value: 0.01}}}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "depleted"}, {Key: "balance", Value: 0.0}}}},
+		)
+	}
+
+	recordGiftCardTransaction(ctx, updated.Code, "redeem", amount, updated.Balance, orderNumber)
+
+	return amount, nil
+}
+
+// RefundGiftCard returns a previously redeemed amount to a gift card
+func RefundGiftCard(ctx context.Context, code string, amount float64, orderNumber string) error {
+	collection := GetCollection("gift_cards")
+
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "balance", Value: amount}}},
+		{Key: "$set", Value: bson.D{{Key: "status", Value: "active"}, {Key: "updated_at", Value: time.Now()}}},
+	}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated models.GiftCard
+	err := collection.FindOneAndUpdate(ctx, bson.D{{Key: "code", Value: code}}, update, findOptions).Decode(&updated)
+	if err != nil {
+		return notFoundError(err, "gift card")
+	}
+
+	recordGiftCardTransaction(ctx, updated.Code, "refund", amount, updated.Balance, orderNumber)
+
+	return nil
+}
+
+// refundOrderGiftCard returns what an order redeemed from its gift card. The order is
+// marked gift_card_refunded in the same write that claims the refund, so cancelling and
+// then refunding an order only returns the amount once. Run it inside inTransaction.
+func refundOrderGiftCard(ctx context.Context, order *models.Order) error {
+	if order.GiftCardCode == "" || order.Totals.GiftCard <= 0 {
+		return nil
+	}
+
+	result, err := GetCollection("orders").UpdateOne(ctx,
+		bson.D{
+			{Key: "order_number", Value: order.OrderNumber},
+			{Key: "gift_card_refunded", Value: bson.D{{Key: "$ne", Value: true}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "gift_card_refunded", Value: true}}}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return nil
+	}
+
+	order.GiftCardRefunded = true
+	return RefundGiftCard(ctx, order.GiftCardCode, order.Totals.GiftCard, order.OrderNumber)
+}
+
+// recordGiftCardTransaction appends an entry to the gift card ledger
+func recordGiftCardTransaction(ctx context.Context, code, txType string, amount, balanceAfter float64, orderNumber string) {
+	transaction := models.GiftCardTransaction{
+		Code:         code,
+		Type:         txType,
+		Amount:       amount,
+		BalanceAfter: balanceAfter,
+		OrderNumber:  orderNumber,
+		CreatedAt:    time.Now(),
+	}
+	if _, err := GetCollection("gift_card_transactions").InsertOne(ctx, transaction); err != nil {
+		slog.WarnContext(ctx, "Failed to record gift card transaction", "type", txType, "code", code, "error", err)
+	}
+}
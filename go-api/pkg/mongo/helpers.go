@@ -4,22 +4,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
 	"julianmorley.ca/con-plar/prog2270/pkg/global"
 	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/shipping"
 )
 
-func GetAllProducts() ([]bson.M, error) {
-	ctx, cancel := global.GetDefaultTimer()
+// GetAllProducts lists every product by name in the request's language, then by SKU
+func GetAllProducts(ctx context.Context) ([]bson.M, error) {
+	ctx, cancel := global.GetRequestTimer(ctx)
 	defer cancel()
 	collection := GetCollection("products")
 
-	cursor, err := collection.Find(ctx, bson.D{})
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "name", Value: 1}, {Key: "sku", Value: 1}}).
+		SetCollation(collation(ctx))
+
+	cursor, err := collection.Find(ctx, bson.D{}, findOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -42,23 +51,35 @@ func CreateProducts(ctx context.Context, products []*models.Product) ([]*models.
 		docs[i] = product
 	}
 
-	result, err := collection.InsertMany(ctx, docs)
-	if err != nil {
-		return nil, err
-	}
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		result, err := collection.InsertMany(ctx, docs)
+		if err != nil {
+			return err
+		}
 
-	// Update the products with their inserted IDs
-	for i, insertedID := range result.InsertedIDs {
-		if objectID, ok := insertedID.(bson.ObjectID); ok {
-			products[i].ID = objectID
+		// Update the products with their inserted IDs
+		for i, insertedID := range result.InsertedIDs {
+			if objectID, ok := insertedID.(bson.ObjectID); ok {
+				products[i].ID = objectID
+			}
+		}
+
+		for _, product := range products {
+			if err := enqueueEvent(ctx, events.ProductCreated, events.ProductCreatedEvent{Product: product}); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return products, nil
 }
 
-func GetAllOrders() ([]bson.M, error) {
-	ctx, cancel := global.GetDefaultTimer()
+func GetAllOrders(ctx context.Context) ([]bson.M, error) {
+	ctx, cancel := global.GetRequestTimer(ctx)
 	defer cancel()
 	collection := GetCollection("orders")
 
@@ -76,23 +97,87 @@ func GetAllOrders() ([]bson.M, error) {
 	return items, nil
 }
 
-func GetAllCustomers() ([]bson.M, error) {
-	ctx, cancel := global.GetDefaultTimer()
-	defer cancel()
+// CustomerFilter narrows the customer listing. Empty fields are ignored.
+type CustomerFilter struct {
+	AccountStatus string
+	LoyaltyTier   string // Bronze, Silver, Gold or Platinum, matched by loyalty points
+	MinTotalSpent *float64
+	CreatedFrom   *time.Time
+	CreatedTo     *time.Time // exclusive
+}
+
+type CustomersResult struct {
+	Customers  []models.Customer     `json:"customers"`
+	Pagination global.PaginationInfo `json:"pagination"`
+}
+
+// customerProjection leaves password hashes out of customer listings
+var customerProjection = bson.D{{Key: "password", Value: 0}}
+
+// query builds the MongoDB filter for the customers matching f
+func (f CustomerFilter) query() bson.D {
+	query := bson.D{}
+	if f.AccountStatus != "" {
+		query = append(query, bson.E{Key: "account_status", Value: f.AccountStatus})
+	}
+	if min, max, ok := models.LoyaltyTierPointRange(f.LoyaltyTier); ok {
+		points := bson.D{{Key: "$gte", Value: min}}
+		if max > 0 {
+			points = append(points, bson.E{Key: "$lt", Value: max})
+		}
+		query = append(query, bson.E{Key: "loyalty_points", Value: points})
+	}
+	if f.MinTotalSpent != nil {
+		query = append(query, bson.E{Key: "total_spent", Value: bson.D{{Key: "$gte", Value: *f.MinTotalSpent}}})
+	}
+	dateFilter := bson.D{}
+	if f.CreatedFrom != nil {
+		dateFilter = append(dateFilter, bson.E{Key: "$gte", Value: *f.CreatedFrom})
+	}
+	if f.CreatedTo != nil {
+		dateFilter = append(dateFilter, bson.E{Key: "$lt", Value: *f.CreatedTo})
+	}
+	if len(dateFilter) > 0 {
+		query = append(query, bson.E{Key: "created_at", Value: dateFilter})
+	}
+	return query
+}
+
+// GetAllCustomers returns a page of the customers matching filter, newest first,
+// without their password hashes
+func GetAllCustomers(ctx context.Context, filter CustomerFilter, page int, limit int) (*CustomersResult, error) {
 	collection := GetCollection("customers")
+	query := filter.query()
 
-	cursor, err := collection.Find(ctx, bson.D{})
+	totalCount, err := collection.CountDocuments(ctx, query, options.Count().SetCollation(collation(ctx)))
+	if err != nil {
+		return nil, err
+	}
+
+	skip := (page - 1) * limit
+
+	findOptions := options.Find().
+		SetProjection(customerProjection).
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetCollation(collation(ctx)).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, query, findOptions)
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var items []bson.M
-	if err := cursor.All(ctx, &items); err != nil {
+	customers := []models.Customer{}
+	if err := cursor.All(ctx, &customers); err != nil {
 		return nil, err
 	}
 
-	return items, nil
+	return &CustomersResult{
+		Customers:  customers,
+		Pagination: global.NewPagination(page, limit, int(totalCount)),
+	}, nil
 }
 
 // GetProductBySKU retrieves a single product by its SKU
@@ -102,98 +187,228 @@ func GetProductBySKU(ctx context.Context, sku string) (*models.Product, error) {
 	var product models.Product
 	err := collection.FindOne(ctx, bson.D{{"sku", sku}}).Decode(&product)
 	if err != nil {
-		return nil, err
+		return nil, notFoundError(err, "product")
 	}
 
 	return &product, nil
 }
 
-// UpdateProductBySKU updates specific fields of a product by SKU and returns the updated product
+// GetProductsBySKUs retrieves all products matching the given SKUs, keyed by SKU
+func GetProductsBySKUs(ctx context.Context, skus []string) (map[string]*models.Product, error) {
+	collection := GetCollection("products")
+
+	cursor, err := collection.Find(ctx, bson.D{{Key: "sku", Value: bson.D{{Key: "$in", Value: skus}}}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var products []models.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+
+	productsBySKU := make(map[string]*models.Product, len(products))
+	for i := range products {
+		productsBySKU[products[i].SKU] = &products[i]
+	}
+
+	return productsBySKU, nil
+}
+
+// previousPrice has a product update return the price it replaced
+var previousPrice = options.FindOneAndUpdate().SetProjection(bson.D{{Key: "price", Value: 1}})
+
+// UpdateProductBySKU updates specific fields of a product by SKU and returns the updated product.
+// Keys may be dotted paths such as stock.warehouse_main; nil values remove the field.
+// A key outside productUpdateSchema or a mistyped value returns an *InvalidUpdateError.
 func UpdateProductBySKU(ctx context.Context, sku string, updates map[string]interface{}) (*models.Product, error) {
 	collection := GetCollection("products")
 
+	if err := productUpdateSchema.check(updates); err != nil {
+		return nil, err
+	}
+
 	// Add updated_at timestamp to the updates
 	updates["updated_at"] = time.Now()
 
-	// Create update document
-	updateDoc := bson.D{{"$set", updates}}
+	var product *models.Product
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		// Update the document; nil values unset their field. The price from before the
+		// update goes in the event for price drop notifications.
+		var before models.Product
+		err := collection.FindOneAndUpdate(ctx, bson.D{{"sku", sku}}, patchPipeline(updates), previousPrice).Decode(&before)
+		if err != nil && !isNoDocuments(err) {
+			return err
+		}
 
-	// Update the document
-	_, err := collection.UpdateOne(ctx, bson.D{{"sku", sku}}, updateDoc)
+		// Fetch the updated product
+		product, err = GetProductBySKU(ctx, sku)
+		if err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, events.ProductUpdated, events.ProductUpdatedEvent{Product: product, PreviousPrice: before.Price})
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Fetch and return the updated product
-	return GetProductBySKU(ctx, sku)
+	return product, nil
 }
 
-// DeleteProductBySKU deletes a product by SKU and returns the deleted product info
-func DeleteProductBySKU(ctx context.Context, sku string) (*models.Product, error) {
+// UpdateProductBySKUIfUnmodified applies updates only if the product's updated_at still
+// equals lastUpdated, so a concurrent edit is not silently overwritten. Returns
+// "product was modified" when another write got there first.
+func UpdateProductBySKUIfUnmodified(ctx context.Context, sku string, lastUpdated time.Time, updates map[string]interface{}) (*models.Product, error) {
 	collection := GetCollection("products")
 
-	// First get the product to return it and for cache cleanup
-	product, err := GetProductBySKU(ctx, sku)
-	if err != nil {
+	if err := productUpdateSchema.check(updates); err != nil {
 		return nil, err
 	}
 
-	// Delete the document
-	result, err := collection.DeleteOne(ctx, bson.D{{"sku", sku}})
+	updates["updated_at"] = time.Now()
+
+	var product *models.Product
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		var before models.Product
+		err := collection.FindOneAndUpdate(ctx, bson.D{{"sku", sku}, {"updated_at", lastUpdated}}, patchPipeline(updates), previousPrice).Decode(&before)
+		if err != nil {
+			if !isNoDocuments(err) {
+				return err
+			}
+			// Distinguish a deleted product from one that changed underneath us
+			if _, err := GetProductBySKU(ctx, sku); err != nil {
+				return err
+			}
+			return ErrProductModified
+		}
+
+		product, err = GetProductBySKU(ctx, sku)
+		if err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, events.ProductUpdated, events.ProductUpdatedEvent{Product: product, PreviousPrice: before.Price})
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if document was actually deleted
-	if result.DeletedCount == 0 {
-		return nil, errors.New("mongo: no documents in result")
+	return product, nil
+}
+
+// DeleteProductBySKU deletes a product by SKU and returns the deleted product info
+func DeleteProductBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	collection := GetCollection("products")
+
+	var product *models.Product
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		// First get the product to return it and for cache cleanup
+		var err error
+		product, err = GetProductBySKU(ctx, sku)
+		if err != nil {
+			return err
+		}
+
+		// Delete the document
+		result, err := collection.DeleteOne(ctx, bson.D{{"sku", sku}})
+		if err != nil {
+			return err
+		}
+
+		// Check if document was actually deleted
+		if result.DeletedCount == 0 {
+			return notFound("product")
+		}
+
+		return enqueueEvent(ctx, events.ProductDeleted, events.ProductDeletedEvent{Product: product})
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return product, nil
 }
 
-func GetAllReviews() ([]bson.M, error) {
-	ctx, cancel := global.GetDefaultTimer()
-	defer cancel()
+// ReviewFilter narrows the review listing. Zero values mean no filter.
+type ReviewFilter struct {
+	ProductID    bson.ObjectID
+	CustomerID   bson.ObjectID
+	Rating       int
+	VerifiedOnly bool
+	SortBy       string // "helpful" or "date"
+	Status       string // moderation status; empty lists only approved reviews
+}
+
+type ReviewsResult struct {
+	Reviews    []models.Review       `json:"reviews"`
+	Pagination global.PaginationInfo `json:"pagination"`
+}
+
+// GetAllReviews returns a filtered, paginated page of reviews.
+// Product and customer filters use the product_id and customer_id indexes.
+func GetAllReviews(ctx context.Context, reviewFilter ReviewFilter, page int, limit int) (*ReviewsResult, error) {
 	collection := GetCollection("reviews")
 
-	cursor, err := collection.Find(ctx, bson.D{})
-	if err != nil {
-		return nil, err
+	filter := bson.D{}
+	if !reviewFilter.ProductID.IsZero() {
+		filter = append(filter, bson.E{Key: "product_id", Value: reviewFilter.ProductID})
+	}
+	if !reviewFilter.CustomerID.IsZero() {
+		filter = append(filter, bson.E{Key: "customer_id", Value: reviewFilter.CustomerID})
+	}
+	if reviewFilter.Rating > 0 {
+		filter = append(filter, bson.E{Key: "rating", Value: reviewFilter.Rating})
+	}
+	if reviewFilter.VerifiedOnly {
+		filter = append(filter, bson.E{Key: "verified_purchase", Value: true})
 	}
-	defer cursor.Close(ctx)
 
-	var items []bson.M
-	if err := cursor.All(ctx, &items); err != nil {
+	// Reviews created before moderation have no status and count as approved
+	switch reviewFilter.Status {
+	case "", "approved":
+		filter = append(filter, bson.E{Key: "moderation_status", Value: bson.D{{Key: "$nin", Value: bson.A{"pending", "flagged"}}}})
+	default:
+		filter = append(filter, bson.E{Key: "moderation_status", Value: reviewFilter.Status})
+	}
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
 		return nil, err
 	}
 
-	return items, nil
-}
+	skip := (page - 1) * limit
 
-func GetAllCartItems() ([]bson.M, error) {
-	ctx, cancel := global.GetDefaultTimer()
-	defer cancel()
-	collection := GetCollection("cart_items")
+	sort := bson.D{{Key: "created_at", Value: -1}}
+	if reviewFilter.SortBy == "helpful" {
+		sort = bson.D{{Key: "helpful_count", Value: -1}, {Key: "created_at", Value: -1}}
+	}
 
-	cursor, err := collection.Find(ctx, bson.D{})
+	findOptions := options.Find().
+		SetSort(sort).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var items []bson.M
-	if err := cursor.All(ctx, &items); err != nil {
+	reviews := []models.Review{}
+	if err := cursor.All(ctx, &reviews); err != nil {
 		return nil, err
 	}
 
-	return items, nil
+	return &ReviewsResult{
+		Reviews:    reviews,
+		Pagination: global.NewPagination(page, limit, int(totalCount)),
+	}, nil
 }
 
-func GetInventoryPagenated() ([]bson.M, error) {
-	ctx, cancel := global.GetDefaultTimer()
+func GetAllCartItems(ctx context.Context) ([]bson.M, error) {
+	ctx, cancel := global.GetRequestTimer(ctx)
 	defer cancel()
-	collection := GetCollection("inventory")
+	collection := GetCollection("cart_items")
 
 	cursor, err := collection.Find(ctx, bson.D{})
 	if err != nil {
@@ -210,9 +425,9 @@ func GetInventoryPagenated() ([]bson.M, error) {
 }
 
 type CustomerOrdersResult struct {
-	Orders     []bson.M           `json:"orders"`
-	Summary    CustomerOrderStats `json:"summary"`
-	Pagination PaginationInfo     `json:"pagination"`
+	Orders     []bson.M              `json:"orders"`
+	Summary    CustomerOrderStats    `json:"summary"`
+	Pagination global.PaginationInfo `json:"pagination"`
 }
 
 type CustomerOrderStats struct {
@@ -220,15 +435,8 @@ type CustomerOrderStats struct {
 	TotalSpent  float64 `json:"total_spent"`
 }
 
-type PaginationInfo struct {
-	Page       int `json:"page"`
-	Limit      int `json:"limit"`
-	TotalPages int `json:"total_pages"`
-	TotalItems int `json:"total_items"`
-}
-
-func GetCustomerOrdersWithStats(customerID bson.ObjectID, page int, limit int) (*CustomerOrdersResult, error) {
-	ctx, cancel := global.GetDefaultTimer()
+func GetCustomerOrdersWithStats(ctx context.Context, customerID bson.ObjectID, page int, limit int) (*CustomerOrdersResult, error) {
+	ctx, cancel := global.GetRequestTimer(ctx)
 	defer cancel()
 	collection := GetCollection("orders")
 
@@ -240,10 +448,6 @@ func GetCustomerOrdersWithStats(customerID bson.ObjectID, page int, limit int) (
 	}
 
 	skip := (page - 1) * limit
-	totalPages := int(totalCount) / limit
-	if int(totalCount)%limit > 0 {
-		totalPages++
-	}
 
 	findOptions := options.Find().
 		SetSort(bson.D{{Key: "created_at", Value: -1}}).
@@ -276,12 +480,7 @@ func GetCustomerOrdersWithStats(customerID bson.ObjectID, page int, limit int) (
 			TotalOrders: int(totalCount),
 			TotalSpent:  totalSpent,
 		},
-		Pagination: PaginationInfo{
-			Page:       page,
-			Limit:      limit,
-			TotalPages: totalPages,
-			TotalItems: int(totalCount),
-		},
+		Pagination: global.NewPagination(page, limit, int(totalCount)),
 	}
 
 	return result, nil
@@ -296,18 +495,25 @@ func CreateCustomer(ctx context.Context, customer *models.Customer) (*models.Cus
 	err := collection.FindOne(ctx, bson.D{{Key: "email", Value: customer.Email}}).Decode(&existingCustomer)
 	if err == nil {
 		// Email already exists
-		return nil, errors.New("email already exists")
+		return nil, duplicate("email")
 	}
 
 	// Insert the customer
-	result, err := collection.InsertOne(ctx, customer)
+	err = inTransaction(ctx, func(ctx context.Context) error {
+		result, err := collection.InsertOne(ctx, customer)
+		if err != nil {
+			return err
+		}
+
+		// Set the generated ID
+		customer.ID = result.InsertedID.(bson.ObjectID)
+
+		return enqueueEvent(ctx, events.CustomerCreated, events.CustomerCreatedEvent{Customer: customer})
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Set the generated ID
-	customer.ID = result.InsertedID.(bson.ObjectID)
-
 	return customer, nil
 }
 
@@ -322,10 +528,7 @@ func GetCustomerByID(ctx context.Context, customerID bson.ObjectID) (*models.Cus
 	var customer models.Customer
 	err := collection.FindOne(ctx, bson.D{{Key: "_id", Value: customerID}}, findOptions).Decode(&customer)
 	if err != nil {
-		if err.Error() == "mongo: no documents in result" {
-			return nil, errors.New("customer not found")
-		}
-		return nil, err
+		return nil, notFoundError(err, "customer")
 	}
 
 	return &customer, nil
@@ -333,8 +536,6 @@ func GetCustomerByID(ctx context.Context, customerID bson.ObjectID) (*models.Cus
 
 // UpdateCustomer updates a customer profile with partial updates
 func UpdateCustomer(ctx context.Context, customerID bson.ObjectID, req *models.UpdateCustomerRequest) (*models.Customer, error) {
-	collection := GetCollection("customers")
-
 	// Build update document with only provided fields
 	updateDoc := bson.D{}
 
@@ -366,63 +567,95 @@ func UpdateCustomer(ctx context.Context, customerID bson.ObjectID, req *models.U
 		return nil, errors.New("no fields to update")
 	}
 
-	update := bson.D{{Key: "$set", Value: updateDoc}}
+	return updateCustomerDocument(ctx, customerID, bson.D{{Key: "$set", Value: updateDoc}})
+}
 
+// updateCustomerDocument applies update to a customer and records a customer.updated
+// event with the result. The password is left out of both.
+func updateCustomerDocument(ctx context.Context, customerID bson.ObjectID, update bson.D) (*models.Customer, error) {
 	// Find and update, returning the updated document
 	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
 	// Exclude password from response
 	findOptions.SetProjection(bson.D{{Key: "password", Value: 0}})
 
 	var updatedCustomer models.Customer
-	err := collection.FindOneAndUpdate(
-		ctx,
-		bson.D{{Key: "_id", Value: customerID}},
-		update,
-		findOptions,
-	).Decode(&updatedCustomer)
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		err := GetCollection("customers").FindOneAndUpdate(
+			ctx,
+			bson.D{{Key: "_id", Value: customerID}},
+			update,
+			findOptions,
+		).Decode(&updatedCustomer)
+		if err != nil {
+			return notFoundError(err, "customer")
+		}
 
+		return enqueueEvent(ctx, events.CustomerUpdated, events.CustomerUpdatedEvent{Customer: &updatedCustomer})
+	})
 	if err != nil {
-		if err.Error() == "mongo: no documents in result" {
-			return nil, errors.New("customer not found")
-		}
 		return nil, err
 	}
 
 	return &updatedCustomer, nil
 }
 
-func AddCustomerAddress(ctx context.Context, customerID bson.ObjectID, address models.Address) (*models.Customer, error) {
-	collection := GetCollection("customers")
+// UpdateCustomerPreferences changes the preferences included in req, leaving the rest
+// of the customer untouched
+func UpdateCustomerPreferences(ctx context.Context, customerID bson.ObjectID, req *models.UpdatePreferencesRequest) (*models.Customer, error) {
+	updateDoc := bson.D{}
 
-	if address.IsDefault {
-		unsetUpdate := bson.D{{Key: "$set", Value: bson.D{{Key: "addresses.$[].is_default", Value: false}}}}
-		_, _ = collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: customerID}}, unsetUpdate)
+	if req.Newsletter != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "preferences.newsletter", Value: *req.Newsletter})
 	}
+	if req.SMSNotifications != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "preferences.sms_notifications", Value: *req.SMSNotifications})
+	}
+	if req.EmailNotifications != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "preferences.email_notifications", Value: *req.EmailNotifications})
+	}
+	if req.Language != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "preferences.language", Value: *req.Language})
+	}
+	if req.Currency != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "preferences.currency", Value: *req.Currency})
+	}
+	if req.FavoriteCategories != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "preferences.favorite_categories", Value: req.FavoriteCategories})
+	}
+
+	if len(updateDoc) == 0 {
+		return nil, errors.New("no fields to update")
+	}
+
+	updateDoc = append(updateDoc, bson.E{Key: "updated_at", Value: time.Now()})
+
+	return updateCustomerDocument(ctx, customerID, bson.D{{Key: "$set", Value: updateDoc}})
+}
+
+func AddCustomerAddress(ctx context.Context, customerID bson.ObjectID, address models.Address) (*models.Customer, error) {
+	collection := GetCollection("customers")
 
 	update := bson.D{
 		{Key: "$push", Value: bson.D{{Key: "addresses", Value: address}}},
 		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
 	}
 
-	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	findOptions.SetProjection(bson.D{{Key: "password", Value: 0}})
-
-	var updatedCustomer models.Customer
-	err := collection.FindOneAndUpdate(
-		ctx,
-		bson.D{{Key: "_id", Value: customerID}},
-		update,
-		findOptions,
-	).Decode(&updatedCustomer)
+	var updatedCustomer *models.Customer
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		if address.IsDefault {
+			unsetUpdate := bson.D{{Key: "$set", Value: bson.D{{Key: "addresses.$[].is_default", Value: false}}}}
+			_, _ = collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: customerID}}, unsetUpdate)
+		}
 
+		var err error
+		updatedCustomer, err = updateCustomerDocument(ctx, customerID, update)
+		return err
+	})
 	if err != nil {
-		if err.Error() == "mongo: no documents in result" {
-			return nil, errors.New("customer not found")
-		}
 		return nil, err
 	}
 
-	return &updatedCustomer, nil
+	return updatedCustomer, nil
 }
 
 func UpdateCustomerAddress(ctx context.Context, customerID bson.ObjectID, addressIndex int, address models.Address) (*models.Customer, error) {
@@ -431,20 +664,11 @@ func UpdateCustomerAddress(ctx context.Context, customerID bson.ObjectID, addres
 	var customer models.Customer
 	err := collection.FindOne(ctx, bson.D{{Key: "_id", Value: customerID}}).Decode(&customer)
 	if err != nil {
-		if err.Error() == "mongo: no documents in result" {
-			return nil, errors.New("customer not found")
-		}
-		return nil, err
+		return nil, notFoundError(err, "customer")
 	}
 
 	if addressIndex < 0 || addressIndex >= len(customer.Addresses) {
-		return nil, errors.New("address not found")
-	}
-
-	// If setting as default, unset all other defaults first
-	if address.IsDefault {
-		unsetUpdate := bson.D{{Key: "$set", Value: bson.D{{Key: "addresses.$[].is_default", Value: false}}}}
-		_, _ = collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: customerID}}, unsetUpdate)
+		return nil, notFound("address")
 	}
 
 	// Update the specific address using array index
@@ -455,22 +679,23 @@ func UpdateCustomerAddress(ctx context.Context, customerID bson.ObjectID, addres
 		}},
 	}
 
-	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	findOptions.SetProjection(bson.D{{Key: "password", Value: 0}})
-
-	var updatedCustomer models.Customer
-	err = collection.FindOneAndUpdate(
-		ctx,
-		bson.D{{Key: "_id", Value: customerID}},
-		update,
-		findOptions,
-	).Decode(&updatedCustomer)
+	var updatedCustomer *models.Customer
+	err = inTransaction(ctx, func(ctx context.Context) error {
+		// If setting as default, unset all other defaults first
+		if address.IsDefault {
+			unsetUpdate := bson.D{{Key: "$set", Value: bson.D{{Key: "addresses.$[].is_default", Value: false}}}}
+			_, _ = collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: customerID}}, unsetUpdate)
+		}
 
+		var err error
+		updatedCustomer, err = updateCustomerDocument(ctx, customerID, update)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &updatedCustomer, nil
+	return updatedCustomer, nil
 }
 
 func DeleteCustomerAddress(ctx context.Context, customerID bson.ObjectID, addressIndex int) (*models.Customer, error) {
@@ -479,18 +704,15 @@ func DeleteCustomerAddress(ctx context.Context, customerID bson.ObjectID, addres
 	var customer models.Customer
 	err := collection.FindOne(ctx, bson.D{{Key: "_id", Value: customerID}}).Decode(&customer)
 	if err != nil {
-		if err.Error() == "mongo: no documents in result" {
-			return nil, errors.New("customer not found")
-		}
-		return nil, err
+		return nil, notFoundError(err, "customer")
 	}
 
 	if addressIndex < 0 || addressIndex >= len(customer.Addresses) {
-		return nil, errors.New("address not found")
+		return nil, notFound("address")
 	}
 
 	if len(customer.Addresses) == 1 {
-		return nil, errors.New("cannot delete last address")
+		return nil, ErrLastAddress
 	}
 
 	wasDefault := customer.Addresses[addressIndex].IsDefault
@@ -508,22 +730,7 @@ func DeleteCustomerAddress(ctx context.Context, customerID bson.ObjectID, addres
 		}},
 	}
 
-	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	findOptions.SetProjection(bson.D{{Key: "password", Value: 0}})
-
-	var updatedCustomer models.Customer
-	err = collection.FindOneAndUpdate(
-		ctx,
-		bson.D{{Key: "_id", Value: customerID}},
-		update,
-		findOptions,
-	).Decode(&updatedCustomer)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &updatedCustomer, nil
+	return updateCustomerDocument(ctx, customerID, update)
 }
 
 // GetOrderByNumber retrieves an order by its order number
@@ -533,30 +740,120 @@ func GetOrderByNumber(ctx context.Context, orderNumber string) (*models.Order, e
 	var order models.Order
 	err := collection.FindOne(ctx, bson.M{"order_number": orderNumber}).Decode(&order)
 	if err != nil {
-		return nil, err
+		return nil, notFoundError(err, "order")
 	}
 
 	return &order, nil
 }
 
-// UpdateOrderByNumber updates an order by its order number with partial updates
+// UpdateOrderByNumber updates an order by its order number with partial updates.
+// Keys may be dotted paths such as shipping_address.city; nil values remove the field.
+// A key outside orderUpdateSchema or a mistyped value returns an *InvalidUpdateError.
 func UpdateOrderByNumber(ctx context.Context, orderNumber string, updates map[string]interface{}) (*models.Order, error) {
 	collection := GetCollection("orders")
 
+	if err := orderUpdateSchema.check(updates); err != nil {
+		return nil, err
+	}
+
 	// Add updated_at timestamp
 	updates["updated_at"] = time.Now()
 
-	// Perform the update
-	filter := bson.M{"order_number": orderNumber}
-	update := bson.M{"$set": updates}
+	var updated *models.Order
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		// A status change stamps the matching timeline date and is emitted as a domain
+		// event for anyone following the order
+		var statusEvent *models.OrderStatusEvent
+		if status, ok := updates["status"].(string); ok {
+			order, err := GetOrderByNumber(ctx, orderNumber)
+			if err != nil {
+				return err
+			}
+
+			// Moving to processing allocates each item to a warehouse and takes the stock,
+			// and cancelling returns whatever was allocated
+			if status == "processing" && order.Status != "processing" && !order.IsAllocated() {
+				items, err := AllocateOrderInventory(ctx, order)
+				if err != nil {
+					return err
+				}
+				updates["items"] = items
+			} else if status == "cancelled" && order.Status != "cancelled" && order.IsAllocated() {
+				items, err := ReleaseOrderInventory(ctx, order)
+				if err != nil {
+					return err
+				}
+				updates["items"] = items
+			}
+
+			if status != order.Status {
+				statusEvent = &models.OrderStatusEvent{OrderNumber: orderNumber, PreviousStatus: order.Status}
+				order.UpdateStatus(status)
+				updates["timeline"] = order.Timeline
+			}
+
+			// Cancelling an order takes it back out of the customer's order stats and
+			// returns its gift card redemption, and reinstating one counts it again
+			if statusEvent != nil && status == "cancelled" {
+				if err := updateCustomerOrderStats(ctx, order, -1); err != nil {
+					return err
+				}
+				if err := refundOrderGiftCard(ctx, order); err != nil {
+					return err
+				}
+			} else if statusEvent != nil && statusEvent.PreviousStatus == "cancelled" {
+				if err := updateCustomerOrderStats(ctx, order, 1); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Perform the update
+		filter := bson.M{"order_number": orderNumber}
 
-	_, err := collection.UpdateOne(ctx, filter, update)
+		_, err := collection.UpdateOne(ctx, filter, patchPipeline(updates))
+		if err != nil {
+			return err
+		}
+
+		// Return the updated order
+		updated, err = GetOrderByNumber(ctx, orderNumber)
+		if err != nil {
+			return err
+		}
+		if statusEvent != nil {
+			statusEvent.Status = updated.Status
+			statusEvent.Timeline = updated.Timeline
+			statusEvent.Tracking = updated.Tracking
+			if err := enqueueEvent(ctx, events.OrderStatusChanged, statusEvent); err != nil {
+				return err
+			}
+		}
+		return enqueueEvent(ctx, events.OrderUpdated, events.OrderUpdatedEvent{Order: updated})
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Return the updated order
-	return GetOrderByNumber(ctx, orderNumber)
+	return updated, nil
+}
+
+// updateCustomerOrderStats adds an order to its customer's order count and spend, or
+// takes it back out when sign is -1. The last order date only ever moves forward.
+func updateCustomerOrderStats(ctx context.Context, order *models.Order, sign int) error {
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{
+			{Key: "total_orders", Value: sign},
+			{Key: "total_spent", Value: float64(sign) * order.Totals.GrandTotal},
+		}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+	}
+	if sign > 0 {
+		update = append(update, bson.E{Key: "$max", Value: bson.D{{Key: "last_order_date", Value: order.Timeline.OrderedAt}}})
+	}
+
+	_, err := GetCollection("customers").UpdateOne(ctx, bson.D{{Key: "_id", Value: order.CustomerID}}, update)
+	return err
 }
 
 // DeleteOrderByNumber deletes an order by its order number
@@ -570,22 +867,93 @@ func DeleteOrderByNumber(ctx context.Context, orderNumber string) (*models.Order
 	}
 
 	// Then delete it
-	filter := bson.M{"order_number": orderNumber}
-	result, err := collection.DeleteOne(ctx, filter)
+	err = inTransaction(ctx, func(ctx context.Context) error {
+		filter := bson.M{"order_number": orderNumber}
+		result, err := collection.DeleteOne(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		if result.DeletedCount == 0 {
+			return notFound("order")
+		}
+
+		return enqueueEvent(ctx, events.OrderDeleted, events.OrderDeletedEvent{Order: order})
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if result.DeletedCount == 0 {
-		return nil, errors.New("order not found")
+	return order, nil
+}
+
+// priceOrderItems fills an order's items from the catalog, so the product, name, unit
+// price and weight are the catalog's rather than whatever the client sent, and returns
+// their subtotal. An item whose SKU doesn't exist or isn't active is rejected.
+func priceOrderItems(ctx context.Context, items []models.OrderItem) (float64, error) {
+	skus := make([]string, len(items))
+	for i, item := range items {
+		skus[i] = item.SKU
+	}
+	products, err := GetProductsBySKUs(ctx, skus)
+	if err != nil {
+		return 0, err
+	}
+
+	var subtotal float64
+	for i := range items {
+		product, ok := products[items[i].SKU]
+		if !ok {
+			return 0, notFound("product '" + items[i].SKU + "'")
+		}
+		if product.Status != "active" {
+			return 0, fmt.Errorf("product '%s' %w", items[i].SKU, ErrProductUnavailable)
+		}
+		items[i].ProductID = product.ID
+		items[i].Name = product.Name
+		items[i].UnitPrice = product.Price
+		items[i].WeightKg = product.WeightKg
+		items[i].CalculateItemSubtotal()
+		subtotal += items[i].Subtotal
+	}
+	return subtotal, nil
+}
+
+// quoteOrderShipping weighs an order's items, as priced by priceOrderItems, and records
+// the rate for shipping them by method to its address
+func quoteOrderShipping(ctx context.Context, order *models.Order, method string) error {
+	var weight float64
+	for _, item := range order.Items {
+		weight += float64(item.Quantity) * shipping.ItemWeight(item.WeightKg)
 	}
 
-	return order, nil
+	if method == "" {
+		method = models.ShippingStandard
+	}
+	rates := shipping.Quote(ctx, shipping.Shipment{
+		Province:   order.ShippingAddress.Province,
+		PostalCode: order.ShippingAddress.PostalCode,
+		Country:    order.ShippingAddress.Country,
+		WeightKg:   weight,
+	})
+	rate, ok := models.FindShippingRate(rates, method)
+	if !ok {
+		return fmt.Errorf("no %s shipping rate for this address", method)
+	}
+	order.ShippingRate = &rate
+	return nil
 }
 
 // CreateNewOrder creates a new order in the database
 func CreateNewOrder(ctx context.Context, orderRequest *models.CreateOrderRequest) (*models.Order, error) {
-	collection := GetCollection("orders")
+	// The customer is optional here: it only sets the loyalty tier and saved addresses
+	customer, _ := GetCustomerByID(ctx, orderRequest.CustomerID)
+
+	// Saved addresses are looked up on the customer
+	shippingAddress, billingAddress, err := orderRequest.ResolveAddresses(customer)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create the order from the request
 	order := &models.Order{
@@ -594,17 +962,37 @@ func CreateNewOrder(ctx context.Context, orderRequest *models.CreateOrderRequest
 		CustomerEmail:   orderRequest.CustomerEmail,
 		Status:          "pending",
 		Items:           orderRequest.Items,
-		ShippingAddress: orderRequest.ShippingAddress,
-		BillingAddress:  orderRequest.BillingAddress,
-		Payment:         orderRequest.Payment,
+		ShippingAddress: shippingAddress,
+		BillingAddress:  billingAddress,
+		Payment:         models.Payment{Method: orderRequest.Payment.Method, Status: "pending"},
 		Notes:           orderRequest.Notes,
 		CreatedAt:       time.Now(),
 		UpdatedAt:       time.Now(),
 	}
 
-	// Calculate item subtotals
-	for i := range order.Items {
-		order.Items[i].CalculateItemSubtotal()
+	// Lock in the customer's loyalty tier for the tier benefits
+	if customer != nil {
+		order.LoyaltyTier = customer.CalculateLoyaltyTier()
+	}
+
+	// Price the items from the catalog
+	itemsSubtotal, err := priceOrderItems(ctx, order.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply coupon discount if a code was provided
+	if orderRequest.CouponCode != "" {
+		coupon, err := ValidateCoupon(ctx, orderRequest.CouponCode, itemsSubtotal, orderRequest.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		order.CouponCode = coupon.Code
+		order.Totals.Discount = coupon.CalculateDiscount(itemsSubtotal)
+	}
+
+	if err := quoteOrderShipping(ctx, order, orderRequest.ShippingMethod); err != nil {
+		return nil, err
 	}
 
 	// Calculate order totals
@@ -613,21 +1001,64 @@ func CreateNewOrder(ctx context.Context, orderRequest *models.CreateOrderRequest
 	// Set timeline
 	order.Timeline.OrderedAt = time.Now()
 
-	// Insert into database
-	result, err := collection.InsertOne(ctx, order)
+	// Insert into database along with its gift card and coupon redemptions and
+	// order.created event
+	err = inTransaction(ctx, func(ctx context.Context) error {
+		if err := redeemOrderGiftCard(ctx, order, orderRequest.GiftCardCode, orderRequest.GiftCardAmount); err != nil {
+			return err
+		}
+		return insertOrder(ctx, order)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Set the generated ID
-	order.ID = result.InsertedID.(bson.ObjectID)
-
 	return order, nil
 }
 
+// redeemOrderGiftCard redeems a gift card against an order's grand total, if a code was
+// given, and recalculates the totals. It starts from the totals without a gift card so
+// a retried transaction redeems the same amount again rather than on top.
+func redeemOrderGiftCard(ctx context.Context, order *models.Order, code string, requested float64) error {
+	order.GiftCardCode = ""
+	order.Totals.GiftCard = 0
+	order.CalculateTotals()
+	if code == "" {
+		return nil
+	}
+
+	redeemed, err := RedeemGiftCard(ctx, code, requested, order.Totals.GrandTotal, order.OrderNumber)
+	if err != nil {
+		return err
+	}
+	order.GiftCardCode = strings.ToUpper(strings.TrimSpace(code))
+	order.Totals.GiftCard = redeemed
+	order.CalculateTotals()
+	return nil
+}
+
+// insertOrder stores a new order, counts it in its customer's stats, redeems its coupon
+// and records its order.created event. Run it inside inTransaction: the coupon's usage
+// limits are only enforced at write time, and exceeding one rolls the order back.
+func insertOrder(ctx context.Context, order *models.Order) error {
+	result, err := GetCollection("orders").InsertOne(ctx, order)
+	if err != nil {
+		return err
+	}
+	order.ID = result.InsertedID.(bson.ObjectID)
+	if err := updateCustomerOrderStats(ctx, order, 1); err != nil {
+		return err
+	}
+	if order.CouponCode != "" {
+		if err := RedeemCoupon(ctx, order.CouponCode, order.CustomerID, order.OrderNumber, order.Totals.Discount); err != nil {
+			return err
+		}
+	}
+	return enqueueEvent(ctx, events.OrderCreated, events.OrderCreatedEvent{Order: order})
+}
+
 // CreateNewOrders creates multiple orders in a single operation
 func CreateNewOrders(ctx context.Context, orderRequests []models.CreateOrderRequest) ([]models.Order, []error) {
-	collection := GetCollection("orders")
 	customersCollection := GetCollection("customers")
 
 	var orders []models.Order
@@ -640,8 +1071,8 @@ func CreateNewOrders(ctx context.Context, orderRequests []models.CreateOrderRequ
 		var customer models.Customer
 		err := customersCollection.FindOne(ctx, bson.D{{Key: "email", Value: orderRequest.CustomerEmail}}).Decode(&customer)
 		if err != nil {
-			if err.Error() == "mongo: no documents in result" {
-				errorsList = append(errorsList, errors.New("customer with email '"+orderRequest.CustomerEmail+"' not found"))
+			if isNoDocuments(err) {
+				errorsList = append(errorsList, fmt.Errorf("customer with email '%s' %w", orderRequest.CustomerEmail, ErrNotFound))
 			} else {
 				errorsList = append(errorsList, err)
 			}
@@ -658,6 +1089,15 @@ func CreateNewOrders(ctx context.Context, orderRequests []models.CreateOrderRequ
 			continue
 		}
 
+		// Saved addresses are looked up on the verified customer
+		shippingAddress, billingAddress, err := orderRequest.ResolveAddresses(&customer)
+		if err != nil {
+			errorsList = append(errorsList, err)
+			// Add a placeholder order to maintain index alignment
+			orders = append(orders, models.Order{})
+			continue
+		}
+
 		// Create the order
 		order := models.Order{
 			OrderNumber:     models.GenerateOrderNumber(),
@@ -665,17 +1105,42 @@ func CreateNewOrders(ctx context.Context, orderRequests []models.CreateOrderRequ
 			CustomerEmail:   customer.Email, // Use the verified customer email
 			Status:          "pending",
 			Items:           orderRequest.Items,
-			ShippingAddress: orderRequest.ShippingAddress,
-			BillingAddress:  orderRequest.BillingAddress,
-			Payment:         orderRequest.Payment,
+			ShippingAddress: shippingAddress,
+			BillingAddress:  billingAddress,
+			Payment:         models.Payment{Method: orderRequest.Payment.Method, Status: "pending"},
 			Notes:           orderRequest.Notes,
+			LoyaltyTier:     customer.CalculateLoyaltyTier(),
 			CreatedAt:       time.Now(),
 			UpdatedAt:       time.Now(),
 		}
 
-		// Calculate item subtotals
-		for j := range order.Items {
-			order.Items[j].CalculateItemSubtotal()
+		// Price the items from the catalog
+		itemsSubtotal, err := priceOrderItems(ctx, order.Items)
+		if err != nil {
+			errorsList = append(errorsList, err)
+			// Add a placeholder order to maintain index alignment
+			orders = append(orders, models.Order{})
+			continue
+		}
+
+		// Apply coupon discount if a code was provided
+		if orderRequest.CouponCode != "" {
+			coupon, err := ValidateCoupon(ctx, orderRequest.CouponCode, itemsSubtotal, customer.ID)
+			if err != nil {
+				errorsList = append(errorsList, errors.New("coupon '"+orderRequest.CouponCode+"' rejected: "+err.Error()))
+				// Add a placeholder order to maintain index alignment
+				orders = append(orders, models.Order{})
+				continue
+			}
+			order.CouponCode = coupon.Code
+			order.Totals.Discount = coupon.CalculateDiscount(itemsSubtotal)
+		}
+
+		if err := quoteOrderShipping(ctx, &order, orderRequest.ShippingMethod); err != nil {
+			errorsList = append(errorsList, errors.New("shipping could not be quoted: "+err.Error()))
+			// Add a placeholder order to maintain index alignment
+			orders = append(orders, models.Order{})
+			continue
 		}
 
 		// Calculate order totals
@@ -689,70 +1154,59 @@ func CreateNewOrders(ctx context.Context, orderRequests []models.CreateOrderRequ
 		errorsList = append(errorsList, nil) // No error for this order
 	}
 
-	// Insert valid orders only
-	if len(ordersToInsert) > 0 {
-		result, err := collection.InsertMany(ctx, ordersToInsert)
-		if err != nil {
-			// If bulk insert fails, mark all valid orders as failed
-			for i := 0; i < len(orders); i++ {
-				if errorsList[i] == nil { // This was a valid order that should have been inserted
-					errorsList[i] = err
-				}
-			}
-			return orders, errorsList
+	// Insert each valid order in its own transaction with its gift card and coupon
+	// redemptions and order.created event, so an order that can't be paid for or is over
+	// a coupon's limit fails on its own without leaving anything redeemed
+	for i, orderRequest := range orderRequests {
+		if errorsList[i] != nil {
+			continue
 		}
-
-		// Set the generated IDs for successfully inserted orders
-		insertIndex := 0
-		for i := 0; i < len(orders); i++ {
-			if errorsList[i] == nil { // This order was successfully processed
-				if insertIndex < len(result.InsertedIDs) {
-					orders[i].ID = result.InsertedIDs[insertIndex].(bson.ObjectID)
-					insertIndex++
-				}
+		order := &orders[i]
+		err := inTransaction(ctx, func(ctx context.Context) error {
+			if err := redeemOrderGiftCard(ctx, order, orderRequest.GiftCardCode, orderRequest.GiftCardAmount); err != nil {
+				return fmt.Errorf("gift card '%s' rejected: %w", orderRequest.GiftCardCode, err)
 			}
+			return insertOrder(ctx, order)
+		})
+		if err != nil {
+			if order.CouponCode != "" && isCouponLimitError(err) {
+				err = errors.New("coupon '" + order.CouponCode + "' rejected: " + err.Error())
+			}
+			errorsList[i] = err
 		}
 	}
 
 	return orders, errorsList
 }
 
-// GetAllCategories retrieves distinct category values from the products collection
-func GetAllCategories() ([]string, error) {
-	ctx, cancel := global.GetDefaultTimer()
+// GetAllCategories retrieves distinct category values from the products collection in
+// the request's alphabetical order. Categories differing only in case are one category.
+func GetAllCategories(ctx context.Context) ([]string, error) {
+	ctx, cancel := global.GetRequestTimer(ctx)
 	defer cancel()
 	collection := GetCollection("products")
 
-	// Use MongoDB aggregation to get distinct categories (case insensitive)
+	// The collation groups and sorts categories ignoring case
 	pipeline := []bson.M{
 		{
 			"$group": bson.M{
-				"_id": bson.M{
-					"$toLower": "$category",
-				},
-				"originalCase": bson.M{
-					"$first": "$category",
-				},
-				"count": bson.M{
-					"$sum": 1,
-				},
+				"_id": "$category",
 			},
 		},
 		{
 			"$sort": bson.M{
-				"originalCase": 1,
+				"_id": 1,
 			},
 		},
 		{
 			"$project": bson.M{
 				"_id":      0,
-				"category": "$originalCase",
-				"count":    1,
+				"category": "$_id",
 			},
 		},
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Aggregate(ctx, pipeline, options.Aggregate().SetCollation(collation(ctx)))
 	if err != nil {
 		return nil, err
 	}
@@ -774,10 +1228,10 @@ func GetAllCategories() ([]string, error) {
 	return categories, nil
 }
 
-func GetAllReviewsForItem(entity string, entityId string) ([]models.Review, error) {
+func GetAllReviewsForItem(ctx context.Context, entity string, entityId string) ([]models.Review, error) {
 	var reviews []models.Review
 
-	ctx, cancel := global.GetDefaultTimer()
+	ctx, cancel := global.GetRequestTimer(ctx)
 	defer cancel()
 
 	collection := GetCollection("reviews")
@@ -792,7 +1246,7 @@ func GetAllReviewsForItem(entity string, entityId string) ([]models.Review, erro
 	var filter bson.M
 	switch entity {
 	case "product":
-		filter = bson.M{"product_id": objId}
+		filter = bson.M{"product_id": objId, "moderation_status": bson.M{"$nin": bson.A{"pending", "flagged"}}}
 	case "customer":
 		filter = bson.M{"customer_id": objId}
 	case "order":
@@ -815,8 +1269,8 @@ func GetAllReviewsForItem(entity string, entityId string) ([]models.Review, erro
 }
 
 // CreateReviewForItem creates a new review in the database
-func CreateReviewForItem(reviewRequest *models.CreateReviewRequest) (*models.Review, error) {
-	ctx, cancel := global.GetDefaultTimer()
+func CreateReviewForItem(ctx context.Context, reviewRequest *models.CreateReviewRequest) (*models.Review, error) {
+	ctx, cancel := global.GetRequestTimer(ctx)
 	defer cancel()
 
 	collection := GetCollection("reviews")
@@ -826,10 +1280,7 @@ func CreateReviewForItem(reviewRequest *models.CreateReviewRequest) (*models.Rev
 	var product models.Product
 	err := productCollection.FindOne(ctx, bson.M{"_id": reviewRequest.ProductID}).Decode(&product)
 	if err != nil {
-		if err.Error() == "mongo: no documents in result" {
-			return nil, errors.New("product not found")
-		}
-		return nil, err
+		return nil, notFoundError(err, "product")
 	}
 
 	// Validate that the customer exists
@@ -837,10 +1288,7 @@ func CreateReviewForItem(reviewRequest *models.CreateReviewRequest) (*models.Rev
 	var customer models.Customer
 	err = customersCollection.FindOne(ctx, bson.M{"_id": reviewRequest.CustomerID}).Decode(&customer)
 	if err != nil {
-		if err.Error() == "mongo: no documents in result" {
-			return nil, errors.New("customer not found")
-		}
-		return nil, err
+		return nil, notFoundError(err, "customer")
 	}
 
 	// If order ID is provided, validate it exists and belongs to the customer
@@ -852,8 +1300,8 @@ func CreateReviewForItem(reviewRequest *models.CreateReviewRequest) (*models.Rev
 			"customer_id": reviewRequest.CustomerID,
 		}).Decode(&order)
 		if err != nil {
-			if err.Error() == "mongo: no documents in result" {
-				return nil, errors.New("order not found or does not belong to customer")
+			if isNoDocuments(err) {
+				return nil, fmt.Errorf("order %w or does not belong to customer", ErrNotFound)
 			}
 			return nil, err
 		}
@@ -867,7 +1315,7 @@ func CreateReviewForItem(reviewRequest *models.CreateReviewRequest) (*models.Rev
 			}
 		}
 		if !productInOrder {
-			return nil, errors.New("product not found in the specified order")
+			return nil, fmt.Errorf("product %w in the specified order", ErrNotFound)
 		}
 	}
 
@@ -880,6 +1328,16 @@ func CreateReviewForItem(reviewRequest *models.CreateReviewRequest) (*models.Rev
 		return nil, errors.New("customer has already reviewed this product")
 	}
 
+	// Verified purchase is decided server-side: the customer must have a delivered order containing the product
+	deliveredOrders, err := GetCollection("orders").CountDocuments(ctx, bson.M{
+		"customer_id":      reviewRequest.CustomerID,
+		"status":           "delivered",
+		"items.product_id": reviewRequest.ProductID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// Create the review
 	review := &models.Review{
 		ProductID:        reviewRequest.ProductID,
@@ -888,27 +1346,35 @@ func CreateReviewForItem(reviewRequest *models.CreateReviewRequest) (*models.Rev
 		Rating:           reviewRequest.Rating,
 		Title:            reviewRequest.Title,
 		Comment:          reviewRequest.Comment,
-		VerifiedPurchase: reviewRequest.VerifiedPurchase,
+		VerifiedPurchase: deliveredOrders > 0,
+		ModerationStatus: "approved",
 		HelpfulCount:     0,
 		CreatedAt:        time.Now(),
 		UpdatedAt:        time.Now(),
 	}
 
 	// Insert into database
-	result, err := collection.InsertOne(ctx, review)
+	err = inTransaction(ctx, func(ctx context.Context) error {
+		result, err := collection.InsertOne(ctx, review)
+		if err != nil {
+			return err
+		}
+
+		// Set the generated ID
+		review.ID = result.InsertedID.(bson.ObjectID)
+
+		return enqueueEvent(ctx, events.ReviewCreated, events.ReviewCreatedEvent{Review: review})
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Set the generated ID
-	review.ID = result.InsertedID.(bson.ObjectID)
-
 	return review, nil
 }
 
 // UpdateReviewForItem updates an existing review with partial updates
-func UpdateReviewForItem(reviewID string, productID string, updateRequest *models.UpdateReviewRequest) (*models.Review, error) {
-	ctx, cancel := global.GetDefaultTimer()
+func UpdateReviewForItem(ctx context.Context, reviewID string, productID string, updateRequest *models.UpdateReviewRequest) (*models.Review, error) {
+	ctx, cancel := global.GetRequestTimer(ctx)
 	defer cancel()
 
 	collection := GetCollection("reviews")
@@ -947,18 +1413,25 @@ func UpdateReviewForItem(reviewID string, productID string, updateRequest *model
 	}
 
 	updateDoc := bson.M{"$set": updates}
-	result, err := collection.UpdateOne(ctx, filter, updateDoc)
-	if err != nil {
-		return nil, err
-	}
+	var updatedReview models.Review
+	err = inTransaction(ctx, func(ctx context.Context) error {
+		result, err := collection.UpdateOne(ctx, filter, updateDoc)
+		if err != nil {
+			return err
+		}
 
-	if result.MatchedCount == 0 {
-		return nil, errors.New("review not found for this product")
-	}
+		if result.MatchedCount == 0 {
+			return fmt.Errorf("review %w for this product", ErrNotFound)
+		}
 
-	// Return the updated review
-	var updatedReview models.Review
-	err = collection.FindOne(ctx, bson.M{"_id": reviewObjID}).Decode(&updatedReview)
+		// Return the updated review
+		err = collection.FindOne(ctx, bson.M{"_id": reviewObjID}).Decode(&updatedReview)
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(ctx, events.ReviewUpdated, events.ReviewUpdatedEvent{Review: &updatedReview})
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -967,8 +1440,8 @@ func UpdateReviewForItem(reviewID string, productID string, updateRequest *model
 }
 
 // DeleteReviewForItem deletes a review by ID for a specific product
-func DeleteReviewForItem(reviewID string, productID string) (string, error) {
-	ctx, cancel := global.GetDefaultTimer()
+func DeleteReviewForItem(ctx context.Context, reviewID string, productID string) (string, error) {
+	ctx, cancel := global.GetRequestTimer(ctx)
 	defer cancel()
 
 	collection := GetCollection("reviews")
@@ -990,15 +1463,22 @@ func DeleteReviewForItem(reviewID string, productID string) (string, error) {
 		"product_id": productObjID,
 	}
 
-	result, err := collection.DeleteOne(ctx, filter)
+	err = inTransaction(ctx, func(ctx context.Context) error {
+		result, err := collection.DeleteOne(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		if result.DeletedCount == 0 {
+			return fmt.Errorf("review %w for this product", ErrNotFound)
+		}
+
+		return enqueueEvent(ctx, events.ReviewDeleted, events.ReviewDeletedEvent{ReviewID: reviewID, ProductID: productID})
+	})
 	if err != nil {
 		return "", err
 	}
 
-	if result.DeletedCount == 0 {
-		return "", errors.New("review not found for this product")
-	}
-
 	return reviewID, nil
 }
 
@@ -1009,7 +1489,9 @@ type SearchResult struct {
 	Title   string      `json:"title"`
 	Snippet string      `json:"snippet"`
 	Score   float64     `json:"score,omitempty"`
-	Data    interface{} `json:"data"`
+	// Highlights are the matching fragments with hits in <em> tags (Atlas Search only)
+	Highlights []string    `json:"highlights,omitempty"`
+	Data       interface{} `json:"data"`
 }
 
 // SearchResults represents grouped search results by collection type
@@ -1019,11 +1501,72 @@ type SearchResults struct {
 	Orders    []SearchResult `json:"orders"`
 	Reviews   []SearchResult `json:"reviews"`
 	Total     int            `json:"total"`
+	// Matches counts every match of each searched type, across all pages
+	Matches map[string]int `json:"matches"`
+	// Facets counts the matching products for filter sidebars
+	Facets *SearchFacets `json:"facets,omitempty"`
+}
+
+// Search result types, which are also the values of the types parameter
+const (
+	SearchTypeProducts  = "products"
+	SearchTypeCustomers = "customers"
+	SearchTypeOrders    = "orders"
+	SearchTypeReviews   = "reviews"
+)
+
+// SearchTypes lists every searchable type in response order
+var SearchTypes = []string{SearchTypeProducts, SearchTypeCustomers, SearchTypeOrders, SearchTypeReviews}
+
+// SearchRequest is one page of a search
+type SearchRequest struct {
+	Query string
+	Page  int
+	// Limit is the page size for each type
+	Limit int
+	// Types are the types to search; empty means every type, or only products when a
+	// product filter is set
+	Types   []string
+	Filters models.ProductFilters
+	// Fuzziness is how many typos Atlas Search tolerates per term; 0 matches exactly
+	Fuzziness int
+	// Alternatives are likely spellings of misspelled terms, which text index
+	// searches look for alongside Query
+	Alternatives []string
+}
+
+// SearchedTypes returns the types req searches
+func (req SearchRequest) SearchedTypes() []string {
+	switch {
+	case len(req.Types) > 0:
+		return req.Types
+	case !req.Filters.IsEmpty():
+		return []string{SearchTypeProducts}
+	default:
+		return SearchTypes
+	}
+}
+
+// textQuery is the $text search string: Query plus any alternative spellings, which
+// $text matches as optional terms
+func (req SearchRequest) textQuery() string {
+	if len(req.Alternatives) == 0 {
+		return req.Query
+	}
+	return req.Query + " " + strings.Join(req.Alternatives, " ")
+}
+
+func (req SearchRequest) skip() int {
+	if req.Page < 1 {
+		return 0
+	}
+	return (req.Page - 1) * req.Limit
 }
 
-// SearchDatabase performs full-text search across all collections
-func SearchDatabase(query string, limit int) (*SearchResults, error) {
-	ctx, cancel := global.GetDefaultTimer()
+// SearchDatabase performs full-text search across the requested collections, returning
+// one page of each. Product filters narrow the product results and facets.
+func SearchDatabase(ctx context.Context, req SearchRequest) (*SearchResults, error) {
+	ctx, cancel := global.GetRequestTimer(ctx)
 	defer cancel()
 
 	results := &SearchResults{
@@ -1031,201 +1574,207 @@ func SearchDatabase(query string, limit int) (*SearchResults, error) {
 		Customers: []SearchResult{},
 		Orders:    []SearchResult{},
 		Reviews:   []SearchResult{},
+		Matches:   map[string]int{},
 	}
 
-	// Search Products
-	productsCollection := GetCollection("products")
-	productResults, err := searchProducts(ctx, productsCollection, query, limit)
-	if err == nil {
-		results.Products = productResults
+	searchers := map[string]struct {
+		collection string
+		search     func(context.Context, *mongo.Collection, SearchRequest) ([]SearchResult, int, error)
+		results    *[]SearchResult
+	}{
+		SearchTypeProducts:  {"products", searchProducts, &results.Products},
+		SearchTypeCustomers: {"customers", searchCustomers, &results.Customers},
+		SearchTypeOrders:    {"orders", searchOrders, &results.Orders},
+		SearchTypeReviews:   {"reviews", searchReviews, &results.Reviews},
 	}
 
-	// Search Customers
-	customersCollection := GetCollection("customers")
-	customerResults, err := searchCustomers(ctx, customersCollection, query, limit)
-	if err == nil {
-		results.Customers = customerResults
-	}
-
-	// Search Orders
-	ordersCollection := GetCollection("orders")
-	orderResults, err := searchOrders(ctx, ordersCollection, query, limit)
-	if err == nil {
-		results.Orders = orderResults
-	}
+	for _, searchType := range req.SearchedTypes() {
+		searcher, ok := searchers[searchType]
+		if !ok {
+			continue
+		}
+		found, matches, err := searcher.search(ctx, GetCollection(searcher.collection), req)
+		if err != nil {
+			slog.WarnContext(ctx, "Error searching collection", "collection", searcher.collection, "error", err)
+			continue
+		}
+		if found != nil {
+			*searcher.results = found
+		}
+		results.Matches[searchType] = matches
+		results.Total += len(found)
 
-	// Search Reviews
-	reviewsCollection := GetCollection("reviews")
-	reviewResults, err := searchReviews(ctx, reviewsCollection, query, limit)
-	if err == nil {
-		results.Reviews = reviewResults
+		if searchType == SearchTypeProducts {
+			facets, err := searchProductFacets(ctx, req)
+			if err != nil {
+				slog.WarnContext(ctx, "Error counting search facets", "error", err)
+			} else {
+				results.Facets = facets
+			}
+		}
 	}
 
-	results.Total = len(results.Products) + len(results.Customers) + len(results.Orders) + len(results.Reviews)
-
 	return results, nil
 }
 
-func searchProducts(ctx context.Context, collection *mongo.Collection, query string, limit int) ([]SearchResult, error) {
-	var products []models.Product
-	var results []SearchResult
+// productSearchFields are the product fields search looks in
+var productSearchFields = []string{"name", "description", "category", "tags", "sku"}
 
-	// Use regex search for name, description, category, tags
-	filter := bson.M{
-		"$or": []bson.M{
-			{"name": bson.M{"$regex": query, "$options": "i"}},
-			{"description": bson.M{"$regex": query, "$options": "i"}},
-			{"category": bson.M{"$regex": query, "$options": "i"}},
-			{"tags": bson.M{"$regex": query, "$options": "i"}},
-			{"sku": bson.M{"$regex": query, "$options": "i"}},
-		},
-	}
+func searchProducts(ctx context.Context, collection *mongo.Collection, req SearchRequest) ([]SearchResult, int, error) {
+	var results []SearchResult
 
-	cursor, err := collection.Find(ctx, filter, options.Find().SetLimit(int64(limit)))
+	matches, total, err := findMatches(ctx, collection, req, productFilterCondition(req.Filters), productSearchFields, nil)
 	if err != nil {
-		return results, err
-	}
-	defer cursor.Close(ctx)
-
-	if err := cursor.All(ctx, &products); err != nil {
-		return results, err
+		return results, 0, err
 	}
 
-	for _, product := range products {
+	for _, match := range matches {
+		var product models.Product
+		if err := bson.Unmarshal(match.Document, &product); err != nil {
+			return results, 0, err
+		}
 		snippet := product.Description
 		if len(snippet) > 150 {
 			snippet = snippet[:150] + "..."
 		}
 
 		results = append(results, SearchResult{
-			ID:      product.ID,
-			Type:    "product",
-			Title:   product.Name,
-			Snippet: snippet,
-			Data:    product,
+			ID:         product.ID,
+			Type:       "product",
+			Title:      product.Name,
+			Snippet:    snippet,
+			Score:      match.Score,
+			Highlights: match.Highlights,
+			Data:       product,
 		})
 	}
 
-	return results, nil
+	return results, total, nil
 }
 
-func searchCustomers(ctx context.Context, collection *mongo.Collection, query string, limit int) ([]SearchResult, error) {
-	var customers []models.Customer
-	var results []SearchResult
+// CustomerSearchData is a customer as search results show it. Credentials, phone
+// numbers, addresses and preferences are never read for search.
+type CustomerSearchData struct {
+	ID            bson.ObjectID `json:"id" bson:"_id"`
+	Email         string        `json:"email" bson:"email"`
+	FirstName     string        `json:"first_name" bson:"first_name"`
+	LastName      string        `json:"last_name" bson:"last_name"`
+	AccountStatus string        `json:"account_status" bson:"account_status"`
+	TotalOrders   int           `json:"total_orders" bson:"total_orders"`
+	TotalSpent    float64       `json:"total_spent" bson:"total_spent"`
+	LastOrderDate time.Time     `json:"last_order_date,omitempty" bson:"last_order_date,omitempty"`
+	CreatedAt     time.Time     `json:"created_at" bson:"created_at"`
+}
 
-	// Search customer name and email
-	filter := bson.M{
-		"$or": []bson.M{
-			{"first_name": bson.M{"$regex": query, "$options": "i"}},
-			{"last_name": bson.M{"$regex": query, "$options": "i"}},
-			{"email": bson.M{"$regex": query, "$options": "i"}},
-			{"phone": bson.M{"$regex": query, "$options": "i"}},
-		},
-	}
+// customerResultFields are the CustomerSearchData fields read from the database
+var customerResultFields = []string{"email", "first_name", "last_name", "account_status", "total_orders", "total_spent", "last_order_date", "created_at"}
 
-	cursor, err := collection.Find(ctx, filter, options.Find().SetLimit(int64(limit)))
-	if err != nil {
-		return results, err
-	}
-	defer cursor.Close(ctx)
+func searchCustomers(ctx context.Context, collection *mongo.Collection, req SearchRequest) ([]SearchResult, int, error) {
+	var results []SearchResult
 
-	if err := cursor.All(ctx, &customers); err != nil {
-		return results, err
+	matches, total, err := findMatches(ctx, collection, req, nil, []string{"first_name", "last_name", "email", "phone"}, customerResultFields)
+	if err != nil {
+		return results, 0, err
 	}
 
-	for _, customer := range customers {
+	for _, match := range matches {
+		var customer CustomerSearchData
+		if err := bson.Unmarshal(match.Document, &customer); err != nil {
+			return results, 0, err
+		}
 		name := customer.FirstName + " " + customer.LastName
-		snippet := fmt.Sprintf("Email: %s | Phone: %s", customer.Email, customer.Phone)
+		snippet := fmt.Sprintf("Email: %s | Orders: %d", customer.Email, customer.TotalOrders)
 
 		results = append(results, SearchResult{
-			ID:      customer.ID,
-			Type:    "customer",
-			Title:   name,
-			Snippet: snippet,
-			Data:    customer,
+			ID:         customer.ID,
+			Type:       "customer",
+			Title:      name,
+			Snippet:    snippet,
+			Score:      match.Score,
+			Highlights: match.Highlights,
+			Data:       customer,
 		})
 	}
 
-	return results, nil
+	return results, total, nil
 }
 
-func searchOrders(ctx context.Context, collection *mongo.Collection, query string, limit int) ([]SearchResult, error) {
-	var orders []models.Order
-	var results []SearchResult
+// OrderSearchData is an order as search results show it. Addresses, payment details,
+// gift card codes and notes are never read for search.
+type OrderSearchData struct {
+	ID            bson.ObjectID      `json:"id" bson:"_id"`
+	OrderNumber   string             `json:"order_number" bson:"order_number"`
+	CustomerID    bson.ObjectID      `json:"customer_id" bson:"customer_id"`
+	CustomerEmail string             `json:"customer_email" bson:"customer_email"`
+	Status        string             `json:"status" bson:"status"`
+	Items         []models.OrderItem `json:"items" bson:"items"`
+	Totals        models.OrderTotals `json:"totals" bson:"totals"`
+	Timeline      models.Timeline    `json:"timeline" bson:"timeline"`
+	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
+}
 
-	// Search order number, customer email, status
-	filter := bson.M{
-		"$or": []bson.M{
-			{"order_number": bson.M{"$regex": query, "$options": "i"}},
-			{"customer_email": bson.M{"$regex": query, "$options": "i"}},
-			{"status": bson.M{"$regex": query, "$options": "i"}},
-			{"notes": bson.M{"$regex": query, "$options": "i"}},
-		},
-	}
+// orderResultFields are the OrderSearchData fields read from the database
+var orderResultFields = []string{"order_number", "customer_id", "customer_email", "status", "items", "totals", "timeline", "created_at"}
 
-	cursor, err := collection.Find(ctx, filter, options.Find().SetLimit(int64(limit)))
-	if err != nil {
-		return results, err
-	}
-	defer cursor.Close(ctx)
+func searchOrders(ctx context.Context, collection *mongo.Collection, req SearchRequest) ([]SearchResult, int, error) {
+	var results []SearchResult
 
-	if err := cursor.All(ctx, &orders); err != nil {
-		return results, err
+	matches, total, err := findMatches(ctx, collection, req, nil, []string{"order_number", "customer_email", "status", "notes"}, orderResultFields)
+	if err != nil {
+		return results, 0, err
 	}
 
-	for _, order := range orders {
+	for _, match := range matches {
+		var order OrderSearchData
+		if err := bson.Unmarshal(match.Document, &order); err != nil {
+			return results, 0, err
+		}
 		snippet := fmt.Sprintf("Status: %s | Total: $%.2f | Items: %d", order.Status, order.Totals.GrandTotal, len(order.Items))
 
 		results = append(results, SearchResult{
-			ID:      order.ID,
-			Type:    "order",
-			Title:   order.OrderNumber,
-			Snippet: snippet,
-			Data:    order,
+			ID:         order.ID,
+			Type:       "order",
+			Title:      order.OrderNumber,
+			Snippet:    snippet,
+			Score:      match.Score,
+			Highlights: match.Highlights,
+			Data:       order,
 		})
 	}
 
-	return results, nil
+	return results, total, nil
 }
 
-func searchReviews(ctx context.Context, collection *mongo.Collection, query string, limit int) ([]SearchResult, error) {
-	var reviews []models.Review
+func searchReviews(ctx context.Context, collection *mongo.Collection, req SearchRequest) ([]SearchResult, int, error) {
 	var results []SearchResult
 
-	// Search review title and comment
-	filter := bson.M{
-		"$or": []bson.M{
-			{"title": bson.M{"$regex": query, "$options": "i"}},
-			{"comment": bson.M{"$regex": query, "$options": "i"}},
-		},
-	}
-
-	cursor, err := collection.Find(ctx, filter, options.Find().SetLimit(int64(limit)))
+	matches, total, err := findMatches(ctx, collection, req, nil, []string{"title", "comment"}, nil)
 	if err != nil {
-		return results, err
-	}
-	defer cursor.Close(ctx)
-
-	if err := cursor.All(ctx, &reviews); err != nil {
-		return results, err
+		return results, 0, err
 	}
 
-	for _, review := range reviews {
+	for _, match := range matches {
+		var review models.Review
+		if err := bson.Unmarshal(match.Document, &review); err != nil {
+			return results, 0, err
+		}
 		snippet := review.Comment
 		if len(snippet) > 150 {
 			snippet = snippet[:150] + "..."
 		}
 
 		results = append(results, SearchResult{
-			ID:      review.ID,
-			Type:    "review",
-			Title:   review.Title,
-			Snippet: snippet,
-			Data:    review,
+			ID:         review.ID,
+			Type:       "review",
+			Title:      review.Title,
+			Snippet:    snippet,
+			Score:      match.Score,
+			Highlights: match.Highlights,
+			Data:       review,
 		})
 	}
 
-	return results, nil
+	return results, total, nil
 }
 
 // DeleteCustomer removes a customer by ID
@@ -1238,16 +1787,18 @@ func DeleteCustomer(ctx context.Context, customerID string) error {
 		return err
 	}
 
-	// Delete the customer
-	result, err := collection.DeleteOne(ctx, bson.M{"_id": objectID})
-	if err != nil {
-		return err
-	}
+	return inTransaction(ctx, func(ctx context.Context) error {
+		// Delete the customer
+		result, err := collection.DeleteOne(ctx, bson.M{"_id": objectID})
+		if err != nil {
+			return err
+		}
 
-	// Check if customer was found and deleted
-	if result.DeletedCount == 0 {
-		return errors.New("customer not found")
-	}
+		// Check if customer was found and deleted
+		if result.DeletedCount == 0 {
+			return notFound("customer")
+		}
 
-	return nil
+		return enqueueEvent(ctx, events.CustomerDeleted, events.CustomerDeletedEvent{CustomerID: customerID})
+	})
 }
@@ -0,0 +1,300 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// importJobMaxAttempts is how many times an import stopped by errors is resumed before
+// it is marked failed
+const importJobMaxAttempts = 3
+
+// importRowInsertBatch is how many rows are stored with each insert when a job is queued
+const importRowInsertBatch = 1000
+
+// ErrImportLeaseLost means another worker claimed the import job, so this one must stop
+var ErrImportLeaseLost = errors.New("import job lease lost")
+
+// importRowError wraps why a row was rejected, telling it apart from errors that stop
+// the job
+type importRowError struct {
+	err error
+}
+
+func (e importRowError) Error() string {
+	return e.err.Error()
+}
+
+// CreateImportJob queues rows, each a product or order request, for the import worker.
+// The job and its rows are stored in one transaction.
+func CreateImportJob(ctx context.Context, kind string, rows []interface{}, createdBy *bson.ObjectID) (*models.ImportJob, error) {
+	now := time.Now()
+	job := &models.ImportJob{
+		ID:          bson.NewObjectID(),
+		Kind:        kind,
+		Status:      models.ImportPending,
+		Total:       len(rows),
+		RowErrors:   []models.ImportRowError{},
+		CreatedBy:   createdBy,
+		AvailableAt: now,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(models.ImportJobRetention),
+	}
+
+	docs := make([]interface{}, len(rows))
+	for i, row := range rows {
+		data, err := bson.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		docs[i] = models.ImportJobRow{JobID: job.ID, Row: i, Data: data, ExpiresAt: job.ExpiresAt}
+	}
+
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		if _, err := GetCollection("import_jobs").InsertOne(ctx, job); err != nil {
+			return err
+		}
+		for start := 0; start < len(docs); start += importRowInsertBatch {
+			end := min(start+importRowInsertBatch, len(docs))
+			if _, err := GetCollection("import_job_rows").InsertMany(ctx, docs[start:end]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// GetImportJob returns an import job's progress
+func GetImportJob(ctx context.Context, id bson.ObjectID) (*models.ImportJob, error) {
+	var job models.ImportJob
+	err := GetCollection("import_jobs").FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&job)
+	if err != nil {
+		return nil, notFoundError(err, "import job")
+	}
+	return &job, nil
+}
+
+// ClaimImportJob leases the oldest queued import, or one whose worker stopped before
+// finishing, hiding it from other workers for lease. It returns nil when there is
+// nothing to do.
+func ClaimImportJob(ctx context.Context, lease time.Duration) (*models.ImportJob, error) {
+	now := time.Now()
+	filter := bson.D{
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{models.ImportPending, models.ImportRunning}}}},
+		{Key: "available_at", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "status", Value: models.ImportRunning}, {Key: "available_at", Value: now.Add(lease)}}},
+		{Key: "$min", Value: bson.D{{Key: "started_at", Value: now}}},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+	}
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job models.ImportJob
+	err := GetCollection("import_jobs").FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&job)
+	if err != nil {
+		if isNoDocuments(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetImportJobRows returns up to limit of a job's rows, starting at row from
+func GetImportJobRows(ctx context.Context, jobID bson.ObjectID, from, limit int) ([]models.ImportJobRow, error) {
+	cursor, err := GetCollection("import_job_rows").Find(ctx,
+		bson.D{{Key: "job_id", Value: jobID}, {Key: "row", Value: bson.D{{Key: "$gte", Value: from}}}},
+		options.Find().SetSort(bson.D{{Key: "row", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []models.ImportJobRow
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ImportProducts creates the products of a job's next rows and records the rows as done
+// in the same transaction, so a resumed job never creates them twice. When the batch
+// fails, each product is tried on its own and the ones that still fail are recorded as
+// rejected rows.
+func ImportProducts(ctx context.Context, job *models.ImportJob, products []*models.Product, lease time.Duration) ([]*models.Product, error) {
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		if _, err := CreateProducts(ctx, products); err != nil {
+			return err
+		}
+		return recordImportProgress(ctx, job, len(products), nil, lease)
+	})
+	if err == nil {
+		advanceImportJob(job, len(products), 0)
+		return products, nil
+	}
+	if errors.Is(err, ErrImportLeaseLost) {
+		return nil, err
+	}
+
+	var created []*models.Product
+	for _, product := range products {
+		err := inTransaction(ctx, func(ctx context.Context) error {
+			if _, err := CreateProducts(ctx, []*models.Product{product}); err != nil {
+				return importRowError{err}
+			}
+			return recordImportProgress(ctx, job, 1, nil, lease)
+		})
+		if err == nil {
+			advanceImportJob(job, 1, 0)
+			created = append(created, product)
+			continue
+		}
+		if err := rejectImportRow(ctx, job, err, lease); err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+// ImportOrder creates the order in a job's next row and records the row as done in the
+// same transaction, so a resumed job never creates it twice. A rejected order, such as
+// one for an unknown customer, is recorded as a rejected row.
+func ImportOrder(ctx context.Context, job *models.ImportJob, req models.CreateOrderRequest, lease time.Duration) error {
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		_, errs := CreateNewOrders(ctx, []models.CreateOrderRequest{req})
+		if errs[0] != nil {
+			return importRowError{errs[0]}
+		}
+		return recordImportProgress(ctx, job, 1, nil, lease)
+	})
+	if err == nil {
+		advanceImportJob(job, 1, 0)
+		return nil
+	}
+	return rejectImportRow(ctx, job, err, lease)
+}
+
+// RecordRejectedImportRow records a job's next row as rejected, such as one that could
+// not be read
+func RecordRejectedImportRow(ctx context.Context, job *models.ImportJob, rowErr error, lease time.Duration) error {
+	return rejectImportRow(ctx, job, importRowError{rowErr}, lease)
+}
+
+// rejectImportRow records the job's next row as rejected when err is an importRowError,
+// and returns any other error
+func rejectImportRow(ctx context.Context, job *models.ImportJob, err error, lease time.Duration) error {
+	var rowErr importRowError
+	if !errors.As(err, &rowErr) {
+		return err
+	}
+	if err := recordImportProgress(ctx, job, 1, []models.ImportRowError{{Row: job.Processed, Error: rowErr.Error()}}, lease); err != nil {
+		return err
+	}
+	advanceImportJob(job, 1, 1)
+	return nil
+}
+
+// recordImportProgress marks the job's next rows as tried, counting rowErrors as
+// rejected and the rest as imported, and extends the lease. It fails with
+// ErrImportLeaseLost when the job was claimed again or has moved on since job was read.
+// Call advanceImportJob once the write is committed.
+func recordImportProgress(ctx context.Context, job *models.ImportJob, rows int, rowErrors []models.ImportRowError, lease time.Duration) error {
+	filter := bson.D{
+		{Key: "_id", Value: job.ID},
+		{Key: "status", Value: models.ImportRunning},
+		{Key: "attempts", Value: job.Attempts},
+		{Key: "processed", Value: job.Processed},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "processed", Value: job.Processed + rows},
+			{Key: "available_at", Value: time.Now().Add(lease)},
+		}},
+		{Key: "$inc", Value: bson.D{
+			{Key: "succeeded", Value: rows - len(rowErrors)},
+			{Key: "failed", Value: len(rowErrors)},
+		}},
+	}
+	if len(rowErrors) > 0 {
+		update = append(update, bson.E{Key: "$push", Value: bson.D{{Key: "row_errors", Value: bson.D{
+			{Key: "$each", Value: rowErrors},
+			{Key: "$slice", Value: models.MaxImportRowErrors},
+		}}}})
+	}
+
+	result, err := GetCollection("import_jobs").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrImportLeaseLost
+	}
+	return nil
+}
+
+// advanceImportJob applies recorded progress to the worker's copy of the job
+func advanceImportJob(job *models.ImportJob, rows, failed int) {
+	job.Processed += rows
+	job.Succeeded += rows - failed
+	job.Failed += failed
+}
+
+// CompleteImportJob marks a job whose rows have all been tried as completed and deletes
+// its rows
+func CompleteImportJob(ctx context.Context, job *models.ImportJob) error {
+	return finishImportJob(ctx, job, bson.D{{Key: "status", Value: models.ImportCompleted}})
+}
+
+// RetryImportJob records an error that stopped a job. The job resumes from its next row
+// at retryAt, or is marked failed when it has used all its attempts.
+func RetryImportJob(ctx context.Context, job *models.ImportJob, jobErr error, retryAt time.Time) error {
+	if job.Attempts >= importJobMaxAttempts {
+		return finishImportJob(ctx, job, bson.D{{Key: "status", Value: models.ImportFailed}, {Key: "error", Value: jobErr.Error()}})
+	}
+
+	_, err := GetCollection("import_jobs").UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: job.ID}, {Key: "attempts", Value: job.Attempts}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: models.ImportPending},
+			{Key: "error", Value: jobErr.Error()},
+			{Key: "available_at", Value: retryAt},
+		}}},
+	)
+	return err
+}
+
+// finishImportJob sets a job's final status, keeping it for ImportJobRetention, and
+// deletes its rows
+func finishImportJob(ctx context.Context, job *models.ImportJob, set bson.D) error {
+	now := time.Now()
+	set = append(set,
+		bson.E{Key: "completed_at", Value: now},
+		bson.E{Key: "expires_at", Value: now.Add(models.ImportJobRetention)},
+	)
+
+	result, err := GetCollection("import_jobs").UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: job.ID}, {Key: "attempts", Value: job.Attempts}},
+		bson.D{{Key: "$set", Value: set}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrImportLeaseLost
+	}
+
+	_, err = GetCollection("import_job_rows").DeleteMany(ctx, bson.D{{Key: "job_id", Value: job.ID}})
+	return err
+}
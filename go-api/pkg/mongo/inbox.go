@@ -0,0 +1,100 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// InboxRetention is how long in-app notifications are kept, read or not
+const InboxRetention = 90 * 24 * time.Hour
+
+// AddInboxNotification stores an in-app notification. One whose DedupeKey was already
+// stored is skipped, so every instance can handle the event behind it.
+func AddInboxNotification(ctx context.Context, notification *models.InboxNotification) error {
+	result, err := GetCollection("inbox").InsertOne(ctx, notification)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return err
+	}
+	notification.ID = result.InsertedID.(bson.ObjectID)
+	return nil
+}
+
+// GetInboxNotifications lists a customer's in-app notifications, newest first, with how
+// many of them are unread
+func GetInboxNotifications(ctx context.Context, customerID bson.ObjectID, unreadOnly bool, page, limit int) ([]models.InboxNotification, int64, int64, error) {
+	collection := GetCollection("inbox")
+
+	filter := bson.D{{Key: "customer_id", Value: customerID}}
+	unreadFilter := bson.D{{Key: "customer_id", Value: customerID}, {Key: "read", Value: false}}
+
+	unread, err := collection.CountDocuments(ctx, unreadFilter)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	total := unread
+	if unreadOnly {
+		filter = unreadFilter
+	} else if total, err = collection.CountDocuments(ctx, filter); err != nil {
+		return nil, 0, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []models.InboxNotification{}
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, 0, 0, err
+	}
+	return notifications, total, unread, nil
+}
+
+// MarkInboxNotificationRead marks one of a customer's notifications read. Marking a read
+// notification again keeps its first read_at.
+func MarkInboxNotificationRead(ctx context.Context, customerID, notificationID bson.ObjectID) (*models.InboxNotification, error) {
+	collection := GetCollection("inbox")
+
+	filter := bson.D{{Key: "_id", Value: notificationID}, {Key: "customer_id", Value: customerID}}
+	update := bson.A{
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "read", Value: true},
+			{Key: "read_at", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$read_at", time.Now()}}}},
+		}}},
+	}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var notification models.InboxNotification
+	err := collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&notification)
+	if err != nil {
+		return nil, notFoundError(err, "notification")
+	}
+	return &notification, nil
+}
+
+// MarkAllInboxNotificationsRead marks every unread notification of a customer read and
+// returns how many it changed
+func MarkAllInboxNotificationsRead(ctx context.Context, customerID bson.ObjectID) (int64, error) {
+	result, err := GetCollection("inbox").UpdateMany(ctx,
+		bson.D{{Key: "customer_id", Value: customerID}, {Key: "read", Value: false}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "read", Value: true}, {Key: "read_at", Value: time.Now()}}}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
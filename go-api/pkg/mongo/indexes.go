@@ -1,14 +1,20 @@
 package mongo
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
 	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/logging"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
 )
 
 type IndexConfig struct {
@@ -157,106 +163,756 @@ var requiredIndexes = []IndexConfig{
 			Options: options.Index().SetName("idx_sku_history"),
 		},
 	},
+
+	// Coupons Collection Indexes
+	// Index 13: Unique coupon code lookup
+	{
+		CollectionName: "coupons",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "code", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_coupon_code_unique"),
+		},
+	},
+	// Index 14: Per-customer coupon redemption counts
+	{
+		CollectionName: "coupon_redemptions",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "coupon_code", Value: 1},
+				{Key: "customer_id", Value: 1},
+			},
+			Options: options.Index().SetName("idx_coupon_redemptions"),
+		},
+	},
+
+	// Gift Cards Collection Indexes
+	// Index 15: Unique gift card code lookup
+	{
+		CollectionName: "gift_cards",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "code", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_gift_card_code_unique"),
+		},
+	},
+
+	// Abandoned Carts Collection Indexes
+	// Index 16: Most recent abandoned carts for the admin listing
+	{
+		CollectionName: "abandoned_carts",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "detected_at", Value: -1}},
+			Options: options.Index().SetName("idx_abandoned_detected"),
+		},
+	},
+	// Index 17: Unique persisted cart per session
+	{
+		CollectionName: "carts",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "session_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_carts_session_unique"),
+		},
+	},
+	// Index 18: Expire persisted carts that have not been touched within the retention window
+	{
+		CollectionName: "carts",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "updated_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(CartSnapshotRetention.Seconds())).SetName("idx_carts_updated_ttl"),
+		},
+	},
+	// Index 19: One helpful vote per customer per review
+	{
+		CollectionName: "review_votes",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "review_id", Value: 1},
+				{Key: "customer_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(true).SetName("idx_review_votes_unique"),
+		},
+	},
+	// Index 20: Recount approval queue
+	{
+		CollectionName: "inventory_recounts",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "status", Value: 1},
+				{Key: "created_at", Value: -1},
+			},
+			Options: options.Index().SetName("idx_recounts_status"),
+		},
+	},
+	// Index 21: One embedding per product
+	{
+		CollectionName: "product_embeddings",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "sku", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_product_embeddings_sku"),
+		},
+	},
+
+	// AI Reports Collection Indexes
+	// Index 22: Due schedules for the report scheduler
+	{
+		CollectionName: "report_schedules",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "enabled", Value: 1},
+				{Key: "next_run_at", Value: 1},
+			},
+			Options: options.Index().SetName("idx_report_schedules_due"),
+		},
+	},
+	// Index 23: Report history by type, most recent first
+	{
+		CollectionName: "reports",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "report_type", Value: 1},
+				{Key: "generated_at", Value: -1},
+			},
+			Options: options.Index().SetName("idx_reports_type_generated"),
+		},
+	},
+	// Index 24: Audit trail for one customer or order, most recent first
+	{
+		CollectionName: "audit_logs",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "entity_type", Value: 1},
+				{Key: "entity_id", Value: 1},
+				{Key: "created_at", Value: -1},
+			},
+			Options: options.Index().SetName("idx_audit_logs_entity_created"),
+		},
+	},
+	// Index 25: Audit log listing by date
+	{
+		CollectionName: "audit_logs",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_audit_logs_created"),
+		},
+	},
+	// Index 26-28: Keyset pagination of the large collections, newest first
+	{
+		CollectionName: "products",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetName("idx_products_created_id"),
+		},
+	},
+	{
+		CollectionName: "orders",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetName("idx_orders_created_id"),
+		},
+	},
+	{
+		CollectionName: "customers",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetName("idx_customers_created_id"),
+		},
+	},
+	// Index 29: Outbox relay queue, oldest claimable event first
+	{
+		CollectionName: "outbox",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "available_at", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("idx_outbox_pending"),
+		},
+	},
+	// Index 30: Delivered outbox events expire; failed ones are kept for inspection
+	{
+		CollectionName: "outbox",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "delivered_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(OutboxRetention.Seconds())).SetName("idx_outbox_delivered_ttl"),
+		},
+	},
+	// Index 31: Text index for full-text search on customers
+	{
+		CollectionName: "customers",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "first_name", Value: "text"},
+				{Key: "last_name", Value: "text"},
+				{Key: "email", Value: "text"},
+				{Key: "phone", Value: "text"},
+			},
+			Options: options.Index().
+				SetName("idx_customer_text_search").
+				SetWeights(bson.D{
+					{Key: "first_name", Value: 5},
+					{Key: "last_name", Value: 5},
+					{Key: "email", Value: 5},
+					{Key: "phone", Value: 1},
+				}),
+		},
+	},
+	// Index 32: Text index for full-text search on orders
+	{
+		CollectionName: "orders",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "order_number", Value: "text"},
+				{Key: "customer_email", Value: "text"},
+				{Key: "status", Value: "text"},
+				{Key: "notes", Value: "text"},
+			},
+			Options: options.Index().
+				SetName("idx_order_text_search").
+				SetWeights(bson.D{
+					{Key: "order_number", Value: 10},
+					{Key: "customer_email", Value: 5},
+					{Key: "status", Value: 2},
+					{Key: "notes", Value: 1},
+				}),
+		},
+	},
+	// Index 33: Text index for full-text search on reviews
+	{
+		CollectionName: "reviews",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "title", Value: "text"},
+				{Key: "comment", Value: "text"},
+			},
+			Options: options.Index().
+				SetName("idx_review_text_search").
+				SetWeights(bson.D{
+					{Key: "title", Value: 5},
+					{Key: "comment", Value: 1},
+				}),
+		},
+	},
+	// Index 34: Search analytics by date; logs expire after the retention window
+	{
+		CollectionName: "search_logs",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(SearchLogRetention.Seconds())).SetName("idx_search_logs_ttl"),
+		},
+	},
+	// Index 35: A customer's saved searches, newest first
+	{
+		CollectionName: "saved_searches",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "customer_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_saved_searches_customer"),
+		},
+	},
+	// Index 36: Saved searches to check against new products
+	{
+		CollectionName: "saved_searches",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "notify", Value: 1}},
+			Options: options.Index().SetName("idx_saved_searches_notify"),
+		},
+	},
+	// Index 37: Each new product matches a saved search at most once
+	{
+		CollectionName: "saved_search_matches",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "saved_search_id", Value: 1}, {Key: "product_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_saved_search_matches_unique"),
+		},
+	},
+	// Index 38: A customer's loyalty history, newest first
+	{
+		CollectionName: "loyalty_transactions",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "customer_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_loyalty_transactions_customer"),
+		},
+	},
+	// Index 39: An order earns, redeems and refunds points at most once each
+	{
+		CollectionName: "loyalty_transactions",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{{Key: "order_number", Value: 1}, {Key: "type", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.D{{Key: "order_number", Value: bson.D{{Key: "$exists", Value: true}}}}).
+				SetName("idx_loyalty_transactions_order"),
+		},
+	},
+	// Index 40: A customer's exports of the same format, newest first
+	{
+		CollectionName: "customer_exports",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "customer_id", Value: 1}, {Key: "format", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_customer_exports_customer"),
+		},
+	},
+	// Index 41: Queued exports for the export worker to claim
+	{
+		CollectionName: "customer_exports",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "available_at", Value: 1}},
+			Options: options.Index().SetName("idx_customer_exports_queue"),
+		},
+	},
+	// Index 42: Exports are deleted once they expire
+	{
+		CollectionName: "customer_exports",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("idx_customer_exports_ttl"),
+		},
+	},
+	// Index 43: Customer listing filtered by account status, newest first
+	{
+		CollectionName: "customers",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "account_status", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetName("idx_customers_status_created"),
+		},
+	},
+	// Index 44: Find the order a payment transaction paid, for refund webhooks
+	{
+		CollectionName: "orders",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "payment.transaction_id", Value: 1}},
+			Options: options.Index().SetName("idx_orders_payment_transaction"),
+		},
+	},
+	// Index 45: One document per template version, newest first
+	{
+		CollectionName: "templates",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "key", Value: 1}, {Key: "version", Value: -1}},
+			Options: options.Index().SetUnique(true).SetName("idx_templates_key_version"),
+		},
+	},
+	// Index 46: Pending notifications for the delivery worker to claim
+	{
+		CollectionName: "notifications",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "available_at", Value: 1}},
+			Options: options.Index().SetName("idx_notifications_queue"),
+		},
+	},
+	// Index 47: Notification listing, newest first
+	{
+		CollectionName: "notifications",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_notifications_created"),
+		},
+	},
+	// Index 48: Sent notifications are removed after NotificationRetention
+	{
+		CollectionName: "notifications",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "sent_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(NotificationRetention.Seconds())).SetName("idx_notifications_sent_ttl"),
+		},
+	},
+	// Index 49: A notification with a dedupe key is queued once, however many instances
+	// handle the event behind it
+	{
+		CollectionName: "notifications",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{{Key: "dedupe_key", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.D{{Key: "dedupe_key", Value: bson.D{{Key: "$exists", Value: true}}}}).
+				SetName("idx_notifications_dedupe"),
+		},
+	},
+	// Index 50: A customer's inbox, newest first
+	{
+		CollectionName: "inbox",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "customer_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_inbox_customer_created"),
+		},
+	},
+	// Index 51: Unread counts and mark-all-read
+	{
+		CollectionName: "inbox",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "customer_id", Value: 1}, {Key: "read", Value: 1}},
+			Options: options.Index().SetName("idx_inbox_customer_read"),
+		},
+	},
+	// Index 52: One inbox entry per event and customer, however many instances handle it
+	{
+		CollectionName: "inbox",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "dedupe_key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_inbox_dedupe"),
+		},
+	},
+	// Index 53: Inbox entries are removed after InboxRetention
+	{
+		CollectionName: "inbox",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(InboxRetention.Seconds())).SetName("idx_inbox_created_ttl"),
+		},
+	},
+	// Index 54: One back-in-stock subscription per product and email, found by SKU on restock
+	{
+		CollectionName: "stock_subscriptions",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "sku", Value: 1}, {Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_stock_subscriptions_sku_email"),
+		},
+	},
+	// Index 55: One price alert per customer and product, listed newest first
+	{
+		CollectionName: "price_alerts",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "customer_id", Value: 1}, {Key: "sku", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_price_alerts_customer_sku"),
+		},
+	},
+	// Index 56: Price alerts matched when a product's price changes
+	{
+		CollectionName: "price_alerts",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "sku", Value: 1}},
+			Options: options.Index().SetName("idx_price_alerts_sku"),
+		},
+	},
+	// Index 57: Skipped notifications are removed after NotificationRetention
+	{
+		CollectionName: "notifications",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "skipped_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(NotificationRetention.Seconds())).SetName("idx_notifications_skipped_ttl"),
+		},
+	},
+	// Index 58: One admin account per email
+	{
+		CollectionName: "admins",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_admins_email"),
+		},
+	},
+	// Index 59: Admin lookup by personal key on every admin request
+	{
+		CollectionName: "admins",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{{Key: "key_hash", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.D{{Key: "key_hash", Value: bson.D{{Key: "$exists", Value: true}}}}).
+				SetName("idx_admins_key"),
+		},
+	},
+	// Index 60: Admin invitation lookup by token
+	{
+		CollectionName: "admins",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{{Key: "invite_token_hash", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.D{{Key: "invite_token_hash", Value: bson.D{{Key: "$exists", Value: true}}}}).
+				SetName("idx_admins_invite_token"),
+		},
+	},
+	// Index 61: Audit entries that changed a field, such as who changed a price
+	{
+		CollectionName: "audit_logs",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "changes.field", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_audit_logs_changed_field"),
+		},
+	},
+	// Index 62: Audit entries by the admin who made them
+	{
+		CollectionName: "audit_logs",
+		IndexModel: mongo.IndexModel{
+			Keys: bson.D{{Key: "admin_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().
+				SetPartialFilterExpression(bson.D{{Key: "admin_id", Value: bson.D{{Key: "$exists", Value: true}}}}).
+				SetName("idx_audit_logs_admin"),
+		},
+	},
+	// Index 63: Queued imports for the import worker to claim
+	{
+		CollectionName: "import_jobs",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "available_at", Value: 1}},
+			Options: options.Index().SetName("idx_import_jobs_queue"),
+		},
+	},
+	// Index 64: Import jobs are deleted once they expire
+	{
+		CollectionName: "import_jobs",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("idx_import_jobs_ttl"),
+		},
+	},
+	// Index 65: An import's rows in order, each stored once
+	{
+		CollectionName: "import_job_rows",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "job_id", Value: 1}, {Key: "row", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_import_job_rows_job_row"),
+		},
+	},
+	// Index 66: Rows left behind by an abandoned import are deleted with it
+	{
+		CollectionName: "import_job_rows",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("idx_import_job_rows_ttl"),
+		},
+	},
+	// Index 67: Report archives already requested for a date range
+	{
+		CollectionName: "report_archives",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "start_date", Value: 1}, {Key: "end_date", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_report_archives_range"),
+		},
+	},
+	// Index 68: Queued report archives for the archive worker to claim
+	{
+		CollectionName: "report_archives",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "available_at", Value: 1}},
+			Options: options.Index().SetName("idx_report_archives_queue"),
+		},
+	},
+	// Index 69: Report archives are deleted once they expire
+	{
+		CollectionName: "report_archives",
+		IndexModel: mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("idx_report_archives_ttl"),
+		},
+	},
 }
 
-func EnsureIndexes() error {
-	log.Println("Starting index creation...")
+// Name returns the name set in the index options, which every required index has
+func (c IndexConfig) Name() string {
+	var indexOptions options.IndexOptions
+	if c.IndexModel.Options != nil {
+		for _, apply := range c.IndexModel.Options.List() {
+			_ = apply(&indexOptions)
+		}
+	}
+	if indexOptions.Name == nil {
+		return ""
+	}
+	return *indexOptions.Name
+}
+
+// indexBuildTimeout bounds listing and building each required index
+const indexBuildTimeout = 10 * time.Second
+
+// EnsureIndexes creates every required index that does not exist yet and reports what
+// it did with each. An index that existing documents break the unique constraint of is
+// skipped; any other failure is returned after the remaining indexes are tried.
+func EnsureIndexes(ctx context.Context) ([]models.EnsuredIndex, error) {
+	slog.InfoContext(ctx, "Starting index creation...")
 
+	existing := map[string]map[string]bool{}
+	results := make([]models.EnsuredIndex, 0, len(requiredIndexes))
+	var failures []error
 	for _, idxConfig := range requiredIndexes {
-		collection := GetCollection(idxConfig.CollectionName)
-		ctx, cancel := global.GetDefaultTimer()
-		defer cancel()
-
-		// Try to extract index name from the options
-		var indexName string
-		if idxConfig.IndexModel.Options != nil {
-			// Build the options to extract the name
-			opts := idxConfig.IndexModel.Options
-			if opts != nil {
-				indexName = "custom_index" // We'll use the defined names from our config
-			}
+		result := models.EnsuredIndex{Collection: idxConfig.CollectionName, Name: idxConfig.Name()}
+		err := ensureIndex(ctx, idxConfig, existing, &result)
+		if err != nil {
+			result.Status = models.IndexFailed
+			result.Error = err.Error()
+			failures = append(failures, fmt.Errorf("%s.%s: %w", result.Collection, result.Name, err))
+			slog.ErrorContext(ctx, "Error creating index", "index", result.Name, "collection", result.Collection, "error", err)
 		}
+		results = append(results, result)
+	}
 
-		// For our specific indexes, we know the names
-		switch {
-		case strings.Contains(fmt.Sprintf("%v", idxConfig.IndexModel.Keys), "category"):
-			indexName = "idx_category"
-		case strings.Contains(fmt.Sprintf("%v", idxConfig.IndexModel.Keys), "sku"):
-			indexName = "idx_sku_unique"
-		case strings.Contains(fmt.Sprintf("%v", idxConfig.IndexModel.Keys), "name"):
-			indexName = "idx_product_text_search"
-		case strings.Contains(fmt.Sprintf("%v", idxConfig.IndexModel.Keys), "status") &&
-			strings.Contains(fmt.Sprintf("%v", idxConfig.IndexModel.Keys), "stock"):
-			indexName = "idx_stock_alert"
-		case strings.Contains(fmt.Sprintf("%v", idxConfig.IndexModel.Keys), "status") &&
-			strings.Contains(fmt.Sprintf("%v", idxConfig.IndexModel.Keys), "price"):
-			indexName = "idx_status_price"
-		case strings.Contains(fmt.Sprintf("%v", idxConfig.IndexModel.Keys), "customer_id"):
-			indexName = "idx_customer_orders"
-		case strings.Contains(fmt.Sprintf("%v", idxConfig.IndexModel.Keys), "order_number"):
-			indexName = "idx_order_number_unique"
-		case strings.Contains(fmt.Sprintf("%v", idxConfig.IndexModel.Keys), "email"):
-			indexName = "idx_customer_email_unique"
-		default:
-			indexName = "unknown_index"
-		}
+	if len(failures) > 0 {
+		return results, errors.Join(failures...)
+	}
+	slog.InfoContext(ctx, "All indexes processed successfully!")
+	return results, nil
+}
 
-		// Check if index already exists
-		cursor, err := collection.Indexes().List(ctx)
+// ensureIndex creates one required index unless it exists, setting the result's status.
+// existing caches each collection's index names between calls.
+func ensureIndex(parent context.Context, idxConfig IndexConfig, existing map[string]map[string]bool, result *models.EnsuredIndex) error {
+	ctx, cancel := context.WithTimeout(parent, indexBuildTimeout)
+	defer cancel()
+
+	names, ok := existing[idxConfig.CollectionName]
+	if !ok {
+		var err error
+		names, err = indexNames(ctx, idxConfig.CollectionName)
 		if err != nil {
-			log.Printf("Error listing indexes on collection %s: %v", idxConfig.CollectionName, err)
-			continue
+			return err
 		}
+		existing[idxConfig.CollectionName] = names
+	}
 
-		var existingIndexes []bson.M
-		if err = cursor.All(ctx, &existingIndexes); err != nil {
-			log.Printf("Error reading indexes on collection %s: %v", idxConfig.CollectionName, err)
-			continue
+	if names[result.Name] {
+		slog.DebugContext(ctx, "Index already exists", "index", result.Name, "collection", idxConfig.CollectionName)
+		result.Status = models.IndexExists
+		return nil
+	}
+
+	if _, err := GetCollection(idxConfig.CollectionName).Indexes().CreateOne(ctx, idxConfig.IndexModel); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			slog.WarnContext(ctx, "Skipping index due to duplicate keys in existing data; consider running CleanupDuplicateSKUs()",
+				"index", result.Name, "collection", idxConfig.CollectionName)
+			result.Status = models.IndexSkipped
+			result.Error = err.Error()
+			return nil
 		}
+		return err
+	}
 
-		indexExists := false
-		for _, index := range existingIndexes {
-			if name, ok := index["name"].(string); ok && name == indexName {
-				indexExists = true
-				break
+	names[result.Name] = true
+	result.Status = models.IndexCreated
+	slog.InfoContext(ctx, "Created index", "index", result.Name, "collection", idxConfig.CollectionName)
+	return nil
+}
+
+// indexNames returns the names of the indexes on a collection
+func indexNames(ctx context.Context, collectionName string) (map[string]bool, error) {
+	cursor, err := GetCollection(collectionName).Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var indexes []bson.M
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, index := range indexes {
+		if name, ok := index["name"].(string); ok {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+// MissingIndexes returns the required indexes that do not exist, as "collection.index_name"
+func MissingIndexes(ctx context.Context) ([]string, error) {
+	existing := map[string]map[string]bool{}
+	var missing []string
+
+	for _, idxConfig := range requiredIndexes {
+		names, ok := existing[idxConfig.CollectionName]
+		if !ok {
+			var err error
+			names, err = indexNames(ctx, idxConfig.CollectionName)
+			if err != nil {
+				return nil, err
 			}
+			existing[idxConfig.CollectionName] = names
 		}
 
-		if indexExists {
-			log.Printf("✓ Index '%s' already exists on collection '%s'", indexName, idxConfig.CollectionName)
-			continue
+		if !names[idxConfig.Name()] {
+			missing = append(missing, idxConfig.CollectionName+"."+idxConfig.Name())
 		}
+	}
 
-		// Create the index
-		createdIndexName, err := collection.Indexes().CreateOne(ctx, idxConfig.IndexModel)
+	return missing, nil
+}
+
+// GetIndexes lists the indexes on a collection, or on every collection when collection
+// is empty, with their usage from $indexStats. Required indexes are marked. An unknown
+// collection returns ErrNotFound.
+func GetIndexes(ctx context.Context, collection string) ([]models.CollectionIndex, error) {
+	filter := bson.D{{Key: "type", Value: "collection"}}
+	if collection != "" {
+		filter = append(filter, bson.E{Key: "name", Value: collection})
+	}
+	collections, err := GetDatabase().ListCollectionNames(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if collection != "" && len(collections) == 0 {
+		return nil, notFound("collection")
+	}
+	sort.Strings(collections)
+
+	required := map[string]bool{}
+	for _, idxConfig := range requiredIndexes {
+		required[idxConfig.CollectionName+"."+idxConfig.Name()] = true
+	}
+
+	indexes := []models.CollectionIndex{}
+	for _, name := range collections {
+		if strings.HasPrefix(name, "system.") {
+			continue
+		}
+		collectionIndexes, err := collectionIndexes(ctx, name)
 		if err != nil {
-			// Handle duplicate key errors gracefully for unique indexes
-			if strings.Contains(err.Error(), "DuplicateKey") || strings.Contains(err.Error(), "E11000") {
-				log.Printf("⚠ Skipping index '%s' on collection '%s' due to duplicate keys in existing data.",
-					indexName, idxConfig.CollectionName)
-				log.Printf("💡 Consider running cleanup: CleanupDuplicateSKUs()")
-				continue
-			}
-			log.Printf("Error creating index '%s' on collection %s: %v", indexName, idxConfig.CollectionName, err)
-			return err
+			return nil, err
+		}
+		for i := range collectionIndexes {
+			collectionIndexes[i].Required = required[name+"."+collectionIndexes[i].Name]
 		}
+		indexes = append(indexes, collectionIndexes...)
+	}
+	return indexes, nil
+}
+
+// collectionIndexes lists one collection's indexes in creation order with their usage
+func collectionIndexes(ctx context.Context, collectionName string) ([]models.CollectionIndex, error) {
+	collection := GetCollection(collectionName)
 
-		log.Printf("✓ Created index '%s' on collection '%s'", createdIndexName, idxConfig.CollectionName)
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var indexes []models.CollectionIndex
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return nil, err
 	}
 
-	log.Println("All indexes processed successfully!")
-	return nil
+	statsCursor, err := collection.Aggregate(ctx, bson.A{bson.D{{Key: "$indexStats", Value: bson.D{}}}})
+	if err != nil {
+		return nil, err
+	}
+	var stats []struct {
+		Name     string `bson:"name"`
+		Accesses struct {
+			Ops   int64     `bson:"ops"`
+			Since time.Time `bson:"since"`
+		} `bson:"accesses"`
+	}
+	if err := statsCursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+
+	// A sharded cluster reports each shard separately, so add them up
+	for i := range indexes {
+		indexes[i].Collection = collectionName
+		for _, stat := range stats {
+			if stat.Name != indexes[i].Name {
+				continue
+			}
+			indexes[i].Accesses += stat.Accesses.Ops
+			if since := stat.Accesses.Since; indexes[i].UsageSince == nil || since.Before(*indexes[i].UsageSince) {
+				indexes[i].UsageSince = &since
+			}
+		}
+	}
+	return indexes, nil
 }
 
 func EnsureIndexesOnStartup() {
-	if err := EnsureIndexes(); err != nil {
-		log.Fatalf("Failed to ensure indexes: %v", err)
+	if _, err := EnsureIndexes(context.Background()); err != nil {
+		logging.Fatal("Failed to ensure indexes", "error", err)
 	}
 }
 
 // CleanupDuplicateSKUs removes products with duplicate SKUs, keeping the most recent one
 func CleanupDuplicateSKUs() error {
-	log.Println("Checking for duplicate SKUs...")
+	slog.Info("Checking for duplicate SKUs...")
 
 	collection := GetCollection("products")
 	ctx, cancel := global.GetDefaultTimer()
@@ -286,11 +942,11 @@ func CleanupDuplicateSKUs() error {
 	}
 
 	if len(duplicates) == 0 {
-		log.Println("✓ No duplicate SKUs found")
+		slog.Info("No duplicate SKUs found")
 		return nil
 	}
 
-	log.Printf("Found %d SKUs with duplicates, cleaning up...", len(duplicates))
+	slog.Info("Found SKUs with duplicates, cleaning up...", "count", len(duplicates))
 
 	for _, dup := range duplicates {
 		sku := dup["_id"].(string)
@@ -300,13 +956,13 @@ func CleanupDuplicateSKUs() error {
 		for i := 1; i < len(ids); i++ {
 			_, err := collection.DeleteOne(ctx, bson.D{{"_id", ids[i]}})
 			if err != nil {
-				log.Printf("Error deleting duplicate product with ID %v: %v", ids[i], err)
+				slog.Error("Error deleting duplicate product", "id", ids[i], "error", err)
 				continue
 			}
-			log.Printf("✓ Deleted duplicate product with SKU %s", sku)
+			slog.Info("Deleted duplicate product", "sku", sku)
 		}
 	}
 
-	log.Println("✓ Duplicate SKU cleanup completed")
+	slog.Info("Duplicate SKU cleanup completed")
 	return nil
 }
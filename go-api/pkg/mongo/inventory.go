@@ -0,0 +1,367 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// InventoryResult is a page of product stock levels
+type InventoryResult struct {
+	Items      []models.InventoryItem `json:"items"`
+	Pagination global.PaginationInfo  `json:"pagination"`
+}
+
+// inventoryProjection limits product documents to the fields in InventoryItem
+var inventoryProjection = bson.D{
+	{Key: "sku", Value: 1},
+	{Key: "name", Value: 1},
+	{Key: "category", Value: 1},
+	{Key: "status", Value: 1},
+	{Key: "stock", Value: 1},
+	{Key: "updated_at", Value: 1},
+}
+
+// InventoryFilter narrows the inventory listing. Zero values mean no filter.
+type InventoryFilter struct {
+	Category          string
+	Status            string // product status; deleted products are always excluded
+	Warehouse         string // only products stocked in this warehouse; also the field checked by LowStockThreshold
+	LowStockThreshold int    // products at or below this quantity, lowest first
+}
+
+// GetInventory returns a paginated stock view of non-deleted products
+func GetInventory(ctx context.Context, inventoryFilter InventoryFilter, page int, limit int) (*InventoryResult, error) {
+	collection := GetCollection("products")
+
+	filter := bson.D{{Key: "status", Value: bson.D{{Key: "$ne", Value: "deleted"}}}}
+	if inventoryFilter.Status != "" {
+		filter = bson.D{{Key: "status", Value: inventoryFilter.Status}}
+	}
+	if inventoryFilter.Category != "" {
+		filter = append(filter, bson.E{Key: "category", Value: inventoryFilter.Category})
+	}
+
+	stockField := "stock.total"
+	if inventoryFilter.Warehouse != "" {
+		stockField = "stock." + inventoryFilter.Warehouse
+	}
+
+	sort := bson.D{{Key: "sku", Value: 1}}
+	switch {
+	case inventoryFilter.LowStockThreshold > 0:
+		filter = append(filter, bson.E{Key: stockField, Value: bson.D{{Key: "$lte", Value: inventoryFilter.LowStockThreshold}}})
+		sort = bson.D{{Key: stockField, Value: 1}, {Key: "sku", Value: 1}}
+	case inventoryFilter.Warehouse != "":
+		filter = append(filter, bson.E{Key: stockField, Value: bson.D{{Key: "$gt", Value: 0}}})
+	}
+
+	totalCount, err := collection.CountDocuments(ctx, filter, options.Count().SetCollation(collation(ctx)))
+	if err != nil {
+		return nil, err
+	}
+
+	skip := (page - 1) * limit
+
+	findOptions := options.Find().
+		SetProjection(inventoryProjection).
+		SetSort(sort).
+		SetCollation(collation(ctx)).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := []models.InventoryItem{}
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	return &InventoryResult{
+		Items:      items,
+		Pagination: global.NewPagination(page, limit, int(totalCount)),
+	}, nil
+}
+
+// GetInventoryBySKU returns a product's stock levels with its 10 most recent inventory changes
+func GetInventoryBySKU(ctx context.Context, sku string) (*models.InventoryDetail, error) {
+	var item models.InventoryItem
+	err := GetCollection("products").FindOne(ctx,
+		bson.D{{Key: "sku", Value: sku}},
+		options.FindOne().SetProjection(inventoryProjection),
+	).Decode(&item)
+	if err != nil {
+		return nil, notFoundError(err, "product")
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+		SetLimit(10)
+
+	cursor, err := GetCollection("inventory_logs").Find(ctx, bson.D{{Key: "sku", Value: sku}}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	logs := []models.InventoryLog{}
+	if err := cursor.All(ctx, &logs); err != nil {
+		return nil, err
+	}
+
+	return &models.InventoryDetail{InventoryItem: item, RecentLogs: logs}, nil
+}
+
+// InventoryHistoryFilter narrows the inventory log history. Zero values mean no filter.
+type InventoryHistoryFilter struct {
+	StartDate  *time.Time
+	EndDate    *time.Time // exclusive
+	Warehouse  string
+	ChangeType string
+}
+
+// InventoryHistoryResult is a page of inventory log entries
+type InventoryHistoryResult struct {
+	Logs       []models.InventoryLog `json:"logs"`
+	Pagination global.PaginationInfo `json:"pagination"`
+}
+
+// GetInventoryHistory returns a product's inventory changes, newest first, using the SKU history index
+func GetInventoryHistory(ctx context.Context, sku string, historyFilter InventoryHistoryFilter, page int, limit int) (*InventoryHistoryResult, error) {
+	collection := GetCollection("inventory_logs")
+
+	filter := bson.D{{Key: "sku", Value: sku}}
+	if historyFilter.StartDate != nil || historyFilter.EndDate != nil {
+		dateFilter := bson.D{}
+		if historyFilter.StartDate != nil {
+			dateFilter = append(dateFilter, bson.E{Key: "$gte", Value: *historyFilter.StartDate})
+		}
+		if historyFilter.EndDate != nil {
+			dateFilter = append(dateFilter, bson.E{Key: "$lt", Value: *historyFilter.EndDate})
+		}
+		filter = append(filter, bson.E{Key: "timestamp", Value: dateFilter})
+	}
+	if historyFilter.Warehouse != "" {
+		filter = append(filter, bson.E{Key: "warehouse", Value: historyFilter.Warehouse})
+	}
+	if historyFilter.ChangeType != "" {
+		filter = append(filter, bson.E{Key: "change_type", Value: historyFilter.ChangeType})
+	}
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := (page - 1) * limit
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	logs := []models.InventoryLog{}
+	if err := cursor.All(ctx, &logs); err != nil {
+		return nil, err
+	}
+
+	return &InventoryHistoryResult{
+		Logs:       logs,
+		Pagination: global.NewPagination(page, limit, int(totalCount)),
+	}, nil
+}
+
+// AdjustInventory atomically applies a relative stock change to one warehouse and logs it.
+// Removals only succeed while the warehouse holds enough stock.
+func AdjustInventory(ctx context.Context, req *models.AdjustInventoryRequest) (*models.Product, *models.InventoryLog, error) {
+	var product *models.Product
+	var inventoryLog models.InventoryLog
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		product, err = applyStockChange(ctx, req.SKU, req.Warehouse, req.Quantity)
+		if err != nil {
+			return err
+		}
+		if err := enqueueEvent(ctx, events.StockChanged, events.StockChangedEvent{SKU: product.SKU, Reason: req.ChangeType, PreviousTotal: product.Stock.Total - req.Quantity, Product: product}); err != nil {
+			return err
+		}
+
+		after := product.Stock.Warehouse(req.Warehouse)
+		inventoryLog = models.InventoryLog{
+			ProductID:       product.ID,
+			SKU:             product.SKU,
+			Warehouse:       req.Warehouse,
+			ChangeType:      req.ChangeType,
+			QuantityBefore:  after - req.Quantity,
+			QuantityAfter:   after,
+			QuantityChanged: req.Quantity,
+			Reason:          req.Reason,
+			PerformedBy:     req.PerformedBy,
+			Notes:           req.Notes,
+			CreatedAt:       time.Now(),
+		}
+		return insertInventoryLogs(ctx, []models.InventoryLog{inventoryLog})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return product, &inventoryLog, nil
+}
+
+// applyStockChange increments one warehouse and the total by quantity, returning the updated product.
+// Negative changes only match while the warehouse holds enough stock.
+func applyStockChange(ctx context.Context, sku, warehouse string, quantity int) (*models.Product, error) {
+	collection := GetCollection("products")
+	stockField := "stock." + warehouse
+
+	filter := bson.D{
+		{Key: "sku", Value: sku},
+		{Key: "status", Value: bson.D{{Key: "$ne", Value: "deleted"}}},
+	}
+	if quantity < 0 {
+		filter = append(filter, bson.E{Key: stockField, Value: bson.D{{Key: "$gte", Value: -quantity}}})
+	}
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{
+			{Key: stockField, Value: quantity},
+			{Key: "stock.total", Value: quantity},
+		}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+	}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var product models.Product
+	err := collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&product)
+	if err != nil {
+		if isNoDocuments(err) {
+			if _, lookupErr := GetProductBySKU(ctx, sku); lookupErr != nil {
+				return nil, notFound("product")
+			}
+			return nil, fmt.Errorf("%w in warehouse", ErrInsufficientStock)
+		}
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// SetInventoryLevels sets absolute stock levels for the given warehouses, recomputes the total
+// and logs one adjustment per warehouse whose level changed
+func SetInventoryLevels(ctx context.Context, sku string, req *models.SetInventoryLevelsRequest) (*models.Product, []models.InventoryLog, error) {
+	collection := GetCollection("products")
+
+	levels := req.Levels()
+	if len(levels) == 0 {
+		return nil, nil, errors.New("no fields to update")
+	}
+
+	now := time.Now()
+	setLevels := bson.D{{Key: "updated_at", Value: now}}
+	for _, warehouse := range models.Warehouses {
+		if level, ok := levels[warehouse]; ok {
+			setLevels = append(setLevels, bson.E{Key: "stock." + warehouse, Value: level})
+		}
+	}
+
+	// Pipeline update so the total is recomputed from the new warehouse levels in the same write
+	update := bson.A{
+		bson.D{{Key: "$set", Value: setLevels}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "stock.total", Value: bson.D{{Key: "$add", Value: bson.A{
+			"$stock.warehouse_main", "$stock.warehouse_east", "$stock.warehouse_west",
+		}}}}}}},
+	}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
+	var product models.Product
+	var before models.Stock
+	var logs []models.InventoryLog
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		err := collection.FindOneAndUpdate(ctx,
+			bson.D{{Key: "sku", Value: sku}, {Key: "status", Value: bson.D{{Key: "$ne", Value: "deleted"}}}},
+			update,
+			findOptions,
+		).Decode(&product)
+		if err != nil {
+			return notFoundError(err, "product")
+		}
+
+		// Reflect the written levels on the returned product
+		before = product.Stock
+		if req.WarehouseMain != nil {
+			product.Stock.WarehouseMain = *req.WarehouseMain
+		}
+		if req.WarehouseEast != nil {
+			product.Stock.WarehouseEast = *req.WarehouseEast
+		}
+		if req.WarehouseWest != nil {
+			product.Stock.WarehouseWest = *req.WarehouseWest
+		}
+		product.CalculateTotalStock()
+		product.UpdatedAt = now
+
+		if err := enqueueEvent(ctx, events.StockChanged, events.StockChangedEvent{SKU: product.SKU, Reason: "adjustment", PreviousTotal: before.Total, Product: &product}); err != nil {
+			return err
+		}
+
+		logs = []models.InventoryLog{}
+		for _, warehouse := range models.Warehouses {
+			level, ok := levels[warehouse]
+			if !ok || level == before.Warehouse(warehouse) {
+				continue
+			}
+			logs = append(logs, models.InventoryLog{
+				ProductID:       product.ID,
+				SKU:             product.SKU,
+				Warehouse:       warehouse,
+				ChangeType:      "adjustment",
+				QuantityBefore:  before.Warehouse(warehouse),
+				QuantityAfter:   level,
+				QuantityChanged: level - before.Warehouse(warehouse),
+				Reason:          req.Reason,
+				PerformedBy:     req.PerformedBy,
+				Notes:           req.Notes,
+				CreatedAt:       now,
+			})
+		}
+		return insertInventoryLogs(ctx, logs)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &product, logs, nil
+}
+
+// insertInventoryLogs writes inventory audit entries. Call it inside inTransaction with
+// the stock change it records, so a change is never left without its log.
+func insertInventoryLogs(ctx context.Context, logs []models.InventoryLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, len(logs))
+	for i := range logs {
+		documents[i] = logs[i]
+	}
+
+	_, err := GetCollection("inventory_logs").InsertMany(ctx, documents)
+	return err
+}
@@ -0,0 +1,208 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// InventoryRecountsResult is a page of inventory recounts
+type InventoryRecountsResult struct {
+	Recounts   []models.InventoryRecount `json:"recounts"`
+	Pagination global.PaginationInfo     `json:"pagination"`
+}
+
+// CreateInventoryRecount records a physical count and its variance against the recorded stock
+// and logs it as a recount. A variance with auto-correct is queued for approval.
+func CreateInventoryRecount(ctx context.Context, sku string, req *models.CreateRecountRequest) (*models.InventoryRecount, error) {
+	product, err := GetProductBySKU(ctx, sku)
+	if err != nil {
+		return nil, notFoundError(err, "product")
+	}
+
+	recorded := product.Stock.Warehouse(req.Warehouse)
+	recount := &models.InventoryRecount{
+		ProductID:        product.ID,
+		SKU:              product.SKU,
+		Warehouse:        req.Warehouse,
+		RecordedQuantity: recorded,
+		CountedQuantity:  *req.CountedQuantity,
+		Variance:         *req.CountedQuantity - recorded,
+		Status:           "recorded",
+		CountedBy:        req.CountedBy,
+		Notes:            req.Notes,
+		CreatedAt:        time.Now(),
+	}
+
+	switch {
+	case recount.Variance == 0:
+		recount.Status = "matched"
+	case req.AutoCorrect:
+		recount.Status = "pending"
+	}
+
+	// Log the count itself; stock only changes once a pending correction is approved
+	reason := "Cycle count matched recorded stock"
+	if recount.Variance != 0 {
+		reason = fmt.Sprintf("Cycle count found variance of %+d (counted %d)", recount.Variance, recount.CountedQuantity)
+	}
+	err = inTransaction(ctx, func(ctx context.Context) error {
+		result, err := GetCollection("inventory_recounts").InsertOne(ctx, recount)
+		if err != nil {
+			return err
+		}
+		recount.ID = result.InsertedID.(bson.ObjectID)
+
+		return insertInventoryLogs(ctx, []models.InventoryLog{{
+			ProductID:       product.ID,
+			SKU:             product.SKU,
+			Warehouse:       req.Warehouse,
+			ChangeType:      "recount",
+			QuantityBefore:  recorded,
+			QuantityAfter:   recorded,
+			QuantityChanged: 0,
+			Reason:          reason,
+			PerformedBy:     req.CountedBy,
+			Notes:           req.Notes,
+			CreatedAt:       recount.CreatedAt,
+		}})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recount, nil
+}
+
+// GetInventoryRecounts returns recounts, newest first, optionally filtered by status
+func GetInventoryRecounts(ctx context.Context, status string, page int, limit int) (*InventoryRecountsResult, error) {
+	collection := GetCollection("inventory_recounts")
+
+	filter := bson.D{}
+	if status != "" {
+		filter = append(filter, bson.E{Key: "status", Value: status})
+	}
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := (page - 1) * limit
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	recounts := []models.InventoryRecount{}
+	if err := cursor.All(ctx, &recounts); err != nil {
+		return nil, err
+	}
+
+	return &InventoryRecountsResult{
+		Recounts:   recounts,
+		Pagination: global.NewPagination(page, limit, int(totalCount)),
+	}, nil
+}
+
+// ApproveInventoryRecount applies a pending recount's variance to the warehouse stock and logs it.
+// The variance is applied relative to current stock so movements since the count are preserved.
+func ApproveInventoryRecount(ctx context.Context, recountID bson.ObjectID, req *models.ReviewRecountRequest) (*models.InventoryRecount, *models.Product, error) {
+	var recount *models.InventoryRecount
+	var product *models.Product
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		recount, err = claimPendingRecount(ctx, recountID, "applied", req)
+		if err != nil {
+			return err
+		}
+
+		product, err = applyStockChange(ctx, recount.SKU, recount.Warehouse, recount.Variance)
+		if err != nil {
+			// Put the recount back in the queue so it can be retried or rejected
+			_, _ = GetCollection("inventory_recounts").UpdateOne(ctx,
+				bson.D{{Key: "_id", Value: recountID}},
+				bson.D{
+					{Key: "$set", Value: bson.D{{Key: "status", Value: "pending"}}},
+					{Key: "$unset", Value: bson.D{{Key: "reviewed_by", Value: ""}, {Key: "review_notes", Value: ""}, {Key: "reviewed_at", Value: ""}}},
+				},
+			)
+			return err
+		}
+
+		if err := enqueueEvent(ctx, events.StockChanged, events.StockChangedEvent{SKU: product.SKU, Reason: "recount", PreviousTotal: product.Stock.Total - recount.Variance, Product: product}); err != nil {
+			return err
+		}
+
+		after := product.Stock.Warehouse(recount.Warehouse)
+		return insertInventoryLogs(ctx, []models.InventoryLog{{
+			ProductID:       product.ID,
+			SKU:             product.SKU,
+			Warehouse:       recount.Warehouse,
+			ChangeType:      "recount",
+			QuantityBefore:  after - recount.Variance,
+			QuantityAfter:   after,
+			QuantityChanged: recount.Variance,
+			Reason:          fmt.Sprintf("Cycle count correction (counted %d, recorded %d)", recount.CountedQuantity, recount.RecordedQuantity),
+			PerformedBy:     req.ReviewedBy,
+			Notes:           req.Notes,
+			CreatedAt:       time.Now(),
+		}})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return recount, product, nil
+}
+
+// RejectInventoryRecount closes a pending recount without changing stock
+func RejectInventoryRecount(ctx context.Context, recountID bson.ObjectID, req *models.ReviewRecountRequest) (*models.InventoryRecount, error) {
+	return claimPendingRecount(ctx, recountID, "rejected", req)
+}
+
+// claimPendingRecount atomically moves a pending recount to its reviewed status
+func claimPendingRecount(ctx context.Context, recountID bson.ObjectID, status string, req *models.ReviewRecountRequest) (*models.InventoryRecount, error) {
+	collection := GetCollection("inventory_recounts")
+
+	now := time.Now()
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: status},
+		{Key: "reviewed_by", Value: req.ReviewedBy},
+		{Key: "review_notes", Value: req.Notes},
+		{Key: "reviewed_at", Value: now},
+	}}}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var recount models.InventoryRecount
+	err := collection.FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: recountID}, {Key: "status", Value: "pending"}},
+		update,
+		findOptions,
+	).Decode(&recount)
+	if err != nil {
+		if isNoDocuments(err) {
+			if count, _ := collection.CountDocuments(ctx, bson.D{{Key: "_id", Value: recountID}}); count == 0 {
+				return nil, notFound("recount")
+			}
+			return nil, errors.New("recount is not pending approval")
+		}
+		return nil, err
+	}
+
+	return &recount, nil
+}
@@ -0,0 +1,219 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// RedeemLoyaltyPoints spends a customer's points as a discount on one of their pending
+// orders. Points can be redeemed once per order, and only up to the amount still due.
+func RedeemLoyaltyPoints(ctx context.Context, customerID bson.ObjectID, orderNumber string, points int) (*models.Order, *models.LoyaltyTransaction, error) {
+	var order *models.Order
+	var transaction *models.LoyaltyTransaction
+
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		order, err = GetOrderByNumber(ctx, orderNumber)
+		if err != nil {
+			return notFoundError(err, "order")
+		}
+		if order.CustomerID != customerID {
+			return notFound("order")
+		}
+		if order.Status != "pending" {
+			return errors.New("order is not pending")
+		}
+		if order.LoyaltyPoints > 0 {
+			return errors.New("loyalty points already redeemed on this order")
+		}
+
+		discount := models.LoyaltyPointsValue(points)
+		previousTotal := order.Totals.GrandTotal
+		order.Totals.Loyalty = discount
+		order.LoyaltyPoints = points
+		order.CalculateTotals()
+		if order.Totals.Subtotal-order.Totals.Discount-order.Totals.Tier-order.Totals.Loyalty < 0 || order.Totals.GrandTotal <= 0 || order.Totals.AmountDue < 0 {
+			return errors.New("points exceed the amount due")
+		}
+
+		// Only deduct if the balance still covers the points at write time
+		var customer models.Customer
+		err = GetCollection("customers").FindOneAndUpdate(ctx,
+			bson.D{{Key: "_id", Value: customerID}, {Key: "loyalty_points", Value: bson.D{{Key: "$gte", Value: points}}}},
+			bson.D{
+				{Key: "$inc", Value: bson.D{{Key: "loyalty_points", Value: -points}}},
+				{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+			},
+			options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.D{{Key: "loyalty_points", Value: 1}}),
+		).Decode(&customer)
+		if err != nil {
+			if isNoDocuments(err) {
+				return errors.New("insufficient loyalty points")
+			}
+			return err
+		}
+
+		order.UpdatedAt = time.Now()
+		result, err := GetCollection("orders").UpdateOne(ctx,
+			bson.D{
+				{Key: "order_number", Value: orderNumber},
+				{Key: "status", Value: "pending"},
+				{Key: "loyalty_points_redeemed", Value: bson.D{{Key: "$exists", Value: false}}},
+			},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "totals", Value: order.Totals},
+				{Key: "loyalty_points_redeemed", Value: points},
+				{Key: "updated_at", Value: order.UpdatedAt},
+			}}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errors.New("order is not pending")
+		}
+
+		// The customer's total spend counted the order's old grand total
+		_, err = GetCollection("customers").UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: customerID}},
+			bson.D{{Key: "$inc", Value: bson.D{{Key: "total_spent", Value: order.Totals.GrandTotal - previousTotal}}}},
+		)
+		if err != nil {
+			return err
+		}
+
+		transaction = &models.LoyaltyTransaction{
+			CustomerID:   customerID,
+			Type:         models.LoyaltyRedeem,
+			Points:       -points,
+			BalanceAfter: customer.LoyaltyPoints,
+			OrderNumber:  orderNumber,
+			Amount:       discount,
+			CreatedAt:    time.Now(),
+		}
+		inserted, err := GetCollection("loyalty_transactions").InsertOne(ctx, transaction)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return errors.New("loyalty points already redeemed on this order")
+			}
+			return err
+		}
+		transaction.ID = inserted.InsertedID.(bson.ObjectID)
+
+		// The discount changes the order's totals
+		return enqueueEvent(ctx, events.OrderUpdated, events.OrderUpdatedEvent{Order: order})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return order, transaction, nil
+}
+
+// AwardLoyaltyPoints credits the customer of a delivered order with the points it
+// earns. It returns the points awarded, or 0 when the order earns none or was already
+// credited.
+func AwardLoyaltyPoints(ctx context.Context, orderNumber string) (int, error) {
+	order, err := GetOrderByNumber(ctx, orderNumber)
+	if err != nil {
+		return 0, err
+	}
+	if order.Status != "delivered" {
+		return 0, nil
+	}
+	return creditLoyaltyPoints(ctx, order, models.LoyaltyEarn, models.LoyaltyPointsEarned(order))
+}
+
+// RefundLoyaltyPoints returns the points redeemed on a cancelled order. It returns the
+// points refunded, or 0 when none were redeemed or they were already refunded.
+func RefundLoyaltyPoints(ctx context.Context, orderNumber string) (int, error) {
+	order, err := GetOrderByNumber(ctx, orderNumber)
+	if err != nil {
+		return 0, err
+	}
+	if order.Status != "cancelled" {
+		return 0, nil
+	}
+	return creditLoyaltyPoints(ctx, order, models.LoyaltyRefund, order.LoyaltyPoints)
+}
+
+// creditLoyaltyPoints adds points to the order's customer. The ledger entry is written
+// first, and its unique index on order and type makes a repeated credit a no-op even
+// without transactions.
+func creditLoyaltyPoints(ctx context.Context, order *models.Order, txType string, points int) (int, error) {
+	if points <= 0 {
+		return 0, nil
+	}
+
+	ledger := GetCollection("loyalty_transactions")
+	transaction := models.LoyaltyTransaction{
+		CustomerID:  order.CustomerID,
+		Type:        txType,
+		Points:      points,
+		OrderNumber: order.OrderNumber,
+		CreatedAt:   time.Now(),
+	}
+	result, err := ledger.InsertOne(ctx, transaction)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var customer models.Customer
+	err = GetCollection("customers").FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: order.CustomerID}},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "loyalty_points", Value: points}}},
+			{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.D{{Key: "loyalty_points", Value: 1}}),
+	).Decode(&customer)
+	if err != nil {
+		// Drop the ledger entry so a retry can credit the points
+		_, _ = ledger.DeleteOne(ctx, bson.D{{Key: "_id", Value: result.InsertedID}})
+		return 0, notFoundError(err, "customer")
+	}
+
+	_, err = ledger.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: result.InsertedID}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "balance_after", Value: customer.LoyaltyPoints}}}},
+	)
+	return points, err
+}
+
+// GetLoyaltyTransactions returns a page of a customer's loyalty ledger, newest first,
+// and the total number of entries
+func GetLoyaltyTransactions(ctx context.Context, customerID bson.ObjectID, page, limit int) ([]models.LoyaltyTransaction, int64, error) {
+	collection := GetCollection("loyalty_transactions")
+	filter := bson.D{{Key: "customer_id", Value: customerID}}
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	transactions := []models.LoyaltyTransaction{}
+	if err := cursor.All(ctx, &transactions); err != nil {
+		return nil, 0, err
+	}
+	return transactions, total, nil
+}
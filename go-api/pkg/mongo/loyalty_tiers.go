@@ -0,0 +1,76 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// GetLoyaltyTierRules returns the saved loyalty tier rules, or the defaults when an
+// admin has not saved any
+func GetLoyaltyTierRules(ctx context.Context) (*models.LoyaltyTierRules, error) {
+	var rules models.LoyaltyTierRules
+	err := GetCollection("settings").FindOne(ctx, bson.D{{Key: "_id", Value: models.LoyaltyTierRulesID}}).Decode(&rules)
+	if err != nil {
+		if isNoDocuments(err) {
+			return models.DefaultLoyaltyTierRules(), nil
+		}
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// LoadLoyaltyTierRules applies the saved loyalty tier rules to cart and order totals
+func LoadLoyaltyTierRules(ctx context.Context) error {
+	rules, err := GetLoyaltyTierRules(ctx)
+	if err != nil {
+		return err
+	}
+	models.SetLoyaltyTierRules(rules)
+	return nil
+}
+
+// UpdateLoyaltyTierRules replaces the loyalty tier rules. Every instance applies them
+// when it receives the loyalty_tiers.updated event.
+func UpdateLoyaltyTierRules(ctx context.Context, tiers []models.LoyaltyTierBenefits) (*models.LoyaltyTierRules, error) {
+	rules := &models.LoyaltyTierRules{
+		ID:        models.LoyaltyTierRulesID,
+		Tiers:     tiers,
+		UpdatedAt: time.Now(),
+	}
+
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		_, err := GetCollection("settings").ReplaceOne(ctx,
+			bson.D{{Key: "_id", Value: models.LoyaltyTierRulesID}},
+			rules,
+			options.Replace().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, events.LoyaltyTiersUpdated, events.LoyaltyTiersUpdatedEvent{Rules: rules})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	models.SetLoyaltyTierRules(rules)
+	return rules, nil
+}
+
+// GetLoyaltyTierByEmail returns the loyalty tier of the customer with email
+func GetLoyaltyTierByEmail(ctx context.Context, email string) (string, error) {
+	var customer models.Customer
+	err := GetCollection("customers").FindOne(ctx,
+		bson.D{{Key: "email", Value: email}},
+		options.FindOne().SetProjection(bson.D{{Key: "loyalty_points", Value: 1}}),
+	).Decode(&customer)
+	if err != nil {
+		return "", notFoundError(err, "customer")
+	}
+	return customer.CalculateLoyaltyTier(), nil
+}
@@ -0,0 +1,250 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/notifications"
+)
+
+// NotificationRetention is how long sent and skipped notifications are kept. Dead-lettered ones stay
+// until they are requeued.
+const NotificationRetention = 30 * 24 * time.Hour
+
+// NotificationFilter narrows the admin notification listing
+type NotificationFilter struct {
+	Status    string
+	Channel   string
+	Recipient string
+}
+
+// EnqueueNotification adds a notification to the delivery queue. Inside inTransaction
+// it is only queued if the surrounding write commits. A notification whose DedupeKey is
+// already queued is skipped and keeps a zero ID.
+func EnqueueNotification(ctx context.Context, notification *models.Notification) error {
+	result, err := GetCollection("notifications").InsertOne(ctx, notification)
+	if err != nil {
+		if notification.DedupeKey != "" && mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return err
+	}
+	notification.ID = result.InsertedID.(bson.ObjectID)
+	return nil
+}
+
+// QueueTemplatedNotification renders the current version of a template, or its
+// built-in default, and queues it for recipient once per dedupeKey
+func QueueTemplatedNotification(ctx context.Context, key, recipient, event, dedupeKey string, vars map[string]string) (*models.Notification, error) {
+	template, err := GetTemplate(ctx, key, 0)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := template.Render(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	notification := models.NewNotification(rendered, recipient, event)
+	notification.DedupeKey = dedupeKey
+	if err := EnqueueNotification(ctx, notification); err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
+
+// QueueCustomerNotification renders a template for a customer and queues it to their
+// email address or phone, once per dedupeKey. The template gets an unsubscribe_url
+// variable for list, and the worker checks the customer's preferences again before sending.
+func QueueCustomerNotification(ctx context.Context, key string, customer *models.Customer, list, event, dedupeKey string, vars map[string]string) (*models.Notification, error) {
+	template, err := GetTemplate(ctx, key, 0)
+	if err != nil {
+		return nil, err
+	}
+	vars = maps.Clone(vars)
+	vars["unsubscribe_url"] = notifications.UnsubscribeURL(customer.ID, list)
+	rendered, err := template.Render(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient := customer.Email
+	if rendered.Channel == models.NotificationSMS {
+		recipient = customer.Phone
+	}
+	notification := models.NewNotification(rendered, recipient, event)
+	notification.DedupeKey = dedupeKey
+	notification.CustomerID = &customer.ID
+	notification.List = list
+	if err := EnqueueNotification(ctx, notification); err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
+
+// ClaimNotification leases the oldest pending notification that is due, hiding it from
+// other workers for lease. It returns nil when nothing is due.
+func ClaimNotification(ctx context.Context, lease time.Duration) (*models.Notification, error) {
+	collection := GetCollection("notifications")
+
+	now := time.Now()
+	filter := bson.D{
+		{Key: "status", Value: models.NotificationPending},
+		{Key: "available_at", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "available_at", Value: now.Add(lease)}}},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+	}
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "available_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var notification models.Notification
+	err := collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&notification)
+	if err != nil {
+		if isNoDocuments(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &notification, nil
+}
+
+// MarkNotificationSent records that a claimed notification was delivered
+func MarkNotificationSent(ctx context.Context, id bson.ObjectID) error {
+	_, err := GetCollection("notifications").UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "status", Value: models.NotificationSent}, {Key: "sent_at", Value: time.Now()}}},
+			{Key: "$unset", Value: bson.D{{Key: "last_error", Value: ""}}},
+		},
+	)
+	return err
+}
+
+// SkipNotification records that a claimed notification won't be sent, and why
+func SkipNotification(ctx context.Context, id bson.ObjectID, reason string) error {
+	_, err := GetCollection("notifications").UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: models.NotificationSkipped},
+			{Key: "last_error", Value: reason},
+			{Key: "skipped_at", Value: time.Now()},
+		}}},
+	)
+	return err
+}
+
+// RetryNotification records a failed delivery. The notification is claimable again at
+// retryAt, or dead-lettered when it has used maxAttempts or permanent is set.
+func RetryNotification(ctx context.Context, notification *models.Notification, deliveryErr error, retryAt time.Time, maxAttempts int, permanent bool) error {
+	set := bson.D{
+		{Key: "status", Value: models.NotificationPending},
+		{Key: "last_error", Value: deliveryErr.Error()},
+		{Key: "available_at", Value: retryAt},
+	}
+	if permanent || notification.Attempts >= maxAttempts {
+		set[0].Value = models.NotificationDead
+		set = append(set, bson.E{Key: "dead_at", Value: time.Now()})
+	}
+
+	_, err := GetCollection("notifications").UpdateOne(ctx, bson.D{{Key: "_id", Value: notification.ID}}, bson.D{{Key: "$set", Value: set}})
+	return err
+}
+
+// RequeueNotification moves a dead-lettered notification back into the queue with a
+// fresh set of attempts
+func RequeueNotification(ctx context.Context, id bson.ObjectID) (*models.Notification, error) {
+	collection := GetCollection("notifications")
+
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: models.NotificationPending},
+			{Key: "attempts", Value: 0},
+			{Key: "available_at", Value: time.Now()},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "dead_at", Value: ""}}},
+	}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var notification models.Notification
+	err := collection.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: id}, {Key: "status", Value: models.NotificationDead}}, update, findOptions).Decode(&notification)
+	if err != nil {
+		if isNoDocuments(err) {
+			count, countErr := collection.CountDocuments(ctx, bson.D{{Key: "_id", Value: id}})
+			if countErr == nil && count > 0 {
+				return nil, errors.New("notification is not dead-lettered")
+			}
+			return nil, notFound("notification")
+		}
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// GetNotifications lists notifications matching filter, newest first, with how many
+// there are in each status
+func GetNotifications(ctx context.Context, filter NotificationFilter, page, limit int) ([]models.Notification, int64, map[string]int64, error) {
+	collection := GetCollection("notifications")
+
+	query := bson.D{}
+	if filter.Status != "" {
+		query = append(query, bson.E{Key: "status", Value: filter.Status})
+	}
+	if filter.Channel != "" {
+		query = append(query, bson.E{Key: "channel", Value: filter.Channel})
+	}
+	if filter.Recipient != "" {
+		query = append(query, bson.E{Key: "recipient", Value: filter.Recipient})
+	}
+
+	total, err := collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []models.Notification{}
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, 0, nil, err
+	}
+
+	statusCursor, err := collection.Aggregate(ctx, bson.A{
+		bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$status"}, {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+	})
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer statusCursor.Close(ctx)
+
+	var statusCounts []struct {
+		Status string `bson:"_id"`
+		Count  int64  `bson:"count"`
+	}
+	if err := statusCursor.All(ctx, &statusCounts); err != nil {
+		return nil, 0, nil, err
+	}
+	counts := map[string]int64{models.NotificationPending: 0, models.NotificationSent: 0, models.NotificationDead: 0, models.NotificationSkipped: 0}
+	for _, statusCount := range statusCounts {
+		counts[statusCount.Status] = statusCount.Count
+	}
+
+	return notifications, total, counts, nil
+}
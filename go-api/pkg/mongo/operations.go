@@ -0,0 +1,63 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+)
+
+// Operation is a kind of database work with its own read preference and deadline, so
+// long reports can run on a secondary without loosening the deadline on order writes
+type Operation struct {
+	readPreference *readpref.ReadPref
+	timeout        time.Duration
+}
+
+var (
+	// Transactional work, such as placing an order or adjusting stock, runs on the
+	// primary under a tight deadline so a stuck write fails fast. inTransaction applies it.
+	Transactional = &Operation{readPreference: readpref.Primary(), timeout: 5 * time.Second}
+	// Analytics aggregations may read a slightly stale secondary and run longer
+	Analytics = &Operation{readPreference: readpref.SecondaryPreferred(), timeout: 30 * time.Second}
+	// Bulk work reads or writes whole collections: customer data exports and seeding.
+	// Writes always go to the primary whatever the read preference.
+	Bulk = &Operation{readPreference: readpref.SecondaryPreferred(), timeout: 2 * time.Minute}
+)
+
+// configureOperations sets each operation's read preference and timeout from cfg
+func configureOperations(cfg config.MongoConfig) {
+	for _, op := range []struct {
+		operation      *Operation
+		readPreference string
+		timeout        time.Duration
+	}{
+		{Transactional, "primary", cfg.TransactionTimeout},
+		{Analytics, cfg.AnalyticsReadPreference, cfg.AnalyticsTimeout},
+		{Bulk, cfg.BulkReadPreference, cfg.BulkTimeout},
+	} {
+		// The config only allows valid modes, so this can't fail
+		if mode, err := readpref.ModeFromString(op.readPreference); err == nil {
+			op.operation.readPreference, _ = readpref.New(mode)
+		}
+		if op.timeout > 0 {
+			op.operation.timeout = op.timeout
+		}
+	}
+}
+
+// Context bounds parent by the operation's timeout. An earlier deadline on parent, such
+// as a worker's, still applies.
+func (op *Operation) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, op.timeout)
+}
+
+// Collection returns a collection handle that reads with the operation's read
+// preference, plus any other collection options. Inside a transaction the
+// transaction's primary read preference wins.
+func (op *Operation) Collection(name string, opts ...options.Lister[options.CollectionOptions]) *mongo.Collection {
+	return GetDatabase().Collection(name, append(opts, options.Collection().SetReadPreference(op.readPreference))...)
+}
@@ -0,0 +1,101 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// OutboxRetention is how long delivered outbox events are kept
+const OutboxRetention = 7 * 24 * time.Hour
+
+// enqueueEvent stores a domain event in the outbox. Call it inside inTransaction so the
+// event is only recorded if the write that produced it commits.
+func enqueueEvent(ctx context.Context, eventType string, data interface{}) error {
+	eventID, payload, err := events.Encode(ctx, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = GetCollection("outbox").InsertOne(ctx, models.OutboxEvent{
+		EventID:     eventID,
+		Type:        eventType,
+		Payload:     string(payload),
+		Status:      models.OutboxPending,
+		AvailableAt: now,
+		CreatedAt:   now,
+	})
+	return err
+}
+
+// EnqueueEvent stores a domain event that doesn't accompany a write, such as the result
+// of a scheduled scan
+func EnqueueEvent(ctx context.Context, eventType string, data interface{}) error {
+	return enqueueEvent(ctx, eventType, data)
+}
+
+// ClaimOutboxEvent leases the oldest pending event that is due, hiding it from other
+// relays for lease. It returns nil when nothing is due.
+func ClaimOutboxEvent(ctx context.Context, lease time.Duration) (*models.OutboxEvent, error) {
+	collection := GetCollection("outbox")
+
+	now := time.Now()
+	filter := bson.D{
+		{Key: "status", Value: models.OutboxPending},
+		{Key: "available_at", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "available_at", Value: now.Add(lease)}}},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+	}
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var event models.OutboxEvent
+	err := collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&event)
+	if err != nil {
+		if isNoDocuments(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// MarkOutboxEventDelivered records that a claimed event was published
+func MarkOutboxEventDelivered(ctx context.Context, id bson.ObjectID) error {
+	_, err := GetCollection("outbox").UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "status", Value: models.OutboxDelivered}, {Key: "delivered_at", Value: time.Now()}}},
+			{Key: "$unset", Value: bson.D{{Key: "last_error", Value: ""}}},
+		},
+	)
+	return err
+}
+
+// RetryOutboxEvent records a failed delivery. The event is claimable again at retryAt,
+// or marked failed when it has used maxAttempts.
+func RetryOutboxEvent(ctx context.Context, event *models.OutboxEvent, deliveryErr error, retryAt time.Time, maxAttempts int) error {
+	status := models.OutboxPending
+	if event.Attempts >= maxAttempts {
+		status = models.OutboxFailed
+	}
+
+	_, err := GetCollection("outbox").UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: event.ID}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: status},
+			{Key: "last_error", Value: deliveryErr.Error()},
+			{Key: "available_at", Value: retryAt},
+		}}},
+	)
+	return err
+}
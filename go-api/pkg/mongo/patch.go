@@ -0,0 +1,50 @@
+package mongo
+
+import (
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// patchPipeline turns a map of field paths into a pipeline update. Nil values unset
+// their path and every other value is set as a literal, so user strings that start
+// with "$" are never read as expressions. Touching stock recomputes stock.total from
+// the warehouse levels in the same write.
+func patchPipeline(updates map[string]interface{}) bson.A {
+	paths := make([]string, 0, len(updates))
+	for path := range updates {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	set := bson.D{}
+	unset := bson.A{}
+	touchesStock := false
+	for _, path := range paths {
+		if path == "stock" || strings.HasPrefix(path, "stock.") {
+			touchesStock = true
+		}
+		if updates[path] == nil {
+			unset = append(unset, path)
+			continue
+		}
+		set = append(set, bson.E{Key: path, Value: bson.D{{Key: "$literal", Value: updates[path]}}})
+	}
+
+	pipeline := bson.A{}
+	if len(set) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$set", Value: set}})
+	}
+	if len(unset) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$unset", Value: unset}})
+	}
+	if touchesStock {
+		pipeline = append(pipeline, bson.D{{Key: "$set", Value: bson.D{{Key: "stock.total", Value: bson.D{{Key: "$add", Value: bson.A{
+			bson.D{{Key: "$ifNull", Value: bson.A{"$stock.warehouse_main", 0}}},
+			bson.D{{Key: "$ifNull", Value: bson.A{"$stock.warehouse_east", 0}}},
+			bson.D{{Key: "$ifNull", Value: bson.A{"$stock.warehouse_west", 0}}},
+		}}}}}}})
+	}
+	return pipeline
+}
@@ -0,0 +1,209 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// SetOrderPaymentIntent records the Stripe PaymentIntent or PayPal order started for an
+// unpaid order
+func SetOrderPaymentIntent(ctx context.Context, orderNumber string, intentID string) error {
+	result, err := GetCollection("orders").UpdateOne(ctx,
+		bson.D{
+			{Key: "order_number", Value: orderNumber},
+			{Key: "payment.status", Value: bson.D{{Key: "$in", Value: bson.A{"pending", "failed"}}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "payment.intent_id", Value: intentID},
+			{Key: "updated_at", Value: time.Now()},
+		}}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrOrderPaid
+	}
+	return nil
+}
+
+// MarkOrderPaid records that transactionID paid amount for an order, stamping
+// timeline.paid_at. It returns nil for an order that was already paid, so a repeated
+// webhook or capture is a no-op, and ErrPaymentMismatch when the payment isn't for the
+// order's current intent or doesn't cover its amount due.
+func MarkOrderPaid(ctx context.Context, orderNumber string, intentID string, transactionID string, amount float64) (*models.Order, error) {
+	filter := bson.D{
+		{Key: "order_number", Value: orderNumber},
+		{Key: "payment.status", Value: bson.D{{Key: "$in", Value: bson.A{"pending", "failed"}}}},
+		{Key: "payment.intent_id", Value: intentID},
+		// Half a cent covers float rounding in the provider's amount
+		{Key: "$expr", Value: bson.D{{Key: "$lte", Value: bson.A{"$totals.amount_due", amount + 0.005}}}},
+	}
+	now := time.Now()
+	update := bson.A{
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "payment.status", Value: "completed"},
+			{Key: "payment.transaction_id", Value: transactionID},
+			{Key: "payment.amount", Value: amount},
+			{Key: "timeline.paid_at", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$timeline.paid_at", now}}}},
+			{Key: "updated_at", Value: now},
+		}}},
+		bson.D{{Key: "$unset", Value: "payment.failure_message"}},
+	}
+	updated, err := updateOrderPayment(ctx, orderNumber, filter, update)
+	if err != nil || updated != nil {
+		return updated, err
+	}
+
+	// Nothing matched: either the order is already paid, or the payment doesn't fit it
+	order, err := GetOrderByNumber(ctx, orderNumber)
+	if err != nil {
+		return nil, err
+	}
+	if order.Payment.Status != "pending" && order.Payment.Status != "failed" {
+		return nil, nil
+	}
+	if order.Payment.IntentID != intentID {
+		return nil, fmt.Errorf("payment %s is not the order's current intent: %w", intentID, ErrPaymentMismatch)
+	}
+	return nil, fmt.Errorf("paid %.2f of %.2f due: %w", amount, order.Totals.AmountDue, ErrPaymentMismatch)
+}
+
+// MarkOrderPaymentFailed records a declined payment on the order's current intent.
+// It returns nil when the order is already paid or has moved on to another intent.
+func MarkOrderPaymentFailed(ctx context.Context, orderNumber string, intentID string, message string) (*models.Order, error) {
+	filter := bson.D{
+		{Key: "order_number", Value: orderNumber},
+		{Key: "payment.status", Value: bson.D{{Key: "$in", Value: bson.A{"pending", "failed"}}}},
+		{Key: "payment.intent_id", Value: intentID},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "payment.status", Value: "failed"},
+		{Key: "payment.failure_message", Value: message},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	return updateOrderPayment(ctx, orderNumber, filter, update)
+}
+
+// RecordOrderRefund sets the total refunded on a paid order, marking the payment refunded
+// and returning any gift card redemption once all of it has been returned. refunded is
+// the provider's running total, so a webhook for an older refund is a no-op and returns
+// nil.
+func RecordOrderRefund(ctx context.Context, orderNumber string, refunded float64) (*models.Order, error) {
+	filter := bson.D{
+		{Key: "order_number", Value: orderNumber},
+		{Key: "payment.status", Value: bson.D{{Key: "$in", Value: bson.A{"completed", "refunded"}}}},
+		{Key: "payment.refunded", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: refunded}}}}},
+	}
+	update := bson.A{
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "payment.refunded", Value: refunded},
+			{Key: "totals.refunded", Value: refunded},
+			{Key: "payment.status", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$gte", Value: bson.A{refunded, "$payment.amount"}}}, "refunded", "completed",
+			}}}},
+			{Key: "updated_at", Value: time.Now()},
+		}}},
+	}
+	return updateOrderRefund(ctx, orderNumber, filter, update)
+}
+
+// AddOrderRefund records a refund issued through the provider on a paid order, raising
+// the refunded total from refundedBefore. Once all of it has been returned the payment
+// is marked refunded and any gift card redemption goes back to the card. A refund
+// webhook may have raised the total already, so the larger of the two is kept.
+// Recording the same refund twice is a no-op that returns nil.
+func AddOrderRefund(ctx context.Context, orderNumber string, refund models.OrderRefund, refundedBefore float64) (*models.Order, error) {
+	refunded := refundedBefore + refund.Amount
+	filter := bson.D{
+		{Key: "order_number", Value: orderNumber},
+		{Key: "payment.status", Value: bson.D{{Key: "$in", Value: bson.A{"completed", "refunded"}}}},
+		{Key: "refunds.id", Value: bson.D{{Key: "$ne", Value: refund.ID}}},
+	}
+	update := bson.A{
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "refunds", Value: bson.D{{Key: "$concatArrays", Value: bson.A{
+				bson.D{{Key: "$ifNull", Value: bson.A{"$refunds", bson.A{}}}},
+				bson.D{{Key: "$literal", Value: bson.A{refund}}},
+			}}}},
+			{Key: "payment.refunded", Value: bson.D{{Key: "$max", Value: bson.A{
+				bson.D{{Key: "$ifNull", Value: bson.A{"$payment.refunded", 0}}}, refunded,
+			}}}},
+			{Key: "updated_at", Value: time.Now()},
+		}}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "totals.refunded", Value: "$payment.refunded"},
+			{Key: "payment.status", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$gte", Value: bson.A{"$payment.refunded", "$payment.amount"}}}, "refunded", "completed",
+			}}}},
+		}}},
+	}
+	return updateOrderRefund(ctx, orderNumber, filter, update)
+}
+
+// updateOrderRefund applies a refund like updateOrderPayment and, once the payment has
+// been refunded in full, returns the order's gift card redemption in the same transaction
+func updateOrderRefund(ctx context.Context, orderNumber string, filter bson.D, update interface{}) (*models.Order, error) {
+	var updated *models.Order
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		updated, err = updateOrderPayment(ctx, orderNumber, filter, update)
+		if err != nil || updated == nil || updated.Payment.Status != "refunded" {
+			return err
+		}
+		return refundOrderGiftCard(ctx, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// GetOrderByTransaction finds the order a Stripe PaymentIntent or PayPal capture paid
+func GetOrderByTransaction(ctx context.Context, transactionID string) (*models.Order, error) {
+	var order models.Order
+	err := GetCollection("orders").FindOne(ctx, bson.D{{Key: "payment.transaction_id", Value: transactionID}}).Decode(&order)
+	if err != nil {
+		return nil, notFoundError(err, "order")
+	}
+	return &order, nil
+}
+
+// updateOrderPayment applies a payment update to the order matching filter along with
+// its order.payment_changed event. It returns nil when the filter no longer matches, and
+// "order not found" when there is no such order at all.
+func updateOrderPayment(ctx context.Context, orderNumber string, filter bson.D, update interface{}) (*models.Order, error) {
+	collection := GetCollection("orders")
+
+	var updated *models.Order
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		var order models.Order
+		err := collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&order)
+		if err != nil {
+			if !isNoDocuments(err) {
+				return err
+			}
+			count, err := collection.CountDocuments(ctx, bson.D{{Key: "order_number", Value: orderNumber}})
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				return notFound("order")
+			}
+			return nil
+		}
+
+		updated = &order
+		return enqueueEvent(ctx, events.OrderPaymentChanged, events.OrderPaymentChangedEvent{OrderNumber: orderNumber, Payment: order.Payment})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
@@ -0,0 +1,109 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// ListPriceAlerts returns a customer's price alerts, newest first
+func ListPriceAlerts(ctx context.Context, customerID bson.ObjectID) ([]models.PriceAlert, error) {
+	cursor, err := GetCollection("price_alerts").Find(ctx,
+		bson.D{{Key: "customer_id", Value: customerID}},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	alerts := []models.PriceAlert{}
+	if err := cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// SavePriceAlert watches a product's price for a customer. An alert the customer already
+// has for the product gets the new target price and is returned with false.
+func SavePriceAlert(ctx context.Context, customerID bson.ObjectID, product *models.Product, targetPrice *float64) (*models.PriceAlert, bool, error) {
+	collection := GetCollection("price_alerts")
+	filter := bson.D{{Key: "customer_id", Value: customerID}, {Key: "sku", Value: product.SKU}}
+
+	var alert models.PriceAlert
+	err := collection.FindOneAndUpdate(ctx, filter,
+		bson.D{{Key: "$set", Value: bson.D{{Key: "target_price", Value: targetPrice}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&alert)
+	if err == nil {
+		return &alert, false, nil
+	}
+	if !isNoDocuments(err) {
+		return nil, false, err
+	}
+
+	count, err := collection.CountDocuments(ctx, bson.D{{Key: "customer_id", Value: customerID}})
+	if err != nil {
+		return nil, false, err
+	}
+	if count >= models.MaxPriceAlerts {
+		return nil, false, limitReached("price alert")
+	}
+
+	alert = models.PriceAlert{
+		CustomerID:  customerID,
+		SKU:         product.SKU,
+		ProductName: product.Name,
+		Price:       product.Price,
+		TargetPrice: targetPrice,
+		CreatedAt:   time.Now(),
+	}
+	result, err := collection.InsertOne(ctx, &alert)
+	if err != nil {
+		return nil, false, err
+	}
+	alert.ID = result.InsertedID.(bson.ObjectID)
+	return &alert, true, nil
+}
+
+// DeletePriceAlert removes one of a customer's price alerts
+func DeletePriceAlert(ctx context.Context, customerID, alertID bson.ObjectID) error {
+	result, err := GetCollection("price_alerts").DeleteOne(ctx, bson.D{{Key: "_id", Value: alertID}, {Key: "customer_id", Value: customerID}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return notFound("price alert")
+	}
+	return nil
+}
+
+// GetPriceAlertsForSKU lists every alert on a product
+func GetPriceAlertsForSKU(ctx context.Context, sku string) ([]models.PriceAlert, error) {
+	cursor, err := GetCollection("price_alerts").Find(ctx, bson.D{{Key: "sku", Value: sku}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	alerts := []models.PriceAlert{}
+	if err := cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// RecordPriceAlertSent counts an alert's email
+func RecordPriceAlertSent(ctx context.Context, alertID bson.ObjectID) error {
+	_, err := GetCollection("price_alerts").UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: alertID}},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "alert_count", Value: 1}}},
+			{Key: "$set", Value: bson.D{{Key: "last_alert_at", Value: time.Now()}}},
+		},
+	)
+	return err
+}
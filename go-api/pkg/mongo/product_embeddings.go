@@ -0,0 +1,149 @@
+package mongo
+
+import (
+	"context"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// GetActiveProductsAfter returns the next batch of active products after lastID, ordered by _id
+func GetActiveProductsAfter(ctx context.Context, lastID bson.ObjectID, limit int) ([]models.Product, error) {
+	collection := GetCollection("products")
+
+	filter := bson.M{"status": "active"}
+	if !lastID.IsZero() {
+		filter["_id"] = bson.M{"$gt": lastID}
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	products := []models.Product{}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// GetProductEmbeddingHashes returns the stored text hash for each of the given SKUs that has an embedding
+func GetProductEmbeddingHashes(ctx context.Context, skus []string) (map[string]string, error) {
+	collection := GetCollection("product_embeddings")
+
+	findOptions := options.Find().SetProjection(bson.M{"sku": 1, "text_hash": 1})
+	cursor, err := collection.Find(ctx, bson.M{"sku": bson.M{"$in": skus}}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var embeddings []models.ProductEmbedding
+	if err := cursor.All(ctx, &embeddings); err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string]string, len(embeddings))
+	for _, embedding := range embeddings {
+		hashes[embedding.SKU] = embedding.TextHash
+	}
+
+	return hashes, nil
+}
+
+// UpsertProductEmbeddings stores embeddings, replacing any existing vector for the same SKU
+func UpsertProductEmbeddings(ctx context.Context, embeddings []models.ProductEmbedding) error {
+	if len(embeddings) == 0 {
+		return nil
+	}
+
+	collection := GetCollection("product_embeddings")
+
+	writes := make([]mongo.WriteModel, len(embeddings))
+	for i := range embeddings {
+		writes[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"sku": embeddings[i].SKU}).
+			SetReplacement(embeddings[i]).
+			SetUpsert(true)
+	}
+
+	_, err := collection.BulkWrite(ctx, writes)
+	return err
+}
+
+// GetProductEmbedding retrieves the stored embedding for a product SKU
+func GetProductEmbedding(ctx context.Context, sku string) (*models.ProductEmbedding, error) {
+	collection := GetCollection("product_embeddings")
+
+	var embedding models.ProductEmbedding
+	err := collection.FindOne(ctx, bson.M{"sku": sku}).Decode(&embedding)
+	if err != nil {
+		return nil, notFoundError(err, "embedding")
+	}
+
+	return &embedding, nil
+}
+
+// FindSimilarProducts ranks every other embedded product by cosine similarity to the
+// target and returns the closest active products. The catalog is small enough that a
+// full scan is cheaper than maintaining a vector index.
+func FindSimilarProducts(ctx context.Context, target *models.ProductEmbedding, limit int) ([]models.SimilarProduct, error) {
+	collection := GetCollection("product_embeddings")
+
+	cursor, err := collection.Find(ctx, bson.M{
+		"sku":   bson.M{"$ne": target.SKU},
+		"model": target.Model,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var embeddings []models.ProductEmbedding
+	if err := cursor.All(ctx, &embeddings); err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]float64, len(embeddings))
+	for _, embedding := range embeddings {
+		scores[embedding.SKU] = models.CosineSimilarity(target.Vector, embedding.Vector)
+	}
+	sort.Slice(embeddings, func(i, j int) bool {
+		return scores[embeddings[i].SKU] > scores[embeddings[j].SKU]
+	})
+
+	// Fetch a few extra candidates in case some are no longer active
+	candidates := make([]string, 0, limit*2)
+	for i := 0; i < len(embeddings) && len(candidates) < limit*2; i++ {
+		candidates = append(candidates, embeddings[i].SKU)
+	}
+	if len(candidates) == 0 {
+		return []models.SimilarProduct{}, nil
+	}
+
+	products, err := GetProductsBySKUs(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	similar := make([]models.SimilarProduct, 0, limit)
+	for _, sku := range candidates {
+		product, ok := products[sku]
+		if !ok || product.Status != "active" {
+			continue
+		}
+		similar = append(similar, models.SimilarProduct{Product: product, Score: scores[sku]})
+		if len(similar) == limit {
+			break
+		}
+	}
+
+	return similar, nil
+}
@@ -0,0 +1,149 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// reportArchiveMaxAttempts is how many times a failing archive is retried before it is
+// marked failed
+const reportArchiveMaxAttempts = 3
+
+// RequestReportArchive queues a report archive for a date range. An archive for the same
+// range that is still queued, running or downloadable is returned instead of queueing
+// another; created reports whether a new one was queued.
+func RequestReportArchive(ctx context.Context, startDate, endDate string, requestedBy *bson.ObjectID) (archive *models.ReportArchive, created bool, err error) {
+	collection := GetCollection("report_archives")
+
+	var existing models.ReportArchive
+	err = collection.FindOne(ctx,
+		bson.D{
+			{Key: "start_date", Value: startDate},
+			{Key: "end_date", Value: endDate},
+			{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{models.ExportPending, models.ExportRunning, models.ExportReady}}}},
+			{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+		},
+		options.FindOne().
+			SetSort(bson.D{{Key: "created_at", Value: -1}}).
+			SetProjection(bson.D{{Key: "data", Value: 0}}),
+	).Decode(&existing)
+	if err == nil {
+		return &existing, false, nil
+	}
+	if !isNoDocuments(err) {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	archive = &models.ReportArchive{
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Status:      models.ExportPending,
+		RequestedBy: requestedBy,
+		AvailableAt: now,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(models.ReportArchiveRetention),
+	}
+	result, err := collection.InsertOne(ctx, archive)
+	if err != nil {
+		return nil, false, err
+	}
+	archive.ID = result.InsertedID.(bson.ObjectID)
+	return archive, true, nil
+}
+
+// GetReportArchive returns a report archive without its file
+func GetReportArchive(ctx context.Context, archiveID bson.ObjectID) (*models.ReportArchive, error) {
+	var archive models.ReportArchive
+	err := GetCollection("report_archives").FindOne(ctx,
+		bson.D{{Key: "_id", Value: archiveID}},
+		options.FindOne().SetProjection(bson.D{{Key: "data", Value: 0}}),
+	).Decode(&archive)
+	if err != nil {
+		return nil, notFoundError(err, "report archive")
+	}
+	return &archive, nil
+}
+
+// GetReportArchiveFile returns a ready report archive with its file
+func GetReportArchiveFile(ctx context.Context, archiveID bson.ObjectID) (*models.ReportArchive, error) {
+	var archive models.ReportArchive
+	err := GetCollection("report_archives").FindOne(ctx, bson.D{
+		{Key: "_id", Value: archiveID},
+		{Key: "status", Value: models.ExportReady},
+	}).Decode(&archive)
+	if err != nil {
+		return nil, notFoundError(err, "report archive")
+	}
+	return &archive, nil
+}
+
+// ClaimReportArchive leases the oldest queued archive, or one whose worker stopped
+// before finishing, hiding it from other workers for lease. It returns nil when there
+// is nothing to do.
+func ClaimReportArchive(ctx context.Context, lease time.Duration) (*models.ReportArchive, error) {
+	now := time.Now()
+	filter := bson.D{
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{models.ExportPending, models.ExportRunning}}}},
+		{Key: "available_at", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "status", Value: models.ExportRunning}, {Key: "available_at", Value: now.Add(lease)}}},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+	}
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var archive models.ReportArchive
+	err := GetCollection("report_archives").FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&archive)
+	if err != nil {
+		if isNoDocuments(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &archive, nil
+}
+
+// CompleteReportArchive stores a claimed archive's file and makes it downloadable
+func CompleteReportArchive(ctx context.Context, archiveID bson.ObjectID, data []byte) error {
+	now := time.Now()
+	_, err := GetCollection("report_archives").UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: archiveID}},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: models.ExportReady},
+				{Key: "data", Value: data},
+				{Key: "size_bytes", Value: len(data)},
+				{Key: "completed_at", Value: now},
+				{Key: "expires_at", Value: now.Add(models.ReportArchiveRetention)},
+			}},
+			{Key: "$unset", Value: bson.D{{Key: "error", Value: ""}}},
+		},
+	)
+	return err
+}
+
+// RetryReportArchive records a failed attempt. The archive is queued again at retryAt,
+// or marked failed when it has used all its attempts.
+func RetryReportArchive(ctx context.Context, archive *models.ReportArchive, archiveErr error, retryAt time.Time) error {
+	status := models.ExportPending
+	if archive.Attempts >= reportArchiveMaxAttempts {
+		status = models.ExportFailed
+	}
+
+	_, err := GetCollection("report_archives").UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: archive.ID}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: status},
+			{Key: "error", Value: archiveErr.Error()},
+			{Key: "available_at", Value: retryAt},
+		}}},
+	)
+	return err
+}
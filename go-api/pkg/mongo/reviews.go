@@ -0,0 +1,176 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// AddReviewHelpfulVote records a customer's helpful vote and increments the review's count.
+// The unique (review_id, customer_id) index guarantees one vote per customer.
+func AddReviewHelpfulVote(ctx context.Context, reviewID, customerID bson.ObjectID) (*models.Review, error) {
+	if _, err := getReviewByID(ctx, reviewID); err != nil {
+		return nil, err
+	}
+
+	vote := models.ReviewVote{
+		ReviewID:   reviewID,
+		CustomerID: customerID,
+		CreatedAt:  time.Now(),
+	}
+	if _, err := GetCollection("review_votes").InsertOne(ctx, vote); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("customer has already voted on this review")
+		}
+		return nil, err
+	}
+
+	return incrementHelpfulCount(ctx, reviewID, 1)
+}
+
+// RemoveReviewHelpfulVote undoes a customer's helpful vote and decrements the review's count
+func RemoveReviewHelpfulVote(ctx context.Context, reviewID, customerID bson.ObjectID) (*models.Review, error) {
+	result, err := GetCollection("review_votes").DeleteOne(ctx, bson.D{
+		{Key: "review_id", Value: reviewID},
+		{Key: "customer_id", Value: customerID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.DeletedCount == 0 {
+		return nil, notFound("vote")
+	}
+
+	return incrementHelpfulCount(ctx, reviewID, -1)
+}
+
+// incrementHelpfulCount atomically adjusts helpful_count, never letting it drop below zero
+func incrementHelpfulCount(ctx context.Context, reviewID bson.ObjectID, delta int) (*models.Review, error) {
+	filter := bson.D{{Key: "_id", Value: reviewID}}
+	if delta < 0 {
+		filter = append(filter, bson.E{Key: "helpful_count", Value: bson.D{{Key: "$gte", Value: -delta}}})
+	}
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "helpful_count", Value: delta}}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+	}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var review models.Review
+	err := GetCollection("reviews").FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&review)
+	if err != nil {
+		if isNoDocuments(err) {
+			// Count already at zero; return the review unchanged
+			return getReviewByID(ctx, reviewID)
+		}
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+// SetReviewReply adds or replaces the merchant reply on a review, keeping the original reply date
+func SetReviewReply(ctx context.Context, reviewID bson.ObjectID, message, author string) (*models.Review, error) {
+	review, err := getReviewByID(ctx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	reply := models.ReviewReply{
+		Message:   message,
+		Author:    author,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if review.HasReply() {
+		reply.CreatedAt = review.Reply.CreatedAt
+	}
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "reply", Value: reply},
+		{Key: "updated_at", Value: now},
+	}}}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated models.Review
+	err = GetCollection("reviews").FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: reviewID}}, update, findOptions).Decode(&updated)
+	if err != nil {
+		return nil, notFoundError(err, "review")
+	}
+
+	return &updated, nil
+}
+
+// SetReviewModeration stores the screening result and moderation status on a review
+func SetReviewModeration(ctx context.Context, reviewID bson.ObjectID, status string, moderation *models.ReviewModeration) error {
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "moderation_status", Value: status},
+		{Key: "moderation", Value: moderation},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+
+	result, err := GetCollection("reviews").UpdateOne(ctx, bson.D{{Key: "_id", Value: reviewID}}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return notFound("review")
+	}
+
+	return nil
+}
+
+// GetReviewsForSentiment returns the next batch of reviews after lastID in _id order.
+// Reviews that already have a sentiment are skipped unless reanalyze is set.
+func GetReviewsForSentiment(ctx context.Context, lastID bson.ObjectID, reanalyze bool, limit int) ([]models.Review, error) {
+	filter := bson.D{}
+	if !lastID.IsZero() {
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$gt", Value: lastID}}})
+	}
+	if !reanalyze {
+		filter = append(filter, bson.E{Key: "sentiment", Value: bson.D{{Key: "$exists", Value: false}}})
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := GetCollection("reviews").Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	reviews := []models.Review{}
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+// SetReviewSentiment stores the sentiment result on a review
+func SetReviewSentiment(ctx context.Context, reviewID bson.ObjectID, sentiment *models.ReviewSentiment) error {
+	_, err := GetCollection("reviews").UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: reviewID}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "sentiment", Value: sentiment}}}},
+	)
+	return err
+}
+
+// getReviewByID retrieves a single review by its ID
+func getReviewByID(ctx context.Context, reviewID bson.ObjectID) (*models.Review, error) {
+	var review models.Review
+	err := GetCollection("reviews").FindOne(ctx, bson.D{{Key: "_id", Value: reviewID}}).Decode(&review)
+	if err != nil {
+		return nil, notFoundError(err, "review")
+	}
+
+	return &review, nil
+}
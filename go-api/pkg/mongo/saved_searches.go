@@ -0,0 +1,239 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// ListSavedSearches returns a customer's saved searches, newest first
+func ListSavedSearches(ctx context.Context, customerID bson.ObjectID) ([]models.SavedSearch, error) {
+	collection := GetCollection("saved_searches")
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := collection.Find(ctx, bson.D{{Key: "customer_id", Value: customerID}}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	searches := []models.SavedSearch{}
+	if err := cursor.All(ctx, &searches); err != nil {
+		return nil, err
+	}
+	return searches, nil
+}
+
+// GetSavedSearch returns one of a customer's saved searches
+func GetSavedSearch(ctx context.Context, customerID, searchID bson.ObjectID) (*models.SavedSearch, error) {
+	collection := GetCollection("saved_searches")
+
+	var search models.SavedSearch
+	err := collection.FindOne(ctx, bson.D{{Key: "_id", Value: searchID}, {Key: "customer_id", Value: customerID}}).Decode(&search)
+	if err != nil {
+		return nil, notFoundError(err, "saved search")
+	}
+	return &search, nil
+}
+
+// CreateSavedSearch stores a new saved search, refusing once the customer has
+// models.MaxSavedSearches of them
+func CreateSavedSearch(ctx context.Context, search *models.SavedSearch) (*models.SavedSearch, error) {
+	collection := GetCollection("saved_searches")
+
+	count, err := collection.CountDocuments(ctx, bson.D{{Key: "customer_id", Value: search.CustomerID}})
+	if err != nil {
+		return nil, err
+	}
+	if count >= models.MaxSavedSearches {
+		return nil, limitReached("saved search")
+	}
+
+	result, err := collection.InsertOne(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+
+	search.ID = result.InsertedID.(bson.ObjectID)
+	return search, nil
+}
+
+// UpdateSavedSearch applies partial updates to one of a customer's saved searches
+func UpdateSavedSearch(ctx context.Context, customerID, searchID bson.ObjectID, req *models.UpdateSavedSearchRequest) (*models.SavedSearch, error) {
+	collection := GetCollection("saved_searches")
+
+	updateDoc := bson.D{}
+
+	if req.Name != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "name", Value: *req.Name})
+	}
+	if req.Query != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "query", Value: *req.Query})
+	}
+	if req.Filters != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "filters", Value: *req.Filters})
+	}
+	if req.Notify != nil {
+		updateDoc = append(updateDoc, bson.E{Key: "notify", Value: *req.Notify})
+	}
+
+	if len(updateDoc) == 0 {
+		return nil, errors.New("no fields to update")
+	}
+
+	updateDoc = append(updateDoc, bson.E{Key: "updated_at", Value: time.Now()})
+
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updatedSearch models.SavedSearch
+	err := collection.FindOneAndUpdate(
+		ctx,
+		bson.D{{Key: "_id", Value: searchID}, {Key: "customer_id", Value: customerID}},
+		bson.D{{Key: "$set", Value: updateDoc}},
+		findOptions,
+	).Decode(&updatedSearch)
+
+	if err != nil {
+		return nil, notFoundError(err, "saved search")
+	}
+
+	return &updatedSearch, nil
+}
+
+// DeleteSavedSearch removes one of a customer's saved searches and its match history
+func DeleteSavedSearch(ctx context.Context, customerID, searchID bson.ObjectID) error {
+	collection := GetCollection("saved_searches")
+
+	result, err := collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: searchID}, {Key: "customer_id", Value: customerID}})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return notFound("saved search")
+	}
+
+	_, err = GetCollection("saved_search_matches").DeleteMany(ctx, bson.D{{Key: "saved_search_id", Value: searchID}})
+	return err
+}
+
+// MatchSavedSearches checks a newly created product against every saved search with
+// notifications on and enqueues a saved_search.matched event for each new match.
+// Matches are recorded, so running this again for the same product notifies nobody
+// twice. It returns the number of new matches.
+func MatchSavedSearches(ctx context.Context, product *models.Product) (int, error) {
+	if product.Status != "active" {
+		return 0, nil
+	}
+
+	// Skip searches whose category or brand filter already rules the product out
+	filter := bson.D{
+		{Key: "notify", Value: true},
+		{Key: "$and", Value: bson.A{
+			bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "filters.categories", Value: bson.D{{Key: "$exists", Value: false}}}},
+				bson.D{{Key: "filters.categories", Value: product.Category}},
+			}}},
+			bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "filters.brands", Value: bson.D{{Key: "$exists", Value: false}}}},
+				bson.D{{Key: "filters.brands", Value: product.Brand}},
+			}}},
+		}},
+	}
+
+	cursor, err := GetCollection("saved_searches").Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	matched := 0
+	for cursor.Next(ctx) {
+		var search models.SavedSearch
+		if err := cursor.Decode(&search); err != nil {
+			return matched, err
+		}
+
+		isMatch, err := productMatchesSearch(ctx, product.ID, &search)
+		if err != nil {
+			return matched, err
+		}
+		if !isMatch {
+			continue
+		}
+
+		recorded, err := recordSavedSearchMatch(ctx, &search, product)
+		if err != nil {
+			return matched, err
+		}
+		if recorded {
+			matched++
+		}
+	}
+	return matched, cursor.Err()
+}
+
+// productMatchesSearch reports whether search would find the product. It always uses
+// the text index: Atlas Search indexes update asynchronously, so a product created a
+// moment ago may not be searchable there yet.
+func productMatchesSearch(ctx context.Context, productID bson.ObjectID, search *models.SavedSearch) (bool, error) {
+	filter := append(bson.D{{Key: "_id", Value: productID}}, productFilterCondition(search.Filters)...)
+	request := SearchRequest{Query: search.Query, Page: 1, Limit: 1, Filters: search.Filters}
+
+	matches, _, err := findTextMatches(ctx, GetCollection("products"), request, filter, productSearchFields, nil)
+	if err != nil {
+		return false, err
+	}
+	return len(matches) > 0, nil
+}
+
+// recordSavedSearchMatch stores the match and its notification event together. It
+// returns false when the match was already recorded, such as by another instance.
+func recordSavedSearchMatch(ctx context.Context, search *models.SavedSearch, product *models.Product) (bool, error) {
+	now := time.Now()
+	match := models.SavedSearchMatch{
+		SavedSearchID: search.ID,
+		CustomerID:    search.CustomerID,
+		ProductID:     product.ID,
+		SKU:           product.SKU,
+		CreatedAt:     now,
+	}
+
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		if _, err := GetCollection("saved_search_matches").InsertOne(ctx, match); err != nil {
+			return err
+		}
+
+		_, err := GetCollection("saved_searches").UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: search.ID}},
+			bson.D{
+				{Key: "$inc", Value: bson.D{{Key: "match_count", Value: 1}}},
+				{Key: "$set", Value: bson.D{{Key: "last_matched_at", Value: now}}},
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(ctx, events.SavedSearchMatched, events.SavedSearchMatchedEvent{
+			CustomerID:    search.CustomerID.Hex(),
+			SavedSearchID: search.ID.Hex(),
+			Name:          search.Name,
+			Query:         search.Query,
+			Product:       product,
+		})
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,201 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// productFilterCondition returns f as query conditions, or nil when no filter is set
+func productFilterCondition(f models.ProductFilters) bson.D {
+	var condition bson.D
+	if len(f.Categories) > 0 {
+		condition = append(condition, bson.E{Key: "category", Value: bson.D{{Key: "$in", Value: f.Categories}}})
+	}
+	if len(f.Brands) > 0 {
+		condition = append(condition, bson.E{Key: "brand", Value: bson.D{{Key: "$in", Value: f.Brands}}})
+	}
+	if f.PriceMin != nil || f.PriceMax != nil {
+		price := bson.D{}
+		if f.PriceMin != nil {
+			price = append(price, bson.E{Key: "$gte", Value: *f.PriceMin})
+		}
+		if f.PriceMax != nil {
+			price = append(price, bson.E{Key: "$lte", Value: *f.PriceMax})
+		}
+		condition = append(condition, bson.E{Key: "price", Value: price})
+	}
+	if f.InStock != nil {
+		if *f.InStock {
+			condition = append(condition, bson.E{Key: "stock.total", Value: bson.D{{Key: "$gt", Value: 0}}})
+		} else {
+			condition = append(condition, bson.E{Key: "stock.total", Value: bson.D{{Key: "$lte", Value: 0}}})
+		}
+	}
+
+	names := make([]string, 0, len(f.Attributes))
+	for name := range f.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		condition = append(condition, bson.E{Key: "attributes." + name, Value: bson.D{{Key: "$in", Value: f.Attributes[name]}}})
+	}
+	return condition
+}
+
+// priceFacetBoundaries are the lower bounds of the price buckets; prices from the last
+// boundary up fall in the default bucket
+var priceFacetBoundaries = bson.A{0, 25, 50, 100, 250, 500, 1000}
+
+// maxFacetValues caps how many values each facet lists, most common first
+const maxFacetValues = 20
+
+// FacetCount is the number of matching products with one facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// SearchFacets counts the products matching the query and filters by each facet
+type SearchFacets struct {
+	Category   []FacetCount            `json:"category"`
+	Brand      []FacetCount            `json:"brand"`
+	Price      []FacetCount            `json:"price"`
+	InStock    []FacetCount            `json:"in_stock"`
+	Attributes map[string][]FacetCount `json:"attributes"`
+}
+
+// searchProductFacets counts the products matching req's query and filters by
+// category, brand, price range, stock and attribute value
+func searchProductFacets(ctx context.Context, req SearchRequest) (*SearchFacets, error) {
+	if settings.SearchMode == SearchModeAtlas {
+		facets, err := aggregateProductFacets(ctx, atlasSearchStage(req, productSearchFields, false), req.Filters, nil)
+		if err == nil {
+			return facets, nil
+		}
+		slog.WarnContext(ctx, "Atlas Search facets failed, using the text index instead", "error", err)
+	}
+
+	facets, err := aggregateProductFacets(ctx, bson.D{{Key: "$match", Value: textCondition(req.textQuery())}}, req.Filters, collation(ctx))
+	if isMissingTextIndex(err) {
+		return aggregateProductFacets(ctx, bson.D{{Key: "$match", Value: regexCondition(req.Query, productSearchFields)}}, req.Filters, collation(ctx))
+	}
+	return facets, err
+}
+
+// aggregateProductFacets counts the products selected by searchStage and filters. With a
+// collation, values differing only in case or accents are counted together. Atlas Search
+// stages don't take one.
+func aggregateProductFacets(ctx context.Context, searchStage bson.D, filters models.ProductFilters, collation *options.Collation) (*SearchFacets, error) {
+	pipeline := mongo.Pipeline{searchStage}
+	if condition := productFilterCondition(filters); len(condition) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: condition}})
+	}
+
+	countBy := func(field interface{}) bson.A {
+		return bson.A{
+			bson.D{{Key: "$sortByCount", Value: field}},
+			bson.D{{Key: "$limit", Value: maxFacetValues}},
+		}
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$facet", Value: bson.D{
+		{Key: "category", Value: countBy("$category")},
+		{Key: "brand", Value: countBy("$brand")},
+		{Key: "price", Value: bson.A{
+			bson.D{{Key: "$bucket", Value: bson.D{
+				{Key: "groupBy", Value: "$price"},
+				{Key: "boundaries", Value: priceFacetBoundaries},
+				{Key: "default", Value: fmt.Sprintf("%v+", priceFacetBoundaries[len(priceFacetBoundaries)-1])},
+			}}},
+		}},
+		{Key: "in_stock", Value: countBy(bson.D{{Key: "$gt", Value: bson.A{"$stock.total", 0}}})},
+		{Key: "attributes", Value: bson.A{
+			bson.D{{Key: "$project", Value: bson.D{{Key: "attribute", Value: bson.D{{Key: "$objectToArray", Value: bson.D{
+				{Key: "$ifNull", Value: bson.A{"$attributes", bson.D{}}},
+			}}}}}}},
+			bson.D{{Key: "$unwind", Value: "$attribute"}},
+			bson.D{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: bson.D{{Key: "name", Value: "$attribute.k"}, {Key: "value", Value: "$attribute.v"}}},
+				{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id.value", Value: 1}}}},
+		}},
+	}}})
+
+	cursor, err := GetCollection("products").Aggregate(ctx, pipeline, options.Aggregate().SetCollation(collation))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	type bucket struct {
+		ID    interface{} `bson:"_id"`
+		Count int64       `bson:"count"`
+	}
+	var results []struct {
+		Category   []bucket `bson:"category"`
+		Brand      []bucket `bson:"brand"`
+		Price      []bucket `bson:"price"`
+		InStock    []bucket `bson:"in_stock"`
+		Attributes []struct {
+			ID struct {
+				Name  string `bson:"name"`
+				Value string `bson:"value"`
+			} `bson:"_id"`
+			Count int64 `bson:"count"`
+		} `bson:"attributes"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	facets := &SearchFacets{
+		Category:   []FacetCount{},
+		Brand:      []FacetCount{},
+		Price:      []FacetCount{},
+		InStock:    []FacetCount{},
+		Attributes: map[string][]FacetCount{},
+	}
+	if len(results) == 0 {
+		return facets, nil
+	}
+	result := results[0]
+
+	for _, b := range result.Category {
+		facets.Category = append(facets.Category, FacetCount{Value: fmt.Sprint(b.ID), Count: b.Count})
+	}
+	for _, b := range result.Brand {
+		facets.Brand = append(facets.Brand, FacetCount{Value: fmt.Sprint(b.ID), Count: b.Count})
+	}
+	for _, b := range result.Price {
+		facets.Price = append(facets.Price, FacetCount{Value: priceBucketLabel(b.ID), Count: b.Count})
+	}
+	for _, b := range result.InStock {
+		facets.InStock = append(facets.InStock, FacetCount{Value: fmt.Sprint(b.ID), Count: b.Count})
+	}
+	for _, attribute := range result.Attributes {
+		values := facets.Attributes[attribute.ID.Name]
+		if len(values) < maxFacetValues {
+			facets.Attributes[attribute.ID.Name] = append(values, FacetCount{Value: attribute.ID.Value, Count: attribute.Count})
+		}
+	}
+	return facets, nil
+}
+
+// priceBucketLabel turns a bucket's lower boundary into a range such as "25-50"
+func priceBucketLabel(id interface{}) string {
+	lower := fmt.Sprint(id)
+	for i := 0; i < len(priceFacetBoundaries)-1; i++ {
+		if fmt.Sprint(priceFacetBoundaries[i]) == lower {
+			return fmt.Sprintf("%s-%v", lower, priceFacetBoundaries[i+1])
+		}
+	}
+	return lower
+}
@@ -0,0 +1,111 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// SearchLogRetention is how long search logs are kept
+const SearchLogRetention = 90 * 24 * time.Hour
+
+// SearchTermStats summarizes the searches for one term
+type SearchTermStats struct {
+	Term         string    `json:"term" bson:"_id"`
+	Searches     int       `json:"searches" bson:"searches"`
+	AvgResults   float64   `json:"avg_results" bson:"avg_results"`
+	LastSearched time.Time `json:"last_searched" bson:"last_searched"`
+}
+
+// SearchAnalytics reports search volume and the most common terms in a date range
+type SearchAnalytics struct {
+	StartDate            string            `json:"start_date"`
+	EndDate              string            `json:"end_date"`
+	TotalSearches        int               `json:"total_searches"`
+	ZeroResultSearches   int               `json:"zero_result_searches"`
+	ZeroResultRate       float64           `json:"zero_result_rate"`
+	TopQueries           []SearchTermStats `json:"top_queries"`
+	TopZeroResultQueries []SearchTermStats `json:"top_zero_result_queries"`
+}
+
+// InsertSearchLog stores one search
+func InsertSearchLog(ctx context.Context, entry *models.SearchLog) error {
+	_, err := GetCollection("search_logs").InsertOne(ctx, entry)
+	return err
+}
+
+// GetSearchAnalytics reports searches made in [start, end), with the limit most
+// searched terms overall and among searches that found nothing
+func GetSearchAnalytics(ctx context.Context, start, end time.Time, limit int) (*SearchAnalytics, error) {
+	ctx, cancel := Analytics.Context(ctx)
+	defer cancel()
+
+	topTerms := func(match bson.D) bson.A {
+		return bson.A{
+			bson.D{{Key: "$match", Value: match}},
+			bson.D{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$term"},
+				{Key: "searches", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "avg_results", Value: bson.D{{Key: "$avg", Value: "$results"}}},
+				{Key: "last_searched", Value: bson.D{{Key: "$max", Value: "$created_at"}}},
+			}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "searches", Value: -1}, {Key: "_id", Value: 1}}}},
+			bson.D{{Key: "$limit", Value: limit}},
+		}
+	}
+
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "created_at", Value: bson.D{{Key: "$gte", Value: start}, {Key: "$lt", Value: end}}}}}},
+		bson.D{{Key: "$facet", Value: bson.D{
+			{Key: "totals", Value: bson.A{
+				bson.D{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: nil},
+					{Key: "searches", Value: bson.D{{Key: "$sum", Value: 1}}},
+					{Key: "zero_results", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{bson.D{{Key: "$eq", Value: bson.A{"$results", 0}}}, 1, 0}}}}}},
+				}}},
+			}},
+			{Key: "top_queries", Value: topTerms(bson.D{})},
+			{Key: "top_zero_result_queries", Value: topTerms(bson.D{{Key: "results", Value: 0}})},
+		}}},
+	}
+
+	cursor, err := Analytics.Collection("search_logs").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Totals []struct {
+			Searches    int `bson:"searches"`
+			ZeroResults int `bson:"zero_results"`
+		} `bson:"totals"`
+		TopQueries           []SearchTermStats `bson:"top_queries"`
+		TopZeroResultQueries []SearchTermStats `bson:"top_zero_result_queries"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	analytics := &SearchAnalytics{
+		TopQueries:           []SearchTermStats{},
+		TopZeroResultQueries: []SearchTermStats{},
+	}
+	if len(results) == 0 {
+		return analytics, nil
+	}
+	if len(results[0].Totals) > 0 {
+		analytics.TotalSearches = results[0].Totals[0].Searches
+		analytics.ZeroResultSearches = results[0].Totals[0].ZeroResults
+		analytics.ZeroResultRate = float64(analytics.ZeroResultSearches) / float64(analytics.TotalSearches)
+	}
+	if results[0].TopQueries != nil {
+		analytics.TopQueries = results[0].TopQueries
+	}
+	if results[0].TopZeroResultQueries != nil {
+		analytics.TopZeroResultQueries = results[0].TopZeroResultQueries
+	}
+	return analytics, nil
+}
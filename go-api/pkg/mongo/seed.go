@@ -1 +1,50 @@
 package mongo
+
+import (
+	"context"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/seed"
+)
+
+// SeedDatabase inserts generated records in one transaction, so a failed seed leaves
+// nothing behind. It publishes only database.seeded, never the per-record events:
+// seeded orders and products must not email the made-up customers or match anyone's
+// saved searches. It runs under the Bulk deadline, since thousands of inserts can
+// outlast a Transactional one.
+func SeedDatabase(ctx context.Context, data *seed.Dataset) error {
+	return runTransaction(ctx, Bulk, func(ctx context.Context) error {
+		if err := insertSeeded(ctx, "products", data.Products); err != nil {
+			return err
+		}
+		if err := insertSeeded(ctx, "customers", data.Customers); err != nil {
+			return err
+		}
+		if err := insertSeeded(ctx, "orders", data.Orders); err != nil {
+			return err
+		}
+		if err := insertSeeded(ctx, "reviews", data.Reviews); err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, events.DatabaseSeeded, events.DatabaseSeededEvent{Counts: models.SeedCounts{
+			Products:  len(data.Products),
+			Customers: len(data.Customers),
+			Orders:    len(data.Orders),
+			Reviews:   len(data.Reviews),
+		}})
+	})
+}
+
+// insertSeeded inserts records into a collection, doing nothing when there are none
+func insertSeeded[T any](ctx context.Context, collectionName string, records []T) error {
+	if len(records) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(records))
+	for i, record := range records {
+		docs[i] = record
+	}
+	_, err := GetCollection(collectionName).InsertMany(ctx, docs)
+	return err
+}
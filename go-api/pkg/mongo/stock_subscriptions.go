@@ -0,0 +1,55 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// SubscribeToRestock records a back-in-stock subscription. Subscribing the same email
+// to the same SKU again returns the existing subscription and false.
+func SubscribeToRestock(ctx context.Context, subscription *models.StockSubscription) (*models.StockSubscription, bool, error) {
+	collection := GetCollection("stock_subscriptions")
+
+	filter := bson.D{{Key: "sku", Value: subscription.SKU}, {Key: "email", Value: subscription.Email}}
+	result, err := collection.UpdateOne(ctx, filter, bson.D{{Key: "$setOnInsert", Value: subscription}}, options.UpdateOne().SetUpsert(true))
+	if err != nil {
+		return nil, false, err
+	}
+
+	var saved models.StockSubscription
+	if err := collection.FindOne(ctx, filter).Decode(&saved); err != nil {
+		return nil, false, err
+	}
+	return &saved, result.UpsertedCount > 0, nil
+}
+
+// GetStockSubscriptions lists a product's subscriptions made before a time, oldest first
+func GetStockSubscriptions(ctx context.Context, sku string, before time.Time) ([]models.StockSubscription, error) {
+	cursor, err := GetCollection("stock_subscriptions").Find(ctx,
+		bson.D{{Key: "sku", Value: sku}, {Key: "created_at", Value: bson.D{{Key: "$lte", Value: before}}}},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	subscriptions := []models.StockSubscription{}
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// DeleteStockSubscriptions removes subscriptions whose email was queued
+func DeleteStockSubscriptions(ctx context.Context, ids []bson.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := GetCollection("stock_subscriptions").DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+	return err
+}
@@ -0,0 +1,63 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// GetTaxRules returns the saved province tax rules, or the defaults when an admin has
+// not saved any
+func GetTaxRules(ctx context.Context) (*models.TaxRules, error) {
+	var rules models.TaxRules
+	err := GetCollection("settings").FindOne(ctx, bson.D{{Key: "_id", Value: models.TaxRulesID}}).Decode(&rules)
+	if err != nil {
+		if isNoDocuments(err) {
+			return models.DefaultTaxRules(), nil
+		}
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// LoadTaxRules applies the saved tax rules to cart and order totals
+func LoadTaxRules(ctx context.Context) error {
+	rules, err := GetTaxRules(ctx)
+	if err != nil {
+		return err
+	}
+	models.SetTaxRules(rules)
+	return nil
+}
+
+// UpdateTaxRules replaces the province tax rules. Every instance applies them when it
+// receives the tax_rules.updated event. Orders keep the rates they were placed with.
+func UpdateTaxRules(ctx context.Context, provinces []models.ProvinceTax) (*models.TaxRules, error) {
+	rules := &models.TaxRules{
+		ID:        models.TaxRulesID,
+		Provinces: provinces,
+		UpdatedAt: time.Now(),
+	}
+
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		_, err := GetCollection("settings").ReplaceOne(ctx,
+			bson.D{{Key: "_id", Value: models.TaxRulesID}},
+			rules,
+			options.Replace().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, events.TaxRulesUpdated, events.TaxRulesUpdatedEvent{Rules: rules})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	models.SetTaxRules(rules)
+	return rules, nil
+}
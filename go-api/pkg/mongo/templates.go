@@ -0,0 +1,151 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// ListTemplates returns the current version of every template, by key
+func ListTemplates(ctx context.Context) ([]models.NotificationTemplate, error) {
+	collection := GetCollection("templates")
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "key", Value: 1}, {Key: "version", Value: -1}}}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$key"}, {Key: "current", Value: bson.D{{Key: "$first", Value: "$$ROOT"}}}}}},
+		{{Key: "$replaceRoot", Value: bson.D{{Key: "newRoot", Value: "$current"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "key", Value: 1}}}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	templates := []models.NotificationTemplate{}
+	if err := cursor.All(ctx, &templates); err != nil {
+		return nil, err
+	}
+
+	// Built-in templates nobody has saved yet are listed as their defaults
+	saved := make(map[string]bool, len(templates))
+	for _, template := range templates {
+		saved[template.Key] = true
+	}
+	for key := range models.DefaultTemplates {
+		if !saved[key] {
+			defaultTemplate, _ := models.DefaultTemplate(key)
+			templates = append(templates, *defaultTemplate)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Key < templates[j].Key })
+	return templates, nil
+}
+
+// GetTemplate returns a version of a template, or its current version when version is 0
+func GetTemplate(ctx context.Context, key string, version int) (*models.NotificationTemplate, error) {
+	collection := GetCollection("templates")
+
+	filter := bson.D{{Key: "key", Value: key}}
+	if version > 0 {
+		filter = append(filter, bson.E{Key: "version", Value: version})
+	}
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+
+	var template models.NotificationTemplate
+	err := collection.FindOne(ctx, filter, findOptions).Decode(&template)
+	if err != nil {
+		if isNoDocuments(err) {
+			// Until one is saved, the current version of a built-in template is its default
+			if defaultTemplate, ok := models.DefaultTemplate(key); ok && version == 0 {
+				return defaultTemplate, nil
+			}
+			return nil, notFound("template")
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+// ListTemplateVersions returns every version of a template, newest first
+func ListTemplateVersions(ctx context.Context, key string) ([]models.NotificationTemplate, error) {
+	collection := GetCollection("templates")
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	cursor, err := collection.Find(ctx, bson.D{{Key: "key", Value: key}}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	versions := []models.NotificationTemplate{}
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, notFound("template")
+	}
+	return versions, nil
+}
+
+// CreateTemplate stores the first version of a new template
+func CreateTemplate(ctx context.Context, template *models.NotificationTemplate) (*models.NotificationTemplate, error) {
+	collection := GetCollection("templates")
+
+	result, err := collection.InsertOne(ctx, template)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, duplicate("template")
+		}
+		return nil, err
+	}
+
+	template.ID = result.InsertedID.(bson.ObjectID)
+	return template, nil
+}
+
+// SaveTemplateVersion stores the version following the current one, built by next from
+// the current version. The unique key and version index makes concurrent saves retry
+// on top of each other instead of overwriting.
+func SaveTemplateVersion(ctx context.Context, key string, next func(current *models.NotificationTemplate) *models.NotificationTemplate) (*models.NotificationTemplate, error) {
+	collection := GetCollection("templates")
+
+	for attempt := 0; attempt < 3; attempt++ {
+		current, err := GetTemplate(ctx, key, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		template := next(current)
+		result, err := collection.InsertOne(ctx, template)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		template.ID = result.InsertedID.(bson.ObjectID)
+		return template, nil
+	}
+	return nil, errors.New("template is being changed concurrently")
+}
+
+// DeleteTemplate removes every version of a template
+func DeleteTemplate(ctx context.Context, key string) error {
+	collection := GetCollection("templates")
+
+	result, err := collection.DeleteMany(ctx, bson.D{{Key: "key", Value: key}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return notFound("template")
+	}
+	return nil
+}
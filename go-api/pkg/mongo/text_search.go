@@ -0,0 +1,142 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// indexNotFoundCode is returned for a $text query on a collection without a text index
+const indexNotFoundCode = 27
+
+// textMatch is one search hit and its relevance
+type textMatch struct {
+	Document bson.Raw
+	// Score is the text or search score; regex matches have no score and report 0
+	Score float64
+	// Highlights are the matching fragments, only returned by Atlas Search
+	Highlights []string
+}
+
+// findMatches returns req's page of matches and the number of matches on every page. It
+// searches with Atlas Search when it is enabled, otherwise with the collection's text
+// index. Matches must also satisfy filter, which may be nil. fields are the fields
+// Atlas Search and the regex fallback look in. resultFields, when set, are the only
+// fields the returned documents include besides _id.
+func findMatches(ctx context.Context, collection *mongo.Collection, req SearchRequest, filter bson.D, fields, resultFields []string) ([]textMatch, int, error) {
+	if settings.SearchMode == SearchModeAtlas {
+		matches, total, err := findAtlasMatches(ctx, collection, req, filter, fields, resultFields)
+		if err == nil {
+			return matches, total, nil
+		}
+		slog.WarnContext(ctx, "Atlas Search failed, using the text index instead", "collection", collection.Name(), "error", err)
+	}
+	return findTextMatches(ctx, collection, req, filter, fields, resultFields)
+}
+
+// findTextMatches searches collection's text index, best matches first. When the
+// collection has no text index yet it falls back to a case-insensitive scan of
+// fallbackFields.
+func findTextMatches(ctx context.Context, collection *mongo.Collection, req SearchRequest, filter bson.D, fallbackFields, resultFields []string) ([]textMatch, int, error) {
+	textScore := bson.D{{Key: "$meta", Value: "textScore"}}
+	findOptions := options.Find().
+		SetProjection(append(bson.D{{Key: "score", Value: textScore}}, includeFields(resultFields)...)).
+		SetSort(bson.D{{Key: "score", Value: textScore}}).
+		SetCollation(collation(ctx)).
+		SetSkip(int64(req.skip())).
+		SetLimit(int64(req.Limit))
+
+	query := append(textCondition(req.textQuery()), filter...)
+	cursor, err := collection.Find(ctx, query, findOptions)
+	if isMissingTextIndex(err) {
+		slog.WarnContext(ctx, "No text index for search, scanning with regex instead", "collection", collection.Name())
+		return findRegexMatches(ctx, collection, req, filter, fallbackFields, resultFields)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var matches []textMatch
+	for cursor.Next(ctx) {
+		// Current is only valid until the next call to Next
+		document := append(bson.Raw(nil), cursor.Current...)
+		score, _ := document.Lookup("score").DoubleOK()
+		matches = append(matches, textMatch{Document: document, Score: score})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := countMatches(ctx, collection, query, req, len(matches))
+	return matches, total, err
+}
+
+func findRegexMatches(ctx context.Context, collection *mongo.Collection, req SearchRequest, filter bson.D, fields, resultFields []string) ([]textMatch, int, error) {
+	query := append(regexCondition(req.Query, fields), filter...)
+	findOptions := options.Find().SetCollation(collation(ctx)).SetSkip(int64(req.skip())).SetLimit(int64(req.Limit))
+	if len(resultFields) > 0 {
+		findOptions.SetProjection(includeFields(resultFields))
+	}
+	cursor, err := collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var matches []textMatch
+	for cursor.Next(ctx) {
+		matches = append(matches, textMatch{Document: append(bson.Raw(nil), cursor.Current...)})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := countMatches(ctx, collection, query, req, len(matches))
+	return matches, total, err
+}
+
+// countMatches counts the documents matching query on every page. A partial page is
+// the last one, so its total is known without asking the server.
+func countMatches(ctx context.Context, collection *mongo.Collection, query bson.D, req SearchRequest, found int) (int, error) {
+	if found > 0 && found < req.Limit {
+		return req.skip() + found, nil
+	}
+	total, err := collection.CountDocuments(ctx, query, options.Count().SetCollation(collation(ctx)))
+	return int(total), err
+}
+
+// includeFields is a projection keeping only fields, or nil to keep every field
+func includeFields(fields []string) bson.D {
+	var projection bson.D
+	for _, field := range fields {
+		projection = append(projection, bson.E{Key: field, Value: 1})
+	}
+	return projection
+}
+
+// textCondition matches documents through the collection's text index
+func textCondition(query string) bson.D {
+	return bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: query}}}}
+}
+
+// regexCondition matches documents with query anywhere in one of fields, ignoring case
+func regexCondition(query string, fields []string) bson.D {
+	pattern := bson.D{{Key: "$regex", Value: regexp.QuoteMeta(query)}, {Key: "$options", Value: "i"}}
+	conditions := bson.A{}
+	for _, field := range fields {
+		conditions = append(conditions, bson.D{{Key: field, Value: pattern}})
+	}
+	return bson.D{{Key: "$or", Value: conditions}}
+}
+
+// isMissingTextIndex reports whether err is a $text query failing for lack of an index
+func isMissingTextIndex(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(indexNotFoundCode)
+}
@@ -0,0 +1,64 @@
+package mongo
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("julianmorley.ca/con-plar/prog2270/pkg/mongo")
+
+// newTracingMonitor creates a command monitor that records every MongoDB command as a
+// client span, parented to the span in the context the command was issued with
+func newTracingMonitor() *event.CommandMonitor {
+	var spans sync.Map // request ID -> trace.Span
+
+	finish := func(requestID int64, err error) {
+		value, ok := spans.LoadAndDelete(requestID)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, started *event.CommandStartedEvent) {
+			collection := ""
+			if value, err := started.Command.LookupErr(started.CommandName); err == nil {
+				collection, _ = value.StringValueOK()
+			}
+
+			name := started.CommandName
+			if collection != "" {
+				name += " " + collection
+			}
+
+			_, span := tracer.Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("db.system.name", "mongodb"),
+					attribute.String("db.namespace", started.DatabaseName),
+					attribute.String("db.collection.name", collection),
+					attribute.String("db.operation.name", started.CommandName),
+				),
+			)
+			spans.Store(started.RequestID, span)
+		},
+		Succeeded: func(ctx context.Context, succeeded *event.CommandSucceededEvent) {
+			finish(succeeded.RequestID, nil)
+		},
+		Failed: func(ctx context.Context, failed *event.CommandFailedEvent) {
+			finish(failed.RequestID, failed.Failure)
+		},
+	}
+}
@@ -0,0 +1,79 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+)
+
+// illegalOperationCode is returned when a standalone server is asked to start a transaction
+const illegalOperationCode = 20
+
+// transactionsUnsupported is set once the server has rejected a transaction
+var transactionsUnsupported atomic.Bool
+
+// transactionOptions read a consistent snapshot from the primary and only commit once a
+// majority of the replica set has the writes, so a failover can't undo half a workflow
+var transactionOptions = options.Transaction().
+	SetReadPreference(readpref.Primary()).
+	SetReadConcern(readconcern.Snapshot()).
+	SetWriteConcern(writeconcern.Majority())
+
+// inTransaction runs write in a multi-document transaction, so every write it makes
+// (an order and its stock, customer stats, inventory logs and outbox events) commits or
+// rolls back together. The driver retries write on transient errors such as write
+// conflicts, so write must not keep state from an earlier attempt. Calls nested in
+// another transaction join it. A standalone server can't run transactions, so there
+// write runs without one and logs a warning the first time. The whole transaction,
+// retries included, must finish within the Transactional deadline.
+func inTransaction(ctx context.Context, write func(ctx context.Context) error) error {
+	return runTransaction(ctx, Transactional, write)
+}
+
+// runTransaction is inTransaction under op's deadline, for work such as seeding that
+// writes too much for the Transactional one
+func runTransaction(ctx context.Context, op *Operation, write func(ctx context.Context) error) error {
+	if inSession(ctx) {
+		return write(ctx)
+	}
+
+	ctx, cancel := op.Context(ctx)
+	defer cancel()
+
+	if transactionsUnsupported.Load() {
+		return write(ctx)
+	}
+
+	session, err := GetMongoClient().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx context.Context) (interface{}, error) {
+		return nil, write(sessionCtx)
+	}, transactionOptions)
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(illegalOperationCode) {
+		// The first operation was rejected, so nothing was written
+		if transactionsUnsupported.CompareAndSwap(false, true) {
+			slog.WarnContext(ctx, "MongoDB does not support transactions; related writes and outbox events are made one at a time instead of atomically", "error", err)
+		}
+		return write(ctx)
+	}
+	return err
+}
+
+// inSession reports whether ctx carries a session, which inside inTransaction means the
+// writes will be rolled back together if the transaction fails
+func inSession(ctx context.Context) bool {
+	return mongo.SessionFromContext(ctx) != nil
+}
@@ -0,0 +1,282 @@
+package mongo
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// InvalidUpdateError is returned when a partial update names a path its model doesn't
+// allow or gives a value of the wrong type, such as a string price
+type InvalidUpdateError struct {
+	Field   string
+	Message string
+	// Unknown is set when the path isn't updatable at all, rather than mistyped
+	Unknown bool
+	// Operator is set when a key is update operator syntax, such as $set or stock.$[]
+	Operator bool
+}
+
+func (e *InvalidUpdateError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// IsOperatorKey reports whether a document key or dotted path would be read by MongoDB
+// as an operator rather than a field name: any part starting with "$" (such as $set,
+// items.$ or stock.$[]), an empty part, or a NUL byte
+func IsOperatorKey(key string) bool {
+	if strings.ContainsRune(key, 0) {
+		return true
+	}
+	for _, part := range strings.Split(key, ".") {
+		if part == "" || strings.HasPrefix(part, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// operatorKeyError rejects a key that is operator syntax
+func operatorKeyError(path string) error {
+	return &InvalidUpdateError{Field: path, Message: "field names may not start with '$', use positional operators or be empty", Operator: true}
+}
+
+// updateSchema is the set of paths a partial update may set on one model, each with the
+// Go type its value must fit. A path ending in ".*" matches any key of a map field.
+type updateSchema map[string]reflect.Type
+
+// productUpdateSchema lists the fields UpdateProductBySKU may change
+var productUpdateSchema = newUpdateSchema(models.Product{},
+	"name", "description", "seo_title", "category", "subcategory", "brand", "price",
+	"currency", "weight_kg", "stock.warehouse_main", "stock.warehouse_east",
+	"stock.warehouse_west", "attributes", "attributes.*", "images", "tags", "status",
+)
+
+// orderUpdateSchema lists the fields UpdateOrderByNumber may change. Items and the
+// timeline are only set by the order workflow itself, never by a caller.
+var orderUpdateSchema = newUpdateSchema(models.Order{},
+	"status", "notes",
+	"shipping_address", "shipping_address.street", "shipping_address.city",
+	"shipping_address.province", "shipping_address.postal_code", "shipping_address.country",
+	"billing_address", "billing_address.street", "billing_address.city",
+	"billing_address.province", "billing_address.postal_code", "billing_address.country",
+	"tracking", "tracking.carrier", "tracking.number", "tracking.url",
+)
+
+// newUpdateSchema resolves each path to its field type on model by bson name. It panics
+// on a path the model doesn't have, so a renamed field fails at startup.
+func newUpdateSchema(model interface{}, paths ...string) updateSchema {
+	schema := updateSchema{}
+	for _, path := range paths {
+		fieldType, ok := pathType(reflect.TypeOf(model), path)
+		if !ok {
+			panic(fmt.Sprintf("mongo: %T has no field %q", model, path))
+		}
+		schema[path] = fieldType
+	}
+	return schema
+}
+
+// check rejects a path outside the schema or a value that doesn't fit its field, and
+// converts whole-number JSON values to int for integer fields so they aren't stored as
+// doubles. Nil values unset their path and are always allowed.
+func (schema updateSchema) check(updates map[string]interface{}) error {
+	paths := make([]string, 0, len(updates))
+	for path := range updates {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if IsOperatorKey(path) {
+			return operatorKeyError(path)
+		}
+
+		fieldType, ok := schema[path]
+		if i := strings.LastIndex(path, "."); !ok && i > 0 {
+			fieldType, ok = schema[path[:i]+".*"]
+		}
+		if !ok {
+			return &InvalidUpdateError{Field: path, Message: "field does not exist or cannot be updated", Unknown: true}
+		}
+		if updates[path] == nil {
+			continue
+		}
+
+		value, err := fitValue(path, updates[path], fieldType)
+		if err != nil {
+			return err
+		}
+		updates[path] = value
+	}
+	return nil
+}
+
+// pathType follows a dotted path through structs by bson name and through map values
+// for ".*"
+func pathType(t reflect.Type, path string) (reflect.Type, bool) {
+	for _, part := range strings.Split(path, ".") {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch {
+		case part == "*" && t.Kind() == reflect.Map:
+			t = t.Elem()
+		case t.Kind() == reflect.Struct:
+			field, ok := bsonField(t, part)
+			if !ok {
+				return nil, false
+			}
+			t = field.Type
+		default:
+			return nil, false
+		}
+	}
+	return t, true
+}
+
+// bsonField finds the struct field stored under name
+func bsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(field.Tag.Get("bson"), ",")
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = strings.ToLower(field.Name)
+		}
+		if tag == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// fitValue checks that value, as decoded from JSON or set in code, can be stored as t.
+// Objects may only hold t's fields, and numbers must fit integer fields exactly.
+func fitValue(path string, value interface{}, t reflect.Type) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if reflect.TypeOf(value).AssignableTo(t) {
+		return value, nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		if reflect.TypeOf(value).AssignableTo(t) {
+			return value, nil
+		}
+	}
+
+	mistyped := func(expected string) error {
+		return &InvalidUpdateError{Field: path, Message: "must be " + expected}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return nil, mistyped("a string")
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, mistyped("a boolean")
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		number, ok := toFloat(value)
+		if !ok || number != math.Trunc(number) {
+			return nil, mistyped("a whole number")
+		}
+		return int(number), nil
+	case reflect.Float32, reflect.Float64:
+		number, ok := toFloat(value)
+		if !ok {
+			return nil, mistyped("a number")
+		}
+		return number, nil
+	case reflect.Slice:
+		items := reflect.ValueOf(value)
+		if items.Kind() != reflect.Slice {
+			return nil, mistyped("an array")
+		}
+		fitted := make([]interface{}, items.Len())
+		for i := range fitted {
+			item, err := fitValue(fmt.Sprintf("%s[%d]", path, i), items.Index(i).Interface(), t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			fitted[i] = item
+		}
+		return fitted, nil
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, mistyped("an object")
+		}
+		fitted := make(map[string]interface{}, len(object))
+		for key, item := range object {
+			if IsOperatorKey(key) || strings.Contains(key, ".") {
+				return nil, operatorKeyError(path + "." + key)
+			}
+			item, err := fitValue(path+"."+key, item, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			fitted[key] = item
+		}
+		return fitted, nil
+	case reflect.Struct:
+		if t == timeType {
+			return nil, mistyped("a date")
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, mistyped("an object")
+		}
+		fitted := make(map[string]interface{}, len(object))
+		for key, item := range object {
+			if IsOperatorKey(key) || strings.Contains(key, ".") {
+				return nil, operatorKeyError(path + "." + key)
+			}
+			field, ok := bsonField(t, key)
+			if !ok {
+				return nil, &InvalidUpdateError{Field: path + "." + key, Message: "field does not exist or cannot be updated", Unknown: true}
+			}
+			item, err := fitValue(path+"."+key, item, field.Type)
+			if err != nil {
+				return nil, err
+			}
+			fitted[key] = item
+		}
+		return fitted, nil
+	case reflect.Interface:
+		return value, nil
+	}
+	return nil, mistyped(t.String())
+}
+
+// toFloat reads any Go number
+func toFloat(value interface{}) (float64, bool) {
+	number := reflect.ValueOf(value)
+	switch number.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(number.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(number.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return number.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,261 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// namespaceNotFoundCode is returned by collMod when the collection doesn't exist yet
+const namespaceNotFoundCode = 26
+
+// validatedCollections are the collections MongoDB checks writes to against a
+// $jsonSchema built from their model, so a bad write is rejected by the database even
+// if it gets past the API's own validation
+var validatedCollections = []struct {
+	Name  string
+	Model interface{}
+}{
+	{"products", models.Product{}},
+	{"orders", models.Order{}},
+	{"customers", models.Customer{}},
+	{"reviews", models.Review{}},
+}
+
+var objectIDType = reflect.TypeOf(bson.ObjectID{})
+
+// EnsureValidators applies each validated collection's $jsonSchema, creating the
+// collection if it doesn't exist. The validation level is moderate, so documents stored
+// before a rule existed can still be updated until they are fixed. With the "warn"
+// setting MongoDB only logs rejected writes, and "off" removes the validators.
+func EnsureValidators(ctx context.Context) error {
+	action := settings.SchemaValidation
+	if action == "" {
+		action = "error"
+	}
+
+	var failures []error
+	for _, collection := range validatedCollections {
+		validator, level := bson.D{{Key: "$jsonSchema", Value: collectionSchema(collection.Model)}}, "moderate"
+		if action == "off" {
+			validator, level = bson.D{}, "off"
+		}
+
+		err := GetDatabase().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: collection.Name},
+			{Key: "validator", Value: validator},
+			{Key: "validationLevel", Value: level},
+			{Key: "validationAction", Value: validationAction(action)},
+		}).Err()
+
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) && serverErr.HasErrorCode(namespaceNotFoundCode) {
+			err = GetDatabase().CreateCollection(ctx, collection.Name, options.CreateCollection().
+				SetValidator(validator).
+				SetValidationLevel(level).
+				SetValidationAction(validationAction(action)))
+		}
+		if err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", collection.Name, err))
+			continue
+		}
+		slog.DebugContext(ctx, "Applied collection validator", "collection", collection.Name, "action", action)
+	}
+	return errors.Join(failures...)
+}
+
+// validationAction maps the setting to MongoDB's action; "off" still needs a valid one
+func validationAction(setting string) string {
+	if setting == "warn" {
+		return "warn"
+	}
+	return "error"
+}
+
+// EnsureValidatorsOnStartup applies the collection validators, logging rather than
+// stopping the server when the database user isn't allowed to run collMod
+func EnsureValidatorsOnStartup() {
+	ctx, cancel := context.WithTimeout(context.Background(), indexBuildTimeout)
+	defer cancel()
+
+	if err := EnsureValidators(ctx); err != nil {
+		slog.Warn("Failed to apply collection validators; writes are only checked by the API", "error", err)
+		return
+	}
+	slog.Info("Collection validators applied", "action", settings.SchemaValidation)
+}
+
+// collectionSchema builds a document's $jsonSchema from its model's bson names and
+// validate tags
+func collectionSchema(model interface{}) bson.M {
+	schema := structSchema(reflect.TypeOf(model))
+	// Every stored document has an _id, whatever the model's omitempty says
+	required, _ := schema["required"].([]string)
+	schema["required"] = append([]string{"_id"}, required...)
+	return schema
+}
+
+// bsonSchema converts a Go type to its $jsonSchema. Pointers, slices and maps may also
+// be stored as null, which is how the driver writes a nil value without omitempty.
+func bsonSchema(t reflect.Type) bson.M {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var schema bson.M
+	switch {
+	case t == timeType:
+		schema = bson.M{"bsonType": "date"}
+	case t == objectIDType:
+		schema = bson.M{"bsonType": "objectId"}
+	default:
+		switch t.Kind() {
+		case reflect.Bool:
+			schema = bson.M{"bsonType": "bool"}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			schema = bson.M{"bsonType": bson.A{"int", "long"}}
+		case reflect.Float32, reflect.Float64:
+			schema = bson.M{"bsonType": "number"}
+		case reflect.String:
+			schema = bson.M{"bsonType": "string"}
+		case reflect.Slice, reflect.Array:
+			schema = bson.M{"bsonType": "array", "items": bsonSchema(t.Elem())}
+			nullable = true
+		case reflect.Map:
+			schema = bson.M{"bsonType": "object", "additionalProperties": bsonSchema(t.Elem())}
+			nullable = true
+		case reflect.Struct:
+			schema = structSchema(t)
+		default:
+			return bson.M{}
+		}
+	}
+
+	if nullable {
+		switch bsonType := schema["bsonType"].(type) {
+		case string:
+			schema["bsonType"] = bson.A{bsonType, "null"}
+		case bson.A:
+			schema["bsonType"] = append(bsonType, "null")
+		}
+	}
+	return schema
+}
+
+// structSchema lists a struct's stored fields with the rules their validate tags set.
+// Unknown fields are allowed, so documents written by an older version still pass.
+func structSchema(t reflect.Type) bson.M {
+	properties := bson.M{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("bson"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		schema := bsonSchema(field.Type)
+		if applyValidateTag(schema, field.Tag.Get("validate"), field.Type) {
+			required = append(required, name)
+		}
+		properties[name] = schema
+	}
+
+	schema := bson.M{"bsonType": "object", "properties": properties}
+	// MongoDB rejects an empty required list
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// applyValidateTag copies the validate rules $jsonSchema can express onto schema and
+// reports whether the field is required. Rules after "dive" apply to elements and are
+// skipped, as are formats such as email and url.
+func applyValidateTag(schema bson.M, rules string, fieldType reflect.Type) bool {
+	for fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+	kind := fieldType.Kind()
+	isString := kind == reflect.String
+	isArray := kind == reflect.Slice || kind == reflect.Array
+
+	required, optional := false, false
+	for _, rule := range strings.Split(rules, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "dive":
+			return required
+		case "omitempty":
+			optional = true
+		case "required":
+			required = true
+			if isString {
+				schema["minLength"] = 1
+			}
+			// A required slice, map or pointer can't be nil, so can't be stored as null
+			if bsonType, ok := schema["bsonType"].(bson.A); ok && bsonType[len(bsonType)-1] == "null" {
+				if len(bsonType) == 2 {
+					schema["bsonType"] = bsonType[0]
+				} else {
+					schema["bsonType"] = bsonType[:len(bsonType)-1]
+				}
+			}
+		case "oneof":
+			values := bson.A{}
+			for _, option := range strings.Fields(value) {
+				values = append(values, option)
+			}
+			if optional {
+				values = append(values, "")
+			}
+			schema["enum"] = values
+		case "min", "max", "len", "gte", "lte", "gt":
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			switch {
+			case isString && optional:
+			case isString && (key == "min" || key == "len"):
+				schema["minLength"] = int(number)
+			case isString && key == "max":
+				schema["maxLength"] = int(number)
+			case isArray && key == "min":
+				schema["minItems"] = int(number)
+			case isArray && key == "max":
+				schema["maxItems"] = int(number)
+			case isString || isArray:
+			case key == "min" || key == "gte":
+				schema["minimum"] = number
+			case key == "max" || key == "lte":
+				schema["maximum"] = number
+			case key == "gt":
+				schema["minimum"] = number
+				schema["exclusiveMinimum"] = true
+			}
+			if isString && !optional && key == "len" {
+				schema["maxLength"] = int(number)
+			}
+		}
+	}
+	return required
+}
@@ -0,0 +1,69 @@
+package mongo
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// propertySchema follows a dotted path through a $jsonSchema's properties
+func propertySchema(t *testing.T, schema bson.M, path ...string) bson.M {
+	t.Helper()
+	for _, name := range path {
+		properties, ok := schema["properties"].(bson.M)
+		if !ok {
+			t.Fatalf("no properties above %q", name)
+		}
+		if schema, ok = properties[name].(bson.M); !ok {
+			t.Fatalf("no schema for %q", name)
+		}
+	}
+	return schema
+}
+
+// allowsNumber applies a schema's minimum and maximum as MongoDB would
+func allowsNumber(schema bson.M, value float64) bool {
+	if minimum, ok := schema["minimum"].(float64); ok {
+		if exclusive, _ := schema["exclusiveMinimum"].(bool); exclusive && value <= minimum {
+			return false
+		}
+		if value < minimum {
+			return false
+		}
+	}
+	if maximum, ok := schema["maximum"].(float64); ok && value > maximum {
+		return false
+	}
+	return true
+}
+
+func TestOrderSchemaAllowsZeroGrandTotal(t *testing.T) {
+	// A coupon worth the whole subtotal with free shipping leaves nothing to pay
+	grandTotal := propertySchema(t, collectionSchema(models.Order{}), "totals", "grand_total")
+
+	for _, tc := range []struct {
+		value float64
+		want  bool
+	}{
+		{0, true},
+		{19.99, true},
+		{-0.01, false},
+	} {
+		if got := allowsNumber(grandTotal, tc.value); got != tc.want {
+			t.Errorf("grand_total %v: allowed = %v, want %v (schema %v)", tc.value, got, tc.want, grandTotal)
+		}
+	}
+}
+
+func TestValidateTagGreaterThan(t *testing.T) {
+	// gt still excludes its bound, so a product can't be free
+	price := propertySchema(t, collectionSchema(models.Product{}), "price")
+
+	if allowsNumber(price, 0) {
+		t.Errorf("price 0 allowed by %v", price)
+	}
+	if !allowsNumber(price, 0.01) {
+		t.Errorf("price 0.01 rejected by %v", price)
+	}
+}
@@ -0,0 +1,100 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// ErrChannelNotConfigured is returned for a notification whose channel has no settings.
+// Retrying can't help, so such notifications are dead-lettered at once.
+var ErrChannelNotConfigured = errors.New("notification channel is not configured")
+
+// PermanentError wraps a failure that retrying can't fix, such as a rejected recipient
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent reports whether a delivery error should dead-letter the notification
+// instead of retrying it
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.Is(err, ErrChannelNotConfigured) || errors.As(err, &permanent)
+}
+
+// Sender delivers notifications on one channel
+type Sender interface {
+	Send(ctx context.Context, notification *models.Notification) error
+}
+
+// senders holds the configured sender of each channel
+var senders = map[string]Sender{}
+
+// Init configures each channel that has settings, and the signing of unsubscribe links.
+// Webhooks need no settings.
+func Init(cfg config.NotificationsConfig) {
+	initUnsubscribe(cfg)
+	senders = map[string]Sender{models.NotificationWebhook: &webhook{httpClient: &http.Client{Timeout: 5 * time.Second}}}
+
+	if cfg.SMTPHost == "" {
+		slog.Info("Email notifications disabled - SMTP_HOST not set")
+	} else {
+		senders[models.NotificationEmail] = newSMTP(cfg)
+		slog.Info("Notifications enabled", "channel", models.NotificationEmail, "host", cfg.SMTPHost)
+	}
+
+	if cfg.TwilioAccountSID == "" || cfg.TwilioAuthToken == "" || cfg.TwilioFromNumber == "" {
+		slog.Info("SMS notifications disabled - TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN or TWILIO_FROM_NUMBER not set")
+	} else {
+		senders[models.NotificationSMS] = newTwilio(cfg)
+		slog.Info("Notifications enabled", "channel", models.NotificationSMS)
+	}
+}
+
+// Deliver sends a notification on its channel
+func Deliver(ctx context.Context, notification *models.Notification) error {
+	sender, ok := senders[notification.Channel]
+	if !ok {
+		return ErrChannelNotConfigured
+	}
+	return sender.Send(ctx, notification)
+}
+
+// webhook posts a notification's JSON body to its recipient URL
+type webhook struct {
+	httpClient *http.Client
+}
+
+func (w *webhook) Send(ctx context.Context, notification *models.Notification) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, notification.Recipient, bytes.NewReader([]byte(notification.Body)))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
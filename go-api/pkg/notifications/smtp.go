@@ -0,0 +1,79 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// smtpSender sends plain-text email through an SMTP relay, using STARTTLS when the
+// server offers it
+type smtpSender struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+func newSMTP(cfg config.NotificationsConfig) *smtpSender {
+	sender := &smtpSender{
+		addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		host: cfg.SMTPHost,
+		from: cfg.SMTPFrom,
+	}
+	if cfg.SMTPUsername != "" {
+		sender.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return sender
+}
+
+func (s *smtpSender) Send(ctx context.Context, notification *models.Notification) error {
+	if strings.ContainsAny(notification.Recipient, "\r\n") || !strings.Contains(notification.Recipient, "@") {
+		return &PermanentError{Err: fmt.Errorf("invalid email recipient %q", notification.Recipient)}
+	}
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", s.from)
+	fmt.Fprintf(&message, "To: %s\r\n", notification.Recipient)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if notification.CustomerID != nil {
+		// One-click unsubscribe (RFC 8058) posts to the same link the body carries
+		fmt.Fprintf(&message, "List-Unsubscribe: <%s>\r\n", UnsubscribeURL(*notification.CustomerID, notification.List))
+		message.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(strings.ReplaceAll(notification.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	// net/smtp has no context support, so the send runs in the background and is
+	// abandoned if ctx ends first
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.addr, s.auth, s.from, []string{notification.Recipient}, []byte(message.String()))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			var protocolErr *textproto.Error
+			if errors.As(err, &protocolErr) && protocolErr.Code >= 500 {
+				// 5xx replies, such as an unknown mailbox, won't succeed on retry
+				return &PermanentError{Err: err}
+			}
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
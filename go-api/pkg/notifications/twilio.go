@@ -0,0 +1,73 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// twilio sends SMS through the Twilio Messages API
+type twilio struct {
+	apiBase    string
+	accountSID string
+	authToken  string
+	from       string
+	httpClient *http.Client
+}
+
+func newTwilio(cfg config.NotificationsConfig) *twilio {
+	return &twilio{
+		apiBase:    strings.TrimSuffix(cfg.TwilioAPIBase, "/"),
+		accountSID: cfg.TwilioAccountSID,
+		authToken:  cfg.TwilioAuthToken,
+		from:       cfg.TwilioFromNumber,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (t *twilio) Send(ctx context.Context, notification *models.Notification) error {
+	form := url.Values{
+		"To":   {notification.Recipient},
+		"From": {t.from},
+		"Body": {notification.Body},
+	}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", t.apiBase, url.PathEscape(t.accountSID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.accountSID, t.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure); err != nil || failure.Message == "" {
+			failure.Message = resp.Status
+		}
+		err := fmt.Errorf("twilio returned %d (%d): %s", resp.StatusCode, failure.Code, failure.Message)
+		if resp.StatusCode == http.StatusBadRequest {
+			// Invalid or unreachable numbers are rejected with 400
+			return &PermanentError{Err: err}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package notifications
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+)
+
+var (
+	unsubscribeSigningKey []byte
+	publicBaseURL         string
+)
+
+// initUnsubscribe sets the key and base URL of unsubscribe links
+func initUnsubscribe(cfg config.NotificationsConfig) {
+	publicBaseURL = cfg.PublicBaseURL
+	unsubscribeSigningKey = []byte(cfg.UnsubscribeSigningKey)
+	if len(unsubscribeSigningKey) == 0 {
+		unsubscribeSigningKey = make([]byte, 32)
+		_, _ = rand.Read(unsubscribeSigningKey)
+		slog.Warn("UNSUBSCRIBE_SIGNING_KEY is not set; unsubscribe links only work on this instance until it restarts")
+	}
+}
+
+// UnsubscribeURL is the link that takes a customer off a list. It doesn't expire, so
+// the link in an old email still works.
+func UnsubscribeURL(customerID bson.ObjectID, list string) string {
+	query := url.Values{
+		"customer":  {customerID.Hex()},
+		"list":      {list},
+		"signature": {unsubscribeSignature(customerID, list)},
+	}
+	return publicBaseURL + "/api/unsubscribe?" + query.Encode()
+}
+
+// ValidUnsubscribeSignature reports whether signature was made for the customer and list
+func ValidUnsubscribeSignature(customerID bson.ObjectID, list, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(unsubscribeSignature(customerID, list)))
+}
+
+// unsubscribeSignature signs a customer ID and list with the unsubscribe signing key
+func unsubscribeSignature(customerID bson.ObjectID, list string) string {
+	mac := hmac.New(sha256.New, unsubscribeSigningKey)
+	fmt.Fprintf(mac, "%s:%s", customerID.Hex(), list)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,150 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+)
+
+// ErrNotConfigured is returned for a provider whose credentials are unset
+var ErrNotConfigured = errors.New("payments are not configured")
+
+// ErrNoProvider is returned for a payment method that isn't paid online, such as cash
+var ErrNoProvider = errors.New("payment method has no payment provider")
+
+// Provider names, also used in webhook URLs
+const (
+	ProviderStripe = "stripe"
+	ProviderPayPal = "paypal"
+)
+
+// Checkout is a payment started with a provider: a Stripe PaymentIntent or a PayPal order
+type Checkout struct {
+	ID           string
+	Status       string // the provider's own status
+	Amount       float64
+	Currency     string
+	ClientSecret string // Stripe only, confirmed with Stripe.js
+	ApprovalURL  string // PayPal only, where the customer approves the payment
+	Reusable     bool   // whether the customer can still complete it
+}
+
+// Capture is a completed payment; TransactionID is what refunds are made against
+type Capture struct {
+	TransactionID string
+	Status        string
+	Amount        float64
+}
+
+// Refund is a refund issued through a provider
+type Refund struct {
+	ID     string  `json:"id"`
+	Status string  `json:"status"`
+	Amount float64 `json:"amount"`
+}
+
+// Provider is a payment service that orders can be paid through. Each one starts
+// payments, refunds them and turns its signed webhooks into WebhookEvents.
+type Provider interface {
+	Name() string
+	// CreatePayment starts a payment of amount for an order. Retrying with the same
+	// order and amount returns the same payment.
+	CreatePayment(ctx context.Context, orderNumber string, amount float64) (*Checkout, error)
+	// GetPayment fetches a payment started by CreatePayment
+	GetPayment(ctx context.Context, id string) (*Checkout, error)
+	// Refund returns amount of a completed payment. refundedSoFar, the total already
+	// refunded, keys the request so a retry doesn't refund twice.
+	Refund(ctx context.Context, transactionID string, amount float64, refundedSoFar float64, reason string) (*Refund, error)
+	// ParseWebhook verifies a webhook request and returns its event
+	ParseWebhook(ctx context.Context, payload []byte, header http.Header) (*WebhookEvent, error)
+}
+
+// Capturer is implemented by providers whose payments are captured by the API once the
+// customer approves them, rather than automatically
+type Capturer interface {
+	Capture(ctx context.Context, id string) (*Capture, error)
+}
+
+// providers holds the configured providers by name
+var providers = map[string]Provider{}
+
+// currency is charged for every order
+var currency = "cad"
+
+// Init configures each provider that has credentials; orders can't be paid with a
+// method whose provider isn't configured
+func Init(cfg config.PaymentsConfig) {
+	currency = cfg.Currency
+	providers = map[string]Provider{}
+
+	if cfg.StripeSecretKey == "" {
+		slog.Info("Stripe payments disabled - STRIPE_SECRET_KEY not set")
+	} else {
+		if cfg.StripeWebhookSecret == "" {
+			slog.Warn("STRIPE_WEBHOOK_SECRET not set - Stripe webhooks will be rejected")
+		}
+		providers[ProviderStripe] = newStripe(cfg)
+		slog.Info("Payments enabled", "provider", ProviderStripe, "currency", currency)
+	}
+
+	if cfg.PayPalClientID == "" || cfg.PayPalClientSecret == "" {
+		slog.Info("PayPal payments disabled - PAYPAL_CLIENT_ID or PAYPAL_CLIENT_SECRET not set")
+	} else {
+		if cfg.PayPalWebhookID == "" {
+			slog.Warn("PAYPAL_WEBHOOK_ID not set - PayPal webhooks will be rejected")
+		}
+		providers[ProviderPayPal] = newPayPal(cfg)
+		slog.Info("Payments enabled", "provider", ProviderPayPal, "currency", currency)
+	}
+}
+
+// Get returns the configured provider with the given name
+func Get(name string) (Provider, bool) {
+	provider, ok := providers[name]
+	return provider, ok
+}
+
+// ProviderName is the provider an order's payment method is paid through, or "" for
+// methods paid offline
+func ProviderName(method string) string {
+	switch method {
+	case "credit_card", "debit_card":
+		return ProviderStripe
+	case "paypal":
+		return ProviderPayPal
+	default:
+		return ""
+	}
+}
+
+// ForMethod returns the provider that takes payments by method
+func ForMethod(method string) (Provider, error) {
+	name := ProviderName(method)
+	if name == "" {
+		return nil, ErrNoProvider
+	}
+	provider, ok := providers[name]
+	if !ok {
+		return nil, ErrNotConfigured
+	}
+	return provider, nil
+}
+
+// Currency is the lowercase ISO code orders are charged in
+func Currency() string {
+	return currency
+}
+
+// ToCents converts a dollar amount to the smallest currency unit
+func ToCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// FromCents converts an amount in cents back to dollars
+func FromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
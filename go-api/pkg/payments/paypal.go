@@ -0,0 +1,358 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+)
+
+// PayPal webhook event types the API acts on
+const (
+	paypalCaptureCompleted = "PAYMENT.CAPTURE.COMPLETED"
+	paypalCaptureDenied    = "PAYMENT.CAPTURE.DENIED"
+	paypalCaptureRefunded  = "PAYMENT.CAPTURE.REFUNDED"
+)
+
+// paypalAmount is PayPal's money object, with the value as a decimal string
+type paypalAmount struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+func newPayPalAmount(amount float64) paypalAmount {
+	return paypalAmount{
+		CurrencyCode: strings.ToUpper(currency),
+		Value:        strconv.FormatFloat(FromCents(ToCents(amount)), 'f', 2, 64),
+	}
+}
+
+func (a paypalAmount) float() float64 {
+	value, _ := strconv.ParseFloat(a.Value, 64)
+	return value
+}
+
+type paypalLink struct {
+	Href string `json:"href"`
+	Rel  string `json:"rel"`
+}
+
+// paypalCapture is the part of a PayPal capture the API uses, from capture responses
+// and PAYMENT.CAPTURE.* events
+type paypalCapture struct {
+	ID                string       `json:"id"`
+	Status            string       `json:"status"`
+	Amount            paypalAmount `json:"amount"`
+	CustomID          string       `json:"custom_id"`
+	SupplementaryData struct {
+		RelatedIDs struct {
+			OrderID string `json:"order_id"`
+		} `json:"related_ids"`
+	} `json:"supplementary_data"`
+}
+
+// paypalOrder is the part of a PayPal order the API uses
+type paypalOrder struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	PurchaseUnits []struct {
+		Amount   paypalAmount `json:"amount"`
+		Payments struct {
+			Captures []paypalCapture `json:"captures"`
+		} `json:"payments"`
+	} `json:"purchase_units"`
+	Links []paypalLink `json:"links"`
+}
+
+func (o *paypalOrder) checkout() *Checkout {
+	checkout := &Checkout{ID: o.ID, Status: o.Status, Currency: strings.ToLower(currency)}
+	if len(o.PurchaseUnits) > 0 {
+		checkout.Amount = o.PurchaseUnits[0].Amount.float()
+		checkout.Currency = strings.ToLower(o.PurchaseUnits[0].Amount.CurrencyCode)
+	}
+	for _, link := range o.Links {
+		if link.Rel == "approve" || link.Rel == "payer-action" {
+			checkout.ApprovalURL = link.Href
+		}
+	}
+	switch o.Status {
+	case "CREATED", "SAVED", "APPROVED", "PAYER_ACTION_REQUIRED":
+		checkout.Reusable = true
+	}
+	return checkout
+}
+
+// paypalRefund is the part of a PayPal refund the API uses, from PAYMENT.CAPTURE.REFUNDED
+// events. The "up" link points at the refunded capture.
+type paypalRefund struct {
+	ID                     string       `json:"id"`
+	Status                 string       `json:"status"`
+	Amount                 paypalAmount `json:"amount"`
+	SellerPayableBreakdown struct {
+		TotalRefundedAmount paypalAmount `json:"total_refunded_amount"`
+	} `json:"seller_payable_breakdown"`
+	Links []paypalLink `json:"links"`
+}
+
+// paypalEvent is a PayPal webhook event. Resource holds the capture or refund it is about.
+type paypalEvent struct {
+	ID        string          `json:"id"`
+	EventType string          `json:"event_type"`
+	Resource  json.RawMessage `json:"resource"`
+}
+
+// PayPalError is returned when PayPal answers with an error status
+type PayPalError struct {
+	StatusCode int
+	Name       string `json:"name"`
+	Message    string `json:"message"`
+	DebugID    string `json:"debug_id"`
+}
+
+func (e *PayPalError) Error() string {
+	return fmt.Sprintf("paypal returned %d (%s): %s", e.StatusCode, e.Name, e.Message)
+}
+
+// paypal takes payments through PayPal Checkout orders. The customer approves the order
+// on PayPal, then the API captures it.
+type paypal struct {
+	apiBase      string
+	clientID     string
+	clientSecret string
+	webhookID    string
+	httpClient   *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+func newPayPal(cfg config.PaymentsConfig) *paypal {
+	return &paypal{
+		apiBase:      strings.TrimSuffix(cfg.PayPalAPIBase, "/"),
+		clientID:     cfg.PayPalClientID,
+		clientSecret: cfg.PayPalClientSecret,
+		webhookID:    cfg.PayPalWebhookID,
+		httpClient:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *paypal) Name() string {
+	return ProviderPayPal
+}
+
+// CreatePayment creates a PayPal order with the order number as its custom_id, so
+// webhooks can find the order
+func (p *paypal) CreatePayment(ctx context.Context, orderNumber string, amount float64) (*Checkout, error) {
+	body := map[string]interface{}{
+		"intent": "CAPTURE",
+		"purchase_units": []map[string]interface{}{{
+			"reference_id": orderNumber,
+			"custom_id":    orderNumber,
+			"amount":       newPayPalAmount(amount),
+		}},
+	}
+
+	var order paypalOrder
+	requestID := fmt.Sprintf("paypal-order-%s-%d", orderNumber, ToCents(amount))
+	if err := p.do(ctx, http.MethodPost, "/v2/checkout/orders", body, requestID, &order); err != nil {
+		return nil, err
+	}
+	return order.checkout(), nil
+}
+
+func (p *paypal) GetPayment(ctx context.Context, id string) (*Checkout, error) {
+	var order paypalOrder
+	if err := p.do(ctx, http.MethodGet, "/v2/checkout/orders/"+url.PathEscape(id), nil, "", &order); err != nil {
+		return nil, err
+	}
+	return order.checkout(), nil
+}
+
+// Capture collects an order the customer approved. Capturing twice returns the first
+// capture.
+func (p *paypal) Capture(ctx context.Context, id string) (*Capture, error) {
+	var order paypalOrder
+	if err := p.do(ctx, http.MethodPost, "/v2/checkout/orders/"+url.PathEscape(id)+"/capture", map[string]interface{}{}, "paypal-capture-"+id, &order); err != nil {
+		return nil, err
+	}
+	if len(order.PurchaseUnits) == 0 || len(order.PurchaseUnits[0].Payments.Captures) == 0 {
+		return nil, fmt.Errorf("paypal order %s has no capture", id)
+	}
+	capture := order.PurchaseUnits[0].Payments.Captures[0]
+	return &Capture{TransactionID: capture.ID, Status: capture.Status, Amount: capture.Amount.float()}, nil
+}
+
+// Refund refunds part or all of a capture
+func (p *paypal) Refund(ctx context.Context, transactionID string, amount float64, refundedSoFar float64, reason string) (*Refund, error) {
+	body := map[string]interface{}{"amount": newPayPalAmount(amount)}
+	if reason != "" {
+		body["note_to_payer"] = reason
+	}
+
+	var refund paypalRefund
+	requestID := fmt.Sprintf("paypal-refund-%s-%d-%d", transactionID, ToCents(refundedSoFar), ToCents(amount))
+	if err := p.do(ctx, http.MethodPost, "/v2/payments/captures/"+url.PathEscape(transactionID)+"/refund", body, requestID, &refund); err != nil {
+		return nil, err
+	}
+	return &Refund{ID: refund.ID, Status: refund.Status, Amount: amount}, nil
+}
+
+// ParseWebhook asks PayPal to verify the transmission headers against the webhook ID,
+// then maps capture and refund events; any other event type comes back with an empty Type
+func (p *paypal) ParseWebhook(ctx context.Context, payload []byte, header http.Header) (*WebhookEvent, error) {
+	if p.webhookID == "" {
+		return nil, ErrInvalidSignature
+	}
+	sent, err := time.Parse(time.RFC3339, header.Get("Paypal-Transmission-Time"))
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if age := time.Since(sent); age > webhookTolerance || age < -webhookTolerance {
+		return nil, ErrInvalidSignature
+	}
+	var event paypalEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	verification := map[string]interface{}{
+		"auth_algo":         header.Get("Paypal-Auth-Algo"),
+		"cert_url":          header.Get("Paypal-Cert-Url"),
+		"transmission_id":   header.Get("Paypal-Transmission-Id"),
+		"transmission_sig":  header.Get("Paypal-Transmission-Sig"),
+		"transmission_time": header.Get("Paypal-Transmission-Time"),
+		"webhook_id":        p.webhookID,
+		"webhook_event":     json.RawMessage(payload),
+	}
+	var verified struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/v1/notifications/verify-webhook-signature", verification, "", &verified); err != nil {
+		return nil, err
+	}
+	if verified.VerificationStatus != "SUCCESS" {
+		return nil, ErrInvalidSignature
+	}
+
+	parsed := &WebhookEvent{ID: event.ID, Provider: ProviderPayPal}
+	switch event.EventType {
+	case paypalCaptureCompleted, paypalCaptureDenied:
+		var capture paypalCapture
+		if err := json.Unmarshal(event.Resource, &capture); err != nil {
+			return nil, err
+		}
+		parsed.OrderNumber = capture.CustomID
+		parsed.PaymentID = capture.SupplementaryData.RelatedIDs.OrderID
+		parsed.TransactionID = capture.ID
+		parsed.Amount = capture.Amount.float()
+		if event.EventType == paypalCaptureCompleted {
+			parsed.Type = EventPaymentSucceeded
+		} else {
+			parsed.Type = EventPaymentFailed
+			parsed.FailureMessage = "PayPal denied the payment"
+		}
+	case paypalCaptureRefunded:
+		var refund paypalRefund
+		if err := json.Unmarshal(event.Resource, &refund); err != nil {
+			return nil, err
+		}
+		for _, link := range refund.Links {
+			if link.Rel == "up" {
+				parsed.TransactionID = path.Base(link.Href)
+			}
+		}
+		parsed.Type = EventRefunded
+		parsed.Refunded = refund.SellerPayableBreakdown.TotalRefundedAmount.float()
+	}
+	return parsed, nil
+}
+
+// token returns a cached OAuth access token, fetching a new one shortly before it expires
+func (p *paypal) token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.accessToken != "" && time.Now().Before(p.expiresAt) {
+		return p.accessToken, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiBase+"/v1/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(p.clientID, p.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"` // seconds
+	}
+	if err := p.send(req, &token); err != nil {
+		return "", err
+	}
+
+	p.accessToken = token.AccessToken
+	p.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return p.accessToken, nil
+}
+
+// do sends a JSON request to PayPal and decodes a successful response into result. A
+// non-empty requestID makes retries of the request idempotent.
+func (p *paypal) do(ctx context.Context, method, path string, body interface{}, requestID string, result interface{}) error {
+	token, err := p.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.apiBase+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if requestID != "" {
+		req.Header.Set("PayPal-Request-Id", requestID)
+	}
+	return p.send(req, result)
+}
+
+func (p *paypal) send(req *http.Request, result interface{}) error {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		failure := PayPalError{StatusCode: resp.StatusCode}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure); err != nil || failure.Message == "" {
+			failure.Message = resp.Status
+		}
+		return &failure
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,236 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+)
+
+// Stripe webhook event types the API acts on
+const (
+	stripePaymentSucceeded = "payment_intent.succeeded"
+	stripePaymentFailed    = "payment_intent.payment_failed"
+	stripeChargeRefunded   = "charge.refunded"
+)
+
+// stripeIntent is the part of a Stripe PaymentIntent the API uses
+type stripeIntent struct {
+	ID               string            `json:"id"`
+	Status           string            `json:"status"`
+	Amount           int64             `json:"amount"` // in cents
+	Currency         string            `json:"currency"`
+	ClientSecret     string            `json:"client_secret"`
+	Metadata         map[string]string `json:"metadata"`
+	LastPaymentError *struct {
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+}
+
+func (pi *stripeIntent) checkout() *Checkout {
+	checkout := &Checkout{
+		ID:           pi.ID,
+		Status:       pi.Status,
+		Amount:       FromCents(pi.Amount),
+		Currency:     pi.Currency,
+		ClientSecret: pi.ClientSecret,
+	}
+	switch pi.Status {
+	case "requires_payment_method", "requires_confirmation", "requires_action":
+		checkout.Reusable = true
+	}
+	return checkout
+}
+
+// stripeCharge is the part of a Stripe Charge the API uses, read from charge.refunded events
+type stripeCharge struct {
+	ID             string `json:"id"`
+	PaymentIntent  string `json:"payment_intent"`
+	Amount         int64  `json:"amount"`          // in cents
+	AmountRefunded int64  `json:"amount_refunded"` // in cents, across every refund
+}
+
+// stripeEvent is a Stripe webhook event. Data.Object holds the PaymentIntent or Charge
+// it is about.
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// StripeError is returned when Stripe answers with an error status
+type StripeError struct {
+	StatusCode int
+	Type       string `json:"type"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *StripeError) Error() string {
+	return fmt.Sprintf("stripe returned %d (%s): %s", e.StatusCode, e.Type, e.Message)
+}
+
+// stripe takes card payments through Stripe PaymentIntents, which capture as soon as the
+// customer confirms them with Stripe.js
+type stripe struct {
+	apiBase       string
+	secretKey     string
+	webhookSecret string
+	httpClient    *http.Client
+}
+
+func newStripe(cfg config.PaymentsConfig) *stripe {
+	return &stripe{
+		apiBase:       strings.TrimSuffix(cfg.StripeAPIBase, "/"),
+		secretKey:     cfg.StripeSecretKey,
+		webhookSecret: cfg.StripeWebhookSecret,
+		httpClient:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (s *stripe) Name() string {
+	return ProviderStripe
+}
+
+// CreatePayment creates a PaymentIntent with the order number in its metadata, so
+// webhooks can find the order
+func (s *stripe) CreatePayment(ctx context.Context, orderNumber string, amount float64) (*Checkout, error) {
+	cents := ToCents(amount)
+	form := url.Values{
+		"amount":                             {strconv.FormatInt(cents, 10)},
+		"currency":                           {currency},
+		"metadata[order_number]":             {orderNumber},
+		"automatic_payment_methods[enabled]": {"true"},
+	}
+
+	var intent stripeIntent
+	idempotencyKey := fmt.Sprintf("payment-intent-%s-%d", orderNumber, cents)
+	if err := s.do(ctx, http.MethodPost, "/v1/payment_intents", form, idempotencyKey, &intent); err != nil {
+		return nil, err
+	}
+	return intent.checkout(), nil
+}
+
+func (s *stripe) GetPayment(ctx context.Context, id string) (*Checkout, error) {
+	var intent stripeIntent
+	if err := s.do(ctx, http.MethodGet, "/v1/payment_intents/"+url.PathEscape(id), nil, "", &intent); err != nil {
+		return nil, err
+	}
+	return intent.checkout(), nil
+}
+
+// Refund refunds part or all of a succeeded PaymentIntent
+func (s *stripe) Refund(ctx context.Context, transactionID string, amount float64, refundedSoFar float64, reason string) (*Refund, error) {
+	cents := ToCents(amount)
+	form := url.Values{
+		"payment_intent": {transactionID},
+		"amount":         {strconv.FormatInt(cents, 10)},
+	}
+	if reason != "" {
+		// Stripe's own reason field only takes a few fixed values
+		form.Set("metadata[reason]", reason)
+	}
+
+	var refund struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+		Amount int64  `json:"amount"`
+	}
+	idempotencyKey := fmt.Sprintf("refund-%s-%d-%d", transactionID, ToCents(refundedSoFar), cents)
+	if err := s.do(ctx, http.MethodPost, "/v1/refunds", form, idempotencyKey, &refund); err != nil {
+		return nil, err
+	}
+	return &Refund{ID: refund.ID, Status: refund.Status, Amount: FromCents(refund.Amount)}, nil
+}
+
+// ParseWebhook checks the Stripe-Signature header and maps PaymentIntent and refund
+// events; any other event type comes back with an empty Type
+func (s *stripe) ParseWebhook(ctx context.Context, payload []byte, header http.Header) (*WebhookEvent, error) {
+	if !verifyStripeSignature(s.webhookSecret, payload, header.Get("Stripe-Signature"), time.Now()) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event stripeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	parsed := &WebhookEvent{ID: event.ID, Provider: ProviderStripe}
+	switch event.Type {
+	case stripePaymentSucceeded, stripePaymentFailed:
+		var intent stripeIntent
+		if err := json.Unmarshal(event.Data.Object, &intent); err != nil {
+			return nil, err
+		}
+		parsed.OrderNumber = intent.Metadata["order_number"]
+		parsed.PaymentID = intent.ID
+		parsed.TransactionID = intent.ID
+		parsed.Amount = FromCents(intent.Amount)
+		if event.Type == stripePaymentSucceeded {
+			parsed.Type = EventPaymentSucceeded
+		} else {
+			parsed.Type = EventPaymentFailed
+			parsed.FailureMessage = "The payment was declined"
+			if intent.LastPaymentError != nil && intent.LastPaymentError.Message != "" {
+				parsed.FailureMessage = intent.LastPaymentError.Message
+			}
+		}
+	case stripeChargeRefunded:
+		var charge stripeCharge
+		if err := json.Unmarshal(event.Data.Object, &charge); err != nil {
+			return nil, err
+		}
+		parsed.Type = EventRefunded
+		parsed.TransactionID = charge.PaymentIntent
+		parsed.Refunded = FromCents(charge.AmountRefunded)
+	}
+	return parsed, nil
+}
+
+// do sends a form-encoded request to Stripe and decodes a successful response into result
+func (s *stripe) do(ctx context.Context, method, path string, form url.Values, idempotencyKey string, result interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.apiBase+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.secretKey, "")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Error StripeError `json:"error"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure); err != nil {
+			failure.Error.Message = resp.Status
+		}
+		failure.Error.StatusCode = resp.StatusCode
+		return &failure.Error
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,79 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook event types the API acts on, the same for every provider
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+	EventRefunded         = "payment.refunded"
+)
+
+// webhookTolerance is how old a signed webhook may be, limiting replays
+const webhookTolerance = 5 * time.Minute
+
+// ErrInvalidSignature is returned for a webhook the provider did not sign or that is too old
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// WebhookEvent is a provider's webhook reduced to what the API acts on. Type is empty
+// for events the API ignores.
+type WebhookEvent struct {
+	ID             string
+	Provider       string
+	Type           string
+	OrderNumber    string  // set for payment events
+	PaymentID      string  // the Stripe PaymentIntent or PayPal order, for payment events
+	TransactionID  string  // what refunds are made against
+	Amount         float64 // charged, for succeeded payments
+	Refunded       float64 // total refunded so far, for refunds
+	FailureMessage string  // why a payment failed
+}
+
+// verifyStripeSignature checks a Stripe-Signature header against the raw payload. The
+// header holds a timestamp t and one or more v1 HMAC-SHA256 signatures of "t.payload".
+func verifyStripeSignature(secret string, payload []byte, signatureHeader string, now time.Time) bool {
+	if secret == "" {
+		return false
+	}
+
+	var timestamp int64
+	var signatures []string
+	for _, part := range strings.Split(signatureHeader, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return false
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > webhookTolerance || age < -webhookTolerance {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	redisclient "github.com/redis/go-redis/v9"
+)
+
+// aiReportCachePrefix namespaces cached AI report responses
+const aiReportCachePrefix = "ai:report:"
+
+// AIReportCacheTTL returns how long AI report insights are reused
+func AIReportCacheTTL() time.Duration {
+	return settings.AIReportCacheTTL
+}
+
+// GetCachedAIReport returns the cached AI report JSON for key, or false on a cache miss
+func GetCachedAIReport(ctx context.Context, key string) (json.RawMessage, bool, error) {
+	client := RedisClient()
+
+	data, err := client.Get(ctx, aiReportCachePrefix+key).Bytes()
+	if err == redisclient.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return json.RawMessage(data), true, nil
+}
+
+// CacheAIReport stores an AI report response under key for the configured TTL
+func CacheAIReport(ctx context.Context, key string, report interface{}) error {
+	client := RedisClient()
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal AI report: %w", err)
+	}
+
+	return client.Set(ctx, aiReportCachePrefix+key, data, AIReportCacheTTL()).Err()
+}
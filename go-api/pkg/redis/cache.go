@@ -0,0 +1,162 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	redisclient "github.com/redis/go-redis/v9"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+)
+
+// X-Cache values reported by Fetch
+const (
+	CacheHit       = "HIT"
+	CacheMiss      = "MISS"
+	CacheRefreshed = "REFRESHED"
+	CacheBypass    = "BYPASS" // the entity isn't cached
+)
+
+// Cache stores one kind of data as JSON under "<prefix><id>", with its own strategy
+// (config.CacheAside, config.WriteThrough or config.NoCache) and TTL
+type Cache struct {
+	name     string
+	prefix   string
+	strategy string
+	ttl      time.Duration
+}
+
+var (
+	// Products are keyed by SKU
+	Products = &Cache{name: "product", prefix: "product:", strategy: config.WriteThrough, ttl: 24 * time.Hour}
+	// Categories are keyed by the language they are sorted in
+	Categories = &Cache{name: "category", prefix: "category:", strategy: config.CacheAside, ttl: time.Hour}
+	// Analytics results are keyed by route and query string
+	Analytics = &Cache{name: "analytics", prefix: "analytics:", strategy: config.CacheAside, ttl: 5 * time.Minute}
+)
+
+// configureCaches sets each cache's strategy and TTL from cfg
+func configureCaches(cfg config.RedisConfig) {
+	for _, c := range []struct {
+		cache  *Cache
+		config config.CacheConfig
+	}{
+		{Products, cfg.Products},
+		{Categories, cfg.Categories},
+		{Analytics, cfg.Analytics},
+	} {
+		if c.config.Strategy != "" {
+			c.cache.strategy = c.config.Strategy
+		}
+		if c.config.TTL > 0 {
+			c.cache.ttl = c.config.TTL
+		}
+	}
+}
+
+// Enabled reports whether the data is cached at all
+func (c *Cache) Enabled() bool {
+	return c.strategy != config.NoCache
+}
+
+// WritesThrough reports whether a change should store the new value rather than drop
+// the cached one
+func (c *Cache) WritesThrough() bool {
+	return c.strategy == config.WriteThrough
+}
+
+// TTL returns how long entries live
+func (c *Cache) TTL() time.Duration {
+	return c.ttl
+}
+
+// Key returns the Redis key for id
+func (c *Cache) Key(id string) string {
+	return c.prefix + id
+}
+
+// Get fills dest from the entry for id, returning false on a miss or when the data
+// isn't cached
+func (c *Cache) Get(ctx context.Context, id string, dest interface{}) (bool, error) {
+	if !c.Enabled() {
+		return false, nil
+	}
+
+	data, err := RedisClient().Get(ctx, c.Key(id)).Bytes()
+	if err == redisclient.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := json.Unmarshal(data, dest); err != nil {
+		return false, fmt.Errorf("failed to unmarshal cached %s %s: %w", c.name, id, err)
+	}
+	return true, nil
+}
+
+// Set stores value under id for the TTL. It does nothing when the data isn't cached.
+func (c *Cache) Set(ctx context.Context, id string, value interface{}) error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s %s: %w", c.name, id, err)
+	}
+	return RedisClient().Set(ctx, c.Key(id), data, c.ttl).Err()
+}
+
+// Delete drops the entries for ids
+func (c *Cache) Delete(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = c.Key(id)
+	}
+	return RedisClient().Del(ctx, keys...).Err()
+}
+
+// Clear drops every entry and returns how many were removed
+func (c *Cache) Clear(ctx context.Context) (int64, error) {
+	return deleteKeys(ctx, c.prefix+"*")
+}
+
+// Fetch is the read path for every strategy: it fills dest from the entry for id, or
+// runs load (which must populate dest) and caches the result. refresh skips the cached
+// copy and stores a fresh one. Cache errors are logged rather than returned, so Redis
+// being down only costs the lookup. It returns the X-Cache value to report.
+func (c *Cache) Fetch(ctx context.Context, id string, refresh bool, dest interface{}, load func() error) (string, error) {
+	if !c.Enabled() {
+		return CacheBypass, load()
+	}
+
+	if !refresh {
+		found, err := c.Get(ctx, id, dest)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to read cache", "cache", c.name, "key", c.Key(id), "error", err)
+		} else if found {
+			return CacheHit, nil
+		}
+	}
+
+	if err := load(); err != nil {
+		return "", err
+	}
+
+	if err := c.Set(ctx, id, dest); err != nil {
+		slog.WarnContext(ctx, "Failed to write cache", "cache", c.name, "key", c.Key(id), "error", err)
+	}
+
+	if refresh {
+		return CacheRefreshed, nil
+	}
+	return CacheMiss, nil
+}
@@ -0,0 +1,143 @@
+package redis
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// cacheFlushPatterns are the keys removed when an admin flushes each cache prefix.
+// Flushing products also drops the document ID keys that point at the cached products.
+var cacheFlushPatterns = map[string][]string{
+	Products.prefix:   {Products.Key("*"), productIDKey("*")},
+	Categories.prefix: {Categories.Key("*")},
+	Analytics.prefix:  {Analytics.Key("*")},
+}
+
+// productDraftPrefix holds AI description drafts awaiting review, which are not cache
+// and survive a product flush
+const productDraftPrefix = "product:draft:"
+
+// cacheScanBatch is how many keys each SCAN asks for and each DEL removes
+const cacheScanBatch = 500
+
+// FlushableCachePrefix reports whether prefix is one FlushCachePrefix accepts
+func FlushableCachePrefix(prefix string) bool {
+	_, ok := cacheFlushPatterns[prefix]
+	return ok
+}
+
+// GetCacheStats counts the keys under each prefix and reads Redis's memory use. It scans
+// the whole keyspace, so it is meant for occasional admin use.
+func GetCacheStats(ctx context.Context) (*models.CacheStats, error) {
+	client := RedisClient()
+
+	counts := map[string]int64{}
+	var total int64
+	iter := client.Scan(ctx, 0, "*", cacheScanBatch).Iterator()
+	for iter.Next(ctx) {
+		prefix := ""
+		if i := strings.Index(iter.Val(), ":"); i >= 0 {
+			prefix = iter.Val()[:i+1]
+		}
+		counts[prefix]++
+		total++
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	stats := &models.CacheStats{TotalKeys: total, Prefixes: make([]models.CachePrefixStats, 0, len(counts))}
+	for prefix, keys := range counts {
+		stats.Prefixes = append(stats.Prefixes, models.CachePrefixStats{Prefix: prefix, Keys: keys})
+	}
+	sort.Slice(stats.Prefixes, func(i, j int) bool {
+		if stats.Prefixes[i].Keys != stats.Prefixes[j].Keys {
+			return stats.Prefixes[i].Keys > stats.Prefixes[j].Keys
+		}
+		return stats.Prefixes[i].Prefix < stats.Prefixes[j].Prefix
+	})
+
+	info, err := client.Info(ctx, "memory").Result()
+	if err != nil {
+		return nil, err
+	}
+	stats.Memory = parseMemoryInfo(info)
+	return stats, nil
+}
+
+// parseMemoryInfo reads the fields of INFO memory that CacheMemory reports
+func parseMemoryInfo(info string) models.CacheMemory {
+	var memory models.CacheMemory
+	for _, line := range strings.Split(info, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "used_memory":
+			memory.UsedBytes, _ = strconv.ParseInt(value, 10, 64)
+		case "used_memory_human":
+			memory.UsedHuman = value
+		case "used_memory_peak":
+			memory.PeakBytes, _ = strconv.ParseInt(value, 10, 64)
+		case "maxmemory":
+			memory.MaxBytes, _ = strconv.ParseInt(value, 10, 64)
+		case "maxmemory_policy":
+			memory.EvictionPolicy = value
+		}
+	}
+	return memory
+}
+
+// FlushCachePrefix deletes the cached keys under a flushable prefix and returns how many
+// were removed
+func FlushCachePrefix(ctx context.Context, prefix string) (int64, error) {
+	var deleted int64
+	for _, pattern := range cacheFlushPatterns[prefix] {
+		removed, err := deleteKeys(ctx, pattern)
+		deleted += removed
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// deleteKeys deletes every key matching pattern, except product drafts, and returns how
+// many were removed
+func deleteKeys(ctx context.Context, pattern string) (int64, error) {
+	client := RedisClient()
+
+	var deleted int64
+	batch := make([]string, 0, cacheScanBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		removed, err := client.Del(ctx, batch...).Result()
+		deleted += removed
+		batch = batch[:0]
+		return err
+	}
+
+	iter := client.Scan(ctx, 0, pattern, cacheScanBatch).Iterator()
+	for iter.Next(ctx) {
+		if strings.HasPrefix(iter.Val(), productDraftPrefix) {
+			continue
+		}
+		batch = append(batch, iter.Val())
+		if len(batch) == cacheScanBatch {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	return deleted, flush()
+}
@@ -0,0 +1,71 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+const (
+	// chatHistoryTTL is how long an idle assistant conversation is remembered
+	chatHistoryTTL = 24 * time.Hour
+	// chatHistoryLimit caps how many messages are kept per conversation
+	chatHistoryLimit = 20
+)
+
+// chatKey returns the Redis key holding a conversation's messages
+func chatKey(sessionID string) string {
+	return fmt.Sprintf("ai:chat:%s", sessionID)
+}
+
+// GetChatHistory returns the stored messages for a conversation, oldest first
+func GetChatHistory(ctx context.Context, sessionID string) ([]models.ChatMessage, error) {
+	client := RedisClient()
+
+	entries, err := client.LRange(ctx, chatKey(sessionID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]models.ChatMessage, 0, len(entries))
+	for _, entry := range entries {
+		var message models.ChatMessage
+		if err := json.Unmarshal([]byte(entry), &message); err != nil {
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// AppendChatMessages adds messages to a conversation, keeping only the most recent ones
+// and refreshing its expiry
+func AppendChatMessages(ctx context.Context, sessionID string, messages ...models.ChatMessage) error {
+	client := RedisClient()
+
+	key := chatKey(sessionID)
+	pipe := client.TxPipeline()
+	for _, message := range messages {
+		messageJSON, err := json.Marshal(message)
+		if err != nil {
+			return fmt.Errorf("failed to marshal chat message: %w", err)
+		}
+		pipe.RPush(ctx, key, messageJSON)
+	}
+	pipe.LTrim(ctx, key, -chatHistoryLimit, -1)
+	pipe.Expire(ctx, key, chatHistoryTTL)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ClearChatHistory forgets a conversation
+func ClearChatHistory(ctx context.Context, sessionID string) error {
+	client := RedisClient()
+
+	return client.Del(ctx, chatKey(sessionID)).Err()
+}
@@ -0,0 +1,27 @@
+package redis
+
+import (
+	"context"
+
+	redisclient "github.com/redis/go-redis/v9"
+)
+
+// domainEventsChannel is the pub/sub channel every instance publishes and listens on
+const domainEventsChannel = "events:domain"
+
+// PublishDomainEvent broadcasts an encoded domain event to every subscribed instance
+func PublishDomainEvent(ctx context.Context, payload []byte) error {
+	client := RedisClient()
+
+	return client.Publish(ctx, domainEventsChannel, payload).Err()
+}
+
+// SubscribeDomainEvents listens for domain events until the returned close function is
+// called. The channel is closed when the subscription ends; go-redis reconnects on its
+// own, and events published while disconnected are not replayed.
+func SubscribeDomainEvents(ctx context.Context) (<-chan *redisclient.Message, func() error) {
+	client := RedisClient()
+
+	pubsub := client.Subscribe(ctx, domainEventsChannel)
+	return pubsub.Channel(), pubsub.Close
+}
@@ -1,15 +1,54 @@
 package redis
 
 import (
+	"log/slog"
+	"sync"
+
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
-	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+)
+
+var (
+	client     *redis.Client
+	clientOnce sync.Once
+	settings   config.RedisConfig
 )
 
+// Configure sets the server address and each cache's strategy and lifetime. Call it
+// before the first RedisClient call.
+func Configure(cfg config.RedisConfig) {
+	settings = cfg
+	configureCaches(cfg)
+}
+
+// RedisClient returns the shared Redis client, creating it on first use.
+// The client pools its own connections, so callers must not close it.
 func RedisClient() *redis.Client {
-	return redis.NewClient(&redis.Options{
-		Addr:     global.GetEnvOrDefault("REDIS_ADDRESS", "localhost:6379"),
-		Password: global.GetEnvOrDefault("REDIS_PASSWORD", ""),
-		DB:       0,
-		Protocol: 2,
+	clientOnce.Do(func() {
+		client = redis.NewClient(&redis.Options{
+			Addr:     settings.Address,
+			Password: settings.Password,
+			DB:       0,
+			Protocol: 2,
+		})
+		if err := redisotel.InstrumentTracing(client); err != nil {
+			slog.Warn("Failed to instrument Redis tracing", "error", err)
+		}
 	})
+	return client
+}
+
+// Close closes the shared client and its pooled connections. Call it once on shutdown.
+func Close() error {
+	if client == nil {
+		return nil
+	}
+
+	if err := client.Close(); err != nil {
+		return err
+	}
+
+	slog.Info("Disconnected from Redis")
+	return nil
 }
@@ -0,0 +1,45 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// funnelKeyRetention keeps daily funnel counters long enough for year-over-year comparisons
+const funnelKeyRetention = 400 * 24 * time.Hour
+
+// funnelKey returns the daily HyperLogLog key for a funnel stage
+func funnelKey(stage string, day time.Time) string {
+	return fmt.Sprintf("funnel:%s:%s", stage, day.UTC().Format("2006-01-02"))
+}
+
+// TrackFunnelEvent records that a session reached a funnel stage today.
+// Sessions are counted once per stage per day using a HyperLogLog.
+func TrackFunnelEvent(ctx context.Context, stage, sessionID string) error {
+	client := RedisClient()
+
+	key := funnelKey(stage, time.Now())
+
+	pipe := client.TxPipeline()
+	pipe.PFAdd(ctx, key, sessionID)
+	pipe.Expire(ctx, key, funnelKeyRetention)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// CountFunnelEvents returns the approximate number of distinct sessions that reached
+// a funnel stage between start and end (inclusive, by UTC day)
+func CountFunnelEvents(ctx context.Context, stage string, start, end time.Time) (int64, error) {
+	client := RedisClient()
+
+	var keys []string
+	for day := start.UTC().Truncate(24 * time.Hour); !day.After(end.UTC()); day = day.Add(24 * time.Hour) {
+		keys = append(keys, funnelKey(stage, day))
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	return client.PFCount(ctx, keys...).Result()
+}
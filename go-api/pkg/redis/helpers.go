@@ -4,127 +4,94 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strconv"
+	"strings"
 	"time"
 
 	redisclient "github.com/redis/go-redis/v9"
 	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/shipping"
 )
 
-func AddProductsToCache(ctx context.Context, products []*models.Product) error {
-	// Cache each product individually using the robust single product caching
-	for _, product := range products {
-		if err := CacheSingleProduct(ctx, product); err != nil {
-			return fmt.Errorf("failed to cache product %s: %w", product.SKU, err)
-		}
+// FetchProduct returns the product cached under sku, or loads it and caches it for the
+// next read, along with the X-Cache value to report
+func FetchProduct(ctx context.Context, sku string, load func() (*models.Product, error)) (*models.Product, string, error) {
+	var product *models.Product
+	status, err := Products.Fetch(ctx, sku, false, &product, func() (err error) {
+		product, err = load()
+		return err
+	})
+	if err != nil {
+		return nil, "", err
 	}
 
-	return nil
+	if status == CacheMiss {
+		if err := cacheProductID(ctx, product); err != nil {
+			slog.WarnContext(ctx, "Failed to cache product ID", "sku", sku, "error", err)
+		}
+	}
+	return product, status, nil
 }
 
-func GetProductFromCache(ctx context.Context, productSKU string) (*models.Product, error) {
-	client := RedisClient()
-	defer client.Close()
-
-	productKey := fmt.Sprintf("product:%s", productSKU)
-	productJSON, err := client.Get(ctx, productKey).Result()
-	if err != nil {
-		return nil, err
+// CacheSingleProduct stores a product under its SKU and indexes it for autocomplete.
+// Autocomplete is indexed even when products aren't cached.
+func CacheSingleProduct(ctx context.Context, product *models.Product) error {
+	if err := Products.Set(ctx, product.SKU, product); err != nil {
+		return fmt.Errorf("failed to cache product %s: %w", product.SKU, err)
 	}
-
-	var product models.Product
-	if err := json.Unmarshal([]byte(productJSON), &product); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal product: %w", err)
+	if err := cacheProductID(ctx, product); err != nil {
+		return fmt.Errorf("failed to cache product %s: %w", product.SKU, err)
 	}
 
-	return &product, nil
+	// Keep autocomplete in step with every product write, which all pass through here
+	// or RefreshCachedProduct
+	return IndexProductSuggestions(ctx, product)
 }
 
-// RemoveProductFromCache removes a product and its related cache entries by SKU
-func RemoveProductFromCache(ctx context.Context, product *models.Product) error {
-	client := RedisClient()
-	defer client.Close()
-
-	// Use pipeline for atomic operations
-	pipe := client.TxPipeline()
-
-	// Remove main product cache entry
-	productKey := fmt.Sprintf("product:%s", product.SKU)
-	pipe.Del(ctx, productKey)
-
-	// Remove SKU mapping
-	skuKey := fmt.Sprintf("sku:%s", product.SKU)
-	pipe.Del(ctx, skuKey)
-
-	// Remove from category list
-	categoryKey := fmt.Sprintf("category:%s", product.Category)
-	pipe.LRem(ctx, categoryKey, 0, product.SKU)
-
-	// Remove from recent products list
-	pipe.LRem(ctx, "products:recent", 0, product.SKU)
-
-	// Execute all operations
-	_, err := pipe.Exec(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to remove product from Redis cache: %w", err)
+// RefreshCachedProduct applies a product write to the cache: write-through stores the
+// new product, and otherwise the cached copy is dropped for the next read to reload.
+// Autocomplete is reindexed either way.
+func RefreshCachedProduct(ctx context.Context, product *models.Product) error {
+	if Products.WritesThrough() {
+		return CacheSingleProduct(ctx, product)
 	}
 
-	return nil
+	if err := uncacheProduct(ctx, product); err != nil {
+		return err
+	}
+	return IndexProductSuggestions(ctx, product)
 }
 
-// CacheSingleProduct stores a single product in Redis cache using SKU-based keys
-func CacheSingleProduct(ctx context.Context, product *models.Product) error {
-	client := RedisClient()
-	defer client.Close()
-
-	// Serialize product to JSON
-	productJSON, err := json.Marshal(product)
-	if err != nil {
-		return fmt.Errorf("failed to marshal product %s: %w", product.SKU, err)
+// RemoveProductFromCache removes a product, its document ID mapping and its
+// autocomplete entries
+func RemoveProductFromCache(ctx context.Context, product *models.Product) error {
+	if err := uncacheProduct(ctx, product); err != nil {
+		return err
 	}
+	return RemoveProductSuggestions(ctx, product.SKU)
+}
 
-	// Use pipeline for atomic operations
-	pipe := client.TxPipeline()
-
-	// Store individual product with key pattern: product:{sku}
-	productKey := fmt.Sprintf("product:%s", product.SKU)
-	pipe.Set(ctx, productKey, productJSON, 24*time.Hour)
-
-	// Store product SKU mapping for quick lookups: sku:{sku} -> {sku} (for consistency)
-	skuKey := fmt.Sprintf("sku:%s", product.SKU)
-	pipe.Set(ctx, skuKey, product.SKU, 24*time.Hour)
-
-	// Add to category-based lists for filtering
-	categoryKey := fmt.Sprintf("category:%s", product.Category)
-	pipe.LPush(ctx, categoryKey, product.SKU)
-	pipe.Expire(ctx, categoryKey, 24*time.Hour)
-
-	// Add to recent products list
-	pipe.LPush(ctx, "products:recent", product.SKU)
-	// Keep only the 100 most recent products
-	pipe.LTrim(ctx, "products:recent", 0, 99)
-	pipe.Expire(ctx, "products:recent", 24*time.Hour)
-
-	// Execute all operations atomically
-	_, err = pipe.Exec(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to execute Redis pipeline for product %s: %w", product.SKU, err)
+// cacheProductID maps the product's document ID to its SKU, so a change stream delete,
+// which only carries the ID, can find the entry to remove
+func cacheProductID(ctx context.Context, product *models.Product) error {
+	if !Products.Enabled() || product.ID.IsZero() {
+		return nil
 	}
-
-	return nil
+	return RedisClient().Set(ctx, productIDKey(product.ID.Hex()), product.SKU, Products.TTL()).Err()
 }
 
-func GetProductBySKUFromCache(ctx context.Context, sku string) (*models.Product, error) {
-	client := RedisClient()
-	defer client.Close()
-
-	skuKey := fmt.Sprintf("sku:%s", sku)
-	productID, err := client.Get(ctx, skuKey).Result()
-	if err != nil {
-		return nil, err
+// uncacheProduct drops a product's entry and document ID mapping
+func uncacheProduct(ctx context.Context, product *models.Product) error {
+	pipe := RedisClient().TxPipeline()
+	pipe.Del(ctx, Products.Key(product.SKU))
+	if !product.ID.IsZero() {
+		pipe.Del(ctx, productIDKey(product.ID.Hex()))
 	}
-
-	return GetProductFromCache(ctx, productID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to remove product %s from Redis cache: %w", product.SKU, err)
+	}
+	return nil
 }
 
 // Cart operations using Redis Hashes
@@ -132,7 +99,6 @@ func GetProductBySKUFromCache(ctx context.Context, sku string) (*models.Product,
 // GetCart retrieves a cart by session ID
 func GetCart(ctx context.Context, sessionID string) (*models.Cart, error) {
 	client := RedisClient()
-	defer client.Close()
 
 	cartKey := fmt.Sprintf("cart:%s", sessionID)
 
@@ -194,6 +160,11 @@ func GetCart(ctx context.Context, sessionID string) (*models.Cart, error) {
 		if addedAt, ok := itemData["added_at"]; ok {
 			item.AddedAt = addedAt
 		}
+		if weightStr, ok := itemData["weight_kg"]; ok {
+			if weight, err := strconv.ParseFloat(weightStr, 64); err == nil {
+				item.WeightKg = weight
+			}
+		}
 
 		items[item.SKU] = item // Key by SKU instead of ProductID
 	}
@@ -235,14 +206,63 @@ func GetCart(ctx context.Context, sessionID string) (*models.Cart, error) {
 	if expiresAt, ok := cartData["expires_at"]; ok {
 		cart.ExpiresAt = expiresAt
 	}
+	if customerEmail, ok := cartData["customer_email"]; ok {
+		cart.CustomerEmail = customerEmail
+	}
+	if loyaltyTier, ok := cartData["loyalty_tier"]; ok {
+		cart.LoyaltyTier = loyaltyTier
+	}
+	if province, ok := cartData["shipping_province"]; ok {
+		cart.Province = province
+	}
+	if postalCode, ok := cartData["shipping_postal_code"]; ok {
+		cart.PostalCode = postalCode
+	}
+	if method, ok := cartData["shipping_method"]; ok {
+		cart.ShippingMethod = method
+	}
+	if ratesJSON, ok := cartData["shipping_rates"]; ok {
+		if err := json.Unmarshal([]byte(ratesJSON), &cart.ShippingRates); err != nil {
+			slog.WarnContext(ctx, "Failed to parse cart shipping rates", "session_id", sessionID, "error", err)
+		}
+	}
+	if taxRateStr, ok := cartData["tax_rate"]; ok {
+		if taxRate, err := strconv.ParseFloat(taxRateStr, 64); err == nil {
+			cart.TaxRate = taxRate
+		}
+	}
+	if discountStr, ok := cartData["discount"]; ok {
+		if discount, err := strconv.ParseFloat(discountStr, 64); err == nil {
+			cart.Discount = discount
+		}
+	}
+	if tierDiscountStr, ok := cartData["tier_discount"]; ok {
+		if tierDiscount, err := strconv.ParseFloat(tierDiscountStr, 64); err == nil {
+			cart.TierDiscount = tierDiscount
+		}
+	}
+	if couponCode, ok := cartData["coupon_code"]; ok && couponCode != "" {
+		coupon := &models.CartCoupon{
+			Code: couponCode,
+			Type: cartData["coupon_type"],
+		}
+		if value, err := strconv.ParseFloat(cartData["coupon_value"], 64); err == nil {
+			coupon.Value = value
+		}
+		if minSubtotal, err := strconv.ParseFloat(cartData["coupon_min_subtotal"], 64); err == nil {
+			coupon.MinSubtotal = minSubtotal
+		}
+		cart.Coupon = coupon
+	}
 
 	return cart, nil
 }
 
-// AddToCart adds an item to the cart
-func AddToCart(ctx context.Context, sessionID, sku string, quantity int, product *models.Product) (*models.Cart, error) {
+// AddToCart adds an item to the cart. customerEmail is optional and is kept
+// on the cart so abandoned carts can be followed up, along with that customer's
+// loyaltyTier so its benefits apply to the cart totals.
+func AddToCart(ctx context.Context, sessionID, sku string, quantity int, product *models.Product, customerEmail, loyaltyTier string) (*models.Cart, error) {
 	client := RedisClient()
-	defer client.Close()
 
 	// Get existing cart
 	cart, err := GetCart(ctx, sessionID)
@@ -250,6 +270,18 @@ func AddToCart(ctx context.Context, sessionID, sku string, quantity int, product
 		return nil, err
 	}
 
+	if customerEmail != "" {
+		cart.CustomerEmail = customerEmail
+		cart.LoyaltyTier = loyaltyTier
+	}
+
+	// The first item added starts a new cart for the sales funnel
+	if len(cart.Items) == 0 {
+		if err := TrackFunnelEvent(ctx, "cart_created", sessionID); err != nil {
+			slog.WarnContext(ctx, "Failed to track cart_created", "session_id", sessionID, "error", err)
+		}
+	}
+
 	// Create or update cart item
 	now := time.Now().UTC().Format(time.RFC3339)
 	subtotal := float64(quantity) * product.Price
@@ -267,12 +299,13 @@ func AddToCart(ctx context.Context, sessionID, sku string, quantity int, product
 			Price:       product.Price,
 			Quantity:    quantity,
 			Subtotal:    subtotal,
+			WeightKg:    product.WeightKg,
 			AddedAt:     now,
 		}
 	}
 
 	// Recalculate cart totals
-	calculateCartTotals(cart)
+	calculateCartTotals(ctx, cart)
 	cart.LastUpdated = now
 	cart.ExpiresAt = time.Now().Add(1 * time.Hour).UTC().Format(time.RFC3339)
 
@@ -288,7 +321,6 @@ func AddToCart(ctx context.Context, sessionID, sku string, quantity int, product
 // UpdateCartItem updates the quantity of an item in the cart
 func UpdateCartItem(ctx context.Context, sessionID, sku string, quantity int) (*models.Cart, error) {
 	client := RedisClient()
-	defer client.Close()
 
 	// Get existing cart
 	cart, err := GetCart(ctx, sessionID)
@@ -315,7 +347,7 @@ func UpdateCartItem(ctx context.Context, sessionID, sku string, quantity int) (*
 	}
 
 	// Recalculate cart totals
-	calculateCartTotals(cart)
+	calculateCartTotals(ctx, cart)
 	cart.LastUpdated = time.Now().UTC().Format(time.RFC3339)
 	cart.ExpiresAt = time.Now().Add(1 * time.Hour).UTC().Format(time.RFC3339)
 
@@ -333,10 +365,157 @@ func RemoveFromCart(ctx context.Context, sessionID, sku string) (*models.Cart, e
 	return UpdateCartItem(ctx, sessionID, sku, 0)
 }
 
+// RevalidateCart compares cart items against the current catalog, updating stale
+// prices and flagging unavailable items. Products are keyed by SKU; a missing entry
+// means the product no longer exists. The cart is only re-saved when a price changed.
+func RevalidateCart(ctx context.Context, cart *models.Cart, products map[string]*models.Product) (*models.Cart, error) {
+	revalidation := &models.CartRevalidation{
+		CheckedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	weightChanged := false
+
+	for sku, item := range cart.Items {
+		product, exists := products[sku]
+		if !exists || product.Status != "active" {
+			available := 0
+			item.OutOfStock = true
+			item.AvailableStock = &available
+			revalidation.OutOfStock++
+			continue
+		}
+
+		if product.WeightKg != item.WeightKg {
+			item.WeightKg = product.WeightKg
+			weightChanged = true
+		}
+
+		if product.Price != item.Price {
+			item.PriceChanged = true
+			item.PreviousPrice = item.Price
+			item.Price = product.Price
+			item.Subtotal = float64(item.Quantity) * item.Price
+			revalidation.PriceChanges++
+		}
+
+		if product.Stock.Total < item.Quantity {
+			available := product.Stock.Total
+			item.OutOfStock = true
+			item.AvailableStock = &available
+			revalidation.OutOfStock++
+		}
+	}
+
+	cart.Revalidation = revalidation
+
+	if revalidation.PriceChanges == 0 && revalidation.OutOfStock == 0 && !weightChanged {
+		return cart, nil
+	}
+
+	// Unavailable quantities are left out of the totals so checkout can't charge for them
+	calculateCartTotals(ctx, cart)
+	if revalidation.PriceChanges == 0 && !weightChanged {
+		return cart, nil
+	}
+	cart.LastUpdated = time.Now().UTC().Format(time.RFC3339)
+
+	client := RedisClient()
+
+	if err := saveCartToRedis(ctx, client, cart); err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+// ApplyCouponToCart attaches a validated coupon to the cart and recalculates totals
+func ApplyCouponToCart(ctx context.Context, sessionID string, coupon *models.Coupon) (*models.Cart, error) {
+	client := RedisClient()
+
+	cart, err := GetCart(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cart.Items) == 0 {
+		return nil, fmt.Errorf("cart is empty")
+	}
+
+	cart.Coupon = &models.CartCoupon{
+		Code:        coupon.Code,
+		Type:        coupon.Type,
+		Value:       coupon.Value,
+		MinSubtotal: coupon.MinSubtotal,
+	}
+
+	calculateCartTotals(ctx, cart)
+	cart.LastUpdated = time.Now().UTC().Format(time.RFC3339)
+	cart.ExpiresAt = time.Now().Add(1 * time.Hour).UTC().Format(time.RFC3339)
+
+	if err := saveCartToRedis(ctx, client, cart); err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+// SetCartShipping sets where and how the cart ships and recalculates its tax and shipping.
+// Empty arguments keep the cart's current values.
+func SetCartShipping(ctx context.Context, sessionID, province, postalCode, method string) (*models.Cart, error) {
+	client := RedisClient()
+
+	cart, err := GetCart(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if province != "" {
+		cart.Province = strings.ToUpper(province)
+	}
+	if postalCode != "" {
+		cart.PostalCode = strings.ToUpper(strings.TrimSpace(postalCode))
+	}
+	if method != "" {
+		cart.ShippingMethod = method
+	}
+	calculateCartTotals(ctx, cart)
+	cart.LastUpdated = time.Now().UTC().Format(time.RFC3339)
+	cart.ExpiresAt = time.Now().Add(1 * time.Hour).UTC().Format(time.RFC3339)
+
+	if err := saveCartToRedis(ctx, client, cart); err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+// RemoveCouponFromCart detaches any coupon from the cart and recalculates totals
+func RemoveCouponFromCart(ctx context.Context, sessionID string) (*models.Cart, error) {
+	client := RedisClient()
+
+	cart, err := GetCart(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cart.Coupon == nil {
+		return nil, fmt.Errorf("no coupon applied to cart")
+	}
+
+	cart.Coupon = nil
+	calculateCartTotals(ctx, cart)
+	cart.LastUpdated = time.Now().UTC().Format(time.RFC3339)
+	cart.ExpiresAt = time.Now().Add(1 * time.Hour).UTC().Format(time.RFC3339)
+
+	if err := saveCartToRedis(ctx, client, cart); err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
 // ClearCart removes all items from the cart
 func ClearCart(ctx context.Context, sessionID string) error {
 	client := RedisClient()
-	defer client.Close()
 
 	// Remove all cart-related keys
 	cartPattern := fmt.Sprintf("cart:%s*", sessionID)
@@ -345,6 +524,9 @@ func ClearCart(ctx context.Context, sessionID string) error {
 		return err
 	}
 
+	// Stop tracking activity so the cart is not reported as abandoned
+	client.ZRem(ctx, cartActivityKey, sessionID)
+
 	if len(keys) > 0 {
 		return client.Del(ctx, keys...).Err()
 	}
@@ -352,43 +534,145 @@ func ClearCart(ctx context.Context, sessionID string) error {
 	return nil
 }
 
+// GetIdleCarts returns session IDs whose last cart activity is older than idleSince,
+// mapped to the time of that last activity
+func GetIdleCarts(ctx context.Context, idleSince time.Time) (map[string]time.Time, error) {
+	client := RedisClient()
+
+	entries, err := client.ZRangeByScoreWithScores(ctx, cartActivityKey, &redisclient.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(idleSince.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	idle := make(map[string]time.Time, len(entries))
+	for _, entry := range entries {
+		if sessionID, ok := entry.Member.(string); ok {
+			idle[sessionID] = time.Unix(int64(entry.Score), 0)
+		}
+	}
+
+	return idle, nil
+}
+
+// GetCartsActiveSince returns session IDs with cart activity in the (since, until] window
+func GetCartsActiveSince(ctx context.Context, since, until time.Time) ([]string, error) {
+	client := RedisClient()
+
+	return client.ZRangeByScore(ctx, cartActivityKey, &redisclient.ZRangeBy{
+		Min: "(" + strconv.FormatInt(since.Unix(), 10),
+		Max: strconv.FormatInt(until.Unix(), 10),
+	}).Result()
+}
+
+// CartExists reports whether a cart is currently stored in Redis for the session
+func CartExists(ctx context.Context, sessionID string) (bool, error) {
+	client := RedisClient()
+
+	exists, err := client.Exists(ctx, fmt.Sprintf("cart:%s", sessionID)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return exists > 0, nil
+}
+
+// RestoreCart rebuilds a Redis cart from a persisted snapshot
+func RestoreCart(ctx context.Context, snapshot *models.PersistedCart) (*models.Cart, error) {
+	client := RedisClient()
+
+	cart := createEmptyCart(snapshot.SessionID)
+	cart.CustomerEmail = snapshot.CustomerEmail
+	cart.LoyaltyTier = snapshot.LoyaltyTier
+	cart.Province = snapshot.Province
+	cart.PostalCode = snapshot.PostalCode
+	cart.ShippingMethod = snapshot.ShippingMethod
+	cart.Coupon = snapshot.Coupon
+	for sku, item := range snapshot.Items {
+		cart.Items[sku] = item
+	}
+
+	calculateCartTotals(ctx, cart)
+
+	if err := saveCartToRedis(ctx, client, cart); err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+// UntrackCartActivity removes a session from the activity index
+func UntrackCartActivity(ctx context.Context, sessionID string) error {
+	client := RedisClient()
+
+	return client.ZRem(ctx, cartActivityKey, sessionID).Err()
+}
+
 // Helper functions
 
+// cartActivityKey is a sorted set of session IDs scored by last cart activity (unix seconds)
+const cartActivityKey = "carts:activity"
+
 func createEmptyCart(sessionID string) *models.Cart {
 	now := time.Now().UTC().Format(time.RFC3339)
 	return &models.Cart{
-		SessionID:   sessionID,
-		Items:       make(map[string]*models.CartItem),
-		Subtotal:    0,
-		Tax:         0,
-		Shipping:    0,
-		Total:       0,
-		ItemCount:   0,
-		LastUpdated: now,
-		ExpiresAt:   time.Now().Add(1 * time.Hour).UTC().Format(time.RFC3339),
+		SessionID:      sessionID,
+		Items:          make(map[string]*models.CartItem),
+		Subtotal:       0,
+		Tax:            0,
+		ShippingMethod: models.ShippingStandard,
+		Shipping:       0,
+		Total:          0,
+		ItemCount:      0,
+		LastUpdated:    now,
+		ExpiresAt:      time.Now().Add(1 * time.Hour).UTC().Format(time.RFC3339),
 	}
 }
 
-func calculateCartTotals(cart *models.Cart) {
+// calculateCartTotals recalculates the cart's discounts, tax and total, quoting shipping
+// for the payable items' weight to the cart's destination
+func calculateCartTotals(ctx context.Context, cart *models.Cart) {
 	cart.Subtotal = 0
 	cart.ItemCount = 0
 
+	var weight float64
 	for _, item := range cart.Items {
-		cart.Subtotal += item.Subtotal
+		cart.Subtotal += float64(item.PayableQuantity()) * item.Price
 		cart.ItemCount += item.Quantity
+		weight += float64(item.PayableQuantity()) * shipping.ItemWeight(item.WeightKg)
 	}
 
-	// Calculate tax (assuming 10% tax rate)
-	cart.Tax = cart.Subtotal * 0.10
+	// Recalculate coupon discount against the current subtotal
+	cart.Discount = 0
+	if cart.Coupon != nil {
+		cart.Discount = cart.Coupon.ToCoupon().CalculateDiscount(cart.Subtotal)
+	}
 
-	// Calculate shipping (free shipping over $50, otherwise $5.99)
+	// Apply the customer's loyalty tier discount after any coupon
+	benefits := models.LoyaltyTierBenefitsFor(cart.LoyaltyTier)
+	cart.TierDiscount = benefits.Discount(cart.Subtotal - cart.Discount)
+
+	// Calculate tax on the discounted subtotal at the shipping province's rate
+	cart.TaxRate = models.TaxRuleFor(cart.Province).Rate()
+	cart.Tax = (cart.Subtotal - cart.Discount - cart.TierDiscount) * cart.TaxRate
+
+	// Quote every method, then charge for the chosen one
+	if cart.ShippingMethod == "" {
+		cart.ShippingMethod = models.ShippingStandard
+	}
+	cart.ShippingRates = nil
 	cart.Shipping = 0
-	if cart.Subtotal > 0 && cart.Subtotal < 50 {
-		cart.Shipping = 5.99
+	if cart.Subtotal > 0 {
+		cart.ShippingRates = shipping.Quote(ctx, shipping.Shipment{Province: cart.Province, PostalCode: cart.PostalCode, WeightKg: weight})
+		if rate, ok := models.FindShippingRate(cart.ShippingRates, cart.ShippingMethod); ok {
+			cart.Shipping = models.ShippingCost(rate, cart.Subtotal, benefits)
+		}
 	}
 
 	// Calculate total
-	cart.Total = cart.Subtotal + cart.Tax + cart.Shipping
+	cart.Total = cart.Subtotal - cart.Discount - cart.TierDiscount + cart.Tax + cart.Shipping
 }
 
 func saveCartToRedis(ctx context.Context, client *redisclient.Client, cart *models.Cart) error {
@@ -396,13 +680,54 @@ func saveCartToRedis(ctx context.Context, client *redisclient.Client, cart *mode
 
 	// Save cart metadata
 	cartData := map[string]interface{}{
-		"subtotal":     fmt.Sprintf("%.2f", cart.Subtotal),
-		"tax":          fmt.Sprintf("%.2f", cart.Tax),
-		"shipping":     fmt.Sprintf("%.2f", cart.Shipping),
-		"total":        fmt.Sprintf("%.2f", cart.Total),
-		"item_count":   fmt.Sprintf("%d", cart.ItemCount),
-		"last_updated": cart.LastUpdated,
-		"expires_at":   cart.ExpiresAt,
+		"subtotal":        fmt.Sprintf("%.2f", cart.Subtotal),
+		"discount":        fmt.Sprintf("%.2f", cart.Discount),
+		"tier_discount":   fmt.Sprintf("%.2f", cart.TierDiscount),
+		"tax":             fmt.Sprintf("%.2f", cart.Tax),
+		"tax_rate":        strconv.FormatFloat(cart.TaxRate, 'f', -1, 64),
+		"shipping":        fmt.Sprintf("%.2f", cart.Shipping),
+		"shipping_method": cart.ShippingMethod,
+		"total":           fmt.Sprintf("%.2f", cart.Total),
+		"item_count":      fmt.Sprintf("%d", cart.ItemCount),
+		"last_updated":    cart.LastUpdated,
+		"expires_at":      cart.ExpiresAt,
+	}
+
+	if cart.CustomerEmail != "" {
+		cartData["customer_email"] = cart.CustomerEmail
+	}
+
+	if cart.LoyaltyTier != "" {
+		cartData["loyalty_tier"] = cart.LoyaltyTier
+	} else {
+		client.HDel(ctx, cartKey, "loyalty_tier")
+	}
+
+	if cart.Province != "" {
+		cartData["shipping_province"] = cart.Province
+	}
+
+	if cart.PostalCode != "" {
+		cartData["shipping_postal_code"] = cart.PostalCode
+	}
+
+	if len(cart.ShippingRates) > 0 {
+		ratesJSON, err := json.Marshal(cart.ShippingRates)
+		if err != nil {
+			return err
+		}
+		cartData["shipping_rates"] = string(ratesJSON)
+	} else {
+		client.HDel(ctx, cartKey, "shipping_rates")
+	}
+
+	if cart.Coupon != nil {
+		cartData["coupon_code"] = cart.Coupon.Code
+		cartData["coupon_type"] = cart.Coupon.Type
+		cartData["coupon_value"] = fmt.Sprintf("%.2f", cart.Coupon.Value)
+		cartData["coupon_min_subtotal"] = fmt.Sprintf("%.2f", cart.Coupon.MinSubtotal)
+	} else {
+		client.HDel(ctx, cartKey, "coupon_code", "coupon_type", "coupon_value", "coupon_min_subtotal")
 	}
 
 	err := client.HSet(ctx, cartKey, cartData).Err()
@@ -413,6 +738,9 @@ func saveCartToRedis(ctx context.Context, client *redisclient.Client, cart *mode
 	// Set TTL for cart (1 hour)
 	client.Expire(ctx, cartKey, 1*time.Hour)
 
+	// Record last activity for abandonment detection
+	client.ZAdd(ctx, cartActivityKey, redisclient.Z{Score: float64(time.Now().Unix()), Member: cart.SessionID})
+
 	// Save individual items
 	for sku, item := range cart.Items {
 		itemKey := fmt.Sprintf("cart:%s:item:%s", cart.SessionID, sku)
@@ -423,6 +751,7 @@ func saveCartToRedis(ctx context.Context, client *redisclient.Client, cart *mode
 			"price":        fmt.Sprintf("%.2f", item.Price),
 			"quantity":     fmt.Sprintf("%d", item.Quantity),
 			"subtotal":     fmt.Sprintf("%.2f", item.Subtotal),
+			"weight_kg":    strconv.FormatFloat(item.WeightKg, 'f', -1, 64),
 			"added_at":     item.AddedAt,
 		}
 
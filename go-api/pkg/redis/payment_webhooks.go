@@ -0,0 +1,71 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redisclient "github.com/redis/go-redis/v9"
+)
+
+const (
+	// webhookClaimTTL is how long an event stays claimed while it is processed, so a
+	// crashed instance's claim expires before the provider's next retry
+	webhookClaimTTL = 2 * time.Minute
+	// webhookProcessedTTL outlasts the providers' retry windows (3 days for both Stripe
+	// and PayPal)
+	webhookProcessedTTL = 7 * 24 * time.Hour
+)
+
+// Webhook event states returned by ClaimWebhookEvent
+const (
+	WebhookEventClaimed    = "claimed"    // the caller should process the event
+	WebhookEventProcessing = "processing" // another delivery is processing it right now
+	WebhookEventProcessed  = "processed"  // it was already applied
+)
+
+// webhookEventKey returns the Redis key recording a provider's webhook event
+func webhookEventKey(provider, eventID string) string {
+	return fmt.Sprintf("webhook:payments:%s:%s", provider, eventID)
+}
+
+// ClaimWebhookEvent marks a webhook event as being processed unless it already is or
+// has been, returning which of the three it found
+func ClaimWebhookEvent(ctx context.Context, provider, eventID string) (string, error) {
+	client := RedisClient()
+	key := webhookEventKey(provider, eventID)
+
+	claimed, err := client.SetNX(ctx, key, WebhookEventProcessing, webhookClaimTTL).Result()
+	if err != nil {
+		return "", err
+	}
+	if claimed {
+		return WebhookEventClaimed, nil
+	}
+
+	state, err := client.Get(ctx, key).Result()
+	if err == redisclient.Nil {
+		// The claim expired between the two calls, so try again
+		return ClaimWebhookEvent(ctx, provider, eventID)
+	}
+	if err != nil {
+		return "", err
+	}
+	return state, nil
+}
+
+// CompleteWebhookEvent records that a claimed event was applied, so later deliveries of
+// it are acknowledged without being processed
+func CompleteWebhookEvent(ctx context.Context, provider, eventID string) error {
+	client := RedisClient()
+
+	return client.Set(ctx, webhookEventKey(provider, eventID), WebhookEventProcessed, webhookProcessedTTL).Err()
+}
+
+// ReleaseWebhookEvent drops the claim on an event that failed, so the provider's retry
+// processes it again
+func ReleaseWebhookEvent(ctx context.Context, provider, eventID string) error {
+	client := RedisClient()
+
+	return client.Del(ctx, webhookEventKey(provider, eventID)).Err()
+}
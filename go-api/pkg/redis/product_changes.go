@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	redisclient "github.com/redis/go-redis/v9"
+)
+
+// productChangesTokenKey stores where the products change stream left off
+const productChangesTokenKey = "changestream:products:token"
+
+// productIDKey maps a product's document ID to its SKU
+func productIDKey(id string) string {
+	return fmt.Sprintf("product-id:%s", id)
+}
+
+// GetCachedProductSKU returns the SKU cached for a product document ID, or "" when the
+// product isn't cached
+func GetCachedProductSKU(ctx context.Context, id string) (string, error) {
+	client := RedisClient()
+
+	sku, err := client.Get(ctx, productIDKey(id)).Result()
+	if err == redisclient.Nil {
+		return "", nil
+	}
+	return sku, err
+}
+
+// GetProductChangesToken returns the saved products change stream resume token, or nil
+// when the stream should start from now
+func GetProductChangesToken(ctx context.Context) ([]byte, error) {
+	client := RedisClient()
+
+	token, err := client.Get(ctx, productChangesTokenKey).Bytes()
+	if err == redisclient.Nil {
+		return nil, nil
+	}
+	return token, err
+}
+
+// SaveProductChangesToken stores the resume token of the last handled product change
+func SaveProductChangesToken(ctx context.Context, token []byte) error {
+	client := RedisClient()
+
+	return client.Set(ctx, productChangesTokenKey, token, 0).Err()
+}
+
+// ClearProductChangesToken forgets the resume token, restarting the stream from now
+func ClearProductChangesToken(ctx context.Context) error {
+	client := RedisClient()
+
+	return client.Del(ctx, productChangesTokenKey).Err()
+}
@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	redisclient "github.com/redis/go-redis/v9"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// productDraftTTL is how long an AI description draft waits for approval
+const productDraftTTL = 24 * time.Hour
+
+// productDraftKey returns the Redis key holding a product's pending description draft
+func productDraftKey(sku string) string {
+	return fmt.Sprintf("product:draft:%s", sku)
+}
+
+// SaveProductDescriptionDraft stores a draft, replacing any earlier one for the product
+func SaveProductDescriptionDraft(ctx context.Context, draft *models.ProductDescriptionDraft) error {
+	client := RedisClient()
+
+	draftJSON, err := json.Marshal(draft)
+	if err != nil {
+		return fmt.Errorf("failed to marshal description draft: %w", err)
+	}
+
+	return client.Set(ctx, productDraftKey(draft.SKU), draftJSON, productDraftTTL).Err()
+}
+
+// GetProductDescriptionDraft returns the pending draft for a product
+func GetProductDescriptionDraft(ctx context.Context, sku string) (*models.ProductDescriptionDraft, error) {
+	client := RedisClient()
+
+	draftJSON, err := client.Get(ctx, productDraftKey(sku)).Result()
+	if err == redisclient.Nil {
+		return nil, errors.New("draft not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var draft models.ProductDescriptionDraft
+	if err := json.Unmarshal([]byte(draftJSON), &draft); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal description draft: %w", err)
+	}
+
+	return &draft, nil
+}
+
+// DeleteProductDescriptionDraft discards a product's pending draft
+func DeleteProductDescriptionDraft(ctx context.Context, sku string) error {
+	client := RedisClient()
+
+	return client.Del(ctx, productDraftKey(sku)).Err()
+}
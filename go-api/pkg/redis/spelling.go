@@ -0,0 +1,184 @@
+package redis
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	redisclient "github.com/redis/go-redis/v9"
+)
+
+// maxSpellingCandidates caps how many index entries a misspelled term is compared with
+const maxSpellingCandidates = 2000
+
+// maxCorrectionsPerTerm caps how many spellings are suggested for one term
+const maxCorrectionsPerTerm = 3
+
+// SpellingCorrections finds the words a search probably meant for each term of query
+// that no product name, brand or category contains, allowing up to maxEdits typos
+// (insertions, deletions, substitutions or swapped letters) per term. Candidates come
+// from the autocomplete index and must share the term's first letter. The closest are
+// returned first, with ties going to the word sharing the most trigrams. Quoted phrases
+// and -excluded words are left alone.
+func SpellingCorrections(ctx context.Context, query string, maxEdits int) (map[string][]string, error) {
+	corrections := map[string][]string{}
+	if maxEdits < 1 {
+		return corrections, nil
+	}
+
+	vocabularies := map[string]map[string]bool{} // by first letter
+	for _, term := range correctableTerms(query) {
+		edits := min(maxEdits, allowedEdits(term))
+		if edits == 0 {
+			continue
+		}
+
+		first := string([]rune(term)[:1])
+		words, ok := vocabularies[first]
+		if !ok {
+			var err error
+			if words, err = vocabularyFrom(ctx, first); err != nil {
+				return nil, err
+			}
+			vocabularies[first] = words
+		}
+		if words[term] {
+			continue
+		}
+
+		type candidate struct {
+			word     string
+			distance int
+			overlap  float64
+		}
+		var candidates []candidate
+		for word := range words {
+			if distance := editDistance(term, word); distance <= edits {
+				candidates = append(candidates, candidate{word, distance, trigramSimilarity(term, word)})
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].distance != candidates[j].distance {
+				return candidates[i].distance < candidates[j].distance
+			}
+			if candidates[i].overlap != candidates[j].overlap {
+				return candidates[i].overlap > candidates[j].overlap
+			}
+			return candidates[i].word < candidates[j].word
+		})
+
+		for i := 0; i < len(candidates) && i < maxCorrectionsPerTerm; i++ {
+			corrections[term] = append(corrections[term], candidates[i].word)
+		}
+	}
+	return corrections, nil
+}
+
+// correctableTerms returns query's lowercase words outside quoted phrases, skipping
+// -excluded words and duplicates
+func correctableTerms(query string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for i, part := range strings.Split(strings.ToLower(query), `"`) {
+		if i%2 == 1 {
+			continue // inside a phrase
+		}
+		for _, word := range strings.Fields(part) {
+			if strings.HasPrefix(word, "-") || seen[word] {
+				continue
+			}
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// allowedEdits scales the typos a term may have with its length, since one typo in a
+// short word usually makes a different word
+func allowedEdits(term string) int {
+	switch length := len([]rune(term)); {
+	case length < 3:
+		return 0
+	case length < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// vocabularyFrom reads the words of the indexed terms starting with prefix
+func vocabularyFrom(ctx context.Context, prefix string) (map[string]bool, error) {
+	entries, err := RedisClient().ZRangeByLex(ctx, suggestIndexKey, &redisclient.ZRangeBy{
+		Min:   "[" + prefix,
+		Max:   "[" + prefix + "\xff",
+		Count: maxSpellingCandidates,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	words := map[string]bool{}
+	for _, entry := range entries {
+		term, _, _ := strings.Cut(entry, "\x00")
+		for _, word := range strings.Fields(term) {
+			words[word] = true
+		}
+	}
+	return words, nil
+}
+
+// editDistance counts the insertions, deletions, substitutions and adjacent swaps
+// that turn a into b (optimal string alignment distance)
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	previous2 := make([]int, len(t)+1)
+	previous := make([]int, len(t)+1)
+	current := make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				current[j] = min(current[j], previous2[j-2]+1)
+			}
+		}
+		previous2, previous, current = previous, current, previous2
+	}
+	return previous[len(t)]
+}
+
+// trigramSimilarity is the share of the two words' three-letter sequences they have
+// in common, from 0 to 1
+func trigramSimilarity(a, b string) float64 {
+	aTrigrams, bTrigrams := trigrams(a), trigrams(b)
+	shared := 0
+	for trigram := range aTrigrams {
+		if bTrigrams[trigram] {
+			shared++
+		}
+	}
+	total := len(aTrigrams) + len(bTrigrams) - shared
+	if total == 0 {
+		return 0
+	}
+	return float64(shared) / float64(total)
+}
+
+// trigrams returns the three-letter sequences of word, padded so its start and end count
+func trigrams(word string) map[string]bool {
+	runes := []rune("  " + word + " ")
+	set := map[string]bool{}
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = true
+	}
+	return set
+}
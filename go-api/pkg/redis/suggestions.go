@@ -0,0 +1,148 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	redisclient "github.com/redis/go-redis/v9"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// Suggestion index keys. Every entry in the sorted set has score 0 so ZRANGEBYLEX can
+// read completions for a prefix. Entries are "<lowercase term>\x00<type>\x00<text>".
+// Several products can share an entry (a brand or category), so the hash counts how
+// many products hold each one, and each product's set records the entries it added.
+const (
+	suggestIndexKey  = "suggest:index"
+	suggestCountsKey = "suggest:counts"
+)
+
+func suggestProductKey(sku string) string {
+	return "suggest:product:" + sku
+}
+
+// Suggestion is one autocomplete completion
+type Suggestion struct {
+	Text string `json:"text"`
+	Type string `json:"type"` // product, brand or category
+}
+
+// replaceSuggestionsScript swaps a product's entries for ARGV, adding entries no other
+// product holds yet and removing entries no product holds any more
+var replaceSuggestionsScript = redisclient.NewScript(`
+local old = redis.call('SMEMBERS', KEYS[1])
+local had, want = {}, {}
+for _, entry in ipairs(old) do had[entry] = true end
+for _, entry in ipairs(ARGV) do want[entry] = true end
+
+for entry in pairs(had) do
+	if not want[entry] and redis.call('HINCRBY', KEYS[3], entry, -1) <= 0 then
+		redis.call('HDEL', KEYS[3], entry)
+		redis.call('ZREM', KEYS[2], entry)
+	end
+end
+for entry in pairs(want) do
+	if not had[entry] and redis.call('HINCRBY', KEYS[3], entry, 1) == 1 then
+		redis.call('ZADD', KEYS[2], 0, entry)
+	end
+end
+
+redis.call('DEL', KEYS[1])
+if #ARGV > 0 then
+	redis.call('SADD', KEYS[1], unpack(ARGV))
+end
+return #ARGV
+`)
+
+// IndexProductSuggestions makes an active product's name, brand and category available
+// as completions and drops the ones it no longer has. Names also complete from the
+// start of each later word, so "pho" suggests "Smart Phone X".
+func IndexProductSuggestions(ctx context.Context, product *models.Product) error {
+	var entries []string
+	if product.Status == "active" {
+		entries = append(entries, suggestionEntries(product.Name, "product", true)...)
+		entries = append(entries, suggestionEntries(product.Brand, "brand", false)...)
+		entries = append(entries, suggestionEntries(product.Category, "category", false)...)
+	}
+	return replaceProductSuggestions(ctx, product.SKU, entries)
+}
+
+// RemoveProductSuggestions drops the completions a deleted product added
+func RemoveProductSuggestions(ctx context.Context, sku string) error {
+	return replaceProductSuggestions(ctx, sku, nil)
+}
+
+func replaceProductSuggestions(ctx context.Context, sku string, entries []string) error {
+	args := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		args[i] = entry
+	}
+
+	keys := []string{suggestProductKey(sku), suggestIndexKey, suggestCountsKey}
+	if err := replaceSuggestionsScript.Run(ctx, RedisClient(), keys, args...).Err(); err != nil {
+		return fmt.Errorf("failed to index suggestions for product %s: %w", sku, err)
+	}
+	return nil
+}
+
+// suggestionEntries builds the index entries for text, one per word start when
+// everyWord is set
+func suggestionEntries(text, suggestionType string, everyWord bool) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+
+	terms := []string{strings.ToLower(text)}
+	if everyWord {
+		words := strings.Fields(terms[0])
+		for i := 1; i < len(words); i++ {
+			terms = append(terms, strings.Join(words[i:], " "))
+		}
+	}
+
+	entries := make([]string, len(terms))
+	for i, term := range terms {
+		entries[i] = term + "\x00" + suggestionType + "\x00" + text
+	}
+	return entries
+}
+
+// SuggestProducts returns up to limit completions that start with prefix,
+// case-insensitively, in alphabetical order
+func SuggestProducts(ctx context.Context, prefix string, limit int) ([]Suggestion, error) {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	suggestions := []Suggestion{}
+	if prefix == "" {
+		return suggestions, nil
+	}
+
+	// A name matched on several of its words appears once per word, so read extra entries
+	entries, err := RedisClient().ZRangeByLex(ctx, suggestIndexKey, &redisclient.ZRangeBy{
+		Min:   "[" + prefix,
+		Max:   "[" + prefix + "\xff",
+		Count: int64(limit * 3),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[Suggestion]bool{}
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "\x00", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		suggestion := Suggestion{Text: parts[2], Type: parts[1]}
+		if seen[suggestion] {
+			continue
+		}
+		seen[suggestion] = true
+		suggestions = append(suggestions, suggestion)
+		if len(suggestions) == limit {
+			break
+		}
+	}
+	return suggestions, nil
+}
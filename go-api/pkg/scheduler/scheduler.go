@@ -0,0 +1,208 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Task is recurring work run on a schedule
+type Task struct {
+	Name string
+	// Spec is a cron expression or descriptor accepted by Parse
+	Spec    string
+	Enabled bool
+	// Timeout bounds one run; 0 means no limit beyond the scheduler's context
+	Timeout time.Duration
+	// RunAtStart also runs the task as soon as the scheduler starts
+	RunAtStart bool
+	Run        func(ctx context.Context) error
+}
+
+// TaskStatus reports a task's configuration and its most recent run
+type TaskStatus struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	Enabled      bool       `json:"enabled"`
+	Running      bool       `json:"running"`
+	NextRun      *time.Time `json:"next_run,omitempty"`
+	LastStarted  *time.Time `json:"last_started,omitempty"`
+	LastFinished *time.Time `json:"last_finished,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastStatus   string     `json:"last_status,omitempty"` // succeeded, failed or skipped
+	LastError    string     `json:"last_error,omitempty"`
+	Runs         int        `json:"runs"`
+	Failures     int        `json:"failures"`
+}
+
+type scheduledTask struct {
+	Task
+	schedule Schedule
+	status   TaskStatus
+}
+
+// Scheduler runs registered tasks at their scheduled times. A task still running when
+// it comes due again is skipped rather than run twice.
+type Scheduler struct {
+	mu      sync.Mutex
+	tasks   map[string]*scheduledTask
+	running sync.WaitGroup // task runs in progress
+}
+
+// New returns a scheduler with no tasks
+func New() *Scheduler {
+	return &Scheduler{tasks: map[string]*scheduledTask{}}
+}
+
+// defaultScheduler runs the application's recurring tasks
+var defaultScheduler = New()
+
+// Register adds a task. Disabled tasks are listed in Status but never run.
+func (s *Scheduler) Register(task Task) error {
+	schedule, err := Parse(task.Spec)
+	if err != nil {
+		return fmt.Errorf("task %s: %w", task.Name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.tasks[task.Name]; exists {
+		return fmt.Errorf("task %s is already registered", task.Name)
+	}
+	s.tasks[task.Name] = &scheduledTask{
+		Task:     task,
+		schedule: schedule,
+		status:   TaskStatus{Name: task.Name, Schedule: task.Spec, Enabled: task.Enabled},
+	}
+	return nil
+}
+
+// Start runs due tasks until ctx is cancelled, then waits for runs in progress to
+// return. Run it in its own goroutine.
+func (s *Scheduler) Start(ctx context.Context) {
+	now := time.Now()
+	s.mu.Lock()
+	enabled := 0
+	for _, task := range s.tasks {
+		if task.Enabled {
+			next := task.schedule.Next(now)
+			if task.RunAtStart {
+				next = now
+			}
+			task.status.NextRun = &next
+			enabled++
+		}
+	}
+	s.mu.Unlock()
+	slog.Info("Scheduler started", "tasks", len(s.tasks), "enabled", enabled)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Runs see the same cancelled ctx; let them finish before the clients close
+			s.running.Wait()
+			slog.Info("Scheduler stopped")
+			return
+		case now := <-ticker.C:
+			s.runDue(ctx, now)
+		}
+	}
+}
+
+// runDue starts every enabled task whose next run has arrived
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, task := range s.tasks {
+		if !task.Enabled || task.status.NextRun == nil || now.Before(*task.status.NextRun) {
+			continue
+		}
+		next := task.schedule.Next(now)
+		task.status.NextRun = &next
+
+		if task.status.Running {
+			task.status.LastStatus = "skipped"
+			slog.Warn("Skipping scheduled task still running from its previous run", "task", task.Name)
+			continue
+		}
+		task.status.Running = true
+		started := now
+		task.status.LastStarted = &started
+		s.running.Add(1)
+		go s.run(ctx, task)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, task *scheduledTask) {
+	defer s.running.Done()
+	if task.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, task.Timeout)
+		defer cancel()
+	}
+
+	started := time.Now()
+	err := runSafely(ctx, task.Run)
+	finished := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task.status.Running = false
+	task.status.LastFinished = &finished
+	task.status.LastDuration = finished.Sub(started).Round(time.Millisecond).String()
+	task.status.Runs++
+	if err != nil {
+		task.status.Failures++
+		task.status.LastStatus = "failed"
+		task.status.LastError = err.Error()
+		slog.Error("Scheduled task failed", "task", task.Name, "error", err)
+		return
+	}
+	task.status.LastStatus = "succeeded"
+	task.status.LastError = ""
+}
+
+// runSafely turns a panic in a task into an error so one bad run can't stop the scheduler
+func runSafely(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return run(ctx)
+}
+
+// Status returns every registered task's status, ordered by name
+func (s *Scheduler) Status() []TaskStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]TaskStatus, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		statuses = append(statuses, task.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Register adds a task to the default scheduler
+func Register(task Task) error {
+	return defaultScheduler.Register(task)
+}
+
+// Start runs the default scheduler until ctx is cancelled
+func Start(ctx context.Context) {
+	defaultScheduler.Start(ctx)
+}
+
+// Status returns the default scheduler's task statuses
+func Status() []TaskStatus {
+	return defaultScheduler.Status()
+}
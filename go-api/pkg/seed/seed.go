@@ -0,0 +1,339 @@
+// Package seed generates realistic sample products, customers, orders and reviews for
+// development and staging databases
+package seed
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// Dataset is a set of generated records that reference each other
+type Dataset struct {
+	Products  []*models.Product
+	Customers []*models.Customer
+	Orders    []*models.Order
+	Reviews   []*models.Review
+}
+
+// Generate builds counts of each record. Orders and reviews are placed by the generated
+// customers on the generated products, so they need at least one of each. Customer
+// totals, loyalty points and product ratings match the orders and reviews generated
+// with them. Every customer gets passwordHash, so they share one password.
+func Generate(counts models.SeedCounts, passwordHash string) (*Dataset, error) {
+	if (counts.Orders > 0 || counts.Reviews > 0) && (counts.Products == 0 || counts.Customers == 0) {
+		return nil, fmt.Errorf("orders and reviews need at least one product and one customer")
+	}
+
+	now := time.Now().Truncate(time.Millisecond)
+	data := &Dataset{}
+	skus := map[string]bool{}
+	for range counts.Products {
+		data.Products = append(data.Products, product(now, skus))
+	}
+	emails := map[string]bool{}
+	for range counts.Customers {
+		data.Customers = append(data.Customers, customer(now, emails, passwordHash))
+	}
+	orderNumbers := map[string]bool{}
+	for range counts.Orders {
+		data.Orders = append(data.Orders, order(now, data, orderNumbers))
+	}
+	reviewData(now, data, counts.Reviews)
+	return data, nil
+}
+
+// product generates an active product with stock in each warehouse
+func product(now time.Time, skus map[string]bool) *models.Product {
+	category := pick(catalog)
+	brand := pick(category.Brands)
+	adjective := pick(adjectives)
+	noun := pick(category.Nouns)
+
+	req := &models.CreateProductRequest{
+		Name:        fmt.Sprintf("%s %s %s", brand, adjective, noun),
+		Description: fmt.Sprintf("The %s %s from %s is %s. %s", strings.ToLower(adjective), strings.ToLower(noun), brand, pick(category.Pitches), pick(closers)),
+		Category:    category.Name,
+		Subcategory: pick(category.Subcategories),
+		Brand:       brand,
+		Price:       float64(category.MinPrice+rand.IntN(category.MaxPrice-category.MinPrice)) + 0.99,
+		Currency:    "CAD",
+		WeightKg:    math.Round((0.1+rand.Float64()*category.MaxWeightKg)*100) / 100,
+		Attributes: map[string]string{
+			"color":    pick(colors),
+			"material": pick(category.Materials),
+		},
+		Tags: []string{strings.ToLower(category.Name), strings.ToLower(noun), strings.ToLower(adjective)},
+	}
+	product := req.ToProduct()
+	for skus[product.SKU] {
+		product.SKU = req.GenerateSKU()
+	}
+	skus[product.SKU] = true
+
+	product.Images = []string{"https://picsum.photos/seed/" + product.SKU + "/800/800"}
+	// About one product in ten is sold out
+	if rand.IntN(10) > 0 {
+		product.Stock = models.Stock{WarehouseMain: rand.IntN(150), WarehouseEast: rand.IntN(60), WarehouseWest: rand.IntN(60)}
+	}
+	product.CalculateTotalStock()
+	product.CreatedAt = daysBefore(now, 365)
+	product.UpdatedAt = product.CreatedAt
+	return product
+}
+
+// customer generates an active customer with one or two addresses
+func customer(now time.Time, emails map[string]bool, passwordHash string) *models.Customer {
+	firstName := pick(firstNames)
+	lastName := pick(lastNames)
+	email := ""
+	for email == "" || emails[email] {
+		email = fmt.Sprintf("%s.%s.%04d@example.com", strings.ToLower(firstName), strings.ToLower(lastName), rand.IntN(10000))
+	}
+	emails[email] = true
+
+	addresses := []models.Address{address()}
+	if rand.IntN(4) == 0 {
+		addresses = append(addresses, address())
+	}
+	addresses[0].IsDefault = true
+
+	createdAt := daysBefore(now, 730)
+	return &models.Customer{
+		ID:        bson.NewObjectID(),
+		Email:     email,
+		Password:  passwordHash,
+		FirstName: firstName,
+		LastName:  lastName,
+		Phone:     fmt.Sprintf("+1-%03d-%03d-%04d", 200+rand.IntN(800), 200+rand.IntN(800), rand.IntN(10000)),
+		Addresses: addresses,
+		Preferences: models.Preferences{
+			Newsletter:         rand.IntN(2) == 0,
+			SMSNotifications:   rand.IntN(4) == 0,
+			EmailNotifications: rand.IntN(5) > 0,
+			Language:           pick([]string{"en", "en", "en", "fr", "es"}),
+			Currency:           "CAD",
+			FavoriteCategories: []string{pick(catalog).Name},
+		},
+		AccountStatus: "active",
+		EmailVerified: rand.IntN(5) > 0,
+		PhoneVerified: rand.IntN(2) == 0,
+		CreatedAt:     createdAt,
+		UpdatedAt:     createdAt,
+	}
+}
+
+// address generates a Canadian address
+func address() models.Address {
+	city := pick(cities)
+	return models.Address{
+		Street:     fmt.Sprintf("%d %s", 1+rand.IntN(9999), pick(streets)),
+		City:       city.Name,
+		Province:   city.Province,
+		PostalCode: fmt.Sprintf("%c%d%c %d%c%d", city.PostalPrefix, rand.IntN(10), letter(), rand.IntN(10), letter(), rand.IntN(10)),
+		Country:    "Canada",
+	}
+}
+
+// order generates an order placed by one of the customers since they signed up, with a
+// status and timeline that fit its age, and adds it to the customer's totals
+func order(now time.Time, data *Dataset, orderNumbers map[string]bool) *models.Order {
+	customer := pick(data.Customers)
+	orderedAt := between(customer.CreatedAt, now)
+
+	order := &models.Order{
+		ID:              bson.NewObjectID(),
+		CustomerID:      customer.ID,
+		CustomerEmail:   customer.Email,
+		ShippingAddress: pick(customer.Addresses),
+		Payment:         models.Payment{Method: pick([]string{"credit_card", "credit_card", "debit_card", "paypal"}), Status: "pending"},
+		Timeline:        models.Timeline{OrderedAt: orderedAt},
+		CreatedAt:       orderedAt,
+	}
+	order.ShippingAddress.IsDefault = false
+	for order.OrderNumber == "" || orderNumbers[order.OrderNumber] {
+		order.OrderNumber = fmt.Sprintf("ORD-%s-%03d", orderedAt.Format("20060102-150405"), rand.IntN(1000))
+	}
+	orderNumbers[order.OrderNumber] = true
+
+	inCart := map[string]bool{}
+	for range 1 + rand.IntN(min(4, len(data.Products))) {
+		product := pick(data.Products)
+		if inCart[product.SKU] {
+			continue
+		}
+		inCart[product.SKU] = true
+		order.Items = append(order.Items, models.OrderItem{
+			ProductID: product.ID,
+			SKU:       product.SKU,
+			Name:      product.Name,
+			Quantity:  1 + rand.IntN(3),
+			UnitPrice: product.Price,
+			WeightKg:  product.WeightKg,
+		})
+	}
+	order.CalculateAllTotals()
+
+	order.Status = orderStatus(now.Sub(orderedAt))
+	at := orderedAt
+	step := func(maxHours int) *time.Time {
+		at = at.Add(time.Duration(1+rand.IntN(maxHours)) * time.Hour)
+		if at.After(now) {
+			at = now
+		}
+		stamped := at
+		return &stamped
+	}
+	switch order.Status {
+	case "cancelled":
+		order.Timeline.CancelledAt = step(48)
+	case "processing", "shipped", "delivered":
+		order.Timeline.PaidAt = step(2)
+		order.Payment.Status = "completed"
+		order.Payment.Amount = order.Totals.AmountDue
+		order.Payment.TransactionID = fmt.Sprintf("seed_%016x", rand.Uint64())
+		if order.Status == "processing" {
+			break
+		}
+		order.Timeline.ShippedAt = step(72)
+		estimatedDelivery := order.Timeline.ShippedAt.AddDate(0, 0, 5)
+		order.Timeline.EstimatedDelivery = &estimatedDelivery
+		order.Tracking = &models.Tracking{Carrier: "Canada Post", Number: fmt.Sprintf("%016d", rand.Int64N(1e16))}
+		if order.Status == "delivered" {
+			order.Timeline.DeliveredAt = step(120)
+		}
+	}
+	order.UpdatedAt = at
+
+	if order.Status != "cancelled" {
+		customer.TotalOrders++
+		customer.TotalSpent = math.Round((customer.TotalSpent+order.Totals.GrandTotal)*100) / 100
+		if orderedAt.After(customer.LastOrderDate) {
+			customer.LastOrderDate = orderedAt
+		}
+	}
+	if order.Status == "delivered" {
+		customer.LoyaltyPoints += models.LoyaltyPointsEarned(order)
+	}
+	return order
+}
+
+// orderStatus picks a status for an order placed age ago; older orders are more likely
+// to have been delivered
+func orderStatus(age time.Duration) string {
+	roll := rand.IntN(100)
+	switch {
+	case roll < 8:
+		return "cancelled"
+	case age < 2*24*time.Hour:
+		return pick([]string{"pending", "processing"})
+	case age < 10*24*time.Hour:
+		return pick([]string{"processing", "shipped", "shipped", "delivered"})
+	default:
+		return "delivered"
+	}
+}
+
+// reviewData generates count approved reviews and sets each product's ratings from
+// them. Customers review a product at most once, and reviews of products they received
+// are verified purchases. It generates fewer when every customer has reviewed every
+// product.
+func reviewData(now time.Time, data *Dataset, count int) {
+	type purchase struct {
+		order   *models.Order
+		product *models.Product
+	}
+	productsByID := map[bson.ObjectID]*models.Product{}
+	for _, product := range data.Products {
+		productsByID[product.ID] = product
+	}
+	var purchases []purchase
+	for _, order := range data.Orders {
+		if order.Status != "delivered" {
+			continue
+		}
+		for _, item := range order.Items {
+			purchases = append(purchases, purchase{order: order, product: productsByID[item.ProductID]})
+		}
+	}
+
+	reviewed := map[[2]bson.ObjectID]bool{}
+	totals := map[bson.ObjectID]int{}
+	for attempts := 0; len(data.Reviews) < count && attempts < count*5; attempts++ {
+		review := &models.Review{ID: bson.NewObjectID(), ModerationStatus: "approved"}
+		var product *models.Product
+		// Most reviews come from customers who received the product
+		if len(purchases) > 0 && rand.IntN(10) < 7 {
+			bought := pick(purchases)
+			product = bought.product
+			review.CustomerID = bought.order.CustomerID
+			review.OrderID = bought.order.ID
+			review.VerifiedPurchase = true
+			review.CreatedAt = between(*bought.order.Timeline.DeliveredAt, now)
+		} else {
+			product = pick(data.Products)
+			reviewer := pick(data.Customers)
+			review.CustomerID = reviewer.ID
+			review.CreatedAt = between(later(reviewer.CreatedAt, product.CreatedAt), now)
+		}
+		key := [2]bson.ObjectID{product.ID, review.CustomerID}
+		if reviewed[key] {
+			continue
+		}
+		reviewed[key] = true
+
+		review.ProductID = product.ID
+		review.Rating = pick([]int{1, 2, 3, 3, 4, 4, 4, 5, 5, 5, 5, 5})
+		phrases := reviewPhrases[review.Rating]
+		review.Title = pick(phrases.Titles)
+		review.Comment = fmt.Sprintf(pick(phrases.Comments), strings.ToLower(product.Subcategory))
+		review.HelpfulCount = rand.IntN(25)
+		review.UpdatedAt = review.CreatedAt
+		data.Reviews = append(data.Reviews, review)
+
+		product.Ratings.Count++
+		totals[product.ID] += review.Rating
+	}
+
+	for _, product := range data.Products {
+		if product.Ratings.Count > 0 {
+			product.Ratings.Average = math.Round(float64(totals[product.ID])/float64(product.Ratings.Count)*10) / 10
+		}
+	}
+}
+
+// pick returns a random element of values
+func pick[T any](values []T) T {
+	return values[rand.IntN(len(values))]
+}
+
+// letter returns a random uppercase letter used in postal codes
+func letter() byte {
+	return "ABCEGHJKLMNPRSTVXY"[rand.IntN(18)]
+}
+
+// daysBefore returns a random time in the days before now
+func daysBefore(now time.Time, days int) time.Time {
+	return now.Add(-time.Duration(rand.Int64N(int64(days) * int64(24*time.Hour))))
+}
+
+// between returns a random time from start to end
+func between(start, end time.Time) time.Time {
+	if !end.After(start) {
+		return end
+	}
+	return start.Add(time.Duration(rand.Int64N(int64(end.Sub(start))))).Truncate(time.Millisecond)
+}
+
+// later returns the later of a and b
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,178 @@
+package seed
+
+// category describes the products generated in one catalog category
+type category struct {
+	Name          string
+	Subcategories []string
+	Brands        []string
+	Nouns         []string
+	Materials     []string
+	Pitches       []string // finish "The <product> from <brand> is ..."
+	MinPrice      int
+	MaxPrice      int
+	MaxWeightKg   float64
+}
+
+var catalog = []category{
+	{
+		Name:          "Electronics",
+		Subcategories: []string{"Audio", "Computers", "Phones", "Cameras", "Wearables"},
+		Brands:        []string{"Voltara", "Northwave", "Pixelon", "Auralink", "Quanta"},
+		Nouns:         []string{"Headphones", "Bluetooth Speaker", "Laptop Stand", "Webcam", "Smartwatch", "Charging Dock", "Keyboard", "Wireless Mouse"},
+		Materials:     []string{"aluminum", "plastic", "silicone"},
+		Pitches:       []string{"built for all-day use with a battery that keeps up", "tuned for clear sound and simple pairing", "compact enough to travel and sturdy enough for daily use"},
+		MinPrice:      19,
+		MaxPrice:      499,
+		MaxWeightKg:   2.5,
+	},
+	{
+		Name:          "Home & Kitchen",
+		Subcategories: []string{"Cookware", "Small Appliances", "Storage", "Tableware"},
+		Brands:        []string{"Hearthstone", "Maple & Co", "Kitchenry", "Ashford Home"},
+		Nouns:         []string{"Cast Iron Skillet", "Chef's Knife", "Pour-Over Coffee Maker", "Storage Jar Set", "Cutting Board", "Dutch Oven", "Kettle"},
+		Materials:     []string{"stainless steel", "cast iron", "bamboo", "ceramic", "glass"},
+		Pitches:       []string{"made to handle busy weeknights and slow Sunday dinners", "easy to clean and built to last for years", "designed to look good on the counter between uses"},
+		MinPrice:      12,
+		MaxPrice:      289,
+		MaxWeightKg:   6,
+	},
+	{
+		Name:          "Sports & Outdoors",
+		Subcategories: []string{"Camping", "Fitness", "Cycling", "Winter Sports"},
+		Brands:        []string{"Trailhead", "Boreal Gear", "Summit Supply", "Ridgeline"},
+		Nouns:         []string{"Hiking Backpack", "Yoga Mat", "Insulated Water Bottle", "Camping Lantern", "Resistance Bands", "Trekking Poles", "Sleeping Bag"},
+		Materials:     []string{"nylon", "polyester", "aluminum", "rubber"},
+		Pitches:       []string{"ready for long trails and Canadian winters", "light enough to carry all day", "tested in rain, snow and everything between"},
+		MinPrice:      15,
+		MaxPrice:      349,
+		MaxWeightKg:   4,
+	},
+	{
+		Name:          "Clothing",
+		Subcategories: []string{"Outerwear", "Tops", "Footwear", "Accessories"},
+		Brands:        []string{"Lakeshore", "Urban Fjord", "Cedar & Wool", "Northline"},
+		Nouns:         []string{"Parka", "Merino Sweater", "Rain Jacket", "Flannel Shirt", "Toque", "Leather Boots", "Fleece Hoodie"},
+		Materials:     []string{"merino wool", "cotton", "down", "leather", "fleece"},
+		Pitches:       []string{"cut for an easy fit and made to be worn every day", "warm without the bulk", "finished with details that hold up season after season"},
+		MinPrice:      18,
+		MaxPrice:      399,
+		MaxWeightKg:   1.5,
+	},
+	{
+		Name:          "Books",
+		Subcategories: []string{"Fiction", "Cookbooks", "Biography", "Travel"},
+		Brands:        []string{"Harbour Press", "Lantern Books", "Northern Pages"},
+		Nouns:         []string{"Hardcover Novel", "Cookbook", "Travel Guide", "Memoir", "Field Guide", "Short Story Collection"},
+		Materials:     []string{"hardcover", "paperback"},
+		Pitches:       []string{"a page-turner readers keep recommending", "full of practical detail and beautiful photography", "a thoughtful read for a long weekend"},
+		MinPrice:      9,
+		MaxPrice:      65,
+		MaxWeightKg:   1.2,
+	},
+	{
+		Name:          "Beauty",
+		Subcategories: []string{"Skincare", "Hair Care", "Fragrance"},
+		Brands:        []string{"Glacier Bloom", "Pure Maple", "Luma"},
+		Nouns:         []string{"Face Moisturizer", "Hair Serum", "Lip Balm Set", "Eau de Parfum", "Cleansing Oil", "Hand Cream"},
+		Materials:     []string{"glass bottle", "recycled plastic", "aluminum tube"},
+		Pitches:       []string{"gentle enough for every day", "made with simple ingredients and no added fragrance", "a small luxury for dry Canadian winters"},
+		MinPrice:      8,
+		MaxPrice:      140,
+		MaxWeightKg:   0.5,
+	},
+	{
+		Name:          "Toys & Games",
+		Subcategories: []string{"Board Games", "Puzzles", "Building Sets", "Outdoor Play"},
+		Brands:        []string{"Playwell", "Brightblock", "Tinker Town"},
+		Nouns:         []string{"Board Game", "1000-Piece Puzzle", "Building Set", "Card Game", "Kite", "Wooden Train Set"},
+		Materials:     []string{"wood", "cardboard", "plastic"},
+		Pitches:       []string{"a favourite for family game night", "easy to learn and hard to put down", "built to survive years of play"},
+		MinPrice:      10,
+		MaxPrice:      120,
+		MaxWeightKg:   2,
+	},
+}
+
+var adjectives = []string{"Classic", "Essential", "Pro", "Compact", "Premium", "Everyday", "Deluxe", "Heritage", "Lightweight", "Signature"}
+
+var closers = []string{
+	"Backed by a one-year warranty.",
+	"A customer favourite since launch.",
+	"Ships from our Canadian warehouses.",
+	"Makes a great gift.",
+	"Available while stock lasts.",
+}
+
+var colors = []string{"black", "white", "navy", "forest green", "charcoal", "red", "sand", "slate blue"}
+
+var firstNames = []string{
+	"Olivia", "Liam", "Emma", "Noah", "Charlotte", "William", "Amelia", "Benjamin", "Sophia", "Lucas",
+	"Chloe", "Ethan", "Maya", "Owen", "Zoe", "Jacob", "Aiden", "Priya", "Mateo", "Aisha",
+	"Wei", "Hannah", "Samuel", "Leah", "Gabriel", "Nora", "Arjun", "Isabelle", "Daniel", "Fatima",
+}
+
+var lastNames = []string{
+	"Smith", "Tremblay", "Martin", "Roy", "Wilson", "MacDonald", "Gagnon", "Brown", "Lee", "Johnson",
+	"Patel", "Nguyen", "Singh", "Campbell", "Anderson", "Bouchard", "Chen", "Taylor", "Leblanc", "Kim",
+}
+
+// city is where generated addresses are, with the first letter of its postal codes
+type city struct {
+	Name         string
+	Province     string
+	PostalPrefix byte
+}
+
+var cities = []city{
+	{"Toronto", "ON", 'M'},
+	{"Kitchener", "ON", 'N'},
+	{"Waterloo", "ON", 'N'},
+	{"Ottawa", "ON", 'K'},
+	{"Montreal", "QC", 'H'},
+	{"Quebec City", "QC", 'G'},
+	{"Vancouver", "BC", 'V'},
+	{"Victoria", "BC", 'V'},
+	{"Calgary", "AB", 'T'},
+	{"Edmonton", "AB", 'T'},
+	{"Winnipeg", "MB", 'R'},
+	{"Saskatoon", "SK", 'S'},
+	{"Halifax", "NS", 'B'},
+	{"Moncton", "NB", 'E'},
+	{"St. John's", "NL", 'A'},
+	{"Charlottetown", "PE", 'C'},
+}
+
+var streets = []string{
+	"King Street", "Queen Street", "Main Street", "Maple Avenue", "Victoria Road", "Lakeshore Drive",
+	"Church Street", "Elm Street", "Park Avenue", "Wellington Street", "Oak Crescent", "Highland Road",
+}
+
+// phrases are the titles and comments of reviews with one rating. Comments take the
+// product's subcategory.
+type phrases struct {
+	Titles   []string
+	Comments []string
+}
+
+var reviewPhrases = map[int]phrases{
+	1: {
+		Titles:   []string{"Disappointed", "Would not buy again", "Broke quickly"},
+		Comments: []string{"Stopped working after a couple of weeks. Expected a lot more from %s.", "Looked nothing like the photos and the quality is poor for %s at this price.", "Returned it. There are better options in %s."},
+	},
+	2: {
+		Titles:   []string{"Not great", "Below expectations", "Just okay at best"},
+		Comments: []string{"It works, but it feels cheap compared to other %s I've owned.", "Arrived quickly, but the finish was rough. Mediocre for %s.", "A few design issues make it hard to recommend over other %s."},
+	},
+	3: {
+		Titles:   []string{"Does the job", "Decent for the price", "It's fine"},
+		Comments: []string{"Nothing special, but it does what it says. Average for %s.", "Good value, though I've seen better quality in %s.", "Some things I like and some I don't. Solid middle of the road for %s."},
+	},
+	4: {
+		Titles:   []string{"Really good", "Happy with it", "Great value"},
+		Comments: []string{"Well made and works as described. One of the better %s I've bought.", "Very happy overall; one small quibble keeps it from five stars among %s.", "Good quality and fast shipping. Would recommend for anyone shopping for %s."},
+	},
+	5: {
+		Titles:   []string{"Love it!", "Exceeded expectations", "Best purchase this year"},
+		Comments: []string{"Fantastic quality. The best %s I've owned, by far.", "Bought one for myself and another as a gift. Can't fault it for %s.", "Exactly what I wanted. Raises the bar for %s."},
+	},
+}
@@ -0,0 +1,155 @@
+package shipping
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// canadaPostMediaType is the content type of Canada Post's rating API
+const canadaPostMediaType = "application/vnd.cpc.ship.rate-v4+xml"
+
+// Canada Post services each method can ship by, cheapest first
+var canadaPostServices = map[string][]string{
+	models.ShippingStandard: {"DOM.RP", "DOM.EP"}, // Regular Parcel, Expedited Parcel
+	models.ShippingExpress:  {"DOM.XP", "DOM.PC"}, // Xpresspost, Priority
+}
+
+// mailingScenario is the body of a Canada Post rating request
+type mailingScenario struct {
+	XMLName        xml.Name `xml:"http://www.canadapost.ca/ws/ship/rate-v4 mailing-scenario"`
+	CustomerNumber string   `xml:"customer-number"`
+	Parcel         struct {
+		Weight float64 `xml:"weight"` // kilograms, three decimals at most
+	} `xml:"parcel-characteristics"`
+	OriginPostalCode string `xml:"origin-postal-code"`
+	Destination      struct {
+		Domestic struct {
+			PostalCode string `xml:"postal-code"`
+		} `xml:"domestic"`
+	} `xml:"destination"`
+}
+
+// priceQuotes is Canada Post's answer to a rating request
+type priceQuotes struct {
+	Quotes []struct {
+		ServiceCode string  `xml:"service-code"`
+		ServiceName string  `xml:"service-name"`
+		Due         float64 `xml:"price-details>due"`
+		TransitDays int     `xml:"service-standard>expected-transit-time"`
+	} `xml:"price-quote"`
+}
+
+// CanadaPostError is returned when Canada Post answers with an error status
+type CanadaPostError struct {
+	StatusCode int
+	Code       string `xml:"message>code"`
+	Message    string `xml:"message>description"`
+}
+
+func (e *CanadaPostError) Error() string {
+	return fmt.Sprintf("canada post returned %d (%s): %s", e.StatusCode, e.Code, e.Message)
+}
+
+// canadaPost quotes domestic parcels through the Canada Post rating API
+type canadaPost struct {
+	apiBase          string
+	username         string
+	password         string
+	customerNumber   string
+	originPostalCode string
+	httpClient       *http.Client
+}
+
+func newCanadaPost(cfg config.ShippingConfig) *canadaPost {
+	return &canadaPost{
+		apiBase:          strings.TrimSuffix(cfg.CanadaPostAPIBase, "/"),
+		username:         cfg.CanadaPostUsername,
+		password:         cfg.CanadaPostPassword,
+		customerNumber:   cfg.CanadaPostCustomer,
+		originPostalCode: normalizePostalCode(cfg.OriginPostalCode),
+		httpClient:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (cp *canadaPost) Name() string {
+	return CarrierCanadaPost
+}
+
+// Quote asks Canada Post for every service to the destination and keeps the cheapest
+// service for each method
+func (cp *canadaPost) Quote(ctx context.Context, shipment Shipment) ([]models.ShippingRate, error) {
+	scenario := mailingScenario{CustomerNumber: cp.customerNumber, OriginPostalCode: cp.originPostalCode}
+	scenario.Parcel.Weight = max(float64(int(shipment.WeightKg*1000+0.5))/1000, 0.001)
+	scenario.Destination.Domestic.PostalCode = normalizePostalCode(shipment.PostalCode)
+
+	body, err := xml.Marshal(scenario)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cp.apiBase+"/rs/ship/price", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(cp.username, cp.password)
+	req.Header.Set("Accept", canadaPostMediaType)
+	req.Header.Set("Content-Type", canadaPostMediaType)
+	req.Header.Set("Accept-language", "en-CA")
+
+	resp, err := cp.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		failure := CanadaPostError{StatusCode: resp.StatusCode}
+		if err := xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure); err != nil || failure.Message == "" {
+			failure.Message = resp.Status
+		}
+		return nil, &failure
+	}
+
+	var quotes priceQuotes
+	if err := xml.NewDecoder(resp.Body).Decode(&quotes); err != nil {
+		return nil, err
+	}
+
+	var rates []models.ShippingRate
+	for _, method := range []string{models.ShippingStandard, models.ShippingExpress} {
+		var best *models.ShippingRate
+		for _, code := range canadaPostServices[method] {
+			for _, quote := range quotes.Quotes {
+				if quote.ServiceCode != code || (best != nil && best.Amount <= quote.Due) {
+					continue
+				}
+				best = &models.ShippingRate{
+					Method:        method,
+					Carrier:       CarrierCanadaPost,
+					Service:       quote.ServiceName,
+					Amount:        quote.Due,
+					EstimatedDays: quote.TransitDays,
+				}
+			}
+		}
+		if best != nil {
+			rates = append(rates, *best)
+		}
+	}
+	return rates, nil
+}
+
+// normalizePostalCode formats a postal code the way Canada Post expects: uppercase
+// without spaces
+func normalizePostalCode(postalCode string) string {
+	return strings.ToUpper(strings.ReplaceAll(postalCode, " ", ""))
+}
@@ -0,0 +1,143 @@
+package shipping
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strings"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+)
+
+// Carriers a rate can come from
+const (
+	CarrierTable      = "table" // the built-in rate table
+	CarrierCanadaPost = "canada_post"
+)
+
+// Shipment is a parcel to be priced
+type Shipment struct {
+	Province   string
+	PostalCode string
+	Country    string // empty means Canada
+	WeightKg   float64
+}
+
+// Carrier is a shipping company whose API quotes rates. A carrier returns a rate for
+// every method it can ship the parcel by.
+type Carrier interface {
+	Name() string
+	Quote(ctx context.Context, shipment Shipment) ([]models.ShippingRate, error)
+}
+
+// zone is how far a destination is from the warehouse
+type zone struct {
+	name     string
+	standard tableRate
+	express  tableRate
+}
+
+// tableRate is a base price plus a price per kilogram
+type tableRate struct {
+	base  float64
+	perKg float64
+	days  int
+}
+
+var (
+	zoneLocal         = zone{"local", tableRate{6.99, 1.00, 2}, tableRate{12.99, 1.50, 1}}
+	zoneNational      = zone{"national", tableRate{9.99, 1.50, 5}, tableRate{19.99, 2.50, 2}}
+	zoneRemote        = zone{"remote", tableRate{19.99, 3.00, 8}, tableRate{34.99, 4.00, 4}}
+	zoneInternational = zone{"international", tableRate{24.99, 5.00, 10}, tableRate{44.99, 7.00, 5}}
+)
+
+// remoteProvinces are the territories, which cost more to reach from anywhere
+var remoteProvinces = map[string]bool{"NT": true, "NU": true, "YT": true}
+
+var (
+	originProvince  = "ON"
+	defaultWeightKg = 0.5
+	carrier         Carrier
+)
+
+// Init sets the warehouse's province and connects Canada Post when its credentials are
+// set; without them every rate comes from the table
+func Init(cfg config.ShippingConfig) {
+	originProvince = cfg.OriginProvince
+	defaultWeightKg = float64(cfg.DefaultWeightGrams) / 1000
+	carrier = nil
+
+	if cfg.CanadaPostUsername == "" || cfg.CanadaPostPassword == "" || cfg.CanadaPostCustomer == "" || cfg.OriginPostalCode == "" {
+		slog.Info("Canada Post rates disabled - using the built-in rate table")
+		return
+	}
+	carrier = newCanadaPost(cfg)
+	slog.Info("Shipping rates enabled", "carrier", CarrierCanadaPost, "origin", cfg.OriginPostalCode)
+}
+
+// ItemWeight is the shipping weight of one unit of a product, or the default weight for
+// products without one
+func ItemWeight(weightKg float64) float64 {
+	if weightKg > 0 {
+		return weightKg
+	}
+	return defaultWeightKg
+}
+
+// zoneFor returns the zone a shipment is going to
+func zoneFor(shipment Shipment) zone {
+	country := strings.ToUpper(shipment.Country)
+	switch province := strings.ToUpper(shipment.Province); {
+	case country != "" && country != "CA" && country != "CANADA":
+		return zoneInternational
+	case remoteProvinces[province]:
+		return zoneRemote
+	case province == originProvince:
+		return zoneLocal
+	default:
+		return zoneNational
+	}
+}
+
+// TableRates prices a shipment from the built-in table, by destination zone and weight
+func TableRates(shipment Shipment) []models.ShippingRate {
+	z := zoneFor(shipment)
+	weight := math.Max(shipment.WeightKg, 0)
+
+	price := func(method string, rate tableRate) models.ShippingRate {
+		return models.ShippingRate{
+			Method:        method,
+			Carrier:       CarrierTable,
+			Service:       z.name,
+			Amount:        math.Round((rate.base+rate.perKg*math.Ceil(weight))*100) / 100,
+			EstimatedDays: rate.days,
+		}
+	}
+	return []models.ShippingRate{
+		price(models.ShippingStandard, z.standard),
+		price(models.ShippingExpress, z.express),
+	}
+}
+
+// Quote prices a shipment with the carrier when one is configured and the destination
+// is a Canadian postal code; otherwise, or if the carrier fails, it uses the table
+func Quote(ctx context.Context, shipment Shipment) []models.ShippingRate {
+	if carrier == nil || shipment.PostalCode == "" || zoneFor(shipment).name == zoneInternational.name {
+		return TableRates(shipment)
+	}
+
+	rates, err := carrier.Quote(ctx, shipment)
+	if err != nil || len(rates) == 0 {
+		slog.WarnContext(ctx, "Carrier quote failed, using table rates", "carrier", carrier.Name(), "error", err)
+		return TableRates(shipment)
+	}
+
+	// Fill in any method the carrier can't offer from the table
+	for _, rate := range TableRates(shipment) {
+		if _, ok := models.FindShippingRate(rates, rate.Method); !ok {
+			rates = append(rates, rate)
+		}
+	}
+	return rates
+}
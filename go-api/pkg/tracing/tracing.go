@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+)
+
+// Init exports spans over OTLP/HTTP when an OTLP endpoint is configured; otherwise
+// spans are created but dropped. The returned function flushes pending spans and
+// should be called on shutdown.
+func Init(ctx context.Context, cfg config.TracingConfig, env string) (func(context.Context) error, error) {
+	// Trace context is always propagated so callers' traces continue through the API
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.OTLPEndpoint == "" {
+		slog.Info("Tracing export disabled - OTEL_EXPORTER_OTLP_ENDPOINT not set")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// The exporter reads the endpoint, headers and protocol options from the standard OTEL_ variables
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.DeploymentEnvironmentName(env),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	slog.Info("Tracing enabled", "service", cfg.ServiceName)
+	return provider.Shutdown, nil
+}
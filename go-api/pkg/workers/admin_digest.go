@@ -0,0 +1,121 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/ai"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// adminDigestTopProducts is how many best sellers the digest lists
+const adminDigestTopProducts = 5
+
+// sendAdminDigest returns the task that emails the past week's sales, best sellers, low
+// stock and AI sales summary to each recipient. The week and recipient make the dedupe
+// key, so instances running the task at the same time queue one email each.
+func sendAdminDigest(recipients []string, lowStockThreshold int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		now := time.Now().UTC()
+		weekStart := now.AddDate(0, 0, -7).Format("2006-01-02")
+		weekEnd := now.AddDate(0, 0, -1).Format("2006-01-02")
+
+		vars, err := buildAdminDigest(ctx, weekStart, weekEnd, lowStockThreshold)
+		if err != nil {
+			return err
+		}
+
+		for _, recipient := range recipients {
+			dedupeKey := models.TemplateAdminDigest + ":" + weekStart + ":" + recipient
+			if _, err := mongo.QueueTemplatedNotification(ctx, models.TemplateAdminDigest, recipient, models.TemplateAdminDigest, dedupeKey, vars); err != nil {
+				return fmt.Errorf("failed to queue digest for %s: %w", recipient, err)
+			}
+		}
+		slog.Info("Queued admin digest", "week_start", weekStart, "recipients", len(recipients))
+		return nil
+	}
+}
+
+// buildAdminDigest loads the week's figures and formats each digest section as text for
+// the admin.digest template
+func buildAdminDigest(ctx context.Context, weekStart, weekEnd string, lowStockThreshold int) (map[string]string, error) {
+	report, err := ai.GenerateSalesReport(ctx, weekStart, weekEnd, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sales: %w", err)
+	}
+	sales, _ := report.Data.RawData.([]mongo.SalesData)
+
+	topProducts, err := mongo.GetTopProductsByRevenue(ctx, adminDigestTopProducts, "revenue", weekStart, weekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load top products: %w", err)
+	}
+
+	lowStock, err := mongo.GetInventory(ctx, mongo.InventoryFilter{LowStockThreshold: lowStockThreshold}, 1, lowStockScanLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan inventory: %w", err)
+	}
+
+	summary := report.Data.AIInsights
+	if summary == "" {
+		summary = "AI insights are unavailable this week."
+	}
+
+	return map[string]string{
+		"week_start":   weekStart,
+		"week_end":     weekEnd,
+		"sales":        formatDigestSales(sales),
+		"top_products": formatDigestTopProducts(topProducts),
+		"low_stock":    formatDigestLowStock(lowStock.Items, lowStock.Pagination.TotalItems, lowStockThreshold),
+		"ai_summary":   summary,
+	}, nil
+}
+
+// formatDigestSales totals the week and lists each day with orders
+func formatDigestSales(sales []mongo.SalesData) string {
+	if len(sales) == 0 {
+		return "No completed orders."
+	}
+
+	var orders int
+	var revenue float64
+	var lines strings.Builder
+	for _, day := range sales {
+		orders += day.TotalOrders
+		revenue += day.TotalRevenue
+		fmt.Fprintf(&lines, "\n  %s: %d orders, $%.2f", day.Date, day.TotalOrders, day.TotalRevenue)
+	}
+	return fmt.Sprintf("%d orders, $%.2f revenue, $%.2f average order", orders, revenue, revenue/float64(orders)) + lines.String()
+}
+
+// formatDigestTopProducts lists the best sellers by revenue
+func formatDigestTopProducts(products []mongo.TopProduct) string {
+	if len(products) == 0 {
+		return "No products sold."
+	}
+
+	lines := make([]string, 0, len(products))
+	for i, product := range products {
+		lines = append(lines, fmt.Sprintf("%d. %s (%s): %d sold, $%.2f", i+1, product.ProductName, product.SKU, product.TotalSold, product.TotalRevenue))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatDigestLowStock lists products at or below the threshold, noting any left off
+func formatDigestLowStock(items []models.InventoryItem, total, threshold int) string {
+	if len(items) == 0 {
+		return fmt.Sprintf("No products at or below %d units.", threshold)
+	}
+
+	lines := make([]string, 0, len(items)+1)
+	for _, item := range items {
+		lines = append(lines, fmt.Sprintf("%s (%s): %d left", item.Name, item.SKU, item.Stock.Total))
+	}
+	if total > len(items) {
+		lines = append(lines, fmt.Sprintf("...and %d more", total-len(items)))
+	}
+	return strings.Join(lines, "\n")
+}
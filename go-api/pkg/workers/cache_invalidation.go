@@ -0,0 +1,126 @@
+package workers
+
+import (
+	"context"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+)
+
+// analyticsSources are the events whose writes change what analytics report: sales,
+// regions and retention read orders, segments read customers, top products and
+// inventory read products and stock, and sentiment reads reviews
+var analyticsSources = []string{
+	events.ProductCreated,
+	events.ProductUpdated,
+	events.ProductDeleted,
+	events.StockChanged,
+	events.OrderCreated,
+	events.OrderUpdated,
+	events.OrderDeleted,
+	events.OrderPaymentChanged,
+	events.CustomerCreated,
+	events.CustomerUpdated,
+	events.CustomerDeleted,
+	events.ReviewCreated,
+	events.ReviewUpdated,
+	events.ReviewDeleted,
+	events.DatabaseSeeded,
+}
+
+// categorySources are the events whose writes can add, rename or remove a category
+var categorySources = []string{
+	events.ProductCreated,
+	events.ProductUpdated,
+	events.ProductDeleted,
+	events.DatabaseSeeded,
+}
+
+// registerCacheInvalidation subscribes the Redis cache to every write that affects it.
+// Handlers and workers write to MongoDB only; this is the one place cached products,
+// categories and analytics are refreshed or dropped after a change, each according to
+// its cache strategy.
+func registerCacheInvalidation() {
+	events.On(events.ProductCreated, refreshCreatedProduct)
+	events.On(events.ProductUpdated, refreshUpdatedProduct)
+	events.On(events.StockChanged, refreshCachedStock)
+	events.On(events.ProductDeleted, removeCachedProduct)
+
+	for _, eventType := range categorySources {
+		events.On(eventType, refreshCategories)
+	}
+
+	// Analytics results are recomputed on the next request
+	for _, eventType := range analyticsSources {
+		events.On(eventType, clearAnalytics)
+	}
+}
+
+// refreshCreatedProduct caches a new product, or indexes it for autocomplete when
+// products are only cached on read
+func refreshCreatedProduct(ctx context.Context, event events.DomainEvent) error {
+	var payload events.ProductCreatedEvent
+	if err := event.Decode(&payload); err != nil {
+		return err
+	}
+	return redis.RefreshCachedProduct(ctx, payload.Product)
+}
+
+// refreshUpdatedProduct stores or drops the cached copy of an updated product
+func refreshUpdatedProduct(ctx context.Context, event events.DomainEvent) error {
+	var payload events.ProductUpdatedEvent
+	if err := event.Decode(&payload); err != nil {
+		return err
+	}
+	return redis.RefreshCachedProduct(ctx, payload.Product)
+}
+
+// refreshCachedStock stores or drops the cached copy of a product whose stock changed
+func refreshCachedStock(ctx context.Context, event events.DomainEvent) error {
+	var payload events.StockChangedEvent
+	if err := event.Decode(&payload); err != nil {
+		return err
+	}
+	return redis.RefreshCachedProduct(ctx, payload.Product)
+}
+
+// removeCachedProduct drops a deleted product from the Redis product cache
+func removeCachedProduct(ctx context.Context, event events.DomainEvent) error {
+	var payload events.ProductDeletedEvent
+	if err := event.Decode(&payload); err != nil {
+		return err
+	}
+	return redis.RemoveProductFromCache(ctx, payload.Product)
+}
+
+// refreshCategories reloads the category list in every language when categories are
+// written through, and otherwise drops the cached lists
+func refreshCategories(ctx context.Context, event events.DomainEvent) error {
+	if !redis.Categories.Enabled() {
+		return nil
+	}
+	if !redis.Categories.WritesThrough() {
+		return redis.Categories.Delete(ctx, mongo.Languages()...)
+	}
+
+	for _, language := range mongo.Languages() {
+		categories, err := mongo.GetAllCategories(mongo.WithLanguage(ctx, language))
+		if err != nil {
+			return err
+		}
+		if err := redis.Categories.Set(ctx, language, categories); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearAnalytics drops cached analytics results
+func clearAnalytics(ctx context.Context, event events.DomainEvent) error {
+	if !redis.Analytics.Enabled() {
+		return nil
+	}
+	_, err := redis.Analytics.Clear(ctx)
+	return err
+}
@@ -0,0 +1,142 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+)
+
+// CartAbandonedEvent is the payload posted to the abandonment webhook
+type CartAbandonedEvent struct {
+	Event string                `json:"event"`
+	Cart  *models.AbandonedCart `json:"cart"`
+}
+
+// sweepAbandonedCarts returns the scheduled task that finds carts idle longer than
+// CartAbandonmentIdle, snapshots them to MongoDB and notifies the webhook
+func sweepAbandonedCarts(cfg config.WorkersConfig) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return detectAbandonedCarts(ctx, cfg.CartAbandonmentIdle, cfg.CartAbandonmentWebhook)
+	}
+}
+
+// detectAbandonedCarts runs a single abandonment scan
+func detectAbandonedCarts(ctx context.Context, idleFor time.Duration, webhookURL string) error {
+	idleCarts, err := redis.GetIdleCarts(ctx, time.Now().Add(-idleFor))
+	if err != nil {
+		return fmt.Errorf("failed to scan for idle carts: %w", err)
+	}
+
+	for sessionID, lastActivity := range idleCarts {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		cart, err := redis.GetCart(ctx, sessionID)
+		if err != nil {
+			slog.Error("Error loading idle cart", "session_id", sessionID, "error", err)
+			continue
+		}
+
+		// Empty or already expired carts are not worth following up
+		if len(cart.Items) == 0 {
+			_ = redis.UntrackCartActivity(ctx, sessionID)
+			continue
+		}
+
+		snapshot := models.NewAbandonedCart(cart, lastActivity)
+		if webhookURL != "" {
+			if err := queueAbandonmentWebhook(ctx, webhookURL, snapshot); err != nil {
+				slog.Warn("Failed to queue abandonment webhook", "session_id", sessionID, "error", err)
+				snapshot.WebhookStatus = "failed"
+			} else {
+				snapshot.WebhookStatus = "queued"
+			}
+		}
+
+		if _, err := mongo.SaveAbandonedCart(ctx, snapshot); err != nil {
+			slog.Error("Error saving abandoned cart", "session_id", sessionID, "error", err)
+			continue
+		}
+
+		_ = redis.UntrackCartActivity(ctx, sessionID)
+		slog.Info("Cart marked as abandoned", "session_id", sessionID, "items", snapshot.ItemCount, "total", snapshot.Total)
+	}
+
+	return nil
+}
+
+// queueAbandonmentWebhook queues the cart.abandoned event for the notification worker
+// to post to the configured URL
+func queueAbandonmentWebhook(ctx context.Context, url string, snapshot *models.AbandonedCart) error {
+	body, err := json.Marshal(CartAbandonedEvent{Event: "cart.abandoned", Cart: snapshot})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return mongo.EnqueueNotification(ctx, &models.Notification{
+		Channel:     models.NotificationWebhook,
+		Recipient:   url,
+		Event:       "cart.abandoned",
+		Body:        string(body),
+		Status:      models.NotificationPending,
+		AvailableAt: now,
+		CreatedAt:   now,
+	})
+}
+
+// recoverAbandonedCarts marks a customer's abandoned carts recovered when they place an
+// order
+func recoverAbandonedCarts(ctx context.Context, event events.DomainEvent) error {
+	var payload events.OrderCreatedEvent
+	if err := event.Decode(&payload); err != nil {
+		return err
+	}
+
+	recovered, err := mongo.MarkAbandonedCartsRecovered(ctx, payload.Order.CustomerEmail, payload.Order.Timeline.OrderedAt)
+	if err != nil {
+		return err
+	}
+	if recovered > 0 {
+		slog.InfoContext(ctx, "Recovered abandoned carts", "order_number", payload.Order.OrderNumber, "carts", recovered)
+	}
+	return nil
+}
+
+// postWebhook posts payload as JSON, treating any non-2xx response as a failure
+func postWebhook(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+)
+
+// StartCartPersistenceWorker snapshots every cart that changed since the previous sweep
+// into the MongoDB carts collection every CartPersistSweep, so carts survive the Redis
+// TTL. It blocks until ctx is cancelled, so run it in its own goroutine.
+func StartCartPersistenceWorker(ctx context.Context, cfg config.WorkersConfig) {
+	slog.Info("Cart persistence worker started", "sweep_seconds", int(cfg.CartPersistSweep.Seconds()))
+
+	ticker := time.NewTicker(cfg.CartPersistSweep)
+	defer ticker.Stop()
+
+	// Catch carts touched shortly before startup as well
+	lastSweep := time.Now().Add(-cfg.CartPersistSweep)
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Cart persistence worker stopped")
+			return
+		case <-ticker.C:
+			now := time.Now()
+			persistChangedCarts(ctx, lastSweep, now)
+			lastSweep = now
+		}
+	}
+}
+
+// persistChangedCarts snapshots carts with activity in the (since, until] window
+func persistChangedCarts(ctx context.Context, since, until time.Time) {
+	sweepCtx, cancel := global.GetDefaultTimer()
+	defer cancel()
+
+	sessionIDs, err := redis.GetCartsActiveSince(sweepCtx, since, until)
+	if err != nil {
+		slog.Error("Error listing changed carts", "error", err)
+		return
+	}
+
+	for _, sessionID := range sessionIDs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		cart, err := redis.GetCart(sweepCtx, sessionID)
+		if err != nil {
+			slog.Error("Error loading cart for persistence", "session_id", sessionID, "error", err)
+			continue
+		}
+
+		if err := mongo.SaveCartSnapshot(sweepCtx, models.NewPersistedCart(cart)); err != nil {
+			slog.Error("Error persisting cart", "session_id", sessionID, "error", err)
+		}
+	}
+}
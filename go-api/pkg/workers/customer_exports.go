@@ -0,0 +1,146 @@
+package workers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+const (
+	// customerExportLease hides a claimed export from other workers while it is built
+	customerExportLease = 5 * time.Minute
+	// customerExportTimeout bounds gathering and storing one export
+	customerExportTimeout = 2 * time.Minute
+	// customerExportRetryDelay is how long a failed export waits before its next attempt
+	customerExportRetryDelay = time.Minute
+	// maxCustomerExportSize keeps the stored file under MongoDB's 16 MB document limit
+	maxCustomerExportSize = 15 << 20
+)
+
+// StartCustomerExportWorker builds queued customer data exports every
+// CustomerExportInterval. Exports are leased from MongoDB, so each is built by one
+// instance and picked up again if that instance stops mid-way.
+// It blocks until ctx is cancelled, so run it in its own goroutine.
+func StartCustomerExportWorker(ctx context.Context, cfg config.WorkersConfig) {
+	slog.Info("Customer export worker started", "interval_seconds", int(cfg.CustomerExportInterval.Seconds()))
+
+	ticker := time.NewTicker(cfg.CustomerExportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Customer export worker stopped")
+			return
+		case <-ticker.C:
+			buildCustomerExports(ctx)
+		}
+	}
+}
+
+// buildCustomerExports builds queued exports, oldest first, until none are left
+func buildCustomerExports(ctx context.Context) {
+	for ctx.Err() == nil {
+		if !buildNextCustomerExport(ctx) {
+			return
+		}
+	}
+}
+
+// buildNextCustomerExport claims and builds one export, reporting whether there was one
+// to try
+func buildNextCustomerExport(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, customerExportTimeout)
+	defer cancel()
+
+	export, err := mongo.ClaimCustomerExport(ctx, customerExportLease)
+	if err != nil {
+		slog.Error("Error claiming customer export", "error", err)
+		return false
+	}
+	if export == nil {
+		return false
+	}
+
+	file, err := buildCustomerExport(ctx, export)
+	if err == nil {
+		err = mongo.CompleteCustomerExport(ctx, export.ID, file)
+	}
+	if err != nil {
+		slog.Warn("Failed to build customer export", "export_id", export.ID.Hex(), "customer_id", export.CustomerID.Hex(), "attempts", export.Attempts, "error", err)
+		if retryErr := mongo.RetryCustomerExport(ctx, export, err, time.Now().Add(customerExportRetryDelay)); retryErr != nil {
+			slog.Error("Error rescheduling customer export", "export_id", export.ID.Hex(), "error", retryErr)
+		}
+		return true
+	}
+
+	slog.Info("Built customer export", "export_id", export.ID.Hex(), "customer_id", export.CustomerID.Hex(), "format", export.Format, "size_bytes", len(file))
+	return true
+}
+
+// buildCustomerExport gathers the customer's data into a JSON document, or a ZIP
+// archive with one JSON file per collection
+func buildCustomerExport(ctx context.Context, export *models.CustomerExport) ([]byte, error) {
+	data, err := mongo.GatherCustomerData(ctx, export.CustomerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather customer data: %w", err)
+	}
+
+	var file []byte
+	if export.Format == "zip" {
+		file, err = zipCustomerData(data)
+	} else {
+		file, err = json.MarshalIndent(data, "", "  ")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(file) > maxCustomerExportSize {
+		return nil, fmt.Errorf("export is %d bytes, over the %d byte limit", len(file), maxCustomerExportSize)
+	}
+	return file, nil
+}
+
+// zipCustomerData writes each part of the export to its own file in a ZIP archive
+func zipCustomerData(data *models.CustomerDataExport) ([]byte, error) {
+	parts := []struct {
+		name    string
+		content interface{}
+	}{
+		{"customer.json", data.Customer},
+		{"orders.json", data.Orders},
+		{"reviews.json", data.Reviews},
+		{"cart_snapshots.json", data.CartSnapshots},
+		{"abandoned_carts.json", data.AbandonedCarts},
+		{"saved_searches.json", data.SavedSearches},
+		{"loyalty_transactions.json", data.LoyaltyTransactions},
+		{"notifications.json", data.Notifications},
+		{"audit_logs.json", data.AuditLogs},
+	}
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for _, part := range parts {
+		writer, err := archive.CreateHeader(&zip.FileHeader{Name: part.name, Method: zip.Deflate, Modified: data.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
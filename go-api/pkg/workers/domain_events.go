@@ -0,0 +1,395 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// StartDomainEventWorker registers the side effects of domain events and runs them as
+// events arrive over Redis. It blocks until ctx is cancelled, so run it in its own goroutine.
+func StartDomainEventWorker(ctx context.Context, cfg config.WorkersConfig) {
+	slog.Info("Domain event worker started")
+
+	// Cached products and analytics
+	registerCacheInvalidation()
+
+	// Live order tracking on whichever instance holds the client's stream
+	events.On(events.OrderStatusChanged, notifyOrderSubscribers)
+
+	// Loyalty points; the ledger keeps instances from crediting an order twice
+	events.On(events.OrderStatusChanged, settleLoyaltyPoints)
+
+	// Customer inboxes; the dedupe key keeps instances from adding an entry twice
+	events.On(events.OrderStatusChanged, addOrderInboxNotification)
+	events.On(events.ProductUpdated, addPriceDropInboxNotifications)
+
+	// Order status emails; the dedupe key keeps instances from queueing one twice
+	events.On(events.OrderStatusChanged, emailOrderStatus)
+
+	// Price alert emails; the dedupe key keeps instances from queueing one twice
+	events.On(events.ProductUpdated, notifyPriceAlerts)
+
+	// Back-in-stock emails; the dedupe key keeps instances from queueing one twice
+	events.On(events.StockChanged, notifyBackInStock)
+
+	// Loyalty tier benefits used in cart and order totals
+	events.On(events.LoyaltyTiersUpdated, reloadLoyaltyTierRules)
+
+	// Province tax rates used in cart and order totals
+	events.On(events.TaxRulesUpdated, reloadTaxRules)
+
+	// Abandoned carts whose customer came back and ordered
+	events.On(events.OrderCreated, recoverAbandonedCarts)
+
+	// Saved search notifications; recorded matches keep instances from notifying twice
+	events.On(events.ProductCreated, matchSavedSearches)
+	events.On(events.SavedSearchMatched, emailSavedSearchMatch)
+
+	events.Listen(ctx)
+	slog.Info("Domain event worker stopped")
+}
+
+// notifyOrderSubscribers relays an order status change to this instance's order streams
+func notifyOrderSubscribers(ctx context.Context, event events.DomainEvent) error {
+	var change models.OrderStatusEvent
+	if err := event.Decode(&change); err != nil {
+		return err
+	}
+	events.Publish(events.OrderTopic(change.OrderNumber), models.OrderEventStatus, &change)
+	return nil
+}
+
+// matchSavedSearches notifies customers whose saved searches match a new product
+func matchSavedSearches(ctx context.Context, event events.DomainEvent) error {
+	var payload events.ProductCreatedEvent
+	if err := event.Decode(&payload); err != nil {
+		return err
+	}
+
+	matched, err := mongo.MatchSavedSearches(ctx, payload.Product)
+	if err != nil {
+		return err
+	}
+	if matched > 0 {
+		slog.InfoContext(ctx, "New product matched saved searches", "sku", payload.Product.SKU, "matches", matched)
+	}
+	return nil
+}
+
+// emailSavedSearchMatch queues the saved search email for a customer with email
+// notifications on. The event ID is the dedupe key, so only one instance's copy is queued.
+func emailSavedSearchMatch(ctx context.Context, event events.DomainEvent) error {
+	var match events.SavedSearchMatchedEvent
+	if err := event.Decode(&match); err != nil {
+		return err
+	}
+
+	customerID, err := bson.ObjectIDFromHex(match.CustomerID)
+	if err != nil {
+		return err
+	}
+	customer, err := mongo.GetCustomerByID(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	if !customer.Preferences.EmailNotifications {
+		return nil
+	}
+
+	_, err = mongo.QueueCustomerNotification(ctx, models.TemplateSavedSearchMatched, customer, models.ListEmail, event.Type, event.Type+":"+event.ID, map[string]string{
+		"first_name":    customer.FirstName,
+		"search_name":   match.Name,
+		"product_name":  match.Product.Name,
+		"product_price": fmt.Sprintf("$%.2f", match.Product.Price),
+	})
+	return err
+}
+
+// settleLoyaltyPoints awards points when an order is delivered and returns redeemed
+// points when it is cancelled
+func settleLoyaltyPoints(ctx context.Context, event events.DomainEvent) error {
+	var change models.OrderStatusEvent
+	if err := event.Decode(&change); err != nil {
+		return err
+	}
+
+	var points int
+	var err error
+	switch change.Status {
+	case "delivered":
+		points, err = mongo.AwardLoyaltyPoints(ctx, change.OrderNumber)
+	case "cancelled":
+		points, err = mongo.RefundLoyaltyPoints(ctx, change.OrderNumber)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if points == 0 {
+		return nil
+	}
+	slog.InfoContext(ctx, "Credited loyalty points", "order_number", change.OrderNumber, "status", change.Status, "points", points)
+
+	order, err := mongo.GetOrderByNumber(ctx, change.OrderNumber)
+	if err != nil {
+		return err
+	}
+	title := fmt.Sprintf("You earned %d loyalty points", points)
+	message := fmt.Sprintf("Order %s was delivered, adding %d points to your balance.", change.OrderNumber, points)
+	if change.Status == "cancelled" {
+		title = fmt.Sprintf("%d loyalty points returned", points)
+		message = fmt.Sprintf("The %d points redeemed on order %s were returned when it was cancelled.", points, change.OrderNumber)
+	}
+	notification := models.NewInboxNotification(order.CustomerID, models.InboxLoyalty, event.ID, title, message)
+	notification.OrderNumber = change.OrderNumber
+	return mongo.AddInboxNotification(ctx, notification)
+}
+
+// orderInboxTitles are the inbox titles for each order status a customer hears about
+var orderInboxTitles = map[string]string{
+	"processing": "Order %s is being prepared",
+	"shipped":    "Order %s has shipped",
+	"delivered":  "Order %s was delivered",
+	"cancelled":  "Order %s was cancelled",
+}
+
+// addOrderInboxNotification tells the customer who placed an order that its status changed
+func addOrderInboxNotification(ctx context.Context, event events.DomainEvent) error {
+	var change models.OrderStatusEvent
+	if err := event.Decode(&change); err != nil {
+		return err
+	}
+	title, ok := orderInboxTitles[change.Status]
+	if !ok {
+		return nil
+	}
+
+	order, err := mongo.GetOrderByNumber(ctx, change.OrderNumber)
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf("Your $%.2f order is now %s.", order.Totals.GrandTotal, change.Status)
+	notification := models.NewInboxNotification(order.CustomerID, models.InboxOrderUpdate, event.ID, fmt.Sprintf(title, change.OrderNumber), message)
+	notification.OrderNumber = change.OrderNumber
+	return mongo.AddInboxNotification(ctx, notification)
+}
+
+// orderStatusTemplates are the emails sent for each order status. An order moving to
+// processing has been paid and is confirmed.
+var orderStatusTemplates = map[string]string{
+	"processing": models.TemplateOrderConfirmed,
+	"shipped":    models.TemplateOrderShipped,
+	"delivered":  models.TemplateOrderDelivered,
+	"cancelled":  models.TemplateOrderCancelled,
+}
+
+// emailOrderStatus queues the email for an order's new status to the customer who
+// placed it. The queue retries the send on its own, apart from the order update.
+func emailOrderStatus(ctx context.Context, event events.DomainEvent) error {
+	var change models.OrderStatusEvent
+	if err := event.Decode(&change); err != nil {
+		return err
+	}
+	key, ok := orderStatusTemplates[change.Status]
+	if !ok {
+		return nil
+	}
+
+	order, err := mongo.GetOrderByNumber(ctx, change.OrderNumber)
+	if err != nil {
+		return err
+	}
+	customer, err := mongo.GetCustomerByID(ctx, order.CustomerID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !customer.Preferences.EmailNotifications {
+		return nil
+	}
+
+	vars := map[string]string{
+		"first_name":   customer.FirstName,
+		"order_number": order.OrderNumber,
+		"order_total":  fmt.Sprintf("$%.2f", order.Totals.GrandTotal),
+	}
+	if change.Status == "shipped" {
+		vars["estimated_delivery"] = "soon"
+		if change.Timeline.EstimatedDelivery != nil {
+			vars["estimated_delivery"] = change.Timeline.EstimatedDelivery.Format("Monday, January 2")
+		}
+		vars["tracking"] = formatTracking(change.Tracking)
+	}
+	_, err = mongo.QueueCustomerNotification(ctx, key, customer, models.ListEmail, event.Type, event.Type+":"+event.ID, vars)
+	return err
+}
+
+// formatTracking describes a shipment's tracking for the shipped email
+func formatTracking(tracking *models.Tracking) string {
+	if tracking == nil {
+		return "Tracking isn't available for this shipment."
+	}
+	text := fmt.Sprintf("Tracking number (%s): %s", tracking.Carrier, tracking.Number)
+	if tracking.URL != "" {
+		text += "\nTrack it at " + tracking.URL
+	}
+	return text
+}
+
+// addPriceDropInboxNotifications tells customers with a product in their saved cart
+// that its price went down
+func addPriceDropInboxNotifications(ctx context.Context, event events.DomainEvent) error {
+	var payload events.ProductUpdatedEvent
+	if err := event.Decode(&payload); err != nil {
+		return err
+	}
+	product := payload.Product
+	if payload.PreviousPrice <= product.Price || product.Status != "active" {
+		return nil
+	}
+
+	customerIDs, err := mongo.GetCustomerIDsWithCartItem(ctx, product.SKU)
+	if err != nil {
+		return err
+	}
+
+	title := fmt.Sprintf("Price drop on %s", product.Name)
+	message := fmt.Sprintf("%s in your cart is now $%.2f, down from $%.2f.", product.Name, product.Price, payload.PreviousPrice)
+	for _, customerID := range customerIDs {
+		notification := models.NewInboxNotification(customerID, models.InboxPriceDrop, event.ID, title, message)
+		notification.SKU = product.SKU
+		if err := mongo.AddInboxNotification(ctx, notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reloadLoyaltyTierRules applies the saved loyalty tier rules on this instance. They are
+// read back from MongoDB rather than the event, so a late event can't restore old rules.
+func reloadLoyaltyTierRules(ctx context.Context, event events.DomainEvent) error {
+	return mongo.LoadLoyaltyTierRules(ctx)
+}
+
+// reloadTaxRules applies the saved tax rules on this instance, read back from MongoDB
+// for the same reason
+func reloadTaxRules(ctx context.Context, event events.DomainEvent) error {
+	return mongo.LoadTaxRules(ctx)
+}
+
+// notifyBackInStock queues the back-in-stock email for each subscription when a product
+// goes from no stock to some, then removes the subscriptions
+func notifyBackInStock(ctx context.Context, event events.DomainEvent) error {
+	var payload events.StockChangedEvent
+	if err := event.Decode(&payload); err != nil {
+		return err
+	}
+	product := payload.Product
+	if payload.PreviousTotal > 0 || product.Stock.Total <= 0 || product.Status != "active" {
+		return nil
+	}
+
+	// Subscriptions made after the restock are for a later one
+	subscriptions, err := mongo.GetStockSubscriptions(ctx, product.SKU, event.At)
+	if err != nil {
+		return err
+	}
+
+	vars := map[string]string{
+		"product_name":  product.Name,
+		"product_price": fmt.Sprintf("$%.2f", product.Price),
+	}
+	notified := make([]bson.ObjectID, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		dedupeKey := event.Type + ":" + event.ID + ":" + subscription.Email
+		if err := queueBackInStock(ctx, subscription, event.Type, dedupeKey, vars); err != nil {
+			return err
+		}
+		notified = append(notified, subscription.ID)
+	}
+	if len(notified) > 0 {
+		slog.InfoContext(ctx, "Queued back-in-stock emails", "sku", product.SKU, "subscriptions", len(notified))
+	}
+	return mongo.DeleteStockSubscriptions(ctx, notified)
+}
+
+// queueBackInStock queues one subscription's back-in-stock email. A customer's
+// subscription is sent like their other notifications, so their email preference applies.
+func queueBackInStock(ctx context.Context, subscription models.StockSubscription, eventType, dedupeKey string, vars map[string]string) error {
+	if subscription.CustomerID == nil {
+		_, err := mongo.QueueTemplatedNotification(ctx, models.TemplateBackInStock, subscription.Email, eventType, dedupeKey, vars)
+		return err
+	}
+
+	customer, err := mongo.GetCustomerByID(ctx, *subscription.CustomerID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	_, err = mongo.QueueCustomerNotification(ctx, models.TemplateBackInStock, customer, models.ListEmail, eventType, dedupeKey, vars)
+	return err
+}
+
+// notifyPriceAlerts queues the price drop email for each alert the new price matches
+func notifyPriceAlerts(ctx context.Context, event events.DomainEvent) error {
+	var payload events.ProductUpdatedEvent
+	if err := event.Decode(&payload); err != nil {
+		return err
+	}
+	product := payload.Product
+	if payload.PreviousPrice <= product.Price || product.Status != "active" {
+		return nil
+	}
+
+	alerts, err := mongo.GetPriceAlertsForSKU(ctx, product.SKU)
+	if err != nil {
+		return err
+	}
+
+	for _, alert := range alerts {
+		if !alert.Matches(payload.PreviousPrice, product.Price) {
+			continue
+		}
+		customer, err := mongo.GetCustomerByID(ctx, alert.CustomerID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNotFound) {
+				continue
+			}
+			return err
+		}
+		if !customer.Preferences.EmailNotifications {
+			continue
+		}
+
+		dedupeKey := event.Type + ":" + event.ID + ":" + alert.ID.Hex()
+		notification, err := mongo.QueueCustomerNotification(ctx, models.TemplatePriceDrop, customer, models.ListEmail, event.Type, dedupeKey, map[string]string{
+			"first_name":     customer.FirstName,
+			"product_name":   product.Name,
+			"product_price":  fmt.Sprintf("$%.2f", product.Price),
+			"previous_price": fmt.Sprintf("$%.2f", payload.PreviousPrice),
+		})
+		if err != nil {
+			return err
+		}
+		// Another instance queued it first when the notification has no ID
+		if !notification.ID.IsZero() {
+			if err := mongo.RecordPriceAlertSent(ctx, alert.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
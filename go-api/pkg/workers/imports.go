@@ -0,0 +1,158 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+const (
+	// importLease hides a claimed import from other workers; recording progress renews it
+	importLease = 2 * time.Minute
+	// importBatchSize is how many rows are read at a time, and how many products are
+	// created in one transaction
+	importBatchSize = 100
+	// importBatchTimeout bounds importing one batch of rows
+	importBatchTimeout = time.Minute
+	// importRetryDelay is how long an import stopped by an error waits before resuming
+	importRetryDelay = time.Minute
+)
+
+// StartImportWorker runs queued bulk imports every ImportInterval. Imports are leased
+// from MongoDB and record their progress as they go, so an import interrupted by a
+// restart resumes from its next row on whichever instance claims it.
+// It blocks until ctx is cancelled, so run it in its own goroutine.
+func StartImportWorker(ctx context.Context, cfg config.WorkersConfig) {
+	slog.Info("Import worker started", "interval_seconds", int(cfg.ImportInterval.Seconds()))
+
+	ticker := time.NewTicker(cfg.ImportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Import worker stopped")
+			return
+		case <-ticker.C:
+			runImports(ctx)
+		}
+	}
+}
+
+// runImports runs queued imports, oldest first, until none are left
+func runImports(ctx context.Context) {
+	for ctx.Err() == nil {
+		if !runNextImport(ctx) {
+			return
+		}
+	}
+}
+
+// runNextImport claims and runs one import, reporting whether there was one to run
+func runNextImport(ctx context.Context) bool {
+	job, err := mongo.ClaimImportJob(ctx, importLease)
+	if err != nil {
+		slog.Error("Error claiming import job", "error", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+	slog.Info("Import job started", "job_id", job.ID.Hex(), "kind", job.Kind, "total", job.Total, "resuming_at", job.Processed)
+
+	err = runImport(ctx, job)
+	if err == nil {
+		err = mongo.CompleteImportJob(ctx, job)
+	}
+	switch {
+	case errors.Is(err, mongo.ErrImportLeaseLost):
+		slog.Warn("Import job was claimed by another worker", "job_id", job.ID.Hex())
+	case err != nil:
+		slog.Warn("Import job stopped", "job_id", job.ID.Hex(), "processed", job.Processed, "attempts", job.Attempts, "error", err)
+		if retryErr := mongo.RetryImportJob(ctx, job, err, time.Now().Add(importRetryDelay)); retryErr != nil {
+			slog.Error("Error rescheduling import job", "job_id", job.ID.Hex(), "error", retryErr)
+		}
+	default:
+		slog.Info("Import job finished", "job_id", job.ID.Hex(), "kind", job.Kind, "succeeded", job.Succeeded, "failed", job.Failed)
+	}
+	return true
+}
+
+// runImport imports a job's rows from the next one still to do
+func runImport(ctx context.Context, job *models.ImportJob) error {
+	for job.Processed < job.Total {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := importBatch(ctx, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importBatch imports the job's next rows
+func importBatch(ctx context.Context, job *models.ImportJob) error {
+	ctx, cancel := context.WithTimeout(ctx, importBatchTimeout)
+	defer cancel()
+
+	rows, err := mongo.GetImportJobRows(ctx, job.ID, job.Processed, importBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to load rows: %w", err)
+	}
+	if len(rows) == 0 || rows[0].Row != job.Processed {
+		return fmt.Errorf("row %d is missing", job.Processed)
+	}
+
+	switch job.Kind {
+	case models.ImportProducts:
+		return importProducts(ctx, job, rows)
+	case models.ImportOrders:
+		return importOrders(ctx, job, rows)
+	default:
+		return fmt.Errorf("unknown import kind %q", job.Kind)
+	}
+}
+
+// importProducts creates the products in rows and caches them
+func importProducts(ctx context.Context, job *models.ImportJob, rows []models.ImportJobRow) error {
+	products := make([]*models.Product, 0, len(rows))
+	for _, row := range rows {
+		var req models.CreateProductRequest
+		if err := bson.Unmarshal(row.Data, &req); err != nil {
+			// Rows before this one are created first so the job stays in row order
+			if len(products) > 0 {
+				break
+			}
+			return mongo.RecordRejectedImportRow(ctx, job, err, importLease)
+		}
+		products = append(products, req.ToProduct())
+	}
+
+	_, err := mongo.ImportProducts(ctx, job, products, importLease)
+	return err
+}
+
+// importOrders creates the orders in rows one at a time
+func importOrders(ctx context.Context, job *models.ImportJob, rows []models.ImportJobRow) error {
+	for _, row := range rows {
+		var req models.CreateOrderRequest
+		if err := bson.Unmarshal(row.Data, &req); err != nil {
+			if err := mongo.RecordRejectedImportRow(ctx, job, err, importLease); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := mongo.ImportOrder(ctx, job, req, importLease); err != nil {
+			return err
+		}
+	}
+	return nil
+}
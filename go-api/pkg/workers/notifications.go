@@ -0,0 +1,132 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/notifications"
+)
+
+const (
+	// notificationLease hides a claimed notification from other workers while it is sent
+	notificationLease = time.Minute
+	// notificationBatchSize caps how many notifications one sweep sends
+	notificationBatchSize = 50
+	// notificationMaxBackoff caps the delay between delivery attempts
+	notificationMaxBackoff = time.Hour
+)
+
+// StartNotificationWorker sends queued notifications every NotificationInterval.
+// Notifications to customers who have since unsubscribed are skipped. A failed send is
+// retried with backoff; after NotificationMaxAttempts, or a failure that retrying can't
+// fix, the notification is dead-lettered.
+// It blocks until ctx is cancelled, so run it in its own goroutine.
+func StartNotificationWorker(ctx context.Context, cfg config.WorkersConfig) {
+	slog.Info("Notification worker started", "interval_seconds", int(cfg.NotificationInterval.Seconds()), "max_attempts", cfg.NotificationMaxAttempts)
+
+	ticker := time.NewTicker(cfg.NotificationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Notification worker stopped")
+			return
+		case <-ticker.C:
+			sendNotifications(ctx, cfg)
+		}
+	}
+}
+
+// sendNotifications sends due notifications until none are left or the batch is full
+func sendNotifications(ctx context.Context, cfg config.WorkersConfig) {
+	for i := 0; i < notificationBatchSize && ctx.Err() == nil; i++ {
+		sendCtx, cancel := global.GetDefaultTimer()
+		sent := sendNextNotification(sendCtx, cfg)
+		cancel()
+		if !sent {
+			return
+		}
+	}
+}
+
+// sendNextNotification claims and sends one notification, reporting whether there was
+// one to try
+func sendNextNotification(ctx context.Context, cfg config.WorkersConfig) bool {
+	notification, err := mongo.ClaimNotification(ctx, notificationLease)
+	if err != nil {
+		slog.Error("Error claiming notification", "error", err)
+		return false
+	}
+	if notification == nil {
+		return false
+	}
+
+	skipReason, err := optOutReason(ctx, notification)
+	if err == nil && skipReason != "" {
+		slog.Info("Skipping notification", "notification_id", notification.ID.Hex(), "channel", notification.Channel, "reason", skipReason)
+		if err := mongo.SkipNotification(ctx, notification.ID, skipReason); err != nil {
+			slog.Error("Error marking notification skipped", "notification_id", notification.ID.Hex(), "error", err)
+		}
+		return true
+	}
+	if err == nil {
+		err = notifications.Deliver(ctx, notification)
+	}
+	if err != nil {
+		permanent := notifications.IsPermanent(err)
+		if permanent || notification.Attempts >= cfg.NotificationMaxAttempts {
+			slog.Error("Dead-lettering notification", "notification_id", notification.ID.Hex(), "channel", notification.Channel, "attempts", notification.Attempts, "error", err)
+		} else {
+			slog.Warn("Failed to send notification", "notification_id", notification.ID.Hex(), "channel", notification.Channel, "attempts", notification.Attempts, "error", err)
+		}
+		retryAt := time.Now().Add(notificationBackoff(notification.Attempts))
+		if retryErr := mongo.RetryNotification(ctx, notification, err, retryAt, cfg.NotificationMaxAttempts, permanent); retryErr != nil {
+			slog.Error("Error rescheduling notification", "notification_id", notification.ID.Hex(), "error", retryErr)
+		}
+		return true
+	}
+
+	if err := mongo.MarkNotificationSent(ctx, notification.ID); err != nil {
+		// The lease expires and the notification is sent again
+		slog.Error("Error marking notification sent", "notification_id", notification.ID.Hex(), "error", err)
+	}
+	return true
+}
+
+// optOutReason says why a customer's notification must not be sent: they were deleted or
+// unsubscribed from its list after it was queued. It is empty for one that can be sent.
+func optOutReason(ctx context.Context, notification *models.Notification) (string, error) {
+	if notification.CustomerID == nil {
+		return "", nil
+	}
+	customer, err := mongo.GetCustomerByID(ctx, *notification.CustomerID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNotFound) {
+			return "customer not found", nil
+		}
+		return "", err
+	}
+	if customer.AccountStatus == "deleted" {
+		return "customer is deleted", nil
+	}
+	if !customer.Preferences.Allows(notification.List) {
+		return "customer unsubscribed from " + notification.List, nil
+	}
+	return "", nil
+}
+
+// notificationBackoff doubles the retry delay with each attempt, starting at 30 seconds
+func notificationBackoff(attempts int) time.Duration {
+	backoff := 30 * time.Second
+	for i := 1; i < attempts && backoff < notificationMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, notificationMaxBackoff)
+}
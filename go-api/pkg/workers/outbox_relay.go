@@ -0,0 +1,127 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+)
+
+const (
+	// outboxLease hides a claimed event from other relays while it is being delivered
+	outboxLease = 30 * time.Second
+	// outboxBatchSize caps how many events one sweep delivers
+	outboxBatchSize = 100
+	// outboxMaxBackoff caps the delay between delivery attempts
+	outboxMaxBackoff = 10 * time.Minute
+)
+
+// StartOutboxRelayWorker delivers outbox events every OutboxRelayInterval: each is
+// published over Redis and, when EventsWebhook is set, posted to the webhook. An event
+// is marked delivered only once both succeed, so delivery is at least once.
+// It blocks until ctx is cancelled, so run it in its own goroutine.
+func StartOutboxRelayWorker(ctx context.Context, cfg config.WorkersConfig) {
+	slog.Info("Outbox relay worker started", "interval_seconds", int(cfg.OutboxRelayInterval.Seconds()), "webhook", cfg.EventsWebhook != "")
+
+	ticker := time.NewTicker(cfg.OutboxRelayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Outbox relay worker stopped")
+			return
+		case <-ticker.C:
+			relayOutboxEvents(ctx, cfg)
+		}
+	}
+}
+
+// relayOutboxEvents delivers due events, oldest first, until none are left or the batch is full
+func relayOutboxEvents(ctx context.Context, cfg config.WorkersConfig) {
+	for i := 0; i < outboxBatchSize && ctx.Err() == nil; i++ {
+		relayCtx, cancel := global.GetDefaultTimer()
+		delivered := relayNextOutboxEvent(relayCtx, cfg)
+		cancel()
+		if !delivered {
+			return
+		}
+	}
+}
+
+// relayNextOutboxEvent claims and delivers one event, reporting whether there was one to try
+func relayNextOutboxEvent(ctx context.Context, cfg config.WorkersConfig) bool {
+	event, err := mongo.ClaimOutboxEvent(ctx, outboxLease)
+	if err != nil {
+		slog.Error("Error claiming outbox event", "error", err)
+		return false
+	}
+	if event == nil {
+		return false
+	}
+
+	if err := deliverOutboxEvent(ctx, event, cfg.EventsWebhook); err != nil {
+		retryAt := time.Now().Add(outboxBackoff(event.Attempts))
+		if event.Attempts >= cfg.OutboxMaxAttempts {
+			slog.Error("Giving up on outbox event", "event_id", event.EventID, "type", event.Type, "attempts", event.Attempts, "error", err)
+		} else {
+			slog.Warn("Failed to deliver outbox event", "event_id", event.EventID, "type", event.Type, "attempts", event.Attempts, "error", err)
+		}
+		if retryErr := mongo.RetryOutboxEvent(ctx, event, err, retryAt, cfg.OutboxMaxAttempts); retryErr != nil {
+			slog.Error("Error rescheduling outbox event", "event_id", event.EventID, "error", retryErr)
+		}
+		return true
+	}
+
+	if err := mongo.MarkOutboxEventDelivered(ctx, event.ID); err != nil {
+		// The lease expires and the event is delivered again, which subscribers tolerate
+		slog.Error("Error marking outbox event delivered", "event_id", event.EventID, "error", err)
+	}
+	return true
+}
+
+// deliverOutboxEvent publishes the event to subscribers and posts it to the webhook
+func deliverOutboxEvent(ctx context.Context, event *models.OutboxEvent, webhookURL string) error {
+	if err := redis.PublishDomainEvent(ctx, []byte(event.Payload)); err != nil {
+		return err
+	}
+	if webhookURL == "" {
+		return nil
+	}
+
+	var envelope events.DomainEvent
+	if err := json.Unmarshal([]byte(event.Payload), &envelope); err != nil {
+		return err
+	}
+	return postWebhook(ctx, webhookURL, DomainEventWebhook{
+		Event: envelope.Type,
+		ID:    envelope.ID,
+		At:    envelope.At,
+		Data:  envelope.Data,
+	})
+}
+
+// outboxBackoff doubles the retry delay with each attempt, starting at 5 seconds
+func outboxBackoff(attempts int) time.Duration {
+	backoff := 5 * time.Second
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, outboxMaxBackoff)
+}
+
+// DomainEventWebhook is the payload posted to EVENTS_WEBHOOK_URL for each domain event.
+// Delivery is at least once, so receivers should deduplicate on ID.
+type DomainEventWebhook struct {
+	Event string          `json:"event"`
+	ID    string          `json:"id"`
+	At    time.Time       `json:"at"`
+	Data  json.RawMessage `json:"data"`
+}
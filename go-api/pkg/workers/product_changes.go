@@ -0,0 +1,91 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+)
+
+// productChangesRetry is how long the watcher waits before reopening a failed stream
+const productChangesRetry = 30 * time.Second
+
+// StartProductChangesWorker follows the products collection's change stream and
+// refreshes or removes the matching Redis cache entries, so edits made outside the API
+// (in Atlas or by another service) don't leave stale products cached. It resumes from
+// the last handled change after a restart. It blocks until ctx is cancelled, so run it
+// in its own goroutine.
+func StartProductChangesWorker(ctx context.Context, cfg config.WorkersConfig) {
+	slog.Info("Product change stream worker started")
+
+	for {
+		err := watchProductChanges(ctx)
+		switch {
+		case ctx.Err() != nil:
+			slog.Info("Product change stream worker stopped")
+			return
+		case errors.Is(err, mongo.ErrChangeStreamsUnsupported):
+			slog.Warn("Product change stream worker disabled; cached products only refresh on API writes", "error", err)
+			return
+		case errors.Is(err, mongo.ErrChangeStreamHistoryLost):
+			// Changes in the gap can't be replayed; entries written then expire with their TTL
+			slog.Warn("Product change stream fell too far behind, restarting from now")
+			clearCtx, cancel := global.GetDefaultTimer()
+			if err := redis.ClearProductChangesToken(clearCtx); err != nil {
+				slog.Error("Error clearing product change stream token", "error", err)
+			}
+			cancel()
+			continue
+		case err != nil:
+			slog.Error("Product change stream failed, retrying", "error", err, "retry_seconds", int(productChangesRetry.Seconds()))
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("Product change stream worker stopped")
+			return
+		case <-time.After(productChangesRetry):
+		}
+	}
+}
+
+// watchProductChanges runs the change stream from the saved resume token until it fails
+func watchProductChanges(ctx context.Context) error {
+	tokenCtx, cancel := global.GetDefaultTimer()
+	token, err := redis.GetProductChangesToken(tokenCtx)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	return mongo.WatchProducts(ctx, token, func(change mongo.ProductChange) error {
+		changeCtx, cancel := global.GetDefaultTimer()
+		defer cancel()
+
+		if err := applyProductChange(changeCtx, change); err != nil {
+			// Skip the change rather than stall the stream; the entry expires with its TTL
+			slog.Error("Error applying product change to cache", "operation", change.Operation, "product_id", change.ID.Hex(), "error", err)
+		}
+		return redis.SaveProductChangesToken(changeCtx, change.ResumeToken)
+	})
+}
+
+// applyProductChange refreshes a changed product's cache entry or removes a deleted one
+func applyProductChange(ctx context.Context, change mongo.ProductChange) error {
+	if change.Product != nil {
+		return redis.RefreshCachedProduct(ctx, change.Product)
+	}
+
+	// Deletes only carry the document ID; the cache maps it back to the SKU
+	sku, err := redis.GetCachedProductSKU(ctx, change.ID.Hex())
+	if err != nil || sku == "" {
+		return err
+	}
+	return redis.RemoveProductFromCache(ctx, &models.Product{ID: change.ID, SKU: sku})
+}
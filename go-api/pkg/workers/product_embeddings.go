@@ -0,0 +1,169 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/ai"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// embeddingBatchSize is the number of products embedded per AI request
+const embeddingBatchSize = 50
+
+// EmbeddingJobStatus reports the progress of the product embedding job
+type EmbeddingJobStatus struct {
+	Running    bool       `json:"running"`
+	Reembed    bool       `json:"reembed"`
+	Embedded   int        `json:"embedded"`
+	Skipped    int        `json:"skipped"`
+	Failed     int        `json:"failed"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+var (
+	embeddingJobMu     sync.Mutex
+	embeddingJobStatus EmbeddingJobStatus
+)
+
+// StartProductEmbeddingJob launches the embedding job in the background. Products whose
+// text has not changed since they were last embedded are skipped unless reembed is set.
+// Only one run is allowed at a time.
+func StartProductEmbeddingJob(reembed bool) (EmbeddingJobStatus, error) {
+	if !ai.IsEnabled() {
+		return EmbeddingJobStatus{}, errors.New("AI service is not enabled")
+	}
+
+	embeddingJobMu.Lock()
+	defer embeddingJobMu.Unlock()
+
+	if embeddingJobStatus.Running {
+		return embeddingJobStatus, errors.New("embedding job already running")
+	}
+
+	now := time.Now()
+	embeddingJobStatus = EmbeddingJobStatus{
+		Running:   true,
+		Reembed:   reembed,
+		StartedAt: &now,
+	}
+
+	go runProductEmbeddingJob(reembed)
+
+	return embeddingJobStatus, nil
+}
+
+// GetProductEmbeddingJobStatus returns a snapshot of the current or last job run
+func GetProductEmbeddingJobStatus() EmbeddingJobStatus {
+	embeddingJobMu.Lock()
+	defer embeddingJobMu.Unlock()
+
+	return embeddingJobStatus
+}
+
+// runProductEmbeddingJob walks active products in batches and stores a vector for each
+func runProductEmbeddingJob(reembed bool) {
+	slog.Info("Product embedding job started", "reembed", reembed)
+
+	var lastID bson.ObjectID
+	for {
+		ctx, cancel := global.GetDefaultTimer()
+		products, err := mongo.GetActiveProductsAfter(ctx, lastID, embeddingBatchSize)
+		cancel()
+		if err != nil {
+			recordEmbeddingProgress(0, 0, 0, err)
+			break
+		}
+		if len(products) == 0 {
+			break
+		}
+		lastID = products[len(products)-1].ID
+
+		pending, skipped, err := productsNeedingEmbedding(products, reembed)
+		if err != nil {
+			recordEmbeddingProgress(0, 0, len(products), err)
+			continue
+		}
+		if len(pending) == 0 {
+			recordEmbeddingProgress(0, skipped, 0, nil)
+			continue
+		}
+
+		aiCtx, aiCancel := context.WithTimeout(context.Background(), 60*time.Second)
+		embeddings, err := ai.EmbedProducts(aiCtx, pending)
+		aiCancel()
+		if err != nil {
+			recordEmbeddingProgress(0, skipped, len(pending), err)
+			continue
+		}
+
+		ctx, cancel = global.GetDefaultTimer()
+		err = mongo.UpsertProductEmbeddings(ctx, embeddings)
+		cancel()
+		if err != nil {
+			recordEmbeddingProgress(0, skipped, len(pending), err)
+			continue
+		}
+		recordEmbeddingProgress(len(embeddings), skipped, 0, nil)
+	}
+
+	embeddingJobMu.Lock()
+	now := time.Now()
+	embeddingJobStatus.Running = false
+	embeddingJobStatus.FinishedAt = &now
+	slog.Info("Product embedding job finished",
+		"embedded", embeddingJobStatus.Embedded, "skipped", embeddingJobStatus.Skipped, "failed", embeddingJobStatus.Failed)
+	embeddingJobMu.Unlock()
+}
+
+// productsNeedingEmbedding filters out products whose stored embedding matches their current text
+func productsNeedingEmbedding(products []models.Product, reembed bool) ([]models.Product, int, error) {
+	if reembed {
+		return products, 0, nil
+	}
+
+	skus := make([]string, len(products))
+	for i := range products {
+		skus[i] = products[i].SKU
+	}
+
+	ctx, cancel := global.GetDefaultTimer()
+	defer cancel()
+
+	hashes, err := mongo.GetProductEmbeddingHashes(ctx, skus)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pending := make([]models.Product, 0, len(products))
+	for _, product := range products {
+		if hashes[product.SKU] == product.EmbeddingTextHash() {
+			continue
+		}
+		pending = append(pending, product)
+	}
+
+	return pending, len(products) - len(pending), nil
+}
+
+// recordEmbeddingProgress adds batch results to the job status
+func recordEmbeddingProgress(embedded, skipped, failed int, err error) {
+	embeddingJobMu.Lock()
+	defer embeddingJobMu.Unlock()
+
+	embeddingJobStatus.Embedded += embedded
+	embeddingJobStatus.Skipped += skipped
+	embeddingJobStatus.Failed += failed
+	if err != nil {
+		slog.Error("Product embedding job error", "error", err)
+		embeddingJobStatus.LastError = err.Error()
+	}
+}
@@ -0,0 +1,253 @@
+package workers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/ai"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+const (
+	// reportArchiveLease hides a claimed archive from other workers while it is built
+	reportArchiveLease = 5 * time.Minute
+	// reportArchiveTimeout bounds generating the reports and storing one archive
+	reportArchiveTimeout = 3 * time.Minute
+	// reportArchiveRetryDelay is how long a failed archive waits before its next attempt
+	reportArchiveRetryDelay = time.Minute
+	// reportArchiveTopProducts is how many best sellers the top products report lists
+	reportArchiveTopProducts = 50
+	// maxReportArchiveSize keeps the stored file under MongoDB's 16 MB document limit
+	maxReportArchiveSize = 15 << 20
+)
+
+// StartReportArchiveWorker builds queued report archives every ReportArchiveInterval.
+// Archives are leased from MongoDB, so each is built by one instance and picked up again
+// if that instance stops mid-way.
+// It blocks until ctx is cancelled, so run it in its own goroutine.
+func StartReportArchiveWorker(ctx context.Context, cfg config.WorkersConfig) {
+	slog.Info("Report archive worker started", "interval_seconds", int(cfg.ReportArchiveInterval.Seconds()))
+
+	ticker := time.NewTicker(cfg.ReportArchiveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Report archive worker stopped")
+			return
+		case <-ticker.C:
+			buildReportArchives(ctx)
+		}
+	}
+}
+
+// buildReportArchives builds queued archives, oldest first, until none are left
+func buildReportArchives(ctx context.Context) {
+	for ctx.Err() == nil {
+		if !buildNextReportArchive(ctx) {
+			return
+		}
+	}
+}
+
+// buildNextReportArchive claims and builds one archive, reporting whether there was one
+// to try
+func buildNextReportArchive(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, reportArchiveTimeout)
+	defer cancel()
+
+	archive, err := mongo.ClaimReportArchive(ctx, reportArchiveLease)
+	if err != nil {
+		slog.Error("Error claiming report archive", "error", err)
+		return false
+	}
+	if archive == nil {
+		return false
+	}
+
+	file, err := buildReportArchive(ctx, archive)
+	if err == nil {
+		err = mongo.CompleteReportArchive(ctx, archive.ID, file)
+	}
+	if err != nil {
+		slog.Warn("Failed to build report archive", "archive_id", archive.ID.Hex(), "start_date", archive.StartDate, "end_date", archive.EndDate, "attempts", archive.Attempts, "error", err)
+		if retryErr := mongo.RetryReportArchive(ctx, archive, err, time.Now().Add(reportArchiveRetryDelay)); retryErr != nil {
+			slog.Error("Error rescheduling report archive", "archive_id", archive.ID.Hex(), "error", retryErr)
+		}
+		return true
+	}
+
+	slog.Info("Built report archive", "archive_id", archive.ID.Hex(), "start_date", archive.StartDate, "end_date", archive.EndDate, "size_bytes", len(file))
+	return true
+}
+
+// archivedReport is one report in an archive: its CSV file and AI summary
+type archivedReport struct {
+	title  string
+	file   string
+	header []string
+	rows   [][]string
+	report *ai.AIReportResponse
+}
+
+// buildReportArchive generates the sales, top product and inventory reports for the
+// archive's range and zips each as CSV, with their AI summaries in summary.md. A report
+// whose data can't be loaded fails the archive; a failed AI summary is noted instead.
+func buildReportArchive(ctx context.Context, archive *models.ReportArchive) ([]byte, error) {
+	sales, err := ai.GenerateSalesReport(ctx, archive.StartDate, archive.EndDate, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sales: %w", err)
+	}
+	topProducts, err := ai.GenerateTopProductsAnalysis(ctx, reportArchiveTopProducts, "revenue", archive.StartDate, archive.EndDate, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load top products: %w", err)
+	}
+	inventory, err := ai.GenerateInventoryReport(ctx, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load inventory: %w", err)
+	}
+
+	reports := []archivedReport{
+		salesCSV(sales),
+		topProductsCSV(topProducts),
+		inventoryCSV(inventory),
+	}
+
+	generatedAt := time.Now().UTC()
+	var buffer bytes.Buffer
+	zipFile := zip.NewWriter(&buffer)
+	for _, report := range reports {
+		writer, err := zipFile.CreateHeader(&zip.FileHeader{Name: report.file, Method: zip.Deflate, Modified: generatedAt})
+		if err != nil {
+			return nil, err
+		}
+		csvWriter := csv.NewWriter(writer)
+		if err := csvWriter.Write(report.header); err != nil {
+			return nil, err
+		}
+		if err := csvWriter.WriteAll(report.rows); err != nil {
+			return nil, err
+		}
+	}
+
+	writer, err := zipFile.CreateHeader(&zip.FileHeader{Name: "summary.md", Method: zip.Deflate, Modified: generatedAt})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write([]byte(reportArchiveSummary(archive, reports, generatedAt))); err != nil {
+		return nil, err
+	}
+	if err := zipFile.Close(); err != nil {
+		return nil, err
+	}
+
+	if buffer.Len() > maxReportArchiveSize {
+		return nil, fmt.Errorf("archive is %d bytes, over the %d byte limit", buffer.Len(), maxReportArchiveSize)
+	}
+	return buffer.Bytes(), nil
+}
+
+// salesCSV lists the range's completed orders by day
+func salesCSV(report *ai.AIReportResponse) archivedReport {
+	sales, _ := report.Data.RawData.([]mongo.SalesData)
+	rows := make([][]string, 0, len(sales))
+	for _, day := range sales {
+		rows = append(rows, []string{
+			day.Date,
+			strconv.Itoa(day.TotalOrders),
+			formatMoney(day.TotalRevenue),
+			formatMoney(day.AvgOrderValue),
+			strconv.Itoa(day.UniqueCustomers),
+		})
+	}
+	return archivedReport{
+		title:  "Sales",
+		file:   "sales.csv",
+		header: []string{"date", "total_orders", "total_revenue", "avg_order_value", "unique_customers"},
+		rows:   rows,
+		report: report,
+	}
+}
+
+// topProductsCSV lists the range's best sellers by revenue
+func topProductsCSV(report *ai.AIReportResponse) archivedReport {
+	products, _ := report.Data.RawData.([]mongo.TopProduct)
+	rows := make([][]string, 0, len(products))
+	for _, product := range products {
+		rows = append(rows, []string{
+			product.SKU,
+			product.ProductName,
+			strconv.Itoa(product.TotalSold),
+			formatMoney(product.TotalRevenue),
+			formatMoney(product.AvgPrice),
+			strconv.Itoa(product.OrderCount),
+		})
+	}
+	return archivedReport{
+		title:  "Top products",
+		file:   "top_products.csv",
+		header: []string{"sku", "name", "total_sold", "total_revenue", "avg_price", "order_count"},
+		rows:   rows,
+		report: report,
+	}
+}
+
+// inventoryCSV lists every active product's stock when the archive was built
+func inventoryCSV(report *ai.AIReportResponse) archivedReport {
+	items, _ := report.Data.RawData.([]mongo.InventoryStatus)
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, []string{
+			item.SKU,
+			item.ProductName,
+			item.Category,
+			strconv.Itoa(item.CurrentStock),
+			strconv.Itoa(item.ReorderLevel),
+			item.StockStatus,
+			item.LastUpdated.UTC().Format(time.RFC3339),
+		})
+	}
+	return archivedReport{
+		title:  "Inventory",
+		file:   "inventory.csv",
+		header: []string{"sku", "name", "category", "current_stock", "reorder_level", "stock_status", "last_updated"},
+		rows:   rows,
+		report: report,
+	}
+}
+
+// reportArchiveSummary writes each report's AI summary as Markdown
+func reportArchiveSummary(archive *models.ReportArchive, reports []archivedReport, generatedAt time.Time) string {
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "# Reports for %s to %s\n\n", archive.StartDate, archive.EndDate)
+	fmt.Fprintf(&summary, "Generated %s. Inventory is as of that time.\n", generatedAt.Format(time.RFC3339))
+
+	for _, report := range reports {
+		fmt.Fprintf(&summary, "\n## %s\n\n", report.title)
+		switch {
+		case report.report.Data.AIInsights != "":
+			summary.WriteString(strings.TrimSpace(report.report.Data.AIInsights))
+		case report.report.Data.Error != "":
+			summary.WriteString("AI insights are unavailable: " + report.report.Data.Error)
+		default:
+			summary.WriteString("AI insights are unavailable.")
+		}
+		fmt.Fprintf(&summary, "\n\nData: %s (%d rows)\n", report.file, len(report.rows))
+	}
+	return summary.String()
+}
+
+// formatMoney writes an amount with two decimals
+func formatMoney(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
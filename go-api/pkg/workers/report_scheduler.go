@@ -0,0 +1,210 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"julianmorley.ca/con-plar/prog2270/pkg/ai"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/models"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// reportRunTimeout bounds how long a single scheduled report may take to generate
+const reportRunTimeout = 2 * time.Minute
+
+// StartReportSchedulerWorker checks every ReportSchedulerScan for AI report schedules
+// that are due, generates each report and stores it in the reports collection. It
+// blocks until ctx is cancelled, so run it in its own goroutine.
+func StartReportSchedulerWorker(ctx context.Context, cfg config.WorkersConfig) {
+	slog.Info("Report scheduler worker started", "scan_seconds", int(cfg.ReportSchedulerScan.Seconds()))
+
+	ticker := time.NewTicker(cfg.ReportSchedulerScan)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Report scheduler worker stopped")
+			return
+		case <-ticker.C:
+			runDueReports(ctx)
+		}
+	}
+}
+
+// runDueReports generates every report whose schedule is due
+func runDueReports(ctx context.Context) {
+	scanCtx, cancel := global.GetDefaultTimer()
+	schedules, err := mongo.GetDueReportSchedules(scanCtx, time.Now())
+	cancel()
+	if err != nil {
+		slog.Error("Error listing due report schedules", "error", err)
+		return
+	}
+
+	for i := range schedules {
+		if ctx.Err() != nil {
+			return
+		}
+		runScheduledReport(ctx, &schedules[i])
+	}
+}
+
+// runScheduledReport claims one due run, generates the report and stores it
+func runScheduledReport(ctx context.Context, schedule *models.ReportSchedule) {
+	ranAt := time.Now()
+
+	claimCtx, cancel := global.GetDefaultTimer()
+	claimed, err := mongo.ClaimReportScheduleRun(claimCtx, schedule, ranAt)
+	cancel()
+	if err != nil {
+		slog.Error("Error claiming report schedule", "schedule_id", schedule.ID.Hex(), "error", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, reportRunTimeout)
+	defer cancel()
+
+	report, err := generateScheduledReport(runCtx, schedule, ranAt)
+	if err == nil {
+		_, err = mongo.SaveStoredReport(runCtx, newStoredReport(schedule, report))
+	}
+
+	lastError := ""
+	if err != nil {
+		lastError = err.Error()
+		slog.Error("Error generating scheduled report", "report_type", schedule.ReportType, "schedule_id", schedule.ID.Hex(), "error", err)
+	} else if report.Data.Error != "" {
+		lastError = report.Data.Error
+	}
+	if err := mongo.SetReportScheduleError(runCtx, schedule.ID, lastError); err != nil {
+		slog.Error("Error updating report schedule", "schedule_id", schedule.ID.Hex(), "error", err)
+	}
+}
+
+// generateScheduledReport runs the report named by the schedule. Sales and product
+// reports cover the period since the previous run unless params set "days".
+func generateScheduledReport(ctx context.Context, schedule *models.ReportSchedule, ranAt time.Time) (*ai.AIReportResponse, error) {
+	params := schedule.Params
+
+	days := int((schedule.Interval() + 24*time.Hour - 1) / (24 * time.Hour))
+	if value, ok := params["days"]; ok {
+		days, _ = strconv.Atoi(value)
+	}
+	startDate := ranAt.AddDate(0, 0, -days).Format("2006-01-02")
+	endDate := ranAt.Format("2006-01-02")
+
+	switch schedule.ReportType {
+	case "sales-report":
+		return ai.GenerateSalesReport(ctx, startDate, endDate, false)
+	case "customer-insights":
+		return ai.GenerateCustomerInsights(ctx, false)
+	case "inventory-report":
+		return ai.GenerateInventoryReport(ctx, params["alertsOnly"] == "true", false)
+	case "product-analysis":
+		limit := paramInt(params, "limit", 10)
+		sortBy := params["sortBy"]
+		if sortBy == "" {
+			sortBy = "revenue"
+		}
+		return ai.GenerateTopProductsAnalysis(ctx, limit, sortBy, startDate, endDate, false)
+	case "forecast":
+		groupBy := params["group_by"]
+		if groupBy == "" {
+			groupBy = "sku"
+		}
+		return ai.GenerateDemandForecast(ctx, groupBy, paramInt(params, "horizon", 30), paramInt(params, "limit", 20), false)
+	default:
+		return nil, fmt.Errorf("unknown report type %q", schedule.ReportType)
+	}
+}
+
+// ValidateReportScheduleParams checks the params a schedule passes to its report,
+// using the same limits as the matching /api/analytics/ai endpoint
+func ValidateReportScheduleParams(reportType string, params map[string]string) error {
+	allowed := map[string][]string{
+		"sales-report":      {"days"},
+		"customer-insights": {},
+		"inventory-report":  {"alertsOnly"},
+		"product-analysis":  {"days", "limit", "sortBy"},
+		"forecast":          {"group_by", "horizon", "limit"},
+	}[reportType]
+
+	for key, value := range params {
+		known := false
+		for _, name := range allowed {
+			if key == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("param %q is not supported by %s reports", key, reportType)
+		}
+
+		switch key {
+		case "days":
+			if days, err := strconv.Atoi(value); err != nil || days < 1 || days > 366 {
+				return errors.New("days must be a number between 1 and 366")
+			}
+		case "limit":
+			if limit, err := strconv.Atoi(value); err != nil || limit < 1 || limit > 100 {
+				return errors.New("limit must be a number between 1 and 100")
+			}
+		case "alertsOnly":
+			if value != "true" && value != "false" {
+				return errors.New("alertsOnly must be true or false")
+			}
+		case "sortBy":
+			if value != "revenue" && value != "quantity" {
+				return errors.New("sortBy must be either 'revenue' or 'quantity'")
+			}
+		case "group_by":
+			if value != "sku" && value != "category" {
+				return errors.New("group_by must be either 'sku' or 'category'")
+			}
+		case "horizon":
+			if value != "30" && value != "90" {
+				return errors.New("horizon must be 30 or 90 days")
+			}
+		}
+	}
+
+	return nil
+}
+
+// newStoredReport copies a generated report into its stored form
+func newStoredReport(schedule *models.ReportSchedule, report *ai.AIReportResponse) *models.StoredReport {
+	scheduleID := schedule.ID
+	return &models.StoredReport{
+		ScheduleID: &scheduleID,
+		ReportType: schedule.ReportType,
+		Params:     schedule.Params,
+		Status:     report.Status,
+		AIEnabled:  report.AIEnabled,
+		Data: models.StoredReportData{
+			RawData:    report.Data.RawData,
+			AIInsights: report.Data.AIInsights,
+			Summary:    report.Data.Summary,
+			Error:      report.Data.Error,
+		},
+		GeneratedAt: report.GeneratedAt,
+	}
+}
+
+// paramInt reads an integer schedule param, falling back to defaultValue
+func paramInt(params map[string]string, key string, defaultValue int) int {
+	if value, err := strconv.Atoi(params[key]); err == nil {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,135 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/ai"
+	"julianmorley.ca/con-plar/prog2270/pkg/global"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+)
+
+// sentimentBatchSize is the number of reviews sent to the AI service per request
+const sentimentBatchSize = 10
+
+// SentimentJobStatus reports the progress of the review sentiment job
+type SentimentJobStatus struct {
+	Running    bool       `json:"running"`
+	Reanalyze  bool       `json:"reanalyze"`
+	Processed  int        `json:"processed"`
+	Failed     int        `json:"failed"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+var (
+	sentimentJobMu     sync.Mutex
+	sentimentJobStatus SentimentJobStatus
+)
+
+// StartReviewSentimentJob launches the sentiment job in the background.
+// Only one run is allowed at a time.
+func StartReviewSentimentJob(reanalyze bool) (SentimentJobStatus, error) {
+	if !ai.IsEnabled() {
+		return SentimentJobStatus{}, errors.New("AI service is not enabled")
+	}
+
+	sentimentJobMu.Lock()
+	defer sentimentJobMu.Unlock()
+
+	if sentimentJobStatus.Running {
+		return sentimentJobStatus, errors.New("sentiment job already running")
+	}
+
+	now := time.Now()
+	sentimentJobStatus = SentimentJobStatus{
+		Running:   true,
+		Reanalyze: reanalyze,
+		StartedAt: &now,
+	}
+
+	go runReviewSentimentJob(reanalyze)
+
+	return sentimentJobStatus, nil
+}
+
+// GetReviewSentimentJobStatus returns a snapshot of the current or last job run
+func GetReviewSentimentJobStatus() SentimentJobStatus {
+	sentimentJobMu.Lock()
+	defer sentimentJobMu.Unlock()
+
+	return sentimentJobStatus
+}
+
+// runReviewSentimentJob walks the reviews collection in batches and stores a sentiment on each
+func runReviewSentimentJob(reanalyze bool) {
+	slog.Info("Review sentiment job started", "reanalyze", reanalyze)
+
+	var lastID bson.ObjectID
+	for {
+		ctx, cancel := global.GetDefaultTimer()
+		reviews, err := mongo.GetReviewsForSentiment(ctx, lastID, reanalyze, sentimentBatchSize)
+		cancel()
+		if err != nil {
+			recordSentimentProgress(0, 0, err)
+			break
+		}
+		if len(reviews) == 0 {
+			break
+		}
+		lastID = reviews[len(reviews)-1].ID
+
+		aiCtx, aiCancel := context.WithTimeout(context.Background(), 60*time.Second)
+		sentiments, err := ai.AnalyzeReviewSentiment(aiCtx, reviews)
+		aiCancel()
+		if err != nil {
+			recordSentimentProgress(0, len(reviews), err)
+			continue
+		}
+
+		processed, failed := 0, 0
+		for _, review := range reviews {
+			sentiment, ok := sentiments[review.ID]
+			if !ok {
+				failed++
+				continue
+			}
+
+			ctx, cancel := global.GetDefaultTimer()
+			err := mongo.SetReviewSentiment(ctx, review.ID, sentiment)
+			cancel()
+			if err != nil {
+				slog.Error("Error saving sentiment", "review_id", review.ID.Hex(), "error", err)
+				failed++
+				continue
+			}
+			processed++
+		}
+		recordSentimentProgress(processed, failed, nil)
+	}
+
+	sentimentJobMu.Lock()
+	now := time.Now()
+	sentimentJobStatus.Running = false
+	sentimentJobStatus.FinishedAt = &now
+	slog.Info("Review sentiment job finished", "processed", sentimentJobStatus.Processed, "failed", sentimentJobStatus.Failed)
+	sentimentJobMu.Unlock()
+}
+
+// recordSentimentProgress adds batch results to the job status
+func recordSentimentProgress(processed, failed int, err error) {
+	sentimentJobMu.Lock()
+	defer sentimentJobMu.Unlock()
+
+	sentimentJobStatus.Processed += processed
+	sentimentJobStatus.Failed += failed
+	if err != nil {
+		slog.Error("Review sentiment job error", "error", err)
+		sentimentJobStatus.LastError = err.Error()
+	}
+}
@@ -0,0 +1,181 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"julianmorley.ca/con-plar/prog2270/pkg/config"
+	"julianmorley.ca/con-plar/prog2270/pkg/events"
+	"julianmorley.ca/con-plar/prog2270/pkg/mongo"
+	"julianmorley.ca/con-plar/prog2270/pkg/redis"
+	"julianmorley.ca/con-plar/prog2270/pkg/scheduler"
+)
+
+// lowStockScanLimit caps how many products one low-stock event lists
+const lowStockScanLimit = 100
+
+// RegisterScheduledTasks adds the workers' recurring tasks to the default scheduler
+func RegisterScheduledTasks(workersCfg config.WorkersConfig, cfg config.SchedulerConfig) error {
+	tasks := []scheduler.Task{
+		{
+			Name:    "cache-warm",
+			Spec:    cfg.CacheWarm.Schedule,
+			Enabled: cfg.CacheWarm.Enabled,
+			Timeout: 2 * time.Minute,
+			Run:     warmProductCache(cfg.CacheWarmLimit),
+		},
+		{
+			Name:    "cart-abandonment",
+			Spec:    cfg.CartAbandonment.Schedule,
+			Enabled: cfg.CartAbandonment.Enabled,
+			Timeout: 2 * time.Minute,
+			Run:     sweepAbandonedCarts(workersCfg),
+		},
+		{
+			Name:    "low-stock-scan",
+			Spec:    cfg.LowStockScan.Schedule,
+			Enabled: cfg.LowStockScan.Enabled,
+			Timeout: time.Minute,
+			Run:     scanLowStock(cfg.LowStockThreshold),
+		},
+		{
+			Name:    "admin-digest",
+			Spec:    cfg.AdminDigest.Schedule,
+			Enabled: cfg.AdminDigest.Enabled && len(cfg.AdminDigestEmails) > 0,
+			Timeout: 5 * time.Minute,
+			Run:     sendAdminDigest(cfg.AdminDigestEmails, cfg.LowStockThreshold),
+		},
+		{
+			Name:       "suggest-index",
+			Spec:       cfg.SuggestIndex.Schedule,
+			Enabled:    cfg.SuggestIndex.Enabled,
+			Timeout:    5 * time.Minute,
+			RunAtStart: true,
+			Run:        indexProductSuggestions,
+		},
+	}
+
+	for _, task := range tasks {
+		if err := scheduler.Register(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// suggestIndexBatchSize is how many products the suggestion rebuild reads at a time
+const suggestIndexBatchSize = 200
+
+// indexProductSuggestions adds every active product to the autocomplete index. Product
+// writes keep the index current, so this fills it on a fresh Redis and repairs drift.
+func indexProductSuggestions(ctx context.Context) error {
+	var lastID bson.ObjectID
+	indexed := 0
+	for {
+		products, err := mongo.GetActiveProductsAfter(ctx, lastID, suggestIndexBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to load products: %w", err)
+		}
+		if len(products) == 0 {
+			break
+		}
+		lastID = products[len(products)-1].ID
+
+		for i := range products {
+			if err := redis.IndexProductSuggestions(ctx, &products[i]); err != nil {
+				return err
+			}
+		}
+		indexed += len(products)
+	}
+	slog.Info("Indexed product suggestions", "products", indexed)
+	return nil
+}
+
+// warmProductCache returns the task that loads the best-selling products into Redis so
+// the busiest product pages are served from cache
+func warmProductCache(limit int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := WarmProductCache(ctx, limit)
+		return err
+	}
+}
+
+// WarmProductCache caches the limit best-selling products, or every active product when
+// limit is 0, and returns how many were cached. It caches nothing when products aren't
+// cached.
+func WarmProductCache(ctx context.Context, limit int) (int, error) {
+	if !redis.Products.Enabled() {
+		return 0, nil
+	}
+	if limit == 0 {
+		return warmAllProducts(ctx)
+	}
+
+	topProducts, err := mongo.GetTopProductsByRevenue(ctx, limit, "quantity", "", "")
+	if err != nil {
+		return 0, fmt.Errorf("failed to load top products: %w", err)
+	}
+
+	skus := make([]string, 0, len(topProducts))
+	for _, product := range topProducts {
+		skus = append(skus, product.SKU)
+	}
+	products, err := mongo.GetProductsBySKUs(ctx, skus)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load products: %w", err)
+	}
+
+	for _, product := range products {
+		if err := redis.CacheSingleProduct(ctx, product); err != nil {
+			return 0, err
+		}
+	}
+	slog.Info("Warmed product cache", "products", len(products))
+	return len(products), nil
+}
+
+// warmAllProducts caches every active product, reading them in batches
+func warmAllProducts(ctx context.Context) (int, error) {
+	var lastID bson.ObjectID
+	warmed := 0
+	for {
+		products, err := mongo.GetActiveProductsAfter(ctx, lastID, suggestIndexBatchSize)
+		if err != nil {
+			return warmed, fmt.Errorf("failed to load products: %w", err)
+		}
+		if len(products) == 0 {
+			break
+		}
+		lastID = products[len(products)-1].ID
+
+		for i := range products {
+			if err := redis.CacheSingleProduct(ctx, &products[i]); err != nil {
+				return warmed, err
+			}
+		}
+		warmed += len(products)
+	}
+	slog.Info("Warmed product cache", "products", warmed)
+	return warmed, nil
+}
+
+// scanLowStock returns the task that records a stock.low event listing every product at
+// or below threshold, so the events webhook can alert whoever reorders stock
+func scanLowStock(threshold int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		result, err := mongo.GetInventory(ctx, mongo.InventoryFilter{LowStockThreshold: threshold}, 1, lowStockScanLimit)
+		if err != nil {
+			return fmt.Errorf("failed to scan inventory: %w", err)
+		}
+		if len(result.Items) == 0 {
+			return nil
+		}
+
+		slog.Info("Low stock products found", "count", result.Pagination.TotalItems, "threshold", threshold)
+		return mongo.EnqueueEvent(ctx, events.StockLow, events.StockLowEvent{Threshold: threshold, Products: result.Items})
+	}
+}